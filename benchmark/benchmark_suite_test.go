@@ -14,10 +14,14 @@ func TestBenchmark(t *testing.T) {
 	RunSpecs(t, "Benchmark Suite")
 }
 
-var size int // file size in MB, will be read from flags
+var (
+	size          int  // file size in MB, will be read from flags
+	disablePacing bool // will be read from flags
+)
 
 func init() {
 	flag.IntVar(&size, "size", 50, "data length (in MB)")
+	flag.BoolVar(&disablePacing, "disable_pacing", false, "disable pacing of outgoing packets")
 }
 
 var _ = BeforeSuite(func() {
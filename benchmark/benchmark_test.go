@@ -44,7 +44,7 @@ var _ = Describe("Benchmarks", func() {
 					ln, err = quic.ListenAddr(
 						"localhost:0",
 						tlsConf,
-						&quic.Config{Versions: []protocol.VersionNumber{version}},
+						&quic.Config{Versions: []protocol.VersionNumber{version}, DisablePacing: disablePacing},
 					)
 					Expect(err).ToNot(HaveOccurred())
 					serverAddr <- ln.Addr()
@@ -66,7 +66,7 @@ var _ = Describe("Benchmarks", func() {
 				sess, err := quic.DialAddr(
 					addr.String(),
 					&tls.Config{InsecureSkipVerify: true, NextProtos: []string{"benchmark"}},
-					&quic.Config{Versions: []protocol.VersionNumber{version}},
+					&quic.Config{Versions: []protocol.VersionNumber{version}, DisablePacing: disablePacing},
 				)
 				Expect(err).ToNot(HaveOccurred())
 				close(handshakeChan)
@@ -13,6 +13,10 @@ type packetBuffer struct {
 	// It doesn't support concurrent use.
 	// It is > 1 when used for coalesced packet.
 	refCount int
+
+	// isMTUProbePacket is set if the buffer contains a path MTU probe packet.
+	// Sending it might fail, if it exceeds the path MTU known to the kernel.
+	isMTUProbePacket bool
 }
 
 // Split increases the refCount.
@@ -67,6 +71,7 @@ var bufferPool sync.Pool
 func getPacketBuffer() *packetBuffer {
 	buf := bufferPool.Get().(*packetBuffer)
 	buf.refCount = 1
+	buf.isMTUProbePacket = false
 	buf.Data = buf.Data[:0]
 	return buf
 }
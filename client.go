@@ -63,6 +63,11 @@ func DialAddr(
 // It uses a new UDP connection and closes this connection when the QUIC session is closed.
 // The hostname for SNI is taken from the given address.
 // The tls.Config.CipherSuites allows setting of TLS 1.3 cipher suites.
+// If the tls.Config.ClientSessionCache contains a session ticket for the server,
+// data sent before completion of the handshake is sent as 0-RTT data.
+// If the server rejects 0-RTT, this data is retransmitted after the handshake completes.
+// 0-RTT data can be replayed by an attacker. Applications must not send non-idempotent
+// requests before the HandshakeComplete context is done.
 func DialAddrEarly(
 	addr string,
 	tlsConf *tls.Config,
@@ -83,7 +88,7 @@ func DialAddrEarlyContext(
 	if err != nil {
 		return nil, err
 	}
-	utils.Logger.WithPrefix(utils.DefaultLogger, "client").Debugf("Returning early session")
+	getLogger(config).WithPrefix("client").Debugf("Returning early session")
 	return sess, nil
 }
 
@@ -195,6 +200,9 @@ func dialContext(
 	if err != nil {
 		return nil, err
 	}
+	if config.UnknownPacketHandler != nil {
+		packetHandlers.SetUnknownPacketHandler(config.UnknownPacketHandler)
+	}
 	c, err := newClient(pconn, remoteAddr, config, tlsConf, host, use0RTT, createdPacketConn)
 	if err != nil {
 		return nil, err
@@ -255,14 +263,14 @@ func newClient(
 	c := &client{
 		srcConnID:         srcConnID,
 		destConnID:        destConnID,
-		conn:              newSendConn(pconn, remoteAddr),
+		conn:              newSendConn(pconn, remoteAddr, maybeEnableDF(pconn, config)),
 		createdPacketConn: createdPacketConn,
 		use0RTT:           use0RTT,
 		tlsConf:           tlsConf,
 		config:            config,
 		version:           config.Versions[0],
 		handshakeChan:     make(chan struct{}),
-		logger:            utils.DefaultLogger.WithPrefix("client"),
+		logger:            getLogger(config).WithPrefix("client"),
 	}
 	return c, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/utils"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/logging"
 )
 
 // Clone clones a Config
@@ -29,9 +30,54 @@ func validateConfig(config *Config) error {
 	if config.MaxIncomingUniStreams > 1<<60 {
 		return errors.New("invalid value for Config.MaxIncomingUniStreams")
 	}
+	if config.ConnectionAttemptRate < 0 {
+		return errors.New("invalid value for Config.ConnectionAttemptRate")
+	}
+	if config.MaxPaths < 0 {
+		return errors.New("invalid value for Config.MaxPaths")
+	}
+	if config.ConnectionAttemptBurst < 0 {
+		return errors.New("invalid value for Config.ConnectionAttemptBurst")
+	}
+	if config.StreamLimitTimeout < 0 {
+		return errors.New("invalid value for Config.StreamLimitTimeout")
+	}
+	if config.SendBufferLowWatermark > config.SendBufferHighWatermark {
+		return errors.New("invalid value for Config.SendBufferLowWatermark")
+	}
+	if config.InitialPacketSize != 0 && config.InitialPacketSize < protocol.MinInitialPacketSize {
+		return errors.New("invalid value for Config.InitialPacketSize")
+	}
+	if config.MaxPacketSize != 0 && config.MaxPacketSize < protocol.MinInitialPacketSize {
+		return errors.New("invalid value for Config.MaxPacketSize")
+	}
+	if config.InitialStreamReceiveWindow >= 1<<62 {
+		return errors.New("invalid value for Config.InitialStreamReceiveWindow")
+	}
+	if config.InitialConnectionReceiveWindow >= 1<<62 {
+		return errors.New("invalid value for Config.InitialConnectionReceiveWindow")
+	}
+	if config.InitialStreamReceiveWindowBidiLocal >= 1<<62 {
+		return errors.New("invalid value for Config.InitialStreamReceiveWindowBidiLocal")
+	}
+	if config.InitialStreamReceiveWindowBidiRemote >= 1<<62 {
+		return errors.New("invalid value for Config.InitialStreamReceiveWindowBidiRemote")
+	}
+	if config.InitialStreamReceiveWindowUni >= 1<<62 {
+		return errors.New("invalid value for Config.InitialStreamReceiveWindowUni")
+	}
 	return nil
 }
 
+// getLogger returns the logger used for the config.
+// It may be called with nil.
+func getLogger(config *Config) utils.Logger {
+	if config == nil || config.Logger == nil {
+		return utils.DefaultLogger
+	}
+	return utils.NewExternalLogger(config.Logger)
+}
+
 // populateServerConfig populates fields in the quic.Config with their default values, if none are set
 // it may be called with nil
 func populateServerConfig(config *Config) *Config {
@@ -91,21 +137,74 @@ func populateConfig(config *Config) *Config {
 	} else if maxIncomingUniStreams < 0 {
 		maxIncomingUniStreams = 0
 	}
+	maxPaths := config.MaxPaths
+	if maxPaths == 0 {
+		maxPaths = protocol.DefaultMaxPaths
+	}
+	sendBufferLowWatermark := config.SendBufferLowWatermark
+	if sendBufferLowWatermark == 0 {
+		sendBufferLowWatermark = config.SendBufferHighWatermark / 2
+	}
+	initialPacketSize := config.InitialPacketSize
+	if initialPacketSize == 0 {
+		initialPacketSize = protocol.MinInitialPacketSize
+	}
+	maxPacketSize := config.MaxPacketSize
+	if maxPacketSize == 0 || maxPacketSize > uint16(protocol.MaxReceivePacketSize) {
+		maxPacketSize = uint16(protocol.MaxReceivePacketSize)
+	}
+	tracer := config.Tracer
+	if len(config.QlogDir) > 0 {
+		qlogTracer := newQlogDirTracer(config.QlogDir, getLogger(config))
+		if tracer == nil {
+			tracer = qlogTracer
+		} else {
+			tracer = logging.NewMultiplexedTracer(tracer, qlogTracer)
+		}
+	}
 
 	return &Config{
 		Versions:                              versions,
 		HandshakeIdleTimeout:                  handshakeIdleTimeout,
 		MaxIdleTimeout:                        idleTimeout,
+		SendStallTimeout:                      config.SendStallTimeout,
+		StreamLimitTimeout:                    config.StreamLimitTimeout,
 		AcceptToken:                           config.AcceptToken,
+		ConnectionAttemptRate:                 config.ConnectionAttemptRate,
+		ConnectionAttemptBurst:                config.ConnectionAttemptBurst,
 		KeepAlive:                             config.KeepAlive,
+		CloseOnIdle:                           config.CloseOnIdle,
+		EnableFairStreamScheduling:            config.EnableFairStreamScheduling,
+		DisableKeyUpdate:                      config.DisableKeyUpdate,
+		DisablePacing:                         config.DisablePacing,
 		MaxReceiveStreamFlowControlWindow:     maxReceiveStreamFlowControlWindow,
 		MaxReceiveConnectionFlowControlWindow: maxReceiveConnectionFlowControlWindow,
+		MaxReceiveUniStreamFlowControlWindow:  config.MaxReceiveUniStreamFlowControlWindow,
+		InitialStreamReceiveWindow:            config.InitialStreamReceiveWindow,
+		InitialConnectionReceiveWindow:        config.InitialConnectionReceiveWindow,
+		InitialStreamReceiveWindowBidiLocal:   config.InitialStreamReceiveWindowBidiLocal,
+		InitialStreamReceiveWindowBidiRemote:  config.InitialStreamReceiveWindowBidiRemote,
+		InitialStreamReceiveWindowUni:         config.InitialStreamReceiveWindowUni,
 		MaxIncomingStreams:                    maxIncomingStreams,
 		MaxIncomingUniStreams:                 maxIncomingUniStreams,
+		MaxConnectionBytes:                    config.MaxConnectionBytes,
+		MaxConnectionBytesErrorCode:           config.MaxConnectionBytesErrorCode,
+		MaxUnproductivePackets:                config.MaxUnproductivePackets,
+		MaxUnproductivePacketsErrorCode:       config.MaxUnproductivePacketsErrorCode,
+		SendBufferHighWatermark:               config.SendBufferHighWatermark,
+		SendBufferLowWatermark:                sendBufferLowWatermark,
+		InitialPacketSize:                     initialPacketSize,
+		MaxPacketSize:                         maxPacketSize,
+		NewCongestionController:               config.NewCongestionController,
 		ConnectionIDLength:                    config.ConnectionIDLength,
 		StatelessResetKey:                     config.StatelessResetKey,
+		UnknownPacketHandler:                  config.UnknownPacketHandler,
+		MaxPaths:                              maxPaths,
+		EnableActiveMigration:                 config.EnableActiveMigration,
 		TokenStore:                            config.TokenStore,
 		EnableDatagrams:                       config.EnableDatagrams,
-		Tracer:                                config.Tracer,
+		EnableRawTransportParameters:          config.EnableRawTransportParameters,
+		Tracer:                                tracer,
+		Logger:                                config.Logger,
 	}
 }
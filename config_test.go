@@ -6,8 +6,10 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -30,6 +32,45 @@ var _ = Describe("Config", func() {
 		It("errors on too large values for MaxIncomingUniStreams", func() {
 			Expect(validateConfig(&Config{MaxIncomingUniStreams: 1<<60 + 1})).To(MatchError("invalid value for Config.MaxIncomingUniStreams"))
 		})
+
+		It("errors on too large initial stream receive windows", func() {
+			Expect(validateConfig(&Config{InitialStreamReceiveWindow: 1 << 62})).To(MatchError("invalid value for Config.InitialStreamReceiveWindow"))
+			Expect(validateConfig(&Config{InitialStreamReceiveWindowBidiLocal: 1 << 62})).To(MatchError("invalid value for Config.InitialStreamReceiveWindowBidiLocal"))
+			Expect(validateConfig(&Config{InitialStreamReceiveWindowBidiRemote: 1 << 62})).To(MatchError("invalid value for Config.InitialStreamReceiveWindowBidiRemote"))
+			Expect(validateConfig(&Config{InitialStreamReceiveWindowUni: 1 << 62})).To(MatchError("invalid value for Config.InitialStreamReceiveWindowUni"))
+		})
+
+		It("errors on too large initial connection receive windows", func() {
+			Expect(validateConfig(&Config{InitialConnectionReceiveWindow: 1 << 62})).To(MatchError("invalid value for Config.InitialConnectionReceiveWindow"))
+		})
+
+		It("errors on negative path limits", func() {
+			Expect(validateConfig(&Config{MaxPaths: -1})).To(MatchError("invalid value for Config.MaxPaths"))
+		})
+
+		It("errors on negative connection attempt rates", func() {
+			Expect(validateConfig(&Config{ConnectionAttemptRate: -1})).To(MatchError("invalid value for Config.ConnectionAttemptRate"))
+			Expect(validateConfig(&Config{ConnectionAttemptBurst: -1})).To(MatchError("invalid value for Config.ConnectionAttemptBurst"))
+		})
+
+		It("errors if the send buffer low watermark is larger than the high watermark", func() {
+			Expect(validateConfig(&Config{SendBufferHighWatermark: 100, SendBufferLowWatermark: 50})).To(Succeed())
+			Expect(validateConfig(&Config{SendBufferHighWatermark: 100, SendBufferLowWatermark: 101})).To(MatchError("invalid value for Config.SendBufferLowWatermark"))
+		})
+
+		It("errors if the initial packet size is smaller than the minimum", func() {
+			Expect(validateConfig(&Config{InitialPacketSize: protocol.MinInitialPacketSize})).To(Succeed())
+			Expect(validateConfig(&Config{InitialPacketSize: protocol.MinInitialPacketSize - 1})).To(MatchError("invalid value for Config.InitialPacketSize"))
+		})
+
+		It("errors if the max packet size is smaller than the minimum", func() {
+			Expect(validateConfig(&Config{MaxPacketSize: protocol.MinInitialPacketSize})).To(Succeed())
+			Expect(validateConfig(&Config{MaxPacketSize: protocol.MinInitialPacketSize - 1})).To(MatchError("invalid value for Config.MaxPacketSize"))
+		})
+
+		It("errors on a negative stream limit timeout", func() {
+			Expect(validateConfig(&Config{StreamLimitTimeout: -time.Second})).To(MatchError("invalid value for Config.StreamLimitTimeout"))
+		})
 	})
 
 	configWithNonZeroNonFunctionFields := func() *Config {
@@ -45,8 +86,10 @@ var _ = Describe("Config", func() {
 			}
 
 			switch fn := typ.Field(i).Name; fn {
-			case "AcceptToken", "GetLogWriter":
+			case "AcceptToken", "GetLogWriter", "NewCongestionController", "UnknownPacketHandler":
 				// Can't compare functions.
+			case "QlogDir":
+				// The QlogDir is converted to a Tracer when populating the config.
 			case "Versions":
 				f.Set(reflect.ValueOf([]VersionNumber{1, 2, 3}))
 			case "ConnectionIDLength":
@@ -55,24 +98,76 @@ var _ = Describe("Config", func() {
 				f.Set(reflect.ValueOf(time.Second))
 			case "MaxIdleTimeout":
 				f.Set(reflect.ValueOf(time.Hour))
+			case "SendStallTimeout":
+				f.Set(reflect.ValueOf(time.Minute))
+			case "StreamLimitTimeout":
+				f.Set(reflect.ValueOf(2 * time.Minute))
+			case "ConnectionAttemptRate":
+				f.Set(reflect.ValueOf(19))
+			case "ConnectionAttemptBurst":
+				f.Set(reflect.ValueOf(20))
+			case "MaxPaths":
+				f.Set(reflect.ValueOf(3))
 			case "TokenStore":
 				f.Set(reflect.ValueOf(NewLRUTokenStore(2, 3)))
 			case "MaxReceiveStreamFlowControlWindow":
 				f.Set(reflect.ValueOf(uint64(9)))
 			case "MaxReceiveConnectionFlowControlWindow":
 				f.Set(reflect.ValueOf(uint64(10)))
+			case "MaxReceiveUniStreamFlowControlWindow":
+				f.Set(reflect.ValueOf(uint64(13)))
+			case "InitialStreamReceiveWindow":
+				f.Set(reflect.ValueOf(uint64(19)))
+			case "InitialConnectionReceiveWindow":
+				f.Set(reflect.ValueOf(uint64(20)))
+			case "InitialStreamReceiveWindowBidiLocal":
+				f.Set(reflect.ValueOf(uint64(16)))
+			case "InitialStreamReceiveWindowBidiRemote":
+				f.Set(reflect.ValueOf(uint64(17)))
+			case "InitialStreamReceiveWindowUni":
+				f.Set(reflect.ValueOf(uint64(18)))
 			case "MaxIncomingStreams":
 				f.Set(reflect.ValueOf(int64(11)))
 			case "MaxIncomingUniStreams":
 				f.Set(reflect.ValueOf(int64(12)))
+			case "MaxConnectionBytes":
+				f.Set(reflect.ValueOf(uint64(14)))
+			case "MaxConnectionBytesErrorCode":
+				f.Set(reflect.ValueOf(ErrorCode(15)))
+			case "MaxUnproductivePackets":
+				f.Set(reflect.ValueOf(uint64(1000)))
+			case "MaxUnproductivePacketsErrorCode":
+				f.Set(reflect.ValueOf(ErrorCode(21)))
+			case "SendBufferHighWatermark":
+				f.Set(reflect.ValueOf(uint64(17)))
+			case "SendBufferLowWatermark":
+				f.Set(reflect.ValueOf(uint64(16)))
+			case "InitialPacketSize":
+				f.Set(reflect.ValueOf(uint16(1300)))
+			case "MaxPacketSize":
+				f.Set(reflect.ValueOf(uint16(1400)))
 			case "StatelessResetKey":
 				f.Set(reflect.ValueOf([]byte{1, 2, 3, 4}))
 			case "KeepAlive":
 				f.Set(reflect.ValueOf(true))
+			case "CloseOnIdle":
+				f.Set(reflect.ValueOf(true))
+			case "EnableFairStreamScheduling":
+				f.Set(reflect.ValueOf(true))
+			case "DisableKeyUpdate":
+				f.Set(reflect.ValueOf(true))
+			case "DisablePacing":
+				f.Set(reflect.ValueOf(true))
 			case "EnableDatagrams":
 				f.Set(reflect.ValueOf(true))
+			case "EnableActiveMigration":
+				f.Set(reflect.ValueOf(true))
+			case "EnableRawTransportParameters":
+				f.Set(reflect.ValueOf(true))
 			case "Tracer":
 				f.Set(reflect.ValueOf(mocklogging.NewMockTracer(mockCtrl)))
+			case "Logger":
+				f.Set(reflect.ValueOf(utils.DefaultLogger))
 			default:
 				Fail(fmt.Sprintf("all fields must be accounted for, but saw unknown field %q", fn))
 			}
@@ -131,6 +226,30 @@ var _ = Describe("Config", func() {
 			Expect(calledAcceptToken).To(BeTrue())
 		})
 
+		It("populates the congestion controller constructor", func() {
+			var calledNewCongestionController bool
+			c1 := &Config{
+				NewCongestionController: func(*congestion.RTTStats) congestion.Controller {
+					calledNewCongestionController = true
+					return nil
+				},
+			}
+			c2 := populateConfig(c1)
+			c2.NewCongestionController(utils.NewRTTStats())
+			Expect(calledNewCongestionController).To(BeTrue())
+			Expect(populateConfig(&Config{}).NewCongestionController).To(BeNil())
+		})
+
+		It("populates the unknown packet handler", func() {
+			var calledUnknownPacketHandler bool
+			c1 := &Config{
+				UnknownPacketHandler: func([]byte, net.Addr) { calledUnknownPacketHandler = true },
+			}
+			c2 := populateConfig(c1)
+			c2.UnknownPacketHandler(nil, &net.UDPAddr{})
+			Expect(calledUnknownPacketHandler).To(BeTrue())
+		})
+
 		It("copies non-function fields", func() {
 			c := configWithNonZeroNonFunctionFields()
 			Expect(populateConfig(c)).To(Equal(c))
@@ -144,6 +263,29 @@ var _ = Describe("Config", func() {
 			Expect(c.MaxReceiveConnectionFlowControlWindow).To(BeEquivalentTo(protocol.DefaultMaxReceiveConnectionFlowControlWindow))
 			Expect(c.MaxIncomingStreams).To(BeEquivalentTo(protocol.DefaultMaxIncomingStreams))
 			Expect(c.MaxIncomingUniStreams).To(BeEquivalentTo(protocol.DefaultMaxIncomingUniStreams))
+			Expect(c.InitialPacketSize).To(BeEquivalentTo(protocol.MinInitialPacketSize))
+			Expect(c.MaxPacketSize).To(BeEquivalentTo(protocol.MaxReceivePacketSize))
+			Expect(c.MaxPaths).To(Equal(protocol.DefaultMaxPaths))
+		})
+
+		It("reduces the max packet size to the size of the packet buffers", func() {
+			c := populateConfig(&Config{MaxPacketSize: uint16(protocol.MaxReceivePacketSize) + 1})
+			Expect(c.MaxPacketSize).To(BeEquivalentTo(protocol.MaxReceivePacketSize))
+		})
+
+		It("adds a qlog tracer if a QlogDir is set", func() {
+			c := populateConfig(&Config{QlogDir: "qlogs"})
+			Expect(c.Tracer).ToNot(BeNil())
+			Expect(c.QlogDir).To(BeEmpty())
+			tracer := mocklogging.NewMockTracer(mockCtrl)
+			c = populateConfig(&Config{QlogDir: "qlogs", Tracer: tracer})
+			Expect(c.Tracer).ToNot(BeNil())
+			Expect(c.Tracer).ToNot(Equal(tracer))
+		})
+
+		It("defaults the send buffer low watermark to half of the high watermark", func() {
+			c := populateConfig(&Config{SendBufferHighWatermark: 1000})
+			Expect(c.SendBufferLowWatermark).To(BeEquivalentTo(500))
 		})
 
 		It("populates empty fields with default values, for the server", func() {
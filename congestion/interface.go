@@ -0,0 +1,49 @@
+// Package congestion defines the interface for congestion controllers used by quic-go.
+// This package should not be considered stable
+package congestion
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+type (
+	// A ByteCount is used to count bytes.
+	ByteCount = protocol.ByteCount
+	// The PacketNumber is the packet number of a packet.
+	PacketNumber = protocol.PacketNumber
+	// The RTTStats contain the RTT estimates of a connection.
+	RTTStats = utils.RTTStats
+)
+
+// A Controller performs congestion control and pacing for a connection.
+// It is only used from the connection's run loop, so implementations don't need to be safe for concurrent use.
+type Controller interface {
+	// TimeUntilSend returns when the next packet should be sent, for pacing.
+	// A zero time means that a packet can be sent immediately.
+	TimeUntilSend(bytesInFlight ByteCount) time.Time
+	// HasPacingBudget says if the pacer allows sending a packet right now.
+	HasPacingBudget() bool
+	// OnPacketSent is called for every packet sent.
+	OnPacketSent(sentTime time.Time, bytesInFlight ByteCount, packetNumber PacketNumber, bytes ByteCount, isRetransmittable bool)
+	// CanSend says if the congestion window allows sending more data.
+	CanSend(bytesInFlight ByteCount) bool
+	// MaybeExitSlowStart is called when an ACK is received, before OnPacketAcked is called for the newly acknowledged packets.
+	MaybeExitSlowStart()
+	// OnPacketAcked is called for every newly acknowledged packet that was counted as bytes in flight.
+	OnPacketAcked(number PacketNumber, ackedBytes ByteCount, priorInFlight ByteCount, eventTime time.Time)
+	// OnPacketLost is called for every packet that was declared lost and was counted as bytes in flight.
+	OnPacketLost(number PacketNumber, lostBytes ByteCount, priorInFlight ByteCount)
+	// GetCongestionWindow returns the current congestion window.
+	GetCongestionWindow() ByteCount
+}
+
+// A NamedController is a Controller that reports the name of its congestion control algorithm.
+// The name is reported in the SessionStats, allowing applications to check which algorithm is used.
+type NamedController interface {
+	Controller
+	// Name returns the name of the congestion control algorithm, e.g. "bbr".
+	Name() string
+}
@@ -36,6 +36,36 @@ func wrapConn(pc net.PacketConn) (connection, error) {
 	return newConn(c)
 }
 
+// enableDF sets the don't-fragment bit on the socket, if the platform supports it.
+// Path MTU discovery is only safe if the DF bit is set: otherwise, probe packets
+// would be fragmented by the IP layer, and always appear to succeed.
+func enableDF(pc net.PacketConn) bool {
+	c, ok := pc.(interface {
+		SyscallConn() (syscall.RawConn, error)
+	})
+	if !ok {
+		return false
+	}
+	rawConn, err := c.SyscallConn()
+	if err != nil {
+		return false
+	}
+	if err := setDF(rawConn); err != nil {
+		utils.DefaultLogger.Debugf("Disabling path MTU discovery: %s", err)
+		return false
+	}
+	return true
+}
+
+// maybeEnableDF sets the don't-fragment bit on the socket, if path MTU discovery is enabled by the config.
+// It is called once per socket, when a server or client starts using it.
+func maybeEnableDF(pc net.PacketConn, config *Config) bool {
+	if protocol.ByteCount(config.MaxPacketSize) <= getMaxPacketSize(pc.LocalAddr()) {
+		return false
+	}
+	return enableDF(pc)
+}
+
 type basicConn struct {
 	net.PacketConn
 }
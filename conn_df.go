@@ -0,0 +1,18 @@
+// +build !linux,!windows
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+)
+
+func setDF(syscall.RawConn) error {
+	// Setting the DF bit is not yet supported on this platform.
+	// Path MTU discovery is disabled, since probe packets would be fragmented.
+	return errors.New("setting DF not supported on this platform")
+}
+
+func isMsgSizeErr(err error) bool {
+	return false
+}
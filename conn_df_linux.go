@@ -0,0 +1,41 @@
+// +build linux
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+func setDF(rawConn syscall.RawConn) error {
+	// Enabling IP_MTU_DISCOVER makes the kernel set the DF bit.
+	// Datagrams that are larger than the path MTU known to the kernel are not fragmented,
+	// instead sending fails with EMSGSIZE.
+	var errIPv4, errIPv6 error
+	if err := rawConn.Control(func(fd uintptr) {
+		errIPv4 = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO)
+		errIPv6 = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_DO)
+	}); err != nil {
+		return err
+	}
+	switch {
+	case errIPv4 == nil && errIPv6 == nil:
+		utils.DefaultLogger.Debugf("Setting DF for IPv4 and IPv6.")
+	case errIPv4 == nil && errIPv6 != nil:
+		utils.DefaultLogger.Debugf("Setting DF for IPv4.")
+	case errIPv4 != nil && errIPv6 == nil:
+		utils.DefaultLogger.Debugf("Setting DF for IPv6.")
+	case errIPv4 != nil && errIPv6 != nil:
+		return errors.New("setting DF failed for both IPv4 and IPv6")
+	}
+	return nil
+}
+
+func isMsgSizeErr(err error) bool {
+	// https://man7.org/linux/man-pages/man7/udp.7.html
+	return errors.Is(err, unix.EMSGSIZE)
+}
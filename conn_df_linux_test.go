@@ -0,0 +1,100 @@
+// +build linux
+
+package quic
+
+import (
+	"net"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Setting the DF bit", func() {
+	msgSizeErr := &net.OpError{Op: "write", Err: os.NewSyscallError("sendto", unix.EMSGSIZE)}
+
+	It("sets the DF bit on UDP sockets", func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Expect(enableDF(conn)).To(BeTrue())
+		rawConn, err := conn.SyscallConn()
+		Expect(err).ToNot(HaveOccurred())
+		var val int
+		var serr error
+		Expect(rawConn.Control(func(fd uintptr) {
+			val, serr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+		})).To(Succeed())
+		Expect(serr).ToNot(HaveOccurred())
+		Expect(val).To(Equal(unix.IP_PMTUDISC_DO))
+	})
+
+	It("only sets the DF bit if path MTU discovery is enabled", func() {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		Expect(maybeEnableDF(conn, &Config{MaxPacketSize: protocol.MaxPacketSizeIPv4})).To(BeFalse())
+		rawConn, err := conn.SyscallConn()
+		Expect(err).ToNot(HaveOccurred())
+		var val int
+		var serr error
+		Expect(rawConn.Control(func(fd uintptr) {
+			val, serr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER)
+		})).To(Succeed())
+		Expect(serr).ToNot(HaveOccurred())
+		Expect(val).ToNot(Equal(unix.IP_PMTUDISC_DO))
+		Expect(maybeEnableDF(conn, &Config{MaxPacketSize: protocol.MaxPacketSizeIPv4 + 1})).To(BeTrue())
+	})
+
+	It("doesn't set the DF bit on other net.PacketConns", func() {
+		Expect(enableDF(NewMockPacketConn(mockCtrl))).To(BeFalse())
+	})
+
+	It("detects EMSGSIZE errors", func() {
+		Expect(isMsgSizeErr(msgSizeErr)).To(BeTrue())
+		Expect(isMsgSizeErr(os.ErrClosed)).To(BeFalse())
+	})
+
+	It("doesn't close the send queue when an MTU probe packet is too large to be sent", func() {
+		c := NewMockSendConn(mockCtrl)
+		q := newSendQueue(c)
+		buf := getPacketBuffer()
+		buf.Data = append(buf.Data[:0], []byte("foobar")...)
+		buf.isMTUProbePacket = true
+		q.Send(buf)
+		written := make(chan struct{})
+		c.EXPECT().Write([]byte("foobar")).DoAndReturn(func([]byte) error {
+			close(written)
+			return msgSizeErr
+		})
+		errChan := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			errChan <- q.Run()
+		}()
+
+		Eventually(written).Should(BeClosed())
+		Consistently(errChan).ShouldNot(Receive())
+		q.Close()
+		Eventually(errChan).Should(Receive(BeNil()))
+	})
+
+	It("closes the send queue when a regular packet is too large to be sent", func() {
+		c := NewMockSendConn(mockCtrl)
+		q := newSendQueue(c)
+		buf := getPacketBuffer()
+		buf.Data = append(buf.Data[:0], []byte("foobar")...)
+		q.Send(buf)
+		c.EXPECT().Write([]byte("foobar")).Return(msgSizeErr)
+		errChan := make(chan error, 1)
+		go func() {
+			defer GinkgoRecover()
+			errChan <- q.Run()
+		}()
+		Eventually(errChan).Should(Receive(Equal(msgSizeErr)))
+	})
+})
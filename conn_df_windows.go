@@ -0,0 +1,44 @@
+// +build windows
+
+package quic
+
+import (
+	"errors"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+)
+
+const (
+	// IP_DONTFRAGMENT and IPV6_DONTFRAG, as defined in ws2ipdef.h
+	ipDontFragment   = 14
+	ipv6DontFragment = 14
+)
+
+func setDF(rawConn syscall.RawConn) error {
+	var errIPv4, errIPv6 error
+	if err := rawConn.Control(func(fd uintptr) {
+		errIPv4 = windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IP, ipDontFragment, 1)
+		errIPv6 = windows.SetsockoptInt(windows.Handle(fd), windows.IPPROTO_IPV6, ipv6DontFragment, 1)
+	}); err != nil {
+		return err
+	}
+	switch {
+	case errIPv4 == nil && errIPv6 == nil:
+		utils.DefaultLogger.Debugf("Setting DF for IPv4 and IPv6.")
+	case errIPv4 == nil && errIPv6 != nil:
+		utils.DefaultLogger.Debugf("Setting DF for IPv4.")
+	case errIPv4 != nil && errIPv6 == nil:
+		utils.DefaultLogger.Debugf("Setting DF for IPv6.")
+	case errIPv4 != nil && errIPv6 != nil:
+		return errors.New("setting DF failed for both IPv4 and IPv6")
+	}
+	return nil
+}
+
+func isMsgSizeErr(err error) bool {
+	// https://docs.microsoft.com/en-us/windows/win32/winsock/windows-sockets-error-codes-2
+	return errors.Is(err, windows.WSAEMSGSIZE)
+}
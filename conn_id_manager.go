@@ -202,6 +202,20 @@ func (h *connIDManager) shouldUpdateConnID() bool {
 		h.packetsSinceLastChange >= h.packetsPerConnectionID
 }
 
+// SwitchForMigration switches to an unused connection ID before migrating to a new path,
+// such that packets sent on the new path can't be linked to packets sent on the old path.
+// It returns false if the peer didn't provide an unused connection ID.
+func (h *connIDManager) SwitchForMigration() bool {
+	if h.activeConnectionID.Len() == 0 {
+		return true
+	}
+	if h.queue.Len() == 0 {
+		return false
+	}
+	h.updateConnectionID()
+	return true
+}
+
 func (h *connIDManager) Get() protocol.ConnectionID {
 	if h.shouldUpdateConnID() {
 		h.updateConnectionID()
@@ -345,6 +345,25 @@ var _ = Describe("Connection ID Manager", func() {
 		Expect(removedTokens[0]).To(Equal(protocol.StatelessResetToken{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}))
 	})
 
+	It("switches to an unused connection ID when migrating", func() {
+		Expect(m.SwitchForMigration()).To(BeFalse())
+		Expect(m.Get()).To(Equal(initialConnID))
+		Expect(m.Add(&wire.NewConnectionIDFrame{
+			SequenceNumber:      1,
+			ConnectionID:        protocol.ConnectionID{1, 2, 3, 4},
+			StatelessResetToken: protocol.StatelessResetToken{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+		})).To(Succeed())
+		Expect(m.SwitchForMigration()).To(BeTrue())
+		Expect(m.Get()).To(Equal(protocol.ConnectionID{1, 2, 3, 4}))
+		Expect(frameQueue).To(ContainElement(&wire.RetireConnectionIDFrame{SequenceNumber: 0}))
+		Expect(m.SwitchForMigration()).To(BeFalse())
+	})
+
+	It("doesn't need a new connection ID for migrating when using zero-length connection IDs", func() {
+		m.ChangeInitialConnID(protocol.ConnectionID{})
+		Expect(m.SwitchForMigration()).To(BeTrue())
+	})
+
 	It("removes the currently active stateless reset token when it is closed", func() {
 		m.Close()
 		Expect(removedTokens).To(BeEmpty())
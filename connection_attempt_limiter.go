@@ -0,0 +1,109 @@
+package quic
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+type connectionAttemptDecision uint8
+
+const (
+	// connectionAttemptAccept means that the connection attempt may proceed
+	connectionAttemptAccept connectionAttemptDecision = iota
+	// connectionAttemptRetry means that the client should be sent a Retry
+	connectionAttemptRetry
+	// connectionAttemptDrop means that the Initial packet should be dropped
+	connectionAttemptDrop
+)
+
+type connectionAttemptBucket struct {
+	tokens     float64
+	lastUpdate time.Time
+}
+
+// The connectionAttemptLimiter limits the rate of connection attempts per source prefix, using a token bucket.
+// Once the bucket is empty, clients are sent a Retry for another burst of attempts.
+// Beyond that, Initial packets are dropped.
+type connectionAttemptLimiter struct {
+	mutex sync.Mutex
+
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*connectionAttemptBucket
+}
+
+func newConnectionAttemptLimiter(rate, burst int) *connectionAttemptLimiter {
+	if burst == 0 {
+		burst = rate
+	}
+	return &connectionAttemptLimiter{
+		rate:    float64(rate),
+		burst:   float64(burst),
+		buckets: make(map[string]*connectionAttemptBucket),
+	}
+}
+
+func (l *connectionAttemptLimiter) Allow(addr net.Addr, now time.Time) connectionAttemptDecision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	prefix := connectionAttemptPrefix(addr)
+	b, ok := l.buckets[prefix]
+	if !ok {
+		if len(l.buckets) >= protocol.MaxConnectionAttemptPrefixes {
+			l.evict(now)
+		}
+		if len(l.buckets) >= protocol.MaxConnectionAttemptPrefixes {
+			return connectionAttemptRetry
+		}
+		b = &connectionAttemptBucket{tokens: l.burst, lastUpdate: now}
+		l.buckets[prefix] = b
+	}
+	l.refill(b, now)
+	if b.tokens <= -l.burst {
+		return connectionAttemptDrop
+	}
+	b.tokens--
+	if b.tokens < 0 {
+		return connectionAttemptRetry
+	}
+	return connectionAttemptAccept
+}
+
+func (l *connectionAttemptLimiter) refill(b *connectionAttemptBucket, now time.Time) {
+	if now.After(b.lastUpdate) {
+		b.tokens += now.Sub(b.lastUpdate).Seconds() * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+	}
+	b.lastUpdate = now
+}
+
+// evict deletes all buckets that have been refilled completely.
+// They are indistinguishable from a newly created bucket.
+func (l *connectionAttemptLimiter) evict(now time.Time) {
+	for prefix, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, prefix)
+		}
+	}
+}
+
+func connectionAttemptPrefix(addr net.Addr) string {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return addr.String()
+	}
+	if ip := udpAddr.IP.To4(); ip != nil {
+		return string(ip[:3])
+	}
+	if len(udpAddr.IP) == net.IPv6len {
+		return string(udpAddr.IP[:6])
+	}
+	return udpAddr.IP.String()
+}
@@ -0,0 +1,94 @@
+package quic
+
+import (
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connection Attempt Limiter", func() {
+	var l *connectionAttemptLimiter
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 1), Port: 1234}
+
+	BeforeEach(func() {
+		l = newConnectionAttemptLimiter(10, 5)
+	})
+
+	It("accepts a burst, then sends Retries, then drops", func() {
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			Expect(l.Allow(addr, now)).To(Equal(connectionAttemptAccept))
+		}
+		for i := 0; i < 5; i++ {
+			Expect(l.Allow(addr, now)).To(Equal(connectionAttemptRetry))
+		}
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptDrop))
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptDrop))
+	})
+
+	It("defaults the burst to the rate", func() {
+		l = newConnectionAttemptLimiter(3, 0)
+		now := time.Now()
+		for i := 0; i < 3; i++ {
+			Expect(l.Allow(addr, now)).To(Equal(connectionAttemptAccept))
+		}
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptRetry))
+	})
+
+	It("refills the bucket over time", func() {
+		now := time.Now()
+		for i := 0; i < 10; i++ {
+			l.Allow(addr, now)
+		}
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptDrop))
+		// 10 attempts per second: after 100ms, one more attempt is allowed
+		now = now.Add(100 * time.Millisecond)
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptRetry))
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptDrop))
+		// the bucket never holds more than the burst
+		now = now.Add(time.Hour)
+		for i := 0; i < 5; i++ {
+			Expect(l.Allow(addr, now)).To(Equal(connectionAttemptAccept))
+		}
+		Expect(l.Allow(addr, now)).To(Equal(connectionAttemptRetry))
+	})
+
+	It("tracks IPv4 addresses per /24", func() {
+		now := time.Now()
+		for i := 0; i < 5; i++ {
+			Expect(l.Allow(&net.UDPAddr{IP: net.IPv4(192, 168, 1, byte(i))}, now)).To(Equal(connectionAttemptAccept))
+		}
+		Expect(l.Allow(&net.UDPAddr{IP: net.IPv4(192, 168, 1, 100)}, now)).To(Equal(connectionAttemptRetry))
+		Expect(l.Allow(&net.UDPAddr{IP: net.IPv4(192, 168, 2, 1)}, now)).To(Equal(connectionAttemptAccept))
+	})
+
+	It("tracks IPv6 addresses per /48", func() {
+		now := time.Now()
+		ip := net.ParseIP("2001:db8:1::1")
+		for i := 0; i < 5; i++ {
+			ip := append(net.IP{}, ip...)
+			ip[15] = byte(i)
+			ip[7] = byte(i)
+			Expect(l.Allow(&net.UDPAddr{IP: ip}, now)).To(Equal(connectionAttemptAccept))
+		}
+		Expect(l.Allow(&net.UDPAddr{IP: ip}, now)).To(Equal(connectionAttemptRetry))
+		Expect(l.Allow(&net.UDPAddr{IP: net.ParseIP("2001:db8:2::1")}, now)).To(Equal(connectionAttemptAccept))
+	})
+
+	It("limits the number of tracked prefixes", func() {
+		now := time.Now()
+		for i := 0; i < protocol.MaxConnectionAttemptPrefixes; i++ {
+			ip := net.IPv4(10, byte(i>>8), byte(i), 1)
+			Expect(l.Allow(&net.UDPAddr{IP: ip}, now)).To(Equal(connectionAttemptAccept))
+		}
+		Expect(l.buckets).To(HaveLen(protocol.MaxConnectionAttemptPrefixes))
+		Expect(l.Allow(&net.UDPAddr{IP: net.IPv4(11, 0, 0, 1)}, now)).To(Equal(connectionAttemptRetry))
+		// once the buckets are refilled, they are evicted
+		Expect(l.Allow(&net.UDPAddr{IP: net.IPv4(11, 0, 0, 1)}, now.Add(time.Second))).To(Equal(connectionAttemptAccept))
+		Expect(l.buckets).To(HaveLen(1))
+	})
+})
@@ -1,14 +1,36 @@
 package quic
 
 import (
+	"context"
+	"net"
+	"sync/atomic"
+
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 )
 
+// A receivedDatagram is the payload of a DATAGRAM frame,
+// together with the address of the packet that carried the frame.
+type receivedDatagram struct {
+	data       []byte
+	remoteAddr net.Addr
+}
+
+// A queuedDatagram is a DATAGRAM frame queued for sending.
+// If set, the callback is called when the packet carrying the frame is acknowledged or declared lost.
+type queuedDatagram struct {
+	*wire.DatagramFrame
+	callback func(acked bool)
+}
+
 type datagramQueue struct {
-	sendQueue chan *wire.DatagramFrame
-	rcvQueue  chan []byte
+	numDropped uint64 // number of received datagrams that were dropped, accessed atomically
+
+	sendQueue chan *queuedDatagram
+	rcvQueue  chan receivedDatagram
+
+	paused utils.AtomicBool
 
 	closeErr error
 	closed   chan struct{}
@@ -21,8 +43,8 @@ type datagramQueue struct {
 func newDatagramQueue(hasData func(), logger utils.Logger) *datagramQueue {
 	return &datagramQueue{
 		hasData:   hasData,
-		sendQueue: make(chan *wire.DatagramFrame),
-		rcvQueue:  make(chan []byte, protocol.DatagramRcvQueueLen),
+		sendQueue: make(chan *queuedDatagram),
+		rcvQueue:  make(chan receivedDatagram, protocol.DatagramRcvQueueLen),
 		closed:    make(chan struct{}),
 		logger:    logger,
 	}
@@ -30,10 +52,11 @@ func newDatagramQueue(hasData func(), logger utils.Logger) *datagramQueue {
 
 // AddAndWait queues a new DATAGRAM frame for sending.
 // It blocks until the frame has been dequeued.
-func (h *datagramQueue) AddAndWait(f *wire.DatagramFrame) error {
+// The callback may be nil.
+func (h *datagramQueue) AddAndWait(f *wire.DatagramFrame, callback func(acked bool)) error {
 	h.hasData()
 	select {
-	case h.sendQueue <- f:
+	case h.sendQueue <- &queuedDatagram{DatagramFrame: f, callback: callback}:
 		return nil
 	case <-h.closed:
 		return h.closeErr
@@ -41,7 +64,11 @@ func (h *datagramQueue) AddAndWait(f *wire.DatagramFrame) error {
 }
 
 // Get dequeues a DATAGRAM frame for sending.
-func (h *datagramQueue) Get() *wire.DatagramFrame {
+// It returns nil if sending is paused.
+func (h *datagramQueue) Get() *queuedDatagram {
+	if h.paused.Get() {
+		return nil
+	}
 	select {
 	case f := <-h.sendQueue:
 		return f
@@ -50,24 +77,47 @@ func (h *datagramQueue) Get() *wire.DatagramFrame {
 	}
 }
 
-// HandleDatagramFrame handles a received DATAGRAM frame.
-func (h *datagramQueue) HandleDatagramFrame(f *wire.DatagramFrame) {
+// SetPaused pauses (or resumes) the sending of DATAGRAM frames.
+func (h *datagramQueue) SetPaused(paused bool) {
+	h.paused.Set(paused)
+}
+
+// HandleDatagramFrame handles a DATAGRAM frame received from remoteAddr.
+// If the receive queue is full, the oldest datagram is dropped.
+func (h *datagramQueue) HandleDatagramFrame(f *wire.DatagramFrame, remoteAddr net.Addr) {
 	data := make([]byte, len(f.Data))
 	copy(data, f.Data)
-	select {
-	case h.rcvQueue <- data:
-	default:
-		h.logger.Debugf("Discarding DATAGRAM frame (%d bytes payload)", len(f.Data))
+	for {
+		select {
+		case h.rcvQueue <- receivedDatagram{data: data, remoteAddr: remoteAddr}:
+			return
+		default:
+		}
+		// The queue is full. Make room by dropping the oldest datagram.
+		// The application might have dequeued a datagram in the mean time, so this might not drop anything.
+		select {
+		case old := <-h.rcvQueue:
+			atomic.AddUint64(&h.numDropped, 1)
+			h.logger.Debugf("Discarding DATAGRAM frame (%d bytes payload). Receive queue full.", len(old.data))
+		default:
+		}
 	}
 }
 
-// Receive gets a received DATAGRAM frame.
-func (h *datagramQueue) Receive() ([]byte, error) {
+// NumDropped returns the number of received datagrams that were dropped because the receive queue was full.
+func (h *datagramQueue) NumDropped() uint64 {
+	return atomic.LoadUint64(&h.numDropped)
+}
+
+// Receive gets a received DATAGRAM frame, and the address it was received from.
+func (h *datagramQueue) Receive(ctx context.Context) ([]byte, net.Addr, error) {
 	select {
-	case data := <-h.rcvQueue:
-		return data, nil
+	case d := <-h.rcvQueue:
+		return d.data, d.remoteAddr, nil
 	case <-h.closed:
-		return nil, h.closeErr
+		return nil, nil, h.closeErr
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
 	}
 }
 
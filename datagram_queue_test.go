@@ -1,8 +1,11 @@
 package quic
 
 import (
+	"context"
 	"errors"
+	"net"
 
+	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 
@@ -31,7 +34,7 @@ var _ = Describe("Datagram Queue", func() {
 			go func() {
 				defer GinkgoRecover()
 				defer close(done)
-				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")})).To(Succeed())
+				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")}, nil)).To(Succeed())
 			}()
 
 			Eventually(queued).Should(HaveLen(1))
@@ -43,11 +46,46 @@ var _ = Describe("Datagram Queue", func() {
 			Expect(queue.Get()).To(BeNil())
 		})
 
+		It("queues a datagram with a callback", func() {
+			var called, acked bool
+			go func() {
+				defer GinkgoRecover()
+				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")}, func(a bool) { called, acked = true, a })).To(Succeed())
+			}()
+
+			Eventually(queued).Should(HaveLen(1))
+			f := queue.Get()
+			Expect(f).ToNot(BeNil())
+			Expect(f.callback).ToNot(BeNil())
+			f.callback(true)
+			Expect(called).To(BeTrue())
+			Expect(acked).To(BeTrue())
+		})
+
+		It("doesn't dequeue datagrams while paused", func() {
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				Expect(queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")}, nil)).To(Succeed())
+			}()
+
+			Eventually(queued).Should(HaveLen(1))
+			queue.SetPaused(true)
+			Consistently(queue.Get).Should(BeNil())
+			Expect(done).ToNot(BeClosed())
+			queue.SetPaused(false)
+			f := queue.Get()
+			Expect(f).ToNot(BeNil())
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("closes", func() {
 			errChan := make(chan error, 1)
 			go func() {
 				defer GinkgoRecover()
-				errChan <- queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")})
+				errChan <- queue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")}, nil)
 			}()
 
 			Consistently(errChan).ShouldNot(Receive())
@@ -58,35 +96,65 @@ var _ = Describe("Datagram Queue", func() {
 
 	Context("receiving", func() {
 		It("receives DATAGRAM frames", func() {
-			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")})
-			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")})
-			data, err := queue.Receive()
+			addr1 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 1337}
+			addr2 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 2), Port: 1337}
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foo")}, addr1)
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("bar")}, addr2)
+			data, addr, err := queue.Receive(context.Background())
 			Expect(err).ToNot(HaveOccurred())
 			Expect(data).To(Equal([]byte("foo")))
-			data, err = queue.Receive()
+			Expect(addr).To(Equal(addr1))
+			data, addr, err = queue.Receive(context.Background())
 			Expect(err).ToNot(HaveOccurred())
 			Expect(data).To(Equal([]byte("bar")))
+			Expect(addr).To(Equal(addr2))
+		})
+
+		It("drops the oldest datagram when the queue is full", func() {
+			for i := 0; i < protocol.DatagramRcvQueueLen+2; i++ {
+				queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte{byte(i)}}, nil)
+			}
+			Expect(queue.NumDropped()).To(BeEquivalentTo(2))
+			for i := 2; i < protocol.DatagramRcvQueueLen+2; i++ {
+				data, _, err := queue.Receive(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte{byte(i)}))
+			}
 		})
 
 		It("blocks until a frame is received", func() {
 			c := make(chan []byte, 1)
 			go func() {
 				defer GinkgoRecover()
-				data, err := queue.Receive()
+				data, _, err := queue.Receive(context.Background())
 				Expect(err).ToNot(HaveOccurred())
 				c <- data
 			}()
 
 			Consistently(c).ShouldNot(Receive())
-			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")})
+			queue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")}, nil)
 			Eventually(c).Should(Receive(Equal([]byte("foobar"))))
 		})
 
+		It("returns when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := make(chan error, 1)
+			go func() {
+				defer GinkgoRecover()
+				_, _, err := queue.Receive(ctx)
+				errChan <- err
+			}()
+
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(Equal(context.Canceled)))
+		})
+
 		It("closes", func() {
 			errChan := make(chan error, 1)
 			go func() {
 				defer GinkgoRecover()
-				_, err := queue.Receive()
+				_, _, err := queue.Receive(context.Background())
 				errChan <- err
 			}()
 
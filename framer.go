@@ -13,10 +13,13 @@ type framer interface {
 	HasData() bool
 
 	QueueControlFrame(wire.Frame)
+	QueuePing(onAcked func())
 	AppendControlFrames([]ackhandler.Frame, protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount)
 
 	AddActiveStream(protocol.StreamID)
 	AppendStreamFrames([]ackhandler.Frame, protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount)
+
+	SetPaused(bool)
 }
 
 type framerI struct {
@@ -27,38 +30,86 @@ type framerI struct {
 
 	activeStreams map[protocol.StreamID]struct{}
 	streamQueue   []protocol.StreamID
+	paused        bool // if paused, no STREAM frames are sent
+
+	// only used for deficit round robin scheduling
+	deficitRoundRobin bool
+	deficits          map[protocol.StreamID]protocol.ByteCount
+	headTurnStarted   bool // did the stream at the head of the streamQueue already start its turn
 
 	controlFrameMutex sync.Mutex
-	controlFrames     []wire.Frame
+	controlFrames     []ackhandler.Frame
+	pathResponses     []*wire.PathResponseFrame
 }
 
 var _ framer = &framerI{}
 
+// deficitRoundRobinQuantum is the number of bytes that every stream is allowed to send
+// per round when using deficit round robin scheduling, i.e. (about) one full-sized packet.
+const deficitRoundRobinQuantum protocol.ByteCount = protocol.MaxPacketSizeIPv4
+
+// maxPathResponses is the maximum number of PATH_RESPONSE frames that are queued.
+// This limit is only hit if the peer floods us with PATH_CHALLENGE frames.
+const maxPathResponses = 256
+
 func newFramer(
 	streamGetter streamGetter,
+	deficitRoundRobin bool,
 	v protocol.VersionNumber,
 ) framer {
 	return &framerI{
-		streamGetter:  streamGetter,
-		activeStreams: make(map[protocol.StreamID]struct{}),
-		version:       v,
+		streamGetter:      streamGetter,
+		activeStreams:     make(map[protocol.StreamID]struct{}),
+		deficitRoundRobin: deficitRoundRobin,
+		deficits:          make(map[protocol.StreamID]protocol.ByteCount),
+		version:           v,
 	}
 }
 
 func (f *framerI) HasData() bool {
 	f.mutex.Lock()
-	hasData := len(f.streamQueue) > 0
+	hasData := !f.paused && len(f.streamQueue) > 0
 	f.mutex.Unlock()
 	if hasData {
 		return true
 	}
 	f.controlFrameMutex.Lock()
-	hasData = len(f.controlFrames) > 0
+	hasData = len(f.controlFrames) > 0 || len(f.pathResponses) > 0
 	f.controlFrameMutex.Unlock()
 	return hasData
 }
 
 func (f *framerI) QueueControlFrame(frame wire.Frame) {
+	if pr, ok := frame.(*wire.PathResponseFrame); ok {
+		f.queuePathResponse(pr)
+		return
+	}
+	f.queueFrame(ackhandler.Frame{Frame: frame})
+}
+
+// queuePathResponse queues a PATH_RESPONSE frame.
+// PATH_RESPONSE frames exceeding maxPathResponses are dropped,
+// such that a peer sending lots of PATH_CHALLENGE frames can't make us use an unbounded amount of memory.
+func (f *framerI) queuePathResponse(frame *wire.PathResponseFrame) {
+	f.controlFrameMutex.Lock()
+	if len(f.pathResponses) < maxPathResponses {
+		f.pathResponses = append(f.pathResponses, frame)
+	}
+	f.controlFrameMutex.Unlock()
+}
+
+// QueuePing queues a PING frame.
+// onAcked is called when the PING frame is acknowledged.
+// If the packet containing the PING frame is lost, a new PING frame is queued.
+func (f *framerI) QueuePing(onAcked func()) {
+	f.queueFrame(ackhandler.Frame{
+		Frame:   &wire.PingFrame{},
+		OnLost:  func(wire.Frame) { f.QueuePing(onAcked) },
+		OnAcked: func(wire.Frame) { onAcked() },
+	})
+}
+
+func (f *framerI) queueFrame(frame ackhandler.Frame) {
 	f.controlFrameMutex.Lock()
 	f.controlFrames = append(f.controlFrames, frame)
 	f.controlFrameMutex.Unlock()
@@ -67,13 +118,22 @@ func (f *framerI) QueueControlFrame(frame wire.Frame) {
 func (f *framerI) AppendControlFrames(frames []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
 	var length protocol.ByteCount
 	f.controlFrameMutex.Lock()
+	for len(f.pathResponses) > 0 {
+		frameLen := f.pathResponses[0].Length(f.version)
+		if length+frameLen > maxLen {
+			break
+		}
+		frames = append(frames, ackhandler.Frame{Frame: f.pathResponses[0]})
+		length += frameLen
+		f.pathResponses = f.pathResponses[1:]
+	}
 	for len(f.controlFrames) > 0 {
 		frame := f.controlFrames[len(f.controlFrames)-1]
-		frameLen := frame.Length(f.version)
+		frameLen := frame.Frame.Length(f.version)
 		if length+frameLen > maxLen {
 			break
 		}
-		frames = append(frames, ackhandler.Frame{Frame: frame})
+		frames = append(frames, frame)
 		length += frameLen
 		f.controlFrames = f.controlFrames[:len(f.controlFrames)-1]
 	}
@@ -90,10 +150,43 @@ func (f *framerI) AddActiveStream(id protocol.StreamID) {
 	f.mutex.Unlock()
 }
 
+// SetPaused pauses (or resumes) the sending of STREAM frames.
+// Control frames are not affected.
+func (f *framerI) SetPaused(paused bool) {
+	f.mutex.Lock()
+	f.paused = paused
+	f.mutex.Unlock()
+}
+
 func (f *framerI) AppendStreamFrames(frames []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
 	var length protocol.ByteCount
 	var lastFrame *ackhandler.Frame
 	f.mutex.Lock()
+	if f.paused {
+		f.mutex.Unlock()
+		return frames, 0
+	}
+	if f.deficitRoundRobin {
+		frames, length, lastFrame = f.appendStreamFramesDeficitRoundRobin(frames, maxLen)
+	} else {
+		frames, length, lastFrame = f.appendStreamFramesRoundRobin(frames, maxLen)
+	}
+	f.mutex.Unlock()
+	if lastFrame != nil {
+		lastFrameLen := lastFrame.Length(f.version)
+		// account for the smaller size of the last STREAM frame
+		lastFrame.Frame.(*wire.StreamFrame).DataLenPresent = false
+		length += lastFrame.Length(f.version) - lastFrameLen
+	}
+	return frames, length
+}
+
+// appendStreamFramesRoundRobin gives every stream one turn per packet,
+// regardless of how much data the stream sends.
+// It must be called with the mutex held.
+func (f *framerI) appendStreamFramesRoundRobin(frames []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount, *ackhandler.Frame) {
+	var length protocol.ByteCount
+	var lastFrame *ackhandler.Frame
 	// pop STREAM frames, until less than MinStreamFrameSize bytes are left in the packet
 	numActiveStreams := len(f.streamQueue)
 	for i := 0; i < numActiveStreams; i++ {
@@ -131,12 +224,80 @@ func (f *framerI) AppendStreamFrames(frames []ackhandler.Frame, maxLen protocol.
 		length += frame.Length(f.version)
 		lastFrame = frame
 	}
-	f.mutex.Unlock()
-	if lastFrame != nil {
-		lastFrameLen := lastFrame.Length(f.version)
-		// account for the smaller size of the last STREAM frame
-		lastFrame.Frame.(*wire.StreamFrame).DataLenPresent = false
-		length += lastFrame.Length(f.version) - lastFrameLen
+	return frames, length, lastFrame
+}
+
+// appendStreamFramesDeficitRoundRobin schedules streams using deficit round robin.
+// At the beginning of its turn, a stream's deficit is increased by deficitRoundRobinQuantum,
+// and the stream is allowed to send STREAM frames until its deficit is used up.
+// A turn can span multiple packets. This way, every stream gets the same share of the bandwidth,
+// no matter how much data it sends per STREAM frame.
+// It must be called with the mutex held.
+func (f *framerI) appendStreamFramesDeficitRoundRobin(frames []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount, *ackhandler.Frame) {
+	var length protocol.ByteCount
+	var lastFrame *ackhandler.Frame
+	// Every stream's turn ends at most once per packet. This guarantees that the loop terminates,
+	// even if streams don't return any STREAM frames.
+	numTurns := len(f.streamQueue)
+	for numTurns > 0 && len(f.streamQueue) > 0 {
+		if protocol.MinStreamFrameSize+length > maxLen {
+			break
+		}
+		id := f.streamQueue[0]
+		str, err := f.streamGetter.GetOrOpenSendStream(id)
+		// The stream can be nil if it completed after it said it had data.
+		if str == nil || err != nil {
+			f.removeHeadOfQueue()
+			numTurns--
+			continue
+		}
+		if !f.headTurnStarted {
+			f.deficits[id] += deficitRoundRobinQuantum
+			f.headTurnStarted = true
+		}
+		deficit := f.deficits[id]
+		maxBytes := maxLen - length
+		if maxBytes <= deficit {
+			// If this is the last STREAM frame in the packet, we'll remove the DataLen field later.
+			maxBytes += quicvarint.Len(uint64(maxBytes))
+		} else {
+			maxBytes = deficit
+		}
+		frame, hasMoreData := str.popStreamFrame(maxBytes)
+		if frame != nil {
+			frames = append(frames, *frame)
+			frameLen := frame.Length(f.version)
+			length += frameLen
+			lastFrame = frame
+			if frameLen < deficit {
+				deficit -= frameLen
+			} else {
+				deficit = 0
+			}
+			f.deficits[id] = deficit
+		}
+		if !hasMoreData { // no more data to send. Stream is not active any more
+			f.removeHeadOfQueue()
+			numTurns--
+			continue
+		}
+		// The stream continues its turn (possibly in the next packet), if its deficit allows it to send another frame.
+		if frame != nil && deficit >= protocol.MinStreamFrameSize {
+			continue
+		}
+		// End the turn, and put the stream back in the queue (at the end).
+		// The remaining deficit is carried over to the next round.
+		f.streamQueue = append(f.streamQueue[1:], id)
+		f.headTurnStarted = false
+		numTurns--
 	}
-	return frames, length
+	return frames, length, lastFrame
+}
+
+func (f *framerI) removeHeadOfQueue() {
+	id := f.streamQueue[0]
+	f.streamQueue = f.streamQueue[1:]
+	delete(f.activeStreams, id)
+	delete(f.deficits, id)
+	f.headTurnStarted = false
 }
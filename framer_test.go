@@ -8,6 +8,7 @@ import (
 	"github.com/golang/mock/gomock"
 
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
 
 	. "github.com/onsi/ginkgo"
@@ -33,7 +34,7 @@ var _ = Describe("Framer", func() {
 		stream1.EXPECT().StreamID().Return(protocol.StreamID(5)).AnyTimes()
 		stream2 = NewMockSendStreamI(mockCtrl)
 		stream2.EXPECT().StreamID().Return(protocol.StreamID(6)).AnyTimes()
-		framer = newFramer(streamGetter, version)
+		framer = newFramer(streamGetter, false, version)
 	})
 
 	Context("handling control frames", func() {
@@ -71,6 +72,23 @@ var _ = Describe("Framer", func() {
 			Expect(length).To(Equal(mdf.Length(version)))
 		})
 
+		It("queues PING frames", func() {
+			var acked bool
+			framer.QueuePing(func() { acked = true })
+			frames, length := framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(HaveLen(1))
+			Expect(frames[0].Frame).To(BeAssignableToTypeOf(&wire.PingFrame{}))
+			Expect(length).To(Equal(frames[0].Frame.Length(version)))
+			// when the PING is lost, a new PING is queued
+			frames[0].OnLost(frames[0].Frame)
+			Expect(framer.HasData()).To(BeTrue())
+			frames, _ = framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(HaveLen(1))
+			Expect(acked).To(BeFalse())
+			frames[0].OnAcked(frames[0].Frame)
+			Expect(acked).To(BeTrue())
+		})
+
 		It("adds the right number of frames", func() {
 			maxSize := protocol.ByteCount(1000)
 			bf := &wire.DataBlockedFrame{MaximumData: 0x1337}
@@ -86,6 +104,21 @@ var _ = Describe("Framer", func() {
 			Expect(frames).To(HaveLen(1))
 			Expect(length).To(Equal(bfLen))
 		})
+
+		It("limits the number of queued PATH_RESPONSE frames", func() {
+			for i := 0; i < 2*maxPathResponses; i++ {
+				framer.QueueControlFrame(&wire.PathResponseFrame{Data: [8]byte{byte(i / 256), byte(i % 256)}})
+			}
+			var frames []ackhandler.Frame
+			for framer.HasData() {
+				frames, _ = framer.AppendControlFrames(frames, 1000)
+			}
+			Expect(frames).To(HaveLen(maxPathResponses))
+			// the PATH_RESPONSE frames are sent in the order they were queued
+			for i, f := range frames {
+				Expect(f.Frame).To(Equal(&wire.PathResponseFrame{Data: [8]byte{byte(i / 256), byte(i % 256)}}))
+			}
+		})
 	})
 
 	Context("popping STREAM frames", func() {
@@ -128,6 +161,31 @@ var _ = Describe("Framer", func() {
 			Expect(framer.HasData()).To(BeFalse())
 		})
 
+		It("doesn't pop STREAM frames while paused", func() {
+			framer.AddActiveStream(id1)
+			framer.SetPaused(true)
+			Expect(framer.HasData()).To(BeFalse())
+			fs, length := framer.AppendStreamFrames(nil, 1000)
+			Expect(fs).To(BeEmpty())
+			Expect(length).To(BeZero())
+			// control frames are still sent
+			ping := &wire.PingFrame{}
+			framer.QueueControlFrame(ping)
+			Expect(framer.HasData()).To(BeTrue())
+			frames, _ := framer.AppendControlFrames(nil, 1000)
+			Expect(frames).To(HaveLen(1))
+			Expect(frames[0].Frame).To(Equal(ping))
+			// resume
+			framer.SetPaused(false)
+			Expect(framer.HasData()).To(BeTrue())
+			f := &wire.StreamFrame{StreamID: id1, Data: []byte("foobar")}
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
+			stream1.EXPECT().popStreamFrame(gomock.Any()).Return(&ackhandler.Frame{Frame: f}, false)
+			fs, _ = framer.AppendStreamFrames(nil, 1000)
+			Expect(fs).To(HaveLen(1))
+			Expect(fs[0].Frame).To(Equal(f))
+		})
+
 		It("appends to a frame slice", func() {
 			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil)
 			f := &wire.StreamFrame{
@@ -354,4 +412,63 @@ var _ = Describe("Framer", func() {
 			Expect(length).To(Equal(f.Length(version)))
 		})
 	})
+
+	Context("scheduling streams", func() {
+		const packetSize = 1200
+
+		// stream1 fills the whole STREAM frame, stream2 only sends small STREAM frames
+		BeforeEach(func() {
+			streamGetter.EXPECT().GetOrOpenSendStream(id1).Return(stream1, nil).AnyTimes()
+			streamGetter.EXPECT().GetOrOpenSendStream(id2).Return(stream2, nil).AnyTimes()
+			stream1.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) (*ackhandler.Frame, bool) {
+				f := &wire.StreamFrame{StreamID: id1, DataLenPresent: true}
+				f.Data = make([]byte, f.MaxDataLen(size, version))
+				return &ackhandler.Frame{Frame: f}, true
+			}).AnyTimes()
+			stream2.EXPECT().popStreamFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) (*ackhandler.Frame, bool) {
+				f := &wire.StreamFrame{StreamID: id2, DataLenPresent: true}
+				f.Data = make([]byte, utils.MinByteCount(100, f.MaxDataLen(size, version)))
+				return &ackhandler.Frame{Frame: f}, true
+			}).AnyTimes()
+		})
+
+		sendPackets := func(numPackets int) map[protocol.StreamID]protocol.ByteCount {
+			bytesSent := make(map[protocol.StreamID]protocol.ByteCount)
+			for i := 0; i < numPackets; i++ {
+				frames, length := framer.AppendStreamFrames(nil, packetSize)
+				Expect(length).To(BeNumerically("<=", packetSize))
+				for _, f := range frames {
+					sf := f.Frame.(*wire.StreamFrame)
+					bytesSent[sf.StreamID] += sf.DataLen()
+				}
+			}
+			return bytesSent
+		}
+
+		It("gives every stream one turn per packet, when using round robin scheduling", func() {
+			framer.AddActiveStream(id2)
+			frames, _ := framer.AppendStreamFrames(nil, packetSize)
+			Expect(frames).To(HaveLen(1))
+			framer.AddActiveStream(id1)
+			bytesSent := sendPackets(100)
+			// stream1 sends a lot more data than stream2
+			Expect(bytesSent[id1]).To(BeNumerically(">", 5*bytesSent[id2]))
+		})
+
+		It("lets streams send multiple STREAM frames per turn, when using deficit round robin scheduling", func() {
+			framer = newFramer(streamGetter, true, version)
+			framer.AddActiveStream(id2)
+			frames, length := framer.AppendStreamFrames(nil, packetSize)
+			Expect(len(frames)).To(BeNumerically(">", 10))
+			Expect(length).To(BeNumerically(">", packetSize-protocol.MinStreamFrameSize))
+		})
+
+		It("shares the bandwidth fairly, when using deficit round robin scheduling", func() {
+			framer = newFramer(streamGetter, true, version)
+			framer.AddActiveStream(id1)
+			framer.AddActiveStream(id2)
+			bytesSent := sendPackets(100)
+			Expect(float64(bytesSent[id1]) / float64(bytesSent[id2])).To(BeNumerically("~", 1, 0.1))
+		})
+	})
 })
@@ -0,0 +1,216 @@
+package http3
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAltSvcMaxAge is the freshness lifetime of an alternative service
+// if the Alt-Svc header doesn't contain the ma parameter (see section 3.1 of RFC 7838).
+const defaultAltSvcMaxAge = 24 * time.Hour
+
+// An altSvc is an alternative service advertised in an Alt-Svc header.
+type altSvc struct {
+	protocol string
+	host     string // empty if the alternative service is on the same host as the origin
+	port     string
+	maxAge   time.Duration
+}
+
+// parseAltSvc parses the value of an Alt-Svc header.
+// It returns clear == true if the header clears all alternative services of the origin.
+// Malformed alternatives are skipped.
+func parseAltSvc(value string) (alts []altSvc, clear bool) {
+	if strings.TrimSpace(value) == "clear" {
+		return nil, true
+	}
+	for _, entry := range strings.Split(value, ",") {
+		params := strings.Split(entry, ";")
+		protocol, authority, ok := parseAltSvcParam(params[0])
+		if !ok {
+			continue
+		}
+		protocol, err := url.PathUnescape(protocol)
+		if err != nil {
+			continue
+		}
+		host, port, err := net.SplitHostPort(authority)
+		if err != nil {
+			continue
+		}
+		alt := altSvc{protocol: protocol, host: host, port: port, maxAge: defaultAltSvcMaxAge}
+		for _, p := range params[1:] {
+			key, val, ok := parseAltSvcParam(p)
+			if !ok || key != "ma" {
+				continue
+			}
+			if ma, err := strconv.ParseUint(val, 10, 32); err == nil {
+				alt.maxAge = time.Duration(ma) * time.Second
+			}
+		}
+		alts = append(alts, alt)
+	}
+	return alts, false
+}
+
+// parseAltSvcParam parses a key=value pair.
+// Quotes around the value are removed.
+func parseAltSvcParam(s string) (key, value string, ok bool) {
+	i := strings.IndexByte(s, '=')
+	if i < 0 {
+		return "", "", false
+	}
+	key = strings.TrimSpace(s[:i])
+	value = strings.TrimSpace(s[i+1:])
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	return key, value, len(key) > 0
+}
+
+type altSvcEntry struct {
+	port    string
+	expires time.Time
+}
+
+// The altSvcCache stores the HTTP/3 alternative services of origins.
+type altSvcCache struct {
+	mutex   sync.Mutex
+	entries map[string]altSvcEntry // the key is the origin's host:port
+}
+
+// update processes the Alt-Svc headers of a response received from origin.
+// Only HTTP/3 alternatives on the same host as the origin are used,
+// since the connection to the alternative service must be authenticated for the origin.
+func (c *altSvcCache) update(origin string, hdr http.Header, now time.Time) {
+	values := hdr.Values("Alt-Svc")
+	if len(values) == 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, v := range values {
+		alts, clear := parseAltSvc(v)
+		if clear {
+			delete(c.entries, origin)
+			return
+		}
+		for _, alt := range alts {
+			if alt.host != "" || (alt.protocol != nextProtoH3Draft29 && alt.protocol != nextProtoH3Draft32) {
+				continue
+			}
+			if c.entries == nil {
+				c.entries = make(map[string]altSvcEntry)
+			}
+			c.entries[origin] = altSvcEntry{port: alt.port, expires: now.Add(alt.maxAge)}
+			return
+		}
+	}
+}
+
+// get returns the port of the HTTP/3 alternative service for origin, if there's a fresh one.
+func (c *altSvcCache) get(origin string, now time.Time) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[origin]
+	if !ok {
+		return "", false
+	}
+	if !now.Before(e.expires) {
+		delete(c.entries, origin)
+		return "", false
+	}
+	return e.port, true
+}
+
+func (c *altSvcCache) remove(origin string) {
+	c.mutex.Lock()
+	delete(c.entries, origin)
+	c.mutex.Unlock()
+}
+
+// AltSvcRoundTripper is a http.RoundTripper that upgrades to HTTP/3 when possible.
+// Requests are sent using the Fallback RoundTripper (usually HTTP/1.1 or HTTP/2),
+// until the server announces HTTP/3 support in an Alt-Svc header.
+// Subsequent requests to this origin are then sent using HTTP/3.
+// If sending a request using HTTP/3 fails, the alternative service is discarded,
+// and the request is retried using the Fallback RoundTripper.
+type AltSvcRoundTripper struct {
+	// Fallback is used for requests to origins that don't support HTTP/3,
+	// and when sending a request using HTTP/3 fails.
+	// If nil, http.DefaultTransport is used.
+	Fallback http.RoundTripper
+
+	// HTTP3 is used for requests to origins that announced HTTP/3 support.
+	// If nil, a RoundTripper with the default configuration is used.
+	HTTP3 http.RoundTripper
+
+	initOnce sync.Once
+	cache    altSvcCache
+}
+
+var _ http.RoundTripper = &AltSvcRoundTripper{}
+
+// RoundTrip does a round trip.
+func (r *AltSvcRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.initOnce.Do(func() {
+		if r.Fallback == nil {
+			r.Fallback = http.DefaultTransport
+		}
+		if r.HTTP3 == nil {
+			r.HTTP3 = &RoundTripper{}
+		}
+	})
+
+	// Alternative services are only used for https origins.
+	if req.URL == nil || req.URL.Scheme != "https" {
+		return r.Fallback.RoundTrip(req)
+	}
+	origin := authorityAddr("https", req.URL.Host)
+	if port, ok := r.cache.get(origin, time.Now()); ok {
+		rsp, err := r.HTTP3.RoundTrip(altSvcRequest(req, port))
+		if err == nil {
+			return rsp, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		r.cache.remove(origin)
+		if !canRetryRequest(req) {
+			return nil, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			newReq := *req
+			newReq.Body = body
+			req = &newReq
+		}
+	}
+	rsp, err := r.Fallback.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.update(origin, rsp.Header, time.Now())
+	return rsp, nil
+}
+
+// altSvcRequest returns a copy of the request that is sent to port.
+// The Host header still contains the origin.
+func altSvcRequest(req *http.Request, port string) *http.Request {
+	newReq := req.Clone(req.Context())
+	if newReq.Host == "" {
+		newReq.Host = req.URL.Host
+	}
+	newReq.URL.Host = net.JoinHostPort(req.URL.Hostname(), port)
+	return newReq
+}
@@ -0,0 +1,243 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+var _ = Describe("Alt-Svc", func() {
+	Context("parsing", func() {
+		It("parses a single alternative", func() {
+			alts, clear := parseAltSvc(`h3-29=":443"; ma=3600`)
+			Expect(clear).To(BeFalse())
+			Expect(alts).To(Equal([]altSvc{{protocol: "h3-29", port: "443", maxAge: time.Hour}}))
+		})
+
+		It("parses multiple alternatives", func() {
+			alts, _ := parseAltSvc(`h3-29=":443"; ma=2592000, h2="alt.example.org:8443", h3-32=":1234"`)
+			Expect(alts).To(Equal([]altSvc{
+				{protocol: "h3-29", port: "443", maxAge: 2592000 * time.Second},
+				{protocol: "h2", host: "alt.example.org", port: "8443", maxAge: defaultAltSvcMaxAge},
+				{protocol: "h3-32", port: "1234", maxAge: defaultAltSvcMaxAge},
+			}))
+		})
+
+		It("unescapes the protocol ID", func() {
+			alts, _ := parseAltSvc(`w%3Dx%3Ay=":443"`)
+			Expect(alts).To(HaveLen(1))
+			Expect(alts[0].protocol).To(Equal("w=x:y"))
+		})
+
+		It("skips malformed alternatives", func() {
+			alts, _ := parseAltSvc(`h3-29, h3-29=":foo:bar", h3-32=":443"; ma=foo`)
+			Expect(alts).To(Equal([]altSvc{{protocol: "h3-32", port: "443", maxAge: defaultAltSvcMaxAge}}))
+		})
+
+		It("parses clear", func() {
+			alts, clear := parseAltSvc(" clear ")
+			Expect(clear).To(BeTrue())
+			Expect(alts).To(BeEmpty())
+		})
+	})
+
+	Context("cache", func() {
+		var cache *altSvcCache
+		now := time.Now()
+
+		BeforeEach(func() {
+			cache = &altSvcCache{}
+		})
+
+		update := func(value string) {
+			cache.update("example.org:443", http.Header{"Alt-Svc": {value}}, now)
+		}
+
+		It("stores HTTP/3 alternatives", func() {
+			update(`h2=":8443", h3-29=":1234"; ma=60`)
+			port, ok := cache.get("example.org:443", now)
+			Expect(ok).To(BeTrue())
+			Expect(port).To(Equal("1234"))
+			_, ok = cache.get("example.org:443", now.Add(time.Minute))
+			Expect(ok).To(BeFalse())
+			Expect(cache.entries).To(BeEmpty())
+		})
+
+		It("ignores alternatives that don't support HTTP/3", func() {
+			update(`h2=":8443"`)
+			_, ok := cache.get("example.org:443", now)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("ignores alternatives on different hosts", func() {
+			update(`h3-29="alt.example.org:443"`)
+			_, ok := cache.get("example.org:443", now)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("clears alternatives", func() {
+			update(`h3-29=":443"`)
+			_, ok := cache.get("example.org:443", now)
+			Expect(ok).To(BeTrue())
+			update("clear")
+			_, ok = cache.get("example.org:443", now)
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Context("upgrading to HTTP/3", func() {
+		var (
+			rt                   *AltSvcRoundTripper
+			fallbackReqs, h3Reqs chan *http.Request
+			fallbackErr, h3Err   error
+			fallbackHeader       http.Header
+		)
+
+		BeforeEach(func() {
+			fallbackReqs = make(chan *http.Request, 10)
+			h3Reqs = make(chan *http.Request, 10)
+			fallbackErr = nil
+			h3Err = nil
+			fallbackHeader = http.Header{"Alt-Svc": {`h3-29=":1234"`}}
+			rt = &AltSvcRoundTripper{
+				Fallback: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					fallbackReqs <- req
+					if fallbackErr != nil {
+						return nil, fallbackErr
+					}
+					return &http.Response{StatusCode: 200, Header: fallbackHeader, Request: req}, nil
+				}),
+				HTTP3: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					h3Reqs <- req
+					if h3Err != nil {
+						return nil, h3Err
+					}
+					return &http.Response{StatusCode: 200, Request: req}, nil
+				}),
+			}
+		})
+
+		It("uses HTTP/3 after the server announced support", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).To(Receive())
+			Expect(h3Reqs).ToNot(Receive())
+
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).ToNot(Receive())
+			var h3Req *http.Request
+			Expect(h3Reqs).To(Receive(&h3Req))
+			Expect(h3Req.URL.Host).To(Equal("example.org:1234"))
+			Expect(h3Req.URL.Path).To(Equal("/foo"))
+			Expect(h3Req.Host).To(Equal("example.org"))
+			// the original request is not modified
+			Expect(req.URL.Host).To(Equal("example.org"))
+		})
+
+		It("doesn't use HTTP/3 for origins that didn't announce support", func() {
+			fallbackHeader = http.Header{}
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 2; i++ {
+				_, err = rt.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fallbackReqs).To(Receive())
+			}
+			Expect(h3Reqs).ToNot(Receive())
+		})
+
+		It("doesn't use HTTP/3 for plain HTTP requests", func() {
+			req, err := http.NewRequest(http.MethodGet, "http://example.org/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 0; i < 2; i++ {
+				_, err = rt.RoundTrip(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fallbackReqs).To(Receive())
+			}
+			Expect(h3Reqs).ToNot(Receive())
+		})
+
+		It("returns errors from the fallback RoundTripper", func() {
+			fallbackErr = errors.New("connection refused")
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError(fallbackErr))
+		})
+
+		It("falls back when the HTTP/3 request fails", func() {
+			req, err := http.NewRequest(http.MethodPost, "https://example.org/foo", bytes.NewReader([]byte("foobar")))
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).To(Receive())
+
+			h3Err = errors.New("handshake error")
+			fallbackHeader = http.Header{}
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h3Reqs).To(Receive())
+			var fallbackReq *http.Request
+			Expect(fallbackReqs).To(Receive(&fallbackReq))
+			body, err := ioutil.ReadAll(fallbackReq.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(body).To(Equal([]byte("foobar")))
+
+			// the alternative service is not used any more
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).To(Receive())
+			Expect(h3Reqs).ToNot(Receive())
+		})
+
+		It("doesn't fall back when the request body can't be obtained again", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).To(Receive())
+
+			h3Err = errors.New("handshake error")
+			req.Body = &mockBody{}
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError(h3Err))
+			Expect(h3Reqs).To(Receive())
+			Expect(fallbackReqs).ToNot(Receive())
+		})
+
+		It("doesn't fall back when the request is canceled", func() {
+			req, err := http.NewRequest(http.MethodGet, "https://example.org/foo", nil)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fallbackReqs).To(Receive())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			h3Err = context.Canceled
+			_, err = rt.RoundTrip(req.WithContext(ctx))
+			Expect(err).To(MatchError(context.Canceled))
+			Expect(fallbackReqs).ToNot(Receive())
+			Expect(h3Reqs).To(Receive())
+			// the alternative service can still be used
+			h3Err = nil
+			_, err = rt.RoundTrip(req)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h3Reqs).To(Receive())
+			Expect(fallbackReqs).ToNot(Receive())
+		})
+	})
+})
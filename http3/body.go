@@ -1,8 +1,12 @@
 package http3
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/http"
 
 	"github.com/lucas-clemente/quic-go"
 )
@@ -20,6 +24,15 @@ type body struct {
 	onFrameError func()
 
 	bytesRemainingInFrame uint64
+	readEOF               bool
+	receivedTrailers      bool
+
+	// If set, a HEADERS frame following the DATA frames is decoded into trailer.
+	// Otherwise, trailers are discarded.
+	ctx             context.Context // canceled when decoding the trailers should be aborted
+	decoder         *qpackDecoder
+	trailer         *http.Header
+	maxTrailerBytes uint64
 }
 
 var _ io.ReadCloser = &body{}
@@ -39,10 +52,51 @@ func newResponseBody(str quic.Stream, done chan<- struct{}, onFrameError func())
 	}
 }
 
+// setTrailer makes the body decode trailers into trailer.
+// The trailers are available once Read returned io.EOF.
+// If the trailers reference dynamic table entries that weren't received yet,
+// decoding blocks until these entries arrive or ctx is canceled.
+func (r *body) setTrailer(ctx context.Context, decoder *qpackDecoder, trailer *http.Header, maxBytes uint64) {
+	r.ctx = ctx
+	r.decoder = decoder
+	r.trailer = trailer
+	r.maxTrailerBytes = maxBytes
+}
+
+// hijackableBody is the body of a http.Response.
+// It gives access to the stream and the QUIC session the response was received on.
+type hijackableBody struct {
+	*body
+	sess quic.Session
+}
+
+var (
+	_ DataStreamer = &hijackableBody{}
+	_ Hijacker     = &hijackableBody{}
+)
+
+// DataStream hands over the stream.
+// After a call to DataStream, the body must not be read any more.
+func (r *hijackableBody) DataStream() quic.Stream {
+	return r.str
+}
+
+func (r *hijackableBody) Session() quic.Session {
+	return r.sess
+}
+
 func (r *body) Read(b []byte) (int, error) {
 	n, err := r.readImpl(b)
+	if err == io.EOF {
+		r.readEOF = true
+	}
 	if err != nil {
 		r.requestDone()
+		// If the request was canceled, the stream was reset.
+		// Return the context error instead of the stream error, like net/http does.
+		if err != io.EOF && r.reqDone != nil && r.ctx != nil && r.ctx.Err() != nil {
+			err = r.ctx.Err()
+		}
 	}
 	return n, err
 }
@@ -55,9 +109,16 @@ func (r *body) readImpl(b []byte) (int, error) {
 			if err != nil {
 				return 0, err
 			}
+			if r.receivedTrailers {
+				// The trailers are the last frame on the stream (apart from unknown frames).
+				r.onFrameError()
+				return 0, fmt.Errorf("peer sent a frame after the trailers: %T", frame)
+			}
 			switch f := frame.(type) {
 			case *headersFrame:
-				// skip HEADERS frames
+				if err := r.readTrailers(f); err != nil {
+					return 0, err
+				}
 				continue
 			case *dataFrame:
 				r.bytesRemainingInFrame = f.Length
@@ -82,6 +143,40 @@ func (r *body) readImpl(b []byte) (int, error) {
 	return n, err
 }
 
+func (r *body) readTrailers(f *headersFrame) error {
+	r.receivedTrailers = true
+	if r.decoder == nil {
+		_, err := io.CopyN(ioutil.Discard, r.str, int64(f.Length))
+		return err
+	}
+	if f.Length > r.maxTrailerBytes {
+		r.str.CancelRead(quic.ErrorCode(errorFrameError))
+		return fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", f.Length, r.maxTrailerBytes)
+	}
+	headerBlock := make([]byte, f.Length)
+	if _, err := io.ReadFull(r.str, headerBlock); err != nil {
+		return err
+	}
+	hfs, err := r.decoder.decode(r.ctx, r.str, headerBlock)
+	if err != nil {
+		return err
+	}
+	if size := fieldSectionSize(hfs); size > r.maxTrailerBytes {
+		r.str.CancelRead(quic.ErrorCode(errorExcessiveLoad))
+		return fmt.Errorf("trailer field section too large: %d bytes (max: %d)", size, r.maxTrailerBytes)
+	}
+	if *r.trailer == nil {
+		*r.trailer = make(http.Header)
+	}
+	for _, hf := range hfs {
+		if hf.IsPseudo() {
+			return errors.New("pseudo header in trailer")
+		}
+		r.trailer.Add(hf.Name, hf.Value)
+	}
+	return nil
+}
+
 func (r *body) requestDone() {
 	if r.reqDoneClosed || r.reqDone == nil {
 		return
@@ -94,5 +189,9 @@ func (r *body) Close() error {
 	r.requestDone()
 	// If the EOF was read, CancelRead() is a no-op.
 	r.str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+	if r.decoder != nil && !r.readEOF {
+		// Trailers that weren't read yet might reference the dynamic table.
+		r.decoder.cancelStream(r.str)
+	}
 	return nil
 }
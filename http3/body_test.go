@@ -2,12 +2,16 @@ package http3
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
 
 	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go"
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/marten-seemann/qpack"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -131,15 +135,103 @@ var _ = Describe("Body", func() {
 			})
 
 			It("skips HEADERS frames", func() {
+				buf.Write(getDataFrame([]byte("foobar")))
+				(&headersFrame{Length: 10}).Write(buf)
+				buf.Write(make([]byte, 10))
+				data, err := ioutil.ReadAll(rb)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+			})
+
+			It("errors on DATA frames after the HEADERS frame, and calls the error callback", func() {
 				buf.Write(getDataFrame([]byte("foo")))
 				(&headersFrame{Length: 10}).Write(buf)
 				buf.Write(make([]byte, 10))
 				buf.Write(getDataFrame([]byte("bar")))
-				b := make([]byte, 6)
-				n, err := io.ReadFull(rb, b)
-				Expect(err).ToNot(HaveOccurred())
-				Expect(n).To(Equal(6))
-				Expect(b).To(Equal([]byte("foobar")))
+				data, err := ioutil.ReadAll(rb)
+				Expect(err).To(MatchError("peer sent a frame after the trailers: *http3.dataFrame"))
+				Expect(data).To(Equal([]byte("foo")))
+				Expect(errorCbCalled).To(BeTrue())
+			})
+
+			It("errors on multiple HEADERS frames after the DATA frames", func() {
+				buf.Write(getDataFrame([]byte("foo")))
+				(&headersFrame{Length: 2}).Write(buf)
+				buf.Write(make([]byte, 2))
+				(&headersFrame{Length: 2}).Write(buf)
+				buf.Write(make([]byte, 2))
+				_, err := ioutil.ReadAll(rb)
+				Expect(err).To(MatchError("peer sent a frame after the trailers: *http3.headersFrame"))
+				Expect(errorCbCalled).To(BeTrue())
+			})
+
+			Context("trailers", func() {
+				var trailer http.Header
+
+				getHeadersFrame := func(fields ...qpack.HeaderField) []byte {
+					headerBuf := &bytes.Buffer{}
+					enc := qpack.NewEncoder(headerBuf)
+					for _, f := range fields {
+						Expect(enc.WriteField(f)).To(Succeed())
+					}
+					b := &bytes.Buffer{}
+					(&headersFrame{Length: uint64(headerBuf.Len())}).Write(b)
+					b.Write(headerBuf.Bytes())
+					return b.Bytes()
+				}
+
+				BeforeEach(func() {
+					trailer = nil
+					rb.setTrailer(context.Background(), newQPACKDecoder(0, 0), &trailer, 1000)
+				})
+
+				It("reads trailers", func() {
+					buf.Write(getDataFrame([]byte("foobar")))
+					buf.Write(getHeadersFrame(
+						qpack.HeaderField{Name: "grpc-status", Value: "0"},
+						qpack.HeaderField{Name: "grpc-message", Value: "foo"},
+					))
+					data, err := ioutil.ReadAll(rb)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(data).To(Equal([]byte("foobar")))
+					Expect(trailer).To(Equal(http.Header{
+						"Grpc-Status":  []string{"0"},
+						"Grpc-Message": []string{"foo"},
+					}))
+				})
+
+				It("errors on pseudo headers in trailers", func() {
+					buf.Write(getDataFrame([]byte("foobar")))
+					buf.Write(getHeadersFrame(qpack.HeaderField{Name: ":status", Value: "200"}))
+					_, err := ioutil.ReadAll(rb)
+					Expect(err).To(MatchError("pseudo header in trailer"))
+				})
+
+				It("errors when the trailers are too large", func() {
+					buf.Write(getDataFrame([]byte("foobar")))
+					(&headersFrame{Length: 1001}).Write(buf)
+					str.EXPECT().CancelRead(quic.ErrorCode(errorFrameError))
+					_, err := ioutil.ReadAll(rb)
+					Expect(err).To(MatchError("HEADERS frame too large: 1001 bytes (max: 1000)"))
+				})
+
+				It("errors when the trailer field section is too large", func() {
+					rb.setTrailer(context.Background(), newQPACKDecoder(0, 0), &trailer, 130)
+					buf.Write(getDataFrame([]byte("foobar")))
+					buf.Write(getHeadersFrame(qpack.HeaderField{Name: "grpc-message", Value: strings.Repeat("a", 100)}))
+					str.EXPECT().CancelRead(quic.ErrorCode(errorExcessiveLoad))
+					_, err := ioutil.ReadAll(rb)
+					Expect(err).To(MatchError(ContainSubstring("trailer field section too large")))
+				})
+
+				It("errors on DATA frames after the trailers", func() {
+					buf.Write(getDataFrame([]byte("foo")))
+					buf.Write(getHeadersFrame(qpack.HeaderField{Name: "grpc-status", Value: "0"}))
+					buf.Write(getDataFrame([]byte("bar")))
+					_, err := ioutil.ReadAll(rb)
+					Expect(err).To(MatchError("peer sent a frame after the trailers: *http3.dataFrame"))
+					Expect(errorCbCalled).To(BeTrue())
+				})
 			})
 
 			It("errors when it can't parse the frame", func() {
@@ -155,6 +247,14 @@ var _ = Describe("Body", func() {
 				Expect(errorCbCalled).To(BeTrue())
 			})
 
+			It("errors on frame types reserved for HTTP/2, and calls the error callback", func() {
+				buf.Write(getDataFrame([]byte("foo")))
+				buf.Write([]byte{0x8, 0x4, 0, 0, 0, 1}) // WINDOW_UPDATE
+				_, err := ioutil.ReadAll(rb)
+				Expect(err).To(MatchError("peer sent an unexpected frame: *http3.reservedFrame"))
+				Expect(errorCbCalled).To(BeTrue())
+			})
+
 			if bodyType == bodyTypeResponse {
 				It("closes the reqDone channel when Read errors", func() {
 					buf.Write([]byte("invalid"))
@@ -172,6 +272,16 @@ var _ = Describe("Body", func() {
 					Expect(err).To(HaveOccurred())
 				})
 
+				It("returns the context error when Read errors after the request was canceled", func() {
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+					rb.setTrailer(ctx, newQPACKDecoder(0, 0), &http.Header{}, 1000)
+					buf.Write([]byte("invalid"))
+					_, err := rb.Read([]byte{0})
+					Expect(err).To(MatchError(context.Canceled))
+					Expect(reqDone).To(BeClosed())
+				})
+
 				It("closes responses", func() {
 					str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled))
 					Expect(rb.Close()).To(Succeed())
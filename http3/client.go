@@ -8,7 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/lucas-clemente/quic-go"
@@ -26,6 +29,7 @@ const MethodGet0RTT = "GET_0RTT"
 const (
 	defaultUserAgent              = "quic-go HTTP/3"
 	defaultMaxResponseHeaderBytes = 10 * 1 << 20 // 10 MB
+	max1xxResponses               = 5            // arbitrary bound on the number of informational responses, same as net/http
 )
 
 var defaultQuicConfig = &quic.Config{
@@ -36,10 +40,19 @@ var defaultQuicConfig = &quic.Config{
 
 var dialAddr = quic.DialAddrEarly
 
+// errGoAway is returned when the request wasn't processed, because the server sent a GOAWAY frame.
+// The request can be retried on a new connection.
+var errGoAway = errors.New("http3: server sent GOAWAY")
+
 type roundTripperOpts struct {
-	DisableCompression bool
-	EnableDatagram     bool
-	MaxHeaderBytes     int64
+	DisableCompression    bool
+	EnableDatagram        bool
+	MaxHeaderBytes        int64
+	QPACKMaxTableCapacity uint64
+	QPACKBlockedStreams   uint64
+	AdditionalSettings    map[uint64]uint64
+	StreamHijacker        func(FrameType, quic.Session, quic.Stream) (hijacked bool, err error)
+	UniStreamHijacker     func(StreamType, quic.Session, quic.ReceiveStream) (hijacked bool)
 }
 
 // client is a HTTP3 client doing requests
@@ -54,11 +67,21 @@ type client struct {
 
 	requestWriter *requestWriter
 
-	decoder *qpack.Decoder
+	encoder *qpackEncoder
+	decoder *qpackDecoder
 
 	hostname string
 	session  quic.EarlySession
 
+	settingsMutex    sync.Mutex
+	receivedSettings chan struct{} // closed when the server's SETTINGS frame was received
+	settings         *settingsFrame
+
+	goAwayMutex    sync.Mutex
+	receivedGoAway bool
+	goAwayID       quic.StreamID
+	activeRequests int
+
 	logger utils.Logger
 }
 
@@ -78,9 +101,18 @@ func newClient(
 	if len(quicConfig.Versions) != 1 {
 		return nil, errors.New("can only use a single QUIC version for dialing a HTTP/3 connection")
 	}
-	quicConfig.MaxIncomingStreams = -1 // don't allow any bidirectional streams
+	if opts.StreamHijacker == nil {
+		quicConfig.MaxIncomingStreams = -1 // don't allow any bidirectional streams
+	} else if quicConfig.MaxIncomingStreams < 0 {
+		// The StreamHijacker handles bidirectional streams opened by the server.
+		quicConfig.MaxIncomingStreams = 0 // use the default value
+	}
+	// If a UniStreamHijacker is set, unidirectional streams might carry application data.
+	if quicConfig.MaxReceiveUniStreamFlowControlWindow == 0 && opts.UniStreamHijacker == nil {
+		quicConfig.MaxReceiveUniStreamFlowControlWindow = uniStreamReceiveWindow
+	}
 	quicConfig.EnableDatagrams = opts.EnableDatagram
-	logger := utils.DefaultLogger.WithPrefix("h3 client")
+	logger := getLogger(quicConfig).WithPrefix("h3 client")
 
 	if tlsConf == nil {
 		tlsConf = &tls.Config{}
@@ -90,15 +122,18 @@ func newClient(
 	// Replace existing ALPNs by H3
 	tlsConf.NextProtos = []string{versionToALPN(quicConfig.Versions[0])}
 
+	encoder := newQPACKEncoder()
 	return &client{
-		hostname:      authorityAddr("https", hostname),
-		tlsConf:       tlsConf,
-		requestWriter: newRequestWriter(logger),
-		decoder:       qpack.NewDecoder(func(hf qpack.HeaderField) {}),
-		config:        quicConfig,
-		opts:          opts,
-		dialer:        dialer,
-		logger:        logger,
+		hostname:         authorityAddr("https", hostname),
+		tlsConf:          tlsConf,
+		requestWriter:    newRequestWriter(encoder, logger),
+		encoder:          encoder,
+		decoder:          newQPACKDecoder(opts.QPACKMaxTableCapacity, opts.QPACKBlockedStreams),
+		receivedSettings: make(chan struct{}),
+		config:           quicConfig,
+		opts:             opts,
+		dialer:           dialer,
+		logger:           logger,
 	}, nil
 }
 
@@ -122,6 +157,9 @@ func (c *client) dial() error {
 	}()
 
 	go c.handleUnidirectionalStreams()
+	if c.opts.StreamHijacker != nil {
+		go c.handleBidirectionalStreams()
+	}
 	return nil
 }
 
@@ -134,9 +172,31 @@ func (c *client) setupSession() error {
 	buf := &bytes.Buffer{}
 	quicvarint.Write(buf, streamTypeControlStream)
 	// send the SETTINGS frame
-	(&settingsFrame{Datagram: c.opts.EnableDatagram}).Write(buf)
-	_, err = str.Write(buf.Bytes())
-	return err
+	(&settingsFrame{
+		Datagram:              c.opts.EnableDatagram,
+		QPACKMaxTableCapacity: c.opts.QPACKMaxTableCapacity,
+		QPACKBlockedStreams:   c.opts.QPACKBlockedStreams,
+		MaxFieldSectionSize:   c.maxHeaderBytes(),
+		other:                 c.opts.AdditionalSettings,
+	}).Write(buf)
+	if _, err := str.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if c.opts.QPACKMaxTableCapacity == 0 {
+		return nil
+	}
+	// open the QPACK decoder stream
+	decoderStr, err := c.session.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	buf.Reset()
+	quicvarint.Write(buf, streamTypeQPACKDecoderStream)
+	if _, err := decoderStr.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	c.decoder.setStream(decoderStr)
+	return nil
 }
 
 func (c *client) handleUnidirectionalStreams() {
@@ -144,6 +204,8 @@ func (c *client) handleUnidirectionalStreams() {
 		str, err := c.session.AcceptUniStream(context.Background())
 		if err != nil {
 			c.logger.Debugf("accepting unidirectional stream failed: %s", err)
+			// unblock requests waiting for dynamic table entries
+			c.decoder.close()
 			return
 		}
 
@@ -153,14 +215,22 @@ func (c *client) handleUnidirectionalStreams() {
 				c.logger.Debugf("reading stream type on stream %d failed: %s", str.StreamID(), err)
 				return
 			}
-			// We're only interested in the control stream here.
 			switch streamType {
 			case streamTypeControlStream:
 			case streamTypePushStream:
 				// We never increased the Push ID, so we don't expect any push streams.
 				c.session.CloseWithError(quic.ErrorCode(errorIDError), "")
 				return
+			case streamTypeQPACKEncoderStream:
+				handleQPACKStreamError(c.session, errorQPACKEncoderStreamError, c.decoder.handleEncoderStream(str))
+				return
+			case streamTypeQPACKDecoderStream:
+				handleQPACKStreamError(c.session, errorQPACKDecoderStreamError, c.encoder.handleDecoderStream(str))
+				return
 			default:
+				if c.opts.UniStreamHijacker != nil && c.opts.UniStreamHijacker(StreamType(streamType), c.session, str) {
+					return
+				}
 				str.CancelRead(quic.ErrorCode(errorStreamCreationError))
 				return
 			}
@@ -174,19 +244,148 @@ func (c *client) handleUnidirectionalStreams() {
 				c.session.CloseWithError(quic.ErrorCode(errorMissingSettings), "")
 				return
 			}
-			if !sf.Datagram {
-				return
-			}
 			// If datagram support was enabled on our side as well as on the server side,
 			// we can expect it to have been negotiated both on the transport and on the HTTP/3 layer.
 			// Note: ConnectionState() will block until the handshake is complete (relevant when using 0-RTT).
-			if c.opts.EnableDatagram && !c.session.ConnectionState().SupportsDatagrams {
+			if sf.Datagram && c.opts.EnableDatagram && !c.session.ConnectionState().SupportsDatagrams {
 				c.session.CloseWithError(quic.ErrorCode(errorSettingsError), "missing QUIC Datagram support")
+				return
 			}
+			if err := c.handleSettings(sf); err != nil {
+				c.session.CloseWithError(quic.ErrorCode(errorStreamCreationError), err.Error())
+				return
+			}
+			if sf.QPACKMaxTableCapacity > 0 {
+				if err := openQPACKEncoderStream(c.session, c.encoder, sf); err != nil {
+					c.logger.Debugf("Opening the QPACK encoder stream failed: %s", err)
+					c.session.CloseWithError(quic.ErrorCode(errorInternalError), "")
+					return
+				}
+			}
+			c.handleControlStream(str)
 		}()
 	}
 }
 
+// handleBidirectionalStreams handles bidirectional streams opened by the server.
+// HTTP/3 doesn't define any server-initiated bidirectional streams,
+// so all streams that are not taken over by the StreamHijacker are a protocol violation.
+func (c *client) handleBidirectionalStreams() {
+	for {
+		str, err := c.session.AcceptStream(context.Background())
+		if err != nil {
+			c.logger.Debugf("accepting bidirectional stream failed: %s", err)
+			return
+		}
+		go func(str quic.Stream) {
+			_, err := parseNextFrameWithHandler(str, func(ft FrameType) (bool, error) {
+				return c.opts.StreamHijacker(ft, c.session, str)
+			})
+			if err == errHijacked {
+				return
+			}
+			if err != nil {
+				c.logger.Debugf("error handling stream: %s", err)
+			}
+			c.session.CloseWithError(quic.ErrorCode(errorStreamCreationError), "received HTTP/3 frame on bidirectional stream")
+		}(str)
+	}
+}
+
+func (c *client) handleSettings(sf *settingsFrame) error {
+	c.settingsMutex.Lock()
+	defer c.settingsMutex.Unlock()
+	if c.settings != nil {
+		return errors.New("duplicate control stream")
+	}
+	c.settings = sf
+	close(c.receivedSettings)
+	c.requestWriter.SetMaxFieldSectionSize(sf.MaxFieldSectionSize)
+	return nil
+}
+
+// extendedConnectEnabled says if the server enabled extended CONNECT.
+// It must only be called after the SETTINGS frame was received.
+func (c *client) extendedConnectEnabled() bool {
+	c.settingsMutex.Lock()
+	defer c.settingsMutex.Unlock()
+	return c.settings.ExtendedConnect
+}
+
+// handleControlStream handles the frames sent on the control stream after the SETTINGS frame.
+func (c *client) handleControlStream(str quic.ReceiveStream) {
+	for {
+		f, err := parseNextFrame(str)
+		if err != nil {
+			c.logger.Debugf("reading from the control stream failed: %s", err)
+			return
+		}
+		switch f := f.(type) {
+		case *goAwayFrame:
+			if err := c.handleGoAway(f.StreamID); err != nil {
+				c.session.CloseWithError(quic.ErrorCode(errorIDError), err.Error())
+				return
+			}
+		default:
+			c.session.CloseWithError(quic.ErrorCode(errorFrameUnexpected), "")
+			return
+		}
+	}
+}
+
+func (c *client) handleGoAway(id quic.StreamID) error {
+	c.goAwayMutex.Lock()
+	if id%4 != 0 {
+		c.goAwayMutex.Unlock()
+		return fmt.Errorf("GOAWAY for invalid stream ID: %d", id)
+	}
+	if c.receivedGoAway && id > c.goAwayID {
+		c.goAwayMutex.Unlock()
+		return fmt.Errorf("GOAWAY stream ID increased from %d to %d", c.goAwayID, id)
+	}
+	c.receivedGoAway = true
+	c.goAwayID = id
+	idle := c.activeRequests == 0
+	c.goAwayMutex.Unlock()
+
+	if idle {
+		c.session.CloseWithError(quic.ErrorCode(errorNoError), "")
+	}
+	return nil
+}
+
+// isGoingAway says if a request on this stream will be processed by the server.
+func (c *client) isGoingAway(id quic.StreamID) bool {
+	c.goAwayMutex.Lock()
+	defer c.goAwayMutex.Unlock()
+	return c.receivedGoAway && id >= c.goAwayID
+}
+
+// startRequest registers a new request.
+// It returns false if the server already sent a GOAWAY frame.
+func (c *client) startRequest() bool {
+	c.goAwayMutex.Lock()
+	defer c.goAwayMutex.Unlock()
+	if c.receivedGoAway {
+		return false
+	}
+	c.activeRequests++
+	return true
+}
+
+// finishRequest is called when a request is done.
+// Once the server sent a GOAWAY frame, the session is closed after the last request completed.
+func (c *client) finishRequest() {
+	c.goAwayMutex.Lock()
+	c.activeRequests--
+	idle := c.receivedGoAway && c.activeRequests == 0
+	c.goAwayMutex.Unlock()
+
+	if idle {
+		c.session.CloseWithError(quic.ErrorCode(errorNoError), "")
+	}
+}
+
 func (c *client) Close() error {
 	if c.session == nil {
 		return nil
@@ -201,6 +400,18 @@ func (c *client) maxHeaderBytes() uint64 {
 	return uint64(c.opts.MaxHeaderBytes)
 }
 
+// Ping checks that the QUIC connection is still alive.
+// It sends a PING frame and blocks until the server acknowledges it, or until ctx is canceled.
+func (c *client) Ping(ctx context.Context) error {
+	c.dialOnce.Do(func() {
+		c.handshakeErr = c.dial()
+	})
+	if c.handshakeErr != nil {
+		return c.handshakeErr
+	}
+	return c.session.Ping(ctx)
+}
+
 // RoundTrip executes a request and returns a response
 func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	if authorityAddr("https", hostnameFromRequest(req)) != c.hostname {
@@ -214,6 +425,10 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	if c.handshakeErr != nil {
 		return nil, c.handshakeErr
 	}
+	// Don't start any new requests after the server sent a GOAWAY.
+	if !c.startRequest() {
+		return nil, errGoAway
+	}
 
 	// Immediately send out this request, if this is a 0-RTT request.
 	if req.Method == MethodGet0RTT {
@@ -223,24 +438,51 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 		select {
 		case <-c.session.HandshakeComplete().Done():
 		case <-req.Context().Done():
+			c.finishRequest()
+			return nil, req.Context().Err()
+		}
+	}
+
+	// The client must not send an extended CONNECT request before the server enabled it in its SETTINGS.
+	if isExtendedConnectRequest(req) {
+		select {
+		case <-c.receivedSettings:
+		case <-c.session.Context().Done():
+			c.finishRequest()
+			return nil, errors.New("http3: session closed before receiving the SETTINGS frame")
+		case <-req.Context().Done():
+			c.finishRequest()
 			return nil, req.Context().Err()
 		}
+		if !c.extendedConnectEnabled() {
+			c.finishRequest()
+			return nil, errors.New("http3: server didn't enable extended CONNECT")
+		}
 	}
 
 	str, err := c.session.OpenStreamSync(req.Context())
 	if err != nil {
+		c.finishRequest()
 		return nil, err
 	}
+	if c.isGoingAway(str.StreamID()) {
+		str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
+		str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+		c.finishRequest()
+		return nil, errGoAway
+	}
 
 	// Request Cancellation:
 	// This go routine keeps running even after RoundTrip() returns.
 	// It is shut down when the application is done processing the body.
 	reqDone := make(chan struct{})
 	go func() {
+		defer c.finishRequest()
 		select {
 		case <-req.Context().Done():
 			str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
 			str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+			c.decoder.cancelStream(str)
 		case <-reqDone:
 		}
 	}()
@@ -248,6 +490,10 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 	rsp, rerr := c.doRequest(req, str, reqDone)
 	if rerr.err != nil { // if any error occurred
 		close(reqDone)
+		// The server rejects requests on streams with IDs equal to or higher than the ID sent in the GOAWAY frame.
+		if serr, ok := rerr.err.(quic.StreamError); ok && serr.ErrorCode() == quic.ErrorCode(errorRequestRejected) && c.isGoingAway(str.StreamID()) {
+			return nil, errGoAway
+		}
 		if rerr.streamErr != 0 { // if it was a stream error
 			str.CancelWrite(quic.ErrorCode(rerr.streamErr))
 		}
@@ -256,7 +502,11 @@ func (c *client) RoundTrip(req *http.Request) (*http.Response, error) {
 			if rerr.err != nil {
 				reason = rerr.err.Error()
 			}
-			c.session.CloseWithError(quic.ErrorCode(rerr.connErr), reason)
+			closeWithConnError(c.session, rerr.connErr, reason)
+		}
+		// If the request was canceled, the stream was reset, and the error is a stream error.
+		if err := req.Context().Err(); err != nil {
+			return nil, err
 		}
 	}
 	return rsp, rerr.err
@@ -272,9 +522,69 @@ func (c *client) doRequest(
 		requestGzip = true
 	}
 	if err := c.requestWriter.WriteRequest(str, req, requestGzip); err != nil {
+		if err == errRequestHeaderListSize {
+			// Nothing was sent on the stream yet.
+			str.CancelRead(quic.ErrorCode(errorRequestCanceled))
+			return nil, newStreamError(errorRequestCanceled, err)
+		}
 		return nil, newStreamError(errorInternalError, err)
 	}
 
+	trace := httptrace.ContextClientTrace(req.Context())
+	var (
+		res    *http.Response
+		num1xx int // number of informational responses received
+	)
+	for {
+		hfs, rerr := c.readHeaders(req.Context(), str)
+		if rerr.err != nil {
+			return nil, rerr
+		}
+		res, rerr = parseResponseHeaders(hfs)
+		if rerr.err != nil {
+			return nil, rerr
+		}
+		if res.StatusCode < 100 || res.StatusCode > 199 {
+			break
+		}
+		// Informational responses (1xx) are followed by another HEADERS frame.
+		num1xx++
+		if num1xx > max1xxResponses {
+			return nil, newStreamError(errorExcessiveLoad, errors.New("too many 1xx informational responses"))
+		}
+		if trace != nil && trace.Got1xxResponse != nil {
+			if err := trace.Got1xxResponse(res.StatusCode, textproto.MIMEHeader(res.Header)); err != nil {
+				return nil, newStreamError(errorRequestCanceled, err)
+			}
+		}
+		if res.StatusCode == http.StatusContinue && trace != nil && trace.Got100Continue != nil {
+			trace.Got100Continue()
+		}
+	}
+	connState := qtls.ToTLSConnectionState(c.session.ConnectionState().TLS)
+	res.TLS = &connState
+
+	respBody := newResponseBody(str, reqDone, func() {
+		c.session.CloseWithError(quic.ErrorCode(errorFrameUnexpected), "")
+	})
+	respBody.setTrailer(req.Context(), c.decoder, &res.Trailer, c.maxHeaderBytes())
+	if requestGzip && res.Header.Get("Content-Encoding") == "gzip" {
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Body = newGzipReader(respBody)
+		res.Uncompressed = true
+	} else {
+		res.Body = &hijackableBody{body: respBody, sess: c.session}
+	}
+
+	return res, requestError{}
+}
+
+// readHeaders reads a HEADERS frame and decodes the header block.
+// If the header block references dynamic table entries that weren't received yet,
+// it blocks until these entries arrive or ctx is canceled.
+func (c *client) readHeaders(ctx context.Context, str quic.Stream) ([]qpack.HeaderField, requestError) {
 	frame, err := parseNextFrame(str)
 	if err != nil {
 		return nil, newStreamError(errorFrameError, err)
@@ -290,18 +600,24 @@ func (c *client) doRequest(
 	if _, err := io.ReadFull(str, headerBlock); err != nil {
 		return nil, newStreamError(errorRequestIncomplete, err)
 	}
-	hfs, err := c.decoder.DecodeFull(headerBlock)
+	hfs, err := c.decoder.decode(ctx, str, headerBlock)
 	if err != nil {
-		// TODO: use the right error code
-		return nil, newConnError(errorGeneralProtocolError, err)
+		if _, ok := err.(*qpackDecodingError); ok {
+			return nil, newConnError(errorQPACKDecompressionFailed, err)
+		}
+		return nil, newStreamError(errorRequestCanceled, err)
+	}
+	if size := fieldSectionSize(hfs); size > c.maxHeaderBytes() {
+		return nil, newStreamError(errorExcessiveLoad, fmt.Errorf("header field section too large: %d bytes (max: %d)", size, c.maxHeaderBytes()))
 	}
+	return hfs, requestError{}
+}
 
-	connState := qtls.ToTLSConnectionState(c.session.ConnectionState().TLS)
+func parseResponseHeaders(hfs []qpack.HeaderField) (*http.Response, requestError) {
 	res := &http.Response{
 		Proto:      "HTTP/3",
 		ProtoMajor: 3,
 		Header:     http.Header{},
-		TLS:        &connState,
 	}
 	for _, hf := range hfs {
 		switch hf.Name {
@@ -312,22 +628,18 @@ func (c *client) doRequest(
 			}
 			res.StatusCode = status
 			res.Status = hf.Value + " " + http.StatusText(status)
+		case "trailer":
+			if res.Trailer == nil {
+				res.Trailer = http.Header{}
+			}
+			for _, key := range strings.Split(hf.Value, ",") {
+				if key = strings.TrimSpace(key); key != "" {
+					res.Trailer[http.CanonicalHeaderKey(key)] = nil
+				}
+			}
 		default:
 			res.Header.Add(hf.Name, hf.Value)
 		}
 	}
-	respBody := newResponseBody(str, reqDone, func() {
-		c.session.CloseWithError(quic.ErrorCode(errorFrameUnexpected), "")
-	})
-	if requestGzip && res.Header.Get("Content-Encoding") == "gzip" {
-		res.Header.Del("Content-Encoding")
-		res.Header.Del("Content-Length")
-		res.ContentLength = -1
-		res.Body = newGzipReader(respBody)
-		res.Uncompressed = true
-	} else {
-		res.Body = respBody
-	}
-
 	return res, requestError{}
 }
@@ -9,6 +9,9 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
 	"time"
 
 	"github.com/golang/mock/gomock"
@@ -149,6 +152,32 @@ var _ = Describe("Client", func() {
 		Expect(err).To(MatchError(testErr))
 	})
 
+	It("allows the server to open bidirectional streams if a StreamHijacker is set", func() {
+		testErr := errors.New("handshake error")
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{
+			StreamHijacker: func(FrameType, quic.Session, quic.Stream) (bool, error) { return false, nil },
+		}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		dialAddr = func(hostname string, _ *tls.Config, quicConf *quic.Config) (quic.EarlySession, error) {
+			Expect(quicConf.MaxIncomingStreams).To(BeZero())
+			return nil, testErr
+		}
+		_, err = client.RoundTrip(req)
+		Expect(err).To(MatchError(testErr))
+	})
+
+	It("uses a small flow control window for unidirectional streams", func() {
+		testErr := errors.New("handshake error")
+		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		dialAddr = func(hostname string, _ *tls.Config, quicConf *quic.Config) (quic.EarlySession, error) {
+			Expect(quicConf.MaxReceiveUniStreamFlowControlWindow).To(BeEquivalentTo(uniStreamReceiveWindow))
+			return nil, testErr
+		}
+		_, err = client.RoundTrip(req)
+		Expect(err).To(MatchError(testErr))
+	})
+
 	It("errors when dialing fails", func() {
 		testErr := errors.New("handshake error")
 		client, err := newClient("localhost:1337", nil, &roundTripperOpts{}, nil, nil)
@@ -234,6 +263,100 @@ var _ = Describe("Client", func() {
 			time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to sess.CloseWithError
 		})
 
+		It("applies the server's maximum field section size to requests", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{MaxFieldSectionSize: 1234}).Write(buf)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(func() uint64 {
+				client.requestWriter.mutex.Lock()
+				defer client.requestWriter.mutex.Unlock()
+				return client.requestWriter.peerMaxFieldSectionSize
+			}).Should(BeEquivalentTo(1234))
+		})
+
+		It("handles GOAWAY frames", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&goAwayFrame{StreamID: 8}).Write(buf)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			closed := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorNoError), "").Do(func(quic.ErrorCode, string) { close(closed) })
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(func() bool { return client.isGoingAway(8) }).Should(BeTrue())
+			Expect(client.isGoingAway(4)).To(BeFalse())
+			// there are no active requests, so the session is closed
+			Eventually(closed).Should(BeClosed())
+		})
+
+		It("errors when the stream ID in the GOAWAY frame increases", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&goAwayFrame{StreamID: 8}).Write(buf)
+			(&goAwayFrame{StreamID: 12}).Write(buf)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorNoError), "")
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorIDError), gomock.Any()).Do(func(quic.ErrorCode, string) {
+				close(done)
+			})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("errors when the GOAWAY frame contains an invalid stream ID", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, streamTypeControlStream)
+			(&settingsFrame{}).Write(buf)
+			(&goAwayFrame{StreamID: 9}).Write(buf)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				return controlStr, nil
+			})
+			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+				<-testDone
+				return nil, errors.New("test done")
+			})
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorIDError), gomock.Any()).Do(func(quic.ErrorCode, string) {
+				close(done)
+			})
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("ignores streams other than the control stream", func() {
 			buf := &bytes.Buffer{}
 			quicvarint.Write(buf, 1337)
@@ -357,6 +480,140 @@ var _ = Describe("Client", func() {
 		})
 	})
 
+	Context("hijacking", func() {
+		var (
+			request *http.Request
+			sess    *mockquic.MockEarlySession
+		)
+
+		BeforeEach(func() {
+			var err error
+			request, err = http.NewRequest("GET", "https://quic.clemente.io:1337/file1.dat", nil)
+			Expect(err).ToNot(HaveOccurred())
+			sess = mockquic.NewMockEarlySession(mockCtrl)
+			sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			sess.EXPECT().OpenStreamSync(gomock.Any()).Return(nil, errors.New("done"))
+			dialAddr = func(hostname string, _ *tls.Config, _ *quic.Config) (quic.EarlySession, error) { return sess, nil }
+		})
+
+		It("sends the additional settings in the SETTINGS frame", func() {
+			client.opts.AdditionalSettings = map[uint64]uint64{0x1337: 42}
+			written := make(chan []byte, 1)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				written <- b
+				return len(b), nil
+			})
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done"))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			var b []byte
+			Eventually(written).Should(Receive(&b))
+			r := bytes.NewReader(b)
+			_, err = quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := parseNextFrame(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&settingsFrame{}))
+			Expect(frame.(*settingsFrame).other).To(HaveKeyWithValue(uint64(0x1337), uint64(42)))
+		})
+
+		It("sends the QPACK settings and the maximum field section size in the SETTINGS frame", func() {
+			client.opts.QPACKMaxTableCapacity = 4096
+			client.opts.QPACKBlockedStreams = 10
+			written := make(chan []byte, 1)
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				written <- b
+				return len(b), nil
+			})
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done"))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			var b []byte
+			Eventually(written).Should(Receive(&b))
+			r := bytes.NewReader(b)
+			_, err = quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := parseNextFrame(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&settingsFrame{}))
+			sf := frame.(*settingsFrame)
+			Expect(sf.MaxFieldSectionSize).To(BeEquivalentTo(1337))
+			Expect(sf.QPACKMaxTableCapacity).To(BeEquivalentTo(4096))
+			Expect(sf.QPACKBlockedStreams).To(BeEquivalentTo(10))
+		})
+
+		It("lets the StreamHijacker take over bidirectional streams", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, 0x41)
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			hijacked := make(chan FrameType, 1)
+			client.opts.StreamHijacker = func(ft FrameType, qsess quic.Session, qstr quic.Stream) (bool, error) {
+				defer GinkgoRecover()
+				Expect(qsess).To(Equal(sess))
+				Expect(qstr).To(Equal(str))
+				hijacked <- ft
+				return true, nil
+			}
+			sess.EXPECT().OpenUniStream().Return(mockquic.NewMockStream(mockCtrl), errors.New("done"))
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorInternalError), gomock.Any()) // opening the control stream failed
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done"))
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(str, nil)
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(hijacked).Should(Receive(BeEquivalentTo(0x41)))
+			time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any other calls to sess.CloseWithError
+		})
+
+		It("closes the session when the server opens a bidirectional stream that is not hijacked", func() {
+			buf := &bytes.Buffer{}
+			(&headersFrame{Length: 0}).Write(buf)
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			client.opts.StreamHijacker = func(FrameType, quic.Session, quic.Stream) (bool, error) {
+				Fail("StreamHijacker called for a HEADERS frame")
+				return false, nil
+			}
+			sess.EXPECT().OpenUniStream().Return(mockquic.NewMockStream(mockCtrl), errors.New("done"))
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorInternalError), gomock.Any()) // opening the control stream failed
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done"))
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(str, nil)
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+			done := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorStreamCreationError), gomock.Any()).Do(func(quic.ErrorCode, string) { close(done) })
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("lets the UniStreamHijacker take over unidirectional streams of unknown types", func() {
+			buf := &bytes.Buffer{}
+			quicvarint.Write(buf, 0x54)
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+			hijacked := make(chan StreamType, 1)
+			client.opts.UniStreamHijacker = func(st StreamType, qsess quic.Session, qstr quic.ReceiveStream) bool {
+				defer GinkgoRecover()
+				Expect(qsess).To(Equal(sess))
+				hijacked <- st
+				return true
+			}
+			sess.EXPECT().OpenUniStream().Return(mockquic.NewMockStream(mockCtrl), errors.New("done"))
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorInternalError), gomock.Any()) // opening the control stream failed
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(str, nil)
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done"))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("done"))
+			Eventually(hijacked).Should(Receive(BeEquivalentTo(0x54)))
+			time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to str.CancelRead
+		})
+	})
+
 	Context("Doing requests", func() {
 		var (
 			request              *http.Request
@@ -397,6 +654,7 @@ var _ = Describe("Client", func() {
 				close(settingsFrameWritten)
 			}) // SETTINGS frame
 			str = mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().AnyTimes()
 			sess = mockquic.NewMockEarlySession(mockCtrl)
 			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
 			sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
@@ -423,6 +681,17 @@ var _ = Describe("Client", func() {
 			Expect(err).To(MatchError(testErr))
 		})
 
+		It("pings the session", func() {
+			sess.EXPECT().Ping(context.Background())
+			Expect(client.Ping(context.Background())).To(Succeed())
+		})
+
+		It("returns the error when pinging the session fails", func() {
+			testErr := errors.New("timeout")
+			sess.EXPECT().Ping(context.Background()).Return(testErr)
+			Expect(client.Ping(context.Background())).To(MatchError(testErr))
+		})
+
 		It("performs a 0-RTT request", func() {
 			testErr := errors.New("stream open error")
 			request.Method = MethodGet0RTT
@@ -440,9 +709,58 @@ var _ = Describe("Client", func() {
 			Expect(decodeHeader(buf)).To(HaveKeyWithValue(":method", "GET"))
 		})
 
+		It("doesn't send new requests after receiving a GOAWAY frame", func() {
+			client.dialOnce.Do(func() { client.handshakeErr = client.dial() })
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorNoError), "")
+			Expect(client.handleGoAway(0)).To(Succeed())
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(errGoAway))
+		})
+
+		It("cancels requests on streams with IDs higher than the GOAWAY stream ID", func() {
+			client.dialOnce.Do(func() { client.handshakeErr = client.dial() })
+			str := mockquic.NewMockStream(mockCtrl)
+			str.EXPECT().StreamID().Return(quic.StreamID(8)).AnyTimes()
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+			str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled))
+			sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			sess.EXPECT().OpenStreamSync(context.Background()).DoAndReturn(func(context.Context) (quic.Stream, error) {
+				// the GOAWAY frame arrives while the stream is being opened
+				Expect(client.handleGoAway(4)).To(Succeed())
+				return str, nil
+			})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorNoError), "")
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError(errGoAway))
+		})
+
+		It("closes the session when the last request completes after receiving a GOAWAY frame", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
+			rw.WriteHeader(200)
+			rw.Flush()
+
+			client.dialOnce.Do(func() { client.handshakeErr = client.dial() })
+			sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			sess.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			sess.EXPECT().ConnectionState().Return(quic.ConnectionState{})
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			str.EXPECT().CancelRead(quic.ErrorCode(errorRequestCanceled))
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(client.handleGoAway(4)).To(Succeed())
+			closed := make(chan struct{})
+			sess.EXPECT().CloseWithError(quic.ErrorCode(errorNoError), "").Do(func(quic.ErrorCode, string) { close(closed) })
+			Consistently(closed).ShouldNot(BeClosed())
+			Expect(rsp.Body.Close()).To(Succeed())
+			Eventually(closed).Should(BeClosed())
+		})
+
 		It("returns a response", func() {
 			rspBuf := &bytes.Buffer{}
-			rw := newResponseWriter(rspBuf, utils.DefaultLogger)
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
 			rw.WriteHeader(418)
 			rw.Flush()
 
@@ -463,6 +781,196 @@ var _ = Describe("Client", func() {
 			Expect(rsp.StatusCode).To(Equal(418))
 		})
 
+		It("gives access to the stream and the QUIC session of the response", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
+			rw.WriteHeader(200)
+			rw.Flush()
+
+			gomock.InOrder(
+				sess.EXPECT().HandshakeComplete().Return(handshakeCtx),
+				sess.EXPECT().OpenStreamSync(context.Background()).Return(str, nil),
+				sess.EXPECT().ConnectionState().Return(quic.ConnectionState{}),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Body).To(BeAssignableToTypeOf(&hijackableBody{}))
+			Expect(rsp.Body.(Hijacker).Session()).To(Equal(sess))
+			Expect(rsp.Body.(DataStreamer).DataStream()).To(Equal(str))
+		})
+
+		It("passes informational responses to the client trace", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
+			rw.Header().Set("Link", "</style.css>; rel=preload; as=style")
+			rw.WriteHeader(http.StatusEarlyHints)
+			rw.Header().Add("Link", "</script.js>; rel=preload; as=script")
+			rw.WriteHeader(http.StatusEarlyHints)
+			rw.Header().Del("Link")
+			rw.WriteHeader(http.StatusOK)
+			rw.Flush()
+
+			gomock.InOrder(
+				sess.EXPECT().HandshakeComplete().Return(handshakeCtx),
+				sess.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil),
+				sess.EXPECT().ConnectionState().Return(quic.ConnectionState{}),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			var statusCodes []int
+			var links [][]string
+			trace := &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					statusCodes = append(statusCodes, code)
+					links = append(links, header["Link"])
+					return nil
+				},
+			}
+			rsp, err := client.RoundTrip(request.WithContext(httptrace.WithClientTrace(context.Background(), trace)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.StatusCode).To(Equal(200))
+			Expect(rsp.Header).ToNot(HaveKey("Link"))
+			Expect(statusCodes).To(Equal([]int{103, 103}))
+			Expect(links).To(Equal([][]string{
+				{"</style.css>; rel=preload; as=style"},
+				{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"},
+			}))
+		})
+
+		It("aborts the request if the client trace returns an error for an informational response", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
+			rw.WriteHeader(http.StatusEarlyHints)
+			rw.WriteHeader(http.StatusOK)
+			rw.Flush()
+
+			sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			sess.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled))
+			testErr := errors.New("trace error")
+			trace := &httptrace.ClientTrace{
+				Got1xxResponse: func(int, textproto.MIMEHeader) error { return testErr },
+			}
+			_, err := client.RoundTrip(request.WithContext(httptrace.WithClientTrace(context.Background(), trace)))
+			Expect(err).To(MatchError(testErr))
+		})
+
+		It("errors when receiving too many informational responses", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
+			for i := 0; i <= max1xxResponses; i++ {
+				rw.WriteHeader(http.StatusEarlyHints)
+			}
+			rw.WriteHeader(http.StatusOK)
+			rw.Flush()
+
+			sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+			sess.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(rspBuf.Read).AnyTimes()
+			str.EXPECT().CancelWrite(quic.ErrorCode(errorExcessiveLoad))
+			_, err := client.RoundTrip(request)
+			Expect(err).To(MatchError("too many 1xx informational responses"))
+		})
+
+		It("populates the response trailers", func() {
+			rspBuf := &bytes.Buffer{}
+			rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
+			rw.Header().Set("Trailer", "Grpc-Status")
+			rw.Write([]byte("foobar"))
+			rw.Header().Set("Grpc-Status", "0")
+			rw.writeTrailers()
+			rw.Flush()
+
+			gomock.InOrder(
+				sess.EXPECT().HandshakeComplete().Return(handshakeCtx),
+				sess.EXPECT().OpenStreamSync(context.Background()).Return(str, nil),
+				sess.EXPECT().ConnectionState().Return(quic.ConnectionState{}),
+			)
+			str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
+			str.EXPECT().Close()
+			str.EXPECT().Read(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+				if rspBuf.Len() == 0 {
+					return 0, io.EOF
+				}
+				return rspBuf.Read(p)
+			}).AnyTimes()
+			rsp, err := client.RoundTrip(request)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rsp.Header).ToNot(HaveKey("Trailer"))
+			Expect(rsp.Trailer).To(Equal(http.Header{"Grpc-Status": nil}))
+			data, err := ioutil.ReadAll(rsp.Body)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("foobar")))
+			Expect(rsp.Trailer).To(Equal(http.Header{"Grpc-Status": []string{"0"}}))
+		})
+
+		Context("extended CONNECT", func() {
+			BeforeEach(func() {
+				var err error
+				request, err = http.NewRequest(http.MethodConnect, "https://quic.clemente.io:1337/chat?id=42", nil)
+				Expect(err).ToNot(HaveOccurred())
+				request.Proto = "webtransport"
+			})
+
+			It("waits for the SETTINGS frame before sending the request", func() {
+				sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				sess.EXPECT().Context().Return(context.Background()).AnyTimes()
+				errChan := make(chan error, 1)
+				go func() {
+					_, err := client.RoundTrip(request)
+					errChan <- err
+				}()
+				Consistently(errChan).ShouldNot(Receive())
+
+				buf := &bytes.Buffer{}
+				sess.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
+				str.EXPECT().Close()
+				str.EXPECT().Read(gomock.Any()).Return(0, errors.New("test done"))
+				str.EXPECT().CancelWrite(gomock.Any())
+				Expect(client.handleSettings(&settingsFrame{ExtendedConnect: true})).To(Succeed())
+				Eventually(errChan).Should(Receive(MatchError("test done")))
+				hfs := decodeHeader(buf)
+				Expect(hfs).To(HaveKeyWithValue(":method", http.MethodConnect))
+				Expect(hfs).To(HaveKeyWithValue(":protocol", "webtransport"))
+				Expect(hfs).To(HaveKeyWithValue(":scheme", "https"))
+				Expect(hfs).To(HaveKeyWithValue(":path", "/chat?id=42"))
+				Expect(hfs).To(HaveKeyWithValue(":authority", "quic.clemente.io:1337"))
+			})
+
+			It("errors if the server didn't enable extended CONNECT", func() {
+				sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				sess.EXPECT().Context().Return(context.Background()).AnyTimes()
+				Expect(client.handleSettings(&settingsFrame{})).To(Succeed())
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("http3: server didn't enable extended CONNECT"))
+			})
+
+			It("errors if the session is closed before the SETTINGS frame is received", func() {
+				sess.EXPECT().HandshakeComplete().Return(handshakeCtx)
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				sess.EXPECT().Context().Return(ctx).AnyTimes()
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("http3: session closed before receiving the SETTINGS frame"))
+			})
+
+			It("rejects duplicate control streams", func() {
+				client.dialOnce.Do(func() { client.handshakeErr = client.dial() })
+				Expect(client.handleSettings(&settingsFrame{})).To(Succeed())
+				Expect(client.handleSettings(&settingsFrame{})).To(MatchError("duplicate control stream"))
+			})
+		})
+
 		Context("requests containing a Body", func() {
 			var strBuf *bytes.Buffer
 
@@ -520,7 +1028,26 @@ var _ = Describe("Client", func() {
 			It("closes the connection when the first frame is not a HEADERS frame", func() {
 				buf := &bytes.Buffer{}
 				(&dataFrame{Length: 0x42}).Write(buf)
-				sess.EXPECT().CloseWithError(quic.ErrorCode(errorFrameUnexpected), gomock.Any())
+				gomock.InOrder(
+					sess.EXPECT().ResetStreams(gomock.Any(), quic.ErrorCode(errorFrameUnexpected)),
+					sess.EXPECT().CloseWithError(quic.ErrorCode(errorFrameUnexpected), gomock.Any()),
+				)
+				closed := make(chan struct{})
+				str.EXPECT().Close().Do(func() { close(closed) })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					return buf.Read(b)
+				}).AnyTimes()
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("expected first frame to be a HEADERS frame"))
+				Eventually(closed).Should(BeClosed())
+			})
+
+			It("closes the connection when it receives a frame type reserved for HTTP/2", func() {
+				buf := bytes.NewBuffer([]byte{0x2, 0x1, 0}) // PRIORITY
+				gomock.InOrder(
+					sess.EXPECT().ResetStreams(gomock.Any(), quic.ErrorCode(errorFrameUnexpected)),
+					sess.EXPECT().CloseWithError(quic.ErrorCode(errorFrameUnexpected), gomock.Any()),
+				)
 				closed := make(chan struct{})
 				str.EXPECT().Close().Do(func() { close(closed) })
 				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
@@ -544,6 +1071,26 @@ var _ = Describe("Client", func() {
 				Expect(err).To(MatchError("HEADERS frame too large: 1338 bytes (max: 1337)"))
 				Eventually(closed).Should(BeClosed())
 			})
+			It("cancels the stream when the header field section is too large", func() {
+				headerBuf := &bytes.Buffer{}
+				enc := qpack.NewEncoder(headerBuf)
+				Expect(enc.WriteField(qpack.HeaderField{Name: ":status", Value: "200"})).To(Succeed())
+				Expect(enc.WriteField(qpack.HeaderField{Name: "foo", Value: strings.Repeat("a", 1300)})).To(Succeed())
+				Expect(enc.Close()).To(Succeed())
+				Expect(headerBuf.Len()).To(BeNumerically("<", 1337))
+				buf := &bytes.Buffer{}
+				(&headersFrame{Length: uint64(headerBuf.Len())}).Write(buf)
+				buf.Write(headerBuf.Bytes())
+				str.EXPECT().CancelWrite(quic.ErrorCode(errorExcessiveLoad))
+				closed := make(chan struct{})
+				str.EXPECT().Close().Do(func() { close(closed) })
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+					return buf.Read(b)
+				}).AnyTimes()
+				_, err := client.RoundTrip(request)
+				Expect(err).To(MatchError("header field section too large: 1377 bytes (max: 1337)"))
+				Eventually(closed).Should(BeClosed())
+			})
 		})
 
 		Context("request cancellations", func() {
@@ -585,13 +1132,13 @@ var _ = Describe("Client", func() {
 					return 0, errors.New("test done")
 				})
 				_, err := client.RoundTrip(req)
-				Expect(err).To(MatchError("test done"))
+				Expect(err).To(MatchError(context.Canceled))
 				Eventually(done).Should(BeClosed())
 			})
 
 			It("cancels a request after the response arrived", func() {
 				rspBuf := &bytes.Buffer{}
-				rw := newResponseWriter(rspBuf, utils.DefaultLogger)
+				rw := newResponseWriter(rspBuf, nil, nil, utils.DefaultLogger)
 				rw.WriteHeader(418)
 				rw.Flush()
 
@@ -656,7 +1203,7 @@ var _ = Describe("Client", func() {
 				sess.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
 				sess.EXPECT().ConnectionState().Return(quic.ConnectionState{})
 				buf := &bytes.Buffer{}
-				rw := newResponseWriter(buf, utils.DefaultLogger)
+				rw := newResponseWriter(buf, nil, nil, utils.DefaultLogger)
 				rw.Header().Set("Content-Encoding", "gzip")
 				gz := gzip.NewWriter(rw)
 				gz.Write([]byte("gzipped response"))
@@ -680,7 +1227,7 @@ var _ = Describe("Client", func() {
 				sess.EXPECT().OpenStreamSync(context.Background()).Return(str, nil)
 				sess.EXPECT().ConnectionState().Return(quic.ConnectionState{})
 				buf := &bytes.Buffer{}
-				rw := newResponseWriter(buf, utils.DefaultLogger)
+				rw := newResponseWriter(buf, nil, nil, utils.DefaultLogger)
 				rw.Write([]byte("not gzipped"))
 				rw.Flush()
 				str.EXPECT().Write(gomock.Any()).AnyTimes().DoAndReturn(func(p []byte) (int, error) { return len(p), nil })
@@ -26,6 +26,10 @@ const (
 	errorMessageError         errorCode = 0x10e
 	errorConnectError         errorCode = 0x10f
 	errorVersionFallback      errorCode = 0x110
+
+	errorQPACKDecompressionFailed errorCode = 0x200
+	errorQPACKEncoderStreamError  errorCode = 0x201
+	errorQPACKDecoderStreamError  errorCode = 0x202
 )
 
 func (e errorCode) String() string {
@@ -64,6 +68,12 @@ func (e errorCode) String() string {
 		return "H3_CONNECT_ERROR"
 	case errorVersionFallback:
 		return "H3_VERSION_FALLBACK"
+	case errorQPACKDecompressionFailed:
+		return "QPACK_DECOMPRESSION_FAILED"
+	case errorQPACKEncoderStreamError:
+		return "QPACK_ENCODER_STREAM_ERROR"
+	case errorQPACKDecoderStreamError:
+		return "QPACK_DECODER_STREAM_ERROR"
 	default:
 		return fmt.Sprintf("unknown error code: %#x", uint16(e))
 	}
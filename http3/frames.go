@@ -25,9 +25,21 @@ func (br *byteReaderImpl) ReadByte() (byte, error) {
 	return b[0], nil
 }
 
+// FrameType is the frame type of a HTTP/3 frame
+type FrameType uint64
+
 type frame interface{}
 
+// unknownFrameHandlerFunc is called for frame types that are not defined by HTTP/3.
+// It is called right after parsing the frame type, before the frame length is parsed.
+// If it returns hijacked == true, parsing stops, and errHijacked is returned.
+type unknownFrameHandlerFunc func(FrameType) (hijacked bool, err error)
+
 func parseNextFrame(b io.Reader) (frame, error) {
+	return parseNextFrameWithHandler(b, nil)
+}
+
+func parseNextFrameWithHandler(b io.Reader, unknownFrameHandler unknownFrameHandlerFunc) (frame, error) {
 	br, ok := b.(byteReader)
 	if !ok {
 		br = &byteReaderImpl{b}
@@ -36,6 +48,15 @@ func parseNextFrame(b io.Reader) (frame, error) {
 	if err != nil {
 		return nil, err
 	}
+	if unknownFrameHandler != nil && !isKnownFrameType(t) {
+		hijacked, err := unknownFrameHandler(FrameType(t))
+		if err != nil {
+			return nil, err
+		}
+		if hijacked {
+			return nil, errHijacked
+		}
+	}
 	l, err := quicvarint.Read(br)
 	if err != nil {
 		return nil, err
@@ -48,12 +69,17 @@ func parseNextFrame(b io.Reader) (frame, error) {
 		return &headersFrame{Length: l}, nil
 	case 0x4:
 		return parseSettingsFrame(br, l)
+	case 0x7:
+		return parseGoAwayFrame(br, l)
+	case 0x2, 0x6, 0x8, 0x9:
+		if _, err := io.CopyN(ioutil.Discard, br, int64(l)); err != nil {
+			return nil, err
+		}
+		return &reservedFrame{Type: FrameType(t)}, nil
 	case 0x3: // CANCEL_PUSH
 		fallthrough
 	case 0x5: // PUSH_PROMISE
 		fallthrough
-	case 0x7: // GOAWAY
-		fallthrough
 	case 0xd: // MAX_PUSH_ID
 		fallthrough
 	case 0xe: // DUPLICATE_PUSH
@@ -63,7 +89,16 @@ func parseNextFrame(b io.Reader) (frame, error) {
 		if _, err := io.CopyN(ioutil.Discard, br, int64(l)); err != nil {
 			return nil, err
 		}
-		return parseNextFrame(b)
+		return parseNextFrameWithHandler(b, unknownFrameHandler)
+	}
+}
+
+func isKnownFrameType(t uint64) bool {
+	switch t {
+	case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8, 0x9, 0xd, 0xe:
+		return true
+	default:
+		return false
 	}
 }
 
@@ -85,11 +120,31 @@ func (f *headersFrame) Write(b *bytes.Buffer) {
 	quicvarint.Write(b, f.Length)
 }
 
-const settingDatagram = 0x276
+// A reservedFrame is a frame of a type that was used in HTTP/2 (PRIORITY, PING, WINDOW_UPDATE and CONTINUATION).
+// These frame types are reserved in HTTP/3, and receiving them is a connection error of type H3_FRAME_UNEXPECTED.
+// The frame payload is skipped.
+type reservedFrame struct {
+	Type FrameType
+}
+
+const (
+	settingQPACKMaxTableCapacity = 0x1
+	settingMaxFieldSectionSize   = 0x6
+	settingQPACKBlockedStreams   = 0x7
+	settingExtendedConnect       = 0x8
+	settingDatagram              = 0x276
+)
 
 type settingsFrame struct {
-	Datagram bool
-	other    map[uint64]uint64 // all settings that we don't explicitly recognize
+	Datagram        bool
+	ExtendedConnect bool
+	// The values of the QPACK settings and of SETTINGS_MAX_FIELD_SECTION_SIZE.
+	// A value of 0 means that the setting is not sent.
+	// For SETTINGS_MAX_FIELD_SECTION_SIZE, this means that there is no limit.
+	QPACKMaxTableCapacity uint64
+	QPACKBlockedStreams   uint64
+	MaxFieldSectionSize   uint64
+	other                 map[uint64]uint64 // all settings that we don't explicitly recognize
 }
 
 func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
@@ -105,7 +160,7 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 	}
 	frame := &settingsFrame{}
 	b := bytes.NewReader(buf)
-	var readDatagram bool
+	var readDatagram, readExtendedConnect, readMaxTableCapacity, readBlockedStreams, readMaxFieldSectionSize bool
 	for b.Len() > 0 {
 		id, err := quicvarint.Read(b)
 		if err != nil { // should not happen. We allocated the whole frame already.
@@ -117,6 +172,33 @@ func parseSettingsFrame(r io.Reader, l uint64) (*settingsFrame, error) {
 		}
 
 		switch id {
+		case settingQPACKMaxTableCapacity:
+			if readMaxTableCapacity {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readMaxTableCapacity = true
+			frame.QPACKMaxTableCapacity = val
+		case settingQPACKBlockedStreams:
+			if readBlockedStreams {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readBlockedStreams = true
+			frame.QPACKBlockedStreams = val
+		case settingMaxFieldSectionSize:
+			if readMaxFieldSectionSize {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readMaxFieldSectionSize = true
+			frame.MaxFieldSectionSize = val
+		case settingExtendedConnect:
+			if readExtendedConnect {
+				return nil, fmt.Errorf("duplicate setting: %d", id)
+			}
+			readExtendedConnect = true
+			if val != 0 && val != 1 {
+				return nil, fmt.Errorf("invalid value for SETTINGS_ENABLE_CONNECT_PROTOCOL: %d", val)
+			}
+			frame.ExtendedConnect = val == 1
 		case settingDatagram:
 			if readDatagram {
 				return nil, fmt.Errorf("duplicate setting: %d", id)
@@ -148,13 +230,73 @@ func (f *settingsFrame) Write(b *bytes.Buffer) {
 	if f.Datagram {
 		l += quicvarint.Len(settingDatagram) + quicvarint.Len(1)
 	}
+	if f.ExtendedConnect {
+		l += quicvarint.Len(settingExtendedConnect) + quicvarint.Len(1)
+	}
+	if f.QPACKMaxTableCapacity > 0 {
+		l += quicvarint.Len(settingQPACKMaxTableCapacity) + quicvarint.Len(f.QPACKMaxTableCapacity)
+	}
+	if f.QPACKBlockedStreams > 0 {
+		l += quicvarint.Len(settingQPACKBlockedStreams) + quicvarint.Len(f.QPACKBlockedStreams)
+	}
+	if f.MaxFieldSectionSize > 0 {
+		l += quicvarint.Len(settingMaxFieldSectionSize) + quicvarint.Len(f.MaxFieldSectionSize)
+	}
 	quicvarint.Write(b, uint64(l))
 	if f.Datagram {
 		quicvarint.Write(b, settingDatagram)
 		quicvarint.Write(b, 1)
 	}
+	if f.ExtendedConnect {
+		quicvarint.Write(b, settingExtendedConnect)
+		quicvarint.Write(b, 1)
+	}
+	if f.QPACKMaxTableCapacity > 0 {
+		quicvarint.Write(b, settingQPACKMaxTableCapacity)
+		quicvarint.Write(b, f.QPACKMaxTableCapacity)
+	}
+	if f.QPACKBlockedStreams > 0 {
+		quicvarint.Write(b, settingQPACKBlockedStreams)
+		quicvarint.Write(b, f.QPACKBlockedStreams)
+	}
+	if f.MaxFieldSectionSize > 0 {
+		quicvarint.Write(b, settingMaxFieldSectionSize)
+		quicvarint.Write(b, f.MaxFieldSectionSize)
+	}
 	for id, val := range f.other {
 		quicvarint.Write(b, id)
 		quicvarint.Write(b, val)
 	}
 }
+
+type goAwayFrame struct {
+	StreamID protocol.StreamID
+}
+
+func parseGoAwayFrame(r io.Reader, l uint64) (*goAwayFrame, error) {
+	if l > 8 {
+		return nil, fmt.Errorf("unexpected size for GOAWAY frame: %d", l)
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	b := bytes.NewReader(buf)
+	id, err := quicvarint.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	if b.Len() > 0 {
+		return nil, fmt.Errorf("unexpected size for GOAWAY frame: %d", l)
+	}
+	return &goAwayFrame{StreamID: protocol.StreamID(id)}, nil
+}
+
+func (f *goAwayFrame) Write(b *bytes.Buffer) {
+	quicvarint.Write(b, 0x7)
+	quicvarint.Write(b, uint64(quicvarint.Len(uint64(f.StreamID))))
+	quicvarint.Write(b, uint64(f.StreamID))
+}
@@ -2,6 +2,7 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 
@@ -30,6 +31,82 @@ var _ = Describe("Frames", func() {
 		Expect(frame.(*dataFrame).Length).To(Equal(uint64(0x1234)))
 	})
 
+	It("parses frame types reserved for HTTP/2", func() {
+		for _, t := range []uint64{0x2, 0x6, 0x8, 0x9} {
+			data := appendVarInt(nil, t)
+			data = appendVarInt(data, 0x10)
+			data = append(data, make([]byte, 0x10)...)
+			buf := bytes.NewBuffer(data)
+			(&dataFrame{Length: 0x1234}).Write(buf)
+			frame, err := parseNextFrameWithHandler(buf, func(FrameType) (bool, error) {
+				Fail("unknown frame handler called")
+				return false, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&reservedFrame{Type: FrameType(t)}))
+			// the payload was consumed
+			frame, err = parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&dataFrame{Length: 0x1234}))
+		}
+	})
+
+	Context("hijacking", func() {
+		It("calls the unknown frame handler for unknown frame types", func() {
+			data := appendVarInt(nil, 0x41)
+			data = appendVarInt(data, 0x1337)
+			r := bytes.NewReader(data)
+			var frameType FrameType
+			_, err := parseNextFrameWithHandler(r, func(ft FrameType) (bool, error) {
+				frameType = ft
+				return true, nil
+			})
+			Expect(err).To(Equal(errHijacked))
+			Expect(frameType).To(BeEquivalentTo(0x41))
+			// the frame length wasn't consumed
+			val, err := quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(val).To(BeEquivalentTo(0x1337))
+		})
+
+		It("doesn't call the unknown frame handler for HTTP/3 frames", func() {
+			buf := &bytes.Buffer{}
+			(&dataFrame{Length: 0x1234}).Write(buf)
+			frame, err := parseNextFrameWithHandler(buf, func(FrameType) (bool, error) {
+				Fail("unknown frame handler called")
+				return false, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+		})
+
+		It("skips unknown frames if the unknown frame handler doesn't hijack the stream", func() {
+			data := appendVarInt(nil, 0xdeadbeef)
+			data = appendVarInt(data, 0x42)
+			data = append(data, make([]byte, 0x42)...)
+			buf := bytes.NewBuffer(data)
+			(&dataFrame{Length: 0x1234}).Write(buf)
+			var called bool
+			frame, err := parseNextFrameWithHandler(buf, func(ft FrameType) (bool, error) {
+				Expect(ft).To(BeEquivalentTo(0xdeadbeef))
+				called = true
+				return false, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(called).To(BeTrue())
+			Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+			Expect(frame.(*dataFrame).Length).To(Equal(uint64(0x1234)))
+		})
+
+		It("returns errors from the unknown frame handler", func() {
+			data := appendVarInt(nil, 0x41)
+			_, err := parseNextFrameWithHandler(bytes.NewReader(data), func(FrameType) (bool, error) {
+				return false, errors.New("test error")
+			})
+			Expect(err).To(MatchError("test error"))
+		})
+	})
+
 	Context("DATA frames", func() {
 		It("parses", func() {
 			data := appendVarInt(nil, 0) // type byte
@@ -103,7 +180,7 @@ var _ = Describe("Frames", func() {
 
 		It("writes", func() {
 			sf := &settingsFrame{other: map[uint64]uint64{
-				1:  2,
+				11: 2,
 				99: 999,
 				13: 37,
 			}}
@@ -134,6 +211,62 @@ var _ = Describe("Frames", func() {
 			}
 		})
 
+		Context("QPACK settings and SETTINGS_MAX_FIELD_SECTION_SIZE", func() {
+			It("reads the values", func() {
+				settings := appendVarInt(nil, settingQPACKMaxTableCapacity)
+				settings = appendVarInt(settings, 4096)
+				settings = appendVarInt(settings, settingQPACKBlockedStreams)
+				settings = appendVarInt(settings, 100)
+				settings = appendVarInt(settings, settingMaxFieldSectionSize)
+				settings = appendVarInt(settings, 1337)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				f, err := parseNextFrame(bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				sf := f.(*settingsFrame)
+				Expect(sf.QPACKMaxTableCapacity).To(BeEquivalentTo(4096))
+				Expect(sf.QPACKBlockedStreams).To(BeEquivalentTo(100))
+				Expect(sf.MaxFieldSectionSize).To(BeEquivalentTo(1337))
+				Expect(sf.other).To(BeEmpty())
+			})
+
+			It("rejects duplicate entries", func() {
+				for _, id := range []uint64{settingQPACKMaxTableCapacity, settingQPACKBlockedStreams, settingMaxFieldSectionSize} {
+					settings := appendVarInt(nil, id)
+					settings = appendVarInt(settings, 1)
+					settings = appendVarInt(settings, id)
+					settings = appendVarInt(settings, 2)
+					data := appendVarInt(nil, 4) // type byte
+					data = appendVarInt(data, uint64(len(settings)))
+					data = append(data, settings...)
+					_, err := parseNextFrame(bytes.NewReader(data))
+					Expect(err).To(MatchError(fmt.Sprintf("duplicate setting: %d", id)))
+				}
+			})
+
+			It("writes the values", func() {
+				sf := &settingsFrame{
+					QPACKMaxTableCapacity: 4096,
+					QPACKBlockedStreams:   100,
+					MaxFieldSectionSize:   1337,
+					other:                 map[uint64]uint64{13: 37},
+				}
+				buf := &bytes.Buffer{}
+				sf.Write(buf)
+				frame, err := parseNextFrame(buf)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(sf))
+			})
+
+			It("doesn't write values that are 0", func() {
+				buf := &bytes.Buffer{}
+				(&settingsFrame{}).Write(buf)
+				Expect(buf.Bytes()).To(Equal([]byte{0x4, 0x0}))
+			})
+		})
+
 		Context("H3_DATAGRAM", func() {
 			It("reads the H3_DATAGRAM value", func() {
 				settings := appendVarInt(nil, settingDatagram)
@@ -179,5 +312,87 @@ var _ = Describe("Frames", func() {
 				Expect(frame).To(Equal(sf))
 			})
 		})
+		Context("SETTINGS_ENABLE_CONNECT_PROTOCOL", func() {
+			It("reads the SETTINGS_ENABLE_CONNECT_PROTOCOL value", func() {
+				settings := appendVarInt(nil, settingExtendedConnect)
+				settings = appendVarInt(settings, 1)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				f, err := parseNextFrame(bytes.NewReader(data))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(f).To(BeAssignableToTypeOf(&settingsFrame{}))
+				sf := f.(*settingsFrame)
+				Expect(sf.ExtendedConnect).To(BeTrue())
+			})
+
+			It("rejects duplicate SETTINGS_ENABLE_CONNECT_PROTOCOL entries", func() {
+				settings := appendVarInt(nil, settingExtendedConnect)
+				settings = appendVarInt(settings, 1)
+				settings = appendVarInt(settings, settingExtendedConnect)
+				settings = appendVarInt(settings, 1)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data))
+				Expect(err).To(MatchError(fmt.Sprintf("duplicate setting: %d", settingExtendedConnect)))
+			})
+
+			It("rejects invalid values for the SETTINGS_ENABLE_CONNECT_PROTOCOL entry", func() {
+				settings := appendVarInt(nil, settingExtendedConnect)
+				settings = appendVarInt(settings, 1337)
+				data := appendVarInt(nil, 4) // type byte
+				data = appendVarInt(data, uint64(len(settings)))
+				data = append(data, settings...)
+				_, err := parseNextFrame(bytes.NewReader(data))
+				Expect(err).To(MatchError("invalid value for SETTINGS_ENABLE_CONNECT_PROTOCOL: 1337"))
+			})
+
+			It("writes the SETTINGS_ENABLE_CONNECT_PROTOCOL setting", func() {
+				sf := &settingsFrame{ExtendedConnect: true, Datagram: true}
+				buf := &bytes.Buffer{}
+				sf.Write(buf)
+				frame, err := parseNextFrame(buf)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(frame).To(Equal(sf))
+			})
+		})
+	})
+
+	Context("GOAWAY frames", func() {
+		It("parses", func() {
+			data := appendVarInt(nil, 7) // type byte
+			data = appendVarInt(data, uint64(quicvarint.Len(100)))
+			data = appendVarInt(data, 100)
+			frame, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&goAwayFrame{}))
+			Expect(frame.(*goAwayFrame).StreamID).To(BeEquivalentTo(100))
+		})
+
+		It("writes", func() {
+			buf := &bytes.Buffer{}
+			(&goAwayFrame{StreamID: 0x1337}).Write(buf)
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 0x1337}))
+		})
+
+		It("rejects frames that contain additional data", func() {
+			data := appendVarInt(nil, 7) // type byte
+			data = appendVarInt(data, 2)
+			data = appendVarInt(data, 1)
+			data = append(data, 0)
+			_, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).To(MatchError("unexpected size for GOAWAY frame: 2"))
+		})
+
+		It("errors on EOF", func() {
+			data := appendVarInt(nil, 7) // type byte
+			data = appendVarInt(data, 4)
+			data = appendVarInt(data, 1)
+			_, err := parseNextFrame(bytes.NewReader(data))
+			Expect(err).To(MatchError(io.EOF))
+		})
 	})
 })
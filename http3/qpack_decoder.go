@@ -0,0 +1,418 @@
+package http3
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/marten-seemann/qpack"
+)
+
+// A qpackDecodingError is returned when a field section can't be decoded.
+// It is a connection error of type QPACK_DECOMPRESSION_FAILED.
+type qpackDecodingError struct {
+	err error
+}
+
+func (e *qpackDecodingError) Error() string {
+	return fmt.Sprintf("QPACK decoding error: %s", e.err)
+}
+
+var errQPACKDecoderClosed = errors.New("QPACK decoder closed")
+
+// A qpackDecoder decodes the field sections received on a connection.
+type qpackDecoder struct {
+	mutex sync.Mutex
+
+	str                io.Writer // the decoder stream, nil if the dynamic table is not used
+	table              qpackDynamicTable
+	maxTableCapacity   uint64 // our SETTINGS_QPACK_MAX_TABLE_CAPACITY
+	maxBlockedStreams  uint64 // our SETTINGS_QPACK_BLOCKED_STREAMS
+	knownReceivedCount uint64 // the Insert Count that the encoder knows we received
+	blockedStreams     uint64
+
+	inserted chan struct{} // closed (and replaced) when new entries are inserted
+	closed   chan struct{}
+	isClosed bool
+
+	handlingEncoderStream bool
+}
+
+func newQPACKDecoder(maxTableCapacity, maxBlockedStreams uint64) *qpackDecoder {
+	return &qpackDecoder{
+		maxTableCapacity:  maxTableCapacity,
+		maxBlockedStreams: maxBlockedStreams,
+		inserted:          make(chan struct{}),
+		closed:            make(chan struct{}),
+	}
+}
+
+// setStream sets the decoder stream.
+// It must be set before the encoder stream is handled.
+func (d *qpackDecoder) setStream(str io.Writer) {
+	d.mutex.Lock()
+	d.str = str
+	d.mutex.Unlock()
+}
+
+// close unblocks all streams waiting for dynamic table entries.
+func (d *qpackDecoder) close() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.isClosed {
+		d.isClosed = true
+		close(d.closed)
+	}
+}
+
+// decode decodes a field section received on stream str.
+// If the field section references dynamic table entries that were not received yet,
+// it blocks until these entries are received, or until the context is canceled.
+// The stream ID is only used if the field section references the dynamic table.
+func (d *qpackDecoder) decode(ctx context.Context, str streamIDGetter, data []byte) ([]qpack.HeaderField, error) {
+	if len(data) == 0 {
+		return []qpack.HeaderField{}, nil
+	}
+	r := bytes.NewReader(data)
+	_, encodedInsertCount, err := readPrefixInt(r, 8)
+	if err != nil {
+		return nil, &qpackDecodingError{err: err}
+	}
+	signAndDelta, deltaBase, err := readPrefixInt(r, 7)
+	if err != nil {
+		return nil, &qpackDecodingError{err: err}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	requiredInsertCount, err := d.decodeRequiredInsertCount(encodedInsertCount)
+	if err != nil {
+		return nil, &qpackDecodingError{err: err}
+	}
+	base := requiredInsertCount + deltaBase
+	if signAndDelta&0x80 > 0 {
+		if deltaBase >= requiredInsertCount {
+			return nil, &qpackDecodingError{err: errors.New("invalid Base")}
+		}
+		base = requiredInsertCount - deltaBase - 1
+	}
+	if requiredInsertCount > d.table.insertCount() {
+		if err := d.waitForInsertions(ctx, str, requiredInsertCount); err != nil {
+			return nil, err
+		}
+	}
+
+	var hfs []qpack.HeaderField
+	for r.Len() > 0 {
+		hf, err := d.parseFieldLine(r, base, requiredInsertCount)
+		if err != nil {
+			return nil, &qpackDecodingError{err: err}
+		}
+		hfs = append(hfs, hf)
+	}
+	if requiredInsertCount > 0 {
+		if err := d.writeInstruction(appendPrefixInt(nil, 7, 0x80, uint64(str.StreamID()))); err != nil {
+			return nil, err
+		}
+		if requiredInsertCount > d.knownReceivedCount {
+			d.knownReceivedCount = requiredInsertCount
+		}
+	}
+	return hfs, nil
+}
+
+// decodeRequiredInsertCount decodes the Required Insert Count.
+// See section 4.5.1.1 of draft-ietf-quic-qpack.
+// It must be called with the mutex held.
+func (d *qpackDecoder) decodeRequiredInsertCount(encoded uint64) (uint64, error) {
+	if encoded == 0 {
+		return 0, nil
+	}
+	maxEntries := d.maxTableCapacity / qpackEntryOverhead
+	fullRange := 2 * maxEntries
+	if encoded > fullRange {
+		return 0, errors.New("invalid Required Insert Count")
+	}
+	maxValue := d.table.insertCount() + maxEntries
+	maxWrapped := (maxValue / fullRange) * fullRange
+	requiredInsertCount := maxWrapped + encoded - 1
+	if requiredInsertCount > maxValue {
+		if requiredInsertCount <= fullRange {
+			return 0, errors.New("invalid Required Insert Count")
+		}
+		requiredInsertCount -= fullRange
+	}
+	if requiredInsertCount == 0 {
+		return 0, errors.New("invalid Required Insert Count")
+	}
+	return requiredInsertCount, nil
+}
+
+// waitForInsertions blocks until the dynamic table contains requiredInsertCount entries.
+// It must be called with the mutex held.
+func (d *qpackDecoder) waitForInsertions(ctx context.Context, str streamIDGetter, requiredInsertCount uint64) error {
+	if d.blockedStreams >= d.maxBlockedStreams {
+		return &qpackDecodingError{err: fmt.Errorf("too many blocked streams (max: %d)", d.maxBlockedStreams)}
+	}
+	d.blockedStreams++
+	defer func() { d.blockedStreams-- }()
+	for d.table.insertCount() < requiredInsertCount {
+		inserted := d.inserted
+		d.mutex.Unlock()
+		select {
+		case <-inserted:
+			d.mutex.Lock()
+		case <-d.closed:
+			d.mutex.Lock()
+			return errQPACKDecoderClosed
+		case <-ctx.Done():
+			d.mutex.Lock()
+			d.cancelStreamLocked(str)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (d *qpackDecoder) parseFieldLine(r *bytes.Reader, base, requiredInsertCount uint64) (qpack.HeaderField, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return qpack.HeaderField{}, err
+	}
+	r.UnreadByte()
+	maxLen := uint64(r.Len())
+	switch {
+	case b&0x80 > 0: // Indexed Field Line
+		_, index, err := readPrefixInt(r, 6)
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		if b&0x40 > 0 {
+			return d.getStatic(index)
+		}
+		return d.getRelative(index, base, requiredInsertCount)
+	case b&0xc0 == 0x40: // Literal Field Line With Name Reference
+		_, index, err := readPrefixInt(r, 4)
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		var hf qpack.HeaderField
+		if b&0x10 > 0 {
+			hf, err = d.getStatic(index)
+		} else {
+			hf, err = d.getRelative(index, base, requiredInsertCount)
+		}
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		hf.Value, err = readString(r, 7, maxLen)
+		return hf, err
+	case b&0xe0 == 0x20: // Literal Field Line With Literal Name
+		name, err := readString(r, 3, maxLen)
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		value, err := readString(r, 7, maxLen)
+		return qpack.HeaderField{Name: name, Value: value}, err
+	case b&0xf0 == 0x10: // Indexed Field Line With Post-Base Index
+		_, index, err := readPrefixInt(r, 4)
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		return d.getPostBase(index, base, requiredInsertCount)
+	default: // Literal Field Line With Post-Base Name Reference
+		_, index, err := readPrefixInt(r, 3)
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		hf, err := d.getPostBase(index, base, requiredInsertCount)
+		if err != nil {
+			return qpack.HeaderField{}, err
+		}
+		hf.Value, err = readString(r, 7, maxLen)
+		return hf, err
+	}
+}
+
+func (d *qpackDecoder) getStatic(index uint64) (qpack.HeaderField, error) {
+	if index >= uint64(len(qpackStaticTable)) {
+		return qpack.HeaderField{}, fmt.Errorf("invalid static table index: %d", index)
+	}
+	return qpackStaticTable[index], nil
+}
+
+func (d *qpackDecoder) getRelative(index, base, requiredInsertCount uint64) (qpack.HeaderField, error) {
+	if index >= base {
+		return qpack.HeaderField{}, fmt.Errorf("invalid relative index: %d", index)
+	}
+	return d.getAbsolute(base-1-index, requiredInsertCount)
+}
+
+func (d *qpackDecoder) getPostBase(index, base, requiredInsertCount uint64) (qpack.HeaderField, error) {
+	return d.getAbsolute(base+index, requiredInsertCount)
+}
+
+func (d *qpackDecoder) getAbsolute(absIndex, requiredInsertCount uint64) (qpack.HeaderField, error) {
+	if absIndex >= requiredInsertCount {
+		return qpack.HeaderField{}, fmt.Errorf("reference to dynamic table entry %d exceeds the Required Insert Count", absIndex)
+	}
+	hf, ok := d.table.get(absIndex)
+	if !ok {
+		return qpack.HeaderField{}, fmt.Errorf("reference to evicted dynamic table entry %d", absIndex)
+	}
+	return hf, nil
+}
+
+// cancelStream tells the encoder that the stream was abandoned.
+// This allows the encoder to release the references to the dynamic table held by this stream.
+func (d *qpackDecoder) cancelStream(str streamIDGetter) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.cancelStreamLocked(str)
+}
+
+func (d *qpackDecoder) cancelStreamLocked(str streamIDGetter) {
+	if d.maxTableCapacity == 0 {
+		return
+	}
+	d.writeInstruction(appendPrefixInt(nil, 6, 0x40, uint64(str.StreamID())))
+}
+
+// writeInstruction writes a decoder instruction.
+// It must be called with the mutex held.
+func (d *qpackDecoder) writeInstruction(b []byte) error {
+	if d.str == nil {
+		return errors.New("no decoder stream")
+	}
+	_, err := d.str.Write(b)
+	return err
+}
+
+// handleEncoderStream processes the encoder instructions sent by the peer.
+// It only returns when reading from the stream fails, or when the peer sent an invalid instruction.
+func (d *qpackDecoder) handleEncoderStream(str io.Reader) error {
+	d.mutex.Lock()
+	if d.handlingEncoderStream {
+		d.mutex.Unlock()
+		return errors.New("duplicate encoder stream")
+	}
+	d.handlingEncoderStream = true
+	d.mutex.Unlock()
+
+	r := bufio.NewReader(str)
+	for {
+		if err := d.handleEncoderInstruction(r); err != nil {
+			return err
+		}
+		// Acknowledge all insertions once we've processed all the data we've received so far.
+		if r.Buffered() == 0 {
+			if err := d.sendInsertCountIncrement(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *qpackDecoder) handleEncoderInstruction(r *bufio.Reader) error {
+	b, err := r.ReadByte()
+	if err != nil {
+		return err
+	}
+	r.UnreadByte()
+
+	d.mutex.Lock()
+	maxLen := d.maxTableCapacity
+	d.mutex.Unlock()
+
+	var hf qpack.HeaderField
+	switch {
+	case b&0x80 > 0: // Insert With Name Reference
+		_, index, err := readPrefixInt(r, 6)
+		if err != nil {
+			return err
+		}
+		value, err := readString(r, 7, maxLen)
+		if err != nil {
+			return err
+		}
+		if b&0x40 > 0 {
+			hf, err = d.getStatic(index)
+			if err != nil {
+				return err
+			}
+		} else {
+			d.mutex.Lock()
+			var ok bool
+			if index < d.table.insertCount() {
+				hf, ok = d.table.get(d.table.insertCount() - 1 - index)
+			}
+			d.mutex.Unlock()
+			if !ok {
+				return fmt.Errorf("invalid relative index: %d", index)
+			}
+		}
+		hf.Value = value
+	case b&0x40 > 0: // Insert With Literal Name
+		name, err := readString(r, 5, maxLen)
+		if err != nil {
+			return err
+		}
+		value, err := readString(r, 7, maxLen)
+		if err != nil {
+			return err
+		}
+		hf = qpack.HeaderField{Name: name, Value: value}
+	case b&0x20 > 0: // Set Dynamic Table Capacity
+		_, capacity, err := readPrefixInt(r, 5)
+		if err != nil {
+			return err
+		}
+		if capacity > d.maxTableCapacity {
+			return fmt.Errorf("dynamic table capacity too large: %d (max: %d)", capacity, d.maxTableCapacity)
+		}
+		d.mutex.Lock()
+		d.table.setCapacity(capacity)
+		d.mutex.Unlock()
+		return nil
+	default: // Duplicate
+		_, index, err := readPrefixInt(r, 5)
+		if err != nil {
+			return err
+		}
+		d.mutex.Lock()
+		var ok bool
+		if index < d.table.insertCount() {
+			hf, ok = d.table.get(d.table.insertCount() - 1 - index)
+		}
+		d.mutex.Unlock()
+		if !ok {
+			return fmt.Errorf("invalid relative index: %d", index)
+		}
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err := d.table.insert(hf); err != nil {
+		return err
+	}
+	close(d.inserted)
+	d.inserted = make(chan struct{})
+	return nil
+}
+
+func (d *qpackDecoder) sendInsertCountIncrement() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	increment := d.table.insertCount() - d.knownReceivedCount
+	if increment == 0 {
+		return nil
+	}
+	d.knownReceivedCount += increment
+	return d.writeInstruction(appendPrefixInt(nil, 6, 0, increment))
+}
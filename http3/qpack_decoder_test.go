@@ -0,0 +1,202 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QPACK decoder", func() {
+	var (
+		decoder    *qpackDecoder
+		decoderStr *bytes.Buffer
+		encoder    *qpackEncoder
+		encoderStr *bytes.Buffer
+	)
+
+	newStream := func(id quic.StreamID) *mockquic.MockStream {
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().Return(id).AnyTimes()
+		return str
+	}
+
+	headers := []qpack.HeaderField{
+		{Name: ":status", Value: "200"},
+		{Name: "content-type", Value: "text/plain"},
+		{Name: "server", Value: "quic-go"},
+		{Name: "x-custom", Value: "foobar"},
+	}
+
+	// handleEncoderInstructions passes the encoder instructions written so far to the decoder
+	handleEncoderInstructions := func() {
+		ExpectWithOffset(1, decoder.handleEncoderStream(bytes.NewReader(encoderStr.Bytes()))).To(MatchError(io.EOF))
+		encoderStr.Reset()
+		decoder.handlingEncoderStream = false
+	}
+
+	BeforeEach(func() {
+		decoder = newQPACKDecoder(1000, 1)
+		decoderStr = &bytes.Buffer{}
+		decoder.setStream(decoderStr)
+		encoder = newQPACKEncoder()
+		encoderStr = &bytes.Buffer{}
+		Expect(encoder.enableDynamicTable(encoderStr, 1000, 1)).To(Succeed())
+	})
+
+	It("decodes field sections that only use the static table", func() {
+		data, err := newQPACKEncoder().encode(newStream(0), headers)
+		Expect(err).ToNot(HaveOccurred())
+		hfs, err := decoder.decode(context.Background(), newStream(0), data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal(headers))
+		Expect(decoderStr.Len()).To(BeZero())
+	})
+
+	It("decodes field sections referencing the dynamic table, and acknowledges them", func() {
+		data, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		handleEncoderInstructions()
+		Expect(decoder.table.insertCount()).To(BeEquivalentTo(2)) // server and x-custom
+		// The insertions were acknowledged by an Insert Count Increment.
+		Expect(decoderStr.Bytes()).To(Equal(appendPrefixInt(nil, 6, 0, 2)))
+		decoderStr.Reset()
+		hfs, err := decoder.decode(context.Background(), newStream(4), data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal(headers))
+		Expect(decoderStr.Bytes()).To(Equal(appendPrefixInt(nil, 7, 0x80, 4)))
+	})
+
+	It("blocks until the referenced entries are received", func() {
+		data, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		done := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(done)
+			hfs, err := decoder.decode(context.Background(), newStream(4), data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hfs).To(Equal(headers))
+		}()
+		Consistently(done).ShouldNot(BeClosed())
+		handleEncoderInstructions()
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("errors when too many streams are blocked", func() {
+		data, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go decoder.decode(ctx, newStream(4), data)
+		Eventually(func() uint64 {
+			decoder.mutex.Lock()
+			defer decoder.mutex.Unlock()
+			return decoder.blockedStreams
+		}).Should(BeEquivalentTo(1))
+		_, err = decoder.decode(context.Background(), newStream(8), data)
+		Expect(err).To(BeAssignableToTypeOf(&qpackDecodingError{}))
+		Expect(err.Error()).To(ContainSubstring("too many blocked streams"))
+	})
+
+	It("sends a Stream Cancellation when a blocked stream is canceled", func() {
+		data, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		ctx, cancel := context.WithCancel(context.Background())
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := decoder.decode(ctx, newStream(4), data)
+			errChan <- err
+		}()
+		Consistently(errChan).ShouldNot(Receive())
+		cancel()
+		Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+		decoder.mutex.Lock()
+		defer decoder.mutex.Unlock()
+		Expect(decoderStr.Bytes()).To(Equal(appendPrefixInt(nil, 6, 0x40, 4)))
+		Expect(decoder.blockedStreams).To(BeZero())
+	})
+
+	It("unblocks streams when it is closed", func() {
+		data, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		errChan := make(chan error, 1)
+		go func() {
+			_, err := decoder.decode(context.Background(), newStream(4), data)
+			errChan <- err
+		}()
+		Consistently(errChan).ShouldNot(Receive())
+		decoder.close()
+		Eventually(errChan).Should(Receive(Equal(errQPACKDecoderClosed)))
+	})
+
+	It("errors on references to entries beyond the Required Insert Count", func() {
+		// Required Insert Count 1, Base 1, indexed field line with relative index 0
+		data := []byte{2, 0, 0x80}
+		encoderStr.Write(appendPrefixInt(nil, 6, 0xc0, 0))
+		encoderStr.Write(appendString(nil, 7, 0, "quic.clemente.io"))
+		encoderStr.Write(appendPrefixInt(nil, 6, 0xc0, 0))
+		encoderStr.Write(appendString(nil, 7, 0, "example.com"))
+		handleEncoderInstructions()
+		hfs, err := decoder.decode(context.Background(), newStream(4), data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal([]qpack.HeaderField{{Name: ":authority", Value: "quic.clemente.io"}}))
+		// Required Insert Count 1, Base 2, indexed field line with relative index 0
+		_, err = decoder.decode(context.Background(), newStream(8), []byte{2, 1, 0x80})
+		Expect(err).To(BeAssignableToTypeOf(&qpackDecodingError{}))
+	})
+
+	It("errors on invalid Required Insert Counts", func() {
+		_, err := decoder.decode(context.Background(), newStream(4), []byte{0xff, 0x10, 0})
+		Expect(err).To(BeAssignableToTypeOf(&qpackDecodingError{}))
+	})
+
+	Context("handling the encoder stream", func() {
+		It("handles Insert With Literal Name and Duplicate", func() {
+			encoderStr.Write(appendString(nil, 5, 0x40, "x-custom"))
+			encoderStr.Write(appendString(nil, 7, 0, "foobar"))
+			encoderStr.Write(appendPrefixInt(nil, 5, 0, 0))
+			handleEncoderInstructions()
+			Expect(decoder.table.insertCount()).To(BeEquivalentTo(2))
+			for i := uint64(0); i < 2; i++ {
+				hf, ok := decoder.table.get(i)
+				Expect(ok).To(BeTrue())
+				Expect(hf).To(Equal(qpack.HeaderField{Name: "x-custom", Value: "foobar"}))
+			}
+		})
+
+		It("handles Insert With Name Reference into the dynamic table", func() {
+			encoderStr.Write(appendString(nil, 5, 0x40, "x-custom"))
+			encoderStr.Write(appendString(nil, 7, 0, "foobar"))
+			encoderStr.Write(appendPrefixInt(nil, 6, 0x80, 0))
+			encoderStr.Write(appendString(nil, 7, 0, "raboof"))
+			handleEncoderInstructions()
+			hf, ok := decoder.table.get(1)
+			Expect(ok).To(BeTrue())
+			Expect(hf).To(Equal(qpack.HeaderField{Name: "x-custom", Value: "raboof"}))
+		})
+
+		It("errors when the capacity exceeds the maximum", func() {
+			err := decoder.handleEncoderStream(bytes.NewReader(appendPrefixInt(nil, 5, 0x20, 1001)))
+			Expect(err).To(MatchError("dynamic table capacity too large: 1001 (max: 1000)"))
+		})
+
+		It("errors on invalid relative indices", func() {
+			err := decoder.handleEncoderStream(bytes.NewReader(appendPrefixInt(nil, 5, 0, 0)))
+			Expect(err).To(MatchError("invalid relative index: 0"))
+		})
+
+		It("errors when an entry doesn't fit into the dynamic table", func() {
+			encoderStr.Write(appendPrefixInt(nil, 5, 0x20, 40))
+			encoderStr.Write(appendString(nil, 5, 0x40, "x-custom"))
+			encoderStr.Write(appendString(nil, 7, 0, "foobar"))
+			Expect(decoder.handleEncoderStream(encoderStr)).To(MatchError("entry larger than the dynamic table capacity"))
+		})
+	})
+})
@@ -0,0 +1,347 @@
+package http3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/marten-seemann/qpack"
+)
+
+// qpackMaxEncoderTableCapacity is the maximum capacity of the dynamic table that the encoder uses,
+// even if the peer allows a larger dynamic table.
+const qpackMaxEncoderTableCapacity = 16 << 10
+
+// Header fields that are never inserted into the dynamic table.
+// Their values either change with (almost) every message, or they contain sensitive information.
+var qpackNeverIndex = map[string]struct{}{
+	":path":          {},
+	"content-length": {},
+	"date":           {},
+	"authorization":  {},
+	"cookie":         {},
+	"set-cookie":     {},
+}
+
+type streamIDGetter interface {
+	StreamID() quic.StreamID
+}
+
+// A qpackSection is an encoded field section that references the dynamic table,
+// and that hasn't been acknowledged by the decoder yet.
+type qpackSection struct {
+	requiredInsertCount uint64
+	minRef              uint64 // the lowest absolute index referenced by the field section
+}
+
+type qpackFieldLineType uint8
+
+const (
+	qpackIndexedStatic qpackFieldLineType = iota
+	qpackIndexedDynamic
+	qpackLiteralStaticName
+	qpackLiteralDynamicName
+	qpackLiteralName
+)
+
+type qpackFieldLine struct {
+	typ   qpackFieldLineType
+	index uint64 // the index in the static table, or the absolute index in the dynamic table
+	hf    qpack.HeaderField
+}
+
+// A qpackEncoder encodes the field sections sent on a connection.
+// As long as the dynamic table is not enabled, only the static table is used.
+type qpackEncoder struct {
+	mutex sync.Mutex
+
+	str                io.Writer // the encoder stream, nil if the dynamic table is not used
+	table              qpackDynamicTable
+	maxEntries         uint64 // derived from the peer's SETTINGS_QPACK_MAX_TABLE_CAPACITY
+	maxBlockedStreams  uint64
+	knownReceivedCount uint64
+	sections           map[quic.StreamID][]qpackSection // unacknowledged field sections, the oldest one first
+
+	handlingDecoderStream bool
+}
+
+func newQPACKEncoder() *qpackEncoder {
+	return &qpackEncoder{sections: make(map[quic.StreamID][]qpackSection)}
+}
+
+// enableDynamicTable starts using the dynamic table.
+// The encoder instructions are written to str.
+// maxTableCapacity and maxBlockedStreams are the values of the peer's SETTINGS.
+func (e *qpackEncoder) enableDynamicTable(str io.Writer, maxTableCapacity, maxBlockedStreams uint64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.str != nil {
+		return errors.New("dynamic table already enabled")
+	}
+	capacity := maxTableCapacity
+	if capacity > qpackMaxEncoderTableCapacity {
+		capacity = qpackMaxEncoderTableCapacity
+	}
+	if _, err := str.Write(appendPrefixInt(nil, 5, 0x20, capacity)); err != nil {
+		return err
+	}
+	e.table.setCapacity(capacity)
+	e.maxEntries = maxTableCapacity / qpackEntryOverhead
+	e.maxBlockedStreams = maxBlockedStreams
+	e.str = str
+	return nil
+}
+
+// encode encodes a field section sent on stream str.
+// The stream ID is only used if the field section references the dynamic table.
+func (e *qpackEncoder) encode(str streamIDGetter, hfs []qpack.HeaderField) ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	lines := make([]qpackFieldLine, 0, len(hfs))
+	if e.str == nil || e.maxEntries == 0 {
+		for _, hf := range hfs {
+			lines = append(lines, e.encodeStatic(hf))
+		}
+		return e.appendFieldSection(nil, 0, 0, lines), nil
+	}
+
+	id := str.StreamID()
+	// Referencing entries that the decoder might not have received yet can block the stream.
+	blockingAllowed := e.isBlocking(id) || e.numBlockedStreams() < e.maxBlockedStreams
+	usable := func(absIndex uint64) bool { return blockingAllowed || absIndex < e.knownReceivedCount }
+	var (
+		instructions        []byte
+		requiredInsertCount uint64
+		minRef              uint64 = math.MaxUint64
+	)
+	addRef := func(absIndex uint64) {
+		if absIndex < minRef {
+			minRef = absIndex
+		}
+		if absIndex >= requiredInsertCount {
+			requiredInsertCount = absIndex + 1
+		}
+	}
+	for _, hf := range hfs {
+		if index, ok := qpackStaticFieldIndex[hf]; ok {
+			lines = append(lines, qpackFieldLine{typ: qpackIndexedStatic, index: index})
+			continue
+		}
+		absIndex, nameOnly, found := e.table.find(hf)
+		if found && !nameOnly && usable(absIndex) {
+			lines = append(lines, qpackFieldLine{typ: qpackIndexedDynamic, index: absIndex})
+			addRef(absIndex)
+			continue
+		}
+		if blockingAllowed && e.shouldIndex(hf) {
+			if instruction, ok := e.insert(hf, e.minOutstandingRef(minRef)); ok {
+				instructions = append(instructions, instruction...)
+				absIndex := e.table.insertCount() - 1
+				lines = append(lines, qpackFieldLine{typ: qpackIndexedDynamic, index: absIndex})
+				addRef(absIndex)
+				continue
+			}
+		}
+		line := e.encodeStatic(hf)
+		if line.typ == qpackLiteralName && found && usable(absIndex) {
+			line = qpackFieldLine{typ: qpackLiteralDynamicName, index: absIndex, hf: hf}
+			addRef(absIndex)
+		}
+		lines = append(lines, line)
+	}
+
+	if len(instructions) > 0 {
+		if _, err := e.str.Write(instructions); err != nil {
+			return nil, err
+		}
+	}
+	if requiredInsertCount > 0 {
+		e.sections[id] = append(e.sections[id], qpackSection{requiredInsertCount: requiredInsertCount, minRef: minRef})
+	}
+	return e.appendFieldSection(nil, requiredInsertCount, e.table.insertCount(), lines), nil
+}
+
+func (e *qpackEncoder) encodeStatic(hf qpack.HeaderField) qpackFieldLine {
+	if index, ok := qpackStaticFieldIndex[hf]; ok {
+		return qpackFieldLine{typ: qpackIndexedStatic, index: index}
+	}
+	if index, ok := qpackStaticNameIndex[hf.Name]; ok {
+		return qpackFieldLine{typ: qpackLiteralStaticName, index: index, hf: hf}
+	}
+	return qpackFieldLine{typ: qpackLiteralName, hf: hf}
+}
+
+func (e *qpackEncoder) shouldIndex(hf qpack.HeaderField) bool {
+	if _, ok := qpackNeverIndex[hf.Name]; ok {
+		return false
+	}
+	return qpackEntrySize(hf) <= e.table.capacity/2
+}
+
+// insert inserts a new entry into the dynamic table, and returns the encoder instruction.
+// It fails if that would require evicting entries with an absolute index of protectedFrom or larger.
+func (e *qpackEncoder) insert(hf qpack.HeaderField, protectedFrom uint64) ([]byte, bool) {
+	n, ok := e.table.numEvictions(qpackEntrySize(hf))
+	if !ok || e.table.dropped+uint64(n) > protectedFrom {
+		return nil, false
+	}
+	var b []byte
+	if index, ok := qpackStaticNameIndex[hf.Name]; ok {
+		// Insert With Name Reference, referencing the static table
+		b = appendPrefixInt(b, 6, 0xc0, index)
+	} else if absIndex, _, found := e.table.find(hf); found && absIndex >= e.table.dropped+uint64(n) {
+		// Insert With Name Reference, referencing the dynamic table
+		b = appendPrefixInt(b, 6, 0x80, e.table.insertCount()-1-absIndex)
+	} else {
+		// Insert With Literal Name
+		b = appendString(b, 5, 0x40, hf.Name)
+	}
+	b = appendString(b, 7, 0, hf.Value)
+	if err := e.table.insert(hf); err != nil { // can't happen, we checked that the entry fits
+		return nil, false
+	}
+	return b, true
+}
+
+func (e *qpackEncoder) appendFieldSection(b []byte, requiredInsertCount, base uint64, lines []qpackFieldLine) []byte {
+	if requiredInsertCount == 0 {
+		b = append(b, 0, 0)
+	} else {
+		b = appendPrefixInt(b, 8, 0, requiredInsertCount%(2*e.maxEntries)+1)
+		// all references are smaller than the base, so the delta is always positive
+		b = appendPrefixInt(b, 7, 0, base-requiredInsertCount)
+	}
+	for _, l := range lines {
+		switch l.typ {
+		case qpackIndexedStatic:
+			b = appendPrefixInt(b, 6, 0xc0, l.index)
+		case qpackIndexedDynamic:
+			b = appendPrefixInt(b, 6, 0x80, base-1-l.index)
+		case qpackLiteralStaticName:
+			b = appendPrefixInt(b, 4, 0x50, l.index)
+			b = appendString(b, 7, 0, l.hf.Value)
+		case qpackLiteralDynamicName:
+			b = appendPrefixInt(b, 4, 0x40, base-1-l.index)
+			b = appendString(b, 7, 0, l.hf.Value)
+		case qpackLiteralName:
+			b = appendString(b, 3, 0x20, l.hf.Name)
+			b = appendString(b, 7, 0, l.hf.Value)
+		}
+	}
+	return b
+}
+
+// isBlocking says if a field section sent on this stream might be blocked on the decoder side.
+func (e *qpackEncoder) isBlocking(id quic.StreamID) bool {
+	for _, s := range e.sections[id] {
+		if s.requiredInsertCount > e.knownReceivedCount {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *qpackEncoder) numBlockedStreams() uint64 {
+	var n uint64
+	for id := range e.sections {
+		if e.isBlocking(id) {
+			n++
+		}
+	}
+	return n
+}
+
+// minOutstandingRef returns the lowest absolute index referenced by any unacknowledged field section.
+func (e *qpackEncoder) minOutstandingRef(minRef uint64) uint64 {
+	for _, sections := range e.sections {
+		for _, s := range sections {
+			if s.minRef < minRef {
+				minRef = s.minRef
+			}
+		}
+	}
+	return minRef
+}
+
+// handleDecoderStream processes the decoder instructions sent by the peer.
+// It only returns when reading from the stream fails, or when the peer sent an invalid instruction.
+func (e *qpackEncoder) handleDecoderStream(str io.Reader) error {
+	e.mutex.Lock()
+	if e.handlingDecoderStream {
+		e.mutex.Unlock()
+		return errors.New("duplicate decoder stream")
+	}
+	e.handlingDecoderStream = true
+	e.mutex.Unlock()
+
+	r := bufio.NewReader(str)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		r.UnreadByte()
+		switch {
+		case b&0x80 > 0: // Section Acknowledgement
+			_, id, err := readPrefixInt(r, 7)
+			if err != nil {
+				return err
+			}
+			if err := e.handleSectionAcknowledgement(quic.StreamID(id)); err != nil {
+				return err
+			}
+		case b&0x40 > 0: // Stream Cancellation
+			_, id, err := readPrefixInt(r, 6)
+			if err != nil {
+				return err
+			}
+			e.mutex.Lock()
+			delete(e.sections, quic.StreamID(id))
+			e.mutex.Unlock()
+		default: // Insert Count Increment
+			_, increment, err := readPrefixInt(r, 6)
+			if err != nil {
+				return err
+			}
+			if err := e.handleInsertCountIncrement(increment); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (e *qpackEncoder) handleSectionAcknowledgement(id quic.StreamID) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	sections := e.sections[id]
+	if len(sections) == 0 {
+		return fmt.Errorf("unexpected Section Acknowledgement for stream %d", id)
+	}
+	if len(sections) == 1 {
+		delete(e.sections, id)
+	} else {
+		e.sections[id] = sections[1:]
+	}
+	if sections[0].requiredInsertCount > e.knownReceivedCount {
+		e.knownReceivedCount = sections[0].requiredInsertCount
+	}
+	return nil
+}
+
+func (e *qpackEncoder) handleInsertCountIncrement(increment uint64) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if increment == 0 || e.knownReceivedCount+increment > e.table.insertCount() {
+		return fmt.Errorf("invalid Insert Count Increment: %d", increment)
+	}
+	e.knownReceivedCount += increment
+	return nil
+}
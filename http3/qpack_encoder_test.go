@@ -0,0 +1,205 @@
+package http3
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/marten-seemann/qpack"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("QPACK encoder", func() {
+	var (
+		encoder    *qpackEncoder
+		encoderStr *bytes.Buffer
+	)
+
+	newStream := func(id quic.StreamID) *mockquic.MockStream {
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().StreamID().Return(id).AnyTimes()
+		return str
+	}
+
+	headers := []qpack.HeaderField{
+		{Name: ":method", Value: "GET"},
+		{Name: ":authority", Value: "quic.clemente.io"},
+		{Name: ":path", Value: "/foo"},
+		{Name: "user-agent", Value: "quic-go HTTP/3"},
+		{Name: "x-custom", Value: "foobar"},
+	}
+
+	BeforeEach(func() {
+		encoder = newQPACKEncoder()
+		encoderStr = &bytes.Buffer{}
+	})
+
+	It("only uses the static table if the dynamic table is not enabled", func() {
+		data, err := encoder.encode(newStream(0), headers)
+		Expect(err).ToNot(HaveOccurred())
+		hfs, err := qpack.NewDecoder(nil).DecodeFull(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal(headers))
+	})
+
+	It("sets the dynamic table capacity", func() {
+		Expect(encoder.enableDynamicTable(encoderStr, 1000, 10)).To(Succeed())
+		Expect(encoderStr.Bytes()).To(Equal(appendPrefixInt(nil, 5, 0x20, 1000)))
+		Expect(encoder.table.capacity).To(BeEquivalentTo(1000))
+	})
+
+	It("limits the dynamic table capacity", func() {
+		Expect(encoder.enableDynamicTable(encoderStr, 1<<30, 10)).To(Succeed())
+		Expect(encoder.table.capacity).To(BeEquivalentTo(qpackMaxEncoderTableCapacity))
+	})
+
+	It("compresses repeated headers using the dynamic table", func() {
+		Expect(encoder.enableDynamicTable(encoderStr, 1000, 10)).To(Succeed())
+		encoderStr.Reset()
+		data1, err := encoder.encode(newStream(0), headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoderStr.Len()).ToNot(BeZero())
+		Expect(encoder.table.insertCount()).To(BeEquivalentTo(3)) // :authority, user-agent and x-custom
+		encoderStr.Reset()
+		data2, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoderStr.Len()).To(BeZero())
+		Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+		Expect(data2).To(Equal(data1))
+		static, err := newQPACKEncoder().encode(newStream(8), headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(data2)).To(BeNumerically("<", len(static)))
+	})
+
+	It("never indexes sensitive header fields", func() {
+		Expect(encoder.enableDynamicTable(encoderStr, 1000, 10)).To(Succeed())
+		_, err := encoder.encode(newStream(0), []qpack.HeaderField{
+			{Name: "authorization", Value: "secret"},
+			{Name: "cookie", Value: "foo=bar"},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoder.table.insertCount()).To(BeZero())
+	})
+
+	It("doesn't block more streams than the peer allows", func() {
+		Expect(encoder.enableDynamicTable(encoderStr, 1000, 1)).To(Succeed())
+		_, err := encoder.encode(newStream(0), headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoder.numBlockedStreams()).To(BeEquivalentTo(1))
+		// The entries weren't acknowledged yet, so they can't be used on a second stream.
+		encoderStr.Reset()
+		data, err := encoder.encode(newStream(4), headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoderStr.Len()).To(BeZero())
+		hfs, err := qpack.NewDecoder(nil).DecodeFull(data)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hfs).To(Equal(headers))
+		// After the acknowledgement, the entries can be used without blocking.
+		Expect(encoder.handleSectionAcknowledgement(0)).To(Succeed())
+		Expect(encoder.numBlockedStreams()).To(BeZero())
+		data, err = encoder.encode(newStream(8), headers)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data[0]).ToNot(BeZero()) // the Required Insert Count
+	})
+
+	It("doesn't evict entries that are referenced by unacknowledged field sections", func() {
+		// room for exactly two entries
+		Expect(encoder.enableDynamicTable(encoderStr, 2*qpackEntrySize(qpack.HeaderField{Name: "x-custom", Value: "foobar"}), 10)).To(Succeed())
+		for i, v := range []string{"foo", "bar"} {
+			_, err := encoder.encode(newStream(quic.StreamID(4*i)), []qpack.HeaderField{{Name: "x-custom", Value: v + v}})
+			Expect(err).ToNot(HaveOccurred())
+		}
+		Expect(encoder.table.insertCount()).To(BeEquivalentTo(2))
+		_, err := encoder.encode(newStream(8), []qpack.HeaderField{{Name: "x-custom", Value: "bazbaz"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoder.table.insertCount()).To(BeEquivalentTo(2))
+		// Once the first entry is not referenced any more, it can be evicted.
+		Expect(encoder.handleSectionAcknowledgement(0)).To(Succeed())
+		_, err = encoder.encode(newStream(12), []qpack.HeaderField{{Name: "x-custom", Value: "bazbaz"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(encoder.table.insertCount()).To(BeEquivalentTo(3))
+		_, ok := encoder.table.get(0)
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("handling the decoder stream", func() {
+		BeforeEach(func() {
+			Expect(encoder.enableDynamicTable(encoderStr, 1000, 10)).To(Succeed())
+			_, err := encoder.encode(newStream(4), headers)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("handles Section Acknowledgements", func() {
+			err := encoder.handleDecoderStream(bytes.NewReader(appendPrefixInt(nil, 7, 0x80, 4)))
+			Expect(err).To(MatchError(io.EOF))
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(3))
+			Expect(encoder.sections).To(BeEmpty())
+		})
+
+		It("handles Stream Cancellations", func() {
+			err := encoder.handleDecoderStream(bytes.NewReader(appendPrefixInt(nil, 6, 0x40, 4)))
+			Expect(err).To(MatchError(io.EOF))
+			Expect(encoder.knownReceivedCount).To(BeZero())
+			Expect(encoder.sections).To(BeEmpty())
+		})
+
+		It("handles Insert Count Increments", func() {
+			err := encoder.handleDecoderStream(bytes.NewReader(appendPrefixInt(nil, 6, 0, 2)))
+			Expect(err).To(MatchError(io.EOF))
+			Expect(encoder.knownReceivedCount).To(BeEquivalentTo(2))
+		})
+
+		It("errors on Section Acknowledgements for unknown streams", func() {
+			err := encoder.handleDecoderStream(bytes.NewReader(appendPrefixInt(nil, 7, 0x80, 8)))
+			Expect(err).To(MatchError("unexpected Section Acknowledgement for stream 8"))
+		})
+
+		It("errors on Insert Count Increments beyond the number of insertions", func() {
+			err := encoder.handleDecoderStream(bytes.NewReader(appendPrefixInt(nil, 6, 0, 4)))
+			Expect(err).To(MatchError("invalid Insert Count Increment: 4"))
+		})
+
+		It("rejects a second decoder stream", func() {
+			r, w := io.Pipe()
+			defer w.Close()
+			go encoder.handleDecoderStream(r)
+			Eventually(func() bool {
+				encoder.mutex.Lock()
+				defer encoder.mutex.Unlock()
+				return encoder.handlingDecoderStream
+			}).Should(BeTrue())
+			Expect(encoder.handleDecoderStream(&bytes.Buffer{})).To(MatchError("duplicate decoder stream"))
+		})
+	})
+
+	Context("round-tripping", func() {
+		It("encodes and decodes headers using the dynamic table", func() {
+			encoderR, encoderW := io.Pipe()
+			decoderR, decoderW := io.Pipe()
+			decoder := newQPACKDecoder(1000, 10)
+			decoder.setStream(decoderW)
+			go decoder.handleEncoderStream(encoderR)
+			go encoder.handleDecoderStream(decoderR)
+			Expect(encoder.enableDynamicTable(encoderW, 1000, 10)).To(Succeed())
+
+			for i := 0; i < 5; i++ {
+				str := newStream(quic.StreamID(4 * i))
+				data, err := encoder.encode(str, headers)
+				Expect(err).ToNot(HaveOccurred())
+				hfs, err := decoder.decode(context.Background(), str, data)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(hfs).To(Equal(headers))
+			}
+			Eventually(func() uint64 {
+				encoder.mutex.Lock()
+				defer encoder.mutex.Unlock()
+				return encoder.knownReceivedCount
+			}).Should(BeEquivalentTo(3))
+			Expect(decoder.table.insertCount()).To(BeEquivalentTo(3))
+		})
+	})
+})
@@ -0,0 +1,35 @@
+package http3
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// openQPACKEncoderStream opens the encoder stream and enables the dynamic table,
+// using the limits that the peer sent in its SETTINGS frame.
+func openQPACKEncoderStream(sess quic.Session, encoder *qpackEncoder, sf *settingsFrame) error {
+	str, err := sess.OpenUniStream()
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, streamTypeQPACKEncoderStream)
+	if _, err := str.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return encoder.enableDynamicTable(str, sf.QPACKMaxTableCapacity, sf.QPACKBlockedStreams)
+}
+
+// handleQPACKStreamError closes the session after handling the peer's encoder or decoder stream failed.
+// Both streams are critical streams, so the peer is not allowed to close them.
+// Any other error is a connection error of type errCode.
+func handleQPACKStreamError(sess quic.Session, errCode errorCode, err error) {
+	if _, ok := err.(quic.StreamError); ok || err == io.EOF {
+		sess.CloseWithError(quic.ErrorCode(errorClosedCriticalStream), "")
+		return
+	}
+	sess.CloseWithError(quic.ErrorCode(errCode), err.Error())
+}
@@ -0,0 +1,181 @@
+package http3
+
+import (
+	"errors"
+	"io"
+
+	"github.com/marten-seemann/qpack"
+	"golang.org/x/net/http2/hpack"
+)
+
+// qpackEntryOverhead is the overhead of every entry in the QPACK dynamic table.
+const qpackEntryOverhead = 32
+
+func qpackEntrySize(hf qpack.HeaderField) uint64 {
+	return uint64(len(hf.Name)+len(hf.Value)) + qpackEntryOverhead
+}
+
+// The qpackDynamicTable is the QPACK dynamic table.
+// It is used by both the encoder and the decoder.
+// Entries are addressed by their absolute index: the first entry ever inserted has the absolute index 0.
+type qpackDynamicTable struct {
+	entries  []qpack.HeaderField // the oldest entry comes first
+	dropped  uint64              // the number of entries that were evicted
+	size     uint64
+	capacity uint64
+}
+
+// insertCount is the total number of insertions into the table.
+func (t *qpackDynamicTable) insertCount() uint64 {
+	return t.dropped + uint64(len(t.entries))
+}
+
+func (t *qpackDynamicTable) get(absIndex uint64) (qpack.HeaderField, bool) {
+	if absIndex < t.dropped || absIndex >= t.insertCount() {
+		return qpack.HeaderField{}, false
+	}
+	return t.entries[absIndex-t.dropped], true
+}
+
+// numEvictions returns how many entries would need to be evicted to make room for size bytes.
+// It returns false if it's not possible to make room.
+func (t *qpackDynamicTable) numEvictions(size uint64) (int, bool) {
+	if size > t.capacity {
+		return 0, false
+	}
+	var n int
+	available := t.capacity - t.size
+	for available < size {
+		available += qpackEntrySize(t.entries[n])
+		n++
+	}
+	return n, true
+}
+
+func (t *qpackDynamicTable) evict(n int) {
+	for i := 0; i < n; i++ {
+		t.size -= qpackEntrySize(t.entries[i])
+		t.entries[i] = qpack.HeaderField{}
+	}
+	t.entries = t.entries[n:]
+	t.dropped += uint64(n)
+}
+
+// insert inserts a new entry, evicting as many of the oldest entries as necessary.
+func (t *qpackDynamicTable) insert(hf qpack.HeaderField) error {
+	size := qpackEntrySize(hf)
+	n, ok := t.numEvictions(size)
+	if !ok {
+		return errors.New("entry larger than the dynamic table capacity")
+	}
+	t.evict(n)
+	t.entries = append(t.entries, hf)
+	t.size += size
+	return nil
+}
+
+// setCapacity sets the capacity, evicting as many of the oldest entries as necessary.
+func (t *qpackDynamicTable) setCapacity(capacity uint64) {
+	t.capacity = capacity
+	var n int
+	for size := t.size; size > t.capacity; n++ {
+		size -= qpackEntrySize(t.entries[n])
+	}
+	t.evict(n)
+}
+
+// find returns the absolute index of the most recent entry matching hf.
+// If no entry matches both name and value, it returns the most recent entry with a matching name.
+func (t *qpackDynamicTable) find(hf qpack.HeaderField) (absIndex uint64, nameOnly bool, found bool) {
+	for i := len(t.entries) - 1; i >= 0; i-- {
+		if t.entries[i].Name != hf.Name {
+			continue
+		}
+		if t.entries[i].Value == hf.Value {
+			return t.dropped + uint64(i), false, true
+		}
+		if !found {
+			absIndex = t.dropped + uint64(i)
+			nameOnly = true
+			found = true
+		}
+	}
+	return
+}
+
+var errQPACKIntegerOverflow = errors.New("QPACK integer overflow")
+
+// appendPrefixInt appends i, encoded as a QPACK (and HPACK) prefixed integer
+// with an n bit prefix, to b. The flags are set in the first byte.
+func appendPrefixInt(b []byte, n uint8, flags byte, i uint64) []byte {
+	max := uint64(1)<<n - 1
+	if i < max {
+		return append(b, flags|byte(i))
+	}
+	b = append(b, flags|byte(max))
+	i -= max
+	for ; i >= 0x80; i >>= 7 {
+		b = append(b, byte(0x80|(i&0x7f)))
+	}
+	return append(b, byte(i))
+}
+
+// readPrefixInt reads a prefixed integer with an n bit prefix.
+// It returns the first byte, which contains the flags preceding the integer.
+func readPrefixInt(r io.ByteReader, n uint8) (byte, uint64, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	max := uint64(1)<<n - 1
+	i := uint64(first) & max
+	if i < max {
+		return first, i, nil
+	}
+	var m uint
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+		if m > 56 {
+			return 0, 0, errQPACKIntegerOverflow
+		}
+		i += uint64(b&0x7f) << m
+		if b&0x80 == 0 {
+			return first, i, nil
+		}
+		m += 7
+	}
+}
+
+// appendString appends a string literal with an n bit prefix for the length.
+// The Huffman encoding is used if it results in a shorter encoding.
+func appendString(b []byte, n uint8, flags byte, s string) []byte {
+	if l := hpack.HuffmanEncodeLength(s); l < uint64(len(s)) {
+		b = appendPrefixInt(b, n, flags|1<<n, l)
+		return hpack.AppendHuffmanString(b, s)
+	}
+	b = appendPrefixInt(b, n, flags, uint64(len(s)))
+	return append(b, s...)
+}
+
+// readString reads a string literal with an n bit prefix for the length.
+// Strings longer than maxLen are rejected.
+func readString(r byteReader, n uint8, maxLen uint64) (string, error) {
+	first, l, err := readPrefixInt(r, n)
+	if err != nil {
+		return "", err
+	}
+	if l > maxLen {
+		return "", errors.New("QPACK string literal too long")
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	if first&(1<<n) == 0 {
+		return string(b), nil
+	}
+	return hpack.HuffmanDecodeToString(b)
+}
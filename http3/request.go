@@ -11,9 +11,21 @@ import (
 	"github.com/marten-seemann/qpack"
 )
 
+// fieldSectionSize calculates the size of a header field section,
+// as defined for SETTINGS_MAX_FIELD_SECTION_SIZE:
+// the sum of the length of name and value of every field, plus an overhead of 32 bytes per field.
+func fieldSectionSize(hfs []qpack.HeaderField) uint64 {
+	var size uint64
+	for _, hf := range hfs {
+		size += uint64(len(hf.Name) + len(hf.Value) + 32)
+	}
+	return size
+}
+
 func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
-	var path, authority, method, contentLengthStr string
+	var path, authority, method, protocol, scheme, contentLengthStr string
 	httpHeaders := http.Header{}
+	var trailer http.Header
 
 	for _, h := range headers {
 		switch h.Name {
@@ -23,8 +35,26 @@ func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 			method = h.Value
 		case ":authority":
 			authority = h.Value
+		case ":protocol":
+			protocol = h.Value
+		case ":scheme":
+			scheme = h.Value
 		case "content-length":
 			contentLengthStr = h.Value
+		case "trailer":
+			for _, key := range strings.Split(h.Value, ",") {
+				key = http.CanonicalHeaderKey(strings.TrimSpace(key))
+				switch key {
+				case "Transfer-Encoding", "Trailer", "Content-Length", "":
+					// Bogus. (copy of http1 rules)
+					// Ignore.
+				default:
+					if trailer == nil {
+						trailer = make(http.Header)
+					}
+					trailer[key] = nil
+				}
+			}
 		default:
 			if !h.IsPseudo() {
 				httpHeaders.Add(h.Name, h.Value)
@@ -38,19 +68,35 @@ func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 	}
 
 	isConnect := method == http.MethodConnect
-	if isConnect {
+	// Extended CONNECT, see https://datatracker.ietf.org/doc/html/rfc8441#section-4
+	isExtendedConnect := isConnect && protocol != ""
+	if isExtendedConnect {
+		if scheme == "" || path == "" || authority == "" {
+			return nil, errors.New("extended CONNECT: :scheme, :path and :authority must not be empty")
+		}
+	} else if isConnect {
 		if path != "" || authority == "" {
 			return nil, errors.New(":path must be empty and :authority must not be empty")
 		}
 	} else if len(path) == 0 || len(authority) == 0 || len(method) == 0 {
 		return nil, errors.New(":path, :authority and :method must not be empty")
+	} else if protocol != "" {
+		return nil, errors.New(":protocol must only be used with the CONNECT method")
 	}
 
 	var u *url.URL
 	var requestURI string
 	var err error
 
-	if isConnect {
+	if isExtendedConnect {
+		u, err = url.ParseRequestURI(path)
+		if err != nil {
+			return nil, err
+		}
+		u.Scheme = scheme
+		u.Host = authority
+		requestURI = path
+	} else if isConnect {
 		u = &url.URL{Host: authority}
 		requestURI = authority
 	} else {
@@ -69,13 +115,19 @@ func requestFromHeaders(headers []qpack.HeaderField) (*http.Request, error) {
 		}
 	}
 
+	proto := "HTTP/3"
+	if isExtendedConnect {
+		proto = protocol
+	}
+
 	return &http.Request{
 		Method:        method,
 		URL:           u,
-		Proto:         "HTTP/3",
+		Proto:         proto,
 		ProtoMajor:    3,
 		ProtoMinor:    0,
 		Header:        httpHeaders,
+		Trailer:       trailer,
 		Body:          nil,
 		ContentLength: contentLength,
 		Host:          authority,
@@ -81,6 +81,21 @@ var _ = Describe("Request", func() {
 		}))
 	})
 
+	It("parses the declared trailers", func() {
+		headers := []qpack.HeaderField{
+			{Name: ":path", Value: "/foo"},
+			{Name: ":authority", Value: "quic.clemente.io"},
+			{Name: ":method", Value: "POST"},
+			{Name: "trailer", Value: "grpc-status, grpc-message,content-length"},
+		}
+		req, err := requestFromHeaders(headers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Trailer).To(Equal(http.Header{
+			"Grpc-Status":  nil,
+			"Grpc-Message": nil,
+		}))
+	})
+
 	It("handles CONNECT method", func() {
 		headers := []qpack.HeaderField{
 			{Name: ":authority", Value: "quic.clemente.io"},
@@ -137,6 +152,47 @@ var _ = Describe("Request", func() {
 		Expect(err).To(MatchError(":path must be empty and :authority must not be empty"))
 	})
 
+	It("handles extended CONNECT requests", func() {
+		headers := []qpack.HeaderField{
+			{Name: ":protocol", Value: "webtransport"},
+			{Name: ":scheme", Value: "https"},
+			{Name: ":path", Value: "/foo?val=1337"},
+			{Name: ":authority", Value: "quic.clemente.io"},
+			{Name: ":method", Value: http.MethodConnect},
+		}
+		req, err := requestFromHeaders(headers)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(req.Method).To(Equal(http.MethodConnect))
+		Expect(req.Proto).To(Equal("webtransport"))
+		Expect(req.URL.String()).To(Equal("https://quic.clemente.io/foo?val=1337"))
+		Expect(req.URL.Query().Get("val")).To(Equal("1337"))
+		Expect(req.RequestURI).To(Equal("/foo?val=1337"))
+		Expect(req.Host).To(Equal("quic.clemente.io"))
+	})
+
+	It("errors with missing pseudo headers in extended CONNECT requests", func() {
+		headers := []qpack.HeaderField{
+			{Name: ":protocol", Value: "webtransport"},
+			{Name: ":path", Value: "/foo"},
+			{Name: ":authority", Value: "quic.clemente.io"},
+			{Name: ":method", Value: http.MethodConnect},
+		}
+		_, err := requestFromHeaders(headers)
+		Expect(err).To(MatchError("extended CONNECT: :scheme, :path and :authority must not be empty"))
+	})
+
+	It("errors when the :protocol pseudo header is used without the CONNECT method", func() {
+		headers := []qpack.HeaderField{
+			{Name: ":protocol", Value: "webtransport"},
+			{Name: ":scheme", Value: "https"},
+			{Name: ":path", Value: "/foo"},
+			{Name: ":authority", Value: "quic.clemente.io"},
+			{Name: ":method", Value: http.MethodGet},
+		}
+		_, err := requestFromHeaders(headers)
+		Expect(err).To(MatchError(":protocol must only be used with the CONNECT method"))
+	})
+
 	Context("extracting the hostname from a request", func() {
 		var url *url.URL
 
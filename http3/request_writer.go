@@ -2,10 +2,12 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -20,121 +22,182 @@ import (
 
 const bodyCopyBufferSize = 8 * 1024
 
+var errRequestHeaderListSize = errors.New("http3: request header list larger than peer's advertised limit")
+
 type requestWriter struct {
-	mutex     sync.Mutex
-	encoder   *qpack.Encoder
-	headerBuf *bytes.Buffer
+	mutex   sync.Mutex
+	encoder *qpackEncoder
+	// the SETTINGS_MAX_FIELD_SECTION_SIZE advertised by the server, 0 if there's no limit
+	peerMaxFieldSectionSize uint64
 
 	logger utils.Logger
 }
 
-func newRequestWriter(logger utils.Logger) *requestWriter {
-	headerBuf := &bytes.Buffer{}
-	encoder := qpack.NewEncoder(headerBuf)
+func newRequestWriter(encoder *qpackEncoder, logger utils.Logger) *requestWriter {
 	return &requestWriter{
-		encoder:   encoder,
-		headerBuf: headerBuf,
-		logger:    logger,
+		encoder: encoder,
+		logger:  logger,
 	}
 }
 
+// SetMaxFieldSectionSize sets the maximum size of the header field section the server is willing to accept.
+// Requests with larger headers are rejected with errRequestHeaderListSize.
+func (w *requestWriter) SetMaxFieldSectionSize(size uint64) {
+	w.mutex.Lock()
+	w.peerMaxFieldSectionSize = size
+	w.mutex.Unlock()
+}
+
 func (w *requestWriter) WriteRequest(str quic.Stream, req *http.Request, gzip bool) error {
 	buf := &bytes.Buffer{}
-	if err := w.writeHeaders(buf, req, gzip); err != nil {
+	if err := w.writeHeaders(buf, str, req, gzip); err != nil {
 		return err
 	}
 	if _, err := str.Write(buf.Bytes()); err != nil {
 		return err
 	}
-	// TODO: add support for trailers
 	if req.Body == nil {
 		str.Close()
 		return nil
 	}
 
-	// send the request body asynchronously
+	// Send the request body asynchronously.
+	// The body is read in chunks, and a chunk is only read after the previous one was written to the stream.
+	// Writing blocks if the stream is blocked by flow control, so the upload is paced by the receiver.
 	go func() {
 		defer req.Body.Close()
 		b := make([]byte, bodyCopyBufferSize)
 		for {
 			n, rerr := req.Body.Read(b)
-			if n == 0 {
-				if rerr == nil {
-					continue
-				} else if rerr == io.EOF {
-					break
+			if n > 0 {
+				buf := &bytes.Buffer{}
+				(&dataFrame{Length: uint64(n)}).Write(buf)
+				if _, err := str.Write(buf.Bytes()); err != nil {
+					w.logger.Errorf("Error writing request: %s", err)
+					return
+				}
+				if _, err := str.Write(b[:n]); err != nil {
+					w.logger.Errorf("Error writing request: %s", err)
+					return
 				}
 			}
-			buf := &bytes.Buffer{}
-			(&dataFrame{Length: uint64(n)}).Write(buf)
-			if _, err := str.Write(buf.Bytes()); err != nil {
-				w.logger.Errorf("Error writing request: %s", err)
-				return
-			}
-			if _, err := str.Write(b[:n]); err != nil {
-				w.logger.Errorf("Error writing request: %s", err)
-				return
+			if rerr == io.EOF {
+				break
 			}
 			if rerr != nil {
-				if rerr == io.EOF {
-					break
-				}
 				str.CancelWrite(quic.ErrorCode(errorRequestCanceled))
 				w.logger.Errorf("Error writing request: %s", rerr)
 				return
 			}
 		}
+		if len(req.Trailer) > 0 {
+			if err := w.writeTrailers(str, req.Trailer); err != nil {
+				w.logger.Errorf("Error writing trailers: %s", err)
+				return
+			}
+		}
 		str.Close()
 	}()
 
 	return nil
 }
 
-func (w *requestWriter) writeHeaders(wr io.Writer, req *http.Request, gzip bool) error {
+func (w *requestWriter) writeHeaders(wr io.Writer, str streamIDGetter, req *http.Request, gzip bool) error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
-	defer w.encoder.Close()
 
-	if err := w.encodeHeaders(req, gzip, "", actualContentLength(req)); err != nil {
+	trailers, err := commaSeparatedTrailers(req)
+	if err != nil {
+		return err
+	}
+	fields, err := w.encodeHeaders(req, gzip, trailers, actualContentLength(req))
+	if err != nil {
 		return err
 	}
+	return w.writeHeaderBlock(wr, str, fields)
+}
 
+// writeTrailers writes the trailers in a HEADERS frame.
+// It must be called after the request body was sent completely.
+func (w *requestWriter) writeTrailers(str quic.Stream, trailer http.Header) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var fields []qpack.HeaderField
+	for k, vv := range trailer {
+		for _, v := range vv {
+			if !httpguts.ValidHeaderFieldValue(v) {
+				return fmt.Errorf("invalid HTTP trailer value %q for trailer %q", v, k)
+			}
+			fields = append(fields, qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	return w.writeHeaderBlock(str, str, fields)
+}
+
+// writeHeaderBlock encodes the header fields and writes them in a HEADERS frame.
+// It must be called with the mutex held.
+func (w *requestWriter) writeHeaderBlock(wr io.Writer, str streamIDGetter, fields []qpack.HeaderField) error {
+	headerBlock, err := w.encoder.encode(str, fields)
+	if err != nil {
+		return err
+	}
 	buf := &bytes.Buffer{}
-	hf := headersFrame{Length: uint64(w.headerBuf.Len())}
+	hf := headersFrame{Length: uint64(len(headerBlock))}
 	hf.Write(buf)
-	if _, err := wr.Write(buf.Bytes()); err != nil {
-		return err
+	buf.Write(headerBlock)
+	_, err = wr.Write(buf.Bytes())
+	return err
+}
+
+// isExtendedConnectRequest says if the request is an extended CONNECT request (RFC 8441).
+// The protocol is set in the Proto field of the request, e.g. "webtransport".
+func isExtendedConnectRequest(req *http.Request) bool {
+	return req.Method == http.MethodConnect && req.Proto != "" && req.Proto != "HTTP/1.1"
+}
+
+// copied from net/http2/transport.go
+func commaSeparatedTrailers(req *http.Request) (string, error) {
+	keys := make([]string, 0, len(req.Trailer))
+	for k := range req.Trailer {
+		k = http.CanonicalHeaderKey(k)
+		switch k {
+		case "Transfer-Encoding", "Trailer", "Content-Length":
+			return "", fmt.Errorf("invalid Trailer key %q", k)
+		}
+		keys = append(keys, k)
 	}
-	if _, err := wr.Write(w.headerBuf.Bytes()); err != nil {
-		return err
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		return strings.Join(keys, ","), nil
 	}
-	w.headerBuf.Reset()
-	return nil
+	return "", nil
 }
 
 // copied from net/transport.go
 
-func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, trailers string, contentLength int64) error {
+func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, trailers string, contentLength int64) ([]qpack.HeaderField, error) {
 	host := req.Host
 	if host == "" {
 		host = req.URL.Host
 	}
 	host, err := httpguts.PunycodeHostPort(host)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	isExtendedConnect := isExtendedConnectRequest(req)
 	var path string
-	if req.Method != "CONNECT" {
+	if req.Method != http.MethodConnect || isExtendedConnect {
 		path = req.URL.RequestURI()
 		if !validPseudoPath(path) {
 			orig := path
 			path = strings.TrimPrefix(path, req.URL.Scheme+"://"+host)
 			if !validPseudoPath(path) {
 				if req.URL.Opaque != "" {
-					return fmt.Errorf("invalid request :path %q from URL.Opaque = %q", orig, req.URL.Opaque)
+					return nil, fmt.Errorf("invalid request :path %q from URL.Opaque = %q", orig, req.URL.Opaque)
 				} else {
-					return fmt.Errorf("invalid request :path %q", orig)
+					return nil, fmt.Errorf("invalid request :path %q", orig)
 				}
 			}
 		}
@@ -145,11 +208,11 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 	// continue to reuse the hpack encoder for future requests)
 	for k, vv := range req.Header {
 		if !httpguts.ValidHeaderFieldName(k) {
-			return fmt.Errorf("invalid HTTP header name %q", k)
+			return nil, fmt.Errorf("invalid HTTP header name %q", k)
 		}
 		for _, v := range vv {
 			if !httpguts.ValidHeaderFieldValue(v) {
-				return fmt.Errorf("invalid HTTP header value %q for header %q", v, k)
+				return nil, fmt.Errorf("invalid HTTP header value %q for header %q", v, k)
 			}
 		}
 	}
@@ -160,9 +223,12 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 		// target URI (the path-absolute production and optionally a '?' character
 		// followed by the query production (see Sections 3.3 and 3.4 of
 		// [RFC3986]).
+		if isExtendedConnect {
+			f(":protocol", req.Proto)
+		}
 		f(":authority", host)
 		f(":method", req.Method)
-		if req.Method != "CONNECT" {
+		if req.Method != http.MethodConnect || isExtendedConnect {
 			f(":path", path)
 			f(":scheme", req.URL.Scheme)
 		}
@@ -225,24 +291,24 @@ func (w *requestWriter) encodeHeaders(req *http.Request, addGzipHeader bool, tra
 		hlSize += uint64(hf.Size())
 	})
 
-	// TODO: check maximum header list size
-	// if hlSize > cc.peerMaxHeaderListSize {
-	// 	return errRequestHeaderListSize
-	// }
+	if w.peerMaxFieldSectionSize > 0 && hlSize > w.peerMaxFieldSectionSize {
+		return nil, errRequestHeaderListSize
+	}
 
 	// trace := httptrace.ContextClientTrace(req.Context())
 	// traceHeaders := traceHasWroteHeaderField(trace)
 
 	// Header list size is ok. Write the headers.
+	var fields []qpack.HeaderField
 	enumerateHeaders(func(name, value string) {
 		name = strings.ToLower(name)
-		w.encoder.WriteField(qpack.HeaderField{Name: name, Value: value})
+		fields = append(fields, qpack.HeaderField{Name: name, Value: value})
 		// if traceHeaders {
 		// 	traceWroteHeaderField(trace, name, value)
 		// }
 	})
 
-	return nil
+	return fields, nil
 }
 
 // authorityAddr returns a given authority (a host/IP, or host:port / ip:port)
@@ -269,8 +335,8 @@ func authorityAddr(scheme string, authority string) (addr string) {
 // validPseudoPath reports whether v is a valid :path pseudo-header
 // value. It must be either:
 //
-//     *) a non-empty string starting with '/'
-//     *) the string '*', for OPTIONS requests.
+//	*) a non-empty string starting with '/'
+//	*) the string '*', for OPTIONS requests.
 //
 // For now this is only used a quick check for deciding when to clean
 // up Opaque URLs before sending requests from the Transport.
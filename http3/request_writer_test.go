@@ -2,13 +2,16 @@ package http3
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/marten-seemann/qpack"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go"
 	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 
@@ -48,7 +51,7 @@ var _ = Describe("Request Writer", func() {
 	}
 
 	BeforeEach(func() {
-		rw = newRequestWriter(utils.DefaultLogger)
+		rw = newRequestWriter(newQPACKEncoder(), utils.DefaultLogger)
 		strBuf = &bytes.Buffer{}
 		str = mockquic.NewMockStream(mockCtrl)
 		str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
@@ -69,6 +72,47 @@ var _ = Describe("Request Writer", func() {
 		Expect(headerFields).ToNot(HaveKey("accept-encoding"))
 	})
 
+	It("rejects requests that exceed the peer's maximum field section size", func() {
+		rw.SetMaxFieldSectionSize(300)
+		req, err := http.NewRequest("GET", "https://quic.clemente.io/index.html", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Header.Set("foo", strings.Repeat("a", 200))
+		Expect(rw.WriteRequest(str, req, false)).To(MatchError(errRequestHeaderListSize))
+		Expect(strBuf.Len()).To(BeZero())
+		// the request writer can still be used for smaller requests
+		str.EXPECT().Close()
+		req.Header.Del("foo")
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Expect(decode(strBuf)).To(HaveKeyWithValue(":path", "/index.html"))
+	})
+
+	It("writes an extended CONNECT request", func() {
+		str.EXPECT().Close()
+		req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io/chat?id=42", nil)
+		Expect(err).ToNot(HaveOccurred())
+		req.Proto = "webtransport"
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
+		Expect(headerFields).To(HaveKeyWithValue(":method", http.MethodConnect))
+		Expect(headerFields).To(HaveKeyWithValue(":protocol", "webtransport"))
+		Expect(headerFields).To(HaveKeyWithValue(":path", "/chat?id=42"))
+		Expect(headerFields).To(HaveKeyWithValue(":scheme", "https"))
+	})
+
+	It("writes a CONNECT request", func() {
+		str.EXPECT().Close()
+		req, err := http.NewRequest(http.MethodConnect, "https://quic.clemente.io/", nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).To(HaveKeyWithValue(":authority", "quic.clemente.io"))
+		Expect(headerFields).To(HaveKeyWithValue(":method", http.MethodConnect))
+		Expect(headerFields).ToNot(HaveKey(":protocol"))
+		Expect(headerFields).ToNot(HaveKey(":path"))
+		Expect(headerFields).ToNot(HaveKey(":scheme"))
+	})
+
 	It("writes a POST request", func() {
 		closed := make(chan struct{})
 		str.EXPECT().Close().Do(func() { close(closed) })
@@ -108,6 +152,90 @@ var _ = Describe("Request Writer", func() {
 		Expect(frame.(*dataFrame).Length).To(BeEquivalentTo(6))
 	})
 
+	It("streams the request body, only reading more data once the stream accepts it", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		unblock := make(chan struct{})
+		buf := &bytes.Buffer{}
+		var writes int
+		str.EXPECT().Write(gomock.Any()).DoAndReturn(func(p []byte) (int, error) {
+			// block the first write of the body, as if the stream was blocked by flow control
+			if writes == 1 {
+				<-unblock
+			}
+			writes++
+			return buf.Write(p)
+		}).AnyTimes()
+		closed := make(chan struct{})
+		str.EXPECT().Close().Do(func() { close(closed) })
+		r, w := io.Pipe()
+		req, err := http.NewRequest("POST", "https://quic.clemente.io/upload.html", r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+
+		data := bytes.Repeat([]byte("a"), 3*bodyCopyBufferSize)
+		written := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			n, _ := w.Write(data)
+			written <- n
+			w.Close()
+		}()
+		// the first chunk was read, but the writer is blocked
+		Consistently(written).ShouldNot(Receive())
+		close(unblock)
+		Eventually(written).Should(Receive(Equal(len(data))))
+		Eventually(closed).Should(BeClosed())
+
+		decode(buf)
+		var body []byte
+		for buf.Len() > 0 {
+			frame, err := parseNextFrame(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+			Expect(frame.(*dataFrame).Length).To(BeNumerically("<=", bodyCopyBufferSize))
+			body = append(body, buf.Next(int(frame.(*dataFrame).Length))...)
+		}
+		Expect(body).To(Equal(data))
+	})
+
+	It("doesn't send empty DATA frames when reading the body fails", func() {
+		canceled := make(chan struct{})
+		str.EXPECT().CancelWrite(quic.ErrorCode(errorRequestCanceled)).Do(func(quic.ErrorCode) { close(canceled) })
+		r, w := io.Pipe()
+		w.CloseWithError(errors.New("read error"))
+		req, err := http.NewRequest("POST", "https://quic.clemente.io/upload.html", r)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+		Eventually(canceled).Should(BeClosed())
+		decode(strBuf)
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("writes trailers after the request body", func() {
+		closed := make(chan struct{})
+		str.EXPECT().Close().Do(func() { close(closed) })
+		req, err := http.NewRequest("POST", "https://quic.clemente.io/upload.html", &foobarReader{})
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Grpc-Status": []string{"0"}}
+		Expect(rw.WriteRequest(str, req, false)).To(Succeed())
+
+		Eventually(closed).Should(BeClosed())
+		headerFields := decode(strBuf)
+		Expect(headerFields).To(HaveKeyWithValue("trailer", "Grpc-Status"))
+		frame, err := parseNextFrame(strBuf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(frame).To(BeAssignableToTypeOf(&dataFrame{}))
+		strBuf.Next(int(frame.(*dataFrame).Length))
+		Expect(decode(strBuf)).To(Equal(map[string]string{"grpc-status": "0"}))
+	})
+
+	It("rejects invalid trailer keys", func() {
+		req, err := http.NewRequest("POST", "https://quic.clemente.io/upload.html", &foobarReader{})
+		Expect(err).ToNot(HaveOccurred())
+		req.Trailer = http.Header{"Content-Length": []string{"6"}}
+		Expect(rw.WriteRequest(str, req, false)).To(MatchError(`invalid Trailer key "Content-Length"`))
+	})
+
 	It("sends cookies", func() {
 		str.EXPECT().Close()
 		req, err := http.NewRequest("GET", "https://quic.clemente.io/", nil)
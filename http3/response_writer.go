@@ -5,19 +5,49 @@ import (
 	"bytes"
 	"io"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/marten-seemann/qpack"
 )
 
+// DataStreamer lets the caller take over the stream. After a call to DataStream
+// the HTTP server library will not do anything else with the stream.
+//
+// It becomes the caller's responsibility to manage and close the stream.
+// This is useful for tunneling data over an (extended) CONNECT request.
+//
+// After a call to DataStream, the original Request.Body must not be used.
+type DataStreamer interface {
+	DataStream() quic.Stream
+}
+
+// Hijacker gives access to the QUIC session that a request was sent on.
+// This allows protocols built on top of HTTP/3 to open additional streams,
+// and to send datagrams, that are associated with the request.
+//
+// It is implemented by the http.ResponseWriter on the server side,
+// and by the http.Response.Body on the client side.
+type Hijacker interface {
+	Session() quic.Session
+}
+
 type responseWriter struct {
-	stream *bufio.Writer
+	str     quic.Stream // only set if the responseWriter was created for a QUIC stream
+	sess    quic.Session
+	stream  *bufio.Writer
+	encoder *qpackEncoder
 
 	header        http.Header
 	status        int // status code passed to WriteHeader
 	headerWritten bool
+	trailers      []string // trailers declared in the Trailer header
+
+	dataStreamUsed bool // set when DataStream() was called
 
 	logger utils.Logger
 }
@@ -25,14 +55,27 @@ type responseWriter struct {
 var (
 	_ http.ResponseWriter = &responseWriter{}
 	_ http.Flusher        = &responseWriter{}
+	_ io.ReaderFrom       = &responseWriter{}
+	_ DataStreamer        = &responseWriter{}
+	_ Hijacker            = &responseWriter{}
 )
 
-func newResponseWriter(stream io.Writer, logger utils.Logger) *responseWriter {
-	return &responseWriter{
-		header: http.Header{},
-		stream: bufio.NewWriter(stream),
-		logger: logger,
+func newResponseWriter(stream io.Writer, sess quic.Session, encoder *qpackEncoder, logger utils.Logger) *responseWriter {
+	w := &responseWriter{
+		header:  http.Header{},
+		stream:  bufio.NewWriter(stream),
+		sess:    sess,
+		encoder: encoder,
+		logger:  logger,
+	}
+	if str, ok := stream.(quic.Stream); ok {
+		w.str = str
 	}
+	// The dynamic table can only be used if we know the stream ID.
+	if w.encoder == nil || w.str == nil {
+		w.encoder = newQPACKEncoder()
+	}
+	return w
 }
 
 func (w *responseWriter) Header() http.Header {
@@ -43,26 +86,79 @@ func (w *responseWriter) WriteHeader(status int) {
 	if w.headerWritten {
 		return
 	}
+	// Informational responses (1xx) are sent in separate HEADERS frames, before the final response.
+	// Send them out immediately, so that the client can act on them (e.g. on 103 Early Hints).
+	if status >= 100 && status <= 199 {
+		w.logger.Infof("Sending informational response %d", status)
+		w.writeHeaders(status)
+		w.Flush()
+		return
+	}
 	w.headerWritten = true
 	w.status = status
 
-	var headers bytes.Buffer
-	enc := qpack.NewEncoder(&headers)
-	enc.WriteField(qpack.HeaderField{Name: ":status", Value: strconv.Itoa(status)})
+	for _, val := range w.header["Trailer"] {
+		for _, key := range strings.Split(val, ",") {
+			w.trailers = append(w.trailers, http.CanonicalHeaderKey(strings.TrimSpace(key)))
+		}
+	}
+
+	w.logger.Infof("Responding with %d", status)
+	w.writeHeaders(status)
+}
 
+// writeHeaders writes a HEADERS frame containing the status and the current header.
+func (w *responseWriter) writeHeaders(status int) {
+	hfs := []qpack.HeaderField{{Name: ":status", Value: strconv.Itoa(status)}}
 	for k, v := range w.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
 		for index := range v {
-			enc.WriteField(qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v[index]})
+		}
+	}
+	w.writeHeaderBlock(hfs)
+}
+
+// writeTrailers writes the trailers, if any, in a HEADERS frame.
+// Trailers are all headers that were declared in the Trailer header before WriteHeader was called,
+// as well as all headers set with the http.TrailerPrefix.
+// It must be called after the handler returned.
+func (w *responseWriter) writeTrailers() {
+	var hfs []qpack.HeaderField
+	for _, k := range w.trailers {
+		for _, v := range w.header[k] {
+			hfs = append(hfs, qpack.HeaderField{Name: strings.ToLower(k), Value: v})
+		}
+	}
+	for k, vv := range w.header {
+		if !strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(k, http.TrailerPrefix))
+		for _, v := range vv {
+			hfs = append(hfs, qpack.HeaderField{Name: name, Value: v})
 		}
 	}
+	if len(hfs) == 0 {
+		return
+	}
+	w.writeHeaderBlock(hfs)
+}
 
+func (w *responseWriter) writeHeaderBlock(hfs []qpack.HeaderField) {
+	headerBlock, err := w.encoder.encode(w.str, hfs)
+	if err != nil {
+		w.logger.Errorf("could not encode headers: %s", err.Error())
+		return
+	}
 	buf := &bytes.Buffer{}
-	(&headersFrame{Length: uint64(headers.Len())}).Write(buf)
-	w.logger.Infof("Responding with %d", status)
+	(&headersFrame{Length: uint64(len(headerBlock))}).Write(buf)
 	if _, err := w.stream.Write(buf.Bytes()); err != nil {
 		w.logger.Errorf("could not write headers frame: %s", err.Error())
 	}
-	if _, err := w.stream.Write(headers.Bytes()); err != nil {
+	if _, err := w.stream.Write(headerBlock); err != nil {
 		w.logger.Errorf("could not write header frame payload: %s", err.Error())
 	}
 }
@@ -83,12 +179,143 @@ func (w *responseWriter) Write(p []byte) (int, error) {
 	return w.stream.Write(p)
 }
 
+// bodyBufferSize is the size of the buffers used by ReadFrom.
+const bodyBufferSize = 32 << 10
+
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, bodyBufferSize)
+		return &b
+	},
+}
+
+// ReadFrom implements io.ReaderFrom, so that io.Copy (and therefore http.ServeContent and http.FileServer)
+// sends the response body using pooled buffers, instead of allocating a new buffer for every copy.
+// If the size of the body is known (e.g. when serving a file), the whole body is sent in a single DATA frame.
+// Otherwise, every chunk read from r is sent in a separate DATA frame.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.headerWritten {
+		w.WriteHeader(200)
+	}
+	if !bodyAllowedForStatus(w.status) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	bp := bodyBufferPool.Get().(*[]byte)
+	defer bodyBufferPool.Put(bp)
+	buf := *bp
+
+	size, ok := bodySize(r)
+	if !ok {
+		var written int64
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				if _, err := w.Write(buf[:n]); err != nil {
+					return written, err
+				}
+				written += int64(n)
+			}
+			if err == io.EOF {
+				return written, nil
+			}
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	if size == 0 {
+		return 0, nil
+	}
+
+	b := &bytes.Buffer{}
+	(&dataFrame{Length: uint64(size)}).Write(b)
+	if _, err := w.stream.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	var written int64
+	for written < size {
+		chunk := buf
+		if rem := size - written; rem < int64(len(chunk)) {
+			chunk = chunk[:rem]
+		}
+		n, err := r.Read(chunk)
+		if n > 0 {
+			if _, err := w.stream.Write(chunk[:n]); err != nil {
+				return written, err
+			}
+			written += int64(n)
+		}
+		if err != nil && written < size {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			// The DATA frame can't be completed any more.
+			if w.str != nil {
+				w.str.CancelWrite(quic.ErrorCode(errorInternalError))
+			}
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// bodySize returns the number of bytes that can be read from r, if it is known.
+func bodySize(r io.Reader) (int64, bool) {
+	switch r := r.(type) {
+	case *io.LimitedReader:
+		// http.ServeContent uses io.CopyN to copy a range of a file.
+		// N is only an upper bound for the size.
+		n, ok := bodySize(r.R)
+		if !ok {
+			return 0, false
+		}
+		if r.N < n {
+			n = r.N
+		}
+		if n < 0 {
+			n = 0
+		}
+		return n, true
+	case *bytes.Reader:
+		return int64(r.Len()), true
+	case *strings.Reader:
+		return int64(r.Len()), true
+	case *os.File:
+		fi, err := r.Stat()
+		if err != nil || !fi.Mode().IsRegular() {
+			return 0, false
+		}
+		offset, err := r.Seek(0, io.SeekCurrent)
+		if err != nil || offset > fi.Size() {
+			return 0, false
+		}
+		return fi.Size() - offset, true
+	default:
+		return 0, false
+	}
+}
+
 func (w *responseWriter) Flush() {
 	if err := w.stream.Flush(); err != nil {
 		w.logger.Errorf("could not flush to stream: %s", err.Error())
 	}
 }
 
+// DataStream flushes the response written so far, and hands over the stream.
+func (w *responseWriter) DataStream() quic.Stream {
+	w.dataStreamUsed = true
+	w.Flush()
+	return w.str
+}
+
+func (w *responseWriter) usedDataStream() bool {
+	return w.dataStreamUsed
+}
+
+func (w *responseWriter) Session() quic.Session {
+	return w.sess
+}
+
 // copied from http2/http2.go
 // bodyAllowedForStatus reports whether a given response status code
 // permits a body. See RFC 2616, section 4.4.
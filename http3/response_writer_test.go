@@ -2,8 +2,13 @@ package http3
 
 import (
 	"bytes"
+	"crypto/rand"
 	"io"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"testing"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/marten-seemann/qpack"
@@ -20,7 +25,7 @@ var _ = Describe("Response Writer", func() {
 
 	BeforeEach(func() {
 		strBuf = &bytes.Buffer{}
-		rw = newResponseWriter(strBuf, utils.DefaultLogger)
+		rw = newResponseWriter(strBuf, nil, nil, utils.DefaultLogger)
 	})
 
 	decodeHeader := func(str io.Reader) map[string][]string {
@@ -112,10 +117,151 @@ var _ = Describe("Response Writer", func() {
 		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
 	})
 
+	It("writes informational responses before the final response", func() {
+		rw.Header().Add("Link", "</style.css>; rel=preload; as=style")
+		rw.WriteHeader(http.StatusEarlyHints)
+		rw.Header().Add("Link", "</script.js>; rel=preload; as=script")
+		rw.WriteHeader(http.StatusEarlyHints)
+		rw.WriteHeader(http.StatusOK)
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"103"}))
+		Expect(fields).To(HaveKeyWithValue("link", []string{"</style.css>; rel=preload; as=style"}))
+		fields = decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"103"}))
+		Expect(fields).To(HaveKeyWithValue("link", []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"}))
+		fields = decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
+	It("flushes informational responses immediately", func() {
+		rw.WriteHeader(http.StatusEarlyHints)
+		Expect(strBuf.Len()).ToNot(BeZero())
+	})
+
+	It("writes trailers", func() {
+		rw.Header().Set("Trailer", "Foo, Bar")
+		rw.Write([]byte("foobar"))
+		rw.Header().Set("Foo", "1")
+		rw.Header().Set("Bar", "2")
+		rw.Header().Set(http.TrailerPrefix+"Baz", "3")
+		rw.writeTrailers()
+		fields := decodeHeader(strBuf)
+		Expect(fields).To(HaveKeyWithValue("trailer", []string{"Foo, Bar"}))
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		fields = decodeHeader(strBuf)
+		Expect(fields).To(Equal(map[string][]string{
+			"foo": {"1"},
+			"bar": {"2"},
+			"baz": {"3"},
+		}))
+	})
+
+	It("doesn't write trailers if none were set", func() {
+		rw.Write([]byte("foobar"))
+		rw.writeTrailers()
+		decodeHeader(strBuf)
+		Expect(getData(strBuf)).To(Equal([]byte("foobar")))
+		Expect(strBuf.Len()).To(BeZero())
+	})
+
 	It("doesn't allow writes if the status code doesn't allow a body", func() {
 		rw.WriteHeader(304)
 		n, err := rw.Write([]byte("foobar"))
 		Expect(n).To(BeZero())
 		Expect(err).To(MatchError(http.ErrBodyNotAllowed))
 	})
+
+	Context("sending bodies using ReadFrom", func() {
+		var (
+			data []byte
+			file *os.File
+		)
+
+		BeforeEach(func() {
+			data = make([]byte, 100*1024)
+			rand.Read(data)
+			var err error
+			file, err = ioutil.TempFile("", "quic-go-http3")
+			Expect(err).ToNot(HaveOccurred())
+			_, err = file.Write(data)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = file.Seek(0, io.SeekStart)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			file.Close()
+			os.Remove(file.Name())
+		})
+
+		It("sends files in a single DATA frame", func() {
+			n, err := io.CopyN(rw, file, int64(len(data))) // this is what http.ServeContent does
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(len(data)))
+			fields := decodeHeader(strBuf)
+			Expect(fields).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(getData(strBuf)).To(Equal(data))
+			Expect(strBuf.Len()).To(BeZero())
+		})
+
+		It("sends a range of a file", func() {
+			_, err := file.Seek(10, io.SeekStart)
+			Expect(err).ToNot(HaveOccurred())
+			n, err := io.CopyN(rw, file, 1000)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(1000))
+			decodeHeader(strBuf)
+			Expect(getData(strBuf)).To(Equal(data[10:1010]))
+			Expect(strBuf.Len()).To(BeZero())
+		})
+
+		It("sends bodies of unknown size in multiple DATA frames", func() {
+			n, err := rw.ReadFrom(struct{ io.Reader }{bytes.NewReader(data)})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(len(data)))
+			decodeHeader(strBuf)
+			var received []byte
+			var frames int
+			for strBuf.Len() > 0 {
+				received = append(received, getData(strBuf)...)
+				frames++
+			}
+			Expect(frames).To(BeNumerically(">", 1))
+			Expect(received).To(Equal(data))
+		})
+
+		It("doesn't allow writes if the status code doesn't allow a body", func() {
+			rw.WriteHeader(304)
+			n, err := rw.ReadFrom(file)
+			Expect(n).To(BeZero())
+			Expect(err).To(MatchError(http.ErrBodyNotAllowed))
+		})
+	})
 })
+
+func BenchmarkServeLargeFile(b *testing.B) {
+	const size = 10 << 20
+	f, err := ioutil.TempFile("", "quic-go-http3")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err := f.Write(make([]byte, size)); err != nil {
+		b.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodGet, "https://quic.clemente.io/file", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rw := newResponseWriter(ioutil.Discard, nil, nil, utils.DefaultLogger)
+		http.ServeContent(rw, req, "file", time.Time{}, f)
+		rw.Flush()
+	}
+}
@@ -1,6 +1,7 @@
 package http3
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -17,6 +18,7 @@ import (
 type roundTripCloser interface {
 	http.RoundTripper
 	io.Closer
+	Ping(context.Context) error
 }
 
 // RoundTripper implements the http.RoundTripper interface
@@ -46,6 +48,21 @@ type RoundTripper struct {
 	// See https://www.ietf.org/archive/id/draft-schinazi-masque-h3-datagram-02.html.
 	EnableDatagrams bool
 
+	// AdditionalSettings specifies additional HTTP/3 settings.
+	// It is invalid to specify any settings defined by the HTTP/3 draft and the datagram draft.
+	AdditionalSettings map[uint64]uint64
+
+	// When set, this callback is called for the first unknown frame parsed on a bidirectional stream opened by the server.
+	// It is called right after parsing the frame type.
+	// If it returns hijacked == true, the callback takes over the stream.
+	// Otherwise, the session is closed, since HTTP/3 doesn't allow the server to open bidirectional streams.
+	StreamHijacker func(FrameType, quic.Session, quic.Stream) (hijacked bool, err error)
+
+	// When set, this callback is called for unidirectional streams of an unknown stream type.
+	// If it returns hijacked == true, the callback takes over the stream.
+	// Otherwise, the stream is reset.
+	UniStreamHijacker func(StreamType, quic.Session, quic.ReceiveStream) (hijacked bool)
+
 	// Dial specifies an optional dial function for creating QUIC
 	// connections for requests.
 	// If Dial is nil, quic.DialAddr will be used.
@@ -53,9 +70,16 @@ type RoundTripper struct {
 
 	// MaxResponseHeaderBytes specifies a limit on how many response bytes are
 	// allowed in the server's response header.
+	// It is advertised to the server in the SETTINGS_MAX_FIELD_SECTION_SIZE setting.
 	// Zero means to use a default limit.
 	MaxResponseHeaderBytes int64
 
+	// QPACKMaxTableCapacity and QPACKBlockedStreams are advertised to the server
+	// in the SETTINGS_QPACK_MAX_TABLE_CAPACITY and SETTINGS_QPACK_BLOCKED_STREAMS settings.
+	// Zero means that the server is not allowed to use the QPACK dynamic table.
+	QPACKMaxTableCapacity uint64
+	QPACKBlockedStreams   uint64
+
 	clients map[string]roundTripCloser
 }
 
@@ -69,7 +93,8 @@ type RoundTripOpt struct {
 	SkipSchemeCheck bool
 }
 
-var _ roundTripCloser = &RoundTripper{}
+var _ http.RoundTripper = &RoundTripper{}
+var _ io.Closer = &RoundTripper{}
 
 // ErrNoCachedConn is returned when RoundTripper.OnlyCachedConn is set
 var ErrNoCachedConn = errors.New("http3: no cached connection was available")
@@ -115,6 +140,29 @@ func (r *RoundTripper) RoundTripOpt(req *http.Request, opt RoundTripOpt) (*http.
 	if err != nil {
 		return nil, err
 	}
+	rsp, err := cl.RoundTrip(req)
+	if err != errGoAway {
+		return rsp, err
+	}
+	// The server didn't process the request, since it is shutting down this connection.
+	// Retry the request on a new connection.
+	r.removeClient(hostname, cl)
+	if !canRetryRequest(req) {
+		return nil, err
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		newReq := *req
+		newReq.Body = body
+		req = &newReq
+	}
+	cl, err = r.getClient(hostname, opt.OnlyCachedConn)
+	if err != nil {
+		return nil, err
+	}
 	return cl.RoundTrip(req)
 }
 
@@ -141,9 +189,14 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTr
 			hostname,
 			r.TLSClientConfig,
 			&roundTripperOpts{
-				EnableDatagram:     r.EnableDatagrams,
-				DisableCompression: r.DisableCompression,
-				MaxHeaderBytes:     r.MaxResponseHeaderBytes,
+				EnableDatagram:        r.EnableDatagrams,
+				DisableCompression:    r.DisableCompression,
+				MaxHeaderBytes:        r.MaxResponseHeaderBytes,
+				QPACKMaxTableCapacity: r.QPACKMaxTableCapacity,
+				QPACKBlockedStreams:   r.QPACKBlockedStreams,
+				AdditionalSettings:    r.AdditionalSettings,
+				StreamHijacker:        r.StreamHijacker,
+				UniStreamHijacker:     r.UniStreamHijacker,
 			},
 			r.QuicConfig,
 			r.Dial,
@@ -156,6 +209,37 @@ func (r *RoundTripper) getClient(hostname string, onlyCached bool) (http.RoundTr
 	return client, nil
 }
 
+// removeClient removes a client that won't be used for new requests.
+// Requests that are currently in flight on this client are not affected.
+func (r *RoundTripper) removeClient(hostname string, cl http.RoundTripper) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.clients[hostname] == cl {
+		delete(r.clients, hostname)
+	}
+}
+
+// Ping checks that the cached connection to the given host is still alive.
+// It sends a PING frame and blocks until the server acknowledges it, or until ctx is canceled.
+// If the check fails, the connection is closed and removed from the pool,
+// so that the next request to this host uses a new connection.
+// If there's no cached connection to the host, ErrNoCachedConn is returned.
+func (r *RoundTripper) Ping(ctx context.Context, host string) error {
+	hostname := authorityAddr("https", host)
+	r.mutex.Lock()
+	cl, ok := r.clients[hostname]
+	r.mutex.Unlock()
+	if !ok {
+		return ErrNoCachedConn
+	}
+	if err := cl.Ping(ctx); err != nil {
+		r.removeClient(hostname, cl)
+		cl.Close()
+		return err
+	}
+	return nil
+}
+
 // Close closes the QUIC connections that this RoundTripper has used
 func (r *RoundTripper) Close() error {
 	r.mutex.Lock()
@@ -169,6 +253,12 @@ func (r *RoundTripper) Close() error {
 	return nil
 }
 
+// canRetryRequest says if a request can be sent again.
+// This is the case if the request doesn't have a body, or if the body can be obtained again.
+func canRetryRequest(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
 func closeRequestBody(req *http.Request) {
 	if req.Body != nil {
 		req.Body.Close()
@@ -17,13 +17,22 @@ import (
 )
 
 type mockClient struct {
-	closed bool
+	closed       bool
+	roundTripErr error
+	pingErr      error
 }
 
 func (m *mockClient) RoundTrip(req *http.Request) (*http.Response, error) {
+	if m.roundTripErr != nil {
+		return nil, m.roundTripErr
+	}
 	return &http.Response{Request: req}, nil
 }
 
+func (m *mockClient) Ping(context.Context) error {
+	return m.pingErr
+}
+
 func (m *mockClient) Close() error {
 	m.closed = true
 	return nil
@@ -240,6 +249,74 @@ var _ = Describe("RoundTripper", func() {
 		})
 	})
 
+	Context("handling GOAWAY", func() {
+		origDialAddr := dialAddr
+
+		BeforeEach(func() {
+			origDialAddr = dialAddr
+			dialAddr = func(string, *tls.Config, *quic.Config) (quic.EarlySession, error) {
+				return nil, errors.New("handshake error")
+			}
+		})
+
+		AfterEach(func() {
+			dialAddr = origDialAddr
+		})
+
+		It("retries requests on a new connection", func() {
+			cl := &mockClient{roundTripErr: errGoAway}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError("handshake error"))
+			Expect(rt.clients).To(HaveLen(1))
+			Expect(rt.clients["www.example.org:443"]).ToNot(Equal(cl))
+			Expect(cl.closed).To(BeFalse())
+		})
+
+		It("retries requests with a body, if the body can be obtained again", func() {
+			cl := &mockClient{roundTripErr: errGoAway}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			req, err := http.NewRequest("POST", "https://www.example.org/upload", bytes.NewReader([]byte("foobar")))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(req.GetBody).ToNot(BeNil())
+			_, err = rt.RoundTrip(req)
+			Expect(err).To(MatchError("handshake error"))
+		})
+
+		It("doesn't retry requests with a body that can't be obtained again", func() {
+			cl := &mockClient{roundTripErr: errGoAway}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			req1.Method = http.MethodPost
+			req1.Body = &mockBody{}
+			_, err := rt.RoundTrip(req1)
+			Expect(err).To(MatchError(errGoAway))
+			Expect(rt.clients).To(BeEmpty())
+		})
+	})
+
+	Context("pinging", func() {
+		It("pings a healthy connection", func() {
+			cl := &mockClient{}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			Expect(rt.Ping(context.Background(), "www.example.org")).To(Succeed())
+			Expect(rt.clients).To(HaveKeyWithValue("www.example.org:443", cl))
+			Expect(cl.closed).To(BeFalse())
+		})
+
+		It("removes a dead connection from the pool", func() {
+			testErr := errors.New("timeout")
+			cl := &mockClient{pingErr: testErr}
+			rt.clients = map[string]roundTripCloser{"www.example.org:443": cl}
+			Expect(rt.Ping(context.Background(), "www.example.org:443")).To(MatchError(testErr))
+			Expect(rt.clients).To(BeEmpty())
+			Expect(cl.closed).To(BeTrue())
+		})
+
+		It("errors if there's no cached connection", func() {
+			Expect(rt.Ping(context.Background(), "www.example.org")).To(MatchError(ErrNoCachedConn))
+		})
+	})
+
 	Context("closing", func() {
 		It("closes", func() {
 			rt.clients = make(map[string]roundTripCloser)
@@ -21,7 +21,6 @@ import (
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/quicvarint"
-	"github.com/marten-seemann/qpack"
 )
 
 func init() {
@@ -37,13 +36,38 @@ var (
 	quicListenAddr = quic.ListenAddrEarly
 )
 
+// errHijacked is returned by handleRequest if the handler or the StreamHijacker took over the stream.
+var errHijacked = errors.New("hijacked")
+
+// goAwayTimeout is the time we wait for the client to close the session after all requests completed,
+// when shutting down gracefully.
+var goAwayTimeout = time.Second
+
 const (
-	nextProtoH3Draft29      = "h3-29"
-	nextProtoH3Draft32      = "h3-32"
-	streamTypeControlStream = 0
-	streamTypePushStream    = 1
+	nextProtoH3Draft29           = "h3-29"
+	nextProtoH3Draft32           = "h3-32"
+	streamTypeControlStream      = 0
+	streamTypePushStream         = 1
+	streamTypeQPACKEncoderStream = 2
+	streamTypeQPACKDecoderStream = 3
 )
 
+// uniStreamReceiveWindow is the flow control window for receiving data on unidirectional streams.
+// HTTP/3 only uses unidirectional streams for the control stream and for the QPACK encoder and decoder streams.
+// These streams carry little data, so there's no need to grant the peer a large window.
+const uniStreamReceiveWindow = 64 << 10 // 64 KB
+
+// getLogger returns the logger configured in the quic.Config, or the default logger if none is configured.
+func getLogger(conf *quic.Config) utils.Logger {
+	if conf == nil || conf.Logger == nil {
+		return utils.DefaultLogger
+	}
+	return utils.NewExternalLogger(conf.Logger)
+}
+
+// StreamType is the stream type of a unidirectional stream.
+type StreamType uint64
+
 func versionToALPN(v protocol.VersionNumber) string {
 	if v == protocol.VersionTLS || v == protocol.VersionDraft29 {
 		return nextProtoH3Draft29
@@ -82,6 +106,16 @@ func newConnError(code errorCode, err error) requestError {
 	return requestError{err: err, connErr: code}
 }
 
+// closeWithConnError handles a connection error that occurred while handling a request.
+// All request streams are reset first, so that they fail with the error code of the connection error.
+func closeWithConnError(sess quic.Session, code errorCode, reason string) {
+	sess.ResetStreams(
+		func(id quic.StreamID) bool { return id.Type() == protocol.StreamTypeBidi },
+		quic.ErrorCode(code),
+	)
+	sess.CloseWithError(quic.ErrorCode(code), reason)
+}
+
 // Server is a HTTP/3 server.
 type Server struct {
 	*http.Server
@@ -95,10 +129,35 @@ type Server struct {
 	// See https://www.ietf.org/archive/id/draft-schinazi-masque-h3-datagram-02.html.
 	EnableDatagrams bool
 
+	// QPACKMaxTableCapacity and QPACKBlockedStreams are advertised to the client
+	// in the SETTINGS_QPACK_MAX_TABLE_CAPACITY and SETTINGS_QPACK_BLOCKED_STREAMS settings.
+	// Zero means that the client is not allowed to use the QPACK dynamic table.
+	// The maximum size of the request header is configured by Server.MaxHeaderBytes,
+	// and advertised in the SETTINGS_MAX_FIELD_SECTION_SIZE setting.
+	QPACKMaxTableCapacity uint64
+	QPACKBlockedStreams   uint64
+
+	// AdditionalSettings specifies additional HTTP/3 settings.
+	// It is invalid to specify any settings defined by the HTTP/3 draft and the datagram draft.
+	AdditionalSettings map[uint64]uint64
+
+	// When set, this callback is called for the first unknown frame parsed on a bidirectional stream.
+	// It is called right after parsing the frame type.
+	// If it returns hijacked == true, the callback takes over the stream, and the server won't use it any more.
+	// Otherwise, the frame is skipped, and the stream is processed as a request stream.
+	// If it returns an error, the stream is reset.
+	StreamHijacker func(FrameType, quic.Session, quic.Stream) (hijacked bool, err error)
+
+	// When set, this callback is called for unidirectional streams of an unknown stream type.
+	// If it returns hijacked == true, the callback takes over the stream.
+	// Otherwise, the stream is reset.
+	UniStreamHijacker func(StreamType, quic.Session, quic.ReceiveStream) (hijacked bool)
+
 	port uint32 // used atomically
 
 	mutex     sync.Mutex
 	listeners map[*quic.EarlyListener]struct{}
+	sessions  map[*serverSession]struct{}
 	closed    utils.AtomicBool
 
 	loggerOnce sync.Once
@@ -144,7 +203,7 @@ func (s *Server) serveImpl(tlsConf *tls.Config, conn net.PacketConn) error {
 		return errors.New("use of http3.Server without http.Server")
 	}
 	s.loggerOnce.Do(func() {
-		s.logger = utils.DefaultLogger.WithPrefix("server")
+		s.logger = getLogger(s.QuicConfig).WithPrefix("server")
 	})
 
 	// The tls.Config we pass to Listen needs to have the GetConfigForClient callback set.
@@ -188,6 +247,10 @@ func (s *Server) serveImpl(tlsConf *tls.Config, conn net.PacketConn) error {
 	if s.EnableDatagrams {
 		quicConf.EnableDatagrams = true
 	}
+	// If a UniStreamHijacker is set, unidirectional streams might carry application data.
+	if quicConf.MaxReceiveUniStreamFlowControlWindow == 0 && s.UniStreamHijacker == nil {
+		quicConf.MaxReceiveUniStreamFlowControlWindow = uniStreamReceiveWindow
+	}
 	if conn == nil {
 		ln, err = quicListenAddr(s.Addr, baseConf, quicConf)
 	} else {
@@ -226,9 +289,78 @@ func (s *Server) removeListener(l *quic.EarlyListener) {
 	s.mutex.Unlock()
 }
 
-func (s *Server) handleConn(sess quic.EarlySession) {
-	decoder := qpack.NewDecoder(nil)
+// A serverSession keeps track of the requests processed on a session,
+// such that the session can be shut down gracefully.
+type serverSession struct {
+	controlStr quic.SendStream
+
+	mutex        sync.Mutex
+	nextStreamID quic.StreamID // the lowest stream ID not yet accepted
+	sentGoAway   bool
+	goAwayID     quic.StreamID
+	requests     sync.WaitGroup
+
+	closed chan struct{} // closed when the session is closed
+}
+
+// acceptRequest registers a new request stream.
+// It returns false if the request was opened after sending the GOAWAY frame, and must not be processed.
+func (s *serverSession) acceptRequest(id quic.StreamID) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.sentGoAway && id >= s.goAwayID {
+		return false
+	}
+	if id >= s.nextStreamID {
+		s.nextStreamID = id + 4
+	}
+	s.requests.Add(1)
+	return true
+}
+
+// goAway sends a GOAWAY frame, using the lowest stream ID that hasn't been accepted yet.
+// Requests on streams with a higher stream ID will be rejected.
+func (s *serverSession) goAway() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.sentGoAway {
+		return nil
+	}
+	s.sentGoAway = true
+	s.goAwayID = s.nextStreamID
+	buf := &bytes.Buffer{}
+	(&goAwayFrame{StreamID: s.goAwayID}).Write(buf)
+	_, err := s.controlStr.Write(buf.Bytes())
+	return err
+}
+
+func (s *Server) addSession(sess *serverSession) {
+	s.mutex.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[*serverSession]struct{})
+	}
+	s.sessions[sess] = struct{}{}
+	closed := s.closed.Get()
+	s.mutex.Unlock()
+
+	// the server is shutting down gracefully, and not accepting any new requests
+	if closed {
+		if err := sess.goAway(); err != nil {
+			s.logger.Debugf("Sending GOAWAY failed: %s", err)
+		}
+	}
+}
+
+func (s *Server) removeSession(sess *serverSession) {
+	s.mutex.Lock()
+	delete(s.sessions, sess)
+	s.mutex.Unlock()
+	close(sess.closed)
+}
 
+func (s *Server) handleConn(sess quic.EarlySession) {
 	// send a SETTINGS frame
 	str, err := sess.OpenUniStream()
 	if err != nil {
@@ -237,10 +369,39 @@ func (s *Server) handleConn(sess quic.EarlySession) {
 	}
 	buf := &bytes.Buffer{}
 	quicvarint.Write(buf, streamTypeControlStream) // stream type
-	(&settingsFrame{Datagram: s.EnableDatagrams}).Write(buf)
+	(&settingsFrame{
+		Datagram:              s.EnableDatagrams,
+		ExtendedConnect:       true,
+		QPACKMaxTableCapacity: s.QPACKMaxTableCapacity,
+		QPACKBlockedStreams:   s.QPACKBlockedStreams,
+		MaxFieldSectionSize:   s.maxHeaderBytes(),
+		other:                 s.AdditionalSettings,
+	}).Write(buf)
 	str.Write(buf.Bytes())
 
-	go s.handleUnidirectionalStreams(sess)
+	decoder := newQPACKDecoder(s.QPACKMaxTableCapacity, s.QPACKBlockedStreams)
+	defer decoder.close()
+	if s.QPACKMaxTableCapacity > 0 {
+		decoderStr, err := sess.OpenUniStream()
+		if err != nil {
+			s.logger.Debugf("Opening the QPACK decoder stream failed.")
+			return
+		}
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, streamTypeQPACKDecoderStream)
+		decoderStr.Write(buf.Bytes())
+		decoder.setStream(decoderStr)
+	}
+	encoder := newQPACKEncoder()
+
+	serverSess := &serverSession{
+		controlStr: str,
+		closed:     make(chan struct{}),
+	}
+	s.addSession(serverSess)
+	defer s.removeSession(serverSess)
+
+	go s.handleUnidirectionalStreams(sess, encoder, decoder)
 
 	// Process all requests immediately.
 	// It's the client's responsibility to decide which requests are eligible for 0-RTT.
@@ -250,10 +411,19 @@ func (s *Server) handleConn(sess quic.EarlySession) {
 			s.logger.Debugf("Accepting stream failed: %s", err)
 			return
 		}
+		if !serverSess.acceptRequest(str.StreamID()) {
+			str.CancelRead(quic.ErrorCode(errorRequestRejected))
+			str.CancelWrite(quic.ErrorCode(errorRequestRejected))
+			continue
+		}
 		go func() {
-			rerr := s.handleRequest(sess, str, decoder, func() {
+			defer serverSess.requests.Done()
+			rerr := s.handleRequest(sess, str, encoder, decoder, func() {
 				sess.CloseWithError(quic.ErrorCode(errorFrameUnexpected), "")
 			})
+			if rerr.err == errHijacked {
+				return
+			}
 			if rerr.err != nil || rerr.streamErr != 0 || rerr.connErr != 0 {
 				s.logger.Debugf("Handling request failed: %s", err)
 				if rerr.streamErr != 0 {
@@ -264,7 +434,7 @@ func (s *Server) handleConn(sess quic.EarlySession) {
 					if rerr.err != nil {
 						reason = rerr.err.Error()
 					}
-					sess.CloseWithError(quic.ErrorCode(rerr.connErr), reason)
+					closeWithConnError(sess, rerr.connErr, reason)
 				}
 				return
 			}
@@ -273,7 +443,7 @@ func (s *Server) handleConn(sess quic.EarlySession) {
 	}
 }
 
-func (s *Server) handleUnidirectionalStreams(sess quic.EarlySession) {
+func (s *Server) handleUnidirectionalStreams(sess quic.EarlySession, encoder *qpackEncoder, decoder *qpackDecoder) {
 	for {
 		str, err := sess.AcceptUniStream(context.Background())
 		if err != nil {
@@ -287,13 +457,21 @@ func (s *Server) handleUnidirectionalStreams(sess quic.EarlySession) {
 				s.logger.Debugf("reading stream type on stream %d failed: %s", str.StreamID(), err)
 				return
 			}
-			// We're only interested in the control stream here.
 			switch streamType {
 			case streamTypeControlStream:
 			case streamTypePushStream: // only the server can push
 				sess.CloseWithError(quic.ErrorCode(errorStreamCreationError), "")
 				return
+			case streamTypeQPACKEncoderStream:
+				handleQPACKStreamError(sess, errorQPACKEncoderStreamError, decoder.handleEncoderStream(str))
+				return
+			case streamTypeQPACKDecoderStream:
+				handleQPACKStreamError(sess, errorQPACKDecoderStreamError, encoder.handleDecoderStream(str))
+				return
 			default:
+				if s.UniStreamHijacker != nil && s.UniStreamHijacker(StreamType(streamType), sess, str) {
+					return
+				}
 				str.CancelRead(quic.ErrorCode(errorStreamCreationError))
 				return
 			}
@@ -307,6 +485,13 @@ func (s *Server) handleUnidirectionalStreams(sess quic.EarlySession) {
 				sess.CloseWithError(quic.ErrorCode(errorMissingSettings), "")
 				return
 			}
+			if sf.QPACKMaxTableCapacity > 0 {
+				if err := openQPACKEncoderStream(sess, encoder, sf); err != nil {
+					s.logger.Debugf("Opening the QPACK encoder stream failed: %s", err)
+					sess.CloseWithError(quic.ErrorCode(errorInternalError), "")
+					return
+				}
+			}
 			if !sf.Datagram {
 				return
 			}
@@ -327,9 +512,16 @@ func (s *Server) maxHeaderBytes() uint64 {
 	return uint64(s.Server.MaxHeaderBytes)
 }
 
-func (s *Server) handleRequest(sess quic.Session, str quic.Stream, decoder *qpack.Decoder, onFrameError func()) requestError {
-	frame, err := parseNextFrame(str)
+func (s *Server) handleRequest(sess quic.Session, str quic.Stream, encoder *qpackEncoder, decoder *qpackDecoder, onFrameError func()) requestError {
+	var ufh unknownFrameHandlerFunc
+	if s.StreamHijacker != nil {
+		ufh = func(ft FrameType) (bool, error) { return s.StreamHijacker(ft, sess, str) }
+	}
+	frame, err := parseNextFrameWithHandler(str, ufh)
 	if err != nil {
+		if err == errHijacked {
+			return requestError{err: errHijacked}
+		}
 		return newStreamError(errorRequestIncomplete, err)
 	}
 	hf, ok := frame.(*headersFrame)
@@ -337,16 +529,30 @@ func (s *Server) handleRequest(sess quic.Session, str quic.Stream, decoder *qpac
 		return newConnError(errorFrameUnexpected, errors.New("expected first frame to be a HEADERS frame"))
 	}
 	if hf.Length > s.maxHeaderBytes() {
+		decoder.cancelStream(str)
 		return newStreamError(errorFrameError, fmt.Errorf("HEADERS frame too large: %d bytes (max: %d)", hf.Length, s.maxHeaderBytes()))
 	}
 	headerBlock := make([]byte, hf.Length)
 	if _, err := io.ReadFull(str, headerBlock); err != nil {
+		decoder.cancelStream(str)
 		return newStreamError(errorRequestIncomplete, err)
 	}
-	hfs, err := decoder.DecodeFull(headerBlock)
+	ctx := str.Context()
+	hfs, err := decoder.decode(ctx, str, headerBlock)
 	if err != nil {
-		// TODO: use the right error code
-		return newConnError(errorGeneralProtocolError, err)
+		if _, ok := err.(*qpackDecodingError); ok {
+			return newConnError(errorQPACKDecompressionFailed, err)
+		}
+		return newStreamError(errorRequestIncomplete, err)
+	}
+	if size := fieldSectionSize(hfs); size > s.maxHeaderBytes() {
+		s.logger.Debugf("Rejecting request on stream %d: header field section too large (%d bytes, max: %d)", str.StreamID(), size, s.maxHeaderBytes())
+		rw := newResponseWriter(str, sess, encoder, s.logger)
+		rw.WriteHeader(http.StatusRequestHeaderFieldsTooLarge)
+		rw.Flush()
+		// The client doesn't need to send the request body.
+		str.CancelRead(quic.ErrorCode(errorNoError))
+		return requestError{}
 	}
 	req, err := requestFromHeaders(hfs)
 	if err != nil {
@@ -355,7 +561,8 @@ func (s *Server) handleRequest(sess quic.Session, str quic.Stream, decoder *qpac
 	}
 
 	req.RemoteAddr = sess.RemoteAddr().String()
-	req.Body = newRequestBody(str, onFrameError)
+	body := newRequestBody(str, onFrameError)
+	req.Body = body
 
 	if s.logger.Debug() {
 		s.logger.Infof("%s %s%s, on stream %d", req.Method, req.Host, req.RequestURI, str.StreamID())
@@ -363,11 +570,11 @@ func (s *Server) handleRequest(sess quic.Session, str quic.Stream, decoder *qpac
 		s.logger.Infof("%s %s%s", req.Method, req.Host, req.RequestURI)
 	}
 
-	ctx := str.Context()
 	ctx = context.WithValue(ctx, ServerContextKey, s)
 	ctx = context.WithValue(ctx, http.LocalAddrContextKey, sess.LocalAddr())
 	req = req.WithContext(ctx)
-	responseWriter := newResponseWriter(str, s.logger)
+	body.setTrailer(ctx, decoder, &req.Trailer, s.maxHeaderBytes())
+	responseWriter := newResponseWriter(str, sess, encoder, s.logger)
 	defer responseWriter.Flush()
 	handler := s.Handler
 	if handler == nil {
@@ -389,10 +596,15 @@ func (s *Server) handleRequest(sess quic.Session, str quic.Stream, decoder *qpac
 		handler.ServeHTTP(responseWriter, req)
 	}()
 
+	if responseWriter.usedDataStream() {
+		return requestError{err: errHijacked}
+	}
+
 	if panicked {
 		responseWriter.WriteHeader(500)
 	} else {
 		responseWriter.WriteHeader(200)
+		responseWriter.writeTrailers()
 	}
 
 	// If the EOF was read by the handler, CancelRead() is a no-op.
@@ -418,15 +630,59 @@ func (s *Server) Close() error {
 }
 
 // CloseGracefully shuts down the server gracefully. The server sends a GOAWAY frame first, then waits for either timeout to trigger, or for all running requests to complete.
+// Requests that the client sent after the GOAWAY frame are rejected, and can be retried on a new connection.
+// Once all requests on a session completed, the client is given some time to receive the responses and close the session.
 // CloseGracefully in combination with ListenAndServe() (instead of Serve()) may race if it is called before a UDP socket is established.
 func (s *Server) CloseGracefully(timeout time.Duration) error {
-	// TODO: implement
-	return nil
+	s.closed.Set(true)
+
+	s.mutex.Lock()
+	sessions := make([]*serverSession, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mutex.Unlock()
+
+	for _, sess := range sessions {
+		if err := sess.goAway(); err != nil {
+			s.logger.Debugf("Sending GOAWAY failed: %s", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(len(sessions))
+	for _, sess := range sessions {
+		go func(sess *serverSession) {
+			defer wg.Done()
+			sess.requests.Wait()
+			// Closing the session now would discard response data that hasn't been delivered yet.
+			// Wait for the client to close the session.
+			timer := time.NewTimer(goAwayTimeout)
+			defer timer.Stop()
+			select {
+			case <-sess.closed:
+			case <-timer.C:
+			}
+		}(sess)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	}
+	return s.Close()
 }
 
 // SetQuicHeaders can be used to set the proper headers that announce that this server supports QUIC.
 // The values that are set depend on the port information from s.Server.Addr, and currently look like this (if Addr has port 443):
-//  Alt-Svc: quic=":443"; ma=2592000; v="33,32,31,30"
+//
+//	Alt-Svc: quic=":443"; ma=2592000; v="33,32,31,30"
 func (s *Server) SetQuicHeaders(hdr http.Header) error {
 	port := atomic.LoadUint32(&s.port)
 
@@ -59,7 +59,8 @@ var _ = Describe("Server", func() {
 
 	Context("handling requests", func() {
 		var (
-			qpackDecoder       *qpack.Decoder
+			qpackEncoder       *qpackEncoder
+			qpackDecoder       *qpackDecoder
 			str                *mockquic.MockStream
 			sess               *mockquic.MockEarlySession
 			exampleGetRequest  *http.Request
@@ -92,7 +93,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(buf.Write).AnyTimes()
 			closed := make(chan struct{})
 			str.EXPECT().Close().Do(func() { close(closed) })
-			rw := newRequestWriter(utils.DefaultLogger)
+			rw := newRequestWriter(newQPACKEncoder(), utils.DefaultLogger)
 			Expect(rw.WriteRequest(str, req, false)).To(Succeed())
 			Eventually(closed).Should(BeClosed())
 			return buf.Bytes()
@@ -115,7 +116,8 @@ var _ = Describe("Server", func() {
 			examplePostRequest, err = http.NewRequest("POST", "https://www.example.com", bytes.NewReader([]byte("foobar")))
 			Expect(err).ToNot(HaveOccurred())
 
-			qpackDecoder = qpack.NewDecoder(nil)
+			qpackEncoder = newQPACKEncoder()
+			qpackDecoder = newQPACKDecoder(0, 0)
 			str = mockquic.NewMockStream(mockCtrl)
 
 			sess = mockquic.NewMockEarlySession(mockCtrl)
@@ -137,7 +139,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			Expect(s.handleRequest(sess, str, qpackDecoder, nil)).To(Equal(requestError{}))
+			Expect(s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)).To(Equal(requestError{}))
 			var req *http.Request
 			Eventually(requestChan).Should(Receive(&req))
 			Expect(req.Host).To(Equal("www.example.com"))
@@ -154,7 +156,7 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(sess, str, qpackDecoder, nil)
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
@@ -171,12 +173,143 @@ var _ = Describe("Server", func() {
 			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
 			str.EXPECT().CancelRead(gomock.Any())
 
-			serr := s.handleRequest(sess, str, qpackDecoder, nil)
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			hfs := decodeHeader(responseBuf)
 			Expect(hfs).To(HaveKeyWithValue(":status", []string{"500"}))
 		})
 
+		It("lets the handler take over the stream", func() {
+			handlerDone := make(chan struct{})
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(handlerDone)
+				w.WriteHeader(http.StatusOK)
+				str := w.(DataStreamer).DataStream()
+				str.Write([]byte("foobar"))
+			})
+
+			responseBuf := &bytes.Buffer{}
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
+
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
+			Expect(serr.err).To(Equal(errHijacked))
+			Eventually(handlerDone).Should(BeClosed())
+			hfs := decodeHeader(responseBuf)
+			Expect(hfs).To(HaveKeyWithValue(":status", []string{"200"}))
+			Expect(responseBuf.Bytes()).To(Equal([]byte("foobar")))
+		})
+
+		It("enables extended CONNECT in the SETTINGS frame", func() {
+			controlBuf := &bytes.Buffer{}
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := mockquic.NewMockEarlySession(mockCtrl)
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done")).MaxTimes(1)
+			s.handleConn(sess)
+			streamType, err := quicvarint.Read(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(streamType).To(BeEquivalentTo(streamTypeControlStream))
+			frame, err := parseNextFrame(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&settingsFrame{}))
+			Expect(frame.(*settingsFrame).ExtendedConnect).To(BeTrue())
+		})
+
+		It("lets the StreamHijacker take over the stream", func() {
+			data := &bytes.Buffer{}
+			quicvarint.Write(data, 0x41)
+			quicvarint.Write(data, 0x1337)
+			setRequest(data.Bytes())
+			var sessionID uint64
+			s.StreamHijacker = func(ft FrameType, qsess quic.Session, qstr quic.Stream) (bool, error) {
+				defer GinkgoRecover()
+				Expect(ft).To(BeEquivalentTo(0x41))
+				Expect(qsess).To(Equal(sess))
+				id, err := quicvarint.Read(&byteReaderImpl{qstr})
+				Expect(err).ToNot(HaveOccurred())
+				sessionID = id
+				return true, nil
+			}
+			s.Handler = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+				Fail("handler called")
+			})
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
+			Expect(serr.err).To(Equal(errHijacked))
+			Expect(sessionID).To(BeEquivalentTo(0x1337))
+		})
+
+		It("resets the stream when the StreamHijacker returns an error", func() {
+			data := &bytes.Buffer{}
+			quicvarint.Write(data, 0x41)
+			setRequest(data.Bytes())
+			s.StreamHijacker = func(FrameType, quic.Session, quic.Stream) (bool, error) {
+				return false, errors.New("test error")
+			}
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
+			Expect(serr.err).To(MatchError("test error"))
+			Expect(serr.streamErr).To(Equal(errorRequestIncomplete))
+		})
+
+		It("gives the handler access to the QUIC session", func() {
+			var handlerSess quic.Session
+			s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerSess = w.(Hijacker).Session()
+			})
+			setRequest(encodeRequest(exampleGetRequest))
+			str.EXPECT().Context().Return(reqContext)
+			str.EXPECT().Write(gomock.Any()).AnyTimes()
+			str.EXPECT().CancelRead(gomock.Any())
+
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
+			Expect(serr.err).ToNot(HaveOccurred())
+			Expect(handlerSess).To(Equal(sess))
+		})
+
+		It("sends the additional settings in the SETTINGS frame", func() {
+			s.AdditionalSettings = map[uint64]uint64{0x1337: 42}
+			controlBuf := &bytes.Buffer{}
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := mockquic.NewMockEarlySession(mockCtrl)
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done")).MaxTimes(1)
+			s.handleConn(sess)
+			_, err := quicvarint.Read(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := parseNextFrame(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&settingsFrame{}))
+			Expect(frame.(*settingsFrame).other).To(HaveKeyWithValue(uint64(0x1337), uint64(42)))
+		})
+
+		It("sends the QPACK settings and the maximum field section size in the SETTINGS frame", func() {
+			s.Server.MaxHeaderBytes = 1337
+			s.QPACKMaxTableCapacity = 4096
+			s.QPACKBlockedStreams = 10
+			controlBuf := &bytes.Buffer{}
+			controlStr := mockquic.NewMockStream(mockCtrl)
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := mockquic.NewMockEarlySession(mockCtrl)
+			sess.EXPECT().OpenUniStream().Return(controlStr, nil)
+			sess.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
+			sess.EXPECT().AcceptUniStream(gomock.Any()).Return(nil, errors.New("done")).MaxTimes(1)
+			s.handleConn(sess)
+			_, err := quicvarint.Read(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			frame, err := parseNextFrame(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(BeAssignableToTypeOf(&settingsFrame{}))
+			sf := frame.(*settingsFrame)
+			Expect(sf.MaxFieldSectionSize).To(BeEquivalentTo(1337))
+			Expect(sf.QPACKMaxTableCapacity).To(BeEquivalentTo(4096))
+			Expect(sf.QPACKBlockedStreams).To(BeEquivalentTo(10))
+		})
+
 		Context("control stream handling", func() {
 			var sess *mockquic.MockEarlySession
 			testDone := make(chan struct{})
@@ -231,6 +364,31 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("lets the UniStreamHijacker take over streams of unknown stream types", func() {
+				buf := &bytes.Buffer{}
+				quicvarint.Write(buf, 0x54)
+				str := mockquic.NewMockStream(mockCtrl)
+				str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+				hijacked := make(chan StreamType, 1)
+				s.UniStreamHijacker = func(st StreamType, qsess quic.Session, qstr quic.ReceiveStream) bool {
+					defer GinkgoRecover()
+					Expect(qstr).To(Equal(str))
+					hijacked <- st
+					return true
+				}
+
+				sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					return str, nil
+				})
+				sess.EXPECT().AcceptUniStream(gomock.Any()).DoAndReturn(func(context.Context) (quic.ReceiveStream, error) {
+					<-testDone
+					return nil, errors.New("test done")
+				})
+				s.handleConn(sess)
+				Eventually(hijacked).Should(Receive(BeEquivalentTo(0x54)))
+				time.Sleep(scaleDuration(20 * time.Millisecond)) // don't EXPECT any calls to str.CancelRead
+			})
+
 			It("errors when the first frame on the control stream is not a SETTINGS frame", func() {
 				buf := &bytes.Buffer{}
 				quicvarint.Write(buf, streamTypeControlStream)
@@ -348,6 +506,7 @@ var _ = Describe("Server", func() {
 				sess.EXPECT().AcceptStream(gomock.Any()).Return(nil, errors.New("done"))
 				sess.EXPECT().RemoteAddr().Return(addr).AnyTimes()
 				sess.EXPECT().LocalAddr().AnyTimes()
+				str.EXPECT().StreamID().AnyTimes()
 			})
 
 			AfterEach(func() { testDone <- struct{}{} })
@@ -396,6 +555,36 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("responds with 431 when the client sends a too large header field section", func() {
+				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					Fail("Handler should not be called.")
+				})
+
+				requestData := encodeRequest(exampleGetRequest)
+				// The HEADERS frame itself is smaller than the limit,
+				// but the decoded header fields (including the per-field overhead) are not.
+				r := bytes.NewReader(requestData)
+				frame, err := parseNextFrame(r)
+				Expect(err).ToNot(HaveOccurred())
+				headerBlock := make([]byte, frame.(*headersFrame).Length)
+				_, err = io.ReadFull(r, headerBlock)
+				Expect(err).ToNot(HaveOccurred())
+				hfs, err := qpack.NewDecoder(nil).DecodeFull(headerBlock)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(fieldSectionSize(hfs)).To(BeNumerically(">", len(headerBlock)))
+				s.Server.MaxHeaderBytes = len(headerBlock)
+				setRequest(requestData)
+				responseBuf := &bytes.Buffer{}
+				done := make(chan struct{})
+				str.EXPECT().Write(gomock.Any()).DoAndReturn(responseBuf.Write).AnyTimes()
+				str.EXPECT().CancelRead(quic.ErrorCode(errorNoError))
+				str.EXPECT().Close().Do(func() { close(done) })
+
+				s.handleConn(sess)
+				Eventually(done).Should(BeClosed())
+				Expect(decodeHeader(responseBuf)).To(HaveKeyWithValue(":status", []string{"431"}))
+			})
+
 			It("handles a request for which the client immediately resets the stream", func() {
 				handlerCalled := make(chan struct{})
 				s.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -425,6 +614,10 @@ var _ = Describe("Server", func() {
 				}).AnyTimes()
 
 				done := make(chan struct{})
+				sess.EXPECT().ResetStreams(gomock.Any(), quic.ErrorCode(errorFrameUnexpected)).Do(func(match func(quic.StreamID) bool, _ quic.ErrorCode) {
+					Expect(match(0)).To(BeTrue())  // client-initiated bidirectional
+					Expect(match(2)).To(BeFalse()) // client-initiated unidirectional
+				})
 				sess.EXPECT().CloseWithError(gomock.Any(), gomock.Any()).Do(func(code quic.ErrorCode, _ string) {
 					Expect(code).To(Equal(quic.ErrorCode(errorFrameUnexpected)))
 					close(done)
@@ -474,7 +667,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.ErrorCode(errorNoError))
 
-			serr := s.handleRequest(sess, str, qpackDecoder, nil)
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
@@ -497,7 +690,7 @@ var _ = Describe("Server", func() {
 			}).AnyTimes()
 			str.EXPECT().CancelRead(quic.ErrorCode(errorNoError))
 
-			serr := s.handleRequest(sess, str, qpackDecoder, nil)
+			serr := s.handleRequest(sess, str, qpackEncoder, qpackDecoder, nil)
 			Expect(serr.err).ToNot(HaveOccurred())
 			Eventually(handlerCalled).Should(BeClosed())
 		})
@@ -682,7 +875,32 @@ var _ = Describe("Server", func() {
 			}
 			s.QuicConfig = conf
 			Expect(s.ListenAndServe()).To(HaveOccurred())
-			Expect(receivedConf).To(Equal(conf))
+			Expect(receivedConf.HandshakeIdleTimeout).To(Equal(time.Nanosecond))
+		})
+
+		It("uses a small flow control window for unidirectional streams", func() {
+			var receivedConf *quic.Config
+			quicListenAddr = func(addr string, _ *tls.Config, config *quic.Config) (quic.EarlyListener, error) {
+				receivedConf = config
+				return nil, errors.New("listen err")
+			}
+			s.QuicConfig = &quic.Config{}
+			Expect(s.ListenAndServe()).To(HaveOccurred())
+			Expect(receivedConf.MaxReceiveUniStreamFlowControlWindow).To(BeEquivalentTo(uniStreamReceiveWindow))
+			Expect(receivedConf.MaxReceiveStreamFlowControlWindow).To(BeZero())
+			// make sure the original quic.Config was not modified
+			Expect(s.QuicConfig.MaxReceiveUniStreamFlowControlWindow).To(BeZero())
+		})
+
+		It("uses the default flow control window for unidirectional streams if a UniStreamHijacker is set", func() {
+			var receivedConf *quic.Config
+			quicListenAddr = func(addr string, _ *tls.Config, config *quic.Config) (quic.EarlyListener, error) {
+				receivedConf = config
+				return nil, errors.New("listen err")
+			}
+			s.UniStreamHijacker = func(StreamType, quic.Session, quic.ReceiveStream) bool { return false }
+			Expect(s.ListenAndServe()).To(HaveOccurred())
+			Expect(receivedConf.MaxReceiveUniStreamFlowControlWindow).To(BeZero())
 		})
 
 		It("sets the GetConfigForClient and replaces the ALPN token to the tls.Config, if the GetConfigForClient callback is not set", func() {
@@ -786,8 +1004,93 @@ var _ = Describe("Server", func() {
 		})
 	})
 
-	It("closes gracefully", func() {
-		Expect(s.CloseGracefully(0)).To(Succeed())
+	Context("closing gracefully", func() {
+		var (
+			controlStr *mockquic.MockStream
+			controlBuf *bytes.Buffer
+		)
+
+		BeforeEach(func() {
+			controlBuf = &bytes.Buffer{}
+			controlStr = mockquic.NewMockStream(mockCtrl)
+		})
+
+		It("closes gracefully", func() {
+			Expect(s.CloseGracefully(0)).To(Succeed())
+		})
+
+		It("sends a GOAWAY frame, and rejects new requests", func() {
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := &serverSession{controlStr: controlStr}
+			Expect(sess.acceptRequest(0)).To(BeTrue())
+			Expect(sess.acceptRequest(8)).To(BeTrue())
+			Expect(sess.goAway()).To(Succeed())
+			frame, err := parseNextFrame(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 12}))
+			// requests below the stream ID are still processed
+			Expect(sess.acceptRequest(4)).To(BeTrue())
+			Expect(sess.acceptRequest(12)).To(BeFalse())
+			Expect(sess.acceptRequest(16)).To(BeFalse())
+			// only sends a single GOAWAY frame
+			Expect(sess.goAway()).To(Succeed())
+		})
+
+		It("waits for running requests to complete", func() {
+			sentGoAway := make(chan struct{})
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+				close(sentGoAway)
+				return len(b), nil
+			})
+			sess := &serverSession{controlStr: controlStr, closed: make(chan struct{})}
+			s.addSession(sess)
+			Expect(sess.acceptRequest(0)).To(BeTrue())
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				Expect(s.CloseGracefully(time.Hour)).To(Succeed())
+				close(done)
+			}()
+			Eventually(sentGoAway).Should(BeClosed())
+			Consistently(done).ShouldNot(BeClosed())
+			sess.requests.Done()
+			// wait for the client to close the session
+			Consistently(done).ShouldNot(BeClosed())
+			s.removeSession(sess)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("doesn't wait for the client to close the session forever", func() {
+			origGoAwayTimeout := goAwayTimeout
+			defer func() { goAwayTimeout = origGoAwayTimeout }()
+			goAwayTimeout = scaleDuration(20 * time.Millisecond)
+
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := &serverSession{controlStr: controlStr, closed: make(chan struct{})}
+			s.addSession(sess)
+			Expect(sess.acceptRequest(0)).To(BeTrue())
+			sess.requests.Done()
+			Expect(s.CloseGracefully(time.Hour)).To(Succeed())
+		})
+
+		It("closes when the timeout expires", func() {
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := &serverSession{controlStr: controlStr, closed: make(chan struct{})}
+			s.addSession(sess)
+			Expect(sess.acceptRequest(0)).To(BeTrue())
+			Expect(s.CloseGracefully(scaleDuration(20 * time.Millisecond))).To(Succeed())
+		})
+
+		It("sends a GOAWAY frame on sessions established after starting the shutdown", func() {
+			Expect(s.CloseGracefully(0)).To(Succeed())
+			controlStr.EXPECT().Write(gomock.Any()).DoAndReturn(controlBuf.Write)
+			sess := &serverSession{controlStr: controlStr}
+			s.addSession(sess)
+			frame, err := parseNextFrame(controlBuf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(frame).To(Equal(&goAwayFrame{StreamID: 0}))
+			Expect(sess.acceptRequest(0)).To(BeFalse())
+		})
 	})
 
 	It("errors when listening fails", func() {
@@ -2,6 +2,7 @@ package self_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -568,4 +569,100 @@ var _ = Describe("Stream Cancelations", func() {
 			Expect(server.Close()).To(Succeed())
 		})
 	})
+
+	Context("sending STOP_SENDING frames", func() {
+		It("unblocks Write on both sides of a bidirectional stream", func() {
+			server, err := quic.ListenAddr("localhost:0", getTLSConfig(), getQuicConfig(nil))
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+
+			serverCanceledRead := make(chan struct{})
+			clientCanceledRead := make(chan struct{})
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				sess, err := server.Accept(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				str, err := sess.AcceptStream(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				str.CancelRead(42)
+				close(serverCanceledRead)
+				_, err = str.Read([]byte{0})
+				Expect(err).To(MatchError(fmt.Sprintf("Read on stream %d canceled with error code 42", str.StreamID())))
+				// The client doesn't read, so this Write blocks on flow control, until the client cancels reading.
+				writeErr := make(chan error, 1)
+				go func() {
+					_, err := str.Write(PRDataLong)
+					writeErr <- err
+				}()
+				Consistently(writeErr, scaleDuration(50*time.Millisecond)).ShouldNot(Receive())
+				<-clientCanceledRead
+				var streamErr quic.StreamError
+				Eventually(writeErr).Should(Receive(&err))
+				Expect(errors.As(err, &streamErr)).To(BeTrue())
+				Expect(streamErr.Canceled()).To(BeTrue())
+				Expect(streamErr.ErrorCode()).To(Equal(quic.ErrorCode(43)))
+			}()
+
+			sess, err := quic.DialAddr(
+				fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+				getTLSClientConfig(),
+				getQuicConfig(nil),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			defer sess.CloseWithError(0, "")
+			str, err := sess.OpenStreamSync(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			// The server only accepts the stream once it receives data.
+			_, err = str.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			<-serverCanceledRead
+			// Once the server's STOP_SENDING frame arrives, the Write is unblocked.
+			_, err = str.Write(PRDataLong)
+			var streamErr quic.StreamError
+			Expect(errors.As(err, &streamErr)).To(BeTrue())
+			Expect(streamErr.Canceled()).To(BeTrue())
+			Expect(streamErr.ErrorCode()).To(Equal(quic.ErrorCode(42)))
+			str.CancelRead(43)
+			close(clientCanceledRead)
+			_, err = str.Read([]byte{0})
+			Expect(err).To(MatchError(fmt.Sprintf("Read on stream %d canceled with error code 43", str.StreamID())))
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("waits for the peer to reset the stream", func() {
+			server, err := quic.ListenAddr("localhost:0", getTLSConfig(), getQuicConfig(nil))
+			Expect(err).ToNot(HaveOccurred())
+			defer server.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				sess, err := server.Accept(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				str, err := sess.AcceptUniStream(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				Expect(str.CancelReadAndWait(ctx, 42)).To(Succeed())
+			}()
+
+			sess, err := quic.DialAddr(
+				fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+				getTLSClientConfig(),
+				getQuicConfig(nil),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			defer sess.CloseWithError(0, "")
+			str, err := sess.OpenUniStreamSync(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			// The server only accepts the stream once it receives data.
+			_, err = str.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(done).Should(BeClosed())
+			Expect(str.Context().Done()).To(BeClosed())
+		})
+	})
 })
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"time"
 
@@ -23,12 +24,14 @@ var _ = Describe("Handshake RTT tests", func() {
 		serverTLSConfig *tls.Config
 		testStartedAt   time.Time
 		acceptStopped   chan struct{}
+		handleSession   func(quic.Session)
 	)
 
 	rtt := 400 * time.Millisecond
 
 	BeforeEach(func() {
 		acceptStopped = make(chan struct{})
+		handleSession = nil
 		serverConfig = getQuicConfig(nil)
 		serverTLSConfig = getTLSConfig()
 	})
@@ -57,9 +60,13 @@ var _ = Describe("Handshake RTT tests", func() {
 			defer GinkgoRecover()
 			defer close(acceptStopped)
 			for {
-				if _, err := server.Accept(context.Background()); err != nil {
+				sess, err := server.Accept(context.Background())
+				if err != nil {
 					return
 				}
+				if handleSession != nil {
+					go handleSession(sess)
+				}
 			}
 		}()
 	}
@@ -102,14 +109,21 @@ var _ = Describe("Handshake RTT tests", func() {
 	// 1 RTT for verifying the source address
 	// 1 RTT for the TLS handshake
 	It("is forward-secure after 2 RTTs", func() {
+		serverRoundTrips := make(chan int, 1)
+		handleSession = func(sess quic.Session) {
+			serverRoundTrips <- sess.ConnectionState().HandshakeRoundTrips
+		}
 		runServerAndProxy()
-		_, err := quic.DialAddr(
+		sess, err := quic.DialAddr(
 			fmt.Sprintf("localhost:%d", proxy.LocalAddr().(*net.UDPAddr).Port),
 			getTLSClientConfig(),
 			clientConfig,
 		)
 		Expect(err).ToNot(HaveOccurred())
 		expectDurationInRTTs(2)
+		// the Retry costs an additional round trip
+		Expect(sess.ConnectionState().HandshakeRoundTrips).To(Equal(2))
+		Eventually(serverRoundTrips).Should(Receive(Equal(2)))
 	})
 
 	It("establishes a connection in 1 RTT when the server doesn't require a token", func() {
@@ -117,13 +131,67 @@ var _ = Describe("Handshake RTT tests", func() {
 			return true
 		}
 		runServerAndProxy()
-		_, err := quic.DialAddr(
+		sess, err := quic.DialAddr(
 			fmt.Sprintf("localhost:%d", proxy.LocalAddr().(*net.UDPAddr).Port),
 			getTLSClientConfig(),
 			clientConfig,
 		)
 		Expect(err).ToNot(HaveOccurred())
 		expectDurationInRTTs(1)
+		Expect(sess.ConnectionState().HandshakeRoundTrips).To(Equal(1))
+	})
+
+	It("reports the handshake duration", func() {
+		serverConfig.AcceptToken = func(_ net.Addr, _ *quic.Token) bool {
+			return true
+		}
+		runServerAndProxy()
+		sess, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", proxy.LocalAddr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			getQuicConfig(nil),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		// The client confirms the handshake when it receives the HANDSHAKE_DONE frame, after 2 RTTs.
+		Eventually(func() time.Duration { return sess.Stats().HandshakeDuration }).ShouldNot(BeZero())
+		duration := sess.Stats().HandshakeDuration
+		Expect(duration).To(BeNumerically(">=", 2*rtt))
+		Expect(duration).To(BeNumerically("<", 3*rtt))
+	})
+
+	// The client sends 1-RTT data in the same flight as its Finished, without waiting for HANDSHAKE_DONE.
+	It("receives the response to the first request after 2 RTTs", func() {
+		serverConfig.AcceptToken = func(_ net.Addr, _ *quic.Token) bool {
+			return true
+		}
+		handleSession = func(sess quic.Session) {
+			defer GinkgoRecover()
+			str, err := sess.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			data, err := ioutil.ReadAll(str)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte("request")))
+			_, err = str.Write([]byte("response"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.Close()).To(Succeed())
+		}
+		runServerAndProxy()
+		sess, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", proxy.LocalAddr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			clientConfig,
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer sess.CloseWithError(0, "")
+		str, err := sess.OpenStream()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = str.Write([]byte("request"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(str.Close()).To(Succeed())
+		data, err := ioutil.ReadAll(str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("response")))
+		expectDurationInRTTs(2)
 	})
 
 	It("establishes a connection in 2 RTTs if a HelloRetryRequest is performed", func() {
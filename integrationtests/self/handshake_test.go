@@ -3,6 +3,8 @@ package self_test
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -174,6 +176,24 @@ var _ = Describe("Handshake tests", func() {
 				Expect(sess.CloseWithError(0, "")).To(Succeed())
 			})
 		}
+
+		It("fails the handshake if client and server don't have a cipher suite in common", func() {
+			tlsConf := getTLSConfig()
+			tlsConf.CipherSuites = []uint16{tls.TLS_AES_128_GCM_SHA256}
+			ln, err := quic.ListenAddr("localhost:0", tlsConf, serverConfig)
+			Expect(err).ToNot(HaveOccurred())
+			defer ln.Close()
+
+			clientTLSConf := getTLSClientConfig()
+			clientTLSConf.CipherSuites = []uint16{tls.TLS_CHACHA20_POLY1305_SHA256}
+			_, err = quic.DialAddr(
+				fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+				clientTLSConf,
+				nil,
+			)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("CRYPTO_ERROR (0x128)")) // TLS alert 40: handshake failure
+		})
 	})
 
 	Context("Certificate validation", func() {
@@ -218,6 +238,32 @@ var _ = Describe("Handshake tests", func() {
 					Expect(err).To(MatchError("CRYPTO_ERROR (0x12a): x509: cannot validate certificate for 127.0.0.1 because it doesn't contain any IP SANs"))
 				})
 
+				It("errors if the certificate is signed by an unknown CA", func() {
+					runServer(getTLSConfig())
+					tlsConf := getTLSClientConfig()
+					tlsConf.RootCAs = x509.NewCertPool()
+					_, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+						tlsConf,
+						clientConfig,
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("CRYPTO_ERROR (0x12a): x509: certificate signed by unknown authority"))
+				})
+
+				It("skips certificate validation if InsecureSkipVerify is set", func() {
+					runServer(getTLSConfig())
+					tlsConf := getTLSClientConfig()
+					tlsConf.RootCAs = x509.NewCertPool()
+					tlsConf.InsecureSkipVerify = true
+					_, err := quic.DialAddr(
+						fmt.Sprintf("127.0.0.1:%d", server.Addr().(*net.UDPAddr).Port),
+						tlsConf,
+						clientConfig,
+					)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
 				It("fails the handshake if the client fails to provide the requested client cert", func() {
 					tlsConf := getTLSConfig()
 					tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
@@ -243,6 +289,55 @@ var _ = Describe("Handshake tests", func() {
 					Expect(err).To(MatchError("CRYPTO_ERROR (0x12a): tls: bad certificate"))
 				})
 
+				It("rejects unknown server names using GetConfigForClient", func() {
+					tlsConf := getTLSConfig()
+					serverNames := make(chan string, 1)
+					tlsConf.GetConfigForClient = func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+						serverNames <- info.ServerName
+						return nil, errors.New("unknown server name")
+					}
+					runServer(tlsConf)
+					clientTLSConf := getTLSClientConfig()
+					clientTLSConf.ServerName = "unknown.example.com"
+					_, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+						clientTLSConf,
+						clientConfig,
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("CRYPTO_ERROR (0x150)")) // TLS alert 80: internal error
+					Expect(serverNames).To(Receive(Equal("unknown.example.com")))
+				})
+
+				It("rejects unknown server names using GetCertificate", func() {
+					tlsConf := getTLSConfig()
+					certs := tlsConf.Certificates
+					tlsConf.Certificates = nil
+					tlsConf.GetCertificate = func(info *tls.ClientHelloInfo) (*tls.Certificate, error) {
+						if info.ServerName != "localhost" {
+							return nil, errors.New("unknown server name")
+						}
+						return &certs[0], nil
+					}
+					runServer(tlsConf)
+					clientTLSConf := getTLSClientConfig()
+					clientTLSConf.ServerName = "unknown.example.com"
+					_, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+						clientTLSConf,
+						clientConfig,
+					)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("CRYPTO_ERROR (0x150)")) // TLS alert 80: internal error
+					// the handshake succeeds for known server names
+					_, err = quic.DialAddr(
+						fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+						getTLSClientConfig(),
+						clientConfig,
+					)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
 				It("uses the ServerName in the tls.Config", func() {
 					runServer(getTLSConfig())
 					tlsConf := getTLSClientConfig()
@@ -11,7 +11,10 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	quic "github.com/lucas-clemente/quic-go"
@@ -161,6 +164,64 @@ var _ = Describe("HTTP tests", func() {
 				Expect(resp.Header.Get("lorem")).To(Equal("ipsum"))
 			})
 
+			It("sends and receives trailers", func() {
+				mux.HandleFunc("/trailers", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					Expect(r.Trailer).To(HaveKey("Foo"))
+					body, err := ioutil.ReadAll(r.Body)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(r.Trailer.Get("foo")).To(Equal("bar"))
+					w.Header().Set("Trailer", "Lorem")
+					w.Write(body)
+					w.Header().Set("Lorem", "ipsum")
+				})
+
+				req, err := http.NewRequest(http.MethodPost, "https://localhost:"+port+"/trailers", bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				req.Trailer = http.Header{"Foo": []string{"bar"}}
+				resp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := ioutil.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+				Expect(resp.Trailer.Get("lorem")).To(Equal("ipsum"))
+			})
+
+			It("receives informational responses", func() {
+				mux.HandleFunc("/early-hints", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+					w.WriteHeader(http.StatusEarlyHints)
+					w.Header().Add("Link", "</script.js>; rel=preload; as=script")
+					w.WriteHeader(http.StatusEarlyHints)
+					w.Write([]byte("foobar"))
+				})
+
+				var statusCodes []int
+				var links [][]string
+				trace := &httptrace.ClientTrace{
+					Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+						statusCodes = append(statusCodes, code)
+						links = append(links, header["Link"])
+						return nil
+					},
+				}
+				req, err := http.NewRequest(http.MethodGet, "https://localhost:"+port+"/early-hints", nil)
+				Expect(err).ToNot(HaveOccurred())
+				resp, err := client.Do(req.WithContext(httptrace.WithClientTrace(context.Background(), trace)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(resp.StatusCode).To(Equal(200))
+				body, err := ioutil.ReadAll(gbytes.TimeoutReader(resp.Body, 3*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal("foobar"))
+				Expect(statusCodes).To(Equal([]int{103, 103}))
+				Expect(links).To(Equal([][]string{
+					{"</style.css>; rel=preload; as=style"},
+					{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"},
+				}))
+			})
+
 			It("downloads a small file", func() {
 				resp, err := client.Get("https://localhost:" + port + "/prdata")
 				Expect(err).ToNot(HaveOccurred())
@@ -229,6 +290,90 @@ var _ = Describe("HTTP tests", func() {
 				Expect(body).To(Equal(PRData))
 			})
 
+			It("streams large uploads, applying backpressure", func() {
+				handlerCalled := make(chan struct{})
+				unblock := make(chan struct{})
+				mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					close(handlerCalled)
+					<-unblock
+					n, err := io.Copy(ioutil.Discard, r.Body)
+					Expect(err).ToNot(HaveOccurred())
+					fmt.Fprintf(w, "%d", n)
+				})
+
+				const total = 4 << 20 // much larger than the initial flow control window
+				var written int64
+				r, w := io.Pipe()
+				go func() {
+					defer GinkgoRecover()
+					chunk := make([]byte, 16<<10)
+					for i := 0; i < total/len(chunk); i++ {
+						n, err := w.Write(chunk)
+						Expect(err).ToNot(HaveOccurred())
+						atomic.AddInt64(&written, int64(n))
+					}
+					w.Close()
+				}()
+
+				rspChan := make(chan *http.Response, 1)
+				go func() {
+					defer GinkgoRecover()
+					rsp, err := client.Post("https://localhost:"+port+"/upload", "application/octet-stream", r)
+					Expect(err).ToNot(HaveOccurred())
+					rspChan <- rsp
+				}()
+				Eventually(handlerCalled).Should(BeClosed())
+				// The handler doesn't read the body, so the upload is blocked by flow control.
+				Consistently(func() int64 { return atomic.LoadInt64(&written) }).Should(BeNumerically("<", total/2))
+				close(unblock)
+
+				var rsp *http.Response
+				Eventually(rspChan, 10*time.Second).Should(Receive(&rsp))
+				Expect(rsp.StatusCode).To(Equal(200))
+				body, err := ioutil.ReadAll(gbytes.TimeoutReader(rsp.Body, 5*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(body)).To(Equal(strconv.Itoa(total)))
+			})
+
+			It("resets the stream when the request is canceled during the upload", func() {
+				received := make(chan struct{})
+				handlerErr := make(chan error, 1)
+				mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					b := make([]byte, 1000)
+					_, err := io.ReadFull(r.Body, b)
+					Expect(err).ToNot(HaveOccurred())
+					close(received)
+					_, err = io.Copy(ioutil.Discard, r.Body)
+					handlerErr <- err
+				})
+
+				r, w := io.Pipe()
+				go func() {
+					defer GinkgoRecover()
+					w.Write(make([]byte, 1000))
+				}()
+				ctx, cancel := context.WithCancel(context.Background())
+				req, err := http.NewRequest(http.MethodPost, "https://localhost:"+port+"/upload", r)
+				Expect(err).ToNot(HaveOccurred())
+				errChan := make(chan error, 1)
+				go func() {
+					_, err := client.Do(req.WithContext(ctx))
+					errChan <- err
+				}()
+				Eventually(received).Should(BeClosed())
+				cancel()
+				Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+				var herr error
+				Eventually(handlerErr).Should(Receive(&herr))
+				serr, ok := herr.(streamCancelError)
+				Expect(ok).To(BeTrue())
+				Expect(serr.Canceled()).To(BeTrue())
+				Expect(serr.ErrorCode()).To(BeEquivalentTo(0x10c))
+				w.Close()
+			})
+
 			It("uses gzip compression", func() {
 				mux.HandleFunc("/gzipped/hello", func(w http.ResponseWriter, r *http.Request) {
 					defer GinkgoRecover()
@@ -279,7 +424,7 @@ var _ = Describe("HTTP tests", func() {
 				cancel()
 				Eventually(handlerCalled).Should(BeClosed())
 				_, err = resp.Body.Read([]byte{0})
-				Expect(err).To(HaveOccurred())
+				Expect(err).To(MatchError(context.Canceled))
 			})
 
 			It("allows streamed HTTP requests", func() {
@@ -319,6 +464,85 @@ var _ = Describe("HTTP tests", func() {
 				Expect(req.Body.Close()).To(Succeed())
 				Eventually(done).Should(BeClosed())
 			})
+
+			It("establishes a tunnel using extended CONNECT", func() {
+				done := make(chan struct{})
+				mux.HandleFunc("/tunnel", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					defer close(done)
+					Expect(r.Method).To(Equal(http.MethodConnect))
+					Expect(r.Proto).To(Equal("echo"))
+					Expect(r.URL.Query().Get("id")).To(Equal("42"))
+					w.WriteHeader(200)
+					w.(http.Flusher).Flush()
+					reader := bufio.NewReader(r.Body)
+					for {
+						msg, err := reader.ReadString('\n')
+						if err != nil {
+							return
+						}
+						_, err = w.Write([]byte(msg))
+						Expect(err).ToNot(HaveOccurred())
+						w.(http.Flusher).Flush()
+					}
+				})
+
+				r, w := io.Pipe()
+				req, err := http.NewRequest(http.MethodConnect, "https://localhost:"+port+"/tunnel?id=42", r)
+				Expect(err).ToNot(HaveOccurred())
+				req.Proto = "echo"
+				rsp, err := client.Do(req)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(rsp.StatusCode).To(Equal(200))
+
+				reader := bufio.NewReader(rsp.Body)
+				for i := 0; i < 5; i++ {
+					msg := fmt.Sprintf("Hello tunnel, %d!\n", i)
+					fmt.Fprint(w, msg)
+					msgRcvd, err := reader.ReadString('\n')
+					Expect(err).ToNot(HaveOccurred())
+					Expect(msgRcvd).To(Equal(msg))
+				}
+				Expect(w.Close()).To(Succeed())
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("completes running requests when closing gracefully", func() {
+				handlerCalled := make(chan struct{})
+				unblock := make(chan struct{})
+				mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					close(handlerCalled)
+					<-unblock
+					w.Write(PRDataLong) // don't check the error here. Stream may be reset.
+				})
+
+				rspChan := make(chan *http.Response, 1)
+				go func() {
+					defer GinkgoRecover()
+					rsp, err := client.Get("https://localhost:" + port + "/slow")
+					Expect(err).ToNot(HaveOccurred())
+					rspChan <- rsp
+				}()
+				Eventually(handlerCalled).Should(BeClosed())
+
+				closed := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					Expect(server.CloseGracefully(10 * time.Second)).To(Succeed())
+					close(closed)
+				}()
+				Consistently(closed).ShouldNot(BeClosed())
+				close(unblock)
+
+				var rsp *http.Response
+				Eventually(rspChan).Should(Receive(&rsp))
+				Expect(rsp.StatusCode).To(Equal(200))
+				body, err := ioutil.ReadAll(gbytes.TimeoutReader(rsp.Body, 20*time.Second))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(body).To(Equal(PRDataLong))
+				Eventually(closed).Should(BeClosed())
+			})
 		})
 	}
 })
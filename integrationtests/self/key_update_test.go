@@ -82,6 +82,7 @@ func (t *connTracer) LostPacket(logging.EncryptionLevel, logging.PacketNumber, l
 }
 func (t *connTracer) UpdatedCongestionState(logging.CongestionState)                     {}
 func (t *connTracer) UpdatedPTOCount(value uint32)                                       {}
+func (t *connTracer) UpdatedAmplificationLimited(bool)                                   {}
 func (t *connTracer) UpdatedKeyFromTLS(logging.EncryptionLevel, logging.Perspective)     {}
 func (t *connTracer) UpdatedKey(generation logging.KeyPhase, remote bool)                {}
 func (t *connTracer) DroppedEncryptionLevel(logging.EncryptionLevel)                     {}
@@ -216,6 +216,49 @@ var _ = Describe("Multiplexing", func() {
 					Eventually(done2, timeout).Should(BeClosed())
 				})
 			})
+
+			Context("multiplexing with other protocols", func() {
+				It("passes packets that aren't QUIC packets to the unknown packet handler", func() {
+					type packet struct {
+						data []byte
+						addr net.Addr
+					}
+					unknownPackets := make(chan packet, 10)
+					server, err := quic.ListenAddr(
+						"localhost:0",
+						getTLSConfig(),
+						getQuicConfig(&quic.Config{
+							Versions: []protocol.VersionNumber{version},
+							UnknownPacketHandler: func(data []byte, addr net.Addr) {
+								b := make([]byte, len(data))
+								copy(b, data)
+								unknownPackets <- packet{data: b, addr: addr}
+							},
+						}),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					runServer(server)
+					defer server.Close()
+
+					addr, err := net.ResolveUDPAddr("udp", "localhost:0")
+					Expect(err).ToNot(HaveOccurred())
+					conn, err := net.ListenUDP("udp", addr)
+					Expect(err).ToNot(HaveOccurred())
+					defer conn.Close()
+
+					// a STUN Binding Request, see RFC 5389
+					stun := append([]byte{0x0, 0x1, 0x0, 0x0, 0x21, 0x12, 0xa4, 0x42}, make([]byte, 12)...)
+					_, err = conn.WriteTo(stun, server.Addr())
+					Expect(err).ToNot(HaveOccurred())
+					var p packet
+					Eventually(unknownPackets).Should(Receive(&p))
+					Expect(p.data).To(Equal(stun))
+					Expect(p.addr.String()).To(Equal(conn.LocalAddr().String()))
+
+					// QUIC packets are still processed as usual
+					dial(conn, server.Addr())
+				})
+			})
 		})
 	}
 })
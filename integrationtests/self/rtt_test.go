@@ -113,6 +113,72 @@ var _ = Describe("non-zero RTT", func() {
 					downloadFile(proxy.LocalPort())
 				})
 			}
+
+			It("downloads faster with larger flow control windows", func() {
+				const rtt = 100 * time.Millisecond
+				ln := runServer()
+				defer ln.Close()
+				go func() {
+					defer GinkgoRecover()
+					// runServer only serves a single session
+					for {
+						sess, err := ln.Accept(context.Background())
+						if err != nil {
+							return
+						}
+						str, err := sess.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = str.Write(PRData)
+						Expect(err).ToNot(HaveOccurred())
+						str.Close()
+					}
+				}()
+				serverPort := ln.Addr().(*net.UDPAddr).Port
+				proxy, err := quicproxy.NewQuicProxy("localhost:0", &quicproxy.Opts{
+					RemoteAddr: fmt.Sprintf("localhost:%d", serverPort),
+					DelayPacket: func(quicproxy.Direction, []byte) time.Duration {
+						return rtt / 2
+					},
+				})
+				Expect(err).ToNot(HaveOccurred())
+				defer proxy.Close()
+
+				download := func(conf *quic.Config) time.Duration {
+					start := time.Now()
+					sess, err := quic.DialAddr(
+						fmt.Sprintf("localhost:%d", proxy.LocalPort()),
+						getTLSClientConfig(),
+						getQuicConfig(conf),
+					)
+					Expect(err).ToNot(HaveOccurred())
+					defer sess.CloseWithError(0, "")
+					str, err := sess.AcceptStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := ioutil.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(data).To(Equal(PRData))
+					return time.Since(start)
+				}
+
+				// Limit the windows, such that auto-tuning can't increase them.
+				// Only 32 KB can be transferred per RTT.
+				durationSmallWindows := download(&quic.Config{
+					Versions:                              []protocol.VersionNumber{version},
+					InitialStreamReceiveWindow:            32 << 10,
+					InitialConnectionReceiveWindow:        48 << 10,
+					MaxReceiveStreamFlowControlWindow:     32 << 10,
+					MaxReceiveConnectionFlowControlWindow: 48 << 10,
+				})
+				durationLargeWindows := download(&quic.Config{
+					Versions:                       []protocol.VersionNumber{version},
+					InitialStreamReceiveWindow:     uint64(2 * len(PRData)),
+					InitialConnectionReceiveWindow: uint64(3 * len(PRData)),
+				})
+				fmt.Fprintf(GinkgoWriter, "Download took %s with small windows, and %s with large windows.\n", durationSmallWindows, durationLargeWindows)
+				// 500 KB need at least 15 round trips with 32 KB windows
+				Expect(durationSmallWindows).To(BeNumerically(">", 10*rtt))
+				Expect(durationLargeWindows).To(BeNumerically("<", durationSmallWindows/2))
+			})
 		})
 	}
 })
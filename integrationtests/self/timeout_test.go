@@ -3,6 +3,7 @@ package self_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -355,6 +356,45 @@ var _ = Describe("Timeout tests", func() {
 		Eventually(serverSessionClosed).Should(BeClosed())
 	})
 
+	It("errors when the peer doesn't increase the stream limit", func() {
+		const streamLimitTimeout = 100 * time.Millisecond
+
+		server, err := quic.ListenAddr(
+			"localhost:0",
+			getTLSConfig(),
+			getQuicConfig(&quic.Config{MaxIncomingStreams: 2}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer server.Close()
+
+		go func() {
+			defer GinkgoRecover()
+			// accept the session, but never accept any streams
+			_, err := server.Accept(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		}()
+
+		sess, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", server.Addr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			getQuicConfig(&quic.Config{StreamLimitTimeout: streamLimitTimeout}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		defer sess.CloseWithError(0, "")
+		for i := 0; i < 2; i++ {
+			_, err := sess.OpenStreamSync(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		}
+		startTime := time.Now()
+		_, err = sess.OpenStreamSync(context.Background())
+		checkTimeoutError(err)
+		Expect(errors.Is(err, quic.ErrStreamLimitNotIncreased)).To(BeTrue())
+		Expect(time.Since(startTime)).To(BeNumerically(">=", streamLimitTimeout))
+		// the session is still usable
+		_, err = sess.OpenUniStream()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
 	Context("faulty packet conns", func() {
 		const handshakeTimeout = time.Second / 2
 
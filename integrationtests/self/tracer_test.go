@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	mrand "math/rand"
 	"net"
+	"os"
+	"path/filepath"
 
 	"github.com/lucas-clemente/quic-go"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -87,4 +90,60 @@ var _ = Describe("Handshake tests", func() {
 			Expect(data).To(Equal(PRData))
 		})
 	}
+
+	It("writes a qlog file per connection to the QlogDir", func() {
+		dir, err := ioutil.TempDir("", "quic-go-qlog")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		ln, err := quic.ListenAddr("localhost:0", getTLSConfig(), getQuicConfig(&quic.Config{QlogDir: dir}))
+		Expect(err).ToNot(HaveOccurred())
+		defer ln.Close()
+		serverSessClosed := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(serverSessClosed)
+			sess, err := ln.Accept(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = sess.AcceptStream(context.Background())
+			Expect(err).To(HaveOccurred())
+		}()
+
+		sess, err := quic.DialAddr(
+			fmt.Sprintf("localhost:%d", ln.Addr().(*net.UDPAddr).Port),
+			getTLSClientConfig(),
+			getQuicConfig(&quic.Config{QlogDir: dir}),
+		)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sess.CloseWithError(0, "")).To(Succeed())
+		Eventually(serverSessClosed).Should(BeClosed())
+
+		// the qlog files are written asynchronously when the session is closed
+		Eventually(func() error {
+			files, err := filepath.Glob(filepath.Join(dir, "*.qlog"))
+			if err != nil {
+				return err
+			}
+			if len(files) != 2 {
+				return fmt.Errorf("expected 2 qlog files, got %d", len(files))
+			}
+			for _, file := range files {
+				data, err := ioutil.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				var qlog map[string]interface{}
+				if err := json.Unmarshal(data, &qlog); err != nil {
+					return err
+				}
+				if qlog["qlog_version"] != "draft-02" {
+					return fmt.Errorf("unexpected qlog version: %v", qlog["qlog_version"])
+				}
+			}
+			return nil
+		}).Should(Succeed())
+		files, err := filepath.Glob(filepath.Join(dir, "*_client.qlog"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(files).To(HaveLen(1))
+	})
 })
@@ -0,0 +1,208 @@
+package self_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/webtransport"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WebTransport tests", func() {
+	for _, v := range protocol.SupportedVersions {
+		version := v
+
+		Context(fmt.Sprintf("with QUIC version %s", version), func() {
+			var (
+				server         *webtransport.Server
+				dialer         *webtransport.Dialer
+				sessChan       chan *webtransport.Session
+				stoppedServing chan struct{}
+				url            string
+			)
+
+			BeforeEach(func() {
+				sessChan = make(chan *webtransport.Session, 1)
+				mux := http.NewServeMux()
+				server = &webtransport.Server{
+					H3: http3.Server{
+						Server:     &http.Server{Handler: mux, TLSConfig: getTLSConfig()},
+						QuicConfig: getQuicConfig(&quic.Config{Versions: []protocol.VersionNumber{version}}),
+					},
+				}
+				mux.HandleFunc("/webtransport", func(w http.ResponseWriter, r *http.Request) {
+					defer GinkgoRecover()
+					sess, err := server.Upgrade(w, r)
+					Expect(err).ToNot(HaveOccurred())
+					sessChan <- sess
+				})
+
+				conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+				Expect(err).ToNot(HaveOccurred())
+				url = "https://localhost:" + strconv.Itoa(conn.LocalAddr().(*net.UDPAddr).Port) + "/webtransport"
+				stoppedServing = make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					server.Serve(conn)
+					close(stoppedServing)
+				}()
+
+				dialer = &webtransport.Dialer{
+					TLSClientConfig: getTLSClientConfig(),
+					QuicConfig: getQuicConfig(&quic.Config{
+						Versions:       []protocol.VersionNumber{version},
+						MaxIdleTimeout: 10 * time.Second,
+					}),
+				}
+			})
+
+			AfterEach(func() {
+				Expect(dialer.Close()).To(Succeed())
+				Expect(server.Close()).To(Succeed())
+				Eventually(stoppedServing).Should(BeClosed())
+			})
+
+			dial := func() (clientSess, serverSess *webtransport.Session) {
+				rsp, sess, err := dialer.Dial(context.Background(), url, nil)
+				ExpectWithOffset(1, err).ToNot(HaveOccurred())
+				ExpectWithOffset(1, rsp.StatusCode).To(Equal(200))
+				EventuallyWithOffset(1, sessChan).Should(Receive(&serverSess))
+				return sess, serverSess
+			}
+
+			It("exchanges data on bidirectional streams opened by the client", func() {
+				clientSess, serverSess := dial()
+				go func() {
+					defer GinkgoRecover()
+					str, err := serverSess.AcceptStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := ioutil.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = str.Write(data)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.Close()).To(Succeed())
+				}()
+
+				str, err := clientSess.OpenStreamSync(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				_, err = str.Write(PRData)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(str.Close()).To(Succeed())
+				data, err := ioutil.ReadAll(str)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal(PRData))
+			})
+
+			It("exchanges data on bidirectional streams opened by the server", func() {
+				clientSess, serverSess := dial()
+				go func() {
+					defer GinkgoRecover()
+					str, err := serverSess.OpenStreamSync(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					_, err = str.Write(PRData)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.Close()).To(Succeed())
+				}()
+
+				str, err := clientSess.AcceptStream(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				data, err := ioutil.ReadAll(str)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal(PRData))
+			})
+
+			It("exchanges data on unidirectional streams", func() {
+				clientSess, serverSess := dial()
+				go func() {
+					defer GinkgoRecover()
+					rstr, err := serverSess.AcceptUniStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := ioutil.ReadAll(rstr)
+					Expect(err).ToNot(HaveOccurred())
+					sstr, err := serverSess.OpenUniStreamSync(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					_, err = sstr.Write(data)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(sstr.Close()).To(Succeed())
+				}()
+
+				sstr, err := clientSess.OpenUniStreamSync(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				_, err = sstr.Write(PRData)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sstr.Close()).To(Succeed())
+				rstr, err := clientSess.AcceptUniStream(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				data, err := ioutil.ReadAll(rstr)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal(PRData))
+			})
+
+			It("exchanges datagrams", func() {
+				clientSess, serverSess := dial()
+				go func() {
+					defer GinkgoRecover()
+					for {
+						data, err := serverSess.ReceiveMessage()
+						if err != nil {
+							return
+						}
+						Expect(serverSess.SendMessage(data)).To(Succeed())
+					}
+				}()
+
+				for i := 0; i < 10; i++ {
+					msg := []byte(fmt.Sprintf("datagram %d", i))
+					Expect(clientSess.SendMessage(msg)).To(Succeed())
+					data, err := clientSess.ReceiveMessage()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(data).To(Equal(msg))
+				}
+			})
+
+			It("multiplexes multiple sessions on the same QUIC session", func() {
+				clientSess1, serverSess1 := dial()
+				clientSess2, serverSess2 := dial()
+				Expect(clientSess1.LocalAddr()).To(Equal(clientSess2.LocalAddr()))
+
+				for i, sess := range []*webtransport.Session{serverSess1, serverSess2} {
+					str, err := sess.OpenUniStreamSync(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					_, err = str.Write([]byte(fmt.Sprintf("session %d", i+1)))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.Close()).To(Succeed())
+					Expect(sess.SendMessage([]byte(fmt.Sprintf("datagram %d", i+1)))).To(Succeed())
+				}
+
+				for i, sess := range []*webtransport.Session{clientSess1, clientSess2} {
+					str, err := sess.AcceptUniStream(context.Background())
+					Expect(err).ToNot(HaveOccurred())
+					data, err := ioutil.ReadAll(str)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(data)).To(Equal(fmt.Sprintf("session %d", i+1)))
+					data, err = sess.ReceiveMessage()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(string(data)).To(Equal(fmt.Sprintf("datagram %d", i+1)))
+				}
+			})
+
+			It("closes the session", func() {
+				clientSess, serverSess := dial()
+				Expect(clientSess.Close()).To(Succeed())
+				Eventually(serverSess.Context().Done()).Should(BeClosed())
+				_, err := serverSess.AcceptStream(context.Background())
+				Expect(err).To(MatchError("webtransport: session closed"))
+			})
+		})
+	}
+})
@@ -2,12 +2,16 @@ package quic
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"time"
 
+	"github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/handshake"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/qerr"
 	"github.com/lucas-clemente/quic-go/logging"
 )
 
@@ -92,6 +96,18 @@ type ReceiveStream interface {
 	// Read will unblock immediately, and future Read calls will fail.
 	// When called multiple times or after reading the io.EOF it is a no-op.
 	CancelRead(ErrorCode)
+	// CancelReadAndWait is like CancelRead, but blocks until the peer has torn down its send side of the stream,
+	// i.e. until it responded to the STOP_SENDING frame by resetting the stream (or until it closed the stream,
+	// if it had already sent all data).
+	// It returns the context's error if the context is canceled first, and the session's error if the session is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	CancelReadAndWait(context.Context, ErrorCode) error
+	// Drain stops granting the peer flow control credit for this stream.
+	// Data that was already received, and data that the peer sends within the current
+	// flow control window, is still delivered by Read, until the peer closes the stream
+	// (and Read returns io.EOF) or resets it.
+	// Warning: This API should not be considered stable and might change soon.
+	Drain()
 	// SetReadDeadline sets the deadline for future Read calls and
 	// any currently-blocked Read call.
 	// A zero value for t means Read will not time out.
@@ -126,6 +142,11 @@ type SendStream interface {
 	// cancels the read-side of their stream.
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
+	// Writable returns a channel that receives a value when the stream was blocked by
+	// flow control, and the peer then granted more flow control credit
+	// (by sending a MAX_STREAM_DATA or a MAX_DATA frame).
+	// Warning: This API should not be considered stable and might change soon.
+	Writable() <-chan struct{}
 	// SetWriteDeadline sets the deadline for future Write calls
 	// and any currently-blocked Write call.
 	// Even if write times out, it may return n > 0, indicating that
@@ -141,6 +162,50 @@ type StreamError interface {
 	ErrorCode() ErrorCode
 }
 
+// ErrDatagramsNotSupported is returned by SendMessage and ReceiveMessage if datagram support wasn't negotiated.
+var ErrDatagramsNotSupported = errors.New("datagram support not negotiated")
+
+// ErrStreamLimitNotIncreased is returned by OpenStreamSync and OpenUniStreamSync (wrapped in a net.Error),
+// if the peer didn't increase its stream limit within Config.StreamLimitTimeout.
+var ErrStreamLimitNotIncreased = errors.New("peer stream limit not increased")
+
+// ErrSendStallTimeout is the error that a session is closed with when the peer didn't acknowledge
+// any new packets within Config.SendStallTimeout.
+// It satisfies the net.Error interface, and Timeout() is true.
+// The peer is notified with a CONNECTION_CLOSE frame.
+var ErrSendStallTimeout error = qerr.NewTimeoutError("No ACK progress")
+
+// A DatagramTooLargeError is returned by SendMessage if the message doesn't fit into a single DATAGRAM frame.
+type DatagramTooLargeError struct {
+	// MaxDataLen is the maximum message size that the peer accepts.
+	MaxDataLen int
+}
+
+func (e *DatagramTooLargeError) Error() string {
+	return fmt.Sprintf("message too large (maximum: %d bytes)", e.MaxDataLen)
+}
+
+// A SessionCloseError describes why a session was closed.
+// It is the cause of the cancellation of the session's context (see Session.Context),
+// and can be obtained using context.Cause on Go 1.20 and newer.
+type SessionCloseError struct {
+	// Remote is set if the session was closed by the peer.
+	Remote bool
+	// IsApplicationError says if ErrorCode is an application error code or a transport error code.
+	IsApplicationError bool
+	ErrorCode          uint64
+	// Err is the error that the session was closed with.
+	Err error
+}
+
+func (e *SessionCloseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SessionCloseError) Unwrap() error {
+	return e.Err
+}
+
 // A Session is a QUIC connection between two peers.
 type Session interface {
 	// AcceptStream returns the next stream opened by the peer, blocking until one is available.
@@ -162,6 +227,8 @@ type Session interface {
 	// It blocks until a new stream can be opened.
 	// If the error is non-nil, it satisfies the net.Error interface.
 	// If the session was closed due to a timeout, Timeout() will be true.
+	// If the peer didn't increase its stream limit within Config.StreamLimitTimeout,
+	// the error wraps ErrStreamLimitNotIncreased, and Timeout() will be true.
 	OpenStreamSync(context.Context) (Stream, error)
 	// OpenUniStream opens a new outgoing unidirectional QUIC stream.
 	// If the error is non-nil, it satisfies the net.Error interface.
@@ -172,7 +239,34 @@ type Session interface {
 	// It blocks until a new stream can be opened.
 	// If the error is non-nil, it satisfies the net.Error interface.
 	// If the session was closed due to a timeout, Timeout() will be true.
+	// If the peer didn't increase its stream limit within Config.StreamLimitTimeout,
+	// the error wraps ErrStreamLimitNotIncreased, and Timeout() will be true.
 	OpenUniStreamSync(context.Context) (SendStream, error)
+	// NextStreamID returns the ID of the stream that the next call to OpenStream or OpenStreamSync will open.
+	// If streams are opened concurrently, the stream with this ID might be opened by a different call.
+	NextStreamID() StreamID
+	// NextUniStreamID returns the ID of the stream that the next call to OpenUniStream or OpenUniStreamSync will open.
+	NextUniStreamID() StreamID
+	// CanOpenStreams checks if num more bidirectional streams can be opened without blocking.
+	// If the peer's stream limit doesn't allow that, a STREAMS_BLOCKED frame is sent,
+	// asking the peer to increase the limit, and false is returned.
+	// This allows an application to request stream credit before opening a burst of streams.
+	// No streams are reserved: streams opened concurrently use up the same stream credit.
+	// If num is not positive, false is returned.
+	CanOpenStreams(num int) bool
+	// CanOpenUniStreams is like CanOpenStreams, but for unidirectional streams.
+	CanOpenUniStreams(num int) bool
+	// OpenStreamIDs returns the IDs of all streams that are currently open, in ascending order.
+	// Streams of all types, opened by both endpoints, are included.
+	// The returned slice is a copy and can be modified by the caller.
+	OpenStreamIDs() []StreamID
+	// IdleStreams returns the bidirectional streams that haven't transferred any data
+	// (by calls to Read or Write) for longer than idleTimeout.
+	// This allows the application to reap streams that the peer abandoned, e.g. by calling CancelRead and CancelWrite.
+	IdleStreams(idleTimeout time.Duration) []Stream
+	// ResetStreams resets all streams whose stream ID matches, using the given error code.
+	// Send streams are canceled as if CancelWrite was called, and receive streams as if CancelRead was called.
+	ResetStreams(match func(StreamID) bool, code ErrorCode)
 	// LocalAddr returns the local address.
 	LocalAddr() net.Addr
 	// RemoteAddr returns the address of the peer.
@@ -181,6 +275,7 @@ type Session interface {
 	// The error string will be sent to the peer.
 	CloseWithError(ErrorCode, string) error
 	// The context is cancelled when the session is closed.
+	// On Go 1.20 and newer, context.Cause returns a *SessionCloseError for the cancelled context.
 	// Warning: This API should not be considered stable and might change soon.
 	Context() context.Context
 	// ConnectionState returns basic details about the QUIC connection.
@@ -188,12 +283,70 @@ type Session interface {
 	// Warning: This API should not be considered stable and might change soon.
 	ConnectionState() ConnectionState
 
+	// Ping sends a PING frame and blocks until the peer acknowledges it.
+	// It can be used to check that the connection is still alive.
+	// If the session is closed before the PING is acknowledged, the error that closed the session is returned.
+	Ping(context.Context) error
+
+	// MigrateTo migrates the connection to a new local address, e.g. when the network interface changed.
+	// It binds a new packet conn to the address, and validates the path to the peer before switching to it.
+	// Until the new path is validated, packets are sent on the old path.
+	// It blocks until the migration succeeded or failed.
+	// Only clients can migrate, and only after the handshake is confirmed.
+	// Warning: This API should not be considered stable and might change soon.
+	MigrateTo(net.Addr) error
+
+	// ProbeMTU sends a PING frame in a UDP datagram of target bytes, and blocks until it is acknowledged or declared lost.
+	// This allows the application to check the path MTU on demand, e.g. after the network changed.
+	// If the probe is acknowledged, true is returned, and the size of the datagrams sent is increased to target
+	// (if it's larger than the current size).
+	// The target must not exceed Config.MaxPacketSize and the peer's max_udp_payload_size.
+	// Probing is only possible after the handshake is confirmed, and if the DF bit is set on the socket (see Config.MaxPacketSize).
+	// Warning: This API should not be considered stable and might change soon.
+	ProbeMTU(target int) (bool, error)
+
+	// PauseSending stops sending of application data (STREAM and DATAGRAM frames).
+	// Data written to streams is buffered until ResumeSending is called.
+	// ACKs, control frames and keep-alive PINGs are still sent,
+	// as are retransmissions of data that was sent before pausing.
+	// Warning: This API should not be considered stable and might change soon.
+	PauseSending()
+	// ResumeSending resumes sending of application data after a call to PauseSending.
+	// Warning: This API should not be considered stable and might change soon.
+	ResumeSending()
+
 	// SendMessage sends a message as a datagram.
+	// If datagram support wasn't negotiated, ErrDatagramsNotSupported is returned.
+	// If the message is too large to fit into a single DATAGRAM frame, a *DatagramTooLargeError is returned.
 	// See https://datatracker.ietf.org/doc/draft-pauly-quic-datagram/.
 	SendMessage([]byte) error
+	// SendMessageWithCallback is like SendMessage, but calls the callback when the packet carrying the message
+	// is acknowledged (acked = true) or declared lost (acked = false).
+	// DATAGRAM frames are never retransmitted, so this allows the application to retransmit lost messages.
+	// The callback is called at most once. It is not called if the session is closed before the packet was
+	// acknowledged or declared lost.
+	// The callback is called from the session's run loop, so it must not block.
+	SendMessageWithCallback(msg []byte, callback func(acked bool)) error
 	// ReceiveMessage gets a message received in a datagram.
+	// It blocks until a message is received, or the session is closed.
 	// See https://datatracker.ietf.org/doc/draft-pauly-quic-datagram/.
 	ReceiveMessage() ([]byte, error)
+	// ReceiveMessageContext is like ReceiveMessage, but also returns when the context is canceled.
+	ReceiveMessageContext(context.Context) ([]byte, error)
+	// ReceiveMessageFrom is like ReceiveMessageContext, but also returns the address the message was received from.
+	// This is the session's RemoteAddr, unless the peer is migrating to a new address:
+	// messages can then be received from the new address before the session switches to it.
+	ReceiveMessageFrom(context.Context) ([]byte, net.Addr, error)
+
+	// Stats returns statistics about the session.
+	// It is safe to call Stats concurrently with all other methods, e.g. from a monitoring goroutine.
+	Stats() SessionStats
+
+	// Events returns a channel on which lifecycle events of the session are delivered.
+	// The channel is buffered. Events are dropped if the application doesn't read them fast enough.
+	// The channel is closed when the session is closed.
+	// Warning: This API should not be considered stable and might change soon.
+	Events() <-chan Event
 }
 
 // An EarlySession is a session that is handshaking.
@@ -232,6 +385,18 @@ type Config struct {
 	// If the timeout is exceeded, the connection is closed.
 	// If this value is zero, the timeout is set to 30 seconds.
 	MaxIdleTimeout time.Duration
+	// SendStallTimeout is the maximum duration that may pass without the peer acknowledging new packets,
+	// while ack-eliciting packets are awaiting acknowledgement.
+	// It allows detecting dead peers faster than the idle timeout, which is reset by every packet received.
+	// This value only applies after the handshake has completed.
+	// If the timeout is exceeded, the connection is closed with ErrSendStallTimeout.
+	// If this value is zero, the send stall timeout is disabled.
+	SendStallTimeout time.Duration
+	// StreamLimitTimeout is the maximum duration that OpenStreamSync and OpenUniStreamSync block
+	// waiting for the peer to increase its stream limit.
+	// If the timeout is exceeded, they return an error that wraps ErrStreamLimitNotIncreased.
+	// If this value is zero, they block until the context is canceled.
+	StreamLimitTimeout time.Duration
 	// AcceptToken determines if a Token is accepted.
 	// It is called with token = nil if the client didn't send a token.
 	// If not set, a default verification function is used:
@@ -240,6 +405,17 @@ type Config struct {
 	//   * else, that it was issued within the last 24 hours.
 	// This option is only valid for the server.
 	AcceptToken func(clientAddr net.Addr, token *Token) bool
+	// ConnectionAttemptRate is the number of new connection attempts per second that are accepted
+	// from a single source prefix (a /24 for IPv4, a /48 for IPv6).
+	// Attempts exceeding this rate are first answered with a Retry packet, which doesn't allocate any state.
+	// If the rate is exceeded by more than ConnectionAttemptBurst attempts, Initial packets are dropped.
+	// If this value is zero, connection attempts are not rate limited.
+	// This option is only valid for the server.
+	ConnectionAttemptRate int
+	// ConnectionAttemptBurst is the number of connection attempts that are accepted from a single source prefix
+	// before ConnectionAttemptRate takes effect.
+	// If this value is zero, it defaults to ConnectionAttemptRate.
+	ConnectionAttemptBurst int
 	// The TokenStore stores tokens received from the server.
 	// Tokens are used to skip address validation on future connection attempts.
 	// The key used to store tokens is the ServerName from the tls.Config, if set
@@ -251,6 +427,35 @@ type Config struct {
 	// MaxReceiveConnectionFlowControlWindow is the connection-level flow control window for receiving data.
 	// If this value is zero, it will default to 1.5 MB for the server and 15 MB for the client.
 	MaxReceiveConnectionFlowControlWindow uint64
+	// MaxReceiveUniStreamFlowControlWindow is the stream-level flow control window for receiving data
+	// on unidirectional streams opened by the peer.
+	// This is useful for application protocols that only use unidirectional streams for low-volume control data,
+	// since it reduces the amount of memory that each of these streams can consume.
+	// If this value is zero, unidirectional streams use the same flow control window as bidirectional streams.
+	MaxReceiveUniStreamFlowControlWindow uint64
+	// InitialStreamReceiveWindow is the initial stream-level flow control window for receiving data, for all types of streams.
+	// It is used unless a window is configured for the respective type of stream (see below).
+	// On high-BDP links, a larger initial window avoids waiting for auto-tuning to increase the window,
+	// up to MaxReceiveStreamFlowControlWindow.
+	// If this value is zero, it will default to 512 KB.
+	InitialStreamReceiveWindow uint64
+	// InitialConnectionReceiveWindow is the initial connection-level flow control window for receiving data.
+	// It is advertised in the initial_max_data transport parameter.
+	// Auto-tuning increases the window up to MaxReceiveConnectionFlowControlWindow.
+	// If this value is zero, it will default to 768 KB.
+	InitialConnectionReceiveWindow uint64
+	// InitialStreamReceiveWindowBidiLocal is the initial stream-level flow control window for receiving data
+	// on bidirectional streams opened by us. It is advertised in the initial_max_stream_data_bidi_local transport parameter.
+	// If this value is zero, it will default to InitialStreamReceiveWindow.
+	InitialStreamReceiveWindowBidiLocal uint64
+	// InitialStreamReceiveWindowBidiRemote is the initial stream-level flow control window for receiving data
+	// on bidirectional streams opened by the peer. It is advertised in the initial_max_stream_data_bidi_remote transport parameter.
+	// If this value is zero, it will default to InitialStreamReceiveWindow.
+	InitialStreamReceiveWindowBidiRemote uint64
+	// InitialStreamReceiveWindowUni is the initial stream-level flow control window for receiving data
+	// on unidirectional streams opened by the peer. It is advertised in the initial_max_stream_data_uni transport parameter.
+	// If this value is zero, it will default to InitialStreamReceiveWindow, or to MaxReceiveUniStreamFlowControlWindow, if that is smaller.
+	InitialStreamReceiveWindowUni uint64
 	// MaxIncomingStreams is the maximum number of concurrent bidirectional streams that a peer is allowed to open.
 	// Values above 2^60 are invalid.
 	// If not set, it will default to 100.
@@ -261,27 +466,323 @@ type Config struct {
 	// If not set, it will default to 100.
 	// If set to a negative value, it doesn't allow any unidirectional streams.
 	MaxIncomingUniStreams int64
+	// MaxConnectionBytes is the maximum number of bytes that can be transferred on a connection,
+	// counting both the data sent and received on all streams.
+	// When this limit is exceeded, the connection is closed with MaxConnectionBytesErrorCode.
+	// If zero, the number of bytes is not limited.
+	MaxConnectionBytes uint64
+	// MaxConnectionBytesErrorCode is the application error code used to close the connection
+	// when MaxConnectionBytes is exceeded.
+	MaxConnectionBytesErrorCode ErrorCode
+	// MaxUnproductivePackets is the maximum number of packets per second that the peer may send
+	// without making any progress on the connection, i.e. packets that only contain ACK, PING and PADDING frames,
+	// and that don't acknowledge any new packets.
+	// This protects against peers that flood the connection with such packets.
+	// Occasional packets, e.g. PINGs sent to keep the connection alive, are not affected.
+	// When this limit is exceeded, the connection is closed with MaxUnproductivePacketsErrorCode.
+	// If zero, the number of such packets is not limited.
+	MaxUnproductivePackets uint64
+	// MaxUnproductivePacketsErrorCode is the application error code used to close the connection
+	// when MaxUnproductivePackets is exceeded.
+	MaxUnproductivePacketsErrorCode ErrorCode
+	// SendBufferHighWatermark is the amount of stream data that was sent, but not yet acknowledged by the peer,
+	// summed over all streams, at which an EventSendBufferAboveHighWatermark is delivered.
+	// This allows proxies to throttle the upstream when the connection can't keep up.
+	// If zero, no send buffer events are delivered.
+	SendBufferHighWatermark uint64
+	// SendBufferLowWatermark is the amount of unacknowledged stream data at which an EventSendBufferBelowLowWatermark
+	// is delivered, after the SendBufferHighWatermark was reached.
+	// It must not be larger than the SendBufferHighWatermark.
+	// If zero, it defaults to half of the SendBufferHighWatermark.
+	SendBufferLowWatermark uint64
+	// InitialPacketSize is the size of the UDP datagrams sent during the handshake.
+	// Datagrams containing an Initial packet are padded to this size,
+	// and no larger datagrams are sent until the handshake is confirmed.
+	// It must not be smaller than 1200 bytes, the minimum size required by the QUIC specification.
+	// Values larger than the maximum packet size for the path (1252 bytes for IPv4, 1232 bytes for IPv6) are reduced to it.
+	// If zero, it defaults to 1200 bytes.
+	InitialPacketSize uint16
+	// MaxPacketSize is the upper bound for the size of UDP datagrams sent after the handshake is confirmed.
+	// Once the handshake is confirmed, path MTU discovery probes for larger packet sizes, up to this value.
+	// A larger size is only used once a probe packet of that size was acknowledged by the peer.
+	// It must not be smaller than 1200 bytes.
+	// Values larger than 1452 bytes are reduced to it.
+	// Values that don't exceed the maximum packet size for the path (1252 bytes for IPv4, 1232 bytes for IPv6)
+	// disable path MTU discovery.
+	// Path MTU discovery requires setting the don't-fragment (DF) bit on the socket. This is currently only
+	// supported on Linux and Windows, and only if the net.PacketConn is a *net.UDPConn.
+	// The DF bit is only set if path MTU discovery is enabled.
+	// On other platforms, path MTU discovery is disabled.
+	// If zero, it defaults to 1452 bytes.
+	MaxPacketSize uint16
+	// NewCongestionController is called to create the congestion controller for a connection,
+	// and again when the connection migrates to a new path.
+	// The RTTStats are shared with the loss detection of the connection, and must not be modified.
+	// If nil, Cubic (in Reno mode) is used.
+	NewCongestionController func(rttStats *congestion.RTTStats) congestion.Controller
 	// The StatelessResetKey is used to generate stateless reset tokens.
 	// If no key is configured, sending of stateless resets is disabled.
 	StatelessResetKey []byte
+	// UnknownPacketHandler is called for packets received on the net.PacketConn that aren't QUIC packets,
+	// i.e. packets that don't have the QUIC fixed bit set, or short header packets that don't belong to any connection.
+	// This allows multiplexing QUIC with other protocols (e.g. STUN) on a single UDP socket, see RFC 7983.
+	// The data is only valid until the callback returns.
+	// Since the net.PacketConn might be shared between multiple servers and clients, the handler applies to all of them.
+	// Packets are processed sequentially, so the callback should return quickly.
+	UnknownPacketHandler func(data []byte, addr net.Addr)
+	// MaxPaths is the maximum number of paths tracked simultaneously, including the path currently in use.
+	// Every path that is being probed, either by us or by the peer, consumes resources,
+	// e.g. for enforcing the amplification limit on new peer addresses.
+	// When the limit is reached, the server stops tracking the oldest path probed by the peer.
+	// Before switching to a new peer address, the server validates it, see RFC 9000, section 9.3.
+	// If set to 1, the client can't migrate, and the server can't enable active migration.
+	// The server then neither responds to probes from new addresses, nor follows the peer to a new address,
+	// e.g. after a NAT rebinding.
+	// If this value is zero, it will default to 4.
+	MaxPaths int
+	// EnableActiveMigration allows the client to migrate the connection to a new address, see RFC 9000, section 9.
+	// It is only valid for the server. By default, the server sends the disable_active_migration transport parameter.
+	// Independent of this setting, the server follows the client to a new address after a NAT rebinding.
+	// Active migration requires MaxPaths to be at least 2.
+	EnableActiveMigration bool
 	// KeepAlive defines whether this peer will periodically send a packet to keep the connection alive.
 	KeepAlive bool
+	// CloseOnIdle closes the connection as soon as the last open stream (of any type) is closed.
+	// This is useful for request / response protocols that don't reuse connections.
+	// Note that the connection is not closed before the first stream was opened.
+	CloseOnIdle bool
+	// EnableFairStreamScheduling enables deficit round robin scheduling of streams.
+	// By default, streams are scheduled round robin, with every stream getting one turn per packet,
+	// regardless of how much data it sends in that turn.
+	// With deficit round robin scheduling, every stream gets the same share of the bandwidth,
+	// at the cost of slightly more overhead when assembling packets.
+	EnableFairStreamScheduling bool
+	// DisableKeyUpdate prevents us from initiating updates of the 1-RTT keys.
+	// Key updates initiated by the peer are still processed.
+	// This is useful for interoperability with implementations that don't support key updates.
+	// Note that on very long connections, this risks exceeding the confidentiality limit of the AEAD,
+	// i.e. the number of packets that can safely be encrypted with a single key.
+	DisableKeyUpdate bool
+	// DisablePacing disables pacing of outgoing packets.
+	// By default, packets are spaced out according to the bandwidth estimate of the congestion controller,
+	// instead of sending a full congestion window in a burst.
+	// This is only useful for benchmarking.
+	DisablePacing bool
+	// EnableRawTransportParameters makes the raw transport parameters received from the peer
+	// available in the ConnectionState. This is useful for debugging interoperability issues.
+	EnableRawTransportParameters bool
 	// See https://datatracker.ietf.org/doc/draft-ietf-quic-datagram/.
 	// Datagrams will only be available when both peers enable datagram support.
 	EnableDatagrams bool
 	Tracer          logging.Tracer
+	// QlogDir is the directory that qlog files are written to, one file per connection.
+	// The directory is created if it doesn't exist yet.
+	// The qlog files are written in addition to any events traced by the Tracer.
+	QlogDir string
+	// Logger is used to log diagnostic messages.
+	// The Logger decides which messages are logged, and is responsible for filtering by log level.
+	// Note that debug messages are generated for every packet sent and received.
+	// Many of these messages are only generated if the Logger has a Debug() bool method that returns true.
+	// If nil, messages are logged using the log package, at the log level set by the QUIC_GO_LOG_LEVEL environment variable
+	// (by default, nothing is logged).
+	// Messages about the net.PacketConn, which might be shared between multiple servers and clients, are always logged that way.
+	Logger Logger
+}
+
+// A Logger logs diagnostic messages.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
 }
 
 // ConnectionState records basic details about a QUIC connection
 type ConnectionState struct {
 	TLS               handshake.ConnectionState
 	SupportsDatagrams bool
+	// DroppedDatagrams is the number of received datagrams that were dropped,
+	// because the application didn't call ReceiveMessage fast enough.
+	// When the receive queue is full, the oldest datagram is dropped.
+	DroppedDatagrams uint64
+	// FlowControl is the current flow control state.
+	// It can be used to diagnose stalls caused by flow control.
+	FlowControl FlowControlState
+	// CreationTime is the time when the session was created.
+	CreationTime time.Time
+	// OriginalDestinationConnectionID is the destination connection ID of the first Initial packet sent by the client.
+	// It is the connection ID passed to Tracer.TracerForConnection, and can be used
+	// to correlate a session with qlogs and packet captures.
+	OriginalDestinationConnectionID logging.ConnectionID
+	// CongestionControl is the name of the congestion control algorithm, "reno" for the default congestion controller.
+	// Controllers created by Config.NewCongestionController report their name by implementing congestion.NamedController,
+	// otherwise it is empty.
+	CongestionControl string
+	// HandshakeRoundTrips is the number of round trips the handshake took.
+	// It is 1 for a regular handshake. Every Retry, Version Negotiation and HelloRetryRequest
+	// adds a round trip, so values larger than 1 indicate a slow connection setup.
+	// Version Negotiation is only taken into account by the client.
+	HandshakeRoundTrips int
+	// PeerActiveConnectionIDLimit is the active_connection_id_limit transport parameter sent by the peer,
+	// i.e. the number of connection IDs the peer is willing to store.
+	// It limits the number of connection IDs issued to the peer, and therefore how often the peer can
+	// switch to a new connection ID, e.g. when migrating to a new path.
+	// It is 0 if the peer didn't send the parameter, in which case the default value of 2 applies.
+	PeerActiveConnectionIDLimit uint64
+	// RawPeerTransportParameters are the transport parameters received from the peer, as they were sent on the wire.
+	// It is only set if Config.EnableRawTransportParameters is set.
+	RawPeerTransportParameters []byte
+}
+
+// PacketLossState contains the number of lost and retransmitted packets, per encryption level.
+// Warning: This API should not be considered stable and might change soon.
+type PacketLossState struct {
+	Initial   PacketLossCounts
+	Handshake PacketLossCounts
+	// OneRTT also counts 0-RTT packets, since they use the same packet number space.
+	OneRTT PacketLossCounts
+}
+
+// LargestAckedState contains the largest acknowledged packet number, per encryption level.
+// The value is -1 if no packet has been acknowledged at that encryption level.
+// Warning: This API should not be considered stable and might change soon.
+type LargestAckedState struct {
+	Initial   logging.PacketNumber
+	Handshake logging.PacketNumber
+	// OneRTT also includes 0-RTT packets, since they use the same packet number space.
+	OneRTT logging.PacketNumber
+}
+
+// PacketLossCounts contains the number of lost and retransmitted packets at an encryption level.
+type PacketLossCounts struct {
+	// LostPackets is the number of packets that were declared lost.
+	LostPackets uint64
+	// RetransmittedPackets is the number of packets whose frames were queued for retransmission.
+	// In addition to lost packets, this includes packets that were retransmitted when sending a probe packet,
+	// and packets that were retransmitted after receiving a Retry.
+	RetransmittedPackets uint64
+}
+
+// EventType is the type of an Event.
+type EventType uint8
+
+const (
+	// EventHandshakeComplete is delivered when the handshake completes.
+	EventHandshakeComplete EventType = 1 + iota
+	// EventKeyUpdated is delivered when the 1-RTT keys are updated, no matter which peer initiated the key update.
+	EventKeyUpdated
+	// EventDatagramReceived is delivered when a DATAGRAM frame is received.
+	// The datagram can be read using ReceiveMessage.
+	EventDatagramReceived
+	// EventPeerStreamLimitChanged is delivered when the peer allows us to open more streams.
+	EventPeerStreamLimitChanged
+	// EventSendBufferAboveHighWatermark is delivered when the amount of unacknowledged stream data
+	// reaches Config.SendBufferHighWatermark.
+	EventSendBufferAboveHighWatermark
+	// EventSendBufferBelowLowWatermark is delivered when the amount of unacknowledged stream data
+	// drops to Config.SendBufferLowWatermark, after an EventSendBufferAboveHighWatermark.
+	EventSendBufferBelowLowWatermark
+	// EventPathMigrated is delivered when the connection was migrated to a new path,
+	// either because we migrated (see Session.MigrateTo), or because the peer did.
+	EventPathMigrated
+)
+
+// An Event is a lifecycle event of a session.
+// Warning: This API should not be considered stable and might change soon.
+type Event struct {
+	Type EventType
+	// Unidirectional says if the new stream limit applies to unidirectional streams.
+	// Only set for EventPeerStreamLimitChanged.
+	Unidirectional bool
+	// MaxStreams is the maximum number of streams we're now allowed to open.
+	// Only set for EventPeerStreamLimitChanged.
+	MaxStreams uint64
+	// LocalAddr and RemoteAddr are the addresses of the new path.
+	// Only set for EventPathMigrated.
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+}
+
+// FlowControlState is a snapshot of the flow control windows of a connection.
+// All values are given in bytes.
+// Warning: This API should not be considered stable and might change soon.
+type FlowControlState struct {
+	// SendWindow is the amount of data the peer currently allows us to send on the connection.
+	SendWindow uint64
+	// ReceiveWindow is the amount of data we currently allow the peer to send on the connection.
+	ReceiveWindow uint64
+	// StreamSendWindows is the sum of the stream-level send windows of all open streams.
+	StreamSendWindows uint64
+	// StreamReceiveWindows is the sum of the stream-level receive windows of all open streams.
+	StreamReceiveWindows uint64
+}
+
+// SessionStats contains statistics about a session.
+// It is a snapshot, taken when Session.Stats is called.
+type SessionStats struct {
+	// BytesSent is the number of bytes sent in QUIC packets, including coalesced packets.
+	BytesSent uint64
+	// PacketsSent is the number of QUIC packets sent.
+	// Coalesced packets are counted individually.
+	PacketsSent uint64
+	// BytesReceived is the number of bytes received, including packets that couldn't be processed.
+	BytesReceived uint64
+	// PacketsReceived is the number of QUIC packets that were received and processed.
+	PacketsReceived uint64
+	// RetransmittedPackets is the number of packets whose frames were queued for retransmission,
+	// summed over all encryption levels.
+	RetransmittedPackets uint64
+	// PacketLoss contains the number of lost and retransmitted packets, per encryption level.
+	// Loss during the handshake is a common cause of slow connection establishment.
+	PacketLoss PacketLossState
+	// LargestAcked contains the largest packet number acknowledged by the peer, per encryption level.
+	// It can be used to diagnose if ACKs are received for Handshake and 1-RTT packets,
+	// e.g. when a connection stalls during the handshake.
+	LargestAcked LargestAckedState
+	// SmoothedRTT is the smoothed round-trip time.
+	SmoothedRTT time.Duration
+	// LatestRTT is the most recent round-trip time sample.
+	LatestRTT time.Duration
+	// CongestionWindow is the size of the congestion window, in bytes.
+	CongestionWindow uint64
+	// HandshakeDuration is the time from the creation of the session until the handshake was confirmed.
+	// For the client, this is the time since the first Initial packet was sent,
+	// including the round trips caused by Retry and HelloRetryRequest.
+	// It is zero until the handshake is confirmed.
+	HandshakeDuration time.Duration
+	// PathMTU is the maximum size of the UDP datagrams currently sent on the path.
+	// It grows when path MTU discovery confirms a larger size.
+	// It is zero until the handshake is confirmed.
+	PathMTU uint64
+	// OpenStreams is the number of streams that are currently open.
+	// Streams of all types, opened by both endpoints, are counted.
+	OpenStreams int
+	// MaxConcurrentStreams is the highest number of streams that were open at the same time.
+	// Streams of all types, opened by both endpoints, are counted.
+	// It can be used to check if the stream limits are being hit.
+	MaxConcurrentStreams int
+	// Paths are the paths that are currently tracked.
+	// The first path is the path currently in use, followed by the paths that are being probed.
+	// The number of paths is limited by Config.MaxPaths.
+	Paths []PathInfo
+}
+
+// PathInfo describes a network path of a session.
+type PathInfo struct {
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// Validated is false for paths that are being probed, and not used for sending application data yet.
+	Validated bool
 }
 
 // A Listener for incoming QUIC connections
 type Listener interface {
 	// Close the server. All active sessions will be closed.
 	Close() error
+	// CloseGracefully stops accepting new sessions, and waits until all active sessions have closed.
+	// Active sessions don't allow the peer to open new streams,
+	// and are closed as soon as all of their streams have completed.
+	// When the context expires, the remaining sessions are closed with a CONNECTION_CLOSE.
+	CloseGracefully(context.Context) error
 	// Addr returns the local network addr that the server is listening on.
 	Addr() net.Addr
 	// Accept returns new sessions. It should be called in a loop.
@@ -293,6 +794,9 @@ type Listener interface {
 type EarlyListener interface {
 	// Close the server. All active sessions will be closed.
 	Close() error
+	// CloseGracefully stops accepting new sessions, and waits until all active sessions have closed.
+	// See Listener.CloseGracefully for details.
+	CloseGracefully(context.Context) error
 	// Addr returns the local network addr that the server is listening on.
 	Addr() net.Addr
 	// Accept returns new early sessions. It should be called in a loop.
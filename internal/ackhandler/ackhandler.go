@@ -1,12 +1,14 @@
 package ackhandler
 
 import (
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/logging"
 )
 
-// NewAckHandler creates a new SentPacketHandler and a new ReceivedPacketHandler
+// NewAckHandler creates a new SentPacketHandler and a new ReceivedPacketHandler.
+// If newCongestionController is nil, Cubic (in Reno mode) is used for congestion control.
 func NewAckHandler(
 	initialPacketNumber protocol.PacketNumber,
 	rttStats *utils.RTTStats,
@@ -14,7 +16,8 @@ func NewAckHandler(
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 	version protocol.VersionNumber,
+	newCongestionController func(*utils.RTTStats) congestion.Controller,
 ) (SentPacketHandler, ReceivedPacketHandler) {
-	sph := newSentPacketHandler(initialPacketNumber, rttStats, pers, tracer, logger)
+	sph := newSentPacketHandler(initialPacketNumber, rttStats, pers, newCongestionController, tracer, logger)
 	return sph, newReceivedPacketHandler(sph, rttStats, logger, version)
 }
@@ -16,6 +16,8 @@ type Packet struct {
 	EncryptionLevel protocol.EncryptionLevel
 	SendTime        time.Time
 
+	IsPathMTUProbePacket bool // We don't report the loss of Path MTU probe packets to the congestion controller.
+
 	includedInBytesInFlight bool
 	declaredLost            bool
 	skippedPacket           bool
@@ -30,6 +32,9 @@ type SentPacketHandler interface {
 	DropPackets(protocol.EncryptionLevel)
 	ResetForRetry() error
 	SetHandshakeConfirmed()
+	// MigratedPath resets the RTT estimate and the congestion controller
+	// when the connection migrates to a new path.
+	MigratedPath()
 
 	// The SendMode determines if and what kind of packets can be sent.
 	SendMode() SendMode
@@ -47,6 +52,24 @@ type SentPacketHandler interface {
 
 	GetLossDetectionTimeout() time.Time
 	OnLossDetectionTimeout() error
+	// PTOCount is the number of consecutive PTOs.
+	// It is reset when an acknowledgement for a new packet is received.
+	PTOCount() uint32
+	// LossCounts returns the number of lost packets, and the number of packets whose frames were
+	// queued for retransmission, in the packet number space of the encryption level.
+	// It is safe to call concurrently.
+	LossCounts(protocol.EncryptionLevel) (lost, retransmitted uint64)
+	// LargestAcked returns the largest packet number acknowledged by the peer in the packet number space
+	// of the encryption level, or protocol.InvalidPacketNumber if no packet has been acknowledged yet.
+	// It is safe to call concurrently.
+	LargestAcked(protocol.EncryptionLevel) protocol.PacketNumber
+	// CongestionControl returns the name of the congestion control algorithm.
+	// It is empty if the congestion controller doesn't implement congestion.NamedController.
+	// It is safe to call concurrently.
+	CongestionControl() string
+	// CongestionWindow returns the current congestion window.
+	// It is safe to call concurrently.
+	CongestionWindow() protocol.ByteCount
 }
 
 type sentPacketTracker interface {
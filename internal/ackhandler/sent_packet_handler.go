@@ -3,6 +3,7 @@ package ackhandler
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/congestion"
@@ -50,6 +51,15 @@ func newPacketNumberSpace(initialPN protocol.PacketNumber, skipPNs bool, rttStat
 }
 
 type sentPacketHandler struct {
+	// The number of lost and retransmitted packets, and the largest acknowledged packet number,
+	// indexed by packet number space, and a copy of the congestion window.
+	// They are accessed atomically, and need to be at the beginning of the struct
+	// to guarantee 64 bit alignment on 32 bit platforms.
+	lostPackets          [3]uint64
+	retransmittedPackets [3]uint64
+	largestAckedPackets  [3]int64
+	congestionWindow     uint64
+
 	initialPackets   *packetNumberSpace
 	handshakePackets *packetNumberSpace
 	appDataPackets   *packetNumberSpace
@@ -62,6 +72,9 @@ type sentPacketHandler struct {
 	// Have we validated the peer's address yet?
 	// Always true for the client.
 	peerAddressValidated bool
+	// Are we blocked by the anti-amplification limit?
+	// Only used for tracing.
+	amplificationLimited bool
 
 	handshakeConfirmed bool
 
@@ -75,8 +88,13 @@ type sentPacketHandler struct {
 
 	bytesInFlight protocol.ByteCount
 
-	congestion congestion.SendAlgorithmWithDebugInfos
-	rttStats   *utils.RTTStats
+	newCongestionController func(*utils.RTTStats) congestion.Controller
+	congestion              congestion.Controller
+	rttStats                *utils.RTTStats
+
+	// The name of the congestion control algorithm, as reported by the first congestion controller.
+	// It doesn't change afterwards, so it can be read concurrently.
+	congestionControl string
 
 	// The number of times a PTO has been sent without receiving an ack.
 	ptoCount uint32
@@ -103,28 +121,41 @@ func newSentPacketHandler(
 	initialPN protocol.PacketNumber,
 	rttStats *utils.RTTStats,
 	pers protocol.Perspective,
+	newCongestionController func(*utils.RTTStats) congestion.Controller,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
 ) *sentPacketHandler {
-	congestion := congestion.NewCubicSender(
-		congestion.DefaultClock{},
-		rttStats,
-		true, // use Reno
-		tracer,
-	)
-
-	return &sentPacketHandler{
+	h := &sentPacketHandler{
+		largestAckedPackets:            [3]int64{int64(protocol.InvalidPacketNumber), int64(protocol.InvalidPacketNumber), int64(protocol.InvalidPacketNumber)},
 		peerCompletedAddressValidation: pers == protocol.PerspectiveServer,
 		peerAddressValidated:           pers == protocol.PerspectiveClient,
 		initialPackets:                 newPacketNumberSpace(initialPN, false, rttStats),
 		handshakePackets:               newPacketNumberSpace(0, false, rttStats),
 		appDataPackets:                 newPacketNumberSpace(0, true, rttStats),
 		rttStats:                       rttStats,
-		congestion:                     congestion,
+		newCongestionController:        newCongestionController,
 		perspective:                    pers,
 		tracer:                         tracer,
 		logger:                         logger,
 	}
+	h.congestion = h.createCongestionController()
+	if named, ok := h.congestion.(congestion.NamedController); ok {
+		h.congestionControl = named.Name()
+	}
+	h.updateCongestionWindow()
+	return h
+}
+
+func (h *sentPacketHandler) createCongestionController() congestion.Controller {
+	if h.newCongestionController != nil {
+		return h.newCongestionController(h.rttStats)
+	}
+	return congestion.NewCubicSender(
+		congestion.DefaultClock{},
+		h.rttStats,
+		true, // use Reno
+		h.tracer,
+	)
 }
 
 func (h *sentPacketHandler) DropPackets(encLevel protocol.EncryptionLevel) {
@@ -196,11 +227,13 @@ func (h *sentPacketHandler) dropPackets(encLevel protocol.EncryptionLevel) {
 
 func (h *sentPacketHandler) ReceivedBytes(n protocol.ByteCount) {
 	h.bytesReceived += n
+	h.updateAmplificationLimited()
 }
 
 func (h *sentPacketHandler) ReceivedPacket(encLevel protocol.EncryptionLevel) {
 	if h.perspective == protocol.PerspectiveServer && encLevel == protocol.EncryptionHandshake {
 		h.peerAddressValidated = true
+		h.updateAmplificationLimited()
 	}
 }
 
@@ -217,6 +250,7 @@ func (h *sentPacketHandler) packetsInFlight() int {
 
 func (h *sentPacketHandler) SentPacket(packet *Packet) {
 	h.bytesSent += packet.Length
+	h.updateAmplificationLimited()
 	// For the client, drop the Initial packet number space when the first Handshake packet is sent.
 	if h.perspective == protocol.PerspectiveClient && packet.EncryptionLevel == protocol.EncryptionHandshake && h.initialPackets != nil {
 		h.dropPackets(protocol.EncryptionInitial)
@@ -278,6 +312,7 @@ func (h *sentPacketHandler) ReceivedAck(ack *wire.AckFrame, encLevel protocol.En
 	}
 
 	pnSpace.largestAcked = utils.MaxPacketNumber(pnSpace.largestAcked, largestAcked)
+	atomic.StoreInt64(&h.largestAckedPackets[pnSpaceIndex(encLevel)], int64(pnSpace.largestAcked))
 
 	// Servers complete address validation when a protected packet is received.
 	if h.perspective == protocol.PerspectiveClient && !h.peerCompletedAddressValidation &&
@@ -317,6 +352,7 @@ func (h *sentPacketHandler) ReceivedAck(ack *wire.AckFrame, encLevel protocol.En
 		}
 		if p.includedInBytesInFlight && !p.declaredLost {
 			h.congestion.OnPacketAcked(p.PacketNumber, p.Length, priorInFlight, rcvTime)
+			h.updateCongestionWindow()
 		}
 		h.removeFromBytesInFlight(p)
 	}
@@ -557,7 +593,12 @@ func (h *sentPacketHandler) detectLostPackets(now time.Time, encLevel protocol.E
 			pnSpace.lossTime = lossTime
 		}
 		if packetLost {
-			h.congestion.OnPacketLost(p.PacketNumber, p.Length, priorInFlight)
+			atomic.AddUint64(&h.lostPackets[pnSpaceIndex(p.EncryptionLevel)], 1)
+			// The loss of a path MTU probe packet doesn't indicate congestion (RFC 9000, Section 14.4).
+			if !p.IsPathMTUProbePacket {
+				h.congestion.OnPacketLost(p.PacketNumber, p.Length, priorInFlight)
+				h.updateCongestionWindow()
+			}
 			p.declaredLost = true
 			h.queueFramesForRetransmission(p)
 			// the bytes in flight need to be reduced no matter if this packet will be retransmitted
@@ -635,6 +676,10 @@ func (h *sentPacketHandler) onVerifiedLossDetectionTimeout() error {
 	return nil
 }
 
+func (h *sentPacketHandler) PTOCount() uint32 {
+	return h.ptoCount
+}
+
 func (h *sentPacketHandler) GetLossDetectionTimeout() time.Time {
 	return h.alarm
 }
@@ -714,6 +759,19 @@ func (h *sentPacketHandler) isAmplificationLimited() bool {
 	return h.bytesSent >= amplificationFactor*h.bytesReceived
 }
 
+// updateAmplificationLimited traces when we become blocked by the anti-amplification limit,
+// and when we're unblocked again.
+func (h *sentPacketHandler) updateAmplificationLimited() {
+	limited := h.isAmplificationLimited()
+	if limited == h.amplificationLimited {
+		return
+	}
+	h.amplificationLimited = limited
+	if h.tracer != nil {
+		h.tracer.UpdatedAmplificationLimited(limited)
+	}
+}
+
 func (h *sentPacketHandler) QueueProbePacket(encLevel protocol.EncryptionLevel) bool {
 	pnSpace := h.getPacketNumberSpace(encLevel)
 	p := pnSpace.history.FirstOutstanding()
@@ -736,6 +794,47 @@ func (h *sentPacketHandler) queueFramesForRetransmission(p *Packet) {
 		f.OnLost(f.Frame)
 	}
 	p.Frames = nil
+	atomic.AddUint64(&h.retransmittedPackets[pnSpaceIndex(p.EncryptionLevel)], 1)
+}
+
+func (h *sentPacketHandler) LossCounts(encLevel protocol.EncryptionLevel) (lost, retransmitted uint64) {
+	i := pnSpaceIndex(encLevel)
+	return atomic.LoadUint64(&h.lostPackets[i]), atomic.LoadUint64(&h.retransmittedPackets[i])
+}
+
+func (h *sentPacketHandler) LargestAcked(encLevel protocol.EncryptionLevel) protocol.PacketNumber {
+	return protocol.PacketNumber(atomic.LoadInt64(&h.largestAckedPackets[pnSpaceIndex(encLevel)]))
+}
+
+func (h *sentPacketHandler) CongestionControl() string {
+	return h.congestionControl
+}
+
+func (h *sentPacketHandler) CongestionWindow() protocol.ByteCount {
+	return protocol.ByteCount(atomic.LoadUint64(&h.congestionWindow))
+}
+
+func (h *sentPacketHandler) MigratedPath() {
+	h.rttStats.OnConnectionMigration()
+	h.congestion = h.createCongestionController()
+	h.updateCongestionWindow()
+}
+
+func (h *sentPacketHandler) updateCongestionWindow() {
+	atomic.StoreUint64(&h.congestionWindow, uint64(h.congestion.GetCongestionWindow()))
+}
+
+func pnSpaceIndex(encLevel protocol.EncryptionLevel) int {
+	switch encLevel {
+	case protocol.EncryptionInitial:
+		return 0
+	case protocol.EncryptionHandshake:
+		return 1
+	case protocol.Encryption0RTT, protocol.Encryption1RTT:
+		return 2
+	default:
+		panic("invalid packet number space")
+	}
 }
 
 func (h *sentPacketHandler) ResetForRetry() error {
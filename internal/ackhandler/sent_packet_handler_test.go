@@ -5,7 +5,9 @@ import (
 	"time"
 
 	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go/internal/congestion"
 	"github.com/lucas-clemente/quic-go/internal/mocks"
+	mocklogging "github.com/lucas-clemente/quic-go/internal/mocks/logging"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 	"github.com/lucas-clemente/quic-go/internal/utils"
 	"github.com/lucas-clemente/quic-go/internal/wire"
@@ -27,7 +29,7 @@ var _ = Describe("SentPacketHandler", func() {
 	JustBeforeEach(func() {
 		lostPackets = nil
 		rttStats := utils.NewRTTStats()
-		handler = newSentPacketHandler(42, rttStats, perspective, nil, utils.DefaultLogger)
+		handler = newSentPacketHandler(42, rttStats, perspective, nil, nil, utils.DefaultLogger)
 		streamFrame = wire.StreamFrame{
 			StreamID: 5,
 			Data:     []byte{0x13, 0x37},
@@ -171,6 +173,23 @@ var _ = Describe("SentPacketHandler", func() {
 				Expect(handler.appDataPackets.largestAcked).To(Equal(protocol.PacketNumber(4)))
 			})
 
+			It("tracks the largest acknowledged packet number", func() {
+				Expect(handler.LargestAcked(protocol.Encryption1RTT)).To(Equal(protocol.InvalidPacketNumber))
+				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 0, Largest: 3}}}
+				Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
+				Expect(handler.LargestAcked(protocol.Encryption1RTT)).To(Equal(protocol.PacketNumber(3)))
+				Expect(handler.LargestAcked(protocol.Encryption0RTT)).To(Equal(protocol.PacketNumber(3)))
+				ack = &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 5, Largest: 6}}}
+				Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
+				Expect(handler.LargestAcked(protocol.Encryption1RTT)).To(Equal(protocol.PacketNumber(6)))
+				// a reordered ACK doesn't decrease the largest acknowledged packet number
+				ack = &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 4, Largest: 4}}}
+				Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
+				Expect(handler.LargestAcked(protocol.Encryption1RTT)).To(Equal(protocol.PacketNumber(6)))
+				Expect(handler.LargestAcked(protocol.EncryptionInitial)).To(Equal(protocol.InvalidPacketNumber))
+				Expect(handler.LargestAcked(protocol.EncryptionHandshake)).To(Equal(protocol.InvalidPacketNumber))
+			})
+
 			It("rejects ACKs that acknowledge a skipped packet number", func() {
 				handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 100}))
 				handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 102}))
@@ -184,6 +203,19 @@ var _ = Describe("SentPacketHandler", func() {
 				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(10)))
 			})
 
+			It("rejects ACKs for a packet number just above the largest sent", func() {
+				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 10, Largest: 10}}}
+				Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(MatchError("PROTOCOL_VIOLATION: Received ACK for an unsent packet"))
+				Expect(handler.appDataPackets.largestAcked).To(Equal(protocol.InvalidPacketNumber))
+				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(10)))
+			})
+
+			It("rejects ACKs in a packet number space that no packets were sent in", func() {
+				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 0, Largest: 0}}}
+				Expect(handler.ReceivedAck(ack, protocol.EncryptionHandshake, time.Now())).To(MatchError("PROTOCOL_VIOLATION: Received ACK for an unsent packet"))
+				Expect(handler.bytesInFlight).To(Equal(protocol.ByteCount(10)))
+			})
+
 			It("ignores repeated ACKs", func() {
 				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 0, Largest: 3}}}
 				Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
@@ -451,6 +483,7 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 
 		It("should call MaybeExitSlowStart and OnPacketAcked", func() {
+			cong.EXPECT().GetCongestionWindow().AnyTimes()
 			rcvTime := time.Now().Add(-5 * time.Second)
 			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
 			gomock.InOrder(
@@ -466,6 +499,7 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 
 		It("doesn't call OnPacketAcked when a retransmitted packet is acked", func() {
+			cong.EXPECT().GetCongestionWindow().AnyTimes()
 			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
@@ -482,7 +516,32 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
 		})
 
+		It("doesn't call OnPacketLost when a path MTU probe packet is lost", func() {
+			cong.EXPECT().GetCongestionWindow().AnyTimes()
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+			var lost bool
+			handler.SentPacket(&Packet{
+				PacketNumber:         1,
+				SendTime:             time.Now().Add(-time.Hour),
+				IsPathMTUProbePacket: true,
+				Frames:               []Frame{{Frame: &wire.PingFrame{}, OnLost: func(wire.Frame) { lost = true }}},
+				Length:               1,
+				EncryptionLevel:      protocol.Encryption1RTT,
+			})
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			// lose packet 1, but don't EXPECT any call to OnPacketLost
+			gomock.InOrder(
+				cong.EXPECT().MaybeExitSlowStart(),
+				cong.EXPECT().OnPacketAcked(protocol.PacketNumber(2), protocol.ByteCount(1), protocol.ByteCount(2), gomock.Any()),
+			)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(lost).To(BeTrue())
+			Expect(handler.bytesInFlight).To(BeZero())
+		})
+
 		It("calls OnPacketAcked and OnPacketLost with the right bytes_in_flight value", func() {
+			cong.EXPECT().GetCongestionWindow().AnyTimes()
 			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(4)
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2, SendTime: time.Now().Add(-30 * time.Minute)}))
@@ -506,6 +565,24 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
 		})
 
+		It("tracks the congestion window", func() {
+			Expect(handler.CongestionWindow()).ToNot(BeZero())
+			cong.EXPECT().OnPacketSent(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(3)
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1, SendTime: time.Now().Add(-time.Hour)}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 2}))
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 3}))
+			cong.EXPECT().MaybeExitSlowStart()
+			cong.EXPECT().OnPacketLost(protocol.PacketNumber(1), gomock.Any(), gomock.Any())
+			cong.EXPECT().OnPacketAcked(protocol.PacketNumber(2), gomock.Any(), gomock.Any(), gomock.Any())
+			gomock.InOrder(
+				cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(1000)),
+				cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(2000)),
+			)
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 2}}}
+			Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(handler.CongestionWindow()).To(Equal(protocol.ByteCount(2000)))
+		})
+
 		It("passes the bytes in flight to the congestion controller", func() {
 			handler.ReceivedPacket(protocol.EncryptionHandshake)
 			cong.EXPECT().OnPacketSent(gomock.Any(), protocol.ByteCount(42), gomock.Any(), protocol.ByteCount(42), true)
@@ -599,6 +676,78 @@ var _ = Describe("SentPacketHandler", func() {
 		Expect(handler.SendMode()).To(Equal(SendAny))
 	})
 
+	It("reports the name of the default congestion controller", func() {
+		Expect(handler.CongestionControl()).To(Equal("reno"))
+	})
+
+	Context("path migration", func() {
+		It("resets the RTT estimate and the congestion controller", func() {
+			initialWindow := handler.CongestionWindow()
+			for i := protocol.PacketNumber(0); i < 30; i++ {
+				handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: i, Length: 1200, SendTime: time.Now().Add(-time.Second)}))
+			}
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 0, Largest: 29}}}
+			Expect(handler.ReceivedAck(ack, protocol.Encryption1RTT, time.Now())).To(Succeed())
+			Expect(handler.rttStats.SmoothedRTT()).ToNot(BeZero())
+			Expect(handler.CongestionWindow()).To(BeNumerically(">", initialWindow))
+			handler.MigratedPath()
+			Expect(handler.rttStats.SmoothedRTT()).To(BeZero())
+			Expect(handler.CongestionWindow()).To(Equal(initialWindow))
+		})
+	})
+
+	Context("using a custom congestion controller", func() {
+		var (
+			cong      *mocks.MockSendAlgorithmWithDebugInfos
+			rttStats  *utils.RTTStats
+			createdCC int
+		)
+
+		BeforeEach(func() { perspective = protocol.PerspectiveClient })
+
+		JustBeforeEach(func() {
+			createdCC = 0
+			cong = mocks.NewMockSendAlgorithmWithDebugInfos(mockCtrl)
+			rttStats = utils.NewRTTStats()
+			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(1337))
+			handler = newSentPacketHandler(
+				42,
+				rttStats,
+				perspective,
+				func(r *utils.RTTStats) congestion.Controller {
+					Expect(r).To(Equal(rttStats))
+					createdCC++
+					return cong
+				},
+				nil,
+				utils.DefaultLogger,
+			)
+		})
+
+		It("uses the congestion controller returned by the constructor", func() {
+			Expect(createdCC).To(Equal(1))
+			Expect(handler.CongestionWindow()).To(Equal(protocol.ByteCount(1337)))
+			cong.EXPECT().CanSend(protocol.ByteCount(0)).Return(false)
+			cong.EXPECT().GetCongestionWindow().AnyTimes()
+			Expect(handler.SendMode()).To(Equal(SendAck))
+			cong.EXPECT().TimeUntilSend(protocol.ByteCount(0)).Return(time.Now().Add(time.Hour))
+			Expect(handler.TimeUntilSend()).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+			cong.EXPECT().HasPacingBudget().Return(false)
+			Expect(handler.HasPacingBudget()).To(BeFalse())
+		})
+
+		It("doesn't report a name for congestion controllers that don't implement NamedController", func() {
+			Expect(handler.CongestionControl()).To(BeEmpty())
+		})
+
+		It("creates a new congestion controller when migrating to a new path", func() {
+			cong.EXPECT().GetCongestionWindow().Return(protocol.ByteCount(42))
+			handler.MigratedPath()
+			Expect(createdCC).To(Equal(2))
+			Expect(handler.CongestionWindow()).To(Equal(protocol.ByteCount(42)))
+		})
+	})
+
 	Context("probe packets", func() {
 		It("queues a probe packet", func() {
 			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 10}))
@@ -637,8 +786,10 @@ var _ = Describe("SentPacketHandler", func() {
 			Expect(handler.OnLossDetectionTimeout()).To(Succeed())
 			Expect(handler.SendMode()).To(Equal(SendPTOAppData))
 			Expect(handler.ptoCount).To(BeEquivalentTo(1))
+			Expect(handler.PTOCount()).To(BeEquivalentTo(1))
 			Expect(handler.ReceivedAck(&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}}, protocol.Encryption1RTT, time.Now())).To(Succeed())
 			Expect(handler.ptoCount).To(BeZero())
+			Expect(handler.PTOCount()).To(BeZero())
 		})
 
 		It("resets the PTO mode and PTO count when a packet number space is dropped", func() {
@@ -807,6 +958,40 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("tracing the amplification limit", func() {
+		var tracer *mocklogging.MockConnectionTracer
+
+		JustBeforeEach(func() {
+			tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
+			tracer.EXPECT().UpdatedMetrics(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedCongestionState(gomock.Any()).AnyTimes()
+			tracer.EXPECT().SetLossTimer(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().LossTimerCanceled().AnyTimes()
+			handler = newSentPacketHandler(0, utils.NewRTTStats(), protocol.PerspectiveServer, nil, tracer, utils.DefaultLogger)
+		})
+
+		It("traces when the server is blocked, and when it is unblocked by receiving more data", func() {
+			handler.ReceivedBytes(200)
+			tracer.EXPECT().UpdatedAmplificationLimited(true)
+			handler.SentPacket(initialPacket(&Packet{PacketNumber: 1, Length: 600}))
+			Expect(handler.SendMode()).To(Equal(SendNone))
+			tracer.EXPECT().UpdatedAmplificationLimited(false)
+			handler.ReceivedBytes(100)
+			Expect(handler.SendMode()).To(Equal(SendAny))
+		})
+
+		It("traces when the server is unblocked by validating the client's address", func() {
+			handler.ReceivedBytes(200)
+			tracer.EXPECT().UpdatedAmplificationLimited(true)
+			handler.SentPacket(initialPacket(&Packet{PacketNumber: 1, Length: 600}))
+			tracer.EXPECT().UpdatedAmplificationLimited(false)
+			handler.ReceivedPacket(protocol.EncryptionHandshake)
+			Expect(handler.SendMode()).To(Equal(SendAny))
+			// don't EXPECT any more calls after the address was validated
+			handler.SentPacket(handshakePacket(&Packet{PacketNumber: 1, Length: 600}))
+		})
+	})
+
 	Context("amplification limit", func() {
 		BeforeEach(func() {
 			perspective = protocol.PerspectiveClient
@@ -915,6 +1100,34 @@ var _ = Describe("SentPacketHandler", func() {
 		})
 	})
 
+	Context("counting lost packets", func() {
+		It("counts lost packets per encryption level", func() {
+			for i := protocol.PacketNumber(1); i <= 5; i++ {
+				handler.SentPacket(handshakePacket(&Packet{PacketNumber: i}))
+			}
+			ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 5, Largest: 5}}}
+			Expect(handler.ReceivedAck(ack, protocol.EncryptionHandshake, time.Now())).To(Succeed())
+			Expect(lostPackets).To(Equal([]protocol.PacketNumber{1, 2}))
+			lost, retransmitted := handler.LossCounts(protocol.EncryptionHandshake)
+			Expect(lost).To(BeEquivalentTo(2))
+			Expect(retransmitted).To(BeEquivalentTo(2))
+			lost, retransmitted = handler.LossCounts(protocol.EncryptionInitial)
+			Expect(lost).To(BeZero())
+			Expect(retransmitted).To(BeZero())
+			lost, retransmitted = handler.LossCounts(protocol.Encryption1RTT)
+			Expect(lost).To(BeZero())
+			Expect(retransmitted).To(BeZero())
+		})
+
+		It("counts packets retransmitted in probe packets", func() {
+			handler.SentPacket(ackElicitingPacket(&Packet{PacketNumber: 1}))
+			Expect(handler.QueueProbePacket(protocol.Encryption1RTT)).To(BeTrue())
+			lost, retransmitted := handler.LossCounts(protocol.Encryption1RTT)
+			Expect(lost).To(BeZero())
+			Expect(retransmitted).To(BeEquivalentTo(1))
+		})
+	})
+
 	Context("Delay-based loss detection", func() {
 		It("immediately detects old packets as lost when receiving an ACK", func() {
 			now := time.Now()
@@ -66,6 +66,8 @@ type cubicSender struct {
 var (
 	_ SendAlgorithm               = &cubicSender{}
 	_ SendAlgorithmWithDebugInfos = &cubicSender{}
+	_ Controller                  = &cubicSender{}
+	_ NamedController             = &cubicSender{}
 )
 
 // NewCubicSender makes a new cubic sender
@@ -98,6 +100,14 @@ func newCubicSender(clock Clock, rttStats *utils.RTTStats, reno bool, initialCon
 	return c
 }
 
+// Name returns the name of the congestion control algorithm.
+func (c *cubicSender) Name() string {
+	if c.reno {
+		return "reno"
+	}
+	return "cubic"
+}
+
 // TimeUntilSend returns when the next packet should be sent.
 func (c *cubicSender) TimeUntilSend(_ protocol.ByteCount) time.Time {
 	return c.pacer.TimeUntilSend()
@@ -449,6 +449,11 @@ var _ = Describe("Cubic Sender", func() {
 		Expect(sender.hybridSlowStart.Started()).To(BeFalse())
 	})
 
+	It("reports its name", func() {
+		Expect(sender.Name()).To(Equal("reno"))
+		Expect(newCubicSender(&clock, rttStats, false, initialCongestionWindowPackets*maxDatagramSize, MaxCongestionWindow, nil).Name()).To(Equal("cubic"))
+	})
+
 	It("default max cwnd", func() {
 		sender = newCubicSender(&clock, rttStats, true /*reno*/, initialCongestionWindowPackets*maxDatagramSize, maxCongestionWindow, nil)
 
@@ -3,9 +3,17 @@ package congestion
 import (
 	"time"
 
+	quiccongestion "github.com/lucas-clemente/quic-go/congestion"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
 )
 
+// A Controller is a congestion controller, as it can be supplied by the application.
+// It is implemented by the cubicSender.
+type Controller = quiccongestion.Controller
+
+// A NamedController is a Controller that reports its name.
+type NamedController = quiccongestion.NamedController
+
 // A SendAlgorithm performs congestion control
 type SendAlgorithm interface {
 	TimeUntilSend(bytesInFlight protocol.ByteCount) time.Time
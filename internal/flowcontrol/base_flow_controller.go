@@ -42,15 +42,32 @@ func (c *baseFlowController) IsNewlyBlocked() (bool, protocol.ByteCount) {
 }
 
 func (c *baseFlowController) AddBytesSent(n protocol.ByteCount) {
+	c.mutex.Lock()
 	c.bytesSent += n
+	c.mutex.Unlock()
 }
 
 // UpdateSendWindow should be called after receiving a WindowUpdateFrame
 // it returns true if the window was actually updated
 func (c *baseFlowController) UpdateSendWindow(offset protocol.ByteCount) {
+	c.mutex.Lock()
 	if offset > c.sendWindow {
 		c.sendWindow = offset
 	}
+	c.mutex.Unlock()
+}
+
+// Windows returns the remaining send and receive windows.
+// For streams, the send window doesn't take connection-level flow control into account.
+func (c *baseFlowController) Windows() (protocol.ByteCount, protocol.ByteCount) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var receiveWindow protocol.ByteCount
+	if c.receiveWindow > c.highestReceived {
+		receiveWindow = c.receiveWindow - c.highestReceived
+	}
+	return c.sendWindowSize(), receiveWindow
 }
 
 func (c *baseFlowController) sendWindowSize() protocol.ByteCount {
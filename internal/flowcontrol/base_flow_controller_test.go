@@ -234,4 +234,26 @@ var _ = Describe("Base Flow controller", func() {
 			})
 		})
 	})
+
+	Context("windows", func() {
+		It("reports the remaining send and receive windows", func() {
+			controller.UpdateSendWindow(100)
+			controller.receiveWindow = 1000
+			send, receive := controller.Windows()
+			Expect(send).To(Equal(protocol.ByteCount(100)))
+			Expect(receive).To(Equal(protocol.ByteCount(1000)))
+			// consume credit
+			controller.AddBytesSent(30)
+			controller.highestReceived = 400
+			send, receive = controller.Windows()
+			Expect(send).To(Equal(protocol.ByteCount(70)))
+			Expect(receive).To(Equal(protocol.ByteCount(600)))
+			// grant credit
+			controller.UpdateSendWindow(200)
+			controller.receiveWindow = 1500
+			send, receive = controller.Windows()
+			Expect(send).To(Equal(protocol.ByteCount(170)))
+			Expect(receive).To(Equal(protocol.ByteCount(1100)))
+		})
+	})
 })
@@ -75,6 +75,14 @@ func (c *connectionFlowController) GetWindowUpdate() protocol.ByteCount {
 	return offset
 }
 
+// BytesTransferred returns the number of bytes sent and received on all streams.
+// Retransmissions are not counted.
+func (c *connectionFlowController) BytesTransferred() protocol.ByteCount {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.bytesSent + c.highestReceived
+}
+
 // EnsureMinimumWindowSize sets a minimum window size
 // it should make sure that the connection-level window is increased when a stream-level window grows
 func (c *connectionFlowController) EnsureMinimumWindowSize(inc protocol.ByteCount) {
@@ -94,6 +94,14 @@ var _ = Describe("Connection Flow controller", func() {
 		})
 	})
 
+	It("counts the bytes transferred", func() {
+		controller.receiveWindow = 10000
+		Expect(controller.BytesTransferred()).To(BeZero())
+		controller.AddBytesSent(100)
+		Expect(controller.IncrementHighestReceived(200)).To(Succeed())
+		Expect(controller.BytesTransferred()).To(Equal(protocol.ByteCount(300)))
+	})
+
 	Context("setting the minimum window size", func() {
 		var (
 			oldWindowSize     protocol.ByteCount
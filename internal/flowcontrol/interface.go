@@ -11,6 +11,9 @@ type flowController interface {
 	AddBytesRead(protocol.ByteCount)
 	GetWindowUpdate() protocol.ByteCount // returns 0 if no update is necessary
 	IsNewlyBlocked() (bool, protocol.ByteCount)
+	// Windows returns the remaining send and receive windows.
+	// It can be called concurrently with the other methods.
+	Windows() (send, receive protocol.ByteCount)
 }
 
 // A StreamFlowController is a flow controller for a QUIC stream.
@@ -29,6 +32,8 @@ type StreamFlowController interface {
 // The ConnectionFlowController is the flow controller for the connection.
 type ConnectionFlowController interface {
 	flowController
+	// BytesTransferred returns the number of bytes sent and received on all streams
+	BytesTransferred() protocol.ByteCount
 }
 
 type connectionFlowControllerI interface {
@@ -123,6 +123,9 @@ type cryptoSetup struct {
 	mutex sync.Mutex // protects all members below
 
 	handshakeCompleteTime time.Time
+	helloRetryRequest     bool
+
+	peerParamsRaw []byte
 
 	readEncLevel  protocol.EncryptionLevel
 	writeEncLevel protocol.EncryptionLevel
@@ -159,6 +162,7 @@ func NewCryptoSetupClient(
 	runner handshakeRunner,
 	tlsConf *tls.Config,
 	enable0RTT bool,
+	disableKeyUpdate bool,
 	rttStats *utils.RTTStats,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
@@ -172,6 +176,7 @@ func NewCryptoSetupClient(
 		runner,
 		tlsConf,
 		enable0RTT,
+		disableKeyUpdate,
 		rttStats,
 		tracer,
 		logger,
@@ -192,6 +197,7 @@ func NewCryptoSetupServer(
 	runner handshakeRunner,
 	tlsConf *tls.Config,
 	enable0RTT bool,
+	disableKeyUpdate bool,
 	rttStats *utils.RTTStats,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
@@ -205,6 +211,7 @@ func NewCryptoSetupServer(
 		runner,
 		tlsConf,
 		enable0RTT,
+		disableKeyUpdate,
 		rttStats,
 		tracer,
 		logger,
@@ -222,6 +229,7 @@ func newCryptoSetup(
 	runner handshakeRunner,
 	tlsConf *tls.Config,
 	enable0RTT bool,
+	disableKeyUpdate bool,
 	rttStats *utils.RTTStats,
 	tracer logging.ConnectionTracer,
 	logger utils.Logger,
@@ -239,7 +247,7 @@ func newCryptoSetup(
 		initialSealer:             initialSealer,
 		initialOpener:             initialOpener,
 		handshakeStream:           handshakeStream,
-		aead:                      newUpdatableAEAD(rttStats, tracer, logger),
+		aead:                      newUpdatableAEAD(rttStats, disableKeyUpdate, tracer, logger),
 		readEncLevel:              protocol.EncryptionInitial,
 		writeEncLevel:             protocol.EncryptionInitial,
 		runner:                    runner,
@@ -367,8 +375,16 @@ readLoop:
 	// but only if a handshake opener and sealer was created.
 	// Otherwise, a HelloRetryRequest was performed.
 	// We're done with the Handshake encryption level after processing the Finished message.
-	return ((msgType == typeClientHello || msgType == typeServerHello) && h.handshakeOpener != nil && h.handshakeSealer != nil) ||
-		msgType == typeFinished
+	if msgType == typeClientHello || msgType == typeServerHello {
+		if h.handshakeOpener != nil && h.handshakeSealer != nil {
+			return true
+		}
+		h.mutex.Lock()
+		h.helloRetryRequest = true
+		h.mutex.Unlock()
+		return false
+	}
+	return msgType == typeFinished
 }
 
 func (h *cryptoSetup) checkEncryptionLevel(msgType messageType, encLevel protocol.EncryptionLevel) error {
@@ -400,6 +416,10 @@ func (h *cryptoSetup) handleTransportParameters(data []byte) {
 		h.runner.OnError(qerr.NewError(qerr.TransportParameterError, err.Error()))
 	}
 	h.peerParams = &tp
+	h.mutex.Lock()
+	h.peerParamsRaw = make([]byte, len(data))
+	copy(h.peerParamsRaw, data)
+	h.mutex.Unlock()
 	h.runner.OnReceivedParams(h.peerParams)
 }
 
@@ -783,3 +803,15 @@ func (h *cryptoSetup) Get1RTTOpener() (ShortHeaderOpener, error) {
 func (h *cryptoSetup) ConnectionState() ConnectionState {
 	return qtls.GetConnectionState(h.conn)
 }
+
+func (h *cryptoSetup) DidHelloRetryRequest() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.helloRetryRequest
+}
+
+func (h *cryptoSetup) PeerTransportParameters() []byte {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.peerParamsRaw
+}
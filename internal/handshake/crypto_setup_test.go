@@ -96,6 +96,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			runner,
 			testdata.GetTLSConfig(),
 			false,
+			false,
 			&utils.RTTStats{},
 			nil,
 			utils.DefaultLogger.WithPrefix("server"),
@@ -137,6 +138,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			runner,
 			testdata.GetTLSConfig(),
 			false,
+			false,
 			&utils.RTTStats{},
 			nil,
 			utils.DefaultLogger.WithPrefix("server"),
@@ -181,6 +183,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			runner,
 			serverConf,
 			false,
+			false,
 			&utils.RTTStats{},
 			nil,
 			utils.DefaultLogger.WithPrefix("server"),
@@ -218,6 +221,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 			NewMockHandshakeRunner(mockCtrl),
 			serverConf,
 			false,
+			false,
 			&utils.RTTStats{},
 			nil,
 			utils.DefaultLogger.WithPrefix("server"),
@@ -335,6 +339,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				cRunner,
 				clientConf,
 				enable0RTT,
+				false,
 				clientRTTStats,
 				nil,
 				utils.DefaultLogger.WithPrefix("client"),
@@ -362,6 +367,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				sRunner,
 				serverConf,
 				enable0RTT,
+				false,
 				serverRTTStats,
 				nil,
 				utils.DefaultLogger.WithPrefix("server"),
@@ -384,7 +390,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 		}
 
 		It("handshakes", func() {
-			_, _, clientErr, _, serverErr := handshakeWithTLSConf(
+			_, client, clientErr, server, serverErr := handshakeWithTLSConf(
 				clientConf, serverConf,
 				&utils.RTTStats{}, &utils.RTTStats{},
 				&wire.TransportParameters{}, &wire.TransportParameters{},
@@ -392,11 +398,13 @@ var _ = Describe("Crypto Setup TLS", func() {
 			)
 			Expect(clientErr).ToNot(HaveOccurred())
 			Expect(serverErr).ToNot(HaveOccurred())
+			Expect(client.DidHelloRetryRequest()).To(BeFalse())
+			Expect(server.DidHelloRetryRequest()).To(BeFalse())
 		})
 
 		It("performs a HelloRetryRequst", func() {
 			serverConf.CurvePreferences = []tls.CurveID{tls.CurveP384}
-			_, _, clientErr, _, serverErr := handshakeWithTLSConf(
+			_, client, clientErr, server, serverErr := handshakeWithTLSConf(
 				clientConf, serverConf,
 				&utils.RTTStats{}, &utils.RTTStats{},
 				&wire.TransportParameters{}, &wire.TransportParameters{},
@@ -404,6 +412,8 @@ var _ = Describe("Crypto Setup TLS", func() {
 			)
 			Expect(clientErr).ToNot(HaveOccurred())
 			Expect(serverErr).ToNot(HaveOccurred())
+			Expect(client.DidHelloRetryRequest()).To(BeTrue())
+			Expect(server.DidHelloRetryRequest()).To(BeTrue())
 		})
 
 		It("handshakes with client auth", func() {
@@ -432,6 +442,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				runner,
 				&tls.Config{InsecureSkipVerify: true},
 				false,
+				false,
 				&utils.RTTStats{},
 				nil,
 				utils.DefaultLogger.WithPrefix("client"),
@@ -475,6 +486,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				cRunner,
 				clientConf,
 				false,
+				false,
 				&utils.RTTStats{},
 				nil,
 				utils.DefaultLogger.WithPrefix("client"),
@@ -500,6 +512,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 				sRunner,
 				serverConf,
 				false,
+				false,
 				&utils.RTTStats{},
 				nil,
 				utils.DefaultLogger.WithPrefix("server"),
@@ -516,6 +529,13 @@ var _ = Describe("Crypto Setup TLS", func() {
 			Expect(cTransportParametersRcvd.MaxIdleTimeout).To(Equal(cTransportParameters.MaxIdleTimeout))
 			Expect(sTransportParametersRcvd).ToNot(BeNil())
 			Expect(sTransportParametersRcvd.MaxIdleTimeout).To(Equal(sTransportParameters.MaxIdleTimeout))
+			// the raw transport parameters are the ones sent by the peer
+			var tp wire.TransportParameters
+			Expect(tp.Unmarshal(server.PeerTransportParameters(), protocol.PerspectiveClient)).To(Succeed())
+			Expect(tp.MaxIdleTimeout).To(Equal(cTransportParameters.MaxIdleTimeout))
+			Expect(tp.Unmarshal(client.PeerTransportParameters(), protocol.PerspectiveServer)).To(Succeed())
+			Expect(tp.MaxIdleTimeout).To(Equal(sTransportParameters.MaxIdleTimeout))
+			Expect(tp.StatelessResetToken).To(Equal(&token))
 		})
 
 		Context("with session tickets", func() {
@@ -534,6 +554,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					cRunner,
 					clientConf,
 					false,
+					false,
 					&utils.RTTStats{},
 					nil,
 					utils.DefaultLogger.WithPrefix("client"),
@@ -555,6 +576,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					sRunner,
 					serverConf,
 					false,
+					false,
 					&utils.RTTStats{},
 					nil,
 					utils.DefaultLogger.WithPrefix("server"),
@@ -596,6 +618,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					cRunner,
 					clientConf,
 					false,
+					false,
 					&utils.RTTStats{},
 					nil,
 					utils.DefaultLogger.WithPrefix("client"),
@@ -617,6 +640,7 @@ var _ = Describe("Crypto Setup TLS", func() {
 					sRunner,
 					serverConf,
 					false,
+					false,
 					&utils.RTTStats{},
 					nil,
 					utils.DefaultLogger.WithPrefix("server"),
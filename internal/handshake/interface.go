@@ -82,6 +82,10 @@ type CryptoSetup interface {
 	SetLargest1RTTAcked(protocol.PacketNumber) error
 	SetHandshakeConfirmed()
 	ConnectionState() ConnectionState
+	// DidHelloRetryRequest says if a HelloRetryRequest was performed during the handshake.
+	DidHelloRetryRequest() bool
+	// PeerTransportParameters returns the raw transport parameters received from the peer.
+	PeerTransportParameters() []byte
 
 	GetInitialOpener() (LongHeaderOpener, error)
 	GetHandshakeOpener() (LongHeaderOpener, error)
@@ -27,10 +27,16 @@ type updatableAEAD struct {
 	firstPacketNumber  protocol.PacketNumber
 	handshakeConfirmed bool
 
-	keyUpdateInterval  uint64
+	keyUpdateInterval uint64
+	// If set, we never initiate a key update. Key updates initiated by the peer are still processed.
+	disableKeyUpdate   bool
 	invalidPacketLimit uint64
 	invalidPacketCount uint64
 
+	// If set, a key update is initiated as soon as a packet with this (or a higher) packet number was sent,
+	// regardless of the key update interval. Only used for testing.
+	forceKeyUpdateAfter protocol.PacketNumber
+
 	// Time when the keys should be dropped. Keys are dropped on the next call to Open().
 	prevRcvAEADExpiry time.Time
 	prevRcvAEAD       cipher.AEAD
@@ -38,6 +44,7 @@ type updatableAEAD struct {
 	firstRcvdWithCurrentKey protocol.PacketNumber
 	firstSentWithCurrentKey protocol.PacketNumber
 	highestRcvdPN           protocol.PacketNumber // highest packet number received (which could be successfully unprotected)
+	highestSentPN           protocol.PacketNumber
 	numRcvdWithCurrentKey   uint64
 	numSentWithCurrentKey   uint64
 	rcvAEAD                 cipher.AEAD
@@ -67,13 +74,16 @@ var (
 	_ ShortHeaderSealer = &updatableAEAD{}
 )
 
-func newUpdatableAEAD(rttStats *utils.RTTStats, tracer logging.ConnectionTracer, logger utils.Logger) *updatableAEAD {
+func newUpdatableAEAD(rttStats *utils.RTTStats, disableKeyUpdate bool, tracer logging.ConnectionTracer, logger utils.Logger) *updatableAEAD {
 	return &updatableAEAD{
 		firstPacketNumber:       protocol.InvalidPacketNumber,
 		largestAcked:            protocol.InvalidPacketNumber,
 		firstRcvdWithCurrentKey: protocol.InvalidPacketNumber,
 		firstSentWithCurrentKey: protocol.InvalidPacketNumber,
+		highestSentPN:           protocol.InvalidPacketNumber,
+		forceKeyUpdateAfter:     protocol.InvalidPacketNumber,
 		keyUpdateInterval:       KeyUpdateInterval,
+		disableKeyUpdate:        disableKeyUpdate,
 		rttStats:                rttStats,
 		tracer:                  tracer,
 		logger:                  logger,
@@ -241,6 +251,7 @@ func (a *updatableAEAD) Seal(dst, src []byte, pn protocol.PacketNumber, ad []byt
 		a.firstPacketNumber = pn
 	}
 	a.numSentWithCurrentKey++
+	a.highestSentPN = utils.MaxPacketNumber(a.highestSentPN, pn)
 	binary.BigEndian.PutUint64(a.nonceBuf[len(a.nonceBuf)-8:], uint64(pn))
 	// The AEAD we're using here will be the qtls.aeadAESGCM13.
 	// It uses the nonce provided here and XOR it with the IV.
@@ -273,9 +284,13 @@ func (a *updatableAEAD) updateAllowed() bool {
 }
 
 func (a *updatableAEAD) shouldInitiateKeyUpdate() bool {
-	if !a.updateAllowed() {
+	if a.disableKeyUpdate || !a.updateAllowed() {
 		return false
 	}
+	if a.forceKeyUpdateAfter != protocol.InvalidPacketNumber && a.highestSentPN >= a.forceKeyUpdateAfter {
+		a.logger.Debugf("Sent packet %d. Forcing key update to the next key phase: %d", a.highestSentPN, a.keyPhase+1)
+		return true
+	}
 	if a.numRcvdWithCurrentKey >= a.keyUpdateInterval {
 		a.logger.Debugf("Received %d packets with current key phase. Initiating key update to the next key phase: %d", a.numRcvdWithCurrentKey, a.keyPhase+1)
 		return true
@@ -289,6 +304,7 @@ func (a *updatableAEAD) shouldInitiateKeyUpdate() bool {
 
 func (a *updatableAEAD) KeyPhase() protocol.KeyPhaseBit {
 	if a.shouldInitiateKeyUpdate() {
+		a.forceKeyUpdateAfter = protocol.InvalidPacketNumber
 		a.rollKeys()
 		a.logger.Debugf("Initiating key update to key phase %d", a.keyPhase)
 		if a.tracer != nil {
@@ -298,6 +314,21 @@ func (a *updatableAEAD) KeyPhase() protocol.KeyPhaseBit {
 	return a.keyPhase.Bit()
 }
 
+// forceKeyUpdate makes the next call to KeyPhase initiate a key update,
+// once a packet with packet number pn (or higher) was sent.
+// The rules for initiating key updates still apply:
+// If the update is not allowed yet, it is initiated as soon as it is allowed.
+// Only used for testing.
+func (a *updatableAEAD) forceKeyUpdate(pn protocol.PacketNumber) {
+	a.forceKeyUpdateAfter = pn
+}
+
+// currentKeyPhase returns the current key phase.
+// In contrast to KeyPhase, it never initiates a key update. Only used for testing.
+func (a *updatableAEAD) currentKeyPhase() protocol.KeyPhase {
+	return a.keyPhase
+}
+
 func (a *updatableAEAD) Overhead() int {
 	return a.aeadOverhead
 }
@@ -21,7 +21,7 @@ import (
 var _ = Describe("Updatable AEAD", func() {
 	It("ChaCha test vector from the draft", func() {
 		secret := splitHexString("9ac312a7f877468ebe69422748ad00a1 5443f18203a07d6060f688f30f21632b")
-		aead := newUpdatableAEAD(&utils.RTTStats{}, nil, nil)
+		aead := newUpdatableAEAD(&utils.RTTStats{}, false, nil, nil)
 		chacha := cipherSuites[2]
 		Expect(chacha.ID).To(Equal(tls.TLS_CHACHA20_POLY1305_SHA256))
 		aead.SetWriteKey(chacha, secret)
@@ -54,8 +54,8 @@ var _ = Describe("Updatable AEAD", func() {
 				rand.Read(trafficSecret2)
 
 				rttStats = utils.NewRTTStats()
-				client = newUpdatableAEAD(rttStats, nil, utils.DefaultLogger)
-				server = newUpdatableAEAD(rttStats, serverTracer, utils.DefaultLogger)
+				client = newUpdatableAEAD(rttStats, false, nil, utils.DefaultLogger)
+				server = newUpdatableAEAD(rttStats, false, serverTracer, utils.DefaultLogger)
 				client.SetReadKey(cs, trafficSecret2)
 				client.SetWriteKey(cs, trafficSecret1)
 				server.SetReadKey(cs, trafficSecret1)
@@ -281,6 +281,33 @@ var _ = Describe("Updatable AEAD", func() {
 							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
 						})
 
+						It("doesn't initiate key updates if key updates are disabled", func() {
+							server.disableKeyUpdate = true
+							server.forceKeyUpdate(0)
+							for i := 0; i < 3*keyUpdateInterval; i++ {
+								pn := protocol.PacketNumber(i)
+								Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseZero))
+								server.Seal(nil, msg, pn, ad)
+							}
+							// don't EXPECT any calls to UpdatedKey
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseZero))
+						})
+
+						It("responds to key updates initiated by the peer if key updates are disabled", func() {
+							server.disableKeyUpdate = true
+							encrypted0 := client.Seal(nil, msg, 0x42, ad)
+							_, err := server.Open(nil, encrypted0, time.Now(), 0x42, protocol.KeyPhaseZero, ad)
+							Expect(err).ToNot(HaveOccurred())
+							_ = server.Seal(nil, msg, 0x1, ad)
+							client.rollKeys()
+							encrypted1 := client.Seal(nil, msg, 0x43, ad)
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), true)
+							decrypted, err := server.Open(nil, encrypted1, time.Now(), 0x43, protocol.KeyPhaseOne, ad)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(decrypted).To(Equal(msg))
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+						})
+
 						It("initiates a key update after sealing the maximum number of packets, for subsequent updates", func() {
 							server.rollKeys()
 							client.rollKeys()
@@ -495,6 +522,83 @@ var _ = Describe("Updatable AEAD", func() {
 							Expect(err).ToNot(HaveOccurred())
 						})
 					})
+
+					Context("forcing key updates", func() {
+						It("initiates a key update after sending a specific packet", func() {
+							server.SetHandshakeConfirmed()
+							server.forceKeyUpdate(5)
+							for i := 0; i <= 5; i++ {
+								pn := protocol.PacketNumber(i)
+								Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseZero))
+								server.Seal(nil, msg, pn, ad)
+							}
+							// inspecting the key phase doesn't initiate the key update
+							Expect(server.currentKeyPhase()).To(BeZero())
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), false)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+							Expect(server.currentKeyPhase()).To(Equal(protocol.KeyPhase(1)))
+							// the client follows the key update
+							encrypted := server.Seal(nil, msg, 6, ad)
+							Expect(client.currentKeyPhase()).To(BeZero())
+							decrypted, err := client.Open(nil, encrypted, time.Now(), 6, protocol.KeyPhaseOne, ad)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(decrypted).To(Equal(msg))
+							Expect(client.currentKeyPhase()).To(Equal(protocol.KeyPhase(1)))
+							// the forced key update only happens once
+							b := client.Seal(nil, msg, 1, ad)
+							_, err = server.Open(nil, b, time.Now(), 1, protocol.KeyPhaseOne, ad)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(server.SetLargestAcked(6)).To(Succeed())
+							for i := 7; i < 100; i++ {
+								Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+								server.Seal(nil, msg, protocol.PacketNumber(i), ad)
+							}
+						})
+
+						It("waits until the key update is allowed", func() {
+							server.forceKeyUpdate(0)
+							server.Seal(nil, msg, 0, ad)
+							// the handshake is not confirmed yet
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseZero))
+							server.SetHandshakeConfirmed()
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), false)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+							// force the next key update, which requires an acknowledgement for a packet sent in key phase 1
+							server.forceKeyUpdate(1)
+							server.Seal(nil, msg, 1, ad)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseOne))
+							client.rollKeys()
+							b := client.Seal(nil, msg, 1, ad)
+							_, err := server.Open(nil, b, time.Now(), 1, protocol.KeyPhaseOne, ad)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(server.SetLargestAcked(1)).To(Succeed())
+							serverTracer.EXPECT().DroppedKey(protocol.KeyPhase(0))
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(2), false)
+							Expect(server.KeyPhase()).To(Equal(protocol.KeyPhaseZero))
+							Expect(server.currentKeyPhase()).To(Equal(protocol.KeyPhase(2)))
+						})
+
+						It("follows a key update that the peer initiated at a specific packet", func() {
+							client.SetHandshakeConfirmed()
+							client.forceKeyUpdate(3)
+							serverTracer.EXPECT().UpdatedKey(protocol.KeyPhase(1), true)
+							for i := 0; i < 6; i++ {
+								pn := protocol.PacketNumber(i)
+								kp := client.KeyPhase()
+								encrypted := client.Seal(nil, msg, pn, ad)
+								decrypted, err := server.Open(nil, encrypted, time.Now(), pn, kp, ad)
+								Expect(err).ToNot(HaveOccurred())
+								Expect(decrypted).To(Equal(msg))
+								if pn <= 3 {
+									Expect(kp).To(Equal(protocol.KeyPhaseZero))
+									Expect(server.currentKeyPhase()).To(BeZero())
+								} else {
+									Expect(kp).To(Equal(protocol.KeyPhaseOne))
+									Expect(server.currentKeyPhase()).To(Equal(protocol.KeyPhase(1)))
+								}
+							}
+						})
+					})
 				})
 			})
 		})
@@ -37,6 +37,34 @@ func (m *MockSentPacketHandler) EXPECT() *MockSentPacketHandlerMockRecorder {
 	return m.recorder
 }
 
+// CongestionControl mocks base method
+func (m *MockSentPacketHandler) CongestionControl() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CongestionControl")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// CongestionControl indicates an expected call of CongestionControl
+func (mr *MockSentPacketHandlerMockRecorder) CongestionControl() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CongestionControl", reflect.TypeOf((*MockSentPacketHandler)(nil).CongestionControl))
+}
+
+// CongestionWindow mocks base method
+func (m *MockSentPacketHandler) CongestionWindow() protocol.ByteCount {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CongestionWindow")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// CongestionWindow indicates an expected call of CongestionWindow
+func (mr *MockSentPacketHandlerMockRecorder) CongestionWindow() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CongestionWindow", reflect.TypeOf((*MockSentPacketHandler)(nil).CongestionWindow))
+}
+
 // DropPackets mocks base method
 func (m *MockSentPacketHandler) DropPackets(arg0 protocol.EncryptionLevel) {
 	m.ctrl.T.Helper()
@@ -77,6 +105,47 @@ func (mr *MockSentPacketHandlerMockRecorder) HasPacingBudget() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasPacingBudget", reflect.TypeOf((*MockSentPacketHandler)(nil).HasPacingBudget))
 }
 
+// LargestAcked mocks base method
+func (m *MockSentPacketHandler) LargestAcked(arg0 protocol.EncryptionLevel) protocol.PacketNumber {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LargestAcked", arg0)
+	ret0, _ := ret[0].(protocol.PacketNumber)
+	return ret0
+}
+
+// LargestAcked indicates an expected call of LargestAcked
+func (mr *MockSentPacketHandlerMockRecorder) LargestAcked(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LargestAcked", reflect.TypeOf((*MockSentPacketHandler)(nil).LargestAcked), arg0)
+}
+
+// LossCounts mocks base method
+func (m *MockSentPacketHandler) LossCounts(arg0 protocol.EncryptionLevel) (uint64, uint64) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LossCounts", arg0)
+	ret0, _ := ret[0].(uint64)
+	ret1, _ := ret[1].(uint64)
+	return ret0, ret1
+}
+
+// LossCounts indicates an expected call of LossCounts
+func (mr *MockSentPacketHandlerMockRecorder) LossCounts(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LossCounts", reflect.TypeOf((*MockSentPacketHandler)(nil).LossCounts), arg0)
+}
+
+// MigratedPath mocks base method
+func (m *MockSentPacketHandler) MigratedPath() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "MigratedPath")
+}
+
+// MigratedPath indicates an expected call of MigratedPath
+func (mr *MockSentPacketHandlerMockRecorder) MigratedPath() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigratedPath", reflect.TypeOf((*MockSentPacketHandler)(nil).MigratedPath))
+}
+
 // OnLossDetectionTimeout mocks base method
 func (m *MockSentPacketHandler) OnLossDetectionTimeout() error {
 	m.ctrl.T.Helper()
@@ -91,6 +160,20 @@ func (mr *MockSentPacketHandlerMockRecorder) OnLossDetectionTimeout() *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnLossDetectionTimeout", reflect.TypeOf((*MockSentPacketHandler)(nil).OnLossDetectionTimeout))
 }
 
+// PTOCount mocks base method
+func (m *MockSentPacketHandler) PTOCount() uint32 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PTOCount")
+	ret0, _ := ret[0].(uint32)
+	return ret0
+}
+
+// PTOCount indicates an expected call of PTOCount
+func (mr *MockSentPacketHandlerMockRecorder) PTOCount() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PTOCount", reflect.TypeOf((*MockSentPacketHandler)(nil).PTOCount))
+}
+
 // PeekPacketNumber mocks base method
 func (m *MockSentPacketHandler) PeekPacketNumber(arg0 protocol.EncryptionLevel) (protocol.PacketNumber, protocol.PacketNumberLen) {
 	m.ctrl.T.Helper()
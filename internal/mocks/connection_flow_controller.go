@@ -58,6 +58,20 @@ func (mr *MockConnectionFlowControllerMockRecorder) AddBytesSent(arg0 interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBytesSent", reflect.TypeOf((*MockConnectionFlowController)(nil).AddBytesSent), arg0)
 }
 
+// BytesTransferred mocks base method
+func (m *MockConnectionFlowController) BytesTransferred() protocol.ByteCount {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BytesTransferred")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// BytesTransferred indicates an expected call of BytesTransferred
+func (mr *MockConnectionFlowControllerMockRecorder) BytesTransferred() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BytesTransferred", reflect.TypeOf((*MockConnectionFlowController)(nil).BytesTransferred))
+}
+
 // GetWindowUpdate mocks base method
 func (m *MockConnectionFlowController) GetWindowUpdate() protocol.ByteCount {
 	m.ctrl.T.Helper()
@@ -112,3 +126,18 @@ func (mr *MockConnectionFlowControllerMockRecorder) UpdateSendWindow(arg0 interf
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSendWindow", reflect.TypeOf((*MockConnectionFlowController)(nil).UpdateSendWindow), arg0)
 }
+
+// Windows mocks base method
+func (m *MockConnectionFlowController) Windows() (protocol.ByteCount, protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Windows")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	ret1, _ := ret[1].(protocol.ByteCount)
+	return ret0, ret1
+}
+
+// Windows indicates an expected call of Windows
+func (mr *MockConnectionFlowControllerMockRecorder) Windows() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Windows", reflect.TypeOf((*MockConnectionFlowController)(nil).Windows))
+}
@@ -76,6 +76,20 @@ func (mr *MockCryptoSetupMockRecorder) ConnectionState() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConnectionState", reflect.TypeOf((*MockCryptoSetup)(nil).ConnectionState))
 }
 
+// DidHelloRetryRequest mocks base method
+func (m *MockCryptoSetup) DidHelloRetryRequest() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DidHelloRetryRequest")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// DidHelloRetryRequest indicates an expected call of DidHelloRetryRequest
+func (mr *MockCryptoSetupMockRecorder) DidHelloRetryRequest() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DidHelloRetryRequest", reflect.TypeOf((*MockCryptoSetup)(nil).DidHelloRetryRequest))
+}
+
 // Get0RTTOpener mocks base method
 func (m *MockCryptoSetup) Get0RTTOpener() (handshake.LongHeaderOpener, error) {
 	m.ctrl.T.Helper()
@@ -225,6 +239,20 @@ func (mr *MockCryptoSetupMockRecorder) HandleMessage(arg0, arg1 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleMessage", reflect.TypeOf((*MockCryptoSetup)(nil).HandleMessage), arg0, arg1)
 }
 
+// PeerTransportParameters mocks base method
+func (m *MockCryptoSetup) PeerTransportParameters() []byte {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PeerTransportParameters")
+	ret0, _ := ret[0].([]byte)
+	return ret0
+}
+
+// PeerTransportParameters indicates an expected call of PeerTransportParameters
+func (mr *MockCryptoSetupMockRecorder) PeerTransportParameters() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PeerTransportParameters", reflect.TypeOf((*MockCryptoSetup)(nil).PeerTransportParameters))
+}
+
 // RunHandshake mocks base method
 func (m *MockCryptoSetup) RunHandshake() {
 	m.ctrl.T.Helper()
@@ -8,6 +8,7 @@ import (
 	context "context"
 	net "net"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	quic "github.com/lucas-clemente/quic-go"
@@ -67,6 +68,34 @@ func (mr *MockEarlySessionMockRecorder) AcceptUniStream(arg0 interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockEarlySession)(nil).AcceptUniStream), arg0)
 }
 
+// CanOpenStreams mocks base method
+func (m *MockEarlySession) CanOpenStreams(arg0 int) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanOpenStreams", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanOpenStreams indicates an expected call of CanOpenStreams
+func (mr *MockEarlySessionMockRecorder) CanOpenStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanOpenStreams", reflect.TypeOf((*MockEarlySession)(nil).CanOpenStreams), arg0)
+}
+
+// CanOpenUniStreams mocks base method
+func (m *MockEarlySession) CanOpenUniStreams(arg0 int) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanOpenUniStreams", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanOpenUniStreams indicates an expected call of CanOpenUniStreams
+func (mr *MockEarlySessionMockRecorder) CanOpenUniStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanOpenUniStreams", reflect.TypeOf((*MockEarlySession)(nil).CanOpenUniStreams), arg0)
+}
+
 // CloseWithError mocks base method
 func (m *MockEarlySession) CloseWithError(arg0 protocol.ApplicationErrorCode, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -109,6 +138,20 @@ func (mr *MockEarlySessionMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockEarlySession)(nil).Context))
 }
 
+// Events mocks base method
+func (m *MockEarlySession) Events() <-chan quic.Event {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events")
+	ret0, _ := ret[0].(<-chan quic.Event)
+	return ret0
+}
+
+// Events indicates an expected call of Events
+func (mr *MockEarlySessionMockRecorder) Events() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockEarlySession)(nil).Events))
+}
+
 // HandshakeComplete mocks base method
 func (m *MockEarlySession) HandshakeComplete() context.Context {
 	m.ctrl.T.Helper()
@@ -123,6 +166,20 @@ func (mr *MockEarlySessionMockRecorder) HandshakeComplete() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandshakeComplete", reflect.TypeOf((*MockEarlySession)(nil).HandshakeComplete))
 }
 
+// IdleStreams mocks base method
+func (m *MockEarlySession) IdleStreams(arg0 time.Duration) []quic.Stream {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdleStreams", arg0)
+	ret0, _ := ret[0].([]quic.Stream)
+	return ret0
+}
+
+// IdleStreams indicates an expected call of IdleStreams
+func (mr *MockEarlySessionMockRecorder) IdleStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdleStreams", reflect.TypeOf((*MockEarlySession)(nil).IdleStreams), arg0)
+}
+
 // LocalAddr mocks base method
 func (m *MockEarlySession) LocalAddr() net.Addr {
 	m.ctrl.T.Helper()
@@ -137,6 +194,48 @@ func (mr *MockEarlySessionMockRecorder) LocalAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LocalAddr", reflect.TypeOf((*MockEarlySession)(nil).LocalAddr))
 }
 
+// MigrateTo mocks base method
+func (m *MockEarlySession) MigrateTo(arg0 net.Addr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateTo", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateTo indicates an expected call of MigrateTo
+func (mr *MockEarlySessionMockRecorder) MigrateTo(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateTo", reflect.TypeOf((*MockEarlySession)(nil).MigrateTo), arg0)
+}
+
+// NextStreamID mocks base method
+func (m *MockEarlySession) NextStreamID() protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextStreamID")
+	ret0, _ := ret[0].(protocol.StreamID)
+	return ret0
+}
+
+// NextStreamID indicates an expected call of NextStreamID
+func (mr *MockEarlySessionMockRecorder) NextStreamID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextStreamID", reflect.TypeOf((*MockEarlySession)(nil).NextStreamID))
+}
+
+// NextUniStreamID mocks base method
+func (m *MockEarlySession) NextUniStreamID() protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextUniStreamID")
+	ret0, _ := ret[0].(protocol.StreamID)
+	return ret0
+}
+
+// NextUniStreamID indicates an expected call of NextUniStreamID
+func (mr *MockEarlySessionMockRecorder) NextUniStreamID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextUniStreamID", reflect.TypeOf((*MockEarlySession)(nil).NextUniStreamID))
+}
+
 // OpenStream mocks base method
 func (m *MockEarlySession) OpenStream() (quic.Stream, error) {
 	m.ctrl.T.Helper()
@@ -152,6 +251,20 @@ func (mr *MockEarlySessionMockRecorder) OpenStream() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenStream", reflect.TypeOf((*MockEarlySession)(nil).OpenStream))
 }
 
+// OpenStreamIDs mocks base method
+func (m *MockEarlySession) OpenStreamIDs() []protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenStreamIDs")
+	ret0, _ := ret[0].([]protocol.StreamID)
+	return ret0
+}
+
+// OpenStreamIDs indicates an expected call of OpenStreamIDs
+func (mr *MockEarlySessionMockRecorder) OpenStreamIDs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenStreamIDs", reflect.TypeOf((*MockEarlySession)(nil).OpenStreamIDs))
+}
+
 // OpenStreamSync mocks base method
 func (m *MockEarlySession) OpenStreamSync(arg0 context.Context) (quic.Stream, error) {
 	m.ctrl.T.Helper()
@@ -197,6 +310,47 @@ func (mr *MockEarlySessionMockRecorder) OpenUniStreamSync(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockEarlySession)(nil).OpenUniStreamSync), arg0)
 }
 
+// PauseSending mocks base method
+func (m *MockEarlySession) PauseSending() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PauseSending")
+}
+
+// PauseSending indicates an expected call of PauseSending
+func (mr *MockEarlySessionMockRecorder) PauseSending() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseSending", reflect.TypeOf((*MockEarlySession)(nil).PauseSending))
+}
+
+// Ping mocks base method
+func (m *MockEarlySession) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockEarlySessionMockRecorder) Ping(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockEarlySession)(nil).Ping), arg0)
+}
+
+// ProbeMTU mocks base method
+func (m *MockEarlySession) ProbeMTU(arg0 int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProbeMTU", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProbeMTU indicates an expected call of ProbeMTU
+func (mr *MockEarlySessionMockRecorder) ProbeMTU(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProbeMTU", reflect.TypeOf((*MockEarlySession)(nil).ProbeMTU), arg0)
+}
+
 // ReceiveMessage mocks base method
 func (m *MockEarlySession) ReceiveMessage() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -212,6 +366,37 @@ func (mr *MockEarlySessionMockRecorder) ReceiveMessage() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessage", reflect.TypeOf((*MockEarlySession)(nil).ReceiveMessage))
 }
 
+// ReceiveMessageContext mocks base method
+func (m *MockEarlySession) ReceiveMessageContext(arg0 context.Context) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveMessageContext", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReceiveMessageContext indicates an expected call of ReceiveMessageContext
+func (mr *MockEarlySessionMockRecorder) ReceiveMessageContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessageContext", reflect.TypeOf((*MockEarlySession)(nil).ReceiveMessageContext), arg0)
+}
+
+// ReceiveMessageFrom mocks base method
+func (m *MockEarlySession) ReceiveMessageFrom(arg0 context.Context) ([]byte, net.Addr, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveMessageFrom", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(net.Addr)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ReceiveMessageFrom indicates an expected call of ReceiveMessageFrom
+func (mr *MockEarlySessionMockRecorder) ReceiveMessageFrom(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessageFrom", reflect.TypeOf((*MockEarlySession)(nil).ReceiveMessageFrom), arg0)
+}
+
 // RemoteAddr mocks base method
 func (m *MockEarlySession) RemoteAddr() net.Addr {
 	m.ctrl.T.Helper()
@@ -226,6 +411,30 @@ func (mr *MockEarlySessionMockRecorder) RemoteAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockEarlySession)(nil).RemoteAddr))
 }
 
+// ResumeSending mocks base method
+func (m *MockEarlySession) ResumeSending() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResumeSending")
+}
+
+// ResumeSending indicates an expected call of ResumeSending
+func (mr *MockEarlySessionMockRecorder) ResumeSending() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSending", reflect.TypeOf((*MockEarlySession)(nil).ResumeSending))
+}
+
+// ResetStreams mocks base method
+func (m *MockEarlySession) ResetStreams(arg0 func(protocol.StreamID) bool, arg1 quic.ErrorCode) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResetStreams", arg0, arg1)
+}
+
+// ResetStreams indicates an expected call of ResetStreams
+func (mr *MockEarlySessionMockRecorder) ResetStreams(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetStreams", reflect.TypeOf((*MockEarlySession)(nil).ResetStreams), arg0, arg1)
+}
+
 // SendMessage mocks base method
 func (m *MockEarlySession) SendMessage(arg0 []byte) error {
 	m.ctrl.T.Helper()
@@ -239,3 +448,31 @@ func (mr *MockEarlySessionMockRecorder) SendMessage(arg0 interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockEarlySession)(nil).SendMessage), arg0)
 }
+
+// SendMessageWithCallback mocks base method
+func (m *MockEarlySession) SendMessageWithCallback(arg0 []byte, arg1 func(bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessageWithCallback", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMessageWithCallback indicates an expected call of SendMessageWithCallback
+func (mr *MockEarlySessionMockRecorder) SendMessageWithCallback(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageWithCallback", reflect.TypeOf((*MockEarlySession)(nil).SendMessageWithCallback), arg0, arg1)
+}
+
+// Stats mocks base method
+func (m *MockEarlySession) Stats() quic.SessionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(quic.SessionStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockEarlySessionMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockEarlySession)(nil).Stats))
+}
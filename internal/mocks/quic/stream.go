@@ -48,6 +48,20 @@ func (mr *MockStreamMockRecorder) CancelRead(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelRead", reflect.TypeOf((*MockStream)(nil).CancelRead), arg0)
 }
 
+// CancelReadAndWait mocks base method
+func (m *MockStream) CancelReadAndWait(arg0 context.Context, arg1 protocol.ApplicationErrorCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelReadAndWait", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelReadAndWait indicates an expected call of CancelReadAndWait
+func (mr *MockStreamMockRecorder) CancelReadAndWait(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelReadAndWait", reflect.TypeOf((*MockStream)(nil).CancelReadAndWait), arg0, arg1)
+}
+
 // CancelWrite mocks base method
 func (m *MockStream) CancelWrite(arg0 protocol.ApplicationErrorCode) {
 	m.ctrl.T.Helper()
@@ -88,6 +102,18 @@ func (mr *MockStreamMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockStream)(nil).Context))
 }
 
+// Drain mocks base method
+func (m *MockStream) Drain() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Drain")
+}
+
+// Drain indicates an expected call of Drain
+func (mr *MockStreamMockRecorder) Drain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockStream)(nil).Drain))
+}
+
 // Read mocks base method
 func (m *MockStream) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -173,3 +199,17 @@ func (mr *MockStreamMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockStream)(nil).Write), arg0)
 }
+
+// Writable mocks base method
+func (m *MockStream) Writable() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Writable")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Writable indicates an expected call of Writable
+func (mr *MockStreamMockRecorder) Writable() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Writable", reflect.TypeOf((*MockStream)(nil).Writable))
+}
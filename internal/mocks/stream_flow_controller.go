@@ -138,3 +138,18 @@ func (mr *MockStreamFlowControllerMockRecorder) UpdateSendWindow(arg0 interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSendWindow", reflect.TypeOf((*MockStreamFlowController)(nil).UpdateSendWindow), arg0)
 }
+
+// Windows mocks base method
+func (m *MockStreamFlowController) Windows() (protocol.ByteCount, protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Windows")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	ret1, _ := ret[1].(protocol.ByteCount)
+	return ret0, ret1
+}
+
+// Windows indicates an expected call of Windows
+func (mr *MockStreamFlowControllerMockRecorder) Windows() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Windows", reflect.TypeOf((*MockStreamFlowController)(nil).Windows))
+}
@@ -11,6 +11,10 @@ const MaxPacketSizeIPv4 = 1252
 // MaxPacketSizeIPv6 is the maximum packet size that we use for sending IPv6 packets.
 const MaxPacketSizeIPv6 = 1232
 
+// PTOsBeforePacketSizeReduction is the number of consecutive PTOs after which the packet size is reduced to MinInitialPacketSize.
+// Repeated PTOs can be a sign that the path is dropping packets larger than that size.
+const PTOsBeforePacketSizeReduction = 3
+
 // MaxCongestionWindowPackets is the maximum congestion window in packet.
 const MaxCongestionWindowPackets = 10000
 
@@ -42,6 +46,9 @@ const DefaultMaxIncomingStreams = 100
 // DefaultMaxIncomingUniStreams is the maximum number of unidirectional streams that a peer may open
 const DefaultMaxIncomingUniStreams = 100
 
+// DefaultMaxPaths is the maximum number of paths tracked per connection, including the path currently in use
+const DefaultMaxPaths = 4
+
 // MaxServerUnprocessedPackets is the max number of packets stored in the server that are not yet processed.
 const MaxServerUnprocessedPackets = 1024
 
@@ -59,6 +66,11 @@ const SkipPacketMaxPeriod PacketNumber = 128 * 1024
 // If the queue is full, new connection attempts will be rejected.
 const MaxAcceptQueueSize = 32
 
+// MaxConnectionAttemptPrefixes is the maximum number of source prefixes that the server tracks
+// for rate limiting connection attempts.
+// If this number is reached, connection attempts from new prefixes are answered with a Retry.
+const MaxConnectionAttemptPrefixes = 4096
+
 // TokenValidity is the duration that a (non-retry) token is considered valid
 const TokenValidity = 24 * time.Hour
 
@@ -108,6 +120,10 @@ const DefaultHandshakeIdleTimeout = 5 * time.Second
 // DefaultHandshakeTimeout is the default timeout for a connection until the crypto handshake succeeds.
 const DefaultHandshakeTimeout = 10 * time.Second
 
+// UnproductivePacketsInterval is the interval over which packets that don't make any progress are counted,
+// in order to enforce Config.MaxUnproductivePackets.
+const UnproductivePacketsInterval = time.Second
+
 // MaxKeepAliveInterval is the maximum time until we send a packet to keep a connection alive.
 // It should be shorter than the time that NATs clear their mapping.
 const MaxKeepAliveInterval = 20 * time.Second
@@ -141,6 +157,10 @@ const MaxDatagramFrameSize ByteCount = 1200
 // See https://datatracker.ietf.org/doc/draft-pauly-quic-datagram/.
 const DatagramRcvQueueLen = 128
 
+// MaxSessionEventQueueLen is the maximum number of events queued for the application.
+// If the application doesn't read events fast enough, events are dropped.
+const MaxSessionEventQueueLen = 32
+
 // MaxNumAckRanges is the maximum number of ACK ranges that we send in an ACK frame.
 // It also serves as a limit for the packet history.
 // If at any point we keep track of more ranges, old ranges are discarded.
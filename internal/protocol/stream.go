@@ -61,6 +61,11 @@ func (s StreamID) InitiatedBy() Perspective {
 	return PerspectiveServer
 }
 
+// IsLocal says if the stream was initiated by the endpoint with the given perspective
+func (s StreamID) IsLocal(pers Perspective) bool {
+	return s.InitiatedBy() == pers
+}
+
 // Type says if this is a unidirectional or bidirectional stream
 func (s StreamID) Type() StreamType {
 	if s%4 >= 2 {
@@ -17,6 +17,21 @@ var _ = Describe("Stream ID", func() {
 		Expect(StreamID(7).InitiatedBy()).To(Equal(PerspectiveServer))
 	})
 
+	It("says if a stream was initiated locally", func() {
+		// client-initiated bidirectional stream
+		Expect(StreamID(4).IsLocal(PerspectiveClient)).To(BeTrue())
+		Expect(StreamID(4).IsLocal(PerspectiveServer)).To(BeFalse())
+		// server-initiated bidirectional stream
+		Expect(StreamID(5).IsLocal(PerspectiveClient)).To(BeFalse())
+		Expect(StreamID(5).IsLocal(PerspectiveServer)).To(BeTrue())
+		// client-initiated unidirectional stream
+		Expect(StreamID(6).IsLocal(PerspectiveClient)).To(BeTrue())
+		Expect(StreamID(6).IsLocal(PerspectiveServer)).To(BeFalse())
+		// server-initiated unidirectional stream
+		Expect(StreamID(7).IsLocal(PerspectiveClient)).To(BeFalse())
+		Expect(StreamID(7).IsLocal(PerspectiveServer)).To(BeTrue())
+	})
+
 	It("tells the directionality", func() {
 		Expect(StreamID(4).Type()).To(Equal(StreamTypeBidi))
 		Expect(StreamID(5).Type()).To(Equal(StreamTypeBidi))
@@ -109,6 +109,65 @@ func (l *defaultLogger) Debug() bool {
 	return l.logLevel == LogLevelDebug
 }
 
+// An ExternalLogger is a logger provided by the application.
+type ExternalLogger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// An ExternalDebugLogger is an ExternalLogger that reports if it logs debug messages.
+type ExternalDebugLogger interface {
+	ExternalLogger
+	Debug() bool
+}
+
+type externalLogger struct {
+	prefix string // escaped, so it can be used in a format string
+	logger ExternalLogger
+}
+
+var _ Logger = &externalLogger{}
+
+// NewExternalLogger creates a Logger that passes all messages to l.
+// l decides which messages are logged, so setting the log level and the time format has no effect.
+func NewExternalLogger(l ExternalLogger) Logger {
+	return &externalLogger{logger: l}
+}
+
+func (l *externalLogger) SetLogLevel(LogLevel) {}
+
+func (l *externalLogger) SetLogTimeFormat(string) {}
+
+func (l *externalLogger) WithPrefix(prefix string) Logger {
+	prefix = strings.ReplaceAll(prefix, "%", "%%") + " "
+	return &externalLogger{
+		prefix: l.prefix + prefix,
+		logger: l.logger,
+	}
+}
+
+// Debug returns true if the ExternalLogger implements the ExternalDebugLogger interface, and logs debug messages.
+// Generating debug messages is expensive, so they are not generated for loggers that don't implement it.
+func (l *externalLogger) Debug() bool {
+	if dl, ok := l.logger.(ExternalDebugLogger); ok {
+		return dl.Debug()
+	}
+	return false
+}
+
+func (l *externalLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(l.prefix+format, args...)
+}
+
+func (l *externalLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(l.prefix+format, args...)
+}
+
+func (l *externalLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(l.prefix+format, args...)
+}
+
 func init() {
 	DefaultLogger = &defaultLogger{}
 	DefaultLogger.SetLogLevel(readLoggingEnv())
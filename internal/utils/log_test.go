@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"time"
@@ -108,6 +109,42 @@ var _ = Describe("Log", func() {
 		Expect(b.String()).To(ContainSubstring("debug"))
 	})
 
+	Context("external loggers", func() {
+		var logger *recordingLogger
+
+		BeforeEach(func() {
+			logger = &recordingLogger{}
+		})
+
+		It("passes all messages to the external logger", func() {
+			l := NewExternalLogger(logger)
+			l.SetLogLevel(LogLevelNothing)
+			l.Debugf("debug %d", 1)
+			l.Infof("info %d", 2)
+			l.Errorf("err %d", 3)
+			Expect(logger.messages).To(Equal([]string{"DEBUG debug 1", "INFO info 2", "ERROR err 3"}))
+			Expect(b.String()).To(BeEmpty())
+		})
+
+		It("doesn't report debug logging if the external logger doesn't have a Debug method", func() {
+			Expect(NewExternalLogger(logger).Debug()).To(BeFalse())
+		})
+
+		It("asks the external logger if debug logging is enabled", func() {
+			dl := &recordingDebugLogger{}
+			l := NewExternalLogger(dl).WithPrefix("prefix")
+			Expect(l.Debug()).To(BeFalse())
+			dl.debug = true
+			Expect(l.Debug()).To(BeTrue())
+		})
+
+		It("adds prefixes", func() {
+			l := NewExternalLogger(logger).WithPrefix("prefix1").WithPrefix("100%")
+			l.Infof("info %d", 42)
+			Expect(logger.messages).To(Equal([]string{"INFO prefix1 100% info 42"}))
+		})
+	})
+
 	Context("reading from env", func() {
 		BeforeEach(func() {
 			Expect(DefaultLogger.(*defaultLogger).logLevel).To(Equal(LogLevelNothing))
@@ -142,3 +179,26 @@ var _ = Describe("Log", func() {
 		})
 	})
 })
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, "DEBUG "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.messages = append(l.messages, "INFO "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.messages = append(l.messages, "ERROR "+fmt.Sprintf(format, args...))
+}
+
+type recordingDebugLogger struct {
+	recordingLogger
+	debug bool
+}
+
+func (l *recordingDebugLogger) Debug() bool { return l.debug }
@@ -117,6 +117,10 @@ type ConnectionTracer interface {
 	LostPacket(EncryptionLevel, PacketNumber, PacketLossReason)
 	UpdatedCongestionState(CongestionState)
 	UpdatedPTOCount(value uint32)
+	// UpdatedAmplificationLimited is called when the server becomes blocked by the anti-amplification limit,
+	// i.e. it has sent 3x the bytes it received from an unvalidated client,
+	// and again when it is unblocked by receiving more data or by validating the client's address.
+	UpdatedAmplificationLimited(limited bool)
 	UpdatedKeyFromTLS(EncryptionLevel, Perspective)
 	UpdatedKey(generation KeyPhase, remote bool)
 	DroppedEncryptionLevel(EncryptionLevel)
@@ -254,6 +254,18 @@ func (mr *MockConnectionTracerMockRecorder) StartedConnection(arg0, arg1, arg2,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartedConnection", reflect.TypeOf((*MockConnectionTracer)(nil).StartedConnection), arg0, arg1, arg2, arg3, arg4)
 }
 
+// UpdatedAmplificationLimited mocks base method
+func (m *MockConnectionTracer) UpdatedAmplificationLimited(arg0 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "UpdatedAmplificationLimited", arg0)
+}
+
+// UpdatedAmplificationLimited indicates an expected call of UpdatedAmplificationLimited
+func (mr *MockConnectionTracerMockRecorder) UpdatedAmplificationLimited(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatedAmplificationLimited", reflect.TypeOf((*MockConnectionTracer)(nil).UpdatedAmplificationLimited), arg0)
+}
+
 // UpdatedCongestionState mocks base method
 func (m *MockConnectionTracer) UpdatedCongestionState(arg0 CongestionState) {
 	m.ctrl.T.Helper()
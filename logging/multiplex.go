@@ -144,6 +144,12 @@ func (m *connTracerMultiplexer) UpdatedPTOCount(value uint32) {
 	}
 }
 
+func (m *connTracerMultiplexer) UpdatedAmplificationLimited(limited bool) {
+	for _, t := range m.tracers {
+		t.UpdatedAmplificationLimited(limited)
+	}
+}
+
 func (m *connTracerMultiplexer) UpdatedKeyFromTLS(encLevel EncryptionLevel, perspective Perspective) {
 	for _, t := range m.tracers {
 		t.UpdatedKeyFromTLS(encLevel, perspective)
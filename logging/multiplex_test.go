@@ -195,6 +195,12 @@ var _ = Describe("Tracing", func() {
 			tracer.UpdatedPTOCount(88)
 		})
 
+		It("traces the UpdatedAmplificationLimited event", func() {
+			tr1.EXPECT().UpdatedAmplificationLimited(true)
+			tr2.EXPECT().UpdatedAmplificationLimited(true)
+			tracer.UpdatedAmplificationLimited(true)
+		})
+
 		It("traces the UpdatedKeyFromTLS event", func() {
 			tr1.EXPECT().UpdatedKeyFromTLS(EncryptionHandshake, PerspectiveClient)
 			tr2.EXPECT().UpdatedKeyFromTLS(EncryptionHandshake, PerspectiveClient)
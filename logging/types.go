@@ -80,6 +80,9 @@ const (
 	// TimeoutReasonIdle is used when the session is closed due to an idle timeout
 	// This reason is not defined in the qlog draft, but very useful for debugging.
 	TimeoutReasonIdle
+	// TimeoutReasonSendStall is used when the session is closed because the peer stopped acknowledging packets
+	// This reason is not defined in the qlog draft, but very useful for debugging.
+	TimeoutReasonSendStall
 )
 
 type CongestionState uint8
@@ -211,6 +211,7 @@ func (t *connTracer) UpdatedPTOCount(value uint32) {
 		ptos.M(1),
 	)
 }
+func (t *connTracer) UpdatedAmplificationLimited(bool)                                   {}
 func (t *connTracer) UpdatedKeyFromTLS(logging.EncryptionLevel, logging.Perspective)     {}
 func (t *connTracer) UpdatedKey(logging.KeyPhase, bool)                                  {}
 func (t *connTracer) DroppedEncryptionLevel(logging.EncryptionLevel)                     {}
@@ -5,6 +5,7 @@
 package quic
 
 import (
+	net "net"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
@@ -173,3 +174,27 @@ func (mr *MockPacketHandlerManagerMockRecorder) SetServer(arg0 interface{}) *gom
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetServer", reflect.TypeOf((*MockPacketHandlerManager)(nil).SetServer), arg0)
 }
+
+// SetUnknownPacketHandler mocks base method
+func (m *MockPacketHandlerManager) SetUnknownPacketHandler(arg0 func([]byte, net.Addr)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetUnknownPacketHandler", arg0)
+}
+
+// SetUnknownPacketHandler indicates an expected call of SetUnknownPacketHandler
+func (mr *MockPacketHandlerManagerMockRecorder) SetUnknownPacketHandler(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUnknownPacketHandler", reflect.TypeOf((*MockPacketHandlerManager)(nil).SetUnknownPacketHandler), arg0)
+}
+
+// handlePacket mocks base method
+func (m *MockPacketHandlerManager) handlePacket(arg0 *receivedPacket) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "handlePacket", arg0)
+}
+
+// handlePacket indicates an expected call of handlePacket
+func (mr *MockPacketHandlerManagerMockRecorder) handlePacket(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "handlePacket", reflect.TypeOf((*MockPacketHandlerManager)(nil).handlePacket), arg0)
+}
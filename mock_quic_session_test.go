@@ -8,6 +8,7 @@ import (
 	context "context"
 	net "net"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
@@ -66,6 +67,34 @@ func (mr *MockQuicSessionMockRecorder) AcceptUniStream(arg0 interface{}) *gomock
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockQuicSession)(nil).AcceptUniStream), arg0)
 }
 
+// CanOpenStreams mocks base method
+func (m *MockQuicSession) CanOpenStreams(arg0 int) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanOpenStreams", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanOpenStreams indicates an expected call of CanOpenStreams
+func (mr *MockQuicSessionMockRecorder) CanOpenStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanOpenStreams", reflect.TypeOf((*MockQuicSession)(nil).CanOpenStreams), arg0)
+}
+
+// CanOpenUniStreams mocks base method
+func (m *MockQuicSession) CanOpenUniStreams(arg0 int) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanOpenUniStreams", arg0)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanOpenUniStreams indicates an expected call of CanOpenUniStreams
+func (mr *MockQuicSessionMockRecorder) CanOpenUniStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanOpenUniStreams", reflect.TypeOf((*MockQuicSession)(nil).CanOpenUniStreams), arg0)
+}
+
 // CloseWithError mocks base method
 func (m *MockQuicSession) CloseWithError(arg0 protocol.ApplicationErrorCode, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -108,6 +137,20 @@ func (mr *MockQuicSessionMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockQuicSession)(nil).Context))
 }
 
+// Events mocks base method
+func (m *MockQuicSession) Events() <-chan Event {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Events")
+	ret0, _ := ret[0].(<-chan Event)
+	return ret0
+}
+
+// Events indicates an expected call of Events
+func (mr *MockQuicSessionMockRecorder) Events() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Events", reflect.TypeOf((*MockQuicSession)(nil).Events))
+}
+
 // GetVersion mocks base method
 func (m *MockQuicSession) GetVersion() protocol.VersionNumber {
 	m.ctrl.T.Helper()
@@ -136,6 +179,20 @@ func (mr *MockQuicSessionMockRecorder) HandshakeComplete() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandshakeComplete", reflect.TypeOf((*MockQuicSession)(nil).HandshakeComplete))
 }
 
+// IdleStreams mocks base method
+func (m *MockQuicSession) IdleStreams(arg0 time.Duration) []Stream {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdleStreams", arg0)
+	ret0, _ := ret[0].([]Stream)
+	return ret0
+}
+
+// IdleStreams indicates an expected call of IdleStreams
+func (mr *MockQuicSessionMockRecorder) IdleStreams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdleStreams", reflect.TypeOf((*MockQuicSession)(nil).IdleStreams), arg0)
+}
+
 // LocalAddr mocks base method
 func (m *MockQuicSession) LocalAddr() net.Addr {
 	m.ctrl.T.Helper()
@@ -150,6 +207,48 @@ func (mr *MockQuicSessionMockRecorder) LocalAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LocalAddr", reflect.TypeOf((*MockQuicSession)(nil).LocalAddr))
 }
 
+// MigrateTo mocks base method
+func (m *MockQuicSession) MigrateTo(arg0 net.Addr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MigrateTo", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MigrateTo indicates an expected call of MigrateTo
+func (mr *MockQuicSessionMockRecorder) MigrateTo(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MigrateTo", reflect.TypeOf((*MockQuicSession)(nil).MigrateTo), arg0)
+}
+
+// NextStreamID mocks base method
+func (m *MockQuicSession) NextStreamID() protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextStreamID")
+	ret0, _ := ret[0].(protocol.StreamID)
+	return ret0
+}
+
+// NextStreamID indicates an expected call of NextStreamID
+func (mr *MockQuicSessionMockRecorder) NextStreamID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextStreamID", reflect.TypeOf((*MockQuicSession)(nil).NextStreamID))
+}
+
+// NextUniStreamID mocks base method
+func (m *MockQuicSession) NextUniStreamID() protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextUniStreamID")
+	ret0, _ := ret[0].(protocol.StreamID)
+	return ret0
+}
+
+// NextUniStreamID indicates an expected call of NextUniStreamID
+func (mr *MockQuicSessionMockRecorder) NextUniStreamID() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextUniStreamID", reflect.TypeOf((*MockQuicSession)(nil).NextUniStreamID))
+}
+
 // OpenStream mocks base method
 func (m *MockQuicSession) OpenStream() (Stream, error) {
 	m.ctrl.T.Helper()
@@ -165,6 +264,20 @@ func (mr *MockQuicSessionMockRecorder) OpenStream() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenStream", reflect.TypeOf((*MockQuicSession)(nil).OpenStream))
 }
 
+// OpenStreamIDs mocks base method
+func (m *MockQuicSession) OpenStreamIDs() []protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenStreamIDs")
+	ret0, _ := ret[0].([]protocol.StreamID)
+	return ret0
+}
+
+// OpenStreamIDs indicates an expected call of OpenStreamIDs
+func (mr *MockQuicSessionMockRecorder) OpenStreamIDs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenStreamIDs", reflect.TypeOf((*MockQuicSession)(nil).OpenStreamIDs))
+}
+
 // OpenStreamSync mocks base method
 func (m *MockQuicSession) OpenStreamSync(arg0 context.Context) (Stream, error) {
 	m.ctrl.T.Helper()
@@ -210,6 +323,47 @@ func (mr *MockQuicSessionMockRecorder) OpenUniStreamSync(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockQuicSession)(nil).OpenUniStreamSync), arg0)
 }
 
+// PauseSending mocks base method
+func (m *MockQuicSession) PauseSending() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "PauseSending")
+}
+
+// PauseSending indicates an expected call of PauseSending
+func (mr *MockQuicSessionMockRecorder) PauseSending() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseSending", reflect.TypeOf((*MockQuicSession)(nil).PauseSending))
+}
+
+// Ping mocks base method
+func (m *MockQuicSession) Ping(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Ping", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Ping indicates an expected call of Ping
+func (mr *MockQuicSessionMockRecorder) Ping(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ping", reflect.TypeOf((*MockQuicSession)(nil).Ping), arg0)
+}
+
+// ProbeMTU mocks base method
+func (m *MockQuicSession) ProbeMTU(arg0 int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ProbeMTU", arg0)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ProbeMTU indicates an expected call of ProbeMTU
+func (mr *MockQuicSessionMockRecorder) ProbeMTU(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ProbeMTU", reflect.TypeOf((*MockQuicSession)(nil).ProbeMTU), arg0)
+}
+
 // ReceiveMessage mocks base method
 func (m *MockQuicSession) ReceiveMessage() ([]byte, error) {
 	m.ctrl.T.Helper()
@@ -225,6 +379,37 @@ func (mr *MockQuicSessionMockRecorder) ReceiveMessage() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessage", reflect.TypeOf((*MockQuicSession)(nil).ReceiveMessage))
 }
 
+// ReceiveMessageContext mocks base method
+func (m *MockQuicSession) ReceiveMessageContext(arg0 context.Context) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveMessageContext", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReceiveMessageContext indicates an expected call of ReceiveMessageContext
+func (mr *MockQuicSessionMockRecorder) ReceiveMessageContext(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessageContext", reflect.TypeOf((*MockQuicSession)(nil).ReceiveMessageContext), arg0)
+}
+
+// ReceiveMessageFrom mocks base method
+func (m *MockQuicSession) ReceiveMessageFrom(arg0 context.Context) ([]byte, net.Addr, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReceiveMessageFrom", arg0)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(net.Addr)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ReceiveMessageFrom indicates an expected call of ReceiveMessageFrom
+func (mr *MockQuicSessionMockRecorder) ReceiveMessageFrom(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReceiveMessageFrom", reflect.TypeOf((*MockQuicSession)(nil).ReceiveMessageFrom), arg0)
+}
+
 // RemoteAddr mocks base method
 func (m *MockQuicSession) RemoteAddr() net.Addr {
 	m.ctrl.T.Helper()
@@ -239,6 +424,30 @@ func (mr *MockQuicSessionMockRecorder) RemoteAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockQuicSession)(nil).RemoteAddr))
 }
 
+// ResumeSending mocks base method
+func (m *MockQuicSession) ResumeSending() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResumeSending")
+}
+
+// ResumeSending indicates an expected call of ResumeSending
+func (mr *MockQuicSessionMockRecorder) ResumeSending() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeSending", reflect.TypeOf((*MockQuicSession)(nil).ResumeSending))
+}
+
+// ResetStreams mocks base method
+func (m *MockQuicSession) ResetStreams(arg0 func(protocol.StreamID) bool, arg1 ErrorCode) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ResetStreams", arg0, arg1)
+}
+
+// ResetStreams indicates an expected call of ResetStreams
+func (mr *MockQuicSessionMockRecorder) ResetStreams(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetStreams", reflect.TypeOf((*MockQuicSession)(nil).ResetStreams), arg0, arg1)
+}
+
 // SendMessage mocks base method
 func (m *MockQuicSession) SendMessage(arg0 []byte) error {
 	m.ctrl.T.Helper()
@@ -253,6 +462,34 @@ func (mr *MockQuicSessionMockRecorder) SendMessage(arg0 interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessage", reflect.TypeOf((*MockQuicSession)(nil).SendMessage), arg0)
 }
 
+// SendMessageWithCallback mocks base method
+func (m *MockQuicSession) SendMessageWithCallback(arg0 []byte, arg1 func(bool)) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMessageWithCallback", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMessageWithCallback indicates an expected call of SendMessageWithCallback
+func (mr *MockQuicSessionMockRecorder) SendMessageWithCallback(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMessageWithCallback", reflect.TypeOf((*MockQuicSession)(nil).SendMessageWithCallback), arg0, arg1)
+}
+
+// Stats mocks base method
+func (m *MockQuicSession) Stats() SessionStats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(SessionStats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats
+func (mr *MockQuicSessionMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockQuicSession)(nil).Stats))
+}
+
 // destroy mocks base method
 func (m *MockQuicSession) destroy(arg0 error) {
 	m.ctrl.T.Helper()
@@ -265,6 +502,18 @@ func (mr *MockQuicSessionMockRecorder) destroy(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "destroy", reflect.TypeOf((*MockQuicSession)(nil).destroy), arg0)
 }
 
+// drain mocks base method
+func (m *MockQuicSession) drain() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "drain")
+}
+
+// drain indicates an expected call of drain
+func (mr *MockQuicSessionMockRecorder) drain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "drain", reflect.TypeOf((*MockQuicSession)(nil).drain))
+}
+
 // earlySessionReady mocks base method
 func (m *MockQuicSession) earlySessionReady() <-chan struct{} {
 	m.ctrl.T.Helper()
@@ -5,6 +5,7 @@
 package quic
 
 import (
+	context "context"
 	reflect "reflect"
 	time "time"
 
@@ -48,6 +49,32 @@ func (mr *MockReceiveStreamIMockRecorder) CancelRead(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelRead", reflect.TypeOf((*MockReceiveStreamI)(nil).CancelRead), arg0)
 }
 
+// CancelReadAndWait mocks base method
+func (m *MockReceiveStreamI) CancelReadAndWait(arg0 context.Context, arg1 protocol.ApplicationErrorCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelReadAndWait", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelReadAndWait indicates an expected call of CancelReadAndWait
+func (mr *MockReceiveStreamIMockRecorder) CancelReadAndWait(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelReadAndWait", reflect.TypeOf((*MockReceiveStreamI)(nil).CancelReadAndWait), arg0, arg1)
+}
+
+// Drain mocks base method
+func (m *MockReceiveStreamI) Drain() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Drain")
+}
+
+// Drain indicates an expected call of Drain
+func (mr *MockReceiveStreamIMockRecorder) Drain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockReceiveStreamI)(nil).Drain))
+}
+
 // Read mocks base method
 func (m *MockReceiveStreamI) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -144,3 +171,17 @@ func (mr *MockReceiveStreamIMockRecorder) handleStreamFrame(arg0 interface{}) *g
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "handleStreamFrame", reflect.TypeOf((*MockReceiveStreamI)(nil).handleStreamFrame), arg0)
 }
+
+// receiveWindowSize mocks base method
+func (m *MockReceiveStreamI) receiveWindowSize() protocol.ByteCount {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "receiveWindowSize")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// receiveWindowSize indicates an expected call of receiveWindowSize
+func (mr *MockReceiveStreamIMockRecorder) receiveWindowSize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "receiveWindowSize", reflect.TypeOf((*MockReceiveStreamI)(nil).receiveWindowSize))
+}
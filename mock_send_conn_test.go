@@ -76,6 +76,44 @@ func (mr *MockSendConnMockRecorder) RemoteAddr() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoteAddr", reflect.TypeOf((*MockSendConn)(nil).RemoteAddr))
 }
 
+// SetPacketConn mocks base method
+func (m *MockSendConn) SetPacketConn(arg0 net.PacketConn, arg1 bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetPacketConn", arg0, arg1)
+}
+
+// SetPacketConn indicates an expected call of SetPacketConn
+func (mr *MockSendConnMockRecorder) SetPacketConn(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetPacketConn", reflect.TypeOf((*MockSendConn)(nil).SetPacketConn), arg0, arg1)
+}
+
+// SetRemoteAddr mocks base method
+func (m *MockSendConn) SetRemoteAddr(arg0 net.Addr) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetRemoteAddr", arg0)
+}
+
+// SetRemoteAddr indicates an expected call of SetRemoteAddr
+func (mr *MockSendConnMockRecorder) SetRemoteAddr(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRemoteAddr", reflect.TypeOf((*MockSendConn)(nil).SetRemoteAddr), arg0)
+}
+
+// SupportsDF mocks base method
+func (m *MockSendConn) SupportsDF() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SupportsDF")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// SupportsDF indicates an expected call of SupportsDF
+func (mr *MockSendConnMockRecorder) SupportsDF() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SupportsDF", reflect.TypeOf((*MockSendConn)(nil).SupportsDF))
+}
+
 // Write mocks base method
 func (m *MockSendConn) Write(arg0 []byte) error {
 	m.ctrl.T.Helper()
@@ -89,3 +127,17 @@ func (mr *MockSendConnMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockSendConn)(nil).Write), arg0)
 }
+
+// WriteTo mocks base method
+func (m *MockSendConn) WriteTo(arg0 []byte, arg1 net.Addr) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteTo", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WriteTo indicates an expected call of WriteTo
+func (mr *MockSendConnMockRecorder) WriteTo(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteTo", reflect.TypeOf((*MockSendConn)(nil).WriteTo), arg0, arg1)
+}
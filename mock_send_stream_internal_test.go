@@ -121,6 +121,20 @@ func (mr *MockSendStreamIMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockSendStreamI)(nil).Write), arg0)
 }
 
+// Writable mocks base method
+func (m *MockSendStreamI) Writable() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Writable")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Writable indicates an expected call of Writable
+func (mr *MockSendStreamIMockRecorder) Writable() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Writable", reflect.TypeOf((*MockSendStreamI)(nil).Writable))
+}
+
 // closeForShutdown mocks base method
 func (m *MockSendStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -185,3 +199,17 @@ func (mr *MockSendStreamIMockRecorder) popStreamFrame(arg0 interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "popStreamFrame", reflect.TypeOf((*MockSendStreamI)(nil).popStreamFrame), arg0)
 }
+
+// sendWindowSize mocks base method
+func (m *MockSendStreamI) sendWindowSize() protocol.ByteCount {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "sendWindowSize")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// sendWindowSize indicates an expected call of sendWindowSize
+func (mr *MockSendStreamIMockRecorder) sendWindowSize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "sendWindowSize", reflect.TypeOf((*MockSendStreamI)(nil).sendWindowSize))
+}
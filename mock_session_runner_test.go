@@ -121,3 +121,15 @@ func (mr *MockSessionRunnerMockRecorder) Retire(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Retire", reflect.TypeOf((*MockSessionRunner)(nil).Retire), arg0)
 }
+
+// handlePacket mocks base method
+func (m *MockSessionRunner) handlePacket(arg0 *receivedPacket) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "handlePacket", arg0)
+}
+
+// handlePacket indicates an expected call of handlePacket
+func (mr *MockSessionRunnerMockRecorder) handlePacket(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "handlePacket", reflect.TypeOf((*MockSessionRunner)(nil).handlePacket), arg0)
+}
@@ -50,6 +50,20 @@ func (mr *MockStreamIMockRecorder) CancelRead(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelRead", reflect.TypeOf((*MockStreamI)(nil).CancelRead), arg0)
 }
 
+// CancelReadAndWait mocks base method
+func (m *MockStreamI) CancelReadAndWait(arg0 context.Context, arg1 protocol.ApplicationErrorCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelReadAndWait", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelReadAndWait indicates an expected call of CancelReadAndWait
+func (mr *MockStreamIMockRecorder) CancelReadAndWait(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelReadAndWait", reflect.TypeOf((*MockStreamI)(nil).CancelReadAndWait), arg0, arg1)
+}
+
 // CancelWrite mocks base method
 func (m *MockStreamI) CancelWrite(arg0 protocol.ApplicationErrorCode) {
 	m.ctrl.T.Helper()
@@ -90,6 +104,18 @@ func (mr *MockStreamIMockRecorder) Context() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Context", reflect.TypeOf((*MockStreamI)(nil).Context))
 }
 
+// Drain mocks base method
+func (m *MockStreamI) Drain() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Drain")
+}
+
+// Drain indicates an expected call of Drain
+func (mr *MockStreamIMockRecorder) Drain() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Drain", reflect.TypeOf((*MockStreamI)(nil).Drain))
+}
+
 // Read mocks base method
 func (m *MockStreamI) Read(arg0 []byte) (int, error) {
 	m.ctrl.T.Helper()
@@ -176,6 +202,20 @@ func (mr *MockStreamIMockRecorder) Write(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockStreamI)(nil).Write), arg0)
 }
 
+// Writable mocks base method
+func (m *MockStreamI) Writable() <-chan struct{} {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Writable")
+	ret0, _ := ret[0].(<-chan struct{})
+	return ret0
+}
+
+// Writable indicates an expected call of Writable
+func (mr *MockStreamIMockRecorder) Writable() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Writable", reflect.TypeOf((*MockStreamI)(nil).Writable))
+}
+
 // closeForShutdown mocks base method
 func (m *MockStreamI) closeForShutdown(arg0 error) {
 	m.ctrl.T.Helper()
@@ -268,6 +308,20 @@ func (mr *MockStreamIMockRecorder) hasData() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "hasData", reflect.TypeOf((*MockStreamI)(nil).hasData))
 }
 
+// lastActivity mocks base method
+func (m *MockStreamI) lastActivity() time.Time {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "lastActivity")
+	ret0, _ := ret[0].(time.Time)
+	return ret0
+}
+
+// lastActivity indicates an expected call of lastActivity
+func (mr *MockStreamIMockRecorder) lastActivity() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "lastActivity", reflect.TypeOf((*MockStreamI)(nil).lastActivity))
+}
+
 // popStreamFrame mocks base method
 func (m *MockStreamI) popStreamFrame(arg0 protocol.ByteCount) (*ackhandler.Frame, bool) {
 	m.ctrl.T.Helper()
@@ -282,3 +336,31 @@ func (mr *MockStreamIMockRecorder) popStreamFrame(arg0 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "popStreamFrame", reflect.TypeOf((*MockStreamI)(nil).popStreamFrame), arg0)
 }
+
+// receiveWindowSize mocks base method
+func (m *MockStreamI) receiveWindowSize() protocol.ByteCount {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "receiveWindowSize")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// receiveWindowSize indicates an expected call of receiveWindowSize
+func (mr *MockStreamIMockRecorder) receiveWindowSize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "receiveWindowSize", reflect.TypeOf((*MockStreamI)(nil).receiveWindowSize))
+}
+
+// sendWindowSize mocks base method
+func (m *MockStreamI) sendWindowSize() protocol.ByteCount {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "sendWindowSize")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	return ret0
+}
+
+// sendWindowSize indicates an expected call of sendWindowSize
+func (mr *MockStreamIMockRecorder) sendWindowSize() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "sendWindowSize", reflect.TypeOf((*MockStreamI)(nil).sendWindowSize))
+}
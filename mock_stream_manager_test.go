@@ -7,6 +7,7 @@ package quic
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	protocol "github.com/lucas-clemente/quic-go/internal/protocol"
@@ -66,6 +67,32 @@ func (mr *MockStreamManagerMockRecorder) AcceptUniStream(arg0 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptUniStream", reflect.TypeOf((*MockStreamManager)(nil).AcceptUniStream), arg0)
 }
 
+// CanOpenStreams mocks base method
+func (m *MockStreamManager) CanOpenStreams(arg0 protocol.StreamType, arg1 int) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CanOpenStreams", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// CanOpenStreams indicates an expected call of CanOpenStreams
+func (mr *MockStreamManagerMockRecorder) CanOpenStreams(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CanOpenStreams", reflect.TypeOf((*MockStreamManager)(nil).CanOpenStreams), arg0, arg1)
+}
+
+// CloseStreams mocks base method
+func (m *MockStreamManager) CloseStreams(arg0 func(protocol.StreamID) bool, arg1 func(sendStreamI), arg2 func(receiveStreamI)) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CloseStreams", arg0, arg1, arg2)
+}
+
+// CloseStreams indicates an expected call of CloseStreams
+func (mr *MockStreamManagerMockRecorder) CloseStreams(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseStreams", reflect.TypeOf((*MockStreamManager)(nil).CloseStreams), arg0, arg1, arg2)
+}
+
 // CloseWithError mocks base method
 func (m *MockStreamManager) CloseWithError(arg0 error) {
 	m.ctrl.T.Helper()
@@ -92,6 +119,21 @@ func (mr *MockStreamManagerMockRecorder) DeleteStream(arg0 interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStream", reflect.TypeOf((*MockStreamManager)(nil).DeleteStream), arg0)
 }
 
+// FlowControlWindows mocks base method
+func (m *MockStreamManager) FlowControlWindows() (protocol.ByteCount, protocol.ByteCount) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FlowControlWindows")
+	ret0, _ := ret[0].(protocol.ByteCount)
+	ret1, _ := ret[1].(protocol.ByteCount)
+	return ret0, ret1
+}
+
+// FlowControlWindows indicates an expected call of FlowControlWindows
+func (mr *MockStreamManagerMockRecorder) FlowControlWindows() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlowControlWindows", reflect.TypeOf((*MockStreamManager)(nil).FlowControlWindows))
+}
+
 // GetOrOpenReceiveStream mocks base method
 func (m *MockStreamManager) GetOrOpenReceiveStream(arg0 protocol.StreamID) (receiveStreamI, error) {
 	m.ctrl.T.Helper()
@@ -136,6 +178,62 @@ func (mr *MockStreamManagerMockRecorder) HandleMaxStreamsFrame(arg0 interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HandleMaxStreamsFrame", reflect.TypeOf((*MockStreamManager)(nil).HandleMaxStreamsFrame), arg0)
 }
 
+// IdleStreams mocks base method
+func (m *MockStreamManager) IdleStreams(arg0 time.Time, arg1 time.Duration) []streamI {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IdleStreams", arg0, arg1)
+	ret0, _ := ret[0].([]streamI)
+	return ret0
+}
+
+// IdleStreams indicates an expected call of IdleStreams
+func (mr *MockStreamManagerMockRecorder) IdleStreams(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IdleStreams", reflect.TypeOf((*MockStreamManager)(nil).IdleStreams), arg0, arg1)
+}
+
+// MaxConcurrentStreams mocks base method
+func (m *MockStreamManager) MaxConcurrentStreams() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MaxConcurrentStreams")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// MaxConcurrentStreams indicates an expected call of MaxConcurrentStreams
+func (mr *MockStreamManagerMockRecorder) MaxConcurrentStreams() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MaxConcurrentStreams", reflect.TypeOf((*MockStreamManager)(nil).MaxConcurrentStreams))
+}
+
+// NextStreamID mocks base method
+func (m *MockStreamManager) NextStreamID(arg0 protocol.StreamType) protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextStreamID", arg0)
+	ret0, _ := ret[0].(protocol.StreamID)
+	return ret0
+}
+
+// NextStreamID indicates an expected call of NextStreamID
+func (mr *MockStreamManagerMockRecorder) NextStreamID(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextStreamID", reflect.TypeOf((*MockStreamManager)(nil).NextStreamID), arg0)
+}
+
+// NumberOfStreams mocks base method
+func (m *MockStreamManager) NumberOfStreams() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NumberOfStreams")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// NumberOfStreams indicates an expected call of NumberOfStreams
+func (mr *MockStreamManagerMockRecorder) NumberOfStreams() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NumberOfStreams", reflect.TypeOf((*MockStreamManager)(nil).NumberOfStreams))
+}
+
 // OpenStream mocks base method
 func (m *MockStreamManager) OpenStream() (Stream, error) {
 	m.ctrl.T.Helper()
@@ -151,6 +249,20 @@ func (mr *MockStreamManagerMockRecorder) OpenStream() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenStream", reflect.TypeOf((*MockStreamManager)(nil).OpenStream))
 }
 
+// OpenStreamIDs mocks base method
+func (m *MockStreamManager) OpenStreamIDs() []protocol.StreamID {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "OpenStreamIDs")
+	ret0, _ := ret[0].([]protocol.StreamID)
+	return ret0
+}
+
+// OpenStreamIDs indicates an expected call of OpenStreamIDs
+func (mr *MockStreamManagerMockRecorder) OpenStreamIDs() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenStreamIDs", reflect.TypeOf((*MockStreamManager)(nil).OpenStreamIDs))
+}
+
 // OpenStreamSync mocks base method
 func (m *MockStreamManager) OpenStreamSync(arg0 context.Context) (Stream, error) {
 	m.ctrl.T.Helper()
@@ -196,6 +308,18 @@ func (mr *MockStreamManagerMockRecorder) OpenUniStreamSync(arg0 interface{}) *go
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OpenUniStreamSync", reflect.TypeOf((*MockStreamManager)(nil).OpenUniStreamSync), arg0)
 }
 
+// StopGrantingStreams mocks base method
+func (m *MockStreamManager) StopGrantingStreams() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "StopGrantingStreams")
+}
+
+// StopGrantingStreams indicates an expected call of StopGrantingStreams
+func (mr *MockStreamManagerMockRecorder) StopGrantingStreams() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopGrantingStreams", reflect.TypeOf((*MockStreamManager)(nil).StopGrantingStreams))
+}
+
 // UpdateLimits mocks base method
 func (m *MockStreamManager) UpdateLimits(arg0 *wire.TransportParameters) {
 	m.ctrl.T.Helper()
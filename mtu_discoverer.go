@@ -0,0 +1,120 @@
+package quic
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/ackhandler"
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+type mtuDiscoverer interface {
+	ShouldSendProbe(now time.Time) bool
+	NextProbeTime() time.Time
+	GetPing() (ping ackhandler.Frame, datagramSize protocol.ByteCount)
+	// OnProbeResult is called with the result of a probe packet that was requested by the application.
+	OnProbeResult(size protocol.ByteCount, acked bool)
+}
+
+// errMTUProbeLost is the result of an MTU probe requested by the application that was lost.
+var errMTUProbeLost = errors.New("MTU probe lost")
+
+// An mtuProbeRequest is a request by the application to probe a packet size, see Session.ProbeMTU.
+type mtuProbeRequest struct {
+	size   protocol.ByteCount
+	result chan error // receives nil if the probe was acknowledged, and errMTUProbeLost if it was lost
+}
+
+const (
+	// At some point, we have to stop searching for a higher MTU.
+	// We're happy to send a packet that's 20 bytes smaller than the actual MTU.
+	maxMTUDiff = 20
+	// send a probe packet every mtuProbeDelay RTTs
+	mtuProbeDelay = 5
+)
+
+// The mtuFinder implements Datagram Packetization Layer Path MTU Discovery (DPLPMTUD),
+// as described in RFC 8899.
+// It performs a binary search between the current packet size and the maximum packet size.
+// Probe packets are PING frames, padded to the size being probed.
+// The packet size is only increased once a probe packet of that size was acknowledged.
+type mtuFinder struct {
+	lastProbeTime time.Time
+	probeInFlight bool
+	mtuIncreased  func(protocol.ByteCount)
+
+	rttStats *utils.RTTStats
+	current  protocol.ByteCount
+	max      protocol.ByteCount // the maximum value, as advertised by the peer (or our maximum size buffer)
+}
+
+var _ mtuDiscoverer = &mtuFinder{}
+
+func newMTUDiscoverer(rttStats *utils.RTTStats, start, max protocol.ByteCount, mtuIncreased func(protocol.ByteCount)) mtuDiscoverer {
+	return &mtuFinder{
+		current:       start,
+		rttStats:      rttStats,
+		lastProbeTime: time.Now(), // to make sure the first probe packet is not sent immediately
+		mtuIncreased:  mtuIncreased,
+		max:           max,
+	}
+}
+
+func (f *mtuFinder) done() bool {
+	return f.max-f.current <= maxMTUDiff+1
+}
+
+func (f *mtuFinder) ShouldSendProbe(now time.Time) bool {
+	if f.probeInFlight || f.done() {
+		return false
+	}
+	return !now.Before(f.NextProbeTime())
+}
+
+// NextProbeTime returns the time when the next probe packet should be sent.
+// It returns the zero value if no probe packet should be sent.
+func (f *mtuFinder) NextProbeTime() time.Time {
+	if f.probeInFlight || f.done() {
+		return time.Time{}
+	}
+	return f.lastProbeTime.Add(mtuProbeDelay * f.rttStats.SmoothedRTT())
+}
+
+func (f *mtuFinder) GetPing() (ackhandler.Frame, protocol.ByteCount) {
+	size := (f.max + f.current) / 2
+	f.lastProbeTime = time.Now()
+	f.probeInFlight = true
+	return ackhandler.Frame{
+		Frame: &wire.PingFrame{},
+		OnLost: func(wire.Frame) {
+			f.probeInFlight = false
+			f.OnProbeResult(size, false)
+		},
+		OnAcked: func(wire.Frame) {
+			f.probeInFlight = false
+			f.OnProbeResult(size, true)
+		},
+	}, size
+}
+
+// OnProbeResult narrows the search range.
+// Probes requested by the application might be in flight at the same time as our own probes,
+// so the results can arrive in any order.
+func (f *mtuFinder) OnProbeResult(size protocol.ByteCount, acked bool) {
+	if acked {
+		if size > f.current {
+			f.current = size
+			// A lost probe might have lowered the maximum below a size that is now known to work.
+			if f.max < size {
+				f.max = size
+			}
+			f.mtuIncreased(size)
+		}
+		return
+	}
+	if size > f.current && size < f.max {
+		f.max = size
+	}
+}
@@ -0,0 +1,116 @@
+package quic
+
+import (
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/utils"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MTU Discoverer", func() {
+	const (
+		rtt                         = 100 * time.Millisecond
+		startMTU protocol.ByteCount = 1000
+		maxMTU   protocol.ByteCount = 2000
+	)
+
+	var (
+		d             mtuDiscoverer
+		rttStats      *utils.RTTStats
+		now           time.Time
+		discoveredMTU protocol.ByteCount
+	)
+
+	BeforeEach(func() {
+		rttStats = &utils.RTTStats{}
+		rttStats.SetInitialRTT(rtt)
+		Expect(rttStats.SmoothedRTT()).To(Equal(rtt))
+		discoveredMTU = 0
+		d = newMTUDiscoverer(rttStats, startMTU, maxMTU, func(s protocol.ByteCount) { discoveredMTU = s })
+		now = time.Now()
+	})
+
+	It("only allows a probe 5 RTTs after the handshake completes", func() {
+		Expect(d.ShouldSendProbe(now)).To(BeFalse())
+		Expect(d.ShouldSendProbe(now.Add(rtt * 9 / 2))).To(BeFalse())
+		Expect(d.NextProbeTime()).To(BeTemporally("~", now.Add(5*rtt), scaleDuration(20*time.Millisecond)))
+		Expect(d.ShouldSendProbe(now.Add(rtt * 5))).To(BeTrue())
+	})
+
+	It("doesn't allow a probe if another probe is still in flight", func() {
+		ping, _ := d.GetPing()
+		Expect(d.ShouldSendProbe(now.Add(10 * rtt))).To(BeFalse())
+		Expect(d.NextProbeTime()).To(BeZero())
+		ping.OnLost(ping.Frame)
+		Expect(d.ShouldSendProbe(now.Add(10 * rtt))).To(BeTrue())
+		Expect(d.NextProbeTime()).ToNot(BeZero())
+	})
+
+	It("tries a lower size when a probe is lost", func() {
+		ping, size := d.GetPing()
+		Expect(size).To(Equal(protocol.ByteCount(1500)))
+		ping.OnLost(ping.Frame)
+		_, size = d.GetPing()
+		Expect(size).To(Equal(protocol.ByteCount(1250)))
+		Expect(discoveredMTU).To(BeZero())
+	})
+
+	It("only increases the size when a probe is acknowledged", func() {
+		ping, size := d.GetPing()
+		Expect(size).To(Equal(protocol.ByteCount(1500)))
+		Expect(discoveredMTU).To(BeZero())
+		ping.OnAcked(ping.Frame)
+		Expect(discoveredMTU).To(Equal(protocol.ByteCount(1500)))
+		_, size = d.GetPing()
+		Expect(size).To(Equal(protocol.ByteCount(1750)))
+	})
+
+	It("stops discovery after getting close enough to the MTU", func() {
+		var sizes []protocol.ByteCount
+		t := now.Add(5 * rtt)
+		for d.ShouldSendProbe(t) {
+			ping, size := d.GetPing()
+			ping.OnAcked(ping.Frame)
+			sizes = append(sizes, size)
+			t = t.Add(5 * rtt)
+		}
+		Expect(sizes).To(Equal([]protocol.ByteCount{1500, 1750, 1875, 1937, 1968, 1984}))
+		Expect(discoveredMTU).To(Equal(protocol.ByteCount(1984)))
+		Expect(d.NextProbeTime()).To(BeZero())
+	})
+
+	Context("probes requested by the application", func() {
+		It("increases the size when a probe is acknowledged", func() {
+			d.OnProbeResult(1800, true)
+			Expect(discoveredMTU).To(Equal(protocol.ByteCount(1800)))
+			_, size := d.GetPing()
+			Expect(size).To(Equal(protocol.ByteCount(1900)))
+		})
+
+		It("tries a lower size when a probe is lost", func() {
+			d.OnProbeResult(1200, false)
+			_, size := d.GetPing()
+			Expect(size).To(Equal(protocol.ByteCount(1100)))
+		})
+
+		It("doesn't decrease the size when a smaller probe is acknowledged later", func() {
+			ping, size := d.GetPing()
+			Expect(size).To(Equal(protocol.ByteCount(1500)))
+			d.OnProbeResult(1800, true)
+			ping.OnAcked(ping.Frame)
+			Expect(discoveredMTU).To(Equal(protocol.ByteCount(1800)))
+		})
+
+		It("increases the maximum when a probe larger than a lost probe is acknowledged", func() {
+			ping, size := d.GetPing()
+			Expect(size).To(Equal(protocol.ByteCount(1500)))
+			ping.OnLost(ping.Frame)
+			d.OnProbeResult(1600, true)
+			Expect(discoveredMTU).To(Equal(protocol.ByteCount(1600)))
+			Expect(d.NextProbeTime()).To(BeZero())
+		})
+	})
+})
@@ -40,6 +40,8 @@ type packetHandlerMap struct {
 	resetTokens map[protocol.StatelessResetToken] /* stateless reset token */ packetHandler
 	server      unknownPacketHandler
 
+	unknownPacketHandler func(data []byte, addr net.Addr) // called for packets that aren't QUIC packets
+
 	listening chan struct{} // is closed when listen returns
 	closed    bool
 
@@ -228,6 +230,12 @@ func (h *packetHandlerMap) SetServer(s unknownPacketHandler) {
 	h.mutex.Unlock()
 }
 
+func (h *packetHandlerMap) SetUnknownPacketHandler(handler func(data []byte, addr net.Addr)) {
+	h.mutex.Lock()
+	h.unknownPacketHandler = handler
+	h.mutex.Unlock()
+}
+
 func (h *packetHandlerMap) CloseServer() {
 	h.mutex.Lock()
 	if h.server == nil {
@@ -302,8 +310,15 @@ func (h *packetHandlerMap) listen() {
 }
 
 func (h *packetHandlerMap) handlePacket(p *receivedPacket) {
+	// QUIC packets always have the fixed bit set.
+	if len(p.data) > 0 && p.data[0]&0x40 == 0 && h.maybeHandleUnknownPacket(p) {
+		return
+	}
 	connID, err := wire.ParseConnectionID(p.data, h.connIDLen)
 	if err != nil {
+		if h.maybeHandleUnknownPacket(p) {
+			return
+		}
 		h.logger.Debugf("error parsing connection ID on packet from %s: %s", p.remoteAddr, err)
 		if h.tracer != nil {
 			h.tracer.DroppedPacket(p.remoteAddr, logging.PacketTypeNotDetermined, p.Size(), logging.PacketDropHeaderParseError)
@@ -313,20 +328,27 @@ func (h *packetHandlerMap) handlePacket(p *receivedPacket) {
 	}
 
 	h.mutex.Lock()
-	defer h.mutex.Unlock()
-
 	if isStatelessReset := h.maybeHandleStatelessReset(p.data); isStatelessReset {
+		h.mutex.Unlock()
 		return
 	}
 
 	if handler, ok := h.handlers[string(connID)]; ok { // existing session
 		handler.handlePacket(p)
+		h.mutex.Unlock()
 		return
 	}
 	if p.data[0]&0x80 == 0 {
+		// A short header packet that doesn't belong to any session might not be a QUIC packet at all.
+		hasUnknownPacketHandler := h.unknownPacketHandler != nil
+		h.mutex.Unlock()
+		if hasUnknownPacketHandler && h.maybeHandleUnknownPacket(p) {
+			return
+		}
 		go h.maybeSendStatelessReset(p, connID)
 		return
 	}
+	defer h.mutex.Unlock()
 	if h.server == nil { // no server set
 		h.logger.Debugf("received a packet with an unexpected connection ID %s", connID)
 		return
@@ -334,6 +356,21 @@ func (h *packetHandlerMap) handlePacket(p *receivedPacket) {
 	h.server.handlePacket(p)
 }
 
+// maybeHandleUnknownPacket passes a packet that isn't a QUIC packet to the unknown packet handler.
+// It returns false if no unknown packet handler is set.
+// It must be called without holding the mutex.
+func (h *packetHandlerMap) maybeHandleUnknownPacket(p *receivedPacket) bool {
+	h.mutex.Lock()
+	handler := h.unknownPacketHandler
+	h.mutex.Unlock()
+	if handler == nil {
+		return false
+	}
+	handler(p.data, p.remoteAddr)
+	p.buffer.MaybeRelease()
+	return true
+}
+
 func (h *packetHandlerMap) maybeHandleStatelessReset(data []byte) bool {
 	// stateless resets are always short header packets
 	if data[0]&0x80 != 0 {
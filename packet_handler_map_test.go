@@ -274,6 +274,70 @@ var _ = Describe("Packet Handler Map", func() {
 			})
 		})
 
+		Context("handling packets that aren't QUIC packets", func() {
+			var unknownPackets chan *receivedPacket
+
+			BeforeEach(func() {
+				connIDLen = 5
+				unknownPackets = make(chan *receivedPacket, 1)
+			})
+
+			JustBeforeEach(func() {
+				handler.SetUnknownPacketHandler(func(data []byte, addr net.Addr) {
+					unknownPackets <- &receivedPacket{data: append([]byte{}, data...), remoteAddr: addr}
+				})
+			})
+
+			It("passes packets that don't have the fixed bit set to the unknown packet handler", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(9, 8, 7, 6), Port: 1234}
+				// a STUN Binding Request, see RFC 5389
+				data := append([]byte{0x0, 0x1, 0x0, 0x0, 0x21, 0x12, 0xa4, 0x42}, make([]byte, 12)...)
+				handler.handlePacket(&receivedPacket{
+					buffer:     getPacketBuffer(),
+					remoteAddr: addr,
+					data:       data,
+				})
+				var p *receivedPacket
+				Expect(unknownPackets).To(Receive(&p))
+				Expect(p.data).To(Equal(data))
+				Expect(p.remoteAddr).To(Equal(addr))
+			})
+
+			It("passes unparseable packets to the unknown packet handler", func() {
+				handler.handlePacket(&receivedPacket{
+					buffer: getPacketBuffer(),
+					data:   []byte{0x40, 0x1, 0x2},
+				})
+				var p *receivedPacket
+				Expect(unknownPackets).To(Receive(&p))
+				Expect(p.data).To(Equal([]byte{0x40, 0x1, 0x2}))
+			})
+
+			It("passes short header packets for unknown connection IDs to the unknown packet handler", func() {
+				data := append([]byte{0x40}, make([]byte, 100)...)
+				handler.handlePacket(&receivedPacket{
+					buffer: getPacketBuffer(),
+					data:   data,
+				})
+				var p *receivedPacket
+				Expect(unknownPackets).To(Receive(&p))
+				Expect(p.data).To(Equal(data))
+			})
+
+			It("handles QUIC packets as usual", func() {
+				connID := protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8}
+				packetHandler := NewMockPacketHandler(mockCtrl)
+				packetHandler.EXPECT().handlePacket(gomock.Any())
+				handler.Add(connID, packetHandler)
+				handler.handlePacket(&receivedPacket{data: getPacket(connID)})
+				server := NewMockUnknownPacketHandler(mockCtrl)
+				server.EXPECT().handlePacket(gomock.Any())
+				handler.SetServer(server)
+				handler.handlePacket(&receivedPacket{data: getPacket(protocol.ConnectionID{8, 7, 6, 5, 4, 3, 2, 1})})
+				Expect(unknownPackets).ToNot(Receive())
+			})
+		})
+
 		Context("stateless resets", func() {
 			BeforeEach(func() {
 				connIDLen = 5
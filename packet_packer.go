@@ -16,14 +16,17 @@ import (
 )
 
 type packer interface {
-	PackCoalescedPacket() (*coalescedPacket, error)
+	PackCoalescedPacket(onlyAck bool) (*coalescedPacket, error)
 	PackPacket() (*packedPacket, error)
 	MaybePackProbePacket(protocol.EncryptionLevel) (*packedPacket, error)
-	MaybePackAckPacket(handshakeConfirmed bool) (*packedPacket, error)
+	MaybePackAckPacket() (*packedPacket, error)
+	PackPathProbePacket(wire.Frame) (*packedPacket, error)
+	PackMTUProbePacket(ping ackhandler.Frame, size protocol.ByteCount) (*packedPacket, error)
 	PackConnectionClose(*qerr.QuicError) (*coalescedPacket, error)
 
 	HandleTransportParameters(*wire.TransportParameters)
 	SetToken([]byte)
+	SetMaxPacketSize(protocol.ByteCount)
 }
 
 type sealer interface {
@@ -47,6 +50,8 @@ type packetContents struct {
 	frames []ackhandler.Frame
 
 	length protocol.ByteCount
+
+	isMTUProbePacket bool
 }
 
 type coalescedPacket struct {
@@ -95,12 +100,13 @@ func (p *packetContents) ToAckHandlerPacket(now time.Time, q *retransmissionQueu
 		}
 	}
 	return &ackhandler.Packet{
-		PacketNumber:    p.header.PacketNumber,
-		LargestAcked:    largestAcked,
-		Frames:          p.frames,
-		Length:          p.length,
-		EncryptionLevel: encLevel,
-		SendTime:        now,
+		PacketNumber:         p.header.PacketNumber,
+		LargestAcked:         largestAcked,
+		Frames:               p.frames,
+		Length:               p.length,
+		EncryptionLevel:      encLevel,
+		SendTime:             now,
+		IsPathMTUProbePacket: p.isMTUProbePacket,
 	}
 }
 
@@ -160,6 +166,7 @@ type packetPacker struct {
 	retransmissionQueue *retransmissionQueue
 
 	maxPacketSize          protocol.ByteCount
+	initialPacketSize      protocol.ByteCount // the packet size used until the handshake is confirmed
 	numNonAckElicitingAcks int
 }
 
@@ -173,6 +180,7 @@ func newPacketPacker(
 	packetNumberManager packetNumberManager,
 	retransmissionQueue *retransmissionQueue,
 	remoteAddr net.Addr, // only used for determining the max packet size
+	initialPacketSize protocol.ByteCount,
 	cryptoSetup sealingManager,
 	framer frameSource,
 	acks ackFrameSource,
@@ -180,6 +188,7 @@ func newPacketPacker(
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
 ) *packetPacker {
+	maxPacketSize := getMaxPacketSize(remoteAddr)
 	return &packetPacker{
 		cryptoSetup:         cryptoSetup,
 		getDestConnID:       getDestConnID,
@@ -193,7 +202,8 @@ func newPacketPacker(
 		framer:              framer,
 		acks:                acks,
 		pnManager:           packetNumberManager,
-		maxPacketSize:       getMaxPacketSize(remoteAddr),
+		maxPacketSize:       maxPacketSize,
+		initialPacketSize:   utils.MinByteCount(initialPacketSize, maxPacketSize),
 	}
 }
 
@@ -281,7 +291,7 @@ func (p *packetPacker) PackConnectionClose(quicErr *qerr.QuicError) (*coalescedP
 		if encLevel == protocol.EncryptionInitial {
 			paddingLen = p.paddingLen(payloads[i].frames, size)
 		}
-		c, err := p.appendPacket(buffer, hdrs[i], payloads[i], paddingLen, encLevel, sealers[i])
+		c, err := p.appendPacket(buffer, hdrs[i], payloads[i], paddingLen, encLevel, sealers[i], false)
 		if err != nil {
 			return nil, err
 		}
@@ -302,27 +312,11 @@ func (p *packetPacker) packetLength(hdr *wire.ExtendedHeader, payload *payload)
 	return hdr.GetLength(p.version) + payload.length + paddingLen
 }
 
-func (p *packetPacker) MaybePackAckPacket(handshakeConfirmed bool) (*packedPacket, error) {
-	var encLevel protocol.EncryptionLevel
-	var ack *wire.AckFrame
-	if !handshakeConfirmed {
-		ack = p.acks.GetAckFrame(protocol.EncryptionInitial, true)
-		if ack != nil {
-			encLevel = protocol.EncryptionInitial
-		} else {
-			ack = p.acks.GetAckFrame(protocol.EncryptionHandshake, true)
-			if ack != nil {
-				encLevel = protocol.EncryptionHandshake
-			}
-		}
-	}
-	if ack == nil {
-		ack = p.acks.GetAckFrame(protocol.Encryption1RTT, true)
-		if ack == nil {
-			return nil, nil
-		}
-		encLevel = protocol.Encryption1RTT
-	}
+// MaybePackAckPacket packs a 1-RTT packet containing only an ACK frame.
+// It should be called after the handshake is confirmed.
+// Before that, PackCoalescedPacket should be used, which coalesces the ACKs for all packet number spaces.
+func (p *packetPacker) MaybePackAckPacket() (*packedPacket, error) {
+	ack := p.acks.GetAckFrame(protocol.Encryption1RTT, true)
 	if ack == nil {
 		return nil, nil
 	}
@@ -331,11 +325,11 @@ func (p *packetPacker) MaybePackAckPacket(handshakeConfirmed bool) (*packedPacke
 		length: ack.Length(p.version),
 	}
 
-	sealer, hdr, err := p.getSealerAndHeader(encLevel)
+	sealer, hdr, err := p.getSealerAndHeader(protocol.Encryption1RTT)
 	if err != nil {
 		return nil, err
 	}
-	return p.writeSinglePacket(hdr, payload, encLevel, sealer)
+	return p.writeSinglePacket(hdr, payload, protocol.Encryption1RTT, sealer)
 }
 
 // only works for Initial packets
@@ -345,20 +339,19 @@ func (p *packetPacker) paddingLen(frames []ackhandler.Frame, size protocol.ByteC
 	if p.perspective == protocol.PerspectiveServer && !ackhandler.HasAckElicitingFrames(frames) {
 		return 0
 	}
-	if size >= p.maxPacketSize {
+	if size >= p.initialPacketSize {
 		return 0
 	}
-	return p.maxPacketSize - size
+	return p.initialPacketSize - size
 }
 
 // PackCoalescedPacket packs a new packet.
 // It packs an Initial / Handshake if there is data to send in these packet number spaces.
+// If onlyAck is set, the packets only contain the ACK frames that are due to be sent,
+// with ACKs for different packet number spaces coalesced into one datagram.
 // It should only be called before the handshake is confirmed.
-func (p *packetPacker) PackCoalescedPacket() (*coalescedPacket, error) {
-	maxPacketSize := p.maxPacketSize
-	if p.perspective == protocol.PerspectiveClient {
-		maxPacketSize = protocol.MinInitialPacketSize
-	}
+func (p *packetPacker) PackCoalescedPacket(onlyAck bool) (*coalescedPacket, error) {
+	maxPacketSize := p.initialPacketSize
 	var initialHdr, handshakeHdr, appDataHdr *wire.ExtendedHeader
 	var initialPayload, handshakePayload, appDataPayload *payload
 	var numPackets int
@@ -369,7 +362,7 @@ func (p *packetPacker) PackCoalescedPacket() (*coalescedPacket, error) {
 	}
 	var size protocol.ByteCount
 	if initialSealer != nil {
-		initialHdr, initialPayload = p.maybeGetCryptoPacket(maxPacketSize-protocol.ByteCount(initialSealer.Overhead()), size, protocol.EncryptionInitial)
+		initialHdr, initialPayload = p.maybeGetCryptoPacket(maxPacketSize-protocol.ByteCount(initialSealer.Overhead()), protocol.EncryptionInitial, onlyAck)
 		if initialPayload != nil {
 			size += p.packetLength(initialHdr, initialPayload) + protocol.ByteCount(initialSealer.Overhead())
 			numPackets++
@@ -385,7 +378,7 @@ func (p *packetPacker) PackCoalescedPacket() (*coalescedPacket, error) {
 			return nil, err
 		}
 		if handshakeSealer != nil {
-			handshakeHdr, handshakePayload = p.maybeGetCryptoPacket(maxPacketSize-size-protocol.ByteCount(handshakeSealer.Overhead()), size, protocol.EncryptionHandshake)
+			handshakeHdr, handshakePayload = p.maybeGetCryptoPacket(maxPacketSize-size-protocol.ByteCount(handshakeSealer.Overhead()), protocol.EncryptionHandshake, onlyAck)
 			if handshakePayload != nil {
 				s := p.packetLength(handshakeHdr, handshakePayload) + protocol.ByteCount(handshakeSealer.Overhead())
 				size += s
@@ -399,7 +392,7 @@ func (p *packetPacker) PackCoalescedPacket() (*coalescedPacket, error) {
 	appDataEncLevel := protocol.Encryption1RTT
 	if size < maxPacketSize-protocol.MinCoalescedPacketSize {
 		var err error
-		appDataSealer, appDataHdr, appDataPayload = p.maybeGetAppDataPacket(maxPacketSize-size, size)
+		appDataSealer, appDataHdr, appDataPayload = p.maybeGetAppDataPacket(maxPacketSize-size, onlyAck)
 		if err != nil {
 			return nil, err
 		}
@@ -425,21 +418,21 @@ func (p *packetPacker) PackCoalescedPacket() (*coalescedPacket, error) {
 	}
 	if initialPayload != nil {
 		padding := p.paddingLen(initialPayload.frames, size)
-		cont, err := p.appendPacket(buffer, initialHdr, initialPayload, padding, protocol.EncryptionInitial, initialSealer)
+		cont, err := p.appendPacket(buffer, initialHdr, initialPayload, padding, protocol.EncryptionInitial, initialSealer, false)
 		if err != nil {
 			return nil, err
 		}
 		packet.packets = append(packet.packets, cont)
 	}
 	if handshakePayload != nil {
-		cont, err := p.appendPacket(buffer, handshakeHdr, handshakePayload, 0, protocol.EncryptionHandshake, handshakeSealer)
+		cont, err := p.appendPacket(buffer, handshakeHdr, handshakePayload, 0, protocol.EncryptionHandshake, handshakeSealer, false)
 		if err != nil {
 			return nil, err
 		}
 		packet.packets = append(packet.packets, cont)
 	}
 	if appDataPayload != nil {
-		cont, err := p.appendPacket(buffer, appDataHdr, appDataPayload, 0, appDataEncLevel, appDataSealer)
+		cont, err := p.appendPacket(buffer, appDataHdr, appDataPayload, 0, appDataEncLevel, appDataSealer, false)
 		if err != nil {
 			return nil, err
 		}
@@ -451,7 +444,7 @@ func (p *packetPacker) PackCoalescedPacket() (*coalescedPacket, error) {
 // PackPacket packs a packet in the application data packet number space.
 // It should be called after the handshake is confirmed.
 func (p *packetPacker) PackPacket() (*packedPacket, error) {
-	sealer, hdr, payload := p.maybeGetAppDataPacket(p.maxPacketSize, 0)
+	sealer, hdr, payload := p.maybeGetAppDataPacket(p.maxPacketSize, false)
 	if payload == nil {
 		return nil, nil
 	}
@@ -460,7 +453,7 @@ func (p *packetPacker) PackPacket() (*packedPacket, error) {
 	if hdr.IsLongHeader {
 		encLevel = protocol.Encryption0RTT
 	}
-	cont, err := p.appendPacket(buffer, hdr, payload, 0, encLevel, sealer)
+	cont, err := p.appendPacket(buffer, hdr, payload, 0, encLevel, sealer, false)
 	if err != nil {
 		return nil, err
 	}
@@ -470,7 +463,15 @@ func (p *packetPacker) PackPacket() (*packedPacket, error) {
 	}, nil
 }
 
-func (p *packetPacker) maybeGetCryptoPacket(maxPacketSize, currentSize protocol.ByteCount, encLevel protocol.EncryptionLevel) (*wire.ExtendedHeader, *payload) {
+func (p *packetPacker) maybeGetCryptoPacket(maxPacketSize protocol.ByteCount, encLevel protocol.EncryptionLevel, onlyAck bool) (*wire.ExtendedHeader, *payload) {
+	if onlyAck {
+		ack := p.acks.GetAckFrame(encLevel, true)
+		if ack == nil {
+			return nil, nil
+		}
+		return p.getLongHeader(encLevel), &payload{ack: ack, length: ack.Length(p.version)}
+	}
+
 	var s cryptoStream
 	var hasRetransmission bool
 	//nolint:exhaustive // Initial and Handshake are the only two encryption levels here.
@@ -484,10 +485,7 @@ func (p *packetPacker) maybeGetCryptoPacket(maxPacketSize, currentSize protocol.
 	}
 
 	hasData := s.HasData()
-	var ack *wire.AckFrame
-	if encLevel == protocol.EncryptionInitial || currentSize == 0 {
-		ack = p.acks.GetAckFrame(encLevel, !hasRetransmission && !hasData)
-	}
+	ack := p.acks.GetAckFrame(encLevel, !hasRetransmission && !hasData)
 	if !hasData && !hasRetransmission && ack == nil {
 		// nothing to send
 		return nil, nil
@@ -527,7 +525,7 @@ func (p *packetPacker) maybeGetCryptoPacket(maxPacketSize, currentSize protocol.
 	return hdr, &payload
 }
 
-func (p *packetPacker) maybeGetAppDataPacket(maxPacketSize, currentSize protocol.ByteCount) (sealer, *wire.ExtendedHeader, *payload) {
+func (p *packetPacker) maybeGetAppDataPacket(maxPacketSize protocol.ByteCount, onlyAck bool) (sealer, *wire.ExtendedHeader, *payload) {
 	var sealer sealer
 	var encLevel protocol.EncryptionLevel
 	var hdr *wire.ExtendedHeader
@@ -549,8 +547,20 @@ func (p *packetPacker) maybeGetAppDataPacket(maxPacketSize, currentSize protocol
 		hdr = p.getLongHeader(protocol.Encryption0RTT)
 	}
 
+	if onlyAck {
+		// 0-RTT packets can't contain ACK frames
+		if encLevel != protocol.Encryption1RTT {
+			return nil, nil, nil
+		}
+		ack := p.acks.GetAckFrame(protocol.Encryption1RTT, true)
+		if ack == nil {
+			return nil, nil, nil
+		}
+		return sealer, hdr, &payload{ack: ack, length: ack.Length(p.version)}
+	}
+
 	maxPayloadSize := maxPacketSize - hdr.GetLength(p.version) - protocol.ByteCount(sealer.Overhead())
-	payload := p.maybeGetAppDataPacketWithEncLevel(maxPayloadSize, encLevel == protocol.Encryption1RTT && currentSize == 0)
+	payload := p.maybeGetAppDataPacketWithEncLevel(maxPayloadSize, encLevel == protocol.Encryption1RTT)
 	return sealer, hdr, payload
 }
 
@@ -584,11 +594,16 @@ func (p *packetPacker) composeNextPacket(maxFrameSize protocol.ByteCount, ackAll
 	var hasDatagram bool
 	if p.datagramQueue != nil {
 		if datagram := p.datagramQueue.Get(); datagram != nil {
-			payload.frames = append(payload.frames, ackhandler.Frame{
-				Frame: datagram,
+			frame := ackhandler.Frame{
+				Frame: datagram.DatagramFrame,
 				// set it to a no-op. Then we won't set the default callback, which would retransmit the frame.
 				OnLost: func(wire.Frame) {},
-			})
+			}
+			if callback := datagram.callback; callback != nil {
+				frame.OnLost = func(wire.Frame) { callback(false) }
+				frame.OnAcked = func(wire.Frame) { callback(true) }
+			}
+			payload.frames = append(payload.frames, frame)
 			payload.length += datagram.Length(p.version)
 			hasDatagram = true
 		}
@@ -648,14 +663,14 @@ func (p *packetPacker) MaybePackProbePacket(encLevel protocol.EncryptionLevel) (
 		if err != nil {
 			return nil, err
 		}
-		hdr, payload = p.maybeGetCryptoPacket(p.maxPacketSize-protocol.ByteCount(sealer.Overhead()), 0, protocol.EncryptionInitial)
+		hdr, payload = p.maybeGetCryptoPacket(p.initialPacketSize-protocol.ByteCount(sealer.Overhead()), protocol.EncryptionInitial, false)
 	case protocol.EncryptionHandshake:
 		var err error
 		sealer, err = p.cryptoSetup.GetHandshakeSealer()
 		if err != nil {
 			return nil, err
 		}
-		hdr, payload = p.maybeGetCryptoPacket(p.maxPacketSize-protocol.ByteCount(sealer.Overhead()), 0, protocol.EncryptionHandshake)
+		hdr, payload = p.maybeGetCryptoPacket(p.initialPacketSize-protocol.ByteCount(sealer.Overhead()), protocol.EncryptionHandshake, false)
 	case protocol.Encryption1RTT:
 		oneRTTSealer, err := p.cryptoSetup.Get1RTTSealer()
 		if err != nil {
@@ -676,7 +691,32 @@ func (p *packetPacker) MaybePackProbePacket(encLevel protocol.EncryptionLevel) (
 		padding = p.paddingLen(payload.frames, size)
 	}
 	buffer := getPacketBuffer()
-	cont, err := p.appendPacket(buffer, hdr, payload, padding, encLevel, sealer)
+	cont, err := p.appendPacket(buffer, hdr, payload, padding, encLevel, sealer, false)
+	if err != nil {
+		return nil, err
+	}
+	return &packedPacket{
+		buffer:         buffer,
+		packetContents: cont,
+	}, nil
+}
+
+// PackPathProbePacket packs a 1-RTT packet containing a single PATH_CHALLENGE or PATH_RESPONSE frame.
+// The packet is padded to the maximum packet size, as required for path validation.
+// The frame is not retransmitted if the packet is lost.
+func (p *packetPacker) PackPathProbePacket(f wire.Frame) (*packedPacket, error) {
+	sealer, err := p.cryptoSetup.Get1RTTSealer()
+	if err != nil {
+		return nil, err
+	}
+	hdr := p.getShortHeader(sealer.KeyPhase())
+	payload := &payload{
+		frames: []ackhandler.Frame{{Frame: f, OnLost: func(wire.Frame) {}}},
+		length: f.Length(p.version),
+	}
+	size := p.packetLength(hdr, payload) + protocol.ByteCount(sealer.Overhead())
+	buffer := getPacketBuffer()
+	cont, err := p.appendPacket(buffer, hdr, payload, p.maxPacketSize-size, protocol.Encryption1RTT, sealer, false)
 	if err != nil {
 		return nil, err
 	}
@@ -686,6 +726,32 @@ func (p *packetPacker) MaybePackProbePacket(encLevel protocol.EncryptionLevel) (
 	}, nil
 }
 
+// PackMTUProbePacket packs a 1-RTT packet containing a single PING frame, padded to size.
+// The packet may be larger than the current maximum packet size.
+func (p *packetPacker) PackMTUProbePacket(ping ackhandler.Frame, size protocol.ByteCount) (*packedPacket, error) {
+	payload := &payload{
+		frames: []ackhandler.Frame{ping},
+		length: ping.Length(p.version),
+	}
+	buffer := getPacketBuffer()
+	sealer, err := p.cryptoSetup.Get1RTTSealer()
+	if err != nil {
+		return nil, err
+	}
+	hdr := p.getShortHeader(sealer.KeyPhase())
+	padding := size - p.packetLength(hdr, payload) - protocol.ByteCount(sealer.Overhead())
+	contents, err := p.appendPacket(buffer, hdr, payload, padding, protocol.Encryption1RTT, sealer, true)
+	if err != nil {
+		return nil, err
+	}
+	contents.isMTUProbePacket = true
+	buffer.isMTUProbePacket = true
+	return &packedPacket{
+		buffer:         buffer,
+		packetContents: contents,
+	}, nil
+}
+
 func (p *packetPacker) getSealerAndHeader(encLevel protocol.EncryptionLevel) (sealer, *wire.ExtendedHeader, error) {
 	switch encLevel {
 	case protocol.EncryptionInitial:
@@ -767,7 +833,7 @@ func (p *packetPacker) writeSinglePacket(
 	if encLevel == protocol.EncryptionInitial {
 		paddingLen = p.paddingLen(payload.frames, hdr.GetLength(p.version)+payload.length+protocol.ByteCount(sealer.Overhead()))
 	}
-	contents, err := p.appendPacket(buffer, hdr, payload, paddingLen, encLevel, sealer)
+	contents, err := p.appendPacket(buffer, hdr, payload, paddingLen, encLevel, sealer, false)
 	if err != nil {
 		return nil, err
 	}
@@ -784,6 +850,7 @@ func (p *packetPacker) appendPacket(
 	padding protocol.ByteCount, // add padding such that the packet has this length. 0 for no padding.
 	encLevel protocol.EncryptionLevel,
 	sealer sealer,
+	isMTUProbePacket bool,
 ) (*packetContents, error) {
 	var paddingLen protocol.ByteCount
 	pnLen := protocol.ByteCount(header.PacketNumberLen)
@@ -819,8 +886,10 @@ func (p *packetPacker) appendPacket(
 	if payloadSize := protocol.ByteCount(buf.Len()-payloadOffset) - paddingLen; payloadSize != payload.length {
 		return nil, fmt.Errorf("PacketPacker BUG: payload size inconsistent (expected %d, got %d bytes)", payload.length, payloadSize)
 	}
-	if size := protocol.ByteCount(buf.Len() + sealer.Overhead()); size > p.maxPacketSize {
-		return nil, fmt.Errorf("PacketPacker BUG: packet too large (%d bytes, allowed %d bytes)", size, p.maxPacketSize)
+	if !isMTUProbePacket {
+		if size := protocol.ByteCount(buf.Len() + sealer.Overhead()); size > p.maxPacketSize {
+			return nil, fmt.Errorf("PacketPacker BUG: packet too large (%d bytes, allowed %d bytes)", size, p.maxPacketSize)
+		}
 	}
 
 	raw := buffer.Data
@@ -849,8 +918,18 @@ func (p *packetPacker) SetToken(token []byte) {
 	p.token = token
 }
 
+// SetMaxPacketSize sets the maximum packet size.
+// It is used to reduce the packet size after repeated PTOs,
+// and to increase it when path MTU discovery confirmed a larger size.
+// It must never be larger than the max_udp_payload_size advertised by the peer.
+func (p *packetPacker) SetMaxPacketSize(s protocol.ByteCount) {
+	p.maxPacketSize = s
+	p.initialPacketSize = utils.MinByteCount(p.initialPacketSize, s)
+}
+
 func (p *packetPacker) HandleTransportParameters(params *wire.TransportParameters) {
 	if params.MaxUDPPayloadSize != 0 {
 		p.maxPacketSize = utils.MinByteCount(p.maxPacketSize, params.MaxUDPPayloadSize)
+		p.initialPacketSize = utils.MinByteCount(p.initialPacketSize, params.MaxUDPPayloadSize)
 	}
 }
@@ -24,7 +24,10 @@ import (
 )
 
 var _ = Describe("Packet packer", func() {
-	const maxPacketSize protocol.ByteCount = 1357
+	const (
+		maxPacketSize     protocol.ByteCount = 1357
+		initialPacketSize protocol.ByteCount = 1234
+	)
 	const version = protocol.VersionTLS
 
 	var (
@@ -101,6 +104,7 @@ var _ = Describe("Packet packer", func() {
 			pnManager,
 			retransmissionQueue,
 			&net.TCPAddr{},
+			initialPacketSize,
 			sealingManager,
 			framer,
 			ackFramer,
@@ -110,6 +114,7 @@ var _ = Describe("Packet packer", func() {
 		)
 		packer.version = version
 		packer.maxPacketSize = maxPacketSize
+		packer.initialPacketSize = initialPacketSize
 	})
 
 	Context("determining the maximum packet size", func() {
@@ -129,6 +134,45 @@ var _ = Describe("Packet packer", func() {
 		})
 	})
 
+	Context("determining the initial packet size", func() {
+		newPacker := func(initialPacketSize protocol.ByteCount) *packetPacker {
+			return newPacketPacker(
+				protocol.ConnectionID{1, 2, 3, 4, 5, 6, 7, 8},
+				func() protocol.ConnectionID { return protocol.ConnectionID{1, 2, 3, 4} },
+				nil,
+				nil,
+				nil,
+				nil,
+				&net.UDPAddr{IP: net.IPv4(11, 12, 13, 14), Port: 1337},
+				initialPacketSize,
+				nil,
+				nil,
+				nil,
+				nil,
+				protocol.PerspectiveClient,
+				version,
+			)
+		}
+
+		It("uses the configured initial packet size", func() {
+			Expect(newPacker(1234).initialPacketSize).To(BeEquivalentTo(1234))
+		})
+
+		It("doesn't use an initial packet size larger than the maximum packet size", func() {
+			Expect(newPacker(protocol.MaxPacketSizeIPv4 + 1).initialPacketSize).To(BeEquivalentTo(protocol.MaxPacketSizeIPv4))
+		})
+
+		It("reduces the initial packet size when the maximum packet size is reduced", func() {
+			p := newPacker(1234)
+			p.SetMaxPacketSize(1220)
+			Expect(p.initialPacketSize).To(BeEquivalentTo(1220))
+			Expect(p.maxPacketSize).To(BeEquivalentTo(1220))
+			p.HandleTransportParameters(&wire.TransportParameters{MaxUDPPayloadSize: 1210})
+			Expect(p.initialPacketSize).To(BeEquivalentTo(1210))
+			Expect(p.maxPacketSize).To(BeEquivalentTo(1210))
+		})
+	})
+
 	Context("generating a packet header", func() {
 		It("uses the Long Header format", func() {
 			pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen3)
@@ -191,7 +235,7 @@ var _ = Describe("Packet packer", func() {
 			expectAppendControlFrames()
 			f := &wire.StreamFrame{Data: []byte{0xde, 0xca, 0xfb, 0xad}}
 			expectAppendStreamFrames(ackhandler.Frame{Frame: f})
-			p, err := packer.PackCoalescedPacket()
+			p, err := packer.PackCoalescedPacket(false)
 			Expect(err).ToNot(HaveOccurred())
 			Expect(p).ToNot(BeNil())
 			Expect(p.packets).To(HaveLen(1))
@@ -220,57 +264,134 @@ var _ = Describe("Packet packer", func() {
 
 		Context("packing ACK packets", func() {
 			It("doesn't pack a packet if there's no ACK to send", func() {
-				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true)
-				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, true)
 				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, true)
-				p, err := packer.MaybePackAckPacket(false)
+				p, err := packer.MaybePackAckPacket()
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p).To(BeNil())
 			})
 
-			It("packs Initial ACK-only packets, and pads them (for the client)", func() {
-				packer.perspective = protocol.PerspectiveClient
-				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
-				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42))
-				sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
-				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
-				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true).Return(ack)
-				p, err := packer.MaybePackAckPacket(false)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(p).ToNot(BeNil())
-				Expect(p.EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
-				Expect(p.ack).To(Equal(ack))
-				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.maxPacketSize))
-				parsePacket(p.buffer.Data)
-			})
-
-			It("packs Initial ACK-only packets, and doesn't pads them (for the server)", func() {
-				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
-				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42))
-				sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
-				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
-				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true).Return(ack)
-				p, err := packer.MaybePackAckPacket(false)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(p).ToNot(BeNil())
-				Expect(p.EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
-				Expect(p.ack).To(Equal(ack))
-				parsePacket(p.buffer.Data)
-			})
-
 			It("packs 1-RTT ACK-only packets", func() {
 				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
 				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
 				ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
 				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, true).Return(ack)
-				p, err := packer.MaybePackAckPacket(true)
+				p, err := packer.MaybePackAckPacket()
 				Expect(err).NotTo(HaveOccurred())
 				Expect(p).ToNot(BeNil())
 				Expect(p.EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
 				Expect(p.ack).To(Equal(ack))
 				parsePacket(p.buffer.Data)
 			})
+
+			Context("during the handshake", func() {
+				It("doesn't pack a packet if there's no ACK to send", func() {
+					sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true)
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, true)
+					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, true)
+					p, err := packer.PackCoalescedPacket(true)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p).To(BeNil())
+				})
+
+				It("packs Initial ACK-only packets, and pads them (for the client)", func() {
+					packer.perspective = protocol.PerspectiveClient
+					pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42))
+					sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().GetHandshakeSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					sealingManager.EXPECT().Get0RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true).Return(ack)
+					p, err := packer.PackCoalescedPacket(true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(p).ToNot(BeNil())
+					Expect(p.packets).To(HaveLen(1))
+					Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
+					Expect(p.packets[0].ack).To(Equal(ack))
+					Expect(p.packets[0].frames).To(BeEmpty())
+					Expect(p.buffer.Len()).To(BeEquivalentTo(packer.initialPacketSize))
+					parsePacket(p.buffer.Data)
+				})
+
+				It("packs Initial ACK-only packets, and doesn't pads them (for the server)", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42))
+					sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().GetHandshakeSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true).Return(ack)
+					p, err := packer.PackCoalescedPacket(true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(p).ToNot(BeNil())
+					Expect(p.packets).To(HaveLen(1))
+					Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
+					Expect(p.packets[0].ack).To(Equal(ack))
+					Expect(p.buffer.Len()).To(BeNumerically("<", 100))
+					parsePacket(p.buffer.Data)
+				})
+
+				It("coalesces ACKs for all packet number spaces into a single datagram", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x24), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x24))
+					pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42))
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x1337), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x1337))
+					sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+					initialAck := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
+					handshakeAck := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 20}}}
+					oneRTTAck := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 3, Largest: 30}}}
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true).Return(initialAck)
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, true).Return(handshakeAck)
+					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, true).Return(oneRTTAck)
+					// don't EXPECT any calls to the crypto streams or the framer
+					p, err := packer.PackCoalescedPacket(true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(p).ToNot(BeNil())
+					Expect(p.packets).To(HaveLen(3))
+					Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
+					Expect(p.packets[0].ack).To(Equal(initialAck))
+					Expect(p.packets[0].frames).To(BeEmpty())
+					Expect(p.packets[1].EncryptionLevel()).To(Equal(protocol.EncryptionHandshake))
+					Expect(p.packets[1].ack).To(Equal(handshakeAck))
+					Expect(p.packets[1].frames).To(BeEmpty())
+					Expect(p.packets[2].EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
+					Expect(p.packets[2].ack).To(Equal(oneRTTAck))
+					Expect(p.packets[2].frames).To(BeEmpty())
+					hdrs := parsePacket(p.buffer.Data)
+					Expect(hdrs).To(HaveLen(3))
+					Expect(hdrs[0].Type).To(Equal(protocol.PacketTypeInitial))
+					Expect(hdrs[1].Type).To(Equal(protocol.PacketTypeHandshake))
+					Expect(hdrs[2].IsLongHeader).To(BeFalse())
+				})
+
+				It("doesn't add ACKs to 0-RTT packets", func() {
+					packer.perspective = protocol.PerspectiveClient
+					pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x24), protocol.PacketNumberLen2)
+					pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x24))
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption0RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+					sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().GetHandshakeSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					sealingManager.EXPECT().Get0RTTSealer().Return(getSealer(), nil)
+					sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+					ack := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
+					ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true).Return(ack)
+					p, err := packer.PackCoalescedPacket(true)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(p).ToNot(BeNil())
+					Expect(p.packets).To(HaveLen(1))
+					Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
+				})
+			})
 		})
 
 		Context("packing 0-RTT packets", func() {
@@ -299,7 +420,7 @@ var _ = Describe("Packet packer", func() {
 				framer.EXPECT().AppendStreamFrames(gomock.Any(), gomock.Any()).DoAndReturn(func(frames []ackhandler.Frame, _ protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
 					return frames, 0
 				})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(p).ToNot(BeNil())
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
@@ -435,7 +556,7 @@ var _ = Describe("Packet packer", func() {
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(2))
 				Expect(p.buffer.Len()).To(BeNumerically(">=", protocol.MinInitialPacketSize))
-				Expect(p.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 				Expect(p.packets[0].header.Type).To(Equal(protocol.PacketTypeInitial))
 				Expect(p.packets[0].header.PacketNumber).To(Equal(protocol.PacketNumber(1)))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -552,7 +673,7 @@ var _ = Describe("Packet packer", func() {
 				go func() {
 					defer GinkgoRecover()
 					defer close(done)
-					datagramQueue.AddAndWait(f)
+					datagramQueue.AddAndWait(f, nil)
 				}()
 				// make sure the DATAGRAM has actually been queued
 				time.Sleep(scaleDuration(20 * time.Millisecond))
@@ -567,6 +688,36 @@ var _ = Describe("Packet packer", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("calls the DATAGRAM callback when the packet is acknowledged or lost", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2).Times(2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42)).Times(2)
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil).Times(2)
+				framer.EXPECT().HasData().Times(2)
+				var results []bool
+				for i := 0; i < 2; i++ {
+					done := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						defer close(done)
+						Expect(datagramQueue.AddAndWait(&wire.DatagramFrame{Data: []byte("foobar")}, func(acked bool) {
+							results = append(results, acked)
+						})).To(Succeed())
+					}()
+					// make sure the DATAGRAM has actually been queued
+					time.Sleep(scaleDuration(20 * time.Millisecond))
+					p, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(p.frames).To(HaveLen(1))
+					if i == 0 {
+						p.frames[0].OnAcked(p.frames[0].Frame)
+					} else {
+						p.frames[0].OnLost(p.frames[0].Frame)
+					}
+					Eventually(done).Should(BeClosed())
+				}
+				Expect(results).To(Equal([]bool{true, false}))
+			})
+
 			It("accounts for the space consumed by control frames", func() {
 				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
@@ -597,7 +748,7 @@ var _ = Describe("Packet packer", func() {
 				packer.retransmissionQueue.AddHandshake(&wire.PingFrame{})
 				handshakeStream.EXPECT().HasData()
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, false)
-				packet, err := packer.PackCoalescedPacket()
+				packet, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packet).ToNot(BeNil())
 				Expect(packet.packets).To(HaveLen(1))
@@ -853,6 +1004,39 @@ var _ = Describe("Packet packer", func() {
 					_, err = packer.PackPacket()
 					Expect(err).ToNot(HaveOccurred())
 				})
+
+				It("sets the max packet size", func() {
+					pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2).Times(3)
+					sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil).Times(3)
+					framer.EXPECT().HasData().Return(true).Times(3)
+					ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, false).Times(3)
+					var initialMaxPacketSize protocol.ByteCount
+					framer.EXPECT().AppendControlFrames(gomock.Any(), gomock.Any()).Do(func(_ []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
+						initialMaxPacketSize = maxLen
+						return nil, 0
+					})
+					expectAppendStreamFrames()
+					_, err := packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					// now reduce the maxPacketSize
+					packer.SetMaxPacketSize(maxPacketSize - 50)
+					framer.EXPECT().AppendControlFrames(gomock.Any(), gomock.Any()).Do(func(_ []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
+						Expect(maxLen).To(Equal(initialMaxPacketSize - 50))
+						return nil, 0
+					})
+					expectAppendStreamFrames()
+					_, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+					// now increase it again, e.g. after path MTU discovery confirmed a larger size
+					packer.SetMaxPacketSize(maxPacketSize + 100)
+					framer.EXPECT().AppendControlFrames(gomock.Any(), gomock.Any()).Do(func(_ []ackhandler.Frame, maxLen protocol.ByteCount) ([]ackhandler.Frame, protocol.ByteCount) {
+						Expect(maxLen).To(Equal(initialMaxPacketSize + 100))
+						return nil, 0
+					})
+					expectAppendStreamFrames()
+					_, err = packer.PackPacket()
+					Expect(err).ToNot(HaveOccurred())
+				})
 			})
 		})
 
@@ -870,7 +1054,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().GetInitialSealer().Return(nil, handshake.ErrKeysDropped)
 				sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p).ToNot(BeNil())
 				parsePacket(p.buffer.Data)
@@ -889,10 +1073,10 @@ var _ = Describe("Packet packer", func() {
 				initialStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
 					return &wire.CryptoFrame{Offset: 0x42, Data: []byte("initial")}
 				})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.buffer.Len()).To(BeNumerically(">=", protocol.MinInitialPacketSize))
-				Expect(p.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -918,12 +1102,12 @@ var _ = Describe("Packet packer", func() {
 					Expect(f.Length(packer.version)).To(Equal(size))
 					return f
 				})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].frames).To(HaveLen(1))
 				Expect(p.packets[0].header.IsLongHeader).To(BeTrue())
-				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.initialPacketSize))
 				parsePacket(p.buffer.Data)
 			})
 
@@ -936,7 +1120,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, false)
-				// don't EXPECT any calls for a Handshake ACK frame
+				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, false)
 				initialStream.EXPECT().HasData().Return(true).Times(2)
 				initialStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
 					return &wire.CryptoFrame{Offset: 0x42, Data: []byte("initial")}
@@ -945,9 +1129,9 @@ var _ = Describe("Packet packer", func() {
 				handshakeStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
 					return &wire.CryptoFrame{Offset: 0x1337, Data: []byte("handshake")}
 				})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
-				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.initialPacketSize))
 				Expect(p.packets).To(HaveLen(2))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -970,16 +1154,16 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, false)
-				// don't EXPECT any calls for a Handshake ACK frame
+				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, false)
 				initialStream.EXPECT().HasData().Return(true).Times(2)
 				initialStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
 					return &wire.CryptoFrame{Offset: 0x42, Data: []byte("initial")}
 				})
 				handshakeStream.EXPECT().HasData()
 				packer.retransmissionQueue.AddHandshake(&wire.PingFrame{})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
-				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.initialPacketSize))
 				Expect(p.packets).To(HaveLen(2))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -1002,13 +1186,14 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, gomock.Any())
+				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, gomock.Any())
 				initialStream.EXPECT().HasData()
 				handshakeStream.EXPECT().HasData()
 				packer.retransmissionQueue.AddInitial(&wire.PingFrame{})
 				packer.retransmissionQueue.AddHandshake(&wire.PingFrame{})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
-				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.initialPacketSize))
 				Expect(p.packets).To(HaveLen(2))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -1031,6 +1216,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().GetHandshakeSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, false)
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, false)
 				initialStream.EXPECT().HasData().Return(true).Times(2)
 				initialStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
 					return &wire.CryptoFrame{Offset: 0x42, Data: []byte("initial")}
@@ -1039,9 +1225,9 @@ var _ = Describe("Packet packer", func() {
 				expectAppendStreamFrames()
 				framer.EXPECT().HasData().Return(true)
 				packer.retransmissionQueue.AddAppData(&wire.PingFrame{})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
-				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(packer.initialPacketSize))
 				Expect(p.packets).To(HaveLen(2))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -1074,10 +1260,10 @@ var _ = Describe("Packet packer", func() {
 				})
 				expectAppendControlFrames()
 				expectAppendStreamFrames(ackhandler.Frame{Frame: &wire.StreamFrame{Data: []byte("foobar")}})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.buffer.Len()).To(BeNumerically(">=", protocol.MinInitialPacketSize))
-				Expect(p.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 				Expect(p.packets).To(HaveLen(2))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 				Expect(p.packets[0].frames).To(HaveLen(1))
@@ -1101,14 +1287,14 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
 				framer.EXPECT().HasData().Return(true)
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, false)
-				// don't EXPECT any calls for a 1-RTT ACK frame
+				ackFramer.EXPECT().GetAckFrame(protocol.Encryption1RTT, false)
 				handshakeStream.EXPECT().HasData().Return(true).Times(2)
 				handshakeStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
 					return &wire.CryptoFrame{Offset: 0x1337, Data: []byte("handshake")}
 				})
 				expectAppendControlFrames()
 				expectAppendStreamFrames(ackhandler.Frame{Frame: &wire.StreamFrame{Data: []byte("foobar")}})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.buffer.Len()).To(BeNumerically("<", 100))
 				Expect(p.packets).To(HaveLen(2))
@@ -1127,6 +1313,38 @@ var _ = Describe("Packet packer", func() {
 				Expect(rest).To(BeEmpty())
 			})
 
+			It("adds ACK frames to all packets of a coalesced packet", func() {
+				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x24), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x24))
+				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42))
+				sealingManager.EXPECT().GetInitialSealer().Return(getSealer(), nil)
+				sealingManager.EXPECT().GetHandshakeSealer().Return(getSealer(), nil)
+				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
+				initialAck := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}}
+				handshakeAck := &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 20}}}
+				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, false).Return(initialAck)
+				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, true).Return(handshakeAck)
+				initialStream.EXPECT().HasData().Return(true).Times(2)
+				initialStream.EXPECT().PopCryptoFrame(gomock.Any()).DoAndReturn(func(size protocol.ByteCount) *wire.CryptoFrame {
+					return &wire.CryptoFrame{Offset: 0x42, Data: []byte("initial")}
+				})
+				handshakeStream.EXPECT().HasData()
+				p, err := packer.PackCoalescedPacket(false)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(p.packets).To(HaveLen(2))
+				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
+				Expect(p.packets[0].ack).To(Equal(initialAck))
+				Expect(p.packets[0].frames).To(HaveLen(1))
+				Expect(p.packets[1].EncryptionLevel()).To(Equal(protocol.EncryptionHandshake))
+				Expect(p.packets[1].ack).To(Equal(handshakeAck))
+				Expect(p.packets[1].frames).To(BeEmpty())
+				hdrs := parsePacket(p.buffer.Data)
+				Expect(hdrs).To(HaveLen(2))
+				Expect(hdrs[0].Type).To(Equal(protocol.PacketTypeInitial))
+				Expect(hdrs[1].Type).To(Equal(protocol.PacketTypeHandshake))
+			})
+
 			It("doesn't add a coalesced packet if the remaining size is smaller than MaxCoalescedPacketSize", func() {
 				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x24), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x24))
@@ -1142,11 +1360,11 @@ var _ = Describe("Packet packer", func() {
 					Expect(f.Length(packer.version)).To(Equal(s))
 					return f
 				})
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionHandshake))
-				Expect(len(p.buffer.Data)).To(BeEquivalentTo(maxPacketSize - protocol.MinCoalescedPacketSize))
+				Expect(len(p.buffer.Data)).To(BeEquivalentTo(initialPacketSize - protocol.MinCoalescedPacketSize))
 				parsePacket(p.buffer.Data)
 			})
 
@@ -1160,7 +1378,7 @@ var _ = Describe("Packet packer", func() {
 				packer.retransmissionQueue.AddHandshake(&wire.PingFrame{})
 				handshakeStream.EXPECT().HasData()
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionHandshake, false)
-				packet, err := packer.PackCoalescedPacket()
+				packet, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(packet).ToNot(BeNil())
 				Expect(packet.packets).To(HaveLen(1))
@@ -1199,7 +1417,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, false)
 				initialStream.EXPECT().HasData()
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
@@ -1216,7 +1434,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionInitial).Return(protocol.PacketNumber(0x42))
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].ack).To(Equal(ack))
@@ -1228,7 +1446,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				initialStream.EXPECT().HasData()
 				ackFramer.EXPECT().GetAckFrame(protocol.EncryptionInitial, true)
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p).To(BeNil())
 			})
@@ -1244,7 +1462,7 @@ var _ = Describe("Packet packer", func() {
 				sealingManager.EXPECT().Get1RTTSealer().Return(nil, handshake.ErrKeysNotYetAvailable)
 				pnManager.EXPECT().PeekPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
 				pnManager.EXPECT().PopPacketNumber(protocol.EncryptionHandshake).Return(protocol.PacketNumber(0x42))
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].ack).To(Equal(ack))
@@ -1267,10 +1485,10 @@ var _ = Describe("Packet packer", func() {
 					initialStream.EXPECT().HasData().Return(true).Times(2)
 					initialStream.EXPECT().PopCryptoFrame(gomock.Any()).Return(f)
 					packer.perspective = protocol.PerspectiveClient
-					p, err := packer.PackCoalescedPacket()
+					p, err := packer.PackCoalescedPacket(false)
 					Expect(err).ToNot(HaveOccurred())
 					Expect(p.buffer.Len()).To(BeNumerically(">=", protocol.MinInitialPacketSize))
-					Expect(p.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+					Expect(p.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 					Expect(p.packets).To(HaveLen(1))
 					Expect(p.packets[0].header.Token).To(Equal(token))
 					Expect(p.packets[0].frames).To(HaveLen(1))
@@ -1293,12 +1511,12 @@ var _ = Describe("Packet packer", func() {
 				initialStream.EXPECT().PopCryptoFrame(gomock.Any()).Return(f)
 				packer.version = protocol.VersionTLS
 				packer.perspective = protocol.PerspectiveClient
-				p, err := packer.PackCoalescedPacket()
+				p, err := packer.PackCoalescedPacket(false)
 				Expect(err).ToNot(HaveOccurred())
 				Expect(p.packets).To(HaveLen(1))
 				Expect(p.packets[0].ack).To(Equal(ack))
 				Expect(p.packets[0].frames).To(HaveLen(1))
-				Expect(p.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+				Expect(p.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 			})
 		})
 
@@ -1321,7 +1539,7 @@ var _ = Describe("Packet packer", func() {
 					Expect(packet).ToNot(BeNil())
 					Expect(packet.EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 					Expect(packet.buffer.Len()).To(BeNumerically(">=", protocol.MinInitialPacketSize))
-					Expect(packet.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+					Expect(packet.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 					Expect(packet.frames).To(HaveLen(1))
 					Expect(packet.frames[0].Frame).To(Equal(f))
 					parsePacket(packet.buffer.Data)
@@ -1341,7 +1559,7 @@ var _ = Describe("Packet packer", func() {
 					Expect(packet).ToNot(BeNil())
 					Expect(packet.EncryptionLevel()).To(Equal(protocol.EncryptionInitial))
 					Expect(packet.buffer.Len()).To(BeNumerically(">=", protocol.MinInitialPacketSize))
-					Expect(packet.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+					Expect(packet.buffer.Len()).To(BeEquivalentTo(initialPacketSize))
 					Expect(packet.frames).To(HaveLen(1))
 					Expect(packet.frames[0].Frame).To(BeAssignableToTypeOf(&wire.PingFrame{}))
 					parsePacket(packet.buffer.Data)
@@ -1381,7 +1599,7 @@ var _ = Describe("Packet packer", func() {
 				Expect(packet.EncryptionLevel()).To(Equal(protocol.EncryptionHandshake))
 				Expect(packet.frames).To(HaveLen(1))
 				Expect(packet.frames[0].Frame).To(BeAssignableToTypeOf(&wire.CryptoFrame{}))
-				Expect(packet.length).To(Equal(maxPacketSize))
+				Expect(packet.length).To(Equal(initialPacketSize))
 				parsePacket(packet.buffer.Data)
 			})
 
@@ -1439,6 +1657,59 @@ var _ = Describe("Packet packer", func() {
 				Expect(packet).To(BeNil())
 			})
 		})
+
+		Context("packing path probe packets", func() {
+			It("packs a full size packet containing a PATH_CHALLENGE", func() {
+				f := &wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+
+				packet, err := packer.PackPathProbePacket(f)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(packet.EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
+				Expect(packet.ack).To(BeNil())
+				Expect(packet.frames).To(HaveLen(1))
+				Expect(packet.frames[0].Frame).To(Equal(f))
+				Expect(packet.length).To(Equal(maxPacketSize))
+				Expect(packet.buffer.Len()).To(BeEquivalentTo(maxPacketSize))
+			})
+
+			It("doesn't retransmit the frame when the packet is lost", func() {
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+
+				packet, err := packer.PackPathProbePacket(&wire.PathResponseFrame{})
+				Expect(err).ToNot(HaveOccurred())
+				p := packet.ToAckHandlerPacket(time.Now(), retransmissionQueue)
+				Expect(p.Frames).To(HaveLen(1))
+				p.Frames[0].OnLost(p.Frames[0].Frame)
+				Expect(retransmissionQueue.HasAppData()).To(BeFalse())
+			})
+		})
+
+		Context("packing MTU probe packets", func() {
+			It("packs a PING frame, padded to the probe size", func() {
+				sealingManager.EXPECT().Get1RTTSealer().Return(getSealer(), nil)
+				pnManager.EXPECT().PeekPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42), protocol.PacketNumberLen2)
+				pnManager.EXPECT().PopPacketNumber(protocol.Encryption1RTT).Return(protocol.PacketNumber(0x42))
+				ping := ackhandler.Frame{Frame: &wire.PingFrame{}, OnLost: func(wire.Frame) {}}
+				probeSize := maxPacketSize + 50
+				packet, err := packer.PackMTUProbePacket(ping, probeSize)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(packet.EncryptionLevel()).To(Equal(protocol.Encryption1RTT))
+				Expect(packet.ack).To(BeNil())
+				Expect(packet.frames).To(HaveLen(1))
+				Expect(packet.frames[0].Frame).To(Equal(&wire.PingFrame{}))
+				Expect(packet.length).To(BeEquivalentTo(probeSize))
+				Expect(packet.buffer.Len()).To(BeEquivalentTo(probeSize))
+				Expect(packet.isMTUProbePacket).To(BeTrue())
+				Expect(packet.buffer.isMTUProbePacket).To(BeTrue())
+				// the maximum packet size for other packets is not increased
+				Expect(packer.maxPacketSize).To(Equal(maxPacketSize))
+			})
+		})
 	})
 })
 
@@ -0,0 +1,92 @@
+package quic
+
+import (
+	"crypto/rand"
+	"net"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+	"github.com/lucas-clemente/quic-go/internal/wire"
+)
+
+// A pathMigration is the migration of a client session to a new local address.
+// The new path is validated using PATH_CHALLENGE and PATH_RESPONSE frames.
+// Until the validation succeeds, packets are sent on the old path.
+type pathMigration struct {
+	conn net.PacketConn
+	// supportsDF says if the don't-fragment bit is set on the new packet conn.
+	supportsDF bool
+	challenge  [8]byte
+
+	// The PATH_CHALLENGE is retransmitted at nextProbe,
+	// the migration is abandoned if no PATH_RESPONSE was received before the deadline.
+	nextProbe time.Time
+	deadline  time.Time
+
+	result chan error
+}
+
+func newPathMigration(conn net.PacketConn, supportsDF bool) (*pathMigration, error) {
+	m := &pathMigration{
+		conn:       conn,
+		supportsDF: supportsDF,
+		result:     make(chan error, 1),
+	}
+	if _, err := rand.Read(m.challenge[:]); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// pathAmplificationFactor limits the amount of data sent on an unvalidated path,
+// relative to the amount of data received on that path.
+const pathAmplificationFactor = 3
+
+// An unvalidatedPath is a peer address that the server received packets from, but that wasn't validated.
+// The source address of these packets might be spoofed,
+// so the server is only allowed to send a limited amount of data to this address.
+// Once the peer sends non-probing packets from this address, the server validates it using a PATH_CHALLENGE.
+type unvalidatedPath struct {
+	addr          net.Addr
+	bytesReceived protocol.ByteCount
+	bytesSent     protocol.ByteCount
+
+	challenge     [8]byte
+	nextChallenge time.Time // zero until the first PATH_CHALLENGE was sent
+	validated     bool
+	// migrate is set if the packet with the highest packet number was a non-probing packet received from this address.
+	// The server switches to this address as soon as it is validated.
+	migrate bool
+}
+
+func newUnvalidatedPath(addr net.Addr) *unvalidatedPath {
+	p := &unvalidatedPath{addr: addr}
+	rand.Read(p.challenge[:])
+	return p
+}
+
+func (p *unvalidatedPath) isAmplificationLimited() bool {
+	return p.bytesSent >= pathAmplificationFactor*p.bytesReceived
+}
+
+// isProbingFrame says if a frame is a probing frame.
+// Packets only containing probing frames don't cause the peer to migrate to a new path.
+func isProbingFrame(f wire.Frame) bool {
+	switch f.(type) {
+	case *wire.PathChallengeFrame, *wire.PathResponseFrame, *wire.NewConnectionIDFrame:
+		return true
+	default:
+		return false
+	}
+}
+
+// equalAddr says if two addresses are the same.
+// If onlyIP is set, the port numbers are not compared.
+func equalAddr(a, b net.Addr, onlyIP bool) bool {
+	udpA, okA := a.(*net.UDPAddr)
+	udpB, okB := b.(*net.UDPAddr)
+	if !okA || !okB {
+		return a.String() == b.String()
+	}
+	return udpA.IP.Equal(udpB.IP) && (onlyIP || udpA.Port == udpB.Port)
+}
@@ -267,6 +267,18 @@ func (e eventUpdatedPTO) MarshalJSONObject(enc *gojay.Encoder) {
 	enc.Uint32Key("pto_count", e.Value)
 }
 
+type eventAmplificationLimitUpdated struct {
+	Limited bool
+}
+
+func (e eventAmplificationLimitUpdated) Category() category { return categoryRecovery }
+func (e eventAmplificationLimitUpdated) Name() string       { return "amplification_limit_updated" }
+func (e eventAmplificationLimitUpdated) IsNil() bool        { return false }
+
+func (e eventAmplificationLimitUpdated) MarshalJSONObject(enc *gojay.Encoder) {
+	enc.BoolKey("amplification_limited", e.Limited)
+}
+
 type eventPacketLost struct {
 	PacketType   packetType
 	PacketNumber protocol.PacketNumber
@@ -324,6 +324,12 @@ func (t *connectionTracer) UpdatedPTOCount(value uint32) {
 	t.mutex.Unlock()
 }
 
+func (t *connectionTracer) UpdatedAmplificationLimited(limited bool) {
+	t.mutex.Lock()
+	t.recordEvent(time.Now(), &eventAmplificationLimitUpdated{Limited: limited})
+	t.mutex.Unlock()
+}
+
 func (t *connectionTracer) UpdatedKeyFromTLS(encLevel protocol.EncryptionLevel, pers protocol.Perspective) {
 	t.mutex.Lock()
 	t.recordEvent(time.Now(), &eventKeyUpdated{
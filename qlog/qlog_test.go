@@ -600,6 +600,14 @@ var _ = Describe("Tracing", func() {
 				Expect(entry.Event).To(HaveKeyWithValue("pto_count", float64(42)))
 			})
 
+			It("records changes of the amplification limit", func() {
+				tracer.UpdatedAmplificationLimited(true)
+				entry := exportAndParseSingle()
+				Expect(entry.Time).To(BeTemporally("~", time.Now(), scaleDuration(10*time.Millisecond)))
+				Expect(entry.Name).To(Equal("recovery:amplification_limit_updated"))
+				Expect(entry.Event).To(HaveKeyWithValue("amplification_limited", true))
+			})
+
 			It("records TLS key updates", func() {
 				tracer.UpdatedKeyFromTLS(protocol.EncryptionHandshake, protocol.PerspectiveClient)
 				entry := exportAndParseSingle()
@@ -310,6 +310,8 @@ func (r timeoutReason) String() string {
 		return "handshake_timeout"
 	case logging.TimeoutReasonIdle:
 		return "idle_timeout"
+	case logging.TimeoutReasonSendStall:
+		return "send_stall_timeout"
 	default:
 		return "unknown close reason"
 	}
@@ -61,6 +61,7 @@ var _ = Describe("Types", func() {
 	It("has a string representation for the close reason", func() {
 		Expect(timeoutReason(logging.TimeoutReasonHandshake).String()).To(Equal("handshake_timeout"))
 		Expect(timeoutReason(logging.TimeoutReasonIdle).String()).To(Equal("idle_timeout"))
+		Expect(timeoutReason(logging.TimeoutReasonSendStall).String()).To(Equal("send_stall_timeout"))
 	})
 
 	It("has a string representation for the key type", func() {
@@ -0,0 +1,34 @@
+package quic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lucas-clemente/quic-go/internal/utils"
+	"github.com/lucas-clemente/quic-go/logging"
+	"github.com/lucas-clemente/quic-go/qlog"
+)
+
+// newQlogDirTracer creates a qlog tracer that writes one file per connection to dir.
+// Files are named by the time the connection was started, the original destination connection ID
+// and the perspective, such that client and server can share a directory.
+func newQlogDirTracer(dir string, logger utils.Logger) logging.Tracer {
+	return qlog.NewTracer(func(p logging.Perspective, connID []byte) io.WriteCloser {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			logger.Errorf("Failed to create qlog dir %s: %s", dir, err)
+			return nil
+		}
+		filename := fmt.Sprintf("%s_%x_%s.qlog", time.Now().Format("20060102T150405.000"), connID, strings.ToLower(p.String()))
+		f, err := os.Create(filepath.Join(dir, filename))
+		if err != nil {
+			logger.Errorf("Failed to create qlog file: %s", err)
+			return nil
+		}
+		return utils.NewBufferedWriteCloser(bufio.NewWriter(f), f)
+	})
+}
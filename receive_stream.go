@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"sync"
@@ -19,6 +20,7 @@ type receiveStreamI interface {
 	handleResetStreamFrame(*wire.ResetStreamFrame) error
 	closeForShutdown(error)
 	getWindowUpdate() protocol.ByteCount
+	receiveWindowSize() protocol.ByteCount
 }
 
 type receiveStream struct {
@@ -44,10 +46,14 @@ type receiveStream struct {
 	finRead           bool // set once we read a frame with a Fin
 	canceledRead      bool // set when CancelRead() is called
 	resetRemotely     bool // set when HandleResetStreamFrame() is called
+	draining          bool // set when Drain() is called
 
 	readChan chan struct{}
 	deadline time.Time
 
+	// finalOffsetChan is closed when the final offset is received, or when the stream is closed for shutdown
+	finalOffsetChan chan struct{}
+
 	flowController flowcontrol.StreamFlowController
 	version        protocol.VersionNumber
 }
@@ -55,6 +61,7 @@ type receiveStream struct {
 var (
 	_ ReceiveStream  = &receiveStream{}
 	_ receiveStreamI = &receiveStream{}
+	_ io.WriterTo    = &receiveStream{}
 )
 
 func newReceiveStream(
@@ -64,13 +71,14 @@ func newReceiveStream(
 	version protocol.VersionNumber,
 ) *receiveStream {
 	return &receiveStream{
-		streamID:       streamID,
-		sender:         sender,
-		flowController: flowController,
-		frameQueue:     newFrameSorter(),
-		readChan:       make(chan struct{}, 1),
-		finalOffset:    protocol.MaxByteCount,
-		version:        version,
+		streamID:        streamID,
+		sender:          sender,
+		flowController:  flowController,
+		frameQueue:      newFrameSorter(),
+		readChan:        make(chan struct{}, 1),
+		finalOffsetChan: make(chan struct{}),
+		finalOffset:     protocol.MaxByteCount,
+		version:         version,
 	}
 }
 
@@ -185,6 +193,89 @@ func (s *receiveStream) readImpl(p []byte) (bool /*stream completed */, int, err
 	return false, bytesRead, nil
 }
 
+// WriteTo implements io.WriterTo.
+// It writes the data of the received STREAM frames directly to w, avoiding the intermediate copy done by io.Copy.
+// It returns when the whole stream has been read, or when an error occurs.
+func (s *receiveStream) WriteTo(w io.Writer) (int64, error) {
+	s.mutex.Lock()
+	completed, n, err := s.writeToImpl(w)
+	s.mutex.Unlock()
+
+	if completed {
+		s.sender.onStreamCompleted(s.streamID)
+	}
+	return n, err
+}
+
+func (s *receiveStream) writeToImpl(w io.Writer) (bool /* stream completed */, int64, error) {
+	deadlineTimer := utils.NewTimer()
+	defer deadlineTimer.Stop()
+
+	var bytesWritten int64
+	for {
+		if s.finRead {
+			return false, bytesWritten, nil
+		}
+		if s.closedForShutdown {
+			return false, bytesWritten, s.closeForShutdownErr
+		}
+		if s.canceledRead {
+			return false, bytesWritten, s.cancelReadErr
+		}
+		if s.resetRemotely {
+			return false, bytesWritten, s.resetRemotelyErr
+		}
+
+		deadline := s.deadline
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				return false, bytesWritten, errDeadline
+			}
+			deadlineTimer.Reset(deadline)
+		}
+
+		if s.currentFrame == nil || s.readPosInFrame >= len(s.currentFrame) {
+			s.dequeueNextFrame()
+		}
+		if s.currentFrame == nil && !s.currentFrameIsLast {
+			s.mutex.Unlock()
+			if deadline.IsZero() {
+				<-s.readChan
+			} else {
+				select {
+				case <-s.readChan:
+				case <-deadlineTimer.Chan():
+					deadlineTimer.SetRead()
+				}
+			}
+			s.mutex.Lock()
+			continue
+		}
+
+		var m int
+		var err error
+		if data := s.currentFrame[s.readPosInFrame:]; len(data) > 0 {
+			s.mutex.Unlock()
+			m, err = w.Write(data)
+			s.mutex.Lock()
+		}
+		s.readPosInFrame += m
+		bytesWritten += int64(m)
+		// when a RESET_STREAM was received, the flow controller was already informed about the final byteOffset for this stream
+		if !s.resetRemotely {
+			s.flowController.AddBytesRead(protocol.ByteCount(m))
+		}
+
+		if s.readPosInFrame >= len(s.currentFrame) && s.currentFrameIsLast {
+			s.finRead = true
+			return true, bytesWritten, err
+		}
+		if err != nil {
+			return false, bytesWritten, err
+		}
+	}
+}
+
 func (s *receiveStream) dequeueNextFrame() {
 	var offset protocol.ByteCount
 	// We're done with the last frame. Release the buffer.
@@ -207,6 +298,22 @@ func (s *receiveStream) CancelRead(errorCode protocol.ApplicationErrorCode) {
 	}
 }
 
+func (s *receiveStream) CancelReadAndWait(ctx context.Context, errorCode protocol.ApplicationErrorCode) error {
+	s.CancelRead(errorCode)
+	select {
+	case <-s.finalOffsetChan:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.finalOffset != protocol.MaxByteCount {
+		return nil
+	}
+	return s.closeForShutdownErr
+}
+
 func (s *receiveStream) cancelReadImpl(errorCode protocol.ApplicationErrorCode) bool /* completed */ {
 	if s.finRead || s.canceledRead || s.resetRemotely {
 		return false
@@ -243,6 +350,7 @@ func (s *receiveStream) handleStreamFrameImpl(frame *wire.StreamFrame) (bool /*
 	if frame.Fin {
 		newlyRcvdFinalOffset = s.finalOffset == protocol.MaxByteCount
 		s.finalOffset = maxOffset
+		s.signalFinalOffset()
 	}
 	if s.canceledRead {
 		return newlyRcvdFinalOffset, nil
@@ -275,9 +383,10 @@ func (s *receiveStream) handleResetStreamFrameImpl(frame *wire.ResetStreamFrame)
 	}
 	newlyRcvdFinalOffset := s.finalOffset == protocol.MaxByteCount
 	s.finalOffset = frame.FinalSize
+	s.signalFinalOffset()
 
 	// ignore duplicate RESET_STREAM frames for this stream (after checking their final offset)
-	if s.resetRemotely {
+	if s.resetRemotely || s.finRead {
 		return false, nil
 	}
 	s.resetRemotely = true
@@ -286,7 +395,10 @@ func (s *receiveStream) handleResetStreamFrameImpl(frame *wire.ResetStreamFrame)
 		error:     fmt.Errorf("stream %d was reset with error code %d", s.streamID, frame.ErrorCode),
 	}
 	s.signalRead()
-	return newlyRcvdFinalOffset, nil
+	// If the FIN was received before, the stream is only completed now,
+	// unless reading was canceled (in which case it was completed when the FIN was received).
+	// The data that wasn't read yet will never be read, so we need to return it to the connection flow controller.
+	return newlyRcvdFinalOffset || !s.canceledRead, nil
 }
 
 func (s *receiveStream) CloseRemote(offset protocol.ByteCount) {
@@ -308,14 +420,45 @@ func (s *receiveStream) closeForShutdown(err error) {
 	s.mutex.Lock()
 	s.closedForShutdown = true
 	s.closeForShutdownErr = err
+	s.signalFinalOffset()
 	s.mutex.Unlock()
 	s.signalRead()
 }
 
+// signalFinalOffset unblocks CancelReadAndWait.
+// It must be called with the mutex locked.
+func (s *receiveStream) signalFinalOffset() {
+	select {
+	case <-s.finalOffsetChan:
+	default:
+		close(s.finalOffsetChan)
+	}
+}
+
+func (s *receiveStream) Drain() {
+	s.mutex.Lock()
+	s.draining = true
+	s.mutex.Unlock()
+}
+
 func (s *receiveStream) getWindowUpdate() protocol.ByteCount {
+	s.mutex.Lock()
+	draining := s.draining
+	s.mutex.Unlock()
+
+	// Don't grant the peer any more flow control credit when draining the stream.
+	if draining {
+		return 0
+	}
 	return s.flowController.GetWindowUpdate()
 }
 
+// receiveWindowSize returns the number of bytes the peer is still allowed to send on this stream
+func (s *receiveStream) receiveWindowSize() protocol.ByteCount {
+	_, receiveWindow := s.flowController.Windows()
+	return receiveWindow
+}
+
 // signalRead performs a non-blocking send on the readChan
 func (s *receiveStream) signalRead() {
 	select {
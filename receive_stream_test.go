@@ -1,7 +1,10 @@
 package quic
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"runtime"
 	"time"
@@ -446,6 +449,92 @@ var _ = Describe("Receive Stream", func() {
 		})
 	})
 
+	Context("writing to an io.Writer", func() {
+		It("writes all data until the FIN", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(2), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), true)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2)).Times(2)
+			Expect(str.handleStreamFrame(&wire.StreamFrame{
+				Offset: 2,
+				Data:   []byte{0xBE, 0xEF},
+				Fin:    true,
+			})).To(Succeed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{
+				Offset: 0,
+				Data:   []byte{0xDE, 0xAD},
+			})).To(Succeed())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			buf := &bytes.Buffer{}
+			n, err := str.WriteTo(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeEquivalentTo(4))
+			Expect(buf.Bytes()).To(Equal([]byte{0xDE, 0xAD, 0xBE, 0xEF}))
+			// the stream was already read completely
+			n, err = str.WriteTo(buf)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeZero())
+		})
+
+		It("waits until data is available", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(2), false)
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(2), true)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(0))
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			buf := &bytes.Buffer{}
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.WriteTo(buf)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(BeEquivalentTo(2))
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte{0xDE, 0xAD}})).To(Succeed())
+			Consistently(done).ShouldNot(BeClosed())
+			str.CloseRemote(2)
+			Eventually(done).Should(BeClosed())
+			Expect(buf.Bytes()).To(Equal([]byte{0xDE, 0xAD}))
+		})
+
+		It("returns errors from the io.Writer", func() {
+			testErr := errors.New("test error")
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), false)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(0))
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte{0xDE, 0xAD, 0xBE, 0xEF}})).To(Succeed())
+			pr, pw := io.Pipe()
+			pr.CloseWithError(testErr)
+			n, err := str.WriteTo(pw)
+			Expect(err).To(MatchError(testErr))
+			Expect(n).To(BeZero())
+		})
+
+		It("unblocks when the stream is closed for shutdown", func() {
+			testErr := errors.New("test error")
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.WriteTo(&bytes.Buffer{})
+				Expect(err).To(MatchError(testErr))
+				Expect(n).To(BeZero())
+			}()
+			Consistently(done).ShouldNot(BeClosed())
+			str.closeForShutdown(testErr)
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("returns an error when the deadline expires", func() {
+			deadline := time.Now().Add(scaleDuration(50 * time.Millisecond))
+			str.SetReadDeadline(deadline)
+			n, err := str.WriteTo(&bytes.Buffer{})
+			Expect(err).To(MatchError(errDeadline))
+			Expect(n).To(BeZero())
+			Expect(time.Now()).To(BeTemporally("~", deadline, scaleDuration(20*time.Millisecond)))
+		})
+	})
+
 	Context("stream cancelations", func() {
 		Context("canceling read", func() {
 			It("unblocks Read", func() {
@@ -557,6 +646,66 @@ var _ = Describe("Receive Stream", func() {
 					Fin:    true,
 				})).To(Succeed())
 			})
+
+			Context("waiting for the peer", func() {
+				It("waits until the peer resets the stream", func() {
+					mockSender.EXPECT().queueControlFrame(&wire.StopSendingFrame{
+						StreamID:  streamID,
+						ErrorCode: 1234,
+					})
+					done := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						defer close(done)
+						Expect(str.CancelReadAndWait(context.Background(), 1234)).To(Succeed())
+					}()
+					Consistently(done).ShouldNot(BeClosed())
+					gomock.InOrder(
+						mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true),
+						mockFC.EXPECT().Abandon(),
+					)
+					mockSender.EXPECT().onStreamCompleted(streamID)
+					Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{
+						StreamID:  streamID,
+						FinalSize: 42,
+						ErrorCode: 1234,
+					})).To(Succeed())
+					Eventually(done).Should(BeClosed())
+				})
+
+				It("returns immediately if the final offset was already received", func() {
+					mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(1000), true)
+					Expect(str.handleStreamFrame(&wire.StreamFrame{
+						Offset: 1000,
+						Fin:    true,
+					})).To(Succeed())
+					mockFC.EXPECT().Abandon()
+					mockSender.EXPECT().queueControlFrame(gomock.Any())
+					mockSender.EXPECT().onStreamCompleted(streamID)
+					Expect(str.CancelReadAndWait(context.Background(), 1234)).To(Succeed())
+				})
+
+				It("returns when the context is canceled", func() {
+					mockSender.EXPECT().queueControlFrame(gomock.Any())
+					ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(20*time.Millisecond))
+					defer cancel()
+					Expect(str.CancelReadAndWait(ctx, 1234)).To(MatchError(context.DeadlineExceeded))
+				})
+
+				It("returns when the stream is closed for shutdown", func() {
+					mockSender.EXPECT().queueControlFrame(gomock.Any())
+					testErr := errors.New("test error")
+					done := make(chan struct{})
+					go func() {
+						defer GinkgoRecover()
+						defer close(done)
+						Expect(str.CancelReadAndWait(context.Background(), 1234)).To(MatchError(testErr))
+					}()
+					Consistently(done).ShouldNot(BeClosed())
+					str.closeForShutdown(testErr)
+					Eventually(done).Should(BeClosed())
+				})
+			})
 		})
 
 		Context("receiving RESET_STREAM frames", func() {
@@ -599,6 +748,9 @@ var _ = Describe("Receive Stream", func() {
 				Expect(err).To(BeAssignableToTypeOf(streamCanceledError{}))
 				Expect(err.(streamCanceledError).Canceled()).To(BeTrue())
 				Expect(err.(streamCanceledError).ErrorCode()).To(Equal(protocol.ApplicationErrorCode(1234)))
+				var streamErr StreamError
+				Expect(errors.As(fmt.Errorf("wrapped: %w", err), &streamErr)).To(BeTrue())
+				Expect(streamErr.ErrorCode()).To(Equal(protocol.ApplicationErrorCode(1234)))
 			})
 
 			It("errors when receiving a RESET_STREAM with an inconsistent offset", func() {
@@ -630,6 +782,38 @@ var _ = Describe("Receive Stream", func() {
 				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
 			})
 
+			It("completes the stream when it is reset after the FIN was received", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true).Times(2)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{
+					StreamID: streamID,
+					Offset:   32,
+					Data:     make([]byte, 10),
+					Fin:      true,
+				})).To(Succeed())
+				// the unread data is returned to the connection flow controller
+				gomock.InOrder(
+					mockFC.EXPECT().Abandon(),
+					mockSender.EXPECT().onStreamCompleted(streamID),
+				)
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+				_, err := strWithTimeout.Read([]byte{0})
+				Expect(err).To(MatchError("stream 1337 was reset with error code 1234"))
+			})
+
+			It("ignores RESET_STREAM frames after all data was read", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(42), true).Times(2)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{
+					StreamID: streamID,
+					Data:     make([]byte, 42),
+					Fin:      true,
+				})).To(Succeed())
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(42))
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				_, err := strWithTimeout.Read(make([]byte, 100))
+				Expect(err).To(MatchError(io.EOF))
+				Expect(str.handleResetStreamFrame(rst)).To(Succeed())
+			})
+
 			It("doesn't do anyting when it was closed for shutdown", func() {
 				str.closeForShutdown(nil)
 				err := str.handleResetStreamFrame(rst)
@@ -654,5 +838,49 @@ var _ = Describe("Receive Stream", func() {
 			mockFC.EXPECT().GetWindowUpdate().Return(protocol.ByteCount(0x100))
 			Expect(str.getWindowUpdate()).To(Equal(protocol.ByteCount(0x100)))
 		})
+
+		Context("draining", func() {
+			It("doesn't grant any more flow control credit", func() {
+				str.Drain()
+				Expect(str.getWindowUpdate()).To(BeZero())
+			})
+
+			It("delivers buffered data until the FIN", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(4), false)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foob")})).To(Succeed())
+				str.Drain()
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Offset: 4, Data: []byte("ar"), Fin: true})).To(Succeed())
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(4))
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(2))
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				b := make([]byte, 10)
+				n, err := strWithTimeout.Read(b)
+				Expect(err).To(MatchError(io.EOF))
+				Expect(b[:n]).To(Equal([]byte("foobar")))
+				Expect(str.getWindowUpdate()).To(BeZero())
+			})
+
+			It("delivers buffered data until the stream is reset", func() {
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+				Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+				str.Drain()
+				mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+				b := make([]byte, 6)
+				n, err := strWithTimeout.Read(b)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(b[:n]).To(Equal([]byte("foobar")))
+				mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), true)
+				mockFC.EXPECT().Abandon()
+				mockSender.EXPECT().onStreamCompleted(streamID)
+				Expect(str.handleResetStreamFrame(&wire.ResetStreamFrame{
+					StreamID:  streamID,
+					FinalSize: 6,
+					ErrorCode: 1234,
+				})).To(Succeed())
+				_, err = strWithTimeout.Read(b)
+				Expect(err).To(MatchError("stream 1337 was reset with error code 1234"))
+			})
+		})
 	})
 })
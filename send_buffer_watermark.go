@@ -0,0 +1,60 @@
+package quic
+
+import (
+	"sync"
+
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+)
+
+// The sendBufferWatermark tracks the amount of stream data that was sent, but not yet acknowledged by the peer,
+// summed over all streams of a session.
+// All methods may be called on a nil sendBufferWatermark, in which case they are no-ops.
+type sendBufferWatermark struct {
+	mutex sync.Mutex
+
+	high, low protocol.ByteCount
+	buffered  protocol.ByteCount
+	aboveHigh bool // set when the high watermark was reached, reset when the low watermark is reached
+}
+
+func newSendBufferWatermark(high, low protocol.ByteCount) *sendBufferWatermark {
+	return &sendBufferWatermark{high: high, low: low}
+}
+
+func (w *sendBufferWatermark) add(n protocol.ByteCount) {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	w.buffered += n
+	w.mutex.Unlock()
+}
+
+func (w *sendBufferWatermark) remove(n protocol.ByteCount) {
+	if w == nil {
+		return
+	}
+	w.mutex.Lock()
+	w.buffered -= n
+	w.mutex.Unlock()
+}
+
+// crossedWatermark says if the high watermark was reached,
+// or if the low watermark was reached after the high watermark, since the last call.
+func (w *sendBufferWatermark) crossedWatermark() (crossed, aboveHigh bool) {
+	if w == nil {
+		return false, false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if !w.aboveHigh && w.buffered >= w.high {
+		w.aboveHigh = true
+		return true, true
+	}
+	if w.aboveHigh && w.buffered <= w.low {
+		w.aboveHigh = false
+		return true, false
+	}
+	return false, w.aboveHigh
+}
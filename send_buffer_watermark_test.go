@@ -0,0 +1,71 @@
+package quic
+
+import (
+	"github.com/lucas-clemente/quic-go/internal/protocol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Send Buffer Watermark", func() {
+	var w *sendBufferWatermark
+
+	BeforeEach(func() {
+		w = newSendBufferWatermark(1000, 400)
+	})
+
+	It("says when the high watermark is reached", func() {
+		w.add(999)
+		crossed, _ := w.crossedWatermark()
+		Expect(crossed).To(BeFalse())
+		w.add(1)
+		crossed, aboveHigh := w.crossedWatermark()
+		Expect(crossed).To(BeTrue())
+		Expect(aboveHigh).To(BeTrue())
+		// only report the crossing once
+		w.add(100)
+		crossed, aboveHigh = w.crossedWatermark()
+		Expect(crossed).To(BeFalse())
+		Expect(aboveHigh).To(BeTrue())
+	})
+
+	It("says when the low watermark is reached, after the high watermark was reached", func() {
+		w.add(300)
+		crossed, _ := w.crossedWatermark()
+		Expect(crossed).To(BeFalse())
+		w.add(900)
+		crossed, _ = w.crossedWatermark()
+		Expect(crossed).To(BeTrue())
+		// dropping below the high watermark doesn't trigger anything
+		w.remove(600)
+		crossed, aboveHigh := w.crossedWatermark()
+		Expect(crossed).To(BeFalse())
+		Expect(aboveHigh).To(BeTrue())
+		w.remove(200)
+		crossed, aboveHigh = w.crossedWatermark()
+		Expect(crossed).To(BeTrue())
+		Expect(aboveHigh).To(BeFalse())
+		// only report the crossing once
+		w.remove(400)
+		crossed, _ = w.crossedWatermark()
+		Expect(crossed).To(BeFalse())
+	})
+
+	It("aggregates data from multiple streams", func() {
+		for i := 0; i < 4; i++ {
+			w.add(250)
+		}
+		crossed, aboveHigh := w.crossedWatermark()
+		Expect(crossed).To(BeTrue())
+		Expect(aboveHigh).To(BeTrue())
+		Expect(w.buffered).To(Equal(protocol.ByteCount(1000)))
+	})
+
+	It("can be used when nil", func() {
+		var w *sendBufferWatermark
+		w.add(1000)
+		w.remove(1000)
+		crossed, _ := w.crossedWatermark()
+		Expect(crossed).To(BeFalse())
+	})
+})
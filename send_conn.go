@@ -2,33 +2,96 @@ package quic
 
 import (
 	"net"
+	"sync"
 )
 
 // A sendConn allows sending using a simple Write() on a non-connected packet conn.
 type sendConn interface {
 	Write([]byte) error
+	// WriteTo sends a packet to an address other than the remote address, using the same packet conn.
+	// It is used to respond to path validation on a new path.
+	WriteTo([]byte, net.Addr) error
 	Close() error
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
+	// SupportsDF says if the don't-fragment bit is set on the packet conn.
+	// Path MTU discovery is only performed if it is.
+	SupportsDF() bool
+	// SetPacketConn changes the packet conn used for sending, when migrating to a new local address.
+	SetPacketConn(conn net.PacketConn, supportsDF bool)
+	// SetRemoteAddr changes the remote address, when the peer migrated to a new address.
+	SetRemoteAddr(net.Addr)
 }
 
 type sconn struct {
-	net.PacketConn
+	mutex sync.RWMutex
 
+	conn       net.PacketConn
 	remoteAddr net.Addr
+	supportsDF bool
 }
 
 var _ sendConn = &sconn{}
 
-func newSendConn(c net.PacketConn, remote net.Addr) sendConn {
-	return &sconn{PacketConn: c, remoteAddr: remote}
+// newSendConn creates a new sendConn.
+// supportsDF says if the don't-fragment bit was set on the packet conn, see maybeEnableDF.
+func newSendConn(c net.PacketConn, remote net.Addr, supportsDF bool) sendConn {
+	return &sconn{
+		conn:       c,
+		remoteAddr: remote,
+		supportsDF: supportsDF,
+	}
 }
 
 func (c *sconn) Write(p []byte) error {
-	_, err := c.PacketConn.WriteTo(p, c.remoteAddr)
+	c.mutex.RLock()
+	conn, remoteAddr := c.conn, c.remoteAddr
+	c.mutex.RUnlock()
+	_, err := conn.WriteTo(p, remoteAddr)
 	return err
 }
 
+func (c *sconn) WriteTo(p []byte, addr net.Addr) error {
+	c.mutex.RLock()
+	conn := c.conn
+	c.mutex.RUnlock()
+	_, err := conn.WriteTo(p, addr)
+	return err
+}
+
+func (c *sconn) Close() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn.Close()
+}
+
+func (c *sconn) LocalAddr() net.Addr {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.conn.LocalAddr()
+}
+
 func (c *sconn) RemoteAddr() net.Addr {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 	return c.remoteAddr
 }
+
+func (c *sconn) SupportsDF() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.supportsDF
+}
+
+func (c *sconn) SetPacketConn(conn net.PacketConn, supportsDF bool) {
+	c.mutex.Lock()
+	c.conn = conn
+	c.supportsDF = supportsDF
+	c.mutex.Unlock()
+}
+
+func (c *sconn) SetRemoteAddr(addr net.Addr) {
+	c.mutex.Lock()
+	c.remoteAddr = addr
+	c.mutex.Unlock()
+}
@@ -17,7 +17,7 @@ var _ = Describe("Connection (for sending packets)", func() {
 	BeforeEach(func() {
 		addr = &net.UDPAddr{IP: net.IPv4(192, 168, 100, 200), Port: 1337}
 		packetConn = NewMockPacketConn(mockCtrl)
-		c = newSendConn(packetConn, addr)
+		c = newSendConn(packetConn, addr, false)
 	})
 
 	It("writes", func() {
@@ -42,4 +42,27 @@ var _ = Describe("Connection (for sending packets)", func() {
 		packetConn.EXPECT().Close()
 		Expect(c.Close()).To(Succeed())
 	})
+
+	It("writes to a different address", func() {
+		otherAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 201), Port: 1338}
+		packetConn.EXPECT().WriteTo([]byte("foobar"), otherAddr)
+		Expect(c.WriteTo([]byte("foobar"), otherAddr)).To(Succeed())
+		Expect(c.RemoteAddr()).To(Equal(addr))
+	})
+
+	It("changes the remote address", func() {
+		newAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 100, 201), Port: 1338}
+		c.SetRemoteAddr(newAddr)
+		Expect(c.RemoteAddr()).To(Equal(newAddr))
+		packetConn.EXPECT().WriteTo([]byte("foobar"), newAddr)
+		Expect(c.Write([]byte("foobar"))).To(Succeed())
+	})
+
+	It("changes the packet conn", func() {
+		newPacketConn := NewMockPacketConn(mockCtrl)
+		c.SetPacketConn(newPacketConn, true)
+		Expect(c.SupportsDF()).To(BeTrue())
+		newPacketConn.EXPECT().WriteTo([]byte("foobar"), addr)
+		Expect(c.Write([]byte("foobar"))).To(Succeed())
+	})
 })
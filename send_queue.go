@@ -38,7 +38,11 @@ func (h *sendQueue) Run() error {
 			shouldClose = true
 		case p := <-h.queue:
 			if err := h.conn.Write(p.Data); err != nil {
-				return err
+				// With the DF bit set, the kernel refuses to send datagrams larger than the path MTU it knows of.
+				// This is expected for path MTU probe packets. Loss detection will declare them lost.
+				if !p.isMTUProbePacket || !isMsgSizeErr(err) {
+					return err
+				}
 			}
 			p.Release()
 		}
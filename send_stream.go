@@ -3,6 +3,7 @@ package quic
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -21,6 +22,7 @@ type sendStreamI interface {
 	popStreamFrame(maxBytes protocol.ByteCount) (*ackhandler.Frame, bool)
 	closeForShutdown(error)
 	handleMaxStreamDataFrame(*wire.MaxStreamDataFrame)
+	sendWindowSize() protocol.ByteCount
 }
 
 type sendStream struct {
@@ -29,6 +31,11 @@ type sendStream struct {
 	numOutstandingFrames int64
 	retransmissionQueue  []*wire.StreamFrame
 
+	// unackedBytes is the amount of stream data that was sent, but not yet acknowledged.
+	// It is reported to the sendBuffer.
+	unackedBytes protocol.ByteCount
+	sendBuffer   *sendBufferWatermark
+
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 
@@ -48,31 +55,43 @@ type sendStream struct {
 
 	dataForWriting []byte // during a Write() call, this slice is the part of p that still needs to be sent out
 	nextFrame      *wire.StreamFrame
+	queuedFrames   []*wire.StreamFrame // STREAM frames filled by ReadFrom(), waiting to be sent
+	frameSizeHint  protocol.ByteCount  // the maximum data length of the last STREAM frame popped, used to size the frames filled by ReadFrom()
 
 	writeChan chan struct{}
 	deadline  time.Time
 
+	blockedByFlowControl bool          // set when data couldn't be sent because the send window was exhausted
+	writableChan         chan struct{} // receives a value when the stream is unblocked
+
 	flowController flowcontrol.StreamFlowController
 
 	version protocol.VersionNumber
 }
 
 var (
-	_ SendStream  = &sendStream{}
-	_ sendStreamI = &sendStream{}
+	_ SendStream    = &sendStream{}
+	_ sendStreamI   = &sendStream{}
+	_ io.ReaderFrom = &sendStream{}
 )
 
+// maxQueuedStreamFrames is the maximum number of STREAM frames that ReadFrom() fills ahead of time.
+const maxQueuedStreamFrames = 32
+
 func newSendStream(
 	streamID protocol.StreamID,
 	sender streamSender,
 	flowController flowcontrol.StreamFlowController,
+	sendBuffer *sendBufferWatermark,
 	version protocol.VersionNumber,
 ) *sendStream {
 	s := &sendStream{
 		streamID:       streamID,
 		sender:         sender,
 		flowController: flowController,
+		sendBuffer:     sendBuffer,
 		writeChan:      make(chan struct{}, 1),
+		writableChan:   make(chan struct{}, 1),
 		version:        version,
 	}
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
@@ -186,6 +205,120 @@ func (s *sendStream) Write(p []byte) (int, error) {
 	return bytesWritten, nil
 }
 
+// ReadFrom implements io.ReaderFrom.
+// It reads from r directly into the buffers of STREAM frames, avoiding the intermediate copy done by io.Copy.
+// It returns when r returns io.EOF (or any other error) and all data read has been handed to the packer.
+// Just like Write, it blocks when flow control doesn't allow sending more data.
+func (s *sendStream) ReadFrom(r io.Reader) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.finishedWriting {
+		return 0, fmt.Errorf("write on closed stream %d", s.streamID)
+	}
+
+	deadlineTimer := utils.NewTimer()
+	defer deadlineTimer.Stop()
+
+	var (
+		bytesWritten int64
+		readErr      error
+	)
+	for readErr == nil {
+		if err := s.waitForQueuedFrames(maxQueuedStreamFrames-1, deadlineTimer); err != nil {
+			return bytesWritten - s.dropQueuedFrames(), err
+		}
+		f := wire.GetStreamFrame()
+		f.StreamID = s.streamID
+		f.DataLenPresent = true
+		f.Fin = false
+		f.Data = f.Data[:s.readFromFrameSize()]
+
+		s.mutex.Unlock()
+		var n int
+		n, readErr = r.Read(f.Data)
+		s.mutex.Lock()
+
+		if n == 0 {
+			f.PutBack()
+			continue
+		}
+		f.Data = f.Data[:n]
+		s.queuedFrames = append(s.queuedFrames, f)
+		bytesWritten += int64(n)
+
+		s.mutex.Unlock()
+		s.sender.onHasStreamData(s.streamID) // must be called without holding the mutex
+		s.mutex.Lock()
+	}
+	// Wait until all frames have been handed over,
+	// so that a subsequent Write() or Close() can't reorder the stream data.
+	if err := s.waitForQueuedFrames(0, deadlineTimer); err != nil {
+		return bytesWritten - s.dropQueuedFrames(), err
+	}
+	if readErr == io.EOF {
+		return bytesWritten, nil
+	}
+	return bytesWritten, readErr
+}
+
+// readFromFrameSize is the number of bytes ReadFrom() reads into a single STREAM frame.
+// By using the size of the last STREAM frame popped, we avoid splitting the frame when packing it.
+func (s *sendStream) readFromFrameSize() protocol.ByteCount {
+	if s.frameSizeHint == 0 {
+		return protocol.MaxReceivePacketSize
+	}
+	return s.frameSizeHint
+}
+
+// waitForQueuedFrames blocks until no more than maxLen of the frames filled by ReadFrom() are waiting to be sent.
+// It must be called with the mutex held.
+func (s *sendStream) waitForQueuedFrames(maxLen int, deadlineTimer *utils.Timer) error {
+	for {
+		if s.canceledWrite {
+			return s.cancelWriteErr
+		}
+		if s.closeForShutdownErr != nil {
+			return s.closeForShutdownErr
+		}
+		deadline := s.deadline
+		if !deadline.IsZero() {
+			if !time.Now().Before(deadline) {
+				return errDeadline
+			}
+			deadlineTimer.Reset(deadline)
+		}
+		if len(s.queuedFrames) <= maxLen {
+			return nil
+		}
+
+		s.mutex.Unlock()
+		if deadline.IsZero() {
+			<-s.writeChan
+		} else {
+			select {
+			case <-s.writeChan:
+			case <-deadlineTimer.Chan():
+				deadlineTimer.SetRead()
+			}
+		}
+		s.mutex.Lock()
+	}
+}
+
+// dropQueuedFrames releases the frames filled by ReadFrom() that haven't been sent yet.
+// It returns the number of bytes dropped.
+// It must be called with the mutex held.
+func (s *sendStream) dropQueuedFrames() int64 {
+	var n int64
+	for _, f := range s.queuedFrames {
+		n += int64(len(f.Data))
+		f.PutBack()
+	}
+	s.queuedFrames = nil
+	return n
+}
+
 func (s *sendStream) canBufferStreamFrame() bool {
 	var l protocol.ByteCount
 	if s.nextFrame != nil {
@@ -227,6 +360,14 @@ func (s *sendStream) popNewOrRetransmittedStreamFrame(maxBytes protocol.ByteCoun
 		}
 	}
 
+	if s.nextFrame == nil && len(s.queuedFrames) > 0 {
+		s.nextFrame = s.queuedFrames[0]
+		s.nextFrame.Offset = s.writeOffset
+		s.queuedFrames[0] = nil
+		s.queuedFrames = s.queuedFrames[1:]
+		s.signalWrite()
+	}
+
 	if len(s.dataForWriting) == 0 && s.nextFrame == nil {
 		if s.finishedWriting && !s.finSent {
 			s.finSent = true
@@ -242,6 +383,7 @@ func (s *sendStream) popNewOrRetransmittedStreamFrame(maxBytes protocol.ByteCoun
 
 	sendWindow := s.flowController.SendWindowSize()
 	if sendWindow == 0 {
+		s.blockedByFlowControl = true
 		if isBlocked, offset := s.flowController.IsNewlyBlocked(); isBlocked {
 			s.sender.queueControlFrame(&wire.StreamDataBlockedFrame{
 				StreamID:          s.streamID,
@@ -251,13 +393,19 @@ func (s *sendStream) popNewOrRetransmittedStreamFrame(maxBytes protocol.ByteCoun
 		}
 		return nil, true
 	}
+	// The connection-level window might have been opened by a MAX_DATA frame.
+	if s.blockedByFlowControl {
+		s.signalWritable()
+	}
 
 	f, hasMoreData := s.popNewStreamFrame(maxBytes, sendWindow)
 	if dataLen := f.DataLen(); dataLen > 0 {
 		s.writeOffset += f.DataLen()
 		s.flowController.AddBytesSent(f.DataLen())
+		s.unackedBytes += f.DataLen()
+		s.sendBuffer.add(f.DataLen())
 	}
-	f.Fin = s.finishedWriting && s.dataForWriting == nil && s.nextFrame == nil && !s.finSent
+	f.Fin = s.finishedWriting && s.dataForWriting == nil && s.nextFrame == nil && len(s.queuedFrames) == 0 && !s.finSent
 	if f.Fin {
 		s.finSent = true
 	}
@@ -269,7 +417,11 @@ func (s *sendStream) popNewStreamFrame(maxBytes, sendWindow protocol.ByteCount)
 		nextFrame := s.nextFrame
 		s.nextFrame = nil
 
-		maxDataLen := utils.MinByteCount(sendWindow, nextFrame.MaxDataLen(maxBytes, s.version))
+		frameSize := nextFrame.MaxDataLen(maxBytes, s.version)
+		if frameSize >= protocol.MinStreamFrameSize {
+			s.frameSizeHint = utils.MinByteCount(frameSize, protocol.MaxReceivePacketSize)
+		}
+		maxDataLen := utils.MinByteCount(sendWindow, frameSize)
 		if nextFrame.DataLen() > maxDataLen {
 			s.nextFrame = wire.GetStreamFrame()
 			s.nextFrame.StreamID = s.streamID
@@ -281,7 +433,7 @@ func (s *sendStream) popNewStreamFrame(maxBytes, sendWindow protocol.ByteCount)
 		} else {
 			s.signalWrite()
 		}
-		return nextFrame, s.nextFrame != nil || s.dataForWriting != nil
+		return nextFrame, s.nextFrame != nil || s.dataForWriting != nil || len(s.queuedFrames) > 0
 	}
 
 	f := wire.GetStreamFrame()
@@ -343,9 +495,16 @@ func (s *sendStream) getDataForWriting(f *wire.StreamFrame, maxBytes protocol.By
 }
 
 func (s *sendStream) frameAcked(f wire.Frame) {
-	f.(*wire.StreamFrame).PutBack()
+	sf := f.(*wire.StreamFrame)
+	dataLen := sf.DataLen()
+	sf.PutBack()
 
 	s.mutex.Lock()
+	// When the stream is canceled, the unacknowledged data is removed from the send buffer at once.
+	if !s.canceledWrite && !s.closedForShutdown {
+		s.unackedBytes -= dataLen
+		s.sendBuffer.remove(dataLen)
+	}
 	s.numOutstandingFrames--
 	if s.numOutstandingFrames < 0 {
 		panic("numOutStandingFrames negative")
@@ -413,6 +572,7 @@ func (s *sendStream) cancelWriteImpl(errorCode protocol.ApplicationErrorCode, wr
 	s.ctxCancel()
 	s.canceledWrite = true
 	s.cancelWriteErr = writeErr
+	s.releaseUnackedBytes()
 	newlyCompleted := s.isNewlyCompleted()
 	s.mutex.Unlock()
 
@@ -429,10 +589,13 @@ func (s *sendStream) cancelWriteImpl(errorCode protocol.ApplicationErrorCode, wr
 
 func (s *sendStream) handleMaxStreamDataFrame(frame *wire.MaxStreamDataFrame) {
 	s.mutex.Lock()
-	hasStreamData := s.dataForWriting != nil || s.nextFrame != nil
+	hasStreamData := s.dataForWriting != nil || s.nextFrame != nil || len(s.queuedFrames) > 0
+	s.flowController.UpdateSendWindow(frame.MaximumStreamData)
+	if s.blockedByFlowControl && s.flowController.SendWindowSize() > 0 {
+		s.signalWritable()
+	}
 	s.mutex.Unlock()
 
-	s.flowController.UpdateSendWindow(frame.MaximumStreamData)
 	if hasStreamData {
 		s.sender.onHasStreamData(s.streamID)
 	}
@@ -450,6 +613,17 @@ func (s *sendStream) Context() context.Context {
 	return s.ctx
 }
 
+// sendWindowSize returns the number of bytes we're still allowed to send on this stream.
+// Connection-level flow control is not taken into account.
+func (s *sendStream) sendWindowSize() protocol.ByteCount {
+	sendWindow, _ := s.flowController.Windows()
+	return sendWindow
+}
+
+func (s *sendStream) Writable() <-chan struct{} {
+	return s.writableChan
+}
+
 func (s *sendStream) SetWriteDeadline(t time.Time) error {
 	s.mutex.Lock()
 	s.deadline = t
@@ -466,10 +640,28 @@ func (s *sendStream) closeForShutdown(err error) {
 	s.ctxCancel()
 	s.closedForShutdown = true
 	s.closeForShutdownErr = err
+	s.releaseUnackedBytes()
 	s.mutex.Unlock()
 	s.signalWrite()
 }
 
+// releaseUnackedBytes removes all unacknowledged data from the send buffer.
+// It must be called with the mutex held.
+func (s *sendStream) releaseUnackedBytes() {
+	s.sendBuffer.remove(s.unackedBytes)
+	s.unackedBytes = 0
+}
+
+// signalWritable performs a non-blocking send on the writableChan.
+// It must be called with the mutex held.
+func (s *sendStream) signalWritable() {
+	s.blockedByFlowControl = false
+	select {
+	case s.writableChan <- struct{}{}:
+	default:
+	}
+}
+
 // signalWrite performs a non-blocking send on the writeChan
 func (s *sendStream) signalWrite() {
 	select {
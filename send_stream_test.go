@@ -32,7 +32,7 @@ var _ = Describe("Send Stream", func() {
 	BeforeEach(func() {
 		mockSender = NewMockStreamSender(mockCtrl)
 		mockFC = mocks.NewMockStreamFlowController(mockCtrl)
-		str = newSendStream(streamID, mockSender, mockFC, protocol.VersionWhatever)
+		str = newSendStream(streamID, mockSender, mockFC, nil, protocol.VersionWhatever)
 
 		timeout := scaleDuration(250 * time.Millisecond)
 		strWithTimeout = gbytes.TimeoutWriter(str, timeout)
@@ -364,6 +364,77 @@ var _ = Describe("Send Stream", func() {
 				str.closeForShutdown(nil)
 				Eventually(done).Should(BeClosed())
 			})
+
+			It("notifies when the stream becomes writable after a MAX_STREAM_DATA frame", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					mockSender.EXPECT().onHasStreamData(streamID)
+					_, err := str.Write([]byte("foobar"))
+					Expect(err).ToNot(HaveOccurred())
+				}()
+				waitForWrite()
+				mockFC.EXPECT().SendWindowSize()
+				mockFC.EXPECT().IsNewlyBlocked().Return(true, protocol.ByteCount(10))
+				mockSender.EXPECT().queueControlFrame(gomock.Any())
+				f, _ := str.popStreamFrame(1000)
+				Expect(f).To(BeNil())
+				Expect(str.Writable()).ToNot(Receive())
+				// a MAX_STREAM_DATA frame that doesn't open the window
+				mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(10))
+				mockFC.EXPECT().SendWindowSize()
+				mockSender.EXPECT().onHasStreamData(streamID)
+				str.handleMaxStreamDataFrame(&wire.MaxStreamDataFrame{StreamID: streamID, MaximumStreamData: 10})
+				Expect(str.Writable()).ToNot(Receive())
+				// a MAX_STREAM_DATA frame that opens the window
+				mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(20))
+				mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(10))
+				mockSender.EXPECT().onHasStreamData(streamID)
+				str.handleMaxStreamDataFrame(&wire.MaxStreamDataFrame{StreamID: streamID, MaximumStreamData: 20})
+				Expect(str.Writable()).To(Receive())
+				// make the Write go routine return
+				str.closeForShutdown(nil)
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("notifies when the stream becomes writable after the connection-level window was opened", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					defer close(done)
+					mockSender.EXPECT().onHasStreamData(streamID)
+					_, err := str.Write([]byte("foobar"))
+					Expect(err).ToNot(HaveOccurred())
+				}()
+				waitForWrite()
+				// blocked by connection-level flow control
+				mockFC.EXPECT().SendWindowSize()
+				mockFC.EXPECT().IsNewlyBlocked()
+				f, hasMoreData := str.popStreamFrame(1000)
+				Expect(f).To(BeNil())
+				Expect(hasMoreData).To(BeTrue())
+				Expect(str.Writable()).ToNot(Receive())
+				// the window was opened by a MAX_DATA frame
+				mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(3))
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(3))
+				f, _ = str.popStreamFrame(expectedFrameHeaderLen(0) + 3)
+				Expect(f).ToNot(BeNil())
+				Expect(str.Writable()).To(Receive())
+				// only notify once
+				mockFC.EXPECT().SendWindowSize().Return(protocol.ByteCount(3))
+				mockFC.EXPECT().AddBytesSent(protocol.ByteCount(3))
+				f, _ = str.popStreamFrame(expectedFrameHeaderLen(3) + 3)
+				Expect(f).ToNot(BeNil())
+				Expect(str.Writable()).ToNot(Receive())
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("doesn't notify if the stream was never blocked", func() {
+				mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(42))
+				str.handleMaxStreamDataFrame(&wire.MaxStreamDataFrame{StreamID: streamID, MaximumStreamData: 42})
+				Expect(str.Writable()).ToNot(Receive())
+			})
 		})
 
 		Context("deadlines", func() {
@@ -649,6 +720,108 @@ var _ = Describe("Send Stream", func() {
 		})
 	})
 
+	Context("reading from an io.Reader", func() {
+		It("reads the data into STREAM frames", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+			mockFC.EXPECT().AddBytesSent(gomock.Any()).AnyTimes()
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.ReadFrom(bytes.NewReader(getData(5000)))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(BeEquivalentTo(5000))
+			}()
+			var data []byte
+			Eventually(func() []byte {
+				if frame, _ := str.popStreamFrame(1000); frame != nil {
+					f := frame.Frame.(*wire.StreamFrame)
+					Expect(f.Offset).To(BeEquivalentTo(len(data)))
+					Expect(f.Fin).To(BeFalse())
+					data = append(data, f.Data...)
+				}
+				return data
+			}).Should(Equal(getData(5000)))
+			Eventually(done).Should(BeClosed())
+			Expect(str.queuedFrames).To(BeEmpty())
+		})
+
+		It("sends a FIN after Close is called", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+			mockFC.EXPECT().AddBytesSent(protocol.ByteCount(6))
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				n, err := str.ReadFrom(bytes.NewReader([]byte("foobar")))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(n).To(BeEquivalentTo(6))
+				Expect(str.Close()).To(Succeed())
+			}()
+			Eventually(func() bool {
+				str.mutex.Lock()
+				defer str.mutex.Unlock()
+				return len(str.queuedFrames) > 0
+			}).Should(BeTrue())
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			Eventually(done).Should(BeClosed())
+			f := frame.Frame.(*wire.StreamFrame)
+			Expect(f.Data).To(Equal([]byte("foobar")))
+			frame, _ = str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			f = frame.Frame.(*wire.StreamFrame)
+			Expect(f.Offset).To(BeEquivalentTo(6))
+			Expect(f.Data).To(BeEmpty())
+			Expect(f.Fin).To(BeTrue())
+		})
+
+		It("stops reading when flow control doesn't allow sending more data", func() {
+			testErr := errors.New("test error")
+			mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+			var n int64
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				defer close(done)
+				var err error
+				n, err = str.ReadFrom(bytes.NewReader(make([]byte, 1<<20)))
+				Expect(err).To(MatchError(testErr))
+			}()
+			queueLen := func() int {
+				str.mutex.Lock()
+				defer str.mutex.Unlock()
+				return len(str.queuedFrames)
+			}
+			Eventually(queueLen).Should(Equal(maxQueuedStreamFrames))
+			Consistently(queueLen).Should(Equal(maxQueuedStreamFrames))
+			Expect(done).ToNot(BeClosed())
+			// make the ReadFrom go routine return
+			str.closeForShutdown(testErr)
+			Eventually(done).Should(BeClosed())
+			Expect(n).To(BeZero())
+			Expect(str.queuedFrames).To(BeEmpty())
+		})
+
+		It("returns an error when the deadline expires", func() {
+			mockSender.EXPECT().onHasStreamData(streamID).AnyTimes()
+			deadline := time.Now().Add(scaleDuration(50 * time.Millisecond))
+			str.SetWriteDeadline(deadline)
+			n, err := str.ReadFrom(bytes.NewReader(make([]byte, 1<<20)))
+			Expect(err).To(MatchError(errDeadline))
+			Expect(n).To(BeZero())
+			Expect(time.Now()).To(BeTemporally("~", deadline, scaleDuration(20*time.Millisecond)))
+		})
+
+		It("doesn't allow calls after Close", func() {
+			Expect(str.Close()).To(Succeed())
+			_, err := str.ReadFrom(bytes.NewReader([]byte("foobar")))
+			Expect(err).To(MatchError("write on closed stream 1337"))
+		})
+	})
+
 	Context("handling MAX_STREAM_DATA frames", func() {
 		It("informs the flow controller", func() {
 			mockFC.EXPECT().UpdateSendWindow(protocol.ByteCount(0x1337))
@@ -1131,4 +1304,50 @@ var _ = Describe("Send Stream", func() {
 			Expect(received).To(Equal(data))
 		})
 	})
+
+	Context("tracking unacknowledged data", func() {
+		BeforeEach(func() {
+			str.sendBuffer = newSendBufferWatermark(1000, 500)
+			mockFC.EXPECT().SendWindowSize().Return(protocol.MaxByteCount).AnyTimes()
+			mockFC.EXPECT().AddBytesSent(gomock.Any()).AnyTimes()
+			mockSender.EXPECT().onHasStreamData(streamID)
+			_, err := strWithTimeout.Write(getData(100))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("counts data until it is acknowledged", func() {
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			Expect(str.sendBuffer.buffered).To(Equal(protocol.ByteCount(100)))
+			// lost data is still counted, since it will be retransmitted
+			mockSender.EXPECT().onHasStreamData(streamID)
+			frame.OnLost(frame.Frame)
+			Expect(str.sendBuffer.buffered).To(Equal(protocol.ByteCount(100)))
+			ret, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(ret).ToNot(BeNil())
+			Expect(str.sendBuffer.buffered).To(Equal(protocol.ByteCount(100)))
+			ret.OnAcked(ret.Frame)
+			Expect(str.sendBuffer.buffered).To(BeZero())
+		})
+
+		It("releases unacknowledged data when writing is canceled", func() {
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			Expect(str.sendBuffer.buffered).To(Equal(protocol.ByteCount(100)))
+			mockSender.EXPECT().queueControlFrame(gomock.Any())
+			str.CancelWrite(1234)
+			Expect(str.sendBuffer.buffered).To(BeZero())
+			mockSender.EXPECT().onStreamCompleted(streamID)
+			frame.OnAcked(frame.Frame)
+			Expect(str.sendBuffer.buffered).To(BeZero())
+		})
+
+		It("releases unacknowledged data when the stream is closed for shutdown", func() {
+			frame, _ := str.popStreamFrame(protocol.MaxByteCount)
+			Expect(frame).ToNot(BeNil())
+			Expect(str.sendBuffer.buffered).To(Equal(protocol.ByteCount(100)))
+			str.closeForShutdown(errors.New("shutdown"))
+			Expect(str.sendBuffer.buffered).To(BeZero())
+		})
+	})
 })
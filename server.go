@@ -38,6 +38,7 @@ type packetHandlerManager interface {
 	Destroy() error
 	sessionRunner
 	SetServer(unknownPacketHandler)
+	SetUnknownPacketHandler(func(data []byte, addr net.Addr))
 	CloseServer()
 }
 
@@ -50,6 +51,7 @@ type quicSession interface {
 	run() error
 	destroy(error)
 	shutdown()
+	drain()
 }
 
 // A Listener of QUIC
@@ -62,6 +64,8 @@ type baseServer struct {
 	config  *Config
 
 	conn net.PacketConn
+	// supportsDF says if the don't-fragment bit is set on the packet conn.
+	supportsDF bool
 	// If the server is started with ListenAddr, we create a packet conn.
 	// If it is started with Listen, we take a packet conn as a parameter.
 	createdPacketConn bool
@@ -71,6 +75,9 @@ type baseServer struct {
 	zeroRTTQueue   *zeroRTTQueue
 	sessionHandler packetHandlerManager
 
+	// nil, if connection attempts are not rate limited
+	connAttemptLimiter *connectionAttemptLimiter
+
 	receivedPackets chan *receivedPacket
 
 	// set as a member, so they can be set in the tests
@@ -96,6 +103,11 @@ type baseServer struct {
 	errorChan   chan struct{}
 	closed      bool
 	running     chan struct{} // closed as soon as run() returns
+	draining    chan struct{} // closed when CloseGracefully is called
+	isDraining  bool
+
+	// all sessions that were created by this server and haven't been closed yet
+	sessions map[quicSession]struct{}
 
 	sessionQueue    chan quicSession
 	sessionQueueLen int32 // to be used as an atomic
@@ -196,6 +208,7 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config, acceptEarl
 	}
 	s := &baseServer{
 		conn:                conn,
+		supportsDF:          maybeEnableDF(conn, config),
 		tlsConf:             tlsConf,
 		config:              config,
 		tokenGenerator:      tokenGenerator,
@@ -204,11 +217,19 @@ func listen(conn net.PacketConn, tlsConf *tls.Config, config *Config, acceptEarl
 		sessionQueue:        make(chan quicSession),
 		errorChan:           make(chan struct{}),
 		running:             make(chan struct{}),
+		draining:            make(chan struct{}),
+		sessions:            make(map[quicSession]struct{}),
 		receivedPackets:     make(chan *receivedPacket, protocol.MaxServerUnprocessedPackets),
 		newSession:          newSession,
-		logger:              utils.DefaultLogger.WithPrefix("server"),
+		logger:              getLogger(config).WithPrefix("server"),
 		acceptEarlySessions: acceptEarly,
 	}
+	if config.ConnectionAttemptRate > 0 {
+		s.connAttemptLimiter = newConnectionAttemptLimiter(config.ConnectionAttemptRate, config.ConnectionAttemptBurst)
+	}
+	if config.UnknownPacketHandler != nil {
+		sessionHandler.SetUnknownPacketHandler(config.UnknownPacketHandler)
+	}
 	go s.run()
 	sessionHandler.SetServer(s)
 	s.logger.Debugf("Listening for %s connections on %s", conn.LocalAddr().Network(), conn.LocalAddr().String())
@@ -269,6 +290,8 @@ func (s *baseServer) accept(ctx context.Context) (quicSession, error) {
 		return sess, nil
 	case <-s.errorChan:
 		return nil, s.serverError
+	case <-s.draining:
+		return nil, s.serverError
 	}
 }
 
@@ -295,6 +318,43 @@ func (s *baseServer) Close() error {
 	return err
 }
 
+// CloseGracefully stops accepting new sessions, and waits for the existing sessions to close.
+// Existing sessions are drained: the peer isn't allowed to open any new streams,
+// and sessions are closed as soon as all of their streams have completed.
+// When the context expires, the remaining sessions are closed, and the context's error is returned.
+func (s *baseServer) CloseGracefully(ctx context.Context) error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	if !s.isDraining {
+		s.isDraining = true
+		s.serverError = errors.New("server closed")
+		close(s.draining)
+	}
+	sessions := make([]quicSession, 0, len(s.sessions))
+	for sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.mutex.Unlock()
+
+	s.logger.Debugf("Shutting down gracefully. Draining %d sessions.", len(sessions))
+	for _, sess := range sessions {
+		go sess.drain()
+	}
+	for _, sess := range sessions {
+		select {
+		case <-sess.Context().Done():
+		case <-ctx.Done():
+			// Close sends a CONNECTION_CLOSE to all remaining sessions.
+			s.Close()
+			return ctx.Err()
+		}
+	}
+	return s.Close()
+}
+
 func (s *baseServer) setCloseError(e error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -306,6 +366,15 @@ func (s *baseServer) setCloseError(e error) {
 	close(s.errorChan)
 }
 
+func (s *baseServer) isShuttingDown() bool {
+	select {
+	case <-s.draining:
+		return true
+	default:
+		return false
+	}
+}
+
 // Addr returns the server's network address
 func (s *baseServer) Addr() net.Addr {
 	return s.conn.LocalAddr()
@@ -417,6 +486,39 @@ func (s *baseServer) handleInitialImpl(p *receivedPacket, hdr *wire.Header) erro
 			}
 		}
 	}
+	if s.isShuttingDown() {
+		s.logger.Debugf("Rejecting new connection. Server is shutting down.")
+		go func() {
+			defer p.buffer.Release()
+			if err := s.sendConnectionRefused(p.remoteAddr, hdr); err != nil {
+				s.logger.Debugf("Error rejecting connection: %s", err)
+			}
+		}()
+		return nil
+	}
+	if s.connAttemptLimiter != nil {
+		switch s.connAttemptLimiter.Allow(p.remoteAddr, time.Now()) {
+		case connectionAttemptDrop:
+			p.buffer.Release()
+			if s.config.Tracer != nil {
+				s.config.Tracer.DroppedPacket(p.remoteAddr, logging.PacketTypeInitial, p.Size(), logging.PacketDropDOSPrevention)
+			}
+			s.logger.Debugf("Connection attempt rate exceeded for %s. Dropping Initial packet.", p.remoteAddr)
+			return nil
+		case connectionAttemptRetry:
+			// Clients that already completed a Retry are validated by AcceptToken below.
+			if token == nil || !token.IsRetryToken {
+				s.logger.Debugf("Connection attempt rate exceeded for %s. Sending a Retry.", p.remoteAddr)
+				go func() {
+					defer p.buffer.Release()
+					if err := s.sendRetry(p.remoteAddr, hdr); err != nil {
+						s.logger.Debugf("Error sending Retry: %s", err)
+					}
+				}()
+				return nil
+			}
+		}
+	}
 	if !s.config.AcceptToken(p.remoteAddr, token) {
 		go func() {
 			defer p.buffer.Release()
@@ -494,7 +596,7 @@ func (s *baseServer) createNewSession(
 			tracer = s.config.Tracer.TracerForConnection(protocol.PerspectiveServer, connID)
 		}
 		sess = s.newSession(
-			newSendConn(s.conn, remoteAddr),
+			newSendConn(s.conn, remoteAddr, s.supportsDF),
 			s.sessionHandler,
 			origDestConnID,
 			retrySrcConnID,
@@ -514,7 +616,15 @@ func (s *baseServer) createNewSession(
 	}); !added {
 		return nil
 	}
-	go sess.run()
+	s.mutex.Lock()
+	s.sessions[sess] = struct{}{}
+	s.mutex.Unlock()
+	go func() {
+		sess.run()
+		s.mutex.Lock()
+		delete(s.sessions, sess)
+		s.mutex.Unlock()
+	}()
 	go s.handleNewSession(sess)
 	return sess
 }
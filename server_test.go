@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"reflect"
 	"runtime/pprof"
@@ -157,6 +158,15 @@ var _ = Describe("Server", func() {
 		Expect(ln.Close()).To(Succeed())
 	})
 
+	It("logs using the Logger from the Config", func() {
+		logger := &recordingLogger{}
+		ln, err := Listen(conn, tlsConf, &Config{Logger: logger})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(logger.Messages()).To(ContainElement("DEBUG server Listening for udp connections on " + (&net.UDPAddr{}).String()))
+		// stop the listener
+		Expect(ln.Close()).To(Succeed())
+	})
+
 	It("listens on a given address", func() {
 		addr := "127.0.0.1:13579"
 		ln, err := ListenAddr(addr, tlsConf, &Config{})
@@ -841,6 +851,70 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 
+			It("rate limits connection attempts, without allocating state for rate-limited attempts", func() {
+				serv.config.AcceptToken = func(_ net.Addr, _ *Token) bool { return true }
+				serv.connAttemptLimiter = newConnectionAttemptLimiter(1, 3)
+				serv.connAttemptLimiter.rate = 0 // make sure the bucket isn't refilled while the test is running
+
+				var sessionsCreated int32
+				serv.newSession = func(
+					_ sendConn,
+					runner sessionRunner,
+					_ protocol.ConnectionID,
+					_ *protocol.ConnectionID,
+					_ protocol.ConnectionID,
+					_ protocol.ConnectionID,
+					_ protocol.ConnectionID,
+					_ protocol.StatelessResetToken,
+					_ *Config,
+					_ *tls.Config,
+					_ *handshake.TokenGenerator,
+					_ bool,
+					_ logging.ConnectionTracer,
+					_ utils.Logger,
+					_ protocol.VersionNumber,
+				) quicSession {
+					atomic.AddInt32(&sessionsCreated, 1)
+					sess := NewMockQuicSession(mockCtrl)
+					sess.EXPECT().handlePacket(gomock.Any())
+					sess.EXPECT().run()
+					sess.EXPECT().Context().Return(context.Background())
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+					sess.EXPECT().HandshakeComplete().Return(ctx)
+					return sess
+				}
+
+				phm.EXPECT().AddWithConnID(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(func(_, _ protocol.ConnectionID, fn func() packetHandler) bool {
+					phm.EXPECT().GetStatelessResetToken(gomock.Any())
+					fn()
+					return true
+				}).Times(3)
+				tracer.EXPECT().TracerForConnection(protocol.PerspectiveServer, gomock.Any()).Times(3)
+				// the next 3 attempts are sent a Retry
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_ net.Addr, replyHdr *logging.Header, _ logging.ByteCount, _ []logging.Frame) {
+					Expect(replyHdr.Type).To(Equal(protocol.PacketTypeRetry))
+				}).Times(3)
+				var retries int32
+				conn.EXPECT().WriteTo(gomock.Any(), gomock.Any()).DoAndReturn(func(b []byte, _ net.Addr) (int, error) {
+					Expect(parseHeader(b).Type).To(Equal(protocol.PacketTypeRetry))
+					atomic.AddInt32(&retries, 1)
+					return len(b), nil
+				}).Times(3)
+				// all other attempts are dropped
+				var dropped int32
+				tracer.EXPECT().DroppedPacket(gomock.Any(), logging.PacketTypeInitial, gomock.Any(), logging.PacketDropDOSPrevention).Do(func(net.Addr, logging.PacketType, protocol.ByteCount, logging.PacketDropReason) {
+					atomic.AddInt32(&dropped, 1)
+				}).Times(94)
+
+				for i := 0; i < 100; i++ {
+					serv.handlePacket(getInitialWithRandomDestConnID())
+				}
+				Eventually(func() int32 { return atomic.LoadInt32(&dropped) }).Should(BeEquivalentTo(94))
+				Eventually(func() int32 { return atomic.LoadInt32(&retries) }).Should(BeEquivalentTo(3))
+				Expect(atomic.LoadInt32(&sessionsCreated)).To(BeEquivalentTo(3))
+			})
+
 			It("doesn't accept new sessions if they were closed in the mean time", func() {
 				serv.config.AcceptToken = func(_ net.Addr, _ *Token) bool { return true }
 
@@ -992,6 +1066,90 @@ var _ = Describe("Server", func() {
 				Eventually(done).Should(BeClosed())
 			})
 		})
+
+		Context("closing gracefully", func() {
+			It("stops accepting sessions", func() {
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					_, err := serv.Accept(context.Background())
+					Expect(err).To(MatchError("server closed"))
+					close(done)
+				}()
+
+				phm.EXPECT().CloseServer()
+				Expect(serv.CloseGracefully(context.Background())).To(Succeed())
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("drains sessions, and waits for them to close", func() {
+				sessCtx, sessCancel := context.WithCancel(context.Background())
+				sess := NewMockQuicSession(mockCtrl)
+				sess.EXPECT().Context().Return(sessCtx).AnyTimes()
+				drained := make(chan struct{})
+				sess.EXPECT().drain().Do(func() { close(drained) })
+				serv.sessions[sess] = struct{}{}
+
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					Expect(serv.CloseGracefully(context.Background())).To(Succeed())
+					close(done)
+				}()
+				Eventually(drained).Should(BeClosed())
+				Consistently(done).ShouldNot(BeClosed())
+				phm.EXPECT().CloseServer()
+				sessCancel()
+				Eventually(done).Should(BeClosed())
+			})
+
+			It("closes the remaining sessions when the context expires", func() {
+				sess := NewMockQuicSession(mockCtrl)
+				sess.EXPECT().Context().Return(context.Background()).AnyTimes()
+				sess.EXPECT().drain()
+				serv.sessions[sess] = struct{}{}
+
+				ctx, cancel := context.WithTimeout(context.Background(), scaleDuration(50*time.Millisecond))
+				defer cancel()
+				phm.EXPECT().CloseServer()
+				Expect(serv.CloseGracefully(ctx)).To(MatchError(context.DeadlineExceeded))
+			})
+
+			It("rejects new connection attempts while shutting down", func() {
+				sess := NewMockQuicSession(mockCtrl)
+				sess.EXPECT().Context().Return(context.Background()).AnyTimes()
+				sess.EXPECT().drain()
+				serv.sessions[sess] = struct{}{}
+				ctx, cancel := context.WithCancel(context.Background())
+				done := make(chan struct{})
+				go func() {
+					defer GinkgoRecover()
+					Expect(serv.CloseGracefully(ctx)).To(MatchError(context.Canceled))
+					close(done)
+				}()
+				Eventually(serv.isShuttingDown).Should(BeTrue())
+
+				p := getInitialWithRandomDestConnID()
+				hdr, _, _, err := wire.ParsePacket(p.data, 0)
+				Expect(err).ToNot(HaveOccurred())
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				written := make(chan struct{})
+				conn.EXPECT().WriteTo(gomock.Any(), p.remoteAddr).DoAndReturn(func(b []byte, _ net.Addr) (int, error) {
+					defer close(written)
+					rejectHdr := parseHeader(b)
+					Expect(rejectHdr.Type).To(Equal(protocol.PacketTypeInitial))
+					Expect(rejectHdr.DestConnectionID).To(Equal(hdr.SrcConnectionID))
+					Expect(rejectHdr.SrcConnectionID).To(Equal(hdr.DestConnectionID))
+					return len(b), nil
+				})
+				serv.handlePacket(p)
+				Eventually(written).Should(BeClosed())
+
+				phm.EXPECT().CloseServer()
+				cancel()
+				Eventually(done).Should(BeClosed())
+			})
+		})
 	})
 
 	Context("server accepting sessions that haven't completed the handshake", func() {
@@ -1249,3 +1407,24 @@ var _ = Describe("default source address verification", func() {
 		Expect(defaultAcceptToken(remoteAddr, token)).To(BeTrue())
 	})
 })
+
+type recordingLogger struct {
+	mutex    sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) log(level, format string, args ...interface{}) {
+	l.mutex.Lock()
+	l.messages = append(l.messages, level+" "+fmt.Sprintf(format, args...))
+	l.mutex.Unlock()
+}
+
+func (l *recordingLogger) Debugf(format string, args ...interface{}) { l.log("DEBUG", format, args...) }
+func (l *recordingLogger) Infof(format string, args ...interface{})  { l.log("INFO", format, args...) }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) { l.log("ERROR", format, args...) }
+
+func (l *recordingLogger) Messages() []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return append([]string(nil), l.messages...)
+}
@@ -41,9 +41,18 @@ type streamManager interface {
 	OpenUniStreamSync(context.Context) (SendStream, error)
 	AcceptStream(context.Context) (Stream, error)
 	AcceptUniStream(context.Context) (ReceiveStream, error)
+	CanOpenStreams(protocol.StreamType, int) bool
+	CloseStreams(match func(protocol.StreamID) bool, closeSend func(sendStreamI), closeReceive func(receiveStreamI))
 	DeleteStream(protocol.StreamID) error
 	UpdateLimits(*wire.TransportParameters)
 	HandleMaxStreamsFrame(*wire.MaxStreamsFrame) error
+	IdleStreams(now time.Time, idleTimeout time.Duration) []streamI
+	MaxConcurrentStreams() int
+	NextStreamID(protocol.StreamType) protocol.StreamID
+	NumberOfStreams() int
+	OpenStreamIDs() []protocol.StreamID
+	FlowControlWindows() (send, receive protocol.ByteCount)
+	StopGrantingStreams()
 	CloseWithError(error)
 }
 
@@ -55,6 +64,8 @@ type cryptoStreamHandler interface {
 	GetSessionTicket() ([]byte, error)
 	io.Closer
 	ConnectionState() handshake.ConnectionState
+	DidHelloRetryRequest() bool
+	PeerTransportParameters() []byte
 }
 
 type receivedPacket struct {
@@ -87,6 +98,9 @@ type sessionRunner interface {
 	ReplaceWithClosed(protocol.ConnectionID, packetHandler)
 	AddResetToken(protocol.StatelessResetToken, packetHandler)
 	RemoveResetToken(protocol.StatelessResetToken)
+	// handlePacket is used for packets received on a path that's not handled by the runner,
+	// e.g. when migrating to a new local address.
+	handlePacket(*receivedPacket)
 }
 
 type handshakeRunner struct {
@@ -128,7 +142,7 @@ type session struct {
 	handshakeDestConnID protocol.ConnectionID
 	// Set for the client. Destination connection ID used on the first Initial sent.
 	origDestConnID protocol.ConnectionID
-	retrySrcConnID *protocol.ConnectionID // only set for the client (and if a Retry was performed)
+	retrySrcConnID *protocol.ConnectionID // only set if a Retry was performed
 
 	srcConnIDLen int
 
@@ -140,6 +154,7 @@ type session struct {
 	sendQueue *sendQueue
 
 	streamsMap      streamManager
+	sendBuffer      *sendBufferWatermark // nil if Config.SendBufferHighWatermark is not set
 	connIDManager   *connIDManager
 	connIDGenerator *connIDGenerator
 
@@ -170,7 +185,9 @@ type session struct {
 	closeChan chan closeError
 
 	ctx                context.Context
-	ctxCancel          context.CancelFunc
+	ctxCancel          func(cause error)
+	closeErr           error // set before ctx is canceled
+	closeCause         error // the cause of the cancellation of ctx, a *SessionCloseError
 	handshakeCtx       context.Context
 	handshakeCtxCancel context.CancelFunc
 
@@ -192,6 +209,14 @@ type session struct {
 	lastPacketReceivedTime time.Time
 	// ... and the time we sent a new ack-eliciting packet after receiving a packet.
 	firstAckElicitingPacketAfterIdleSentTime time.Time
+	// The send stall timeout is started when we send an ack-eliciting packet,
+	// and reset when the peer acknowledges a new packet.
+	sendStallStartTime time.Time
+	largestAcked1RTT   protocol.PacketNumber
+	// unproductivePackets is the number of 1-RTT packets that didn't make any progress,
+	// received since unproductivePacketsIntervalStart, or since the last packet that made progress.
+	unproductivePackets              uint64
+	unproductivePacketsIntervalStart time.Time
 	// pacingDeadline is the time when the next packet should be sent
 	pacingDeadline time.Time
 
@@ -203,8 +228,41 @@ type session struct {
 	keepAlivePingSent bool
 	keepAliveInterval time.Duration
 
+	// reducedPacketSize is set when the packet size was reduced after repeated PTOs.
+	reducedPacketSize bool
+	// mtuDiscoverer is nil until the handshake is confirmed, and if path MTU discovery is disabled.
+	mtuDiscoverer mtuDiscoverer
+	// see ProbeMTU
+	mtuProbeChan chan *mtuProbeRequest
+	mtuProbes    []*mtuProbeRequest // the probes requested by the application that haven't been sent yet
+
 	datagramQueue *datagramQueue
 
+	// statistics returned by Stats, updated from the run loop
+	statsMutex sync.Mutex
+	stats      SessionStats
+
+	events chan Event
+	// used to detect key updates
+	largestRcvd1RTTPacket protocol.PacketNumber
+	rcvdKeyPhase          protocol.KeyPhaseBit
+	// the stream limits announced by the peer
+	peerMaxBidiStreamNum protocol.StreamNum
+	peerMaxUniStreamNum  protocol.StreamNum
+
+	// only used by the server, see drain
+	drainChan chan struct{}
+	draining  bool
+
+	// only used by the client, see MigrateTo
+	migrationChan chan *pathMigration
+	migration     *pathMigration // the migration that is currently in progress
+	migratedConn  net.PacketConn // the packet conn of the last successful migration
+	runner        sessionRunner  // handles the packets received on the packet conn of a new path
+	// only used by the server, the peer addresses that packets from new paths were received from, oldest first
+	unvalidatedPaths []*unvalidatedPath
+	probingPaths     []PathInfo // the paths being probed, as reported in the SessionStats, guarded by the statsMutex
+
 	logID  string
 	tracer logging.ConnectionTracer
 	logger utils.Logger
@@ -238,6 +296,7 @@ var newSession = func(
 		config:                conf,
 		handshakeDestConnID:   destConnID,
 		srcConnIDLen:          srcConnID.Len(),
+		retrySrcConnID:        retrySrcConnID,
 		tokenGenerator:        tokenGenerator,
 		oneRTTStream:          newCryptoStream(),
 		perspective:           protocol.PerspectiveServer,
@@ -247,8 +306,10 @@ var newSession = func(
 		version:               v,
 	}
 	if origDestConnID != nil {
+		s.origDestConnID = origDestConnID
 		s.logID = origDestConnID.String()
 	} else {
+		s.origDestConnID = clientDestConnID
 		s.logID = destConnID.String()
 	}
 	s.connIDManager = newConnIDManager(
@@ -276,26 +337,30 @@ var newSession = func(
 		s.tracer,
 		s.logger,
 		s.version,
+		s.config.NewCongestionController,
 	)
 	initialStream := newCryptoStream()
 	handshakeStream := newCryptoStream()
 	params := &wire.TransportParameters{
-		InitialMaxStreamDataBidiLocal:   protocol.InitialMaxStreamData,
-		InitialMaxStreamDataBidiRemote:  protocol.InitialMaxStreamData,
-		InitialMaxStreamDataUni:         protocol.InitialMaxStreamData,
-		InitialMaxData:                  protocol.InitialMaxData,
+		InitialMaxStreamDataBidiLocal:   initialBidiStreamReceiveWindow(s.config, true),
+		InitialMaxStreamDataBidiRemote:  initialBidiStreamReceiveWindow(s.config, false),
+		InitialMaxStreamDataUni:         initialUniStreamReceiveWindow(s.config),
+		InitialMaxData:                  initialConnectionReceiveWindow(s.config),
 		MaxIdleTimeout:                  s.config.MaxIdleTimeout,
 		MaxBidiStreamNum:                protocol.StreamNum(s.config.MaxIncomingStreams),
 		MaxUniStreamNum:                 protocol.StreamNum(s.config.MaxIncomingUniStreams),
 		MaxAckDelay:                     protocol.MaxAckDelayInclGranularity,
 		AckDelayExponent:                protocol.AckDelayExponent,
-		DisableActiveMigration:          true,
 		StatelessResetToken:             &statelessResetToken,
 		OriginalDestinationConnectionID: origDestConnID,
 		ActiveConnectionIDLimit:         protocol.MaxActiveConnectionIDs,
 		InitialSourceConnectionID:       srcConnID,
 		RetrySourceConnectionID:         retrySrcConnID,
 	}
+	// Without tracking any other paths, we can't validate the new address of a migrating client.
+	if !s.config.EnableActiveMigration || s.config.MaxPaths < 2 {
+		params.DisableActiveMigration = true
+	}
 	if s.config.EnableDatagrams {
 		params.MaxDatagramFrameSize = protocol.MaxDatagramFrameSize
 	}
@@ -320,6 +385,7 @@ var newSession = func(
 		},
 		tlsConf,
 		enable0RTT,
+		s.config.DisableKeyUpdate,
 		s.rttStats,
 		tracer,
 		logger,
@@ -334,6 +400,7 @@ var newSession = func(
 		s.sentPacketHandler,
 		s.retransmissionQueue,
 		s.RemoteAddr(),
+		protocol.ByteCount(s.config.InitialPacketSize),
 		cs,
 		s.framer,
 		s.receivedPacketHandler,
@@ -369,6 +436,7 @@ var newClientSession = func(
 		srcConnIDLen:          srcConnID.Len(),
 		perspective:           protocol.PerspectiveClient,
 		handshakeCompleteChan: make(chan struct{}),
+		runner:                runner,
 		logID:                 destConnID.String(),
 		logger:                logger,
 		tracer:                tracer,
@@ -400,14 +468,15 @@ var newClientSession = func(
 		s.tracer,
 		s.logger,
 		s.version,
+		s.config.NewCongestionController,
 	)
 	initialStream := newCryptoStream()
 	handshakeStream := newCryptoStream()
 	params := &wire.TransportParameters{
-		InitialMaxStreamDataBidiRemote: protocol.InitialMaxStreamData,
-		InitialMaxStreamDataBidiLocal:  protocol.InitialMaxStreamData,
-		InitialMaxStreamDataUni:        protocol.InitialMaxStreamData,
-		InitialMaxData:                 protocol.InitialMaxData,
+		InitialMaxStreamDataBidiRemote: initialBidiStreamReceiveWindow(s.config, false),
+		InitialMaxStreamDataBidiLocal:  initialBidiStreamReceiveWindow(s.config, true),
+		InitialMaxStreamDataUni:        initialUniStreamReceiveWindow(s.config),
+		InitialMaxData:                 initialConnectionReceiveWindow(s.config),
 		MaxIdleTimeout:                 s.config.MaxIdleTimeout,
 		MaxBidiStreamNum:               protocol.StreamNum(s.config.MaxIncomingStreams),
 		MaxUniStreamNum:                protocol.StreamNum(s.config.MaxIncomingUniStreams),
@@ -438,6 +507,7 @@ var newClientSession = func(
 		},
 		tlsConf,
 		enable0RTT,
+		s.config.DisableKeyUpdate,
 		s.rttStats,
 		tracer,
 		logger,
@@ -455,6 +525,7 @@ var newClientSession = func(
 		s.sentPacketHandler,
 		s.retransmissionQueue,
 		s.RemoteAddr(),
+		protocol.ByteCount(s.config.InitialPacketSize),
 		cs,
 		s.framer,
 		s.receivedPacketHandler,
@@ -480,32 +551,48 @@ func (s *session) preSetup() {
 	s.retransmissionQueue = newRetransmissionQueue(s.version)
 	s.frameParser = wire.NewFrameParser(s.config.EnableDatagrams, s.version)
 	s.rttStats = &utils.RTTStats{}
+	initialConnReceiveWindow := initialConnectionReceiveWindow(s.config)
 	s.connFlowController = flowcontrol.NewConnectionFlowController(
-		protocol.InitialMaxData,
-		protocol.ByteCount(s.config.MaxReceiveConnectionFlowControlWindow),
+		initialConnReceiveWindow,
+		// auto-tuning must never shrink the window below its initial value
+		utils.MaxByteCount(protocol.ByteCount(s.config.MaxReceiveConnectionFlowControlWindow), initialConnReceiveWindow),
 		s.onHasConnectionWindowUpdate,
 		s.rttStats,
 		s.logger,
 	)
 	s.earlySessionReadyChan = make(chan struct{})
+	if s.config.SendBufferHighWatermark > 0 {
+		s.sendBuffer = newSendBufferWatermark(
+			protocol.ByteCount(s.config.SendBufferHighWatermark),
+			protocol.ByteCount(s.config.SendBufferLowWatermark),
+		)
+	}
 	s.streamsMap = newStreamsMap(
 		s,
 		s.newFlowController,
 		uint64(s.config.MaxIncomingStreams),
 		uint64(s.config.MaxIncomingUniStreams),
+		s.onAllStreamsClosed,
+		s.sendBuffer,
 		s.perspective,
 		s.version,
 	)
-	s.framer = newFramer(s.streamsMap, s.version)
+	s.framer = newFramer(s.streamsMap, s.config.EnableFairStreamScheduling, s.version)
 	s.receivedPackets = make(chan *receivedPacket, protocol.MaxSessionUnprocessedPackets)
 	s.closeChan = make(chan closeError, 1)
 	s.sendingScheduled = make(chan struct{}, 1)
-	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
+	s.ctx, s.ctxCancel = newCancelCauseContext()
 	s.handshakeCtx, s.handshakeCtxCancel = context.WithCancel(context.Background())
 
 	now := time.Now()
 	s.lastPacketReceivedTime = now
 	s.sessionCreationTime = now
+	s.largestAcked1RTT = protocol.InvalidPacketNumber
+	s.largestRcvd1RTTPacket = protocol.InvalidPacketNumber
+	s.events = make(chan Event, protocol.MaxSessionEventQueueLen)
+	s.migrationChan = make(chan *pathMigration)
+	s.mtuProbeChan = make(chan *mtuProbeRequest)
+	s.drainChan = make(chan struct{})
 
 	s.windowUpdateQueue = newWindowUpdateQueue(s.streamsMap, s.connFlowController, s.framer.QueueControlFrame)
 	if s.config.EnableDatagrams {
@@ -515,7 +602,7 @@ func (s *session) preSetup() {
 
 // run the session main loop
 func (s *session) run() error {
-	defer s.ctxCancel()
+	defer func() { s.ctxCancel(s.closeCause) }()
 
 	s.timer = utils.NewTimer()
 
@@ -580,6 +667,13 @@ runLoop:
 			}
 		case <-s.handshakeCompleteChan:
 			s.handleHandshakeComplete()
+		case m := <-s.migrationChan:
+			s.startMigration(m)
+		case p := <-s.mtuProbeChan:
+			s.startMTUProbe(p)
+		case <-s.drainChan:
+			s.draining = true
+			s.streamsMap.StopGrantingStreams()
 		}
 
 		now := time.Now()
@@ -589,6 +683,7 @@ runLoop:
 			if err := s.sentPacketHandler.OnLossDetectionTimeout(); err != nil {
 				s.closeLocal(err)
 			}
+			s.maybeReducePacketSize()
 		}
 
 		if keepAliveTime := s.nextKeepAliveTime(); !keepAliveTime.IsZero() && !now.Before(keepAliveTime) {
@@ -614,9 +709,33 @@ runLoop:
 			}
 		}
 
+		if s.migration != nil {
+			s.maybeProbePath(now)
+		}
+
+		if deadline := s.sendStallDeadline(); !deadline.IsZero() && !now.Before(deadline) {
+			if s.tracer != nil {
+				s.tracer.ClosedConnection(logging.NewTimeoutCloseReason(logging.TimeoutReasonSendStall))
+			}
+			s.closeLocal(ErrSendStallTimeout)
+			continue
+		}
+
+		if s.exceededMaxConnectionBytes() {
+			s.closeLocal(qerr.NewApplicationError(qerr.ErrorCode(s.config.MaxConnectionBytesErrorCode), "connection byte limit exceeded"))
+			continue
+		}
+
+		if s.draining && s.streamsMap.NumberOfStreams() == 0 {
+			s.logger.Debugf("All streams completed. Closing the draining session.")
+			s.closeLocal(nil)
+			continue
+		}
+
 		if err := s.sendPackets(); err != nil {
 			s.closeLocal(err)
 		}
+		s.maybeQueueSendBufferEvent()
 	}
 
 	s.handleCloseError(closeErr)
@@ -626,10 +745,24 @@ runLoop:
 	s.logger.Infof("Connection %s closed.", s.logID)
 	s.cryptoStreamHandler.Close()
 	s.sendQueue.Close()
+	if s.migration != nil {
+		s.migration.conn.Close()
+	}
+	if s.migratedConn != nil {
+		s.migratedConn.Close()
+	}
 	s.timer.Stop()
+	close(s.events)
 	return closeErr.err
 }
 
+func (s *session) exceededMaxConnectionBytes() bool {
+	if s.config.MaxConnectionBytes == 0 {
+		return false
+	}
+	return uint64(s.connFlowController.BytesTransferred()) > s.config.MaxConnectionBytes
+}
+
 // blocks until the early session can be used
 func (s *session) earlySessionReady() <-chan struct{} {
 	return s.earlySessionReadyChan
@@ -648,9 +781,69 @@ func (s *session) supportsDatagrams() bool {
 }
 
 func (s *session) ConnectionState() ConnectionState {
-	return ConnectionState{
-		TLS:               s.cryptoStreamHandler.ConnectionState(),
-		SupportsDatagrams: s.supportsDatagrams(),
+	state := ConnectionState{
+		TLS:                             s.cryptoStreamHandler.ConnectionState(),
+		SupportsDatagrams:               s.supportsDatagrams(),
+		FlowControl:                     s.flowControlState(),
+		CreationTime:                    s.sessionCreationTime,
+		OriginalDestinationConnectionID: s.origDestConnID,
+		CongestionControl:               s.sentPacketHandler.CongestionControl(),
+		HandshakeRoundTrips:             s.handshakeRoundTrips(),
+		PeerActiveConnectionIDLimit:     s.peerParams.ActiveConnectionIDLimit,
+	}
+	if s.datagramQueue != nil {
+		state.DroppedDatagrams = s.datagramQueue.NumDropped()
+	}
+	if s.config.EnableRawTransportParameters {
+		state.RawPeerTransportParameters = s.cryptoStreamHandler.PeerTransportParameters()
+	}
+	return state
+}
+
+func (s *session) packetLossState() PacketLossState {
+	lossCounts := func(encLevel protocol.EncryptionLevel) PacketLossCounts {
+		lost, retransmitted := s.sentPacketHandler.LossCounts(encLevel)
+		return PacketLossCounts{LostPackets: lost, RetransmittedPackets: retransmitted}
+	}
+	return PacketLossState{
+		Initial:   lossCounts(protocol.EncryptionInitial),
+		Handshake: lossCounts(protocol.EncryptionHandshake),
+		OneRTT:    lossCounts(protocol.Encryption1RTT),
+	}
+}
+
+// handshakeRoundTrips returns the number of round trips the handshake took.
+// Every Retry, Version Negotiation and HelloRetryRequest costs an additional round trip.
+func (s *session) handshakeRoundTrips() int {
+	roundTrips := 1
+	if s.retrySrcConnID != nil {
+		roundTrips++
+	}
+	if s.versionNegotiated {
+		roundTrips++
+	}
+	if s.cryptoStreamHandler.DidHelloRetryRequest() {
+		roundTrips++
+	}
+	return roundTrips
+}
+
+func (s *session) largestAckedState() LargestAckedState {
+	return LargestAckedState{
+		Initial:   s.sentPacketHandler.LargestAcked(protocol.EncryptionInitial),
+		Handshake: s.sentPacketHandler.LargestAcked(protocol.EncryptionHandshake),
+		OneRTT:    s.sentPacketHandler.LargestAcked(protocol.Encryption1RTT),
+	}
+}
+
+func (s *session) flowControlState() FlowControlState {
+	sendWindow, receiveWindow := s.connFlowController.Windows()
+	streamSendWindows, streamReceiveWindows := s.streamsMap.FlowControlWindows()
+	return FlowControlState{
+		SendWindow:           uint64(sendWindow),
+		ReceiveWindow:        uint64(receiveWindow),
+		StreamSendWindows:    uint64(streamSendWindows),
+		StreamReceiveWindows: uint64(streamReceiveWindows),
 	}
 }
 
@@ -663,6 +856,16 @@ func (s *session) nextKeepAliveTime() time.Time {
 	return s.lastPacketReceivedTime.Add(s.keepAliveInterval / 2)
 }
 
+// Time when the connection is considered stalled.
+// It returns a zero time if the send stall timeout is disabled,
+// or if no ack-eliciting packet is awaiting acknowledgement.
+func (s *session) sendStallDeadline() time.Time {
+	if s.config.SendStallTimeout == 0 || !s.handshakeComplete || s.sendStallStartTime.IsZero() {
+		return time.Time{}
+	}
+	return s.sendStallStartTime.Add(s.config.SendStallTimeout)
+}
+
 func (s *session) maybeResetTimer() {
 	var deadline time.Time
 	if !s.handshakeComplete {
@@ -675,6 +878,9 @@ func (s *session) maybeResetTimer() {
 		}
 	}
 
+	if stallDeadline := s.sendStallDeadline(); !stallDeadline.IsZero() {
+		deadline = utils.MinTime(deadline, stallDeadline)
+	}
 	if ackAlarm := s.receivedPacketHandler.GetAlarmTimeout(); !ackAlarm.IsZero() {
 		deadline = utils.MinTime(deadline, ackAlarm)
 	}
@@ -684,6 +890,14 @@ func (s *session) maybeResetTimer() {
 	if !s.pacingDeadline.IsZero() {
 		deadline = utils.MinTime(deadline, s.pacingDeadline)
 	}
+	if s.migration != nil {
+		deadline = utils.MinTime(deadline, utils.MinTime(s.migration.nextProbe, s.migration.deadline))
+	}
+	if s.mtuDiscoverer != nil {
+		if probeTime := s.mtuDiscoverer.NextProbeTime(); !probeTime.IsZero() {
+			deadline = utils.MinTime(deadline, probeTime)
+		}
+	}
 
 	s.timer.Reset(deadline)
 }
@@ -702,10 +916,10 @@ func (s *session) handleHandshakeComplete() {
 
 	s.connIDManager.SetHandshakeComplete()
 	s.connIDGenerator.SetHandshakeComplete()
+	s.queueEvent(Event{Type: EventHandshakeComplete})
 
 	if s.perspective == protocol.PerspectiveServer {
-		s.handshakeConfirmed = true
-		s.sentPacketHandler.SetHandshakeConfirmed()
+		s.handleHandshakeConfirmed()
 		ticket, err := s.cryptoStreamHandler.GetSessionTicket()
 		if err != nil {
 			s.closeLocal(err)
@@ -735,9 +949,11 @@ func (s *session) handlePacketImpl(rp *receivedPacket) bool {
 	var counter uint8
 	var lastConnID protocol.ConnectionID
 	var processed bool
+	var numProcessed int
 	data := rp.data
+	size := protocol.ByteCount(len(data))
 	p := rp
-	s.sentPacketHandler.ReceivedBytes(protocol.ByteCount(len(data)))
+	s.sentPacketHandler.ReceivedBytes(size)
 	for len(data) > 0 {
 		if counter > 0 {
 			p = p.Clone()
@@ -786,10 +1002,12 @@ func (s *session) handlePacketImpl(rp *receivedPacket) bool {
 		p.data = packetData
 		if wasProcessed := s.handleSinglePacket(p, hdr); wasProcessed {
 			processed = true
+			numProcessed++
 		}
 		data = rest
 	}
 	p.buffer.MaybeRelease()
+	s.countReceivedPackets(numProcessed, size)
 	return processed
 }
 
@@ -875,7 +1093,7 @@ func (s *session) handleSinglePacket(p *receivedPacket, hdr *wire.Header) bool /
 		return false
 	}
 
-	if err := s.handleUnpackedPacket(packet, p.ecn, p.rcvTime, p.Size()); err != nil {
+	if err := s.handleUnpackedPacket(packet, p.ecn, p.rcvTime, p.remoteAddr, p.Size()); err != nil {
 		s.closeLocal(err)
 		return false
 	}
@@ -998,7 +1216,8 @@ func (s *session) handleUnpackedPacket(
 	packet *unpackedPacket,
 	ecn protocol.ECN,
 	rcvTime time.Time,
-	packetSize protocol.ByteCount, // only for logging
+	remoteAddr net.Addr,
+	packetSize protocol.ByteCount, // for logging, and for the amplification limit on unvalidated paths
 ) error {
 	if len(packet.data) == 0 {
 		return qerr.NewError(qerr.ProtocolViolation, "empty packet")
@@ -1038,12 +1257,40 @@ func (s *session) handleUnpackedPacket(
 	s.lastPacketReceivedTime = rcvTime
 	s.firstAckElicitingPacketAfterIdleSentTime = time.Time{}
 	s.keepAlivePingSent = false
+	// The server follows the client when it migrates to a new address.
+	fromNewPath := s.perspective == protocol.PerspectiveServer &&
+		packet.encryptionLevel == protocol.Encryption1RTT &&
+		remoteAddr != nil && !equalAddr(remoteAddr, s.conn.RemoteAddr(), false)
+	var path *unvalidatedPath
+	if fromNewPath {
+		path = s.getUnvalidatedPath(remoteAddr)
+		if path != nil {
+			path.bytesReceived += packetSize
+		}
+	}
+	isLargest := packet.packetNumber > s.largestRcvd1RTTPacket
+	if packet.encryptionLevel == protocol.Encryption1RTT {
+		s.detectKeyUpdate(packet)
+	}
+
+	handleFrame := func(frame wire.Frame) error {
+		// A PATH_CHALLENGE has to be answered on the path it was received on.
+		if challenge, ok := frame.(*wire.PathChallengeFrame); ok && fromNewPath {
+			if path != nil {
+				s.sendPathResponse(challenge, path)
+			}
+			return nil
+		}
+		return s.handleFrame(frame, packet.encryptionLevel, packet.hdr.DestConnectionID, remoteAddr)
+	}
 
 	// Only used for tracing.
 	// If we're not tracing, this slice will always remain empty.
 	var frames []wire.Frame
 	r := bytes.NewReader(packet.data)
-	var isAckEliciting bool
+	var isAckEliciting, isProductive bool
+	isProbing := true
+	largestAcked := s.largestAcked1RTT
 	for {
 		frame, err := s.frameParser.ParseNext(r, packet.encryptionLevel)
 		if err != nil {
@@ -1055,10 +1302,16 @@ func (s *session) handleUnpackedPacket(
 		if ackhandler.IsFrameAckEliciting(frame) {
 			isAckEliciting = true
 		}
+		if !isProbingFrame(frame) {
+			isProbing = false
+		}
+		if !isUnproductiveFrame(frame) {
+			isProductive = true
+		}
 		// Only process frames now if we're not logging.
 		// If we're logging, we need to make sure that the packet_received event is logged first.
 		if s.tracer == nil {
-			if err := s.handleFrame(frame, packet.encryptionLevel, packet.hdr.DestConnectionID); err != nil {
+			if err := handleFrame(frame); err != nil {
 				return err
 			}
 		} else {
@@ -1073,16 +1326,46 @@ func (s *session) handleUnpackedPacket(
 		}
 		s.tracer.ReceivedPacket(packet.hdr, packetSize, fs)
 		for _, frame := range frames {
-			if err := s.handleFrame(frame, packet.encryptionLevel, packet.hdr.DestConnectionID); err != nil {
+			if err := handleFrame(frame); err != nil {
 				return err
 			}
 		}
 	}
 
+	if packet.encryptionLevel == protocol.Encryption1RTT {
+		if isProductive || s.largestAcked1RTT > largestAcked {
+			s.unproductivePackets = 0
+		} else {
+			if rcvTime.Sub(s.unproductivePacketsIntervalStart) >= protocol.UnproductivePacketsInterval {
+				s.unproductivePacketsIntervalStart = rcvTime
+				s.unproductivePackets = 0
+			}
+			s.unproductivePackets++
+			if s.config.MaxUnproductivePackets > 0 && s.unproductivePackets > s.config.MaxUnproductivePackets {
+				return qerr.NewApplicationError(qerr.ErrorCode(s.config.MaxUnproductivePacketsErrorCode), "too many packets without progress")
+			}
+		}
+	}
+
+	if s.perspective == protocol.PerspectiveServer && packet.encryptionLevel == protocol.Encryption1RTT && isLargest && !isProbing {
+		s.handleNonProbingPacket(path, rcvTime)
+	}
+
 	return s.receivedPacketHandler.ReceivedPacket(packet.packetNumber, ecn, packet.encryptionLevel, rcvTime, isAckEliciting)
 }
 
-func (s *session) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel, destConnID protocol.ConnectionID) error {
+// isUnproductiveFrame says if a frame doesn't make any progress on the connection on its own.
+// ACK frames only make progress if they acknowledge new packets.
+func isUnproductiveFrame(f wire.Frame) bool {
+	switch f.(type) {
+	case *wire.AckFrame, *wire.PingFrame:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *session) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel, destConnID protocol.ConnectionID, remoteAddr net.Addr) error {
 	var err error
 	wire.LogFrame(s.logger, f, false)
 	switch frame := f.(type) {
@@ -1111,8 +1394,7 @@ func (s *session) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel, d
 	case *wire.PathChallengeFrame:
 		s.handlePathChallengeFrame(frame)
 	case *wire.PathResponseFrame:
-		// since we don't send PATH_CHALLENGEs, we don't expect PATH_RESPONSEs
-		err = errors.New("unexpected PATH_RESPONSE frame")
+		s.handlePathResponseFrame(frame)
 	case *wire.NewTokenFrame:
 		err = s.handleNewTokenFrame(frame)
 	case *wire.NewConnectionIDFrame:
@@ -1122,7 +1404,7 @@ func (s *session) handleFrame(f wire.Frame, encLevel protocol.EncryptionLevel, d
 	case *wire.HandshakeDoneFrame:
 		err = s.handleHandshakeDoneFrame()
 	case *wire.DatagramFrame:
-		err = s.handleDatagramFrame(frame)
+		err = s.handleDatagramFrame(frame, remoteAddr)
 	default:
 		err = fmt.Errorf("unexpected frame type: %s", reflect.ValueOf(&frame).Elem().Type().Name())
 	}
@@ -1194,7 +1476,23 @@ func (s *session) handleMaxStreamDataFrame(frame *wire.MaxStreamDataFrame) error
 }
 
 func (s *session) handleMaxStreamsFrame(frame *wire.MaxStreamsFrame) error {
-	return s.streamsMap.HandleMaxStreamsFrame(frame)
+	if err := s.streamsMap.HandleMaxStreamsFrame(frame); err != nil {
+		return err
+	}
+	uni := frame.Type == protocol.StreamTypeUni
+	maxStreamNum := &s.peerMaxBidiStreamNum
+	if uni {
+		maxStreamNum = &s.peerMaxUniStreamNum
+	}
+	if frame.MaxStreamNum > *maxStreamNum {
+		*maxStreamNum = frame.MaxStreamNum
+		s.queueEvent(Event{
+			Type:           EventPeerStreamLimitChanged,
+			Unidirectional: uni,
+			MaxStreams:     uint64(frame.MaxStreamNum),
+		})
+	}
+	return nil
 }
 
 func (s *session) handleResetStreamFrame(frame *wire.ResetStreamFrame) error {
@@ -1236,6 +1534,29 @@ func (s *session) handleNewTokenFrame(frame *wire.NewTokenFrame) error {
 	return nil
 }
 
+func (s *session) handlePathResponseFrame(frame *wire.PathResponseFrame) {
+	if s.perspective == protocol.PerspectiveServer {
+		s.handlePeerPathResponse(frame)
+		return
+	}
+	// PATH_RESPONSE frames that don't belong to a path validation in progress are ignored.
+	if s.migration == nil || frame.Data != s.migration.challenge {
+		return
+	}
+	m := s.migration
+	s.migration = nil
+	s.updateProbingPaths()
+	s.logger.Infof("Path validation succeeded. Migrating to %s.", m.conn.LocalAddr())
+	s.conn.SetPacketConn(m.conn, m.supportsDF)
+	if s.migratedConn != nil {
+		s.migratedConn.Close()
+	}
+	s.migratedConn = m.conn
+	s.sentPacketHandler.MigratedPath()
+	s.queueEvent(Event{Type: EventPathMigrated, LocalAddr: m.conn.LocalAddr(), RemoteAddr: s.conn.RemoteAddr()})
+	m.result <- nil
+}
+
 func (s *session) handleNewConnectionIDFrame(f *wire.NewConnectionIDFrame) error {
 	return s.connIDManager.Add(f)
 }
@@ -1248,12 +1569,20 @@ func (s *session) handleHandshakeDoneFrame() error {
 	if s.perspective == protocol.PerspectiveServer {
 		return qerr.NewError(qerr.ProtocolViolation, "received a HANDSHAKE_DONE frame")
 	}
-	s.handshakeConfirmed = true
-	s.sentPacketHandler.SetHandshakeConfirmed()
 	s.cryptoStreamHandler.SetHandshakeConfirmed()
+	s.handleHandshakeConfirmed()
 	return nil
 }
 
+func (s *session) handleHandshakeConfirmed() {
+	s.handshakeConfirmed = true
+	s.sentPacketHandler.SetHandshakeConfirmed()
+	s.statsMutex.Lock()
+	s.stats.HandshakeDuration = time.Since(s.sessionCreationTime)
+	s.statsMutex.Unlock()
+	s.startMTUDiscovery()
+}
+
 func (s *session) handleAckFrame(frame *wire.AckFrame, encLevel protocol.EncryptionLevel) error {
 	if err := s.sentPacketHandler.ReceivedAck(frame, encLevel, s.lastPacketReceivedTime); err != nil {
 		return err
@@ -1261,14 +1590,19 @@ func (s *session) handleAckFrame(frame *wire.AckFrame, encLevel protocol.Encrypt
 	if encLevel != protocol.Encryption1RTT {
 		return nil
 	}
+	if largestAcked := frame.LargestAcked(); largestAcked > s.largestAcked1RTT {
+		s.largestAcked1RTT = largestAcked
+		s.sendStallStartTime = time.Time{}
+	}
 	return s.cryptoStreamHandler.SetLargest1RTTAcked(frame.LargestAcked())
 }
 
-func (s *session) handleDatagramFrame(f *wire.DatagramFrame) error {
+func (s *session) handleDatagramFrame(f *wire.DatagramFrame, remoteAddr net.Addr) error {
 	if f.Length(s.version) > protocol.MaxDatagramFrameSize {
 		return qerr.NewError(qerr.ProtocolViolation, "DATAGRAM frame too large")
 	}
-	s.datagramQueue.HandleDatagramFrame(f)
+	s.datagramQueue.HandleDatagramFrame(f, remoteAddr)
+	s.queueEvent(Event{Type: EventDatagramReceived})
 	return nil
 }
 
@@ -1308,6 +1642,16 @@ func (s *session) closeRemote(e error) {
 	})
 }
 
+// drain is called when the server shuts down gracefully.
+// The peer isn't allowed to open any new streams,
+// and the session is closed as soon as all open streams have completed.
+func (s *session) drain() {
+	select {
+	case s.drainChan <- struct{}{}:
+	case <-s.ctx.Done():
+	}
+}
+
 // Close the connection. It sends a NO_ERROR application error.
 // It waits until the run loop has stopped before returning
 func (s *session) shutdown() {
@@ -1332,6 +1676,13 @@ func (s *session) handleCloseError(closeErr closeError) {
 		quicErr = qerr.ToQuicError(closeErr.err)
 	}
 
+	s.closeErr = quicErr
+	s.closeCause = &SessionCloseError{
+		Remote:             closeErr.remote,
+		IsApplicationError: quicErr.IsApplicationError(),
+		ErrorCode:          uint64(quicErr.ErrorCode),
+		Err:                closeErr.err,
+	}
 	s.streamsMap.CloseWithError(quicErr)
 	s.connIDManager.Close()
 	if s.datagramQueue != nil {
@@ -1430,6 +1781,8 @@ func (s *session) processTransportParametersImpl(params *wire.TransportParameter
 	s.idleTimeout = utils.MinNonZeroDuration(s.config.MaxIdleTimeout, params.MaxIdleTimeout)
 	s.keepAliveInterval = utils.MinDuration(s.idleTimeout/2, protocol.MaxKeepAliveInterval)
 	s.streamsMap.UpdateLimits(params)
+	s.peerMaxBidiStreamNum = params.MaxBidiStreamNum
+	s.peerMaxUniStreamNum = params.MaxUniStreamNum
 	s.packer.HandleTransportParameters(params)
 	s.frameParser.SetAckDelayExponent(params.AckDelayExponent)
 	s.connFlowController.UpdateSendWindow(params.InitialMaxData)
@@ -1483,7 +1836,7 @@ func (s *session) sendPackets() error {
 				return err
 			}
 		case ackhandler.SendAny:
-			if s.handshakeComplete && !s.sentPacketHandler.HasPacingBudget() {
+			if s.handshakeComplete && !s.config.DisablePacing && !s.sentPacketHandler.HasPacingBudget() {
 				s.pacingDeadline = s.sentPacketHandler.TimeUntilSend()
 				return nil
 			}
@@ -1499,7 +1852,16 @@ func (s *session) sendPackets() error {
 }
 
 func (s *session) maybeSendAckOnlyPacket() error {
-	packet, err := s.packer.MaybePackAckPacket(s.handshakeConfirmed)
+	if !s.handshakeConfirmed {
+		packet, err := s.packer.PackCoalescedPacket(true)
+		if err != nil || packet == nil {
+			return err
+		}
+		s.sendPackedCoalescedPacket(packet, time.Now())
+		return nil
+	}
+
+	packet, err := s.packer.MaybePackAckPacket()
 	if err != nil {
 		return err
 	}
@@ -1559,20 +1921,29 @@ func (s *session) sendPacket() (bool, error) {
 	s.windowUpdateQueue.QueueAll()
 
 	if !s.handshakeConfirmed {
-		now := time.Now()
-		packet, err := s.packer.PackCoalescedPacket()
+		packet, err := s.packer.PackCoalescedPacket(false)
 		if err != nil || packet == nil {
 			return false, err
 		}
-		s.logCoalescedPacket(packet)
-		for _, p := range packet.packets {
-			if s.firstAckElicitingPacketAfterIdleSentTime.IsZero() && p.IsAckEliciting() {
-				s.firstAckElicitingPacketAfterIdleSentTime = now
-			}
-			s.sentPacketHandler.SentPacket(p.ToAckHandlerPacket(now, s.retransmissionQueue))
+		s.sendPackedCoalescedPacket(packet, time.Now())
+		return true, nil
+	}
+	if len(s.mtuProbes) > 0 {
+		p := s.mtuProbes[0]
+		s.mtuProbes = s.mtuProbes[1:]
+		packet, err := s.packer.PackMTUProbePacket(s.getMTUProbePing(p), p.size)
+		if err != nil {
+			return false, err
 		}
-		s.connIDManager.SentPacket()
-		s.sendQueue.Send(packet.buffer)
+		s.sendPackedPacket(packet)
+		return true, nil
+	}
+	if s.mtuDiscoverer != nil && s.mtuDiscoverer.ShouldSendProbe(time.Now()) {
+		packet, err := s.packer.PackMTUProbePacket(s.mtuDiscoverer.GetPing())
+		if err != nil {
+			return false, err
+		}
+		s.sendPackedPacket(packet)
 		return true, nil
 	}
 	packet, err := s.packer.PackPacket()
@@ -1583,17 +1954,62 @@ func (s *session) sendPacket() (bool, error) {
 	return true, nil
 }
 
+func (s *session) sendPackedCoalescedPacket(packet *coalescedPacket, now time.Time) {
+	s.logCoalescedPacket(packet)
+	for _, p := range packet.packets {
+		if s.firstAckElicitingPacketAfterIdleSentTime.IsZero() && p.IsAckEliciting() {
+			s.firstAckElicitingPacketAfterIdleSentTime = now
+		}
+		s.maybeStartSendStallTimer(p, now)
+		s.sentPacketHandler.SentPacket(p.ToAckHandlerPacket(now, s.retransmissionQueue))
+	}
+	s.connIDManager.SentPacket()
+	s.countSentPackets(len(packet.packets), packet.buffer.Len())
+	s.sendQueue.Send(packet.buffer)
+}
+
 func (s *session) sendPackedPacket(packet *packedPacket) {
 	now := time.Now()
 	if s.firstAckElicitingPacketAfterIdleSentTime.IsZero() && packet.IsAckEliciting() {
 		s.firstAckElicitingPacketAfterIdleSentTime = now
 	}
+	s.maybeStartSendStallTimer(packet.packetContents, now)
 	s.logPacket(packet)
 	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket(now, s.retransmissionQueue))
 	s.connIDManager.SentPacket()
+	s.countSentPackets(1, packet.buffer.Len())
 	s.sendQueue.Send(packet.buffer)
 }
 
+// maybeStartSendStallTimer starts the send stall timer when an ack-eliciting packet is sent, unless it's already running.
+// Path MTU probe packets might be too large for the path, so the peer not acknowledging them doesn't mean it's dead.
+// For the same reason, packets sent on paths that are being validated (see sendPathProbe) don't start the timer.
+func (s *session) maybeStartSendStallTimer(p *packetContents, now time.Time) {
+	if s.sendStallStartTime.IsZero() && p.IsAckEliciting() && !p.isMTUProbePacket {
+		s.sendStallStartTime = now
+	}
+}
+
+// countSentPackets updates the statistics after sending a UDP datagram containing numPackets QUIC packets.
+func (s *session) countSentPackets(numPackets int, size protocol.ByteCount) {
+	s.statsMutex.Lock()
+	s.stats.PacketsSent += uint64(numPackets)
+	s.stats.BytesSent += uint64(size)
+	s.statsMutex.Unlock()
+}
+
+// countReceivedPackets updates the statistics after receiving a UDP datagram,
+// of which numPackets QUIC packets could be processed.
+// Since RTT samples are only taken when receiving packets, it also updates the RTT.
+func (s *session) countReceivedPackets(numPackets int, size protocol.ByteCount) {
+	s.statsMutex.Lock()
+	s.stats.PacketsReceived += uint64(numPackets)
+	s.stats.BytesReceived += uint64(size)
+	s.stats.SmoothedRTT = s.rttStats.SmoothedRTT()
+	s.stats.LatestRTT = s.rttStats.LatestRTT()
+	s.statsMutex.Unlock()
+}
+
 func (s *session) sendConnectionClose(quicErr *qerr.QuicError) ([]byte, error) {
 	packet, err := s.packer.PackConnectionClose(quicErr)
 	if err != nil {
@@ -1657,35 +2073,120 @@ func (s *session) AcceptUniStream(ctx context.Context) (ReceiveStream, error) {
 
 // OpenStream opens a stream
 func (s *session) OpenStream() (Stream, error) {
-	return s.streamsMap.OpenStream()
+	str, err := s.streamsMap.OpenStream()
+	return str, s.handleOpenStreamError(err)
 }
 
 func (s *session) OpenStreamSync(ctx context.Context) (Stream, error) {
-	return s.streamsMap.OpenStreamSync(ctx)
+	streamCtx, cancel := s.streamLimitContext(ctx)
+	defer cancel()
+	str, err := s.streamsMap.OpenStreamSync(streamCtx)
+	return str, s.handleOpenStreamError(convertStreamLimitTimeout(ctx, err))
 }
 
 func (s *session) OpenUniStream() (SendStream, error) {
-	return s.streamsMap.OpenUniStream()
+	str, err := s.streamsMap.OpenUniStream()
+	return str, s.handleOpenStreamError(err)
 }
 
 func (s *session) OpenUniStreamSync(ctx context.Context) (SendStream, error) {
-	return s.streamsMap.OpenUniStreamSync(ctx)
+	streamCtx, cancel := s.streamLimitContext(ctx)
+	defer cancel()
+	str, err := s.streamsMap.OpenUniStreamSync(streamCtx)
+	return str, s.handleOpenStreamError(convertStreamLimitTimeout(ctx, err))
+}
+
+func (s *session) NextStreamID() StreamID {
+	return s.streamsMap.NextStreamID(protocol.StreamTypeBidi)
+}
+
+func (s *session) NextUniStreamID() StreamID {
+	return s.streamsMap.NextStreamID(protocol.StreamTypeUni)
+}
+
+func (s *session) OpenStreamIDs() []StreamID {
+	return s.streamsMap.OpenStreamIDs()
+}
+
+func (s *session) IdleStreams(idleTimeout time.Duration) []Stream {
+	idle := s.streamsMap.IdleStreams(time.Now(), idleTimeout)
+	strs := make([]Stream, 0, len(idle))
+	for _, str := range idle {
+		strs = append(strs, str)
+	}
+	return strs
+}
+
+func (s *session) ResetStreams(match func(StreamID) bool, code ErrorCode) {
+	s.streamsMap.CloseStreams(
+		match,
+		func(str sendStreamI) { str.CancelWrite(code) },
+		func(str receiveStreamI) { str.CancelRead(code) },
+	)
+}
+
+func (s *session) CanOpenStreams(num int) bool {
+	return s.streamsMap.CanOpenStreams(protocol.StreamTypeBidi, num)
+}
+
+func (s *session) CanOpenUniStreams(num int) bool {
+	return s.streamsMap.CanOpenStreams(protocol.StreamTypeUni, num)
+}
+
+// streamLimitContext returns a context that is canceled after the stream limit timeout.
+func (s *session) streamLimitContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.config.StreamLimitTimeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, s.config.StreamLimitTimeout)
+}
+
+// convertStreamLimitTimeout converts the expiry of the stream limit timeout into an ErrStreamLimitNotIncreased.
+// Errors caused by the context passed by the application are returned unchanged.
+func convertStreamLimitTimeout(ctx context.Context, err error) error {
+	if err == context.DeadlineExceeded && ctx.Err() == nil {
+		return streamOpenErr{ErrStreamLimitNotIncreased}
+	}
+	return err
+}
+
+// handleOpenStreamError closes the session when we ran out of stream IDs.
+// There's no way to open any more streams of that type on this connection.
+func (s *session) handleOpenStreamError(err error) error {
+	if err != errStreamIDsExhausted {
+		return err
+	}
+	qErr := qerr.NewError(qerr.StreamLimitError, err.Error())
+	s.closeLocal(qErr)
+	return qErr
 }
 
 func (s *session) newFlowController(id protocol.StreamID) flowcontrol.StreamFlowController {
 	initialSendWindow := s.peerParams.InitialMaxStreamDataUni
 	if id.Type() == protocol.StreamTypeBidi {
-		if id.InitiatedBy() == s.perspective {
+		if id.IsLocal(s.perspective) {
 			initialSendWindow = s.peerParams.InitialMaxStreamDataBidiRemote
 		} else {
 			initialSendWindow = s.peerParams.InitialMaxStreamDataBidiLocal
 		}
 	}
+	var initialReceiveWindow protocol.ByteCount
+	maxReceiveWindow := protocol.ByteCount(s.config.MaxReceiveStreamFlowControlWindow)
+	if id.Type() == protocol.StreamTypeUni {
+		initialReceiveWindow = initialUniStreamReceiveWindow(s.config)
+		if s.config.MaxReceiveUniStreamFlowControlWindow > 0 {
+			maxReceiveWindow = protocol.ByteCount(s.config.MaxReceiveUniStreamFlowControlWindow)
+		}
+	} else {
+		initialReceiveWindow = initialBidiStreamReceiveWindow(s.config, id.IsLocal(s.perspective))
+	}
+	// auto-tuning must never shrink the window below its initial value
+	maxReceiveWindow = utils.MaxByteCount(maxReceiveWindow, initialReceiveWindow)
 	return flowcontrol.NewStreamFlowController(
 		id,
 		s.connFlowController,
-		protocol.InitialMaxStreamData,
-		protocol.ByteCount(s.config.MaxReceiveStreamFlowControlWindow),
+		initialReceiveWindow,
+		maxReceiveWindow,
 		initialSendWindow,
 		s.onHasStreamWindowUpdate,
 		s.rttStats,
@@ -1693,6 +2194,177 @@ func (s *session) newFlowController(id protocol.StreamID) flowcontrol.StreamFlow
 	)
 }
 
+// initialBidiStreamReceiveWindow returns the initial flow control window for receiving data on bidirectional streams.
+// local says if the streams are opened by us.
+func initialBidiStreamReceiveWindow(conf *Config, local bool) protocol.ByteCount {
+	window := conf.InitialStreamReceiveWindowBidiRemote
+	if local {
+		window = conf.InitialStreamReceiveWindowBidiLocal
+	}
+	if window > 0 {
+		return protocol.ByteCount(window)
+	}
+	return initialStreamReceiveWindow(conf)
+}
+
+// initialUniStreamReceiveWindow returns the initial flow control window for receiving data on unidirectional streams.
+func initialUniStreamReceiveWindow(conf *Config) protocol.ByteCount {
+	if conf.InitialStreamReceiveWindowUni > 0 {
+		return protocol.ByteCount(conf.InitialStreamReceiveWindowUni)
+	}
+	window := initialStreamReceiveWindow(conf)
+	if conf.MaxReceiveUniStreamFlowControlWindow > 0 && protocol.ByteCount(conf.MaxReceiveUniStreamFlowControlWindow) < window {
+		return protocol.ByteCount(conf.MaxReceiveUniStreamFlowControlWindow)
+	}
+	return window
+}
+
+// initialStreamReceiveWindow returns the initial flow control window for receiving data on streams,
+// if no window is configured for the respective type of stream.
+func initialStreamReceiveWindow(conf *Config) protocol.ByteCount {
+	if conf.InitialStreamReceiveWindow > 0 {
+		return protocol.ByteCount(conf.InitialStreamReceiveWindow)
+	}
+	return protocol.InitialMaxStreamData
+}
+
+// initialConnectionReceiveWindow returns the initial connection-level flow control window for receiving data.
+func initialConnectionReceiveWindow(conf *Config) protocol.ByteCount {
+	if conf.InitialConnectionReceiveWindow > 0 {
+		return protocol.ByteCount(conf.InitialConnectionReceiveWindow)
+	}
+	return protocol.InitialMaxData
+}
+
+// maybeReducePacketSize reduces the packet size to the minimum packet size after repeated PTOs.
+// The path might be black-holing packets larger than that.
+func (s *session) maybeReducePacketSize() {
+	if s.reducedPacketSize || s.sentPacketHandler.PTOCount() < protocol.PTOsBeforePacketSizeReduction {
+		return
+	}
+	s.logger.Infof("%d consecutive PTOs. Reducing the packet size to %d bytes.", s.sentPacketHandler.PTOCount(), protocol.MinInitialPacketSize)
+	s.packer.SetMaxPacketSize(protocol.MinInitialPacketSize)
+	s.reducedPacketSize = true
+	// Don't probe for larger packet sizes on a path that might be black-holing them.
+	if s.mtuDiscoverer != nil {
+		s.mtuDiscoverer = nil
+		s.setPathMTU(protocol.MinInitialPacketSize)
+	}
+}
+
+// startMTUDiscovery starts path MTU discovery.
+// It is called when the handshake is confirmed.
+// Probes are bounded by Config.MaxPacketSize and the peer's max_udp_payload_size.
+func (s *session) startMTUDiscovery() {
+	if s.reducedPacketSize {
+		s.setPathMTU(protocol.MinInitialPacketSize)
+		return
+	}
+	start := getMaxPacketSize(s.conn.RemoteAddr())
+	if s.peerParams != nil && s.peerParams.MaxUDPPayloadSize > 0 {
+		start = utils.MinByteCount(start, s.peerParams.MaxUDPPayloadSize)
+	}
+	maxPacketSize := s.maxProbeSize()
+	// Without the DF bit, probe packets would be fragmented, and always appear to succeed.
+	if !s.conn.SupportsDF() {
+		maxPacketSize = start
+	}
+	s.setPathMTU(start)
+	if maxPacketSize <= start {
+		return
+	}
+	s.mtuDiscoverer = newMTUDiscoverer(s.rttStats, start, maxPacketSize, func(size protocol.ByteCount) {
+		if s.reducedPacketSize {
+			return
+		}
+		s.logger.Debugf("Path MTU discovery: increasing the packet size to %d bytes.", size)
+		s.packer.SetMaxPacketSize(size)
+		s.setPathMTU(size)
+	})
+}
+
+// maxProbeSize returns the largest packet size that may be probed.
+// It is bounded by Config.MaxPacketSize and the peer's max_udp_payload_size.
+func (s *session) maxProbeSize() protocol.ByteCount {
+	maxPacketSize := protocol.ByteCount(s.config.MaxPacketSize)
+	if s.peerParams != nil && s.peerParams.MaxUDPPayloadSize > 0 {
+		maxPacketSize = utils.MinByteCount(maxPacketSize, s.peerParams.MaxUDPPayloadSize)
+	}
+	return maxPacketSize
+}
+
+func (s *session) ProbeMTU(target int) (bool, error) {
+	if target < protocol.MinInitialPacketSize {
+		return false, fmt.Errorf("MTU probe size %d is smaller than the minimum packet size (%d bytes)", target, protocol.MinInitialPacketSize)
+	}
+	p := &mtuProbeRequest{
+		size:   protocol.ByteCount(target),
+		result: make(chan error, 1),
+	}
+	select {
+	case s.mtuProbeChan <- p:
+	case <-s.ctx.Done():
+		return false, s.closeErr
+	}
+	select {
+	case err := <-p.result:
+		if err == errMTUProbeLost {
+			return false, nil
+		}
+		return err == nil, err
+	case <-s.ctx.Done():
+		return false, s.closeErr
+	}
+}
+
+func (s *session) startMTUProbe(p *mtuProbeRequest) {
+	switch {
+	case !s.handshakeConfirmed:
+		p.result <- errors.New("cannot probe the path MTU before the handshake is confirmed")
+	case !s.conn.SupportsDF():
+		// Without the DF bit, the probe packet would be fragmented, and always appear to succeed.
+		p.result <- errors.New("cannot probe the path MTU without setting the DF bit")
+	case p.size > s.maxProbeSize():
+		p.result <- fmt.Errorf("MTU probe size %d exceeds the maximum packet size (%d bytes)", p.size, s.maxProbeSize())
+	default:
+		s.mtuProbes = append(s.mtuProbes, p)
+	}
+}
+
+// getMTUProbePing returns the PING frame for an MTU probe requested by the application.
+// An acknowledged probe raises the packet size, a lost probe doesn't reduce it:
+// loss of regular packets is handled by maybeReducePacketSize.
+func (s *session) getMTUProbePing(p *mtuProbeRequest) ackhandler.Frame {
+	return ackhandler.Frame{
+		Frame: &wire.PingFrame{},
+		OnLost: func(wire.Frame) {
+			if s.mtuDiscoverer != nil {
+				s.mtuDiscoverer.OnProbeResult(p.size, false)
+			}
+			p.result <- errMTUProbeLost
+		},
+		OnAcked: func(wire.Frame) {
+			if s.mtuDiscoverer != nil {
+				// This calls the mtuIncreased callback if the size was increased.
+				s.mtuDiscoverer.OnProbeResult(p.size, true)
+			} else if p.size > protocol.ByteCount(s.stats.PathMTU) { // only written on the run loop, no need to lock
+				s.logger.Debugf("MTU probe: increasing the packet size to %d bytes.", p.size)
+				s.packer.SetMaxPacketSize(p.size)
+				s.setPathMTU(p.size)
+				// The probe shows that the path doesn't black-hole packets of this size (any more).
+				s.reducedPacketSize = false
+			}
+			p.result <- nil
+		},
+	}
+}
+
+func (s *session) setPathMTU(size protocol.ByteCount) {
+	s.statsMutex.Lock()
+	s.stats.PathMTU = uint64(size)
+	s.statsMutex.Unlock()
+}
+
 // scheduleSending signals that we have data for sending
 func (s *session) scheduleSending() {
 	select {
@@ -1752,19 +2424,376 @@ func (s *session) onStreamCompleted(id protocol.StreamID) {
 	}
 }
 
+func (s *session) onAllStreamsClosed() {
+	if s.config.CloseOnIdle {
+		s.logger.Debugf("All streams closed. Closing the session.")
+		s.closeLocal(nil)
+	}
+}
+
+func (s *session) Ping(ctx context.Context) error {
+	acked := make(chan struct{})
+	var once sync.Once
+	s.framer.QueuePing(func() { once.Do(func() { close(acked) }) })
+	s.scheduleSending()
+	select {
+	case <-acked:
+		return nil
+	case <-s.ctx.Done():
+		return s.closeErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *session) MigrateTo(addr net.Addr) error {
+	if s.perspective == protocol.PerspectiveServer {
+		return errors.New("only clients can migrate")
+	}
+	conn, err := net.ListenPacket(addr.Network(), addr.String())
+	if err != nil {
+		return err
+	}
+	m, err := newPathMigration(conn, maybeEnableDF(conn, s.config))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	select {
+	case s.migrationChan <- m:
+	case <-s.ctx.Done():
+		conn.Close()
+		return s.closeErr
+	}
+	select {
+	case err := <-m.result:
+		return err
+	case <-s.ctx.Done():
+		return s.closeErr
+	}
+}
+
+func (s *session) startMigration(m *pathMigration) {
+	var err error
+	switch {
+	case !s.handshakeConfirmed:
+		err = errors.New("cannot migrate before the handshake is confirmed")
+	case s.peerParams.DisableActiveMigration:
+		err = errors.New("peer disabled active migration")
+	case s.migration != nil:
+		err = errors.New("migration already in progress")
+	case s.config.MaxPaths < 2:
+		err = errors.New("path limit reached")
+	case !s.connIDManager.SwitchForMigration():
+		err = errors.New("no unused connection ID available for migration")
+	}
+	var conn connection
+	if err == nil {
+		conn, err = wrapConn(m.conn)
+	}
+	if err != nil {
+		m.conn.Close()
+		m.result <- err
+		return
+	}
+	s.logger.Debugf("Validating path from %s to %s.", m.conn.LocalAddr(), s.conn.RemoteAddr())
+	go s.readFromPath(conn)
+	now := time.Now()
+	// Path validation is abandoned after 3 PTOs.
+	// Since the RTT of the new path is not known yet, use at least the PTO calculated from the initial RTT.
+	m.deadline = now.Add(3 * utils.MaxDuration(s.rttStats.PTO(true), (&utils.RTTStats{}).PTO(true)))
+	s.migration = m
+	s.updateProbingPaths()
+	s.sendPathChallenge(now)
+}
+
+// readFromPath reads packets from the packet conn of a new path, until the packet conn is closed.
+// The packets are passed to the runner, such that they're handled the same way as packets received on the original path,
+// e.g. stateless resets are detected.
+func (s *session) readFromPath(conn connection) {
+	for {
+		p, err := conn.ReadPacket()
+		if nerr, ok := err.(net.Error); ok && nerr.Temporary() {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		s.runner.handlePacket(p)
+	}
+}
+
+// maybeProbePath retransmits the PATH_CHALLENGE on the path that is being validated,
+// and abandons the migration if the path couldn't be validated in time.
+func (s *session) maybeProbePath(now time.Time) {
+	if !now.Before(s.migration.deadline) {
+		s.logger.Debugf("Path validation for %s timed out.", s.migration.conn.LocalAddr())
+		s.abandonMigration(errors.New("path validation timed out"))
+		return
+	}
+	if !now.Before(s.migration.nextProbe) {
+		s.sendPathChallenge(now)
+	}
+}
+
+func (s *session) sendPathChallenge(now time.Time) {
+	m := s.migration
+	m.nextProbe = now.Add(s.rttStats.PTO(true))
+	if err := s.sendPathProbe(&wire.PathChallengeFrame{Data: m.challenge}, func(b []byte) error {
+		_, err := m.conn.WriteTo(b, s.conn.RemoteAddr())
+		return err
+	}); err != nil {
+		s.abandonMigration(err)
+	}
+}
+
+func (s *session) abandonMigration(err error) {
+	s.migration.conn.Close()
+	s.migration.result <- err
+	s.migration = nil
+	s.updateProbingPaths()
+}
+
+// sendPathResponse responds to a PATH_CHALLENGE received from a new address, on the path it was received on.
+func (s *session) sendPathResponse(frame *wire.PathChallengeFrame, path *unvalidatedPath) {
+	s.sendOnUnvalidatedPath(&wire.PathResponseFrame{Data: frame.Data}, path)
+}
+
+// sendOnUnvalidatedPath sends a PATH_CHALLENGE or a PATH_RESPONSE frame to a peer address that wasn't validated.
+// The frame is not sent if that would exceed the amplification limit of the path.
+func (s *session) sendOnUnvalidatedPath(f wire.Frame, path *unvalidatedPath) {
+	if path.isAmplificationLimited() {
+		s.logger.Debugf("Not sending %T to %s. Amplification limited: received %d bytes, already sent out %d bytes", f, path.addr, path.bytesReceived, path.bytesSent)
+		return
+	}
+	if err := s.sendPathProbe(f, func(b []byte) error {
+		path.bytesSent += protocol.ByteCount(len(b))
+		return s.conn.WriteTo(b, path.addr)
+	}); err != nil {
+		s.logger.Debugf("Failed to send %T to %s: %s", f, path.addr, err)
+	}
+}
+
+// sendPathProbe sends a packet containing a PATH_CHALLENGE or a PATH_RESPONSE frame on a path other than the current path.
+func (s *session) sendPathProbe(f wire.Frame, write func([]byte) error) error {
+	packet, err := s.packer.PackPathProbePacket(f)
+	if err != nil {
+		return err
+	}
+	s.logPacket(packet)
+	s.sentPacketHandler.SentPacket(packet.ToAckHandlerPacket(time.Now(), s.retransmissionQueue))
+	s.connIDManager.SentPacket()
+	s.countSentPackets(1, packet.buffer.Len())
+	err = write(packet.buffer.Data)
+	packet.buffer.Release()
+	return err
+}
+
+// getUnvalidatedPath returns the unvalidated path for a peer address, and starts tracking it if necessary.
+// When the maximum number of paths is reached, the oldest unvalidated path is dropped.
+// It returns nil if the path limit doesn't allow tracking any unvalidated paths.
+func (s *session) getUnvalidatedPath(addr net.Addr) *unvalidatedPath {
+	for _, p := range s.unvalidatedPaths {
+		if equalAddr(p.addr, addr, false) {
+			return p
+		}
+	}
+	// the path currently in use counts towards the limit
+	maxUnvalidatedPaths := s.config.MaxPaths - 1
+	if maxUnvalidatedPaths <= 0 {
+		return nil
+	}
+	if len(s.unvalidatedPaths) >= maxUnvalidatedPaths {
+		s.logger.Debugf("Path limit reached. Dropping the path to %s.", s.unvalidatedPaths[0].addr)
+		s.unvalidatedPaths = s.unvalidatedPaths[1:]
+	}
+	p := newUnvalidatedPath(addr)
+	s.unvalidatedPaths = append(s.unvalidatedPaths, p)
+	s.updateProbingPaths()
+	return p
+}
+
+// updateProbingPaths updates the paths being probed, as reported in the SessionStats.
+// It must be called from the run loop, whenever a path being probed is added or removed.
+func (s *session) updateProbingPaths() {
+	var paths []PathInfo
+	if s.migration != nil {
+		paths = append(paths, PathInfo{LocalAddr: s.migration.conn.LocalAddr(), RemoteAddr: s.conn.RemoteAddr()})
+	}
+	for _, p := range s.unvalidatedPaths {
+		paths = append(paths, PathInfo{LocalAddr: s.conn.LocalAddr(), RemoteAddr: p.addr, Validated: p.validated})
+	}
+	s.statsMutex.Lock()
+	s.probingPaths = paths
+	s.statsMutex.Unlock()
+}
+
+// handleNonProbingPacket is called by the server when it receives a non-probing packet
+// with the highest packet number received so far.
+// If the packet was received from a new address, path is the (not yet validated) path to that address,
+// otherwise it is nil.
+// The server only switches to a new address after validating it:
+// the source address of the packet might have been spoofed by an attacker.
+func (s *session) handleNonProbingPacket(path *unvalidatedPath, now time.Time) {
+	for _, p := range s.unvalidatedPaths {
+		p.migrate = p == path
+	}
+	if path == nil {
+		return
+	}
+	if path.validated {
+		s.handlePeerMigration(path.addr)
+		return
+	}
+	// Since the peer keeps sending non-probing packets from the new address,
+	// the PATH_CHALLENGE is retransmitted when receiving one of these packets, at most once per PTO.
+	if !path.nextChallenge.IsZero() && now.Before(path.nextChallenge) {
+		return
+	}
+	path.nextChallenge = now.Add(s.rttStats.PTO(true))
+	s.logger.Debugf("Validating path to %s.", path.addr)
+	s.sendOnUnvalidatedPath(&wire.PathChallengeFrame{Data: path.challenge}, path)
+}
+
+// handlePeerPathResponse handles a PATH_RESPONSE frame received by the server.
+// If it validates the address that the peer is migrating to, the server switches to that address.
+func (s *session) handlePeerPathResponse(frame *wire.PathResponseFrame) {
+	for _, p := range s.unvalidatedPaths {
+		// PATH_RESPONSE frames that don't belong to a path validation in progress are ignored.
+		if p.nextChallenge.IsZero() || p.validated || frame.Data != p.challenge {
+			continue
+		}
+		s.logger.Debugf("Path validation for %s succeeded.", p.addr)
+		p.validated = true
+		if p.migrate {
+			s.handlePeerMigration(p.addr)
+		} else {
+			s.updateProbingPaths()
+		}
+		return
+	}
+}
+
+// handlePeerMigration switches to a new peer address, after this address was validated.
+func (s *session) handlePeerMigration(addr net.Addr) {
+	s.logger.Infof("Peer migrated from %s to %s.", s.conn.RemoteAddr(), addr)
+	// NAT rebindings often only change the port number. There's no need to reset congestion state in that case.
+	onlyPortChanged := equalAddr(addr, s.conn.RemoteAddr(), true)
+	s.conn.SetRemoteAddr(addr)
+	for i, p := range s.unvalidatedPaths {
+		if equalAddr(p.addr, addr, false) {
+			s.unvalidatedPaths = append(s.unvalidatedPaths[:i], s.unvalidatedPaths[i+1:]...)
+			break
+		}
+	}
+	s.updateProbingPaths()
+	if !onlyPortChanged {
+		s.sentPacketHandler.MigratedPath()
+	}
+	s.queueEvent(Event{Type: EventPathMigrated, LocalAddr: s.conn.LocalAddr(), RemoteAddr: addr})
+}
+
+func (s *session) PauseSending() {
+	s.framer.SetPaused(true)
+	if s.datagramQueue != nil {
+		s.datagramQueue.SetPaused(true)
+	}
+}
+
+func (s *session) ResumeSending() {
+	s.framer.SetPaused(false)
+	if s.datagramQueue != nil {
+		s.datagramQueue.SetPaused(false)
+	}
+	s.scheduleSending()
+}
+
 func (s *session) SendMessage(p []byte) error {
+	return s.SendMessageWithCallback(p, nil)
+}
+
+func (s *session) SendMessageWithCallback(p []byte, callback func(acked bool)) error {
+	if s.datagramQueue == nil || !s.supportsDatagrams() {
+		return ErrDatagramsNotSupported
+	}
 	f := &wire.DatagramFrame{DataLenPresent: true}
-	if protocol.ByteCount(len(p)) > f.MaxDataLen(s.peerParams.MaxDatagramFrameSize, s.version) {
-		return errors.New("message too large")
+	if maxDataLen := f.MaxDataLen(s.peerParams.MaxDatagramFrameSize, s.version); protocol.ByteCount(len(p)) > maxDataLen {
+		return &DatagramTooLargeError{MaxDataLen: int(maxDataLen)}
 	}
 	f.Data = make([]byte, len(p))
 	copy(f.Data, p)
-	s.datagramQueue.AddAndWait(f)
-	return nil
+	return s.datagramQueue.AddAndWait(f, callback)
 }
 
 func (s *session) ReceiveMessage() ([]byte, error) {
-	return s.datagramQueue.Receive()
+	return s.ReceiveMessageContext(context.Background())
+}
+
+func (s *session) ReceiveMessageContext(ctx context.Context) ([]byte, error) {
+	data, _, err := s.ReceiveMessageFrom(ctx)
+	return data, err
+}
+
+func (s *session) ReceiveMessageFrom(ctx context.Context) ([]byte, net.Addr, error) {
+	if s.datagramQueue == nil {
+		return nil, nil, ErrDatagramsNotSupported
+	}
+	return s.datagramQueue.Receive(ctx)
+}
+
+func (s *session) Stats() SessionStats {
+	s.statsMutex.Lock()
+	stats := s.stats
+	probingPaths := s.probingPaths
+	s.statsMutex.Unlock()
+	stats.Paths = append([]PathInfo{{LocalAddr: s.LocalAddr(), RemoteAddr: s.RemoteAddr(), Validated: true}}, probingPaths...)
+	stats.PacketLoss = s.packetLossState()
+	stats.RetransmittedPackets = stats.PacketLoss.Initial.RetransmittedPackets + stats.PacketLoss.Handshake.RetransmittedPackets + stats.PacketLoss.OneRTT.RetransmittedPackets
+	stats.LargestAcked = s.largestAckedState()
+	stats.CongestionWindow = uint64(s.sentPacketHandler.CongestionWindow())
+	stats.OpenStreams = s.streamsMap.NumberOfStreams()
+	stats.MaxConcurrentStreams = s.streamsMap.MaxConcurrentStreams()
+	return stats
+}
+
+func (s *session) Events() <-chan Event {
+	return s.events
+}
+
+// queueEvent queues an event for the application.
+// It must only be called from the run loop.
+func (s *session) queueEvent(e Event) {
+	select {
+	case s.events <- e:
+	default:
+		s.logger.Debugf("Dropping event of type %d. Event queue full.", e.Type)
+	}
+}
+
+// maybeQueueSendBufferEvent queues an event if the amount of unacknowledged stream data crossed a watermark.
+func (s *session) maybeQueueSendBufferEvent() {
+	crossed, aboveHigh := s.sendBuffer.crossedWatermark()
+	if !crossed {
+		return
+	}
+	if aboveHigh {
+		s.queueEvent(Event{Type: EventSendBufferAboveHighWatermark})
+	} else {
+		s.queueEvent(Event{Type: EventSendBufferBelowLowWatermark})
+	}
+}
+
+// detectKeyUpdate detects key updates by looking at the key phase of received 1-RTT packets.
+// Reordered packets that still use the old keys are ignored.
+func (s *session) detectKeyUpdate(packet *unpackedPacket) {
+	if packet.packetNumber <= s.largestRcvd1RTTPacket {
+		return
+	}
+	if s.largestRcvd1RTTPacket != protocol.InvalidPacketNumber && packet.hdr.KeyPhase != s.rcvdKeyPhase {
+		s.queueEvent(Event{Type: EventKeyUpdated})
+	}
+	s.largestRcvd1RTTPacket = packet.packetNumber
+	s.rcvdKeyPhase = packet.hdr.KeyPhase
 }
 
 func (s *session) LocalAddr() net.Addr {
@@ -0,0 +1,43 @@
+// +build !go1.20
+
+package quic
+
+import (
+	"context"
+	"sync"
+)
+
+// A causeContext records the cause of its cancellation.
+// context.WithCancelCause is only available on Go 1.20 and newer.
+type causeContext struct {
+	context.Context
+
+	once  sync.Once
+	cause error // set before the context is canceled
+}
+
+func newCancelCauseContext() (context.Context, func(error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &causeContext{Context: ctx}
+	return c, func(cause error) {
+		c.once.Do(func() { c.cause = cause })
+		cancel()
+	}
+}
+
+// contextCause returns the cause of the cancellation of a context created by newCancelCauseContext.
+func contextCause(ctx context.Context) error {
+	c, ok := ctx.(*causeContext)
+	if !ok {
+		return ctx.Err()
+	}
+	select {
+	case <-c.Done():
+		if c.cause == nil {
+			return c.Err()
+		}
+		return c.cause
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,14 @@
+// +build go1.20
+
+package quic
+
+import "context"
+
+func newCancelCauseContext() (context.Context, func(error)) {
+	return context.WithCancelCause(context.Background())
+}
+
+// contextCause returns the cause of the cancellation of a context created by newCancelCauseContext.
+func contextCause(ctx context.Context) error {
+	return context.Cause(ctx)
+}
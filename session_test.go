@@ -11,6 +11,7 @@ import (
 	"net"
 	"runtime/pprof"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/ackhandler"
@@ -87,6 +88,7 @@ var _ = Describe("Session", func() {
 		mconn = NewMockSendConn(mockCtrl)
 		mconn.EXPECT().RemoteAddr().Return(remoteAddr).AnyTimes()
 		mconn.EXPECT().LocalAddr().Return(localAddr).AnyTimes()
+		mconn.EXPECT().SupportsDF().AnyTimes()
 		tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
 		Expect(err).ToNot(HaveOccurred())
 		tracer = mocklogging.NewMockConnectionTracer(mockCtrl)
@@ -167,6 +169,21 @@ var _ = Describe("Session", func() {
 				err := sess.handleAckFrame(f, protocol.EncryptionHandshake)
 				Expect(err).ToNot(HaveOccurred())
 			})
+
+			It("resets the send stall timer when new packets are acknowledged", func() {
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().ReceivedAck(gomock.Any(), protocol.Encryption1RTT, gomock.Any()).Times(2)
+				sess.sentPacketHandler = sph
+				cryptoSetup.EXPECT().SetLargest1RTTAcked(gomock.Any()).Times(2)
+				sendTime := time.Now().Add(-time.Second)
+				sess.sendStallStartTime = sendTime
+				Expect(sess.handleAckFrame(&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 3}}}, protocol.Encryption1RTT)).To(Succeed())
+				Expect(sess.sendStallStartTime).To(BeZero())
+				// an ACK that doesn't acknowledge any new packets doesn't count as progress
+				sess.sendStallStartTime = sendTime
+				Expect(sess.handleAckFrame(&wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 2, Largest: 3}}}, protocol.Encryption1RTT)).To(Succeed())
+				Expect(sess.sendStallStartTime).To(Equal(sendTime))
+			})
 		})
 
 		Context("handling RESET_STREAM frames", func() {
@@ -201,7 +218,7 @@ var _ = Describe("Session", func() {
 				Expect(sess.handleFrame(&wire.ResetStreamFrame{
 					StreamID:  3,
 					ErrorCode: 42,
-				}, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+				}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
 			})
 		})
 
@@ -236,7 +253,7 @@ var _ = Describe("Session", func() {
 				Expect(sess.handleFrame(&wire.MaxStreamDataFrame{
 					StreamID:          10,
 					MaximumStreamData: 1337,
-				}, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+				}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
 			})
 		})
 
@@ -257,6 +274,89 @@ var _ = Describe("Session", func() {
 				streamManager.EXPECT().HandleMaxStreamsFrame(f).Return(testErr)
 				err := sess.handleMaxStreamsFrame(f)
 				Expect(err).To(MatchError(testErr))
+				Expect(sess.Events()).ToNot(Receive())
+			})
+
+			It("delivers an event when the peer raises the stream limit", func() {
+				sess.peerMaxUniStreamNum = 10
+				streamManager.EXPECT().HandleMaxStreamsFrame(gomock.Any()).Times(3)
+				Expect(sess.handleMaxStreamsFrame(&wire.MaxStreamsFrame{Type: protocol.StreamTypeUni, MaxStreamNum: 10})).To(Succeed())
+				Expect(sess.Events()).ToNot(Receive())
+				Expect(sess.handleMaxStreamsFrame(&wire.MaxStreamsFrame{Type: protocol.StreamTypeUni, MaxStreamNum: 11})).To(Succeed())
+				Expect(sess.Events()).To(Receive(Equal(Event{
+					Type:           EventPeerStreamLimitChanged,
+					Unidirectional: true,
+					MaxStreams:     11,
+				})))
+				Expect(sess.handleMaxStreamsFrame(&wire.MaxStreamsFrame{Type: protocol.StreamTypeBidi, MaxStreamNum: 5})).To(Succeed())
+				Expect(sess.Events()).To(Receive(Equal(Event{
+					Type:       EventPeerStreamLimitChanged,
+					MaxStreams: 5,
+				})))
+			})
+		})
+
+		Context("handling DATAGRAM frames", func() {
+			It("delivers an event when a datagram is received", func() {
+				sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+				Expect(sess.handleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")}, remoteAddr)).To(Succeed())
+				Expect(sess.Events()).To(Receive(Equal(Event{Type: EventDatagramReceived})))
+				data, err := sess.ReceiveMessage()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+			})
+
+			It("returns from ReceiveMessageContext when the context is canceled", func() {
+				sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+				ctx, cancel := context.WithCancel(context.Background())
+				errChan := make(chan error, 1)
+				go func() {
+					_, err := sess.ReceiveMessageContext(ctx)
+					errChan <- err
+				}()
+				Consistently(errChan).ShouldNot(Receive())
+				cancel()
+				Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+			})
+
+			It("reports the address a datagram was received from", func() {
+				sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				Expect(sess.handleFrame(&wire.DatagramFrame{Data: []byte("foo")}, protocol.Encryption1RTT, protocol.ConnectionID{}, remoteAddr)).To(Succeed())
+				Expect(sess.handleFrame(&wire.DatagramFrame{Data: []byte("bar")}, protocol.Encryption1RTT, protocol.ConnectionID{}, addr)).To(Succeed())
+				data, from, err := sess.ReceiveMessageFrom(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foo")))
+				Expect(from).To(Equal(remoteAddr))
+				data, from, err = sess.ReceiveMessageFrom(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("bar")))
+				Expect(from).To(Equal(addr))
+			})
+
+			It("errors when sending a message if the peer didn't enable datagram support", func() {
+				sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+				sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+				Expect(sess.SendMessage([]byte("foobar"))).To(MatchError(ErrDatagramsNotSupported))
+			})
+
+			It("errors when sending or receiving a message if datagram support is not enabled", func() {
+				sess.datagramQueue = nil
+				sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: 1000}
+				Expect(sess.SendMessage([]byte("foobar"))).To(MatchError(ErrDatagramsNotSupported))
+				_, err := sess.ReceiveMessage()
+				Expect(err).To(MatchError(ErrDatagramsNotSupported))
+				_, err = sess.ReceiveMessageContext(context.Background())
+				Expect(err).To(MatchError(ErrDatagramsNotSupported))
+			})
+
+			It("errors when sending a message that is too large", func() {
+				sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+				sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: 100}
+				maxDataLen := (&wire.DatagramFrame{DataLenPresent: true}).MaxDataLen(100, sess.version)
+				err := sess.SendMessage(make([]byte, maxDataLen+1))
+				Expect(err).To(Equal(&DatagramTooLargeError{MaxDataLen: int(maxDataLen)}))
+				Expect(err.Error()).To(Equal(fmt.Sprintf("message too large (maximum: %d bytes)", maxDataLen)))
 			})
 		})
 
@@ -278,7 +378,7 @@ var _ = Describe("Session", func() {
 				Expect(sess.handleFrame(&wire.StopSendingFrame{
 					StreamID:  3,
 					ErrorCode: 1337,
-				}, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+				}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
 			})
 		})
 
@@ -286,23 +386,49 @@ var _ = Describe("Session", func() {
 			Expect(sess.handleFrame(&wire.NewConnectionIDFrame{
 				SequenceNumber: 10,
 				ConnectionID:   protocol.ConnectionID{1, 2, 3, 4},
-			}, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+			}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
 			Expect(sess.connIDManager.queue.Back().Value.ConnectionID).To(Equal(protocol.ConnectionID{1, 2, 3, 4}))
 		})
 
 		It("handles PING frames", func() {
-			err := sess.handleFrame(&wire.PingFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{})
+			err := sess.handleFrame(&wire.PingFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
-		It("rejects PATH_RESPONSE frames", func() {
-			err := sess.handleFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}, protocol.Encryption1RTT, protocol.ConnectionID{})
-			Expect(err).To(MatchError("unexpected PATH_RESPONSE frame"))
+		It("returns from Ping when the PING frame is acknowledged", func() {
+			errChan := make(chan error, 1)
+			go func() { errChan <- sess.Ping(context.Background()) }()
+			var frames []ackhandler.Frame
+			Eventually(func() []ackhandler.Frame {
+				frames, _ = sess.framer.AppendControlFrames(nil, 1000)
+				return frames
+			}).Should(HaveLen(1))
+			Expect(frames[0].Frame).To(Equal(&wire.PingFrame{}))
+			Consistently(errChan).ShouldNot(Receive())
+			frames[0].OnAcked(frames[0].Frame)
+			Eventually(errChan).Should(Receive(BeNil()))
+		})
+
+		It("returns from Ping when the context is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			errChan := make(chan error, 1)
+			go func() { errChan <- sess.Ping(ctx) }()
+			Consistently(errChan).ShouldNot(Receive())
+			cancel()
+			Eventually(errChan).Should(Receive(MatchError(context.Canceled)))
+		})
+
+		It("ignores PATH_RESPONSE frames that don't belong to a path validation", func() {
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
+		})
+
+		It("doesn't allow servers to migrate", func() {
+			Expect(sess.MigrateTo(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})).To(MatchError("only clients can migrate"))
 		})
 
 		It("handles PATH_CHALLENGE frames", func() {
 			data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
-			err := sess.handleFrame(&wire.PathChallengeFrame{Data: data}, protocol.Encryption1RTT, protocol.ConnectionID{})
+			err := sess.handleFrame(&wire.PathChallengeFrame{Data: data}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)
 			Expect(err).ToNot(HaveOccurred())
 			frames, _ := sess.framer.AppendControlFrames(nil, 1000)
 			Expect(frames).To(Equal([]ackhandler.Frame{{Frame: &wire.PathResponseFrame{Data: data}}}))
@@ -316,17 +442,17 @@ var _ = Describe("Session", func() {
 		})
 
 		It("handles BLOCKED frames", func() {
-			err := sess.handleFrame(&wire.DataBlockedFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{})
+			err := sess.handleFrame(&wire.DataBlockedFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("handles STREAM_BLOCKED frames", func() {
-			err := sess.handleFrame(&wire.StreamDataBlockedFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{})
+			err := sess.handleFrame(&wire.StreamDataBlockedFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("handles STREAMS_BLOCKED frames", func() {
-			err := sess.handleFrame(&wire.StreamsBlockedFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{})
+			err := sess.handleFrame(&wire.StreamsBlockedFrame{}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -358,8 +484,13 @@ var _ = Describe("Session", func() {
 			Expect(sess.handleFrame(&wire.ConnectionCloseFrame{
 				ErrorCode:    qerr.StreamLimitError,
 				ReasonPhrase: "foobar",
-			}, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+			}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
 			Eventually(sess.Context().Done()).Should(BeClosed())
+			Expect(contextCause(sess.Context())).To(Equal(&SessionCloseError{
+				Remote:    true,
+				ErrorCode: uint64(qerr.StreamLimitError),
+				Err:       testErr,
+			}))
 		})
 
 		It("handles CONNECTION_CLOSE frames, with an application error code", func() {
@@ -392,7 +523,7 @@ var _ = Describe("Session", func() {
 				ReasonPhrase:       "foobar",
 				IsApplicationError: true,
 			}
-			Expect(sess.handleFrame(ccf, protocol.Encryption1RTT, protocol.ConnectionID{})).To(Succeed())
+			Expect(sess.handleFrame(ccf, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
 			Eventually(sess.Context().Done()).Should(BeClosed())
 		})
 
@@ -462,6 +593,52 @@ var _ = Describe("Session", func() {
 			Expect(sess.Context().Done()).To(BeClosed())
 		})
 
+		It("closes a draining session once all streams have completed", func() {
+			sess.handshakeComplete = true
+			packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
+			runSession()
+			streamManager.EXPECT().StopGrantingStreams()
+			gomock.InOrder(
+				streamManager.EXPECT().NumberOfStreams().Return(1),
+				streamManager.EXPECT().NumberOfStreams().Return(0),
+			)
+			sess.drain()
+			// there's still an open stream
+			Consistently(areSessionsRunning).Should(BeTrue())
+
+			streamManager.EXPECT().CloseWithError(qerr.NewApplicationError(0, ""))
+			expectReplaceWithClosed()
+			cryptoSetup.EXPECT().Close()
+			buffer := getPacketBuffer()
+			buffer.Data = append(buffer.Data, []byte("connection close")...)
+			packer.EXPECT().PackConnectionClose(gomock.Any()).DoAndReturn(func(quicErr *qerr.QuicError) (*coalescedPacket, error) {
+				Expect(quicErr.ErrorCode).To(BeEquivalentTo(qerr.NoError))
+				return &coalescedPacket{buffer: buffer}, nil
+			})
+			mconn.EXPECT().Write([]byte("connection close"))
+			tracer.EXPECT().ClosedConnection(gomock.Any())
+			tracer.EXPECT().Close()
+			sess.scheduleSending() // the last stream completed
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(sess.Context().Done()).To(BeClosed())
+		})
+
+		It("doesn't set a cause before the session is closed", func() {
+			Expect(contextCause(sess.Context())).To(BeNil())
+			runSession()
+			Consistently(func() error { return contextCause(sess.Context()) }).Should(BeNil())
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			expectReplaceWithClosed()
+			cryptoSetup.EXPECT().Close()
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&coalescedPacket{buffer: getPacketBuffer()}, nil)
+			mconn.EXPECT().Write(gomock.Any())
+			tracer.EXPECT().ClosedConnection(gomock.Any())
+			tracer.EXPECT().Close()
+			sess.shutdown()
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(contextCause(sess.Context())).To(BeAssignableToTypeOf(&SessionCloseError{}))
+		})
+
 		It("only closes once", func() {
 			runSession()
 			streamManager.EXPECT().CloseWithError(gomock.Any())
@@ -501,6 +678,55 @@ var _ = Describe("Session", func() {
 			sess.CloseWithError(0x1337, "test error")
 			Eventually(areSessionsRunning).Should(BeFalse())
 			Expect(sess.Context().Done()).To(BeClosed())
+			Expect(contextCause(sess.Context())).To(Equal(&SessionCloseError{
+				IsApplicationError: true,
+				ErrorCode:          0x1337,
+				Err:                qerr.NewApplicationError(0x1337, "test error"),
+			}))
+		})
+
+		It("closes the session when the connection byte limit is exceeded", func() {
+			sess.config.MaxConnectionBytes = 1000
+			sess.config.MaxConnectionBytesErrorCode = 0x42
+			connFC := mocks.NewMockConnectionFlowController(mockCtrl)
+			connFC.EXPECT().BytesTransferred().Return(protocol.ByteCount(1001)).AnyTimes()
+			sess.connFlowController = connFC
+			runSession()
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			expectReplaceWithClosed()
+			cryptoSetup.EXPECT().Close()
+			packer.EXPECT().PackConnectionClose(gomock.Any()).DoAndReturn(func(quicErr *qerr.QuicError) (*coalescedPacket, error) {
+				Expect(quicErr.IsApplicationError()).To(BeTrue())
+				Expect(quicErr.ErrorCode).To(BeEquivalentTo(0x42))
+				Expect(quicErr.ErrorMessage).To(Equal("connection byte limit exceeded"))
+				return &coalescedPacket{buffer: getPacketBuffer()}, nil
+			})
+			mconn.EXPECT().Write(gomock.Any())
+			gomock.InOrder(
+				tracer.EXPECT().ClosedConnection(gomock.Any()),
+				tracer.EXPECT().Close(),
+			)
+			sess.scheduleSending()
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Expect(sess.Context().Done()).To(BeClosed())
+		})
+
+		It("returns the close error when pinging a closed session", func() {
+			runSession()
+			streamManager.EXPECT().CloseWithError(gomock.Any())
+			expectReplaceWithClosed()
+			cryptoSetup.EXPECT().Close()
+			packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&coalescedPacket{buffer: getPacketBuffer()}, nil)
+			packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
+			mconn.EXPECT().Write(gomock.Any())
+			tracer.EXPECT().ClosedConnection(gomock.Any())
+			tracer.EXPECT().Close()
+			errChan := make(chan error, 1)
+			go func() { errChan <- sess.Ping(context.Background()) }()
+			Consistently(errChan).ShouldNot(Receive())
+			sess.CloseWithError(0x1337, "test error")
+			Eventually(areSessionsRunning).Should(BeFalse())
+			Eventually(errChan).Should(Receive(Equal(qerr.NewApplicationError(0x1337, "test error"))))
 		})
 
 		It("includes the frame type in transport-level close frames", func() {
@@ -801,7 +1027,10 @@ var _ = Describe("Session", func() {
 			packet.rcvTime = rcvTime
 			tracer.EXPECT().StartedConnection(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
 			tracer.EXPECT().ReceivedPacket(hdr, protocol.ByteCount(len(packet.data)), []logging.Frame{&logging.PingFrame{}})
+			size := len(packet.data)
 			Expect(sess.handlePacketImpl(packet)).To(BeTrue())
+			Expect(sess.stats.PacketsReceived).To(BeEquivalentTo(1))
+			Expect(sess.stats.BytesReceived).To(BeEquivalentTo(size))
 		})
 
 		It("drops duplicate packets", func() {
@@ -1028,20 +1257,300 @@ var _ = Describe("Session", func() {
 		})
 
 		Context("updating the remote address", func() {
-			It("doesn't support connection migration", func() {
+			var sph *mockackhandler.MockSentPacketHandler
+
+			BeforeEach(func() {
+				sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sess.sentPacketHandler = sph
+				tracer.EXPECT().StartedConnection(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+			})
+
+			receivePacket := func(pn protocol.PacketNumber, data []byte, addr net.Addr) {
 				unpacker.EXPECT().Unpack(gomock.Any(), gomock.Any(), gomock.Any()).Return(&unpackedPacket{
+					packetNumber:    pn,
 					encryptionLevel: protocol.Encryption1RTT,
-					hdr:             &wire.ExtendedHeader{},
-					data:            []byte{0}, // one PADDING frame
+					hdr:             &wire.ExtendedHeader{PacketNumber: pn},
+					data:            data,
 				}, nil)
 				packet := getPacket(&wire.ExtendedHeader{
 					Header:          wire.Header{DestConnectionID: srcConnID},
+					PacketNumber:    pn,
 					PacketNumberLen: protocol.PacketNumberLen1,
 				}, nil)
-				packet.remoteAddr = &net.IPAddr{IP: net.IPv4(192, 168, 0, 100)}
-				tracer.EXPECT().StartedConnection(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				packet.remoteAddr = addr
+				packet.rcvTime = time.Now()
 				tracer.EXPECT().ReceivedPacket(gomock.Any(), protocol.ByteCount(len(packet.data)), gomock.Any())
 				Expect(sess.handlePacketImpl(packet)).To(BeTrue())
+			}
+
+			expectPathChallenge := func(addr net.Addr, data *[8]byte) {
+				packer.EXPECT().PackPathProbePacket(gomock.Any()).DoAndReturn(func(f wire.Frame) (*packedPacket, error) {
+					Expect(f).To(BeAssignableToTypeOf(&wire.PathChallengeFrame{}))
+					*data = f.(*wire.PathChallengeFrame).Data
+					buffer := getPacketBuffer()
+					buffer.Data = append(buffer.Data, []byte("probe")...)
+					return &packedPacket{
+						buffer: buffer,
+						packetContents: &packetContents{
+							header: &wire.ExtendedHeader{PacketNumber: 10},
+							length: 5, // probe
+						},
+					}, nil
+				})
+				sph.EXPECT().SentPacket(gomock.Any())
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				mconn.EXPECT().WriteTo([]byte("probe"), addr)
+			}
+
+			It("doesn't switch to a new address for packets that only contain PADDING", func() {
+				receivePacket(1, []byte{0}, &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337})
+			})
+
+			It("validates a new address before switching to it", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				var data [8]byte
+				expectPathChallenge(addr, &data)
+				receivePacket(1, []byte{0x1}, addr) // PING frame
+				Expect(sess.unvalidatedPaths).To(HaveLen(1))
+				// a PATH_RESPONSE with the wrong data doesn't validate the path
+				Expect(sess.handleFrame(&wire.PathResponseFrame{Data: [8]byte{1, 2, 3}}, protocol.Encryption1RTT, protocol.ConnectionID{}, addr)).To(Succeed())
+				mconn.EXPECT().SetRemoteAddr(addr)
+				sph.EXPECT().MigratedPath()
+				Expect(sess.handleFrame(&wire.PathResponseFrame{Data: data}, protocol.Encryption1RTT, protocol.ConnectionID{}, addr)).To(Succeed())
+				Expect(sess.unvalidatedPaths).To(BeEmpty())
+				Expect(sess.Events()).To(Receive(Equal(Event{Type: EventPathMigrated, LocalAddr: localAddr, RemoteAddr: addr})))
+			})
+
+			It("retransmits the PATH_CHALLENGE at most once per PTO", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				var data, retransmission [8]byte
+				expectPathChallenge(addr, &data)
+				receivePacket(1, []byte{0x1}, addr) // PING frame
+				receivePacket(2, []byte{0x1}, addr) // PING frame
+				Expect(sess.unvalidatedPaths).To(HaveLen(1))
+				sess.unvalidatedPaths[0].nextChallenge = time.Now().Add(-time.Millisecond)
+				expectPathChallenge(addr, &retransmission)
+				receivePacket(3, []byte{0x1}, addr) // PING frame
+				Expect(retransmission).To(Equal(data))
+			})
+
+			It("doesn't switch to a validated address if the peer moved back to the old address", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				var data [8]byte
+				expectPathChallenge(addr, &data)
+				receivePacket(1, []byte{0x1}, addr)       // PING frame
+				receivePacket(2, []byte{0x1}, remoteAddr) // PING frame
+				Expect(sess.handleFrame(&wire.PathResponseFrame{Data: data}, protocol.Encryption1RTT, protocol.ConnectionID{}, addr)).To(Succeed())
+				sph.EXPECT().LossCounts(gomock.Any()).Times(3)
+				sph.EXPECT().CongestionWindow()
+				sph.EXPECT().LargestAcked(gomock.Any()).Times(3)
+				streamManager.EXPECT().NumberOfStreams()
+				streamManager.EXPECT().MaxConcurrentStreams()
+				Expect(sess.Stats().Paths).To(ContainElement(PathInfo{LocalAddr: localAddr, RemoteAddr: addr, Validated: true}))
+				// the address was already validated, so the server switches immediately
+				mconn.EXPECT().SetRemoteAddr(addr)
+				sph.EXPECT().MigratedPath()
+				receivePacket(3, []byte{0x1}, addr) // PING frame
+			})
+
+			It("doesn't follow the peer to a new address if the path limit doesn't allow validating it", func() {
+				sess.config.MaxPaths = 1
+				// don't EXPECT any calls to PackPathProbePacket or SetRemoteAddr
+				receivePacket(1, []byte{0x1}, &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}) // PING frame
+				Expect(sess.unvalidatedPaths).To(BeEmpty())
+			})
+
+			It("reports the new address for datagrams received during the path change", func() {
+				sess.frameParser = wire.NewFrameParser(true, sess.version)
+				sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				b := &bytes.Buffer{}
+				Expect((&wire.DatagramFrame{Data: []byte("foobar")}).Write(b, sess.version)).To(Succeed())
+				var challenge [8]byte
+				expectPathChallenge(addr, &challenge)
+				receivePacket(1, b.Bytes(), addr)
+				data, from, err := sess.ReceiveMessageFrom(context.Background())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal([]byte("foobar")))
+				Expect(from).To(Equal(addr))
+			})
+
+			It("doesn't reset the congestion state when only the port changed", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4242}
+				var data [8]byte
+				expectPathChallenge(addr, &data)
+				receivePacket(1, []byte{0x1}, addr) // PING frame
+				mconn.EXPECT().SetRemoteAddr(addr)
+				Expect(sess.handleFrame(&wire.PathResponseFrame{Data: data}, protocol.Encryption1RTT, protocol.ConnectionID{}, addr)).To(Succeed())
+			})
+
+			It("doesn't switch to a new address for reordered packets", func() {
+				sess.largestRcvd1RTTPacket = 10
+				receivePacket(9, []byte{0x1}, &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}) // PING frame
+			})
+
+			It("responds to a PATH_CHALLENGE on the path it was received on", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				data := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+				b := &bytes.Buffer{}
+				Expect((&wire.PathChallengeFrame{Data: data}).Write(b, sess.version)).To(Succeed())
+				buffer := getPacketBuffer()
+				buffer.Data = append(buffer.Data, []byte("foobar")...)
+				packer.EXPECT().PackPathProbePacket(&wire.PathResponseFrame{Data: data}).Return(&packedPacket{
+					buffer: buffer,
+					packetContents: &packetContents{
+						header: &wire.ExtendedHeader{PacketNumber: 10},
+						length: 6, // foobar
+					},
+				}, nil)
+				sph.EXPECT().SentPacket(gomock.Any())
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				mconn.EXPECT().WriteTo([]byte("foobar"), addr)
+				receivePacket(1, b.Bytes(), addr)
+				frames, _ := sess.framer.AppendControlFrames(nil, 1000)
+				Expect(frames).To(BeEmpty())
+			})
+
+			It("limits the PATH_RESPONSEs sent to a new address to 3x the data received from it", func() {
+				addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337}
+				b := &bytes.Buffer{}
+				for i := 0; i < 10; i++ {
+					Expect((&wire.PathChallengeFrame{Data: [8]byte{byte(i)}}).Write(b, sess.version)).To(Succeed())
+				}
+				packer.EXPECT().PackPathProbePacket(gomock.Any()).DoAndReturn(func(f wire.Frame) (*packedPacket, error) {
+					buffer := getPacketBuffer()
+					buffer.Data = append(buffer.Data, bytes.Repeat([]byte{'f'}, 1000)...)
+					return &packedPacket{
+						buffer: buffer,
+						packetContents: &packetContents{
+							header: &wire.ExtendedHeader{PacketNumber: 10},
+							length: 1000,
+						},
+					}, nil
+				}).AnyTimes()
+				sph.EXPECT().SentPacket(gomock.Any()).AnyTimes()
+				tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+				var bytesSent protocol.ByteCount
+				var numResponses int
+				mconn.EXPECT().WriteTo(gomock.Any(), addr).Do(func(b []byte, _ net.Addr) {
+					bytesSent += protocol.ByteCount(len(b))
+					numResponses++
+				}).AnyTimes()
+				// flood the session with PATH_CHALLENGE frames
+				for i := 0; i < 100; i++ {
+					receivePacket(protocol.PacketNumber(i), b.Bytes(), addr)
+				}
+				bytesReceived := sess.unvalidatedPaths[0].bytesReceived
+				Expect(numResponses).To(BeNumerically(">", 0))
+				Expect(numResponses).To(BeNumerically("<", 10))
+				// the limit is checked before sending a packet, so it might be exceeded by one packet
+				Expect(bytesSent).To(BeNumerically("<=", 3*bytesReceived+1000))
+				Expect(bytesSent).To(BeNumerically(">", 3*bytesReceived-1000))
+			})
+
+			It("limits the number of paths probed by the peer", func() {
+				sess.config.MaxPaths = 3
+				for i := 0; i < 5; i++ {
+					receivePacket(protocol.PacketNumber(i), []byte{0}, &net.UDPAddr{IP: net.IPv4(192, 168, 0, byte(i)), Port: 1337}) // PADDING
+				}
+				Expect(sess.unvalidatedPaths).To(HaveLen(2))
+				sph.EXPECT().LossCounts(gomock.Any()).Times(3)
+				sph.EXPECT().CongestionWindow()
+				sph.EXPECT().LargestAcked(gomock.Any()).Times(3)
+				streamManager.EXPECT().NumberOfStreams()
+				streamManager.EXPECT().MaxConcurrentStreams()
+				paths := sess.Stats().Paths
+				Expect(paths).To(HaveLen(3))
+				Expect(paths[0]).To(Equal(PathInfo{LocalAddr: localAddr, RemoteAddr: remoteAddr, Validated: true}))
+				// the oldest paths were dropped
+				Expect(paths[1]).To(Equal(PathInfo{LocalAddr: localAddr, RemoteAddr: &net.UDPAddr{IP: net.IPv4(192, 168, 0, 3), Port: 1337}}))
+				Expect(paths[2]).To(Equal(PathInfo{LocalAddr: localAddr, RemoteAddr: &net.UDPAddr{IP: net.IPv4(192, 168, 0, 4), Port: 1337}}))
+			})
+
+			It("doesn't respond to PATH_CHALLENGEs if the path limit doesn't allow probing", func() {
+				sess.config.MaxPaths = 1
+				b := &bytes.Buffer{}
+				Expect((&wire.PathChallengeFrame{Data: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}}).Write(b, sess.version)).To(Succeed())
+				// don't EXPECT any calls to PackPathProbePacket
+				receivePacket(1, b.Bytes(), &net.UDPAddr{IP: net.IPv4(192, 168, 0, 100), Port: 1337})
+				Expect(sess.unvalidatedPaths).To(BeEmpty())
+			})
+		})
+
+		Context("limiting unproductive packets", func() {
+			var (
+				pn      protocol.PacketNumber
+				rcvTime time.Time
+			)
+
+			BeforeEach(func() {
+				pn = 0
+				rcvTime = time.Now()
+				sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+				sph.EXPECT().ReceivedAck(gomock.Any(), protocol.Encryption1RTT, gomock.Any()).AnyTimes()
+				sess.sentPacketHandler = sph
+				cryptoSetup.EXPECT().SetLargest1RTTAcked(gomock.Any()).AnyTimes()
+				tracer.EXPECT().StartedConnection(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+				tracer.EXPECT().ReceivedPacket(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes()
+				sess.config.MaxUnproductivePackets = 10
+				sess.config.MaxUnproductivePacketsErrorCode = 0x42
+			})
+
+			receivePacket := func(frames ...wire.Frame) error {
+				b := &bytes.Buffer{}
+				for _, f := range frames {
+					Expect(f.Write(b, sess.version)).To(Succeed())
+				}
+				pn++
+				return sess.handleUnpackedPacket(&unpackedPacket{
+					packetNumber:    pn,
+					encryptionLevel: protocol.Encryption1RTT,
+					hdr:             &wire.ExtendedHeader{PacketNumber: pn},
+					data:            b.Bytes(),
+				}, protocol.ECNNon, rcvTime, remoteAddr, 100)
+			}
+
+			It("closes the connection when the peer floods it with PING-only packets", func() {
+				for i := 0; i < 10; i++ {
+					Expect(receivePacket(&wire.PingFrame{})).To(Succeed())
+				}
+				Expect(receivePacket(&wire.PingFrame{})).To(MatchError("Application error 0x42: too many packets without progress"))
+			})
+
+			It("resets the count when an ACK acknowledges new packets", func() {
+				for i := 0; i < 10; i++ {
+					Expect(receivePacket(&wire.PingFrame{})).To(Succeed())
+				}
+				Expect(receivePacket(&wire.AckFrame{AckRanges: []wire.AckRange{{Largest: 5}}})).To(Succeed())
+				// ACKs that don't acknowledge new packets don't count as progress
+				for i := 0; i < 10; i++ {
+					Expect(receivePacket(&wire.AckFrame{AckRanges: []wire.AckRange{{Largest: 5}}})).To(Succeed())
+				}
+				Expect(receivePacket(&wire.AckFrame{AckRanges: []wire.AckRange{{Largest: 5}}})).To(MatchError("Application error 0x42: too many packets without progress"))
+			})
+
+			It("doesn't close the connection when the peer sends PING-only packets to keep it alive", func() {
+				for i := 0; i < 100; i++ {
+					rcvTime = rcvTime.Add(protocol.UnproductivePacketsInterval / 5)
+					Expect(receivePacket(&wire.PingFrame{})).To(Succeed())
+				}
+			})
+
+			It("resets the count when receiving other frames", func() {
+				for i := 0; i < 10; i++ {
+					Expect(receivePacket(&wire.PingFrame{})).To(Succeed())
+				}
+				Expect(receivePacket(&wire.PingFrame{}, &wire.MaxDataFrame{MaximumData: 1000})).To(Succeed())
+				Expect(sess.unproductivePackets).To(BeZero())
+				Expect(receivePacket(&wire.PingFrame{})).To(Succeed())
+			})
+
+			It("doesn't limit the number of packets if MaxUnproductivePackets is zero", func() {
+				sess.config.MaxUnproductivePackets = 0
+				for i := 0; i < 100; i++ {
+					Expect(receivePacket(&wire.PingFrame{})).To(Succeed())
+				}
+				Expect(sess.unproductivePackets).To(BeEquivalentTo(100))
 			})
 		})
 
@@ -1220,18 +1729,92 @@ var _ = Describe("Session", func() {
 		})
 
 		It("sends ACK only packets", func() {
+			sess.handshakeConfirmed = true
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 			sph.EXPECT().TimeUntilSend().AnyTimes()
 			sph.EXPECT().GetLossDetectionTimeout().AnyTimes()
 			sph.EXPECT().SendMode().Return(ackhandler.SendAck)
 			done := make(chan struct{})
-			packer.EXPECT().MaybePackAckPacket(false).Do(func(bool) { close(done) })
+			packer.EXPECT().MaybePackAckPacket().Do(func() { close(done) })
 			sess.sentPacketHandler = sph
 			runSession()
 			sess.scheduleSending()
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("coalesces ACK only packets before the handshake is confirmed", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().TimeUntilSend().AnyTimes()
+			sph.EXPECT().GetLossDetectionTimeout().AnyTimes()
+			sph.EXPECT().SendMode().Return(ackhandler.SendAck)
+			buffer := getPacketBuffer()
+			buffer.Data = append(buffer.Data, []byte("foobar")...)
+			packer.EXPECT().PackCoalescedPacket(true).Return(&coalescedPacket{
+				buffer: buffer,
+				packets: []*packetContents{
+					{
+						header: &wire.ExtendedHeader{
+							Header:       wire.Header{IsLongHeader: true, Type: protocol.PacketTypeInitial},
+							PacketNumber: 13,
+						},
+						ack:    &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 10}}},
+						length: 50,
+					},
+					{
+						header: &wire.ExtendedHeader{
+							Header:       wire.Header{IsLongHeader: true, Type: protocol.PacketTypeHandshake},
+							PacketNumber: 37,
+						},
+						ack:    &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 20}}},
+						length: 60,
+					},
+				},
+			}, nil)
+			gomock.InOrder(
+				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
+					Expect(p.EncryptionLevel).To(Equal(protocol.EncryptionInitial))
+					Expect(p.PacketNumber).To(Equal(protocol.PacketNumber(13)))
+				}),
+				sph.EXPECT().SentPacket(gomock.Any()).Do(func(p *ackhandler.Packet) {
+					Expect(p.EncryptionLevel).To(Equal(protocol.EncryptionHandshake))
+					Expect(p.PacketNumber).To(Equal(protocol.PacketNumber(37)))
+				}),
+			)
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+			sent := make(chan struct{})
+			mconn.EXPECT().Write([]byte("foobar")).Do(func([]byte) { close(sent) })
+			sess.sentPacketHandler = sph
+			runSession()
+			sess.scheduleSending()
+			Eventually(sent).Should(BeClosed())
+		})
+
+		It("reduces the packet size after repeated PTOs", func() {
+			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sph.EXPECT().TimeUntilSend().AnyTimes()
+			sph.EXPECT().SendMode().Return(ackhandler.SendNone).AnyTimes()
+			var ptoCount uint32
+			sph.EXPECT().GetLossDetectionTimeout().DoAndReturn(func() time.Time {
+				if atomic.LoadUint32(&ptoCount) < protocol.PTOsBeforePacketSizeReduction+1 {
+					return time.Now().Add(-time.Second)
+				}
+				return time.Now().Add(time.Hour)
+			}).AnyTimes()
+			sph.EXPECT().OnLossDetectionTimeout().Do(func() { atomic.AddUint32(&ptoCount, 1) }).Times(protocol.PTOsBeforePacketSizeReduction + 1)
+			sph.EXPECT().PTOCount().DoAndReturn(func() uint32 { return atomic.LoadUint32(&ptoCount) }).AnyTimes()
+			sess.sentPacketHandler = sph
+			reduced := make(chan struct{})
+			// The packet size is only reduced once.
+			packer.EXPECT().SetMaxPacketSize(protocol.ByteCount(protocol.MinInitialPacketSize)).Do(func(protocol.ByteCount) {
+				Expect(atomic.LoadUint32(&ptoCount)).To(BeEquivalentTo(protocol.PTOsBeforePacketSizeReduction))
+				close(reduced)
+			})
+			runSession()
+			Eventually(reduced).Should(BeClosed())
+			Eventually(func() uint32 { return atomic.LoadUint32(&ptoCount) }).Should(BeEquivalentTo(protocol.PTOsBeforePacketSizeReduction + 1))
+			Expect(sess.reducedPacketSize).To(BeTrue())
+		})
+
 		It("adds a BLOCKED frame when it is connection-level flow control blocked", func() {
 			sess.handshakeConfirmed = true
 			sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
@@ -1382,6 +1965,114 @@ var _ = Describe("Session", func() {
 			time.Sleep(50 * time.Millisecond) // make sure that only 2 packes are sent
 		})
 
+		It("doesn't pace packets if pacing is disabled", func() {
+			sess.config.DisablePacing = true
+			sph.EXPECT().SentPacket(gomock.Any()).Times(2)
+			// don't EXPECT any calls to HasPacingBudget and TimeUntilSend
+			sph.EXPECT().SendMode().Return(ackhandler.SendAny).Times(3)
+			packer.EXPECT().PackPacket().Return(getPacket(10), nil)
+			packer.EXPECT().PackPacket().Return(getPacket(11), nil)
+			packer.EXPECT().PackPacket()
+			mconn.EXPECT().Write(gomock.Any()).Times(2)
+			go func() {
+				defer GinkgoRecover()
+				cryptoSetup.EXPECT().RunHandshake().MaxTimes(1)
+				sess.run()
+			}()
+			sess.scheduleSending()
+			time.Sleep(50 * time.Millisecond) // make sure that only 2 packes are sent
+		})
+
+		It("sends MTU probe packets", func() {
+			sess.mtuDiscoverer = newMTUDiscoverer(&utils.RTTStats{}, 1000, 1500, func(protocol.ByteCount) {})
+			sph.EXPECT().SentPacket(gomock.Any()).Times(2)
+			sph.EXPECT().HasPacingBudget().Return(true).AnyTimes()
+			sph.EXPECT().SendMode().Return(ackhandler.SendAny).Times(3)
+			packer.EXPECT().PackMTUProbePacket(gomock.Any(), protocol.ByteCount(1250)).Return(getPacket(10), nil)
+			packer.EXPECT().PackPacket().Return(getPacket(11), nil)
+			packer.EXPECT().PackPacket()
+			mconn.EXPECT().Write(gomock.Any()).Times(2)
+			go func() {
+				defer GinkgoRecover()
+				cryptoSetup.EXPECT().RunHandshake().MaxTimes(1)
+				sess.run()
+			}()
+			sess.scheduleSending()
+			time.Sleep(50 * time.Millisecond) // make sure that only one probe packet is sent
+		})
+
+		Context("MTU probes requested by the application", func() {
+			var pings chan ackhandler.Frame
+
+			BeforeEach(func() {
+				pings = make(chan ackhandler.Frame, 1)
+				mconn.EXPECT().SupportsDF().Return(true).AnyTimes()
+				sph.EXPECT().HasPacingBudget().Return(true).AnyTimes()
+				sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
+				packer.EXPECT().PackPacket().AnyTimes()
+				go func() {
+					defer GinkgoRecover()
+					cryptoSetup.EXPECT().RunHandshake().MaxTimes(1)
+					sess.run()
+				}()
+			})
+
+			expectProbe := func(size protocol.ByteCount) {
+				sph.EXPECT().SentPacket(gomock.Any()).Do(func(packet *ackhandler.Packet) {
+					Expect(packet.IsPathMTUProbePacket).To(BeTrue())
+				})
+				packer.EXPECT().PackMTUProbePacket(gomock.Any(), size).DoAndReturn(func(ping ackhandler.Frame, _ protocol.ByteCount) (*packedPacket, error) {
+					pings <- ping
+					p := getPacket(10)
+					p.isMTUProbePacket = true
+					return p, nil
+				})
+				mconn.EXPECT().Write(gomock.Any())
+			}
+
+			probeMTU := func(size int) (result chan bool) {
+				result = make(chan bool, 1)
+				go func() {
+					defer GinkgoRecover()
+					ok, err := sess.ProbeMTU(size)
+					Expect(err).ToNot(HaveOccurred())
+					result <- ok
+				}()
+				return result
+			}
+
+			It("increases the packet size when the probe is acknowledged", func() {
+				expectProbe(1400)
+				result := probeMTU(1400)
+				var ping ackhandler.Frame
+				Eventually(pings).Should(Receive(&ping))
+				Consistently(result).ShouldNot(Receive())
+				packer.EXPECT().SetMaxPacketSize(protocol.ByteCount(1400))
+				ping.OnAcked(ping.Frame)
+				Eventually(result).Should(Receive(BeTrue()))
+			})
+
+			It("doesn't change the packet size when the probe is lost", func() {
+				expectProbe(1400)
+				result := probeMTU(1400)
+				var ping ackhandler.Frame
+				Eventually(pings).Should(Receive(&ping))
+				// don't EXPECT any calls to SetMaxPacketSize
+				ping.OnLost(ping.Frame)
+				Eventually(result).Should(Receive(BeFalse()))
+			})
+
+			It("rejects probes larger than the maximum packet size", func() {
+				_, err := sess.ProbeMTU(int(protocol.MaxReceivePacketSize) + 1)
+				Expect(err).To(MatchError(fmt.Sprintf("MTU probe size %d exceeds the maximum packet size (%d bytes)", protocol.MaxReceivePacketSize+1, protocol.MaxReceivePacketSize)))
+			})
+
+			It("rejects probes smaller than the minimum packet size", func() {
+				_, err := sess.ProbeMTU(protocol.MinInitialPacketSize - 1)
+				Expect(err).To(MatchError(fmt.Sprintf("MTU probe size %d is smaller than the minimum packet size (1200 bytes)", protocol.MinInitialPacketSize-1)))
+			})
+		})
+
 		// when becoming congestion limited, at some point the SendMode will change from SendAny to SendAck
 		// we shouldn't send the ACK in the same run
 		It("doesn't send an ACK right after becoming congestion limited", func() {
@@ -1549,7 +2240,7 @@ var _ = Describe("Session", func() {
 		sess.sentPacketHandler = sph
 		buffer := getPacketBuffer()
 		buffer.Data = append(buffer.Data, []byte("foobar")...)
-		packer.EXPECT().PackCoalescedPacket().Return(&coalescedPacket{
+		packer.EXPECT().PackCoalescedPacket(false).Return(&coalescedPacket{
 			buffer: buffer,
 			packets: []*packetContents{
 				{
@@ -1574,7 +2265,7 @@ var _ = Describe("Session", func() {
 				},
 			},
 		}, nil)
-		packer.EXPECT().PackCoalescedPacket().AnyTimes()
+		packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
 
 		sph.EXPECT().GetLossDetectionTimeout().AnyTimes()
 		sph.EXPECT().SendMode().Return(ackhandler.SendAny).AnyTimes()
@@ -1625,7 +2316,7 @@ var _ = Describe("Session", func() {
 	})
 
 	It("cancels the HandshakeComplete context when the handshake completes", func() {
-		packer.EXPECT().PackCoalescedPacket().AnyTimes()
+		packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
 		finishHandshake := make(chan struct{})
 		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 		sess.sentPacketHandler = sph
@@ -1647,6 +2338,7 @@ var _ = Describe("Session", func() {
 		Consistently(handshakeCtx.Done()).ShouldNot(BeClosed())
 		close(finishHandshake)
 		Eventually(handshakeCtx.Done()).Should(BeClosed())
+		Eventually(sess.Events()).Should(Receive(Equal(Event{Type: EventHandshakeComplete})))
 		// make sure the go routine returns
 		streamManager.EXPECT().CloseWithError(gomock.Any())
 		expectReplaceWithClosed()
@@ -1657,11 +2349,38 @@ var _ = Describe("Session", func() {
 		tracer.EXPECT().Close()
 		sess.shutdown()
 		Eventually(sess.Context().Done()).Should(BeClosed())
+		Eventually(sess.Events()).Should(BeClosed())
+	})
+
+	It("detects key updates", func() {
+		packet := func(pn protocol.PacketNumber, kp protocol.KeyPhaseBit) *unpackedPacket {
+			return &unpackedPacket{
+				packetNumber:    pn,
+				hdr:             &wire.ExtendedHeader{KeyPhase: kp},
+				encryptionLevel: protocol.Encryption1RTT,
+			}
+		}
+		sess.detectKeyUpdate(packet(1, protocol.KeyPhaseZero))
+		sess.detectKeyUpdate(packet(2, protocol.KeyPhaseZero))
+		Expect(sess.Events()).ToNot(Receive())
+		sess.detectKeyUpdate(packet(4, protocol.KeyPhaseOne))
+		Expect(sess.Events()).To(Receive(Equal(Event{Type: EventKeyUpdated})))
+		// a reordered packet, sent with the old keys
+		sess.detectKeyUpdate(packet(3, protocol.KeyPhaseZero))
+		sess.detectKeyUpdate(packet(5, protocol.KeyPhaseOne))
+		Expect(sess.Events()).ToNot(Receive())
+	})
+
+	It("drops events if the application doesn't read them", func() {
+		for i := 0; i < protocol.MaxSessionEventQueueLen+1; i++ {
+			sess.queueEvent(Event{Type: EventDatagramReceived})
+		}
+		Expect(sess.Events()).To(HaveLen(protocol.MaxSessionEventQueueLen))
 	})
 
 	It("sends a session ticket when the handshake completes", func() {
 		const size = protocol.MaxPostHandshakeCryptoFrameSize * 3 / 2
-		packer.EXPECT().PackCoalescedPacket().AnyTimes()
+		packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
 		finishHandshake := make(chan struct{})
 		sessionRunner.EXPECT().Retire(clientDestConnID)
 		go func() {
@@ -1705,7 +2424,7 @@ var _ = Describe("Session", func() {
 	})
 
 	It("doesn't cancel the HandshakeComplete context when the handshake fails", func() {
-		packer.EXPECT().PackCoalescedPacket().AnyTimes()
+		packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
 		streamManager.EXPECT().CloseWithError(gomock.Any())
 		expectReplaceWithClosed()
 		packer.EXPECT().PackConnectionClose(gomock.Any()).Return(&coalescedPacket{buffer: getPacketBuffer()}, nil)
@@ -1825,7 +2544,7 @@ var _ = Describe("Session", func() {
 			}
 			streamManager.EXPECT().UpdateLimits(params)
 			packer.EXPECT().HandleTransportParameters(params)
-			packer.EXPECT().PackCoalescedPacket().MaxTimes(3)
+			packer.EXPECT().PackCoalescedPacket(false).MaxTimes(3)
 			Expect(sess.earlySessionReady()).ToNot(BeClosed())
 			sessionRunner.EXPECT().GetStatelessResetToken(gomock.Any()).Times(2)
 			sessionRunner.EXPECT().Add(gomock.Any(), sess).Times(2)
@@ -1833,6 +2552,231 @@ var _ = Describe("Session", func() {
 			sess.processTransportParameters(params)
 			Expect(sess.earlySessionReady()).To(BeClosed())
 		})
+
+		It("disables active migration by default", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			var params *wire.TransportParameters
+			tracer.EXPECT().SentTransportParameters(gomock.Any()).Do(func(p *wire.TransportParameters) { params = p })
+			tracer.EXPECT().UpdatedKeyFromTLS(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedCongestionState(gomock.Any())
+			tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			newSession(
+				mconn,
+				sessionRunner,
+				nil,
+				nil,
+				clientDestConnID,
+				destConnID,
+				srcConnID,
+				protocol.StatelessResetToken{},
+				populateServerConfig(&Config{}),
+				nil, // tls.Config
+				tokenGenerator,
+				false,
+				tracer,
+				utils.DefaultLogger,
+				protocol.VersionTLS,
+			)
+			Expect(params).ToNot(BeNil())
+			Expect(params.DisableActiveMigration).To(BeTrue())
+		})
+
+		It("disables active migration if the path limit doesn't allow validating new addresses", func() {
+			for _, maxPaths := range []int{1, 2} {
+				tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+				var params *wire.TransportParameters
+				tracer.EXPECT().SentTransportParameters(gomock.Any()).Do(func(p *wire.TransportParameters) { params = p })
+				tracer.EXPECT().UpdatedKeyFromTLS(gomock.Any(), gomock.Any()).AnyTimes()
+				tracer.EXPECT().UpdatedCongestionState(gomock.Any())
+				tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
+				Expect(err).ToNot(HaveOccurred())
+				newSession(
+					mconn,
+					sessionRunner,
+					nil,
+					nil,
+					clientDestConnID,
+					destConnID,
+					srcConnID,
+					protocol.StatelessResetToken{},
+					populateServerConfig(&Config{MaxPaths: maxPaths, EnableActiveMigration: true}),
+					nil, // tls.Config
+					tokenGenerator,
+					false,
+					tracer,
+					utils.DefaultLogger,
+					protocol.VersionTLS,
+				)
+				Expect(params).ToNot(BeNil())
+				Expect(params.DisableActiveMigration).To(Equal(maxPaths == 1))
+			}
+		})
+
+		It("sends a reduced flow control window for unidirectional streams", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			var params *wire.TransportParameters
+			tracer.EXPECT().SentTransportParameters(gomock.Any()).Do(func(p *wire.TransportParameters) { params = p })
+			tracer.EXPECT().UpdatedKeyFromTLS(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedCongestionState(gomock.Any())
+			tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			newSession(
+				mconn,
+				sessionRunner,
+				nil,
+				nil,
+				clientDestConnID,
+				destConnID,
+				srcConnID,
+				protocol.StatelessResetToken{},
+				populateServerConfig(&Config{MaxReceiveUniStreamFlowControlWindow: 1000}),
+				nil, // tls.Config
+				tokenGenerator,
+				false,
+				tracer,
+				utils.DefaultLogger,
+				protocol.VersionTLS,
+			)
+			Expect(params).ToNot(BeNil())
+			Expect(params.InitialMaxStreamDataUni).To(BeEquivalentTo(1000))
+			Expect(params.InitialMaxStreamDataBidiLocal).To(BeEquivalentTo(protocol.InitialMaxStreamData))
+			Expect(params.InitialMaxStreamDataBidiRemote).To(BeEquivalentTo(protocol.InitialMaxStreamData))
+		})
+
+		It("sends the configured initial flow control windows for streams", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			var params *wire.TransportParameters
+			tracer.EXPECT().SentTransportParameters(gomock.Any()).Do(func(p *wire.TransportParameters) { params = p })
+			tracer.EXPECT().UpdatedKeyFromTLS(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedCongestionState(gomock.Any())
+			tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			newSession(
+				mconn,
+				sessionRunner,
+				nil,
+				nil,
+				clientDestConnID,
+				destConnID,
+				srcConnID,
+				protocol.StatelessResetToken{},
+				populateServerConfig(&Config{
+					InitialStreamReceiveWindowBidiLocal:  1000,
+					InitialStreamReceiveWindowBidiRemote: 2000,
+					InitialStreamReceiveWindowUni:        3000,
+				}),
+				nil, // tls.Config
+				tokenGenerator,
+				false,
+				tracer,
+				utils.DefaultLogger,
+				protocol.VersionTLS,
+			)
+			Expect(params).ToNot(BeNil())
+			Expect(params.InitialMaxStreamDataBidiLocal).To(BeEquivalentTo(1000))
+			Expect(params.InitialMaxStreamDataBidiRemote).To(BeEquivalentTo(2000))
+			Expect(params.InitialMaxStreamDataUni).To(BeEquivalentTo(3000))
+		})
+
+		It("sends the configured initial flow control windows for streams and the connection", func() {
+			tracer := mocklogging.NewMockConnectionTracer(mockCtrl)
+			var params *wire.TransportParameters
+			tracer.EXPECT().SentTransportParameters(gomock.Any()).Do(func(p *wire.TransportParameters) { params = p })
+			tracer.EXPECT().UpdatedKeyFromTLS(gomock.Any(), gomock.Any()).AnyTimes()
+			tracer.EXPECT().UpdatedCongestionState(gomock.Any())
+			tokenGenerator, err := handshake.NewTokenGenerator(rand.Reader)
+			Expect(err).ToNot(HaveOccurred())
+			newSession(
+				mconn,
+				sessionRunner,
+				nil,
+				nil,
+				clientDestConnID,
+				destConnID,
+				srcConnID,
+				protocol.StatelessResetToken{},
+				populateServerConfig(&Config{
+					InitialStreamReceiveWindow:     4 << 20,
+					InitialConnectionReceiveWindow: 8 << 20,
+				}),
+				nil, // tls.Config
+				tokenGenerator,
+				false,
+				tracer,
+				utils.DefaultLogger,
+				protocol.VersionTLS,
+			)
+			Expect(params).ToNot(BeNil())
+			Expect(params.InitialMaxStreamDataBidiLocal).To(BeEquivalentTo(4 << 20))
+			Expect(params.InitialMaxStreamDataBidiRemote).To(BeEquivalentTo(4 << 20))
+			Expect(params.InitialMaxStreamDataUni).To(BeEquivalentTo(4 << 20))
+			Expect(params.InitialMaxData).To(BeEquivalentTo(8 << 20))
+		})
+
+		It("enforces the initial flow control window for bidirectional streams opened by us", func() {
+			sess.config.InitialStreamReceiveWindowBidiLocal = 1000
+			fc := sess.newFlowController(1) // a bidirectional stream opened by the server
+			Expect(fc.UpdateHighestReceived(1000, false)).To(Succeed())
+			Expect(fc.UpdateHighestReceived(1001, false)).ToNot(Succeed())
+			// other streams use the default window
+			Expect(sess.newFlowController(0).UpdateHighestReceived(1001, false)).To(Succeed())
+			Expect(sess.newFlowController(2).UpdateHighestReceived(1001, false)).To(Succeed())
+		})
+
+		It("enforces the initial flow control window for bidirectional streams opened by the peer", func() {
+			sess.config.InitialStreamReceiveWindowBidiRemote = 1000
+			fc := sess.newFlowController(0) // a bidirectional stream opened by the client
+			Expect(fc.UpdateHighestReceived(1000, false)).To(Succeed())
+			Expect(fc.UpdateHighestReceived(1001, false)).ToNot(Succeed())
+			// other streams use the default window
+			Expect(sess.newFlowController(1).UpdateHighestReceived(1001, false)).To(Succeed())
+			Expect(sess.newFlowController(2).UpdateHighestReceived(1001, false)).To(Succeed())
+		})
+
+		It("enforces the initial flow control window for unidirectional streams", func() {
+			sess.config.InitialStreamReceiveWindowUni = 1000
+			fc := sess.newFlowController(2) // a unidirectional stream opened by the client
+			Expect(fc.UpdateHighestReceived(1000, false)).To(Succeed())
+			Expect(fc.UpdateHighestReceived(1001, false)).ToNot(Succeed())
+			// bidirectional streams use the default window
+			Expect(sess.newFlowController(0).UpdateHighestReceived(1001, false)).To(Succeed())
+			Expect(sess.newFlowController(1).UpdateHighestReceived(1001, false)).To(Succeed())
+		})
+
+		It("enforces the initial flow control window for all types of streams", func() {
+			sess.config.InitialStreamReceiveWindow = 1000
+			sess.config.InitialStreamReceiveWindowUni = 2000
+			for _, id := range []protocol.StreamID{0, 1} {
+				fc := sess.newFlowController(id)
+				Expect(fc.UpdateHighestReceived(1000, false)).To(Succeed())
+				Expect(fc.UpdateHighestReceived(1001, false)).ToNot(Succeed())
+			}
+			// the window configured for unidirectional streams takes precedence
+			Expect(sess.newFlowController(2).UpdateHighestReceived(2000, false)).To(Succeed())
+		})
+
+		It("enforces the reduced flow control window for unidirectional streams", func() {
+			sess.config.MaxReceiveUniStreamFlowControlWindow = 1000
+			fc := sess.newFlowController(2) // a unidirectional stream opened by the client
+			Expect(fc.UpdateHighestReceived(1000, false)).To(Succeed())
+			Expect(fc.UpdateHighestReceived(1001, false)).ToNot(Succeed())
+			// bidirectional streams use the default window
+			fc = sess.newFlowController(0)
+			Expect(fc.UpdateHighestReceived(1001, false)).To(Succeed())
+		})
+
+		It("uses the right send windows for bidirectional streams", func() {
+			sess.peerParams = &wire.TransportParameters{
+				InitialMaxStreamDataBidiLocal:  0x1000,
+				InitialMaxStreamDataBidiRemote: 0x2000,
+			}
+			sess.connFlowController.UpdateSendWindow(0x10000)
+			// a bidirectional stream opened by the client: the limit for streams opened by the client applies
+			Expect(sess.newFlowController(0).SendWindowSize()).To(BeEquivalentTo(0x1000))
+			// a bidirectional stream opened by the server: the limit for streams opened by the server applies
+			Expect(sess.newFlowController(1).SendWindowSize()).To(BeEquivalentTo(0x2000))
+		})
 	})
 
 	Context("keep-alives", func() {
@@ -1877,7 +2821,7 @@ var _ = Describe("Session", func() {
 			setRemoteIdleTimeout(5 * time.Second)
 			sess.lastPacketReceivedTime = time.Now().Add(-5 * time.Second / 2)
 			sent := make(chan struct{})
-			packer.EXPECT().PackCoalescedPacket().Do(func() (*packedPacket, error) {
+			packer.EXPECT().PackCoalescedPacket(false).Do(func(bool) (*packedPacket, error) {
 				close(sent)
 				return nil, nil
 			})
@@ -1890,7 +2834,7 @@ var _ = Describe("Session", func() {
 			setRemoteIdleTimeout(time.Hour)
 			sess.lastPacketReceivedTime = time.Now().Add(-protocol.MaxKeepAliveInterval).Add(-time.Millisecond)
 			sent := make(chan struct{})
-			packer.EXPECT().PackCoalescedPacket().Do(func() (*packedPacket, error) {
+			packer.EXPECT().PackCoalescedPacket(false).Do(func(bool) (*packedPacket, error) {
 				close(sent)
 				return nil, nil
 			})
@@ -1907,6 +2851,15 @@ var _ = Describe("Session", func() {
 			time.Sleep(50 * time.Millisecond)
 		})
 
+		It("doesn't send a PING if an ack-eliciting packet was sent since the last packet was received", func() {
+			setRemoteIdleTimeout(5 * time.Second)
+			sess.lastPacketReceivedTime = time.Now().Add(-5 * time.Second / 2)
+			sess.firstAckElicitingPacketAfterIdleSentTime = time.Now().Add(-time.Second)
+			runSession()
+			// don't EXPECT() any calls to mconn.Write()
+			time.Sleep(50 * time.Millisecond)
+		})
+
 		It("doesn't send a PING if the handshake isn't completed yet", func() {
 			sess.handshakeComplete = false
 			// Needs to be shorter than our idle timeout.
@@ -2010,7 +2963,7 @@ var _ = Describe("Session", func() {
 
 		It("closes the session due to the idle timeout before handshake", func() {
 			sess.config.HandshakeIdleTimeout = 0
-			packer.EXPECT().PackCoalescedPacket().AnyTimes()
+			packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
 			sessionRunner.EXPECT().Remove(gomock.Any()).AnyTimes()
 			cryptoSetup.EXPECT().Close()
 			gomock.InOrder(
@@ -2038,7 +2991,7 @@ var _ = Describe("Session", func() {
 		})
 
 		It("closes the session due to the idle timeout after handshake", func() {
-			packer.EXPECT().PackCoalescedPacket().AnyTimes()
+			packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
 			gomock.InOrder(
 				sessionRunner.EXPECT().Retire(clientDestConnID),
 				sessionRunner.EXPECT().Remove(gomock.Any()),
@@ -2070,6 +3023,40 @@ var _ = Describe("Session", func() {
 			Eventually(done).Should(BeClosed())
 		})
 
+		It("closes the session when the peer stops acknowledging packets", func() {
+			packer.EXPECT().PackCoalescedPacket(false).AnyTimes()
+			expectReplaceWithClosed()
+			cryptoSetup.EXPECT().Close()
+			// the peer is notified
+			packer.EXPECT().PackConnectionClose(ErrSendStallTimeout).Return(&coalescedPacket{buffer: getPacketBuffer()}, nil)
+			mconn.EXPECT().Write(gomock.Any())
+			gomock.InOrder(
+				tracer.EXPECT().ClosedConnection(gomock.Any()).Do(func(reason logging.CloseReason) {
+					timeout, ok := reason.Timeout()
+					Expect(ok).To(BeTrue())
+					Expect(timeout).To(Equal(logging.TimeoutReasonSendStall))
+				}),
+				tracer.EXPECT().Close(),
+			)
+			sess.config.SendStallTimeout = 100 * time.Millisecond
+			// the peer is still sending packets, so the idle timeout doesn't fire
+			sess.lastPacketReceivedTime = time.Now()
+			sess.sendStallStartTime = time.Now()
+			done := make(chan struct{})
+			go func() {
+				defer GinkgoRecover()
+				cryptoSetup.EXPECT().RunHandshake().MaxTimes(1)
+				err := sess.run()
+				Expect(err).To(MatchError(ErrSendStallTimeout))
+				nerr, ok := err.(net.Error)
+				Expect(ok).To(BeTrue())
+				Expect(nerr.Timeout()).To(BeTrue())
+				close(done)
+			}()
+			Consistently(done, 50*time.Millisecond).ShouldNot(BeClosed())
+			Eventually(done).Should(BeClosed())
+		})
+
 		It("doesn't time out when it just sent a packet", func() {
 			sess.lastPacketReceivedTime = time.Now().Add(-time.Hour)
 			sess.firstAckElicitingPacketAfterIdleSentTime = time.Now().Add(-time.Second)
@@ -2104,6 +3091,23 @@ var _ = Describe("Session", func() {
 		Eventually(done).Should(BeClosed())
 	})
 
+	It("pauses and resumes sending of application data", func() {
+		sess.framer.AddActiveStream(5)
+		Expect(sess.framer.HasData()).To(BeTrue())
+		sess.PauseSending()
+		Expect(sess.framer.HasData()).To(BeFalse())
+		// control frames are still sent
+		sess.framer.QueueControlFrame(&wire.PingFrame{})
+		Expect(sess.framer.HasData()).To(BeTrue())
+		frames, _ := sess.framer.AppendControlFrames(nil, protocol.MaxByteCount)
+		Expect(frames).To(HaveLen(1))
+		Expect(sess.framer.HasData()).To(BeFalse())
+		Expect(sess.sendingScheduled).ToNot(Receive())
+		sess.ResumeSending()
+		Expect(sess.framer.HasData()).To(BeTrue())
+		Expect(sess.sendingScheduled).To(Receive())
+	})
+
 	Context("getting streams", func() {
 		It("opens streams", func() {
 			mstr := NewMockStreamI(mockCtrl)
@@ -2137,6 +3141,99 @@ var _ = Describe("Session", func() {
 			Expect(str).To(Equal(mstr))
 		})
 
+		It("returns the ID of the next stream", func() {
+			streamManager.EXPECT().NextStreamID(protocol.StreamTypeBidi).Return(protocol.StreamID(8))
+			Expect(sess.NextStreamID()).To(Equal(protocol.StreamID(8)))
+			streamManager.EXPECT().NextStreamID(protocol.StreamTypeUni).Return(protocol.StreamID(10))
+			Expect(sess.NextUniStreamID()).To(Equal(protocol.StreamID(10)))
+		})
+
+		It("checks if streams can be opened", func() {
+			streamManager.EXPECT().CanOpenStreams(protocol.StreamTypeBidi, 5).Return(true)
+			Expect(sess.CanOpenStreams(5)).To(BeTrue())
+			streamManager.EXPECT().CanOpenStreams(protocol.StreamTypeUni, 6).Return(false)
+			Expect(sess.CanOpenUniStreams(6)).To(BeFalse())
+		})
+
+		It("returns the idle streams", func() {
+			str := NewMockStreamI(mockCtrl)
+			streamManager.EXPECT().IdleStreams(gomock.Any(), time.Minute).Return([]streamI{str})
+			Expect(sess.IdleStreams(time.Minute)).To(Equal([]Stream{str}))
+		})
+
+		It("resets streams", func() {
+			sendStr := NewMockSendStreamI(mockCtrl)
+			receiveStr := NewMockReceiveStreamI(mockCtrl)
+			streamManager.EXPECT().CloseStreams(gomock.Any(), gomock.Any(), gomock.Any()).Do(
+				func(match func(protocol.StreamID) bool, closeSend func(sendStreamI), closeReceive func(receiveStreamI)) {
+					Expect(match(4)).To(BeTrue())
+					Expect(match(8)).To(BeFalse())
+					closeSend(sendStr)
+					closeReceive(receiveStr)
+				},
+			)
+			sendStr.EXPECT().CancelWrite(ErrorCode(1337))
+			receiveStr.EXPECT().CancelRead(ErrorCode(1337))
+			sess.ResetStreams(func(id StreamID) bool { return id == 4 }, 1337)
+		})
+
+		It("returns the IDs of the open streams", func() {
+			streamManager.EXPECT().OpenStreamIDs().Return([]protocol.StreamID{0, 3, 4})
+			Expect(sess.OpenStreamIDs()).To(Equal([]protocol.StreamID{0, 3, 4}))
+		})
+
+		It("errors if the peer doesn't increase the stream limit in time", func() {
+			sess.config.StreamLimitTimeout = 50 * time.Millisecond
+			streamManager.EXPECT().OpenStreamSync(gomock.Any()).DoAndReturn(func(ctx context.Context) (Stream, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			_, err := sess.OpenStreamSync(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(errors.Is(err, ErrStreamLimitNotIncreased)).To(BeTrue())
+			nerr, ok := err.(net.Error)
+			Expect(ok).To(BeTrue())
+			Expect(nerr.Timeout()).To(BeTrue())
+		})
+
+		It("errors if the peer doesn't increase the unidirectional stream limit in time", func() {
+			sess.config.StreamLimitTimeout = 50 * time.Millisecond
+			streamManager.EXPECT().OpenUniStreamSync(gomock.Any()).DoAndReturn(func(ctx context.Context) (SendStream, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			_, err := sess.OpenUniStreamSync(context.Background())
+			Expect(errors.Is(err, ErrStreamLimitNotIncreased)).To(BeTrue())
+		})
+
+		It("returns the context's error when the context is canceled before the stream limit timeout", func() {
+			sess.config.StreamLimitTimeout = time.Hour
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			streamManager.EXPECT().OpenStreamSync(gomock.Any()).DoAndReturn(func(ctx context.Context) (Stream, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			})
+			_, err := sess.OpenStreamSync(ctx)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("closes the session when the stream IDs are exhausted", func() {
+			streamManager.EXPECT().OpenStream().Return(nil, errStreamIDsExhausted)
+			_, err := sess.OpenStream()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.StreamLimitError))
+			Expect(sess.closeChan).To(Receive(Equal(closeError{err: err})))
+		})
+
+		It("closes the session when the stream IDs for unidirectional streams are exhausted", func() {
+			streamManager.EXPECT().OpenUniStreamSync(context.Background()).Return(nil, errStreamIDsExhausted)
+			_, err := sess.OpenUniStreamSync(context.Background())
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*qerr.QuicError).ErrorCode).To(Equal(qerr.StreamLimitError))
+			Expect(sess.closeChan).To(Receive(Equal(closeError{err: err})))
+		})
+
 		It("accepts streams", func() {
 			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 			defer cancel()
@@ -2165,6 +3262,211 @@ var _ = Describe("Session", func() {
 	It("returns the remote address", func() {
 		Expect(sess.RemoteAddr()).To(Equal(remoteAddr))
 	})
+
+	It("closes the session when all streams are closed, if CloseOnIdle is set", func() {
+		sess.config.CloseOnIdle = true
+		sess.onAllStreamsClosed()
+		Expect(sess.closeChan).To(Receive(Equal(closeError{})))
+	})
+
+	It("doesn't close the session when all streams are closed, if CloseOnIdle is not set", func() {
+		sess.onAllStreamsClosed()
+		Expect(sess.closeChan).ToNot(Receive())
+	})
+
+	It("reports the creation time and the original destination connection ID in the connection state", func() {
+		sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{})
+		cryptoSetup.EXPECT().DidHelloRetryRequest()
+		streamManager.EXPECT().FlowControlWindows()
+		state := sess.ConnectionState()
+		Expect(state.CreationTime).To(BeTemporally("~", time.Now(), scaleDuration(100*time.Millisecond)))
+		Expect(state.OriginalDestinationConnectionID).To(Equal(clientDestConnID))
+	})
+
+	It("only starts path MTU discovery if the DF bit is set", func() {
+		sess.startMTUDiscovery()
+		Expect(sess.mtuDiscoverer).To(BeNil())
+		conn := NewMockSendConn(mockCtrl)
+		conn.EXPECT().RemoteAddr().Return(remoteAddr).AnyTimes()
+		conn.EXPECT().SupportsDF().Return(true)
+		sess.conn = conn
+		sess.startMTUDiscovery()
+		Expect(sess.mtuDiscoverer).ToNot(BeNil())
+	})
+
+	It("doesn't start the send stall timer for MTU probe packets", func() {
+		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		sess.sentPacketHandler = sph
+		p := getPacket(10)
+		p.frames = []ackhandler.Frame{{Frame: &wire.PingFrame{}}}
+		p.isMTUProbePacket = true
+		sph.EXPECT().SentPacket(gomock.Any()).Do(func(packet *ackhandler.Packet) {
+			Expect(packet.IsPathMTUProbePacket).To(BeTrue())
+		})
+		tracer.EXPECT().SentPacket(p.header, p.length, gomock.Any(), gomock.Any())
+		sess.sendPackedPacket(p)
+		Expect(sess.sendStallStartTime).To(BeZero())
+	})
+
+	It("starts the send stall timer when sending a coalesced packet", func() {
+		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		sess.sentPacketHandler = sph
+		sph.EXPECT().SentPacket(gomock.Any()).Times(2)
+		tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+		packet := &coalescedPacket{
+			buffer: getPacketBuffer(),
+			packets: []*packetContents{
+				{
+					header: &wire.ExtendedHeader{
+						Header:       wire.Header{IsLongHeader: true, Type: protocol.PacketTypeHandshake},
+						PacketNumber: 1,
+					},
+					ack: &wire.AckFrame{AckRanges: []wire.AckRange{{Smallest: 1, Largest: 1}}},
+				},
+				{
+					header: &wire.ExtendedHeader{PacketNumber: 2},
+					frames: []ackhandler.Frame{{Frame: &wire.HandshakeDoneFrame{}}},
+				},
+			},
+		}
+		now := time.Now()
+		sess.sendPackedCoalescedPacket(packet, now)
+		Expect(sess.sendStallStartTime).To(Equal(now))
+	})
+
+	It("reports the peer's active_connection_id_limit in the connection state", func() {
+		sess.peerParams = &wire.TransportParameters{
+			MaxDatagramFrameSize:    protocol.InvalidByteCount,
+			ActiveConnectionIDLimit: 7,
+		}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{})
+		cryptoSetup.EXPECT().DidHelloRetryRequest()
+		streamManager.EXPECT().FlowControlWindows()
+		Expect(sess.ConnectionState().PeerActiveConnectionIDLimit).To(BeEquivalentTo(7))
+	})
+
+	It("reports the congestion control algorithm in the connection state", func() {
+		sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{}).Times(2)
+		cryptoSetup.EXPECT().DidHelloRetryRequest().Times(2)
+		streamManager.EXPECT().FlowControlWindows().Times(2)
+		Expect(sess.ConnectionState().CongestionControl).To(Equal("reno"))
+		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		sph.EXPECT().CongestionControl().Return("bbr")
+		sess.sentPacketHandler = sph
+		Expect(sess.ConnectionState().CongestionControl).To(Equal("bbr"))
+	})
+
+	It("reports the flow control state in the connection state", func() {
+		sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{})
+		cryptoSetup.EXPECT().DidHelloRetryRequest()
+		connFC := mocks.NewMockConnectionFlowController(mockCtrl)
+		connFC.EXPECT().Windows().Return(protocol.ByteCount(1000), protocol.ByteCount(2000))
+		sess.connFlowController = connFC
+		streamManager.EXPECT().FlowControlWindows().Return(protocol.ByteCount(300), protocol.ByteCount(400))
+		Expect(sess.ConnectionState().FlowControl).To(Equal(FlowControlState{
+			SendWindow:           1000,
+			ReceiveWindow:        2000,
+			StreamSendWindows:    300,
+			StreamReceiveWindows: 400,
+		}))
+	})
+
+	It("reports the raw transport parameters in the connection state, if enabled", func() {
+		sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{}).Times(2)
+		cryptoSetup.EXPECT().DidHelloRetryRequest().Times(2)
+		streamManager.EXPECT().FlowControlWindows().Times(2)
+		Expect(sess.ConnectionState().RawPeerTransportParameters).To(BeNil())
+		sess.config.EnableRawTransportParameters = true
+		cryptoSetup.EXPECT().PeerTransportParameters().Return([]byte("foobar"))
+		Expect(sess.ConnectionState().RawPeerTransportParameters).To(Equal([]byte("foobar")))
+	})
+
+	It("reports the number of dropped datagrams in the connection state", func() {
+		sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: 1000}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{})
+		cryptoSetup.EXPECT().DidHelloRetryRequest()
+		streamManager.EXPECT().FlowControlWindows()
+		sess.datagramQueue = newDatagramQueue(func() {}, utils.DefaultLogger)
+		for i := 0; i < protocol.DatagramRcvQueueLen+3; i++ {
+			sess.datagramQueue.HandleDatagramFrame(&wire.DatagramFrame{Data: []byte("foobar")}, nil)
+		}
+		state := sess.ConnectionState()
+		Expect(state.SupportsDatagrams).To(BeTrue())
+		Expect(state.DroppedDatagrams).To(BeEquivalentTo(3))
+	})
+
+	It("reports statistics", func() {
+		sess.rttStats.UpdateRTT(100*time.Millisecond, 0, time.Now())
+		sess.countSentPackets(2, 1000)
+		sess.countSentPackets(1, 500)
+		sess.countReceivedPackets(1, 1200)
+		sess.countReceivedPackets(0, 100) // a packet that couldn't be processed
+		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		sph.EXPECT().LossCounts(protocol.EncryptionInitial).Return(uint64(1), uint64(2))
+		sph.EXPECT().LossCounts(protocol.EncryptionHandshake).Return(uint64(0), uint64(3))
+		sph.EXPECT().LossCounts(protocol.Encryption1RTT).Return(uint64(4), uint64(5))
+		sph.EXPECT().CongestionWindow().Return(protocol.ByteCount(12345))
+		sph.EXPECT().LargestAcked(protocol.EncryptionInitial).Return(protocol.PacketNumber(3))
+		sph.EXPECT().LargestAcked(protocol.EncryptionHandshake).Return(protocol.PacketNumber(1))
+		sph.EXPECT().LargestAcked(protocol.Encryption1RTT).Return(protocol.InvalidPacketNumber)
+		sess.sentPacketHandler = sph
+		streamManager.EXPECT().NumberOfStreams().Return(7)
+		streamManager.EXPECT().MaxConcurrentStreams().Return(42)
+		Expect(sess.Stats()).To(Equal(SessionStats{
+			BytesSent:            1500,
+			PacketsSent:          3,
+			BytesReceived:        1300,
+			PacketsReceived:      1,
+			RetransmittedPackets: 10,
+			PacketLoss: PacketLossState{
+				Initial:   PacketLossCounts{LostPackets: 1, RetransmittedPackets: 2},
+				Handshake: PacketLossCounts{RetransmittedPackets: 3},
+				OneRTT:    PacketLossCounts{LostPackets: 4, RetransmittedPackets: 5},
+			},
+			LargestAcked:         LargestAckedState{Initial: 3, Handshake: 1, OneRTT: -1},
+			SmoothedRTT:          100 * time.Millisecond,
+			LatestRTT:            100 * time.Millisecond,
+			CongestionWindow:     12345,
+			OpenStreams:          7,
+			MaxConcurrentStreams: 42,
+			Paths:                []PathInfo{{LocalAddr: localAddr, RemoteAddr: remoteAddr, Validated: true}},
+		}))
+	})
+
+	It("reports the number of handshake round trips in the connection state", func() {
+		sess.peerParams = &wire.TransportParameters{MaxDatagramFrameSize: protocol.InvalidByteCount}
+		cryptoSetup.EXPECT().ConnectionState().Return(handshake.ConnectionState{}).Times(3)
+		streamManager.EXPECT().FlowControlWindows().Times(3)
+		cryptoSetup.EXPECT().DidHelloRetryRequest()
+		Expect(sess.ConnectionState().HandshakeRoundTrips).To(Equal(1))
+		sess.retrySrcConnID = &protocol.ConnectionID{1, 2, 3, 4}
+		cryptoSetup.EXPECT().DidHelloRetryRequest()
+		Expect(sess.ConnectionState().HandshakeRoundTrips).To(Equal(2))
+		cryptoSetup.EXPECT().DidHelloRetryRequest().Return(true)
+		Expect(sess.ConnectionState().HandshakeRoundTrips).To(Equal(3))
+	})
+
+	It("delivers events when the send buffer crosses the watermarks", func() {
+		sess.maybeQueueSendBufferEvent() // no send buffer configured
+		Expect(sess.Events()).ToNot(Receive())
+		sess.sendBuffer = newSendBufferWatermark(1000, 500)
+		sess.sendBuffer.add(600)
+		sess.maybeQueueSendBufferEvent()
+		Expect(sess.Events()).ToNot(Receive())
+		sess.sendBuffer.add(400)
+		sess.maybeQueueSendBufferEvent()
+		Expect(sess.Events()).To(Receive(Equal(Event{Type: EventSendBufferAboveHighWatermark})))
+		sess.sendBuffer.remove(300)
+		sess.maybeQueueSendBufferEvent()
+		Expect(sess.Events()).ToNot(Receive())
+		sess.sendBuffer.remove(200)
+		sess.maybeQueueSendBufferEvent()
+		Expect(sess.Events()).To(Receive(Equal(Event{Type: EventSendBufferBelowLowWatermark})))
+	})
 })
 
 var _ = Describe("Client Session", func() {
@@ -2206,6 +3508,7 @@ var _ = Describe("Client Session", func() {
 		Eventually(areSessionsRunning).Should(BeFalse())
 
 		mconn = NewMockSendConn(mockCtrl)
+		mconn.EXPECT().SupportsDF().AnyTimes()
 		mconn.EXPECT().RemoteAddr().Return(&net.UDPAddr{}).Times(2)
 		mconn.EXPECT().LocalAddr().Return(&net.UDPAddr{})
 		if tlsConf == nil {
@@ -2305,6 +3608,146 @@ var _ = Describe("Client Session", func() {
 		Expect(sess.handleSinglePacket(&receivedPacket{buffer: getPacketBuffer()}, hdr)).To(BeTrue())
 	})
 
+	Context("migrating to a new local address", func() {
+		var (
+			sph    *mockackhandler.MockSentPacketHandler
+			server *net.UDPConn
+			conn   *net.UDPConn
+		)
+
+		BeforeEach(func() {
+			sph = mockackhandler.NewMockSentPacketHandler(mockCtrl)
+			sess.sentPacketHandler = sph
+			sess.handshakeConfirmed = true
+			sess.peerParams = &wire.TransportParameters{}
+			var err error
+			server, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			conn, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			Expect(err).ToNot(HaveOccurred())
+			mconn.EXPECT().RemoteAddr().Return(server.LocalAddr()).AnyTimes()
+		})
+
+		AfterEach(func() {
+			server.Close()
+			conn.Close()
+		})
+
+		addConnectionID := func() {
+			sessionRunner.EXPECT().AddResetToken(gomock.Any(), gomock.Any())
+			Expect(sess.handleNewConnectionIDFrame(&wire.NewConnectionIDFrame{
+				SequenceNumber: 1,
+				ConnectionID:   protocol.ConnectionID{1, 2, 3, 4, 5},
+			})).To(Succeed())
+		}
+
+		expectPathChallenge := func(challenge *wire.PathChallengeFrame) {
+			packer.EXPECT().PackPathProbePacket(gomock.Any()).DoAndReturn(func(f wire.Frame) (*packedPacket, error) {
+				Expect(f).To(BeAssignableToTypeOf(&wire.PathChallengeFrame{}))
+				*challenge = *f.(*wire.PathChallengeFrame)
+				buffer := getPacketBuffer()
+				buffer.Data = append(buffer.Data, []byte("probe")...)
+				return &packedPacket{
+					buffer: buffer,
+					packetContents: &packetContents{
+						header: &wire.ExtendedHeader{PacketNumber: 10},
+						frames: []ackhandler.Frame{{Frame: f}},
+						length: 5, // probe
+					},
+				}, nil
+			})
+			sph.EXPECT().SentPacket(gomock.Any())
+			tracer.EXPECT().SentPacket(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+		}
+
+		It("refuses to migrate before the handshake is confirmed", func() {
+			sess.handshakeConfirmed = false
+			m, err := newPathMigration(conn, false)
+			Expect(err).ToNot(HaveOccurred())
+			sess.startMigration(m)
+			Expect(m.result).To(Receive(MatchError("cannot migrate before the handshake is confirmed")))
+			Expect(sess.migration).To(BeNil())
+		})
+
+		It("refuses to migrate if the peer disabled active migration", func() {
+			sess.peerParams.DisableActiveMigration = true
+			m, err := newPathMigration(conn, false)
+			Expect(err).ToNot(HaveOccurred())
+			sess.startMigration(m)
+			Expect(m.result).To(Receive(MatchError("peer disabled active migration")))
+		})
+
+		It("refuses to migrate if the path limit is reached", func() {
+			sess.config.MaxPaths = 1
+			addConnectionID()
+			m, err := newPathMigration(conn, false)
+			Expect(err).ToNot(HaveOccurred())
+			sess.startMigration(m)
+			Expect(m.result).To(Receive(MatchError("path limit reached")))
+			Expect(sess.migration).To(BeNil())
+		})
+
+		It("refuses to migrate if no unused connection ID is available", func() {
+			m, err := newPathMigration(conn, false)
+			Expect(err).ToNot(HaveOccurred())
+			sess.startMigration(m)
+			Expect(m.result).To(Receive(MatchError("no unused connection ID available for migration")))
+		})
+
+		It("validates the new path before switching to it", func() {
+			addConnectionID()
+			m, err := newPathMigration(conn, false)
+			Expect(err).ToNot(HaveOccurred())
+			var challenge wire.PathChallengeFrame
+			expectPathChallenge(&challenge)
+			sess.startMigration(m)
+			Expect(challenge.Data).To(Equal(m.challenge))
+			Expect(sess.migration).To(Equal(m))
+			Expect(sess.probingPaths).To(Equal([]PathInfo{{LocalAddr: conn.LocalAddr(), RemoteAddr: server.LocalAddr()}}))
+			Expect(sess.connIDManager.Get()).To(Equal(protocol.ConnectionID{1, 2, 3, 4, 5}))
+			// the PATH_CHALLENGE is sent from the new local address
+			b := make([]byte, 100)
+			n, addr, err := server.ReadFrom(b)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(b[:n]).To(Equal([]byte("probe")))
+			Expect(addr.String()).To(Equal(conn.LocalAddr().String()))
+			// packets received on the new path are passed to the session runner, e.g. to detect stateless resets
+			received := make(chan *receivedPacket, 1)
+			sessionRunner.EXPECT().handlePacket(gomock.Any()).Do(func(p *receivedPacket) { received <- p })
+			_, err = server.WriteTo([]byte("foobar"), conn.LocalAddr())
+			Expect(err).ToNot(HaveOccurred())
+			var p *receivedPacket
+			Eventually(received).Should(Receive(&p))
+			Expect(p.data).To(Equal([]byte("foobar")))
+			Expect(m.result).ToNot(Receive())
+			mconn.EXPECT().SetPacketConn(conn, false)
+			sph.EXPECT().MigratedPath()
+			Expect(sess.handleFrame(&wire.PathResponseFrame{Data: challenge.Data}, protocol.Encryption1RTT, protocol.ConnectionID{}, nil)).To(Succeed())
+			Expect(m.result).To(Receive(BeNil()))
+			Expect(sess.migration).To(BeNil())
+			Expect(sess.probingPaths).To(BeEmpty())
+			Expect(sess.migratedConn).To(Equal(conn))
+			Expect(sess.Events()).To(Receive(Equal(Event{Type: EventPathMigrated, LocalAddr: conn.LocalAddr(), RemoteAddr: server.LocalAddr()})))
+		})
+
+		It("abandons the migration if the path can't be validated", func() {
+			addConnectionID()
+			m, err := newPathMigration(conn, false)
+			Expect(err).ToNot(HaveOccurred())
+			var challenge, retransmission wire.PathChallengeFrame
+			expectPathChallenge(&challenge)
+			sess.startMigration(m)
+			// the PATH_CHALLENGE is retransmitted
+			expectPathChallenge(&retransmission)
+			sess.maybeProbePath(m.nextProbe)
+			Expect(retransmission).To(Equal(challenge))
+			Expect(m.result).ToNot(Receive())
+			sess.maybeProbePath(m.deadline)
+			Expect(m.result).To(Receive(MatchError("path validation timed out")))
+			Expect(sess.migration).To(BeNil())
+		})
+	})
+
 	It("handles HANDSHAKE_DONE frames", func() {
 		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
 		sess.sentPacketHandler = sph
@@ -2313,6 +3756,17 @@ var _ = Describe("Client Session", func() {
 		Expect(sess.handleHandshakeDoneFrame()).To(Succeed())
 	})
 
+	It("records the handshake duration when the handshake is confirmed", func() {
+		sess.sessionCreationTime = time.Now().Add(-100 * time.Millisecond)
+		Expect(sess.stats.HandshakeDuration).To(BeZero())
+		sph := mockackhandler.NewMockSentPacketHandler(mockCtrl)
+		sess.sentPacketHandler = sph
+		sph.EXPECT().SetHandshakeConfirmed()
+		cryptoSetup.EXPECT().SetHandshakeConfirmed()
+		Expect(sess.handleHandshakeDoneFrame()).To(Succeed())
+		Expect(sess.stats.HandshakeDuration).To(BeNumerically("~", 100*time.Millisecond, scaleDuration(20*time.Millisecond)))
+	})
+
 	Context("handling tokens", func() {
 		var mockTokenStore *MockTokenStore
 
@@ -2522,7 +3976,7 @@ var _ = Describe("Client Session", func() {
 				},
 			}
 			packer.EXPECT().HandleTransportParameters(gomock.Any())
-			packer.EXPECT().PackCoalescedPacket().MaxTimes(1)
+			packer.EXPECT().PackCoalescedPacket(false).MaxTimes(1)
 			tracer.EXPECT().ReceivedTransportParameters(params)
 			sess.processTransportParameters(params)
 			sess.connIDManager.SetHandshakeComplete()
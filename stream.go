@@ -1,6 +1,7 @@
 package quic
 
 import (
+	"io"
 	"sync"
 	"time"
 
@@ -46,11 +47,15 @@ type streamI interface {
 	handleStreamFrame(*wire.StreamFrame) error
 	handleResetStreamFrame(*wire.ResetStreamFrame) error
 	getWindowUpdate() protocol.ByteCount
+	receiveWindowSize() protocol.ByteCount
 	// for sending
 	hasData() bool
 	handleStopSendingFrame(*wire.StopSendingFrame)
 	popStreamFrame(maxBytes protocol.ByteCount) (*ackhandler.Frame, bool)
 	handleMaxStreamDataFrame(*wire.MaxStreamDataFrame)
+	sendWindowSize() protocol.ByteCount
+	// lastActivity returns the time of the last call to Read or Write that transferred data
+	lastActivity() time.Time
 }
 
 var (
@@ -70,10 +75,17 @@ type stream struct {
 	receiveStreamCompleted bool
 	sendStreamCompleted    bool
 
+	activityMutex    sync.Mutex
+	lastActivityTime time.Time
+
 	version protocol.VersionNumber
 }
 
-var _ Stream = &stream{}
+var (
+	_ Stream        = &stream{}
+	_ io.ReaderFrom = &stream{}
+	_ io.WriterTo   = &stream{}
+)
 
 type streamCanceledError struct {
 	error
@@ -89,9 +101,10 @@ var _ StreamError = &streamCanceledError{}
 func newStream(streamID protocol.StreamID,
 	sender streamSender,
 	flowController flowcontrol.StreamFlowController,
+	sendBuffer *sendBufferWatermark,
 	version protocol.VersionNumber,
 ) *stream {
-	s := &stream{sender: sender, version: version}
+	s := &stream{sender: sender, version: version, lastActivityTime: time.Now()}
 	senderForSendStream := &uniStreamSender{
 		streamSender: sender,
 		onStreamCompletedImpl: func() {
@@ -101,7 +114,7 @@ func newStream(streamID protocol.StreamID,
 			s.completedMutex.Unlock()
 		},
 	}
-	s.sendStream = *newSendStream(streamID, senderForSendStream, flowController, version)
+	s.sendStream = *newSendStream(streamID, senderForSendStream, flowController, sendBuffer, version)
 	senderForReceiveStream := &uniStreamSender{
 		streamSender: sender,
 		onStreamCompletedImpl: func() {
@@ -121,6 +134,50 @@ func (s *stream) StreamID() protocol.StreamID {
 	return s.sendStream.StreamID()
 }
 
+func (s *stream) Read(p []byte) (int, error) {
+	n, err := s.receiveStream.Read(p)
+	if n > 0 {
+		s.updateLastActivity()
+	}
+	return n, err
+}
+
+func (s *stream) Write(p []byte) (int, error) {
+	n, err := s.sendStream.Write(p)
+	if n > 0 {
+		s.updateLastActivity()
+	}
+	return n, err
+}
+
+func (s *stream) ReadFrom(r io.Reader) (int64, error) {
+	n, err := s.sendStream.ReadFrom(r)
+	if n > 0 {
+		s.updateLastActivity()
+	}
+	return n, err
+}
+
+func (s *stream) WriteTo(w io.Writer) (int64, error) {
+	n, err := s.receiveStream.WriteTo(w)
+	if n > 0 {
+		s.updateLastActivity()
+	}
+	return n, err
+}
+
+func (s *stream) updateLastActivity() {
+	s.activityMutex.Lock()
+	s.lastActivityTime = time.Now()
+	s.activityMutex.Unlock()
+}
+
+func (s *stream) lastActivity() time.Time {
+	s.activityMutex.Lock()
+	defer s.activityMutex.Unlock()
+	return s.lastActivityTime
+}
+
 func (s *stream) Close() error {
 	return s.sendStream.Close()
 }
@@ -39,7 +39,7 @@ var _ = Describe("Stream", func() {
 	BeforeEach(func() {
 		mockSender = NewMockStreamSender(mockCtrl)
 		mockFC = mocks.NewMockStreamFlowController(mockCtrl)
-		str = newStream(streamID, mockSender, mockFC, protocol.VersionWhatever)
+		str = newStream(streamID, mockSender, mockFC, nil, protocol.VersionWhatever)
 
 		timeout := scaleDuration(250 * time.Millisecond)
 		strWithTimeout = struct {
@@ -76,6 +76,40 @@ var _ = Describe("Stream", func() {
 		})
 	})
 
+	Context("tracking activity", func() {
+		BeforeEach(func() {
+			str.lastActivityTime = time.Now().Add(-time.Hour)
+		})
+
+		It("updates the last activity when reading", func() {
+			mockFC.EXPECT().UpdateHighestReceived(protocol.ByteCount(6), false)
+			mockFC.EXPECT().AddBytesRead(protocol.ByteCount(6))
+			Expect(str.handleStreamFrame(&wire.StreamFrame{Data: []byte("foobar")})).To(Succeed())
+			n, err := strWithTimeout.Read(make([]byte, 6))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(6))
+			Expect(str.lastActivity()).To(BeTemporally("~", time.Now(), scaleDuration(10*time.Millisecond)))
+		})
+
+		It("updates the last activity when writing", func() {
+			mockSender.EXPECT().onHasStreamData(streamID)
+			n, err := strWithTimeout.Write([]byte("foobar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(Equal(6))
+			Expect(str.lastActivity()).To(BeTemporally("~", time.Now(), scaleDuration(10*time.Millisecond)))
+		})
+
+		It("doesn't update the last activity when no data is transferred", func() {
+			n, err := str.Write(nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(n).To(BeZero())
+			str.SetReadDeadline(time.Now().Add(-time.Second))
+			_, err = str.Read(make([]byte, 6))
+			Expect(err).To(MatchError(errDeadline))
+			Expect(str.lastActivity()).To(BeTemporally("<", time.Now().Add(-time.Minute)))
+		})
+	})
+
 	Context("completing", func() {
 		It("is not completed when only the receive side is completed", func() {
 			// don't EXPECT a call to mockSender.onStreamCompleted()
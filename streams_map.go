@@ -5,6 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/lucas-clemente/quic-go/internal/flowcontrol"
 	"github.com/lucas-clemente/quic-go/internal/protocol"
@@ -15,6 +18,8 @@ import (
 type streamError struct {
 	message string
 	nums    []protocol.StreamNum
+	// set if the peer tried to open a stream beyond the stream limit
+	limitExceeded bool
 }
 
 func (e streamError) Error() string {
@@ -30,19 +35,37 @@ func convertStreamError(err error, stype protocol.StreamType, pers protocol.Pers
 	for i, num := range strError.nums {
 		ids[i] = num.StreamID(stype, pers)
 	}
-	return fmt.Errorf(strError.Error(), ids...)
+	return streamError{
+		message:       fmt.Sprintf(strError.Error(), ids...),
+		limitExceeded: strError.limitExceeded,
+	}
+}
+
+// streamErrorCode returns the error code used to close the connection
+// when the peer violates the stream state or the stream limit.
+func streamErrorCode(err error) qerr.ErrorCode {
+	if strError, ok := err.(streamError); ok && strError.limitExceeded {
+		return qerr.StreamLimitError
+	}
+	return qerr.StreamStateError
 }
 
 type streamOpenErr struct{ error }
 
 var _ net.Error = &streamOpenErr{}
 
-func (e streamOpenErr) Temporary() bool { return e.error == errTooManyOpenStreams }
-func (streamOpenErr) Timeout() bool     { return false }
+func (e streamOpenErr) Temporary() bool {
+	return e.error == errTooManyOpenStreams || e.error == ErrStreamLimitNotIncreased
+}
+func (e streamOpenErr) Timeout() bool { return e.error == ErrStreamLimitNotIncreased }
+func (e streamOpenErr) Unwrap() error { return e.error }
 
 // errTooManyOpenStreams is used internally by the outgoing streams maps.
 var errTooManyOpenStreams = errors.New("too many open streams")
 
+// errStreamIDsExhausted is returned by the outgoing streams maps when the maximum stream ID has been used.
+var errStreamIDsExhausted = errors.New("stream IDs exhausted")
+
 type streamsMap struct {
 	perspective protocol.Perspective
 
@@ -53,6 +76,12 @@ type streamsMap struct {
 	outgoingUniStreams  *outgoingUniStreamsMap
 	incomingBidiStreams *incomingBidiStreamsMap
 	incomingUniStreams  *incomingUniStreamsMap
+
+	numStreamsMutex sync.Mutex
+	numStreams      int
+	maxNumStreams   int // the highest number of streams that were open at the same time
+
+	onAllStreamsClosed func() // called when the last open stream is deleted
 }
 
 var _ streamManager = &streamsMap{}
@@ -62,25 +91,30 @@ func newStreamsMap(
 	newFlowController func(protocol.StreamID) flowcontrol.StreamFlowController,
 	maxIncomingBidiStreams uint64,
 	maxIncomingUniStreams uint64,
+	onAllStreamsClosed func(),
+	sendBuffer *sendBufferWatermark,
 	perspective protocol.Perspective,
 	version protocol.VersionNumber,
 ) streamManager {
 	m := &streamsMap{
-		perspective:       perspective,
-		newFlowController: newFlowController,
-		sender:            sender,
+		perspective:        perspective,
+		newFlowController:  newFlowController,
+		sender:             sender,
+		onAllStreamsClosed: onAllStreamsClosed,
 	}
 	m.outgoingBidiStreams = newOutgoingBidiStreamsMap(
 		func(num protocol.StreamNum) streamI {
 			id := num.StreamID(protocol.StreamTypeBidi, perspective)
-			return newStream(id, m.sender, m.newFlowController(id), version)
+			m.streamOpened()
+			return newStream(id, m.sender, m.newFlowController(id), sendBuffer, version)
 		},
 		sender.queueControlFrame,
 	)
 	m.incomingBidiStreams = newIncomingBidiStreamsMap(
 		func(num protocol.StreamNum) streamI {
 			id := num.StreamID(protocol.StreamTypeBidi, perspective.Opposite())
-			return newStream(id, m.sender, m.newFlowController(id), version)
+			m.streamOpened()
+			return newStream(id, m.sender, m.newFlowController(id), sendBuffer, version)
 		},
 		maxIncomingBidiStreams,
 		sender.queueControlFrame,
@@ -88,13 +122,15 @@ func newStreamsMap(
 	m.outgoingUniStreams = newOutgoingUniStreamsMap(
 		func(num protocol.StreamNum) sendStreamI {
 			id := num.StreamID(protocol.StreamTypeUni, perspective)
-			return newSendStream(id, m.sender, m.newFlowController(id), version)
+			m.streamOpened()
+			return newSendStream(id, m.sender, m.newFlowController(id), sendBuffer, version)
 		},
 		sender.queueControlFrame,
 	)
 	m.incomingUniStreams = newIncomingUniStreamsMap(
 		func(num protocol.StreamNum) receiveStreamI {
 			id := num.StreamID(protocol.StreamTypeUni, perspective.Opposite())
+			m.streamOpened()
 			return newReceiveStream(id, m.sender, m.newFlowController(id), version)
 		},
 		maxIncomingUniStreams,
@@ -115,7 +151,7 @@ func (m *streamsMap) OpenStreamSync(ctx context.Context) (Stream, error) {
 
 func (m *streamsMap) OpenUniStream() (SendStream, error) {
 	str, err := m.outgoingUniStreams.OpenStream()
-	return str, convertStreamError(err, protocol.StreamTypeBidi, m.perspective)
+	return str, convertStreamError(err, protocol.StreamTypeUni, m.perspective)
 }
 
 func (m *streamsMap) OpenUniStreamSync(ctx context.Context) (SendStream, error) {
@@ -134,15 +170,29 @@ func (m *streamsMap) AcceptUniStream(ctx context.Context) (ReceiveStream, error)
 }
 
 func (m *streamsMap) DeleteStream(id protocol.StreamID) error {
+	if err := m.deleteStream(id); err != nil {
+		return err
+	}
+	m.numStreamsMutex.Lock()
+	m.numStreams--
+	allStreamsClosed := m.numStreams == 0
+	m.numStreamsMutex.Unlock()
+	if allStreamsClosed {
+		m.onAllStreamsClosed()
+	}
+	return nil
+}
+
+func (m *streamsMap) deleteStream(id protocol.StreamID) error {
 	num := id.StreamNum()
 	switch id.Type() {
 	case protocol.StreamTypeUni:
-		if id.InitiatedBy() == m.perspective {
+		if id.IsLocal(m.perspective) {
 			return convertStreamError(m.outgoingUniStreams.DeleteStream(num), protocol.StreamTypeUni, m.perspective)
 		}
 		return convertStreamError(m.incomingUniStreams.DeleteStream(num), protocol.StreamTypeUni, m.perspective.Opposite())
 	case protocol.StreamTypeBidi:
-		if id.InitiatedBy() == m.perspective {
+		if id.IsLocal(m.perspective) {
 			return convertStreamError(m.outgoingBidiStreams.DeleteStream(num), protocol.StreamTypeBidi, m.perspective)
 		}
 		return convertStreamError(m.incomingBidiStreams.DeleteStream(num), protocol.StreamTypeBidi, m.perspective.Opposite())
@@ -150,10 +200,75 @@ func (m *streamsMap) DeleteStream(id protocol.StreamID) error {
 	panic("")
 }
 
+func (m *streamsMap) streamOpened() {
+	m.numStreamsMutex.Lock()
+	m.numStreams++
+	if m.numStreams > m.maxNumStreams {
+		m.maxNumStreams = m.numStreams
+	}
+	m.numStreamsMutex.Unlock()
+}
+
+// NumberOfStreams returns the number of streams that are currently open.
+// Streams of all types, opened by both endpoints, are counted.
+func (m *streamsMap) NumberOfStreams() int {
+	m.numStreamsMutex.Lock()
+	defer m.numStreamsMutex.Unlock()
+	return m.numStreams
+}
+
+// MaxConcurrentStreams returns the highest number of streams that were open at the same time.
+// Streams of all types, opened by both endpoints, are counted.
+func (m *streamsMap) MaxConcurrentStreams() int {
+	m.numStreamsMutex.Lock()
+	defer m.numStreamsMutex.Unlock()
+	return m.maxNumStreams
+}
+
+// FlowControlWindows returns the sum of the stream-level send and receive windows of all open streams.
+func (m *streamsMap) FlowControlWindows() (protocol.ByteCount, protocol.ByteCount) {
+	var sendWindow, receiveWindow protocol.ByteCount
+	for _, str := range m.Snapshot() {
+		sendWindow += str.sendWindowSize()
+		receiveWindow += str.receiveWindowSize()
+	}
+	for _, str := range m.outgoingUniStreams.Snapshot() {
+		sendWindow += str.sendWindowSize()
+	}
+	for _, str := range m.incomingUniStreams.Snapshot() {
+		receiveWindow += str.receiveWindowSize()
+	}
+	return sendWindow, receiveWindow
+}
+
+// NextStreamID returns the ID that will be assigned to the next stream of the given type
+// opened by OpenStream(Sync) or OpenUniStream(Sync).
+func (m *streamsMap) NextStreamID(stype protocol.StreamType) protocol.StreamID {
+	switch stype {
+	case protocol.StreamTypeBidi:
+		return m.outgoingBidiStreams.NextStream().StreamID(stype, m.perspective)
+	case protocol.StreamTypeUni:
+		return m.outgoingUniStreams.NextStream().StreamID(stype, m.perspective)
+	}
+	panic("")
+}
+
+// CanOpenStreams checks if num more streams of the given type can be opened without blocking.
+// If not, a STREAMS_BLOCKED frame is sent to ask the peer for more stream credit.
+func (m *streamsMap) CanOpenStreams(stype protocol.StreamType, num int) bool {
+	switch stype {
+	case protocol.StreamTypeBidi:
+		return m.outgoingBidiStreams.CanOpenStreams(num)
+	case protocol.StreamTypeUni:
+		return m.outgoingUniStreams.CanOpenStreams(num)
+	}
+	panic("")
+}
+
 func (m *streamsMap) GetOrOpenReceiveStream(id protocol.StreamID) (receiveStreamI, error) {
 	str, err := m.getOrOpenReceiveStream(id)
 	if err != nil {
-		return nil, qerr.NewError(qerr.StreamStateError, err.Error())
+		return nil, qerr.NewError(streamErrorCode(err), err.Error())
 	}
 	return str, nil
 }
@@ -162,16 +277,16 @@ func (m *streamsMap) getOrOpenReceiveStream(id protocol.StreamID) (receiveStream
 	num := id.StreamNum()
 	switch id.Type() {
 	case protocol.StreamTypeUni:
-		if id.InitiatedBy() == m.perspective {
+		if id.IsLocal(m.perspective) {
 			// an outgoing unidirectional stream is a send stream, not a receive stream
 			return nil, fmt.Errorf("peer attempted to open receive stream %d", id)
 		}
 		str, err := m.incomingUniStreams.GetOrOpenStream(num)
-		return str, convertStreamError(err, protocol.StreamTypeUni, m.perspective)
+		return str, convertStreamError(err, protocol.StreamTypeUni, m.perspective.Opposite())
 	case protocol.StreamTypeBidi:
 		var str receiveStreamI
 		var err error
-		if id.InitiatedBy() == m.perspective {
+		if id.IsLocal(m.perspective) {
 			str, err = m.outgoingBidiStreams.GetStream(num)
 		} else {
 			str, err = m.incomingBidiStreams.GetOrOpenStream(num)
@@ -184,7 +299,7 @@ func (m *streamsMap) getOrOpenReceiveStream(id protocol.StreamID) (receiveStream
 func (m *streamsMap) GetOrOpenSendStream(id protocol.StreamID) (sendStreamI, error) {
 	str, err := m.getOrOpenSendStream(id)
 	if err != nil {
-		return nil, qerr.NewError(qerr.StreamStateError, err.Error())
+		return nil, qerr.NewError(streamErrorCode(err), err.Error())
 	}
 	return str, nil
 }
@@ -193,7 +308,7 @@ func (m *streamsMap) getOrOpenSendStream(id protocol.StreamID) (sendStreamI, err
 	num := id.StreamNum()
 	switch id.Type() {
 	case protocol.StreamTypeUni:
-		if id.InitiatedBy() == m.perspective {
+		if id.IsLocal(m.perspective) {
 			str, err := m.outgoingUniStreams.GetStream(num)
 			return str, convertStreamError(err, protocol.StreamTypeUni, m.perspective)
 		}
@@ -202,7 +317,7 @@ func (m *streamsMap) getOrOpenSendStream(id protocol.StreamID) (sendStreamI, err
 	case protocol.StreamTypeBidi:
 		var str sendStreamI
 		var err error
-		if id.InitiatedBy() == m.perspective {
+		if id.IsLocal(m.perspective) {
 			str, err = m.outgoingBidiStreams.GetStream(num)
 		} else {
 			str, err = m.incomingBidiStreams.GetOrOpenStream(num)
@@ -227,6 +342,90 @@ func (m *streamsMap) UpdateLimits(p *wire.TransportParameters) {
 	m.outgoingUniStreams.SetMaxStream(p.MaxUniStreamNum)
 }
 
+// Snapshot returns the bidirectional streams that are currently open.
+// The returned slice is a copy, so the caller can iterate over it without blocking
+// concurrent modifications of the map.
+// Unidirectional streams can be obtained from the respective typed maps.
+func (m *streamsMap) Snapshot() []streamI {
+	outgoing := m.outgoingBidiStreams.Snapshot()
+	incoming := m.incomingBidiStreams.Snapshot()
+	return append(outgoing, incoming...)
+}
+
+// IdleStreams returns the bidirectional streams that haven't transferred any data
+// (by calls to Read or Write) for longer than idleTimeout.
+// It doesn't hold the lock while checking the streams, so it can be called by a reaper
+// without blocking concurrent modifications of the map.
+func (m *streamsMap) IdleStreams(now time.Time, idleTimeout time.Duration) []streamI {
+	var idle []streamI
+	for _, str := range m.Snapshot() {
+		if now.Sub(str.lastActivity()) > idleTimeout {
+			idle = append(idle, str)
+		}
+	}
+	return idle
+}
+
+// CloseStreams closes all streams whose stream ID matches.
+// For send streams, closeSend is called, for receive streams, closeReceive is called.
+// Bidirectional streams are both send and receive streams, so both functions are called.
+// The set of streams is determined while holding the lock of the respective map, but the close functions
+// are called after releasing it: closing a stream might complete it, which deletes it from the map.
+func (m *streamsMap) CloseStreams(
+	match func(protocol.StreamID) bool,
+	closeSend func(sendStreamI),
+	closeReceive func(receiveStreamI),
+) {
+	var sendStreams []sendStreamI
+	var receiveStreams []receiveStreamI
+	for _, str := range m.Snapshot() {
+		if match(str.StreamID()) {
+			sendStreams = append(sendStreams, str)
+			receiveStreams = append(receiveStreams, str)
+		}
+	}
+	for _, str := range m.outgoingUniStreams.Snapshot() {
+		if match(str.StreamID()) {
+			sendStreams = append(sendStreams, str)
+		}
+	}
+	for _, str := range m.incomingUniStreams.Snapshot() {
+		if match(str.StreamID()) {
+			receiveStreams = append(receiveStreams, str)
+		}
+	}
+	for _, str := range sendStreams {
+		closeSend(str)
+	}
+	for _, str := range receiveStreams {
+		closeReceive(str)
+	}
+}
+
+// OpenStreamIDs returns the IDs of all streams that are currently open, in ascending order.
+// The returned slice is a copy and can be modified by the caller.
+func (m *streamsMap) OpenStreamIDs() []protocol.StreamID {
+	var ids []protocol.StreamID
+	for _, str := range m.Snapshot() {
+		ids = append(ids, str.StreamID())
+	}
+	for _, str := range m.outgoingUniStreams.Snapshot() {
+		ids = append(ids, str.StreamID())
+	}
+	for _, str := range m.incomingUniStreams.Snapshot() {
+		ids = append(ids, str.StreamID())
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// StopGrantingStreams stops allowing the peer to open new streams, of both types.
+// It is used when the server is shutting down gracefully.
+func (m *streamsMap) StopGrantingStreams() {
+	m.incomingBidiStreams.StopGrantingStreams()
+	m.incomingUniStreams.StopGrantingStreams()
+}
+
 func (m *streamsMap) CloseWithError(err error) {
 	m.outgoingBidiStreams.CloseWithError(err)
 	m.outgoingUniStreams.CloseWithError(err)
@@ -27,6 +27,7 @@ type incomingItemsMap struct {
 	nextStreamToOpen   protocol.StreamNum // the highest stream that the peer opened
 	maxStream          protocol.StreamNum // the highest stream that the peer is allowed to open
 	maxNumStreams      uint64             // maximum number of streams
+	stopGranting       bool               // set when StopGrantingStreams is called
 
 	newStream        func(protocol.StreamNum) item
 	queueMaxStreamID func(*wire.MaxStreamsFrame)
@@ -98,8 +99,9 @@ func (m *incomingItemsMap) GetOrOpenStream(num protocol.StreamNum) (item, error)
 	if num > m.maxStream {
 		m.mutex.RUnlock()
 		return nil, streamError{
-			message: "peer tried to open stream %d (current limit: %d)",
-			nums:    []protocol.StreamNum{num, m.maxStream},
+			message:       "peer tried to open stream %d (current limit: %d)",
+			nums:          []protocol.StreamNum{num, m.maxStream},
+			limitExceeded: true,
 		}
 	}
 	// if the num is smaller than the highest we accepted
@@ -165,7 +167,7 @@ func (m *incomingItemsMap) deleteStream(num protocol.StreamNum) error {
 
 	delete(m.streams, num)
 	// queue a MAX_STREAM_ID frame, giving the peer the option to open a new stream
-	if m.maxNumStreams > uint64(len(m.streams)) {
+	if !m.stopGranting && m.maxNumStreams > uint64(len(m.streams)) {
 		maxStream := m.nextStreamToOpen + protocol.StreamNum(m.maxNumStreams-uint64(len(m.streams))) - 1
 		// Never send a value larger than protocol.MaxStreamCount.
 		if maxStream <= protocol.MaxStreamCount {
@@ -179,6 +181,29 @@ func (m *incomingItemsMap) deleteStream(num protocol.StreamNum) error {
 	return nil
 }
 
+// Snapshot returns the streams that are currently open.
+// Streams that were deleted, but not yet accepted, are not included.
+func (m *incomingItemsMap) Snapshot() []item {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	streams := make([]item, 0, len(m.streams))
+	for _, entry := range m.streams {
+		if !entry.shouldDelete {
+			streams = append(streams, entry.stream)
+		}
+	}
+	return streams
+}
+
+// StopGrantingStreams stops allowing the peer to open new streams.
+// The peer can still open streams up to the current limit.
+func (m *incomingItemsMap) StopGrantingStreams() {
+	m.mutex.Lock()
+	m.stopGranting = true
+	m.mutex.Unlock()
+}
+
 func (m *incomingItemsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
@@ -96,6 +96,7 @@ var _ = Describe("Streams Map (incoming)", func() {
 		_, err := m.GetOrOpenStream(6)
 		Expect(err).To(HaveOccurred())
 		Expect(err.(streamError).TestError()).To(MatchError("peer tried to open stream 6 (current limit: 5)"))
+		Expect(err.(streamError).limitExceeded).To(BeTrue())
 	})
 
 	It("blocks AcceptStream until a new stream is available", func() {
@@ -205,6 +206,24 @@ var _ = Describe("Streams Map (incoming)", func() {
 		Expect(str).ToNot(BeNil())
 	})
 
+	It("returns a snapshot of the open streams", func() {
+		_, err := m.GetOrOpenStream(2)
+		Expect(err).ToNot(HaveOccurred())
+		snapshot := m.Snapshot()
+		Expect(snapshot).To(HaveLen(2))
+		// modifying the map doesn't change the snapshot
+		_, err = m.GetOrOpenStream(3)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(snapshot).To(HaveLen(2))
+		// streams queued for deleting are not included
+		Expect(m.DeleteStream(1)).To(Succeed())
+		nums := make([]protocol.StreamNum, 0, 2)
+		for _, str := range m.Snapshot() {
+			nums = append(nums, str.(*mockGenericStream).num)
+		}
+		Expect(nums).To(ConsistOf(protocol.StreamNum(2), protocol.StreamNum(3)))
+	})
+
 	It("errors when deleting a non-existing stream", func() {
 		err := m.DeleteStream(1337)
 		Expect(err).To(HaveOccurred())
@@ -232,6 +251,21 @@ var _ = Describe("Streams Map (incoming)", func() {
 		Expect(m.DeleteStream(4)).To(Succeed())
 	})
 
+	It("doesn't send MAX_STREAMS frames after StopGrantingStreams was called", func() {
+		_, err := m.GetOrOpenStream(5)
+		Expect(err).ToNot(HaveOccurred())
+		for i := 0; i < 5; i++ {
+			_, err := m.AcceptStream(context.Background())
+			Expect(err).ToNot(HaveOccurred())
+		}
+		m.StopGrantingStreams()
+		// don't EXPECT any calls to queueControlFrame
+		Expect(m.DeleteStream(3)).To(Succeed())
+		_, err = m.GetOrOpenStream(6)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(streamError).TestError()).To(MatchError("peer tried to open stream 6 (current limit: 5)"))
+	})
+
 	Context("using high stream limits", func() {
 		BeforeEach(func() { maxNumStreams = uint64(protocol.MaxStreamCount) - 2 })
 
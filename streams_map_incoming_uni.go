@@ -29,6 +29,7 @@ type incomingUniStreamsMap struct {
 	nextStreamToOpen   protocol.StreamNum // the highest stream that the peer opened
 	maxStream          protocol.StreamNum // the highest stream that the peer is allowed to open
 	maxNumStreams      uint64             // maximum number of streams
+	stopGranting       bool               // set when StopGrantingStreams is called
 
 	newStream        func(protocol.StreamNum) receiveStreamI
 	queueMaxStreamID func(*wire.MaxStreamsFrame)
@@ -100,8 +101,9 @@ func (m *incomingUniStreamsMap) GetOrOpenStream(num protocol.StreamNum) (receive
 	if num > m.maxStream {
 		m.mutex.RUnlock()
 		return nil, streamError{
-			message: "peer tried to open stream %d (current limit: %d)",
-			nums:    []protocol.StreamNum{num, m.maxStream},
+			message:       "peer tried to open stream %d (current limit: %d)",
+			nums:          []protocol.StreamNum{num, m.maxStream},
+			limitExceeded: true,
 		}
 	}
 	// if the num is smaller than the highest we accepted
@@ -167,7 +169,7 @@ func (m *incomingUniStreamsMap) deleteStream(num protocol.StreamNum) error {
 
 	delete(m.streams, num)
 	// queue a MAX_STREAM_ID frame, giving the peer the option to open a new stream
-	if m.maxNumStreams > uint64(len(m.streams)) {
+	if !m.stopGranting && m.maxNumStreams > uint64(len(m.streams)) {
 		maxStream := m.nextStreamToOpen + protocol.StreamNum(m.maxNumStreams-uint64(len(m.streams))) - 1
 		// Never send a value larger than protocol.MaxStreamCount.
 		if maxStream <= protocol.MaxStreamCount {
@@ -181,6 +183,29 @@ func (m *incomingUniStreamsMap) deleteStream(num protocol.StreamNum) error {
 	return nil
 }
 
+// Snapshot returns the streams that are currently open.
+// Streams that were deleted, but not yet accepted, are not included.
+func (m *incomingUniStreamsMap) Snapshot() []receiveStreamI {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	streams := make([]receiveStreamI, 0, len(m.streams))
+	for _, entry := range m.streams {
+		if !entry.shouldDelete {
+			streams = append(streams, entry.stream)
+		}
+	}
+	return streams
+}
+
+// StopGrantingStreams stops allowing the peer to open new streams.
+// The peer can still open streams up to the current limit.
+func (m *incomingUniStreamsMap) StopGrantingStreams() {
+	m.mutex.Lock()
+	m.stopGranting = true
+	m.mutex.Unlock()
+}
+
 func (m *incomingUniStreamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
@@ -50,6 +50,9 @@ func (m *outgoingItemsMap) OpenStream() (item, error) {
 	if m.closeErr != nil {
 		return nil, m.closeErr
 	}
+	if m.nextStream > protocol.MaxStreamCount {
+		return nil, errStreamIDsExhausted
+	}
 
 	// if there are OpenStreamSync calls waiting, return an error here
 	if len(m.openQueue) > 0 || m.nextStream > m.maxStream {
@@ -66,6 +69,9 @@ func (m *outgoingItemsMap) OpenStreamSync(ctx context.Context) (item, error) {
 	if m.closeErr != nil {
 		return nil, m.closeErr
 	}
+	if m.nextStream > protocol.MaxStreamCount {
+		return nil, errStreamIDsExhausted
+	}
 
 	if err := ctx.Err(); err != nil {
 		return nil, err
@@ -90,6 +96,9 @@ func (m *outgoingItemsMap) OpenStreamSync(ctx context.Context) (item, error) {
 		case <-ctx.Done():
 			m.mutex.Lock()
 			delete(m.openQueue, queuePos)
+			// We might have been unblocked at the same time as the context was canceled.
+			// Pass on the wake-up to the next waiting OpenStreamSync call.
+			m.unblockOpenSync()
 			return nil, ctx.Err()
 		case <-waitChan:
 		}
@@ -98,6 +107,11 @@ func (m *outgoingItemsMap) OpenStreamSync(ctx context.Context) (item, error) {
 		if m.closeErr != nil {
 			return nil, m.closeErr
 		}
+		if m.nextStream > protocol.MaxStreamCount {
+			delete(m.openQueue, queuePos)
+			m.unblockOpenSync()
+			return nil, errStreamIDsExhausted
+		}
 		if m.nextStream > m.maxStream {
 			// no stream available. Continue waiting
 			continue
@@ -117,6 +131,24 @@ func (m *outgoingItemsMap) openStream() item {
 	return s
 }
 
+// CanOpenStreams checks if num more streams can be opened without blocking.
+// If the current stream limit doesn't allow that, a STREAMS_BLOCKED frame is queued,
+// asking the peer to increase the limit.
+// No streams are reserved: the result can be invalidated by concurrent calls to OpenStream(Sync).
+func (m *outgoingItemsMap) CanOpenStreams(num int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closeErr != nil || num <= 0 {
+		return false
+	}
+	if m.nextStream-1+protocol.StreamNum(len(m.openQueue)+num) <= m.maxStream {
+		return true
+	}
+	m.maybeSendBlockedFrame()
+	return false
+}
+
 // maybeSendBlockedFrame queues a STREAMS_BLOCKED frame for the current stream offset,
 // if we haven't sent one for this offset yet
 func (m *outgoingItemsMap) maybeSendBlockedFrame() {
@@ -198,6 +230,25 @@ func (m *outgoingItemsMap) unblockOpenSync() {
 	}
 }
 
+// Snapshot returns the streams that are currently open.
+func (m *outgoingItemsMap) Snapshot() []item {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	streams := make([]item, 0, len(m.streams))
+	for _, str := range m.streams {
+		streams = append(streams, str)
+	}
+	return streams
+}
+
+// NextStream returns the number of the stream that will be opened next.
+func (m *outgoingItemsMap) NextStream() protocol.StreamNum {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.nextStream
+}
+
 func (m *outgoingItemsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
@@ -54,6 +54,14 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Expect(str.(*mockGenericStream).num).To(Equal(protocol.StreamNum(2)))
 		})
 
+		It("returns the number of the next stream", func() {
+			Expect(m.NextStream()).To(Equal(protocol.StreamNum(1)))
+			str, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.(*mockGenericStream).num).To(Equal(protocol.StreamNum(1)))
+			Expect(m.NextStream()).To(Equal(protocol.StreamNum(2)))
+		})
+
 		It("doesn't open streams after it has been closed", func() {
 			testErr := errors.New("close")
 			m.CloseWithError(testErr)
@@ -100,6 +108,22 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Expect(err.(streamError).TestError()).To(MatchError("Tried to delete unknown outgoing stream 1"))
 		})
 
+		It("returns a snapshot of the open streams", func() {
+			str1, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			str2, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			snapshot := m.Snapshot()
+			Expect(snapshot).To(ConsistOf(str1, str2))
+			// modifying the map doesn't change the snapshot
+			Expect(m.DeleteStream(1)).To(Succeed())
+			_, err = m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot).To(ConsistOf(str1, str2))
+			Expect(m.Snapshot()).To(HaveLen(2))
+			Expect(m.Snapshot()).ToNot(ContainElement(str1))
+		})
+
 		It("closes all streams when CloseWithError is called", func() {
 			str1, err := m.OpenStream()
 			Expect(err).ToNot(HaveOccurred())
@@ -234,6 +258,39 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Eventually(done3).Should(BeClosed())
 		})
 
+		It("doesn't lose the wake-up when a context is canceled at the same time as the stream limit is raised", func() {
+			mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
+			done := make(chan error, 2)
+			ctx, cancel := context.WithCancel(context.Background())
+			go func() {
+				defer GinkgoRecover()
+				_, err := m.OpenStreamSync(ctx)
+				done <- err
+			}()
+			waitForEnqueued(1)
+			go func() {
+				defer GinkgoRecover()
+				_, err := m.OpenStreamSync(context.Background())
+				done <- err
+			}()
+			waitForEnqueued(2)
+
+			m.mutex.Lock()
+			m.maxStream = 1
+			m.unblockOpenSync() // wakes up the first OpenStreamSync call
+			cancel()
+			m.mutex.Unlock()
+			// No matter which OpenStreamSync call opens it, the stream must be opened.
+			var err error
+			Eventually(done).Should(Receive(&err))
+			if err != nil {
+				Expect(err).To(MatchError(context.Canceled))
+				Eventually(done).Should(Receive(BeNil()))
+			}
+			Expect(m.NextStream()).To(Equal(protocol.StreamNum(2)))
+			m.CloseWithError(errors.New("test done"))
+		})
+
 		It("unblocks multiple OpenStreamSync calls at the same time", func() {
 			mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
 			done := make(chan struct{})
@@ -349,6 +406,45 @@ var _ = Describe("Streams Map (outgoing)", func() {
 			Expect(err.Error()).To(Equal(errTooManyOpenStreams.Error()))
 		})
 
+		It("checks if streams can be opened", func() {
+			m.SetMaxStream(6)
+			_, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(m.CanOpenStreams(5)).To(BeTrue())
+			mockSender.EXPECT().queueControlFrame(gomock.Any()).Do(func(f wire.Frame) {
+				Expect(f.(*wire.StreamsBlockedFrame).StreamLimit).To(BeEquivalentTo(6))
+			})
+			Expect(m.CanOpenStreams(6)).To(BeFalse())
+			// only one STREAMS_BLOCKED frame is sent for the same stream limit
+			Expect(m.CanOpenStreams(6)).To(BeFalse())
+			m.SetMaxStream(7)
+			Expect(m.CanOpenStreams(6)).To(BeTrue())
+		})
+
+		It("rejects non-positive numbers of streams", func() {
+			m.SetMaxStream(6)
+			Expect(m.CanOpenStreams(0)).To(BeFalse())
+			Expect(m.CanOpenStreams(-1)).To(BeFalse())
+		})
+
+		It("errors when the stream IDs are exhausted", func() {
+			m.SetMaxStream(protocol.MaxStreamCount)
+			m.nextStream = protocol.MaxStreamCount
+			str, err := m.OpenStream()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(str.(*mockGenericStream).num).To(Equal(protocol.MaxStreamCount))
+			_, err = m.OpenStream()
+			Expect(err).To(MatchError(errStreamIDsExhausted))
+			_, err = m.OpenStreamSync(context.Background())
+			Expect(err).To(MatchError(errStreamIDsExhausted))
+		})
+
+		It("doesn't allow opening streams after it has been closed", func() {
+			m.SetMaxStream(6)
+			m.CloseWithError(errors.New("close"))
+			Expect(m.CanOpenStreams(1)).To(BeFalse())
+		})
+
 		It("only sends one STREAMS_BLOCKED frame for one stream ID", func() {
 			m.SetMaxStream(1)
 			mockSender.EXPECT().queueControlFrame(gomock.Any()).Do(func(f wire.Frame) {
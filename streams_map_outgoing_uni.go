@@ -52,6 +52,9 @@ func (m *outgoingUniStreamsMap) OpenStream() (sendStreamI, error) {
 	if m.closeErr != nil {
 		return nil, m.closeErr
 	}
+	if m.nextStream > protocol.MaxStreamCount {
+		return nil, errStreamIDsExhausted
+	}
 
 	// if there are OpenStreamSync calls waiting, return an error here
 	if len(m.openQueue) > 0 || m.nextStream > m.maxStream {
@@ -68,6 +71,9 @@ func (m *outgoingUniStreamsMap) OpenStreamSync(ctx context.Context) (sendStreamI
 	if m.closeErr != nil {
 		return nil, m.closeErr
 	}
+	if m.nextStream > protocol.MaxStreamCount {
+		return nil, errStreamIDsExhausted
+	}
 
 	if err := ctx.Err(); err != nil {
 		return nil, err
@@ -92,6 +98,9 @@ func (m *outgoingUniStreamsMap) OpenStreamSync(ctx context.Context) (sendStreamI
 		case <-ctx.Done():
 			m.mutex.Lock()
 			delete(m.openQueue, queuePos)
+			// We might have been unblocked at the same time as the context was canceled.
+			// Pass on the wake-up to the next waiting OpenStreamSync call.
+			m.unblockOpenSync()
 			return nil, ctx.Err()
 		case <-waitChan:
 		}
@@ -100,6 +109,11 @@ func (m *outgoingUniStreamsMap) OpenStreamSync(ctx context.Context) (sendStreamI
 		if m.closeErr != nil {
 			return nil, m.closeErr
 		}
+		if m.nextStream > protocol.MaxStreamCount {
+			delete(m.openQueue, queuePos)
+			m.unblockOpenSync()
+			return nil, errStreamIDsExhausted
+		}
 		if m.nextStream > m.maxStream {
 			// no stream available. Continue waiting
 			continue
@@ -119,6 +133,24 @@ func (m *outgoingUniStreamsMap) openStream() sendStreamI {
 	return s
 }
 
+// CanOpenStreams checks if num more streams can be opened without blocking.
+// If the current stream limit doesn't allow that, a STREAMS_BLOCKED frame is queued,
+// asking the peer to increase the limit.
+// No streams are reserved: the result can be invalidated by concurrent calls to OpenStream(Sync).
+func (m *outgoingUniStreamsMap) CanOpenStreams(num int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closeErr != nil || num <= 0 {
+		return false
+	}
+	if m.nextStream-1+protocol.StreamNum(len(m.openQueue)+num) <= m.maxStream {
+		return true
+	}
+	m.maybeSendBlockedFrame()
+	return false
+}
+
 // maybeSendBlockedFrame queues a STREAMS_BLOCKED frame for the current stream offset,
 // if we haven't sent one for this offset yet
 func (m *outgoingUniStreamsMap) maybeSendBlockedFrame() {
@@ -200,6 +232,25 @@ func (m *outgoingUniStreamsMap) unblockOpenSync() {
 	}
 }
 
+// Snapshot returns the streams that are currently open.
+func (m *outgoingUniStreamsMap) Snapshot() []sendStreamI {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	streams := make([]sendStreamI, 0, len(m.streams))
+	for _, str := range m.streams {
+		streams = append(streams, str)
+	}
+	return streams
+}
+
+// NextStream returns the number of the stream that will be opened next.
+func (m *outgoingUniStreamsMap) NextStream() protocol.StreamNum {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.nextStream
+}
+
 func (m *outgoingUniStreamsMap) CloseWithError(err error) {
 	m.mutex.Lock()
 	m.closeErr = err
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/lucas-clemente/quic-go/internal/flowcontrol"
@@ -69,8 +70,9 @@ var _ = Describe("Streams Map", func() {
 
 		Context(perspective.String(), func() {
 			var (
-				m          *streamsMap
-				mockSender *MockStreamSender
+				m                        *streamsMap
+				mockSender               *MockStreamSender
+				numAllStreamsClosedCalls int
 			)
 
 			const (
@@ -87,7 +89,9 @@ var _ = Describe("Streams Map", func() {
 
 			BeforeEach(func() {
 				mockSender = NewMockStreamSender(mockCtrl)
-				m = newStreamsMap(mockSender, newFlowController, MaxBidiStreamNum, MaxUniStreamNum, perspective, protocol.VersionWhatever).(*streamsMap)
+				numAllStreamsClosedCalls = 0
+				onAllStreamsClosed := func() { numAllStreamsClosedCalls++ }
+				m = newStreamsMap(mockSender, newFlowController, MaxBidiStreamNum, MaxUniStreamNum, onAllStreamsClosed, nil, perspective, protocol.VersionWhatever).(*streamsMap)
 			})
 
 			Context("opening", func() {
@@ -114,6 +118,24 @@ var _ = Describe("Streams Map", func() {
 					Expect(str).To(BeAssignableToTypeOf(&sendStream{}))
 					Expect(str.StreamID()).To(Equal(ids.firstOutgoingUniStream + 4))
 				})
+
+				It("predicts the IDs of the next streams", func() {
+					allowUnlimitedStreams()
+					Expect(m.NextStreamID(protocol.StreamTypeBidi)).To(Equal(ids.firstOutgoingBidiStream))
+					Expect(m.NextStreamID(protocol.StreamTypeUni)).To(Equal(ids.firstOutgoingUniStream))
+					for i := 0; i < 3; i++ {
+						id := m.NextStreamID(protocol.StreamTypeBidi)
+						str, err := m.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(str.StreamID()).To(Equal(id))
+					}
+					uniID := m.NextStreamID(protocol.StreamTypeUni)
+					ustr, err := m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(ustr.StreamID()).To(Equal(uniID))
+					Expect(m.NextStreamID(protocol.StreamTypeBidi)).To(Equal(ids.firstOutgoingBidiStream + 12))
+					Expect(m.NextStreamID(protocol.StreamTypeUni)).To(Equal(ids.firstOutgoingUniStream + 4))
+				})
 			})
 
 			Context("accepting", func() {
@@ -261,7 +283,7 @@ var _ = Describe("Streams Map", func() {
 						Expect(str.StreamID()).To(Equal(ids.firstOutgoingUniStream))
 					})
 
-					It("errors when the peer tries to open a higher outgoing bidirectional stream", func() {
+					It("errors when the peer tries to open a higher outgoing unidirectional stream", func() {
 						id := ids.firstOutgoingUniStream + 5*4
 						_, err := m.GetOrOpenSendStream(id)
 						Expect(err).To(MatchError(fmt.Sprintf("STREAM_STATE_ERROR: peer attempted to open stream %d", id)))
@@ -320,6 +342,277 @@ var _ = Describe("Streams Map", func() {
 				})
 			})
 
+			Context("snapshots", func() {
+				BeforeEach(func() {
+					mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
+					allowUnlimitedStreams()
+				})
+
+				It("returns the bidirectional streams", func() {
+					str1, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					str2, err := m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingUniStream)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.Snapshot()).To(ConsistOf(str1, str2))
+				})
+
+				It("isn't affected by later modifications", func() {
+					str, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					snapshot := m.Snapshot()
+					Expect(m.DeleteStream(str.StreamID())).To(Succeed())
+					_, err = m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(snapshot).To(HaveLen(1))
+					Expect(snapshot[0].StreamID()).To(Equal(ids.firstOutgoingBidiStream))
+					Expect(m.Snapshot()).To(HaveLen(1))
+					Expect(m.Snapshot()[0].StreamID()).To(Equal(ids.firstOutgoingBidiStream + 4))
+				})
+
+				It("sums the flow control windows of all open streams", func() {
+					send, receive := m.FlowControlWindows()
+					Expect(send).To(BeZero())
+					Expect(receive).To(BeZero())
+					bidiStr, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					bidiStr.(*stream).sendStream.flowController.(*mocks.MockStreamFlowController).EXPECT().
+						Windows().Return(protocol.ByteCount(1), protocol.ByteCount(10)).Times(2)
+					uniSendStr, err := m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					uniSendStr.(*sendStream).flowController.(*mocks.MockStreamFlowController).EXPECT().
+						Windows().Return(protocol.ByteCount(100), protocol.ByteCount(1000))
+					uniReceiveStr, err := m.GetOrOpenReceiveStream(ids.firstIncomingUniStream)
+					Expect(err).ToNot(HaveOccurred())
+					uniReceiveStr.(*receiveStream).flowController.(*mocks.MockStreamFlowController).EXPECT().
+						Windows().Return(protocol.ByteCount(10000), protocol.ByteCount(100000))
+					send, receive = m.FlowControlWindows()
+					Expect(send).To(Equal(protocol.ByteCount(101)))
+					Expect(receive).To(Equal(protocol.ByteCount(100010)))
+				})
+
+				It("returns the IDs of all open streams", func() {
+					Expect(m.OpenStreamIDs()).To(BeEmpty())
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingUniStream)
+					Expect(err).ToNot(HaveOccurred())
+					openIDs := m.OpenStreamIDs()
+					Expect(openIDs).To(ConsistOf(
+						ids.firstOutgoingBidiStream,
+						ids.firstOutgoingUniStream,
+						ids.firstIncomingBidiStream,
+						ids.firstIncomingUniStream,
+					))
+					for i := 1; i < len(openIDs); i++ {
+						Expect(openIDs[i]).To(BeNumerically(">", openIDs[i-1]))
+					}
+					// modifying the map doesn't change the returned slice
+					Expect(m.DeleteStream(ids.firstOutgoingUniStream)).To(Succeed())
+					Expect(openIDs).To(HaveLen(4))
+					Expect(m.OpenStreamIDs()).To(HaveLen(3))
+					Expect(m.OpenStreamIDs()).ToNot(ContainElement(ids.firstOutgoingUniStream))
+					// modifying the returned slice doesn't change the map
+					openIDs[0] = 1337
+					Expect(m.OpenStreamIDs()).ToNot(ContainElement(protocol.StreamID(1337)))
+				})
+			})
+
+			Context("idle streams", func() {
+				BeforeEach(func() {
+					allowUnlimitedStreams()
+				})
+
+				It("returns the streams that have been idle for longer than the timeout", func() {
+					now := time.Now()
+					str1, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					str2, err := m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					str3, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					str1.(*stream).lastActivityTime = now.Add(-2 * time.Minute)
+					str2.(*stream).lastActivityTime = now.Add(-time.Minute - time.Nanosecond)
+					str3.(*stream).lastActivityTime = now.Add(-time.Minute)
+					Expect(m.IdleStreams(now, time.Minute)).To(ConsistOf(str1, str2))
+				})
+
+				It("doesn't return streams that are active", func() {
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.IdleStreams(time.Now(), time.Minute)).To(BeEmpty())
+				})
+			})
+
+			Context("closing streams", func() {
+				var sendIDs, receiveIDs []protocol.StreamID
+
+				closeSend := func(str sendStreamI) { sendIDs = append(sendIDs, str.StreamID()) }
+				closeReceive := func(str receiveStreamI) { receiveIDs = append(receiveIDs, str.StreamID()) }
+
+				BeforeEach(func() {
+					sendIDs = nil
+					receiveIDs = nil
+					mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
+					allowUnlimitedStreams()
+					for i := 0; i < 2; i++ {
+						_, err := m.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						_, err = m.OpenUniStream()
+						Expect(err).ToNot(HaveOccurred())
+					}
+					_, err := m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream + 4)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingUniStream + 4)
+					Expect(err).ToNot(HaveOccurred())
+				})
+
+				It("closes bidirectional streams", func() {
+					m.CloseStreams(
+						func(id protocol.StreamID) bool { return id.Type() == protocol.StreamTypeBidi },
+						closeSend,
+						closeReceive,
+					)
+					bidiIDs := []protocol.StreamID{
+						ids.firstOutgoingBidiStream,
+						ids.firstOutgoingBidiStream + 4,
+						ids.firstIncomingBidiStream,
+						ids.firstIncomingBidiStream + 4,
+					}
+					Expect(sendIDs).To(ConsistOf(bidiIDs))
+					Expect(receiveIDs).To(ConsistOf(bidiIDs))
+				})
+
+				It("closes unidirectional streams", func() {
+					m.CloseStreams(
+						func(id protocol.StreamID) bool { return id.Type() == protocol.StreamTypeUni },
+						closeSend,
+						closeReceive,
+					)
+					Expect(sendIDs).To(ConsistOf(ids.firstOutgoingUniStream, ids.firstOutgoingUniStream+4))
+					Expect(receiveIDs).To(ConsistOf(ids.firstIncomingUniStream, ids.firstIncomingUniStream+4))
+				})
+
+				It("closes streams in an ID range", func() {
+					m.CloseStreams(
+						func(id protocol.StreamID) bool { return id >= 4 },
+						closeSend,
+						closeReceive,
+					)
+					Expect(sendIDs).To(ConsistOf(ids.firstOutgoingBidiStream+4, ids.firstIncomingBidiStream+4, ids.firstOutgoingUniStream+4))
+					Expect(receiveIDs).To(ConsistOf(ids.firstOutgoingBidiStream+4, ids.firstIncomingBidiStream+4, ids.firstIncomingUniStream+4))
+				})
+
+				It("allows deleting streams when closing them", func() {
+					m.CloseStreams(
+						func(protocol.StreamID) bool { return true },
+						func(str sendStreamI) { Expect(m.DeleteStream(str.StreamID())).To(Succeed()) },
+						func(receiveStreamI) {},
+					)
+					for i := 0; i < 2; i++ {
+						str, err := m.GetOrOpenSendStream(ids.firstOutgoingBidiStream + protocol.StreamID(4*i))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(str).To(BeNil())
+					}
+				})
+			})
+
+			Context("counting concurrent streams", func() {
+				BeforeEach(func() {
+					mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
+					allowUnlimitedStreams()
+				})
+
+				It("counts streams of all types", func() {
+					Expect(m.MaxConcurrentStreams()).To(BeZero())
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					// opening this stream implicitly opens the stream with the lower stream number
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingUniStream + 4)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.MaxConcurrentStreams()).To(Equal(5))
+				})
+
+				It("tracks the peak, not the current number of streams", func() {
+					for i := 0; i < 3; i++ {
+						_, err := m.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+					}
+					Expect(m.MaxConcurrentStreams()).To(Equal(3))
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream)).To(Succeed())
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream + 4)).To(Succeed())
+					Expect(m.MaxConcurrentStreams()).To(Equal(3))
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.MaxConcurrentStreams()).To(Equal(3))
+					// now 2 streams are open
+					for i := 0; i < 2; i++ {
+						_, err := m.OpenUniStream()
+						Expect(err).ToNot(HaveOccurred())
+					}
+					Expect(m.MaxConcurrentStreams()).To(Equal(4))
+				})
+
+				It("counts the number of open streams", func() {
+					Expect(m.NumberOfStreams()).To(BeZero())
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.NumberOfStreams()).To(Equal(3))
+					Expect(m.DeleteStream(ids.firstOutgoingUniStream)).To(Succeed())
+					Expect(m.NumberOfStreams()).To(Equal(2))
+				})
+
+				It("notifies when the last stream is closed", func() {
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(ids.firstIncomingUniStream)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream)).To(Succeed())
+					Expect(numAllStreamsClosedCalls).To(BeZero())
+					Expect(m.DeleteStream(ids.firstIncomingUniStream)).To(Succeed())
+					Expect(numAllStreamsClosedCalls).To(Equal(1))
+					// notifies again when all streams opened later are closed
+					_, err = m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.DeleteStream(ids.firstOutgoingUniStream)).To(Succeed())
+					Expect(numAllStreamsClosedCalls).To(Equal(2))
+				})
+
+				It("doesn't notify when deleting a stream fails", func() {
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream)).ToNot(Succeed())
+					Expect(numAllStreamsClosedCalls).To(BeZero())
+				})
+
+				It("doesn't count failed deletions", func() {
+					_, err := m.OpenStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream)).To(Succeed())
+					Expect(m.DeleteStream(ids.firstOutgoingBidiStream)).ToNot(Succeed())
+					for i := 0; i < 2; i++ {
+						_, err := m.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+					}
+					Expect(m.MaxConcurrentStreams()).To(Equal(2))
+				})
+			})
+
 			Context("updating stream ID limits", func() {
 				for _, p := range []protocol.Perspective{protocol.PerspectiveClient, protocol.PerspectiveServer} {
 					pers := p
@@ -355,6 +648,64 @@ var _ = Describe("Streams Map", func() {
 				}
 			})
 
+			Context("counting streams per type", func() {
+				BeforeEach(func() {
+					mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
+				})
+
+				It("enforces the limits for incoming bidirectional and unidirectional streams separately", func() {
+					lastBidi := ids.firstIncomingBidiStream + 4*(MaxBidiStreamNum-1)
+					lastUni := ids.firstIncomingUniStream + 4*(MaxUniStreamNum-1)
+					_, err := m.GetOrOpenReceiveStream(lastBidi)
+					Expect(err).ToNot(HaveOccurred())
+					// opening bidirectional streams doesn't count towards the limit for unidirectional streams
+					_, err = m.GetOrOpenReceiveStream(lastUni)
+					Expect(err).ToNot(HaveOccurred())
+					_, err = m.GetOrOpenReceiveStream(lastBidi + 4)
+					Expect(err).To(MatchError(fmt.Sprintf("STREAM_LIMIT_ERROR: peer tried to open stream %d (current limit: %d)", lastBidi+4, lastBidi)))
+					_, err = m.GetOrOpenReceiveStream(lastUni + 4)
+					Expect(err).To(MatchError(fmt.Sprintf("STREAM_LIMIT_ERROR: peer tried to open stream %d (current limit: %d)", lastUni+4, lastUni)))
+				})
+
+				It("enforces the limits for outgoing bidirectional and unidirectional streams separately", func() {
+					Expect(m.HandleMaxStreamsFrame(&wire.MaxStreamsFrame{
+						Type:         protocol.StreamTypeBidi,
+						MaxStreamNum: 2,
+					})).To(Succeed())
+					_, err := m.OpenUniStream()
+					expectTooManyStreamsError(err)
+					Expect(m.HandleMaxStreamsFrame(&wire.MaxStreamsFrame{
+						Type:         protocol.StreamTypeUni,
+						MaxStreamNum: 1,
+					})).To(Succeed())
+					str, err := m.OpenUniStream()
+					Expect(err).ToNot(HaveOccurred())
+					Expect(str.StreamID()).To(Equal(ids.firstOutgoingUniStream))
+					// opening a unidirectional stream doesn't count towards the limit for bidirectional streams
+					for i := 0; i < 2; i++ {
+						str, err := m.OpenStream()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(str.StreamID()).To(Equal(ids.firstOutgoingBidiStream + protocol.StreamID(4*i)))
+					}
+					_, err = m.OpenStream()
+					expectTooManyStreamsError(err)
+					_, err = m.OpenUniStream()
+					expectTooManyStreamsError(err)
+				})
+
+				It("rejects stream IDs that don't match the stream type and the initiator", func() {
+					// the peer can't open unidirectional streams that we would have to initiate
+					_, err := m.GetOrOpenReceiveStream(ids.firstOutgoingUniStream + 4)
+					Expect(err).To(MatchError(fmt.Sprintf("STREAM_STATE_ERROR: peer attempted to open receive stream %d", ids.firstOutgoingUniStream+4)))
+					// ... nor send on unidirectional streams it initiated
+					_, err = m.GetOrOpenSendStream(ids.firstIncomingUniStream + 4)
+					Expect(err).To(MatchError(fmt.Sprintf("STREAM_STATE_ERROR: peer attempted to open send stream %d", ids.firstIncomingUniStream+4)))
+					// ... nor open bidirectional streams that we haven't opened yet
+					_, err = m.GetOrOpenReceiveStream(ids.firstOutgoingBidiStream)
+					Expect(err).To(MatchError(fmt.Sprintf("STREAM_STATE_ERROR: peer attempted to open stream %d", ids.firstOutgoingBidiStream)))
+				})
+			})
+
 			Context("handling MAX_STREAMS frames", func() {
 				BeforeEach(func() {
 					mockSender.EXPECT().queueControlFrame(gomock.Any()).AnyTimes()
@@ -389,6 +740,34 @@ var _ = Describe("Streams Map", func() {
 				})
 			})
 
+			Context("checking if streams can be opened", func() {
+				It("checks bidirectional streams", func() {
+					Expect(m.HandleMaxStreamsFrame(&wire.MaxStreamsFrame{
+						Type:         protocol.StreamTypeBidi,
+						MaxStreamNum: 3,
+					})).To(Succeed())
+					Expect(m.CanOpenStreams(protocol.StreamTypeBidi, 3)).To(BeTrue())
+					mockSender.EXPECT().queueControlFrame(&wire.StreamsBlockedFrame{
+						Type:        protocol.StreamTypeBidi,
+						StreamLimit: 3,
+					})
+					Expect(m.CanOpenStreams(protocol.StreamTypeBidi, 4)).To(BeFalse())
+				})
+
+				It("checks unidirectional streams", func() {
+					Expect(m.HandleMaxStreamsFrame(&wire.MaxStreamsFrame{
+						Type:         protocol.StreamTypeUni,
+						MaxStreamNum: 3,
+					})).To(Succeed())
+					Expect(m.CanOpenStreams(protocol.StreamTypeUni, 3)).To(BeTrue())
+					mockSender.EXPECT().queueControlFrame(&wire.StreamsBlockedFrame{
+						Type:        protocol.StreamTypeUni,
+						StreamLimit: 3,
+					})
+					Expect(m.CanOpenStreams(protocol.StreamTypeUni, 4)).To(BeFalse())
+				})
+			})
+
 			Context("sending MAX_STREAMS frames", func() {
 				It("sends a MAX_STREAMS frame for bidirectional streams", func() {
 					_, err := m.GetOrOpenReceiveStream(ids.firstIncomingBidiStream)
@@ -0,0 +1,132 @@
+package webtransport
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+// Dialer dials WebTransport sessions.
+// Sessions to the same host share a single QUIC session.
+type Dialer struct {
+	// TLSClientConfig specifies the TLS configuration to use.
+	// If nil, the default configuration is used.
+	TLSClientConfig *tls.Config
+
+	// QuicConfig is the quic.Config used for dialing new QUIC sessions.
+	// If nil, reasonable default values will be used.
+	QuicConfig *quic.Config
+
+	// StreamReorderingTimeout is the time that streams are held,
+	// if they arrive before the response to the CONNECT request of their session was received.
+	// If zero, a default value of 5 seconds is used.
+	StreamReorderingTimeout time.Duration
+
+	initOnce     sync.Once
+	roundTripper *http3.RoundTripper
+	manager      *sessionManager
+}
+
+func (d *Dialer) initialize() {
+	d.initOnce.Do(func() {
+		timeout := d.StreamReorderingTimeout
+		if timeout == 0 {
+			timeout = defaultStreamReorderingTimeout
+		}
+		d.manager = newSessionManager(timeout)
+		d.roundTripper = &http3.RoundTripper{
+			TLSClientConfig:    d.TLSClientConfig,
+			QuicConfig:         d.QuicConfig,
+			DisableCompression: true,
+			EnableDatagrams:    true,
+			AdditionalSettings: map[uint64]uint64{settingsEnableWebtransport: 1},
+			StreamHijacker:     d.manager.HandleStream,
+			UniStreamHijacker:  d.manager.HandleUniStream,
+		}
+	})
+}
+
+// Dial establishes a new WebTransport session, using an extended CONNECT request to urlStr.
+// The context is only used while establishing the session.
+// If the server doesn't respond with a 2xx status code, the response is returned along with an error.
+func (d *Dialer) Dial(ctx context.Context, urlStr string, reqHdr http.Header) (*http.Response, *Session, error) {
+	d.initialize()
+
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reqHdr == nil {
+		reqHdr = http.Header{}
+	}
+	reqHdr.Set(versionHeader, "1")
+	// The request body is never written to.
+	// It keeps the CONNECT stream open until the session is closed.
+	pr, pw := io.Pipe()
+	// The request context must not be cancelled when ctx is cancelled after the session was established,
+	// since that would reset the CONNECT stream.
+	reqCtx, cancel := context.WithCancel(context.Background())
+	req := (&http.Request{
+		Method: http.MethodConnect,
+		Proto:  protocolHeader,
+		URL:    u,
+		Host:   u.Host,
+		Header: reqHdr,
+		Body:   pr,
+	}).WithContext(reqCtx)
+
+	dialDone := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-dialDone:
+		}
+	}()
+	rsp, err := d.roundTripper.RoundTrip(req)
+	close(dialDone)
+	if err != nil {
+		cancel()
+		pw.Close()
+		return nil, nil, err
+	}
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		pw.Close()
+		rsp.Body.Close()
+		return rsp, nil, fmt.Errorf("webtransport: received status %d", rsp.StatusCode)
+	}
+	streamer, ok := rsp.Body.(http3.DataStreamer)
+	if !ok {
+		cancel()
+		return rsp, nil, errors.New("webtransport: response body doesn't implement http3.DataStreamer")
+	}
+	hijacker, ok := rsp.Body.(http3.Hijacker)
+	if !ok {
+		cancel()
+		return rsp, nil, errors.New("webtransport: response body doesn't implement http3.Hijacker")
+	}
+	str := streamer.DataStream()
+	qsess := hijacker.Session()
+	id := sessionID(str.StreamID())
+	sess := newSession(id, qsess, str, func() {
+		pw.Close()
+		rsp.Body.Close()
+	})
+	d.manager.AddSession(qsess, id, sess)
+	return rsp, sess, nil
+}
+
+// Close closes all QUIC sessions, terminating all WebTransport sessions.
+func (d *Dialer) Close() error {
+	d.initialize()
+	return d.roundTripper.Close()
+}
@@ -0,0 +1,105 @@
+package webtransport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+)
+
+// Server is a WebTransport server.
+// It configures the HTTP/3 server to handle WebTransport streams and datagrams.
+// WebTransport sessions are established by calling Upgrade from the HTTP handler.
+type Server struct {
+	H3 http3.Server
+
+	// StreamReorderingTimeout is the time that streams are held,
+	// if they arrive before the CONNECT request of their session was handled.
+	// If zero, a default value of 5 seconds is used.
+	StreamReorderingTimeout time.Duration
+
+	initOnce sync.Once
+	manager  *sessionManager
+}
+
+func (s *Server) initialize() {
+	s.initOnce.Do(func() {
+		timeout := s.StreamReorderingTimeout
+		if timeout == 0 {
+			timeout = defaultStreamReorderingTimeout
+		}
+		s.manager = newSessionManager(timeout)
+		s.H3.EnableDatagrams = true
+		if s.H3.AdditionalSettings == nil {
+			s.H3.AdditionalSettings = make(map[uint64]uint64)
+		}
+		s.H3.AdditionalSettings[settingsEnableWebtransport] = 1
+		s.H3.StreamHijacker = s.manager.HandleStream
+		s.H3.UniStreamHijacker = s.manager.HandleUniStream
+	})
+}
+
+// Serve serves WebTransport (and HTTP/3) on an existing UDP connection.
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.initialize()
+	return s.H3.Serve(conn)
+}
+
+// ListenAndServe listens on the UDP address s.H3.Addr, and serves WebTransport (and HTTP/3).
+func (s *Server) ListenAndServe() error {
+	s.initialize()
+	return s.H3.ListenAndServe()
+}
+
+// ListenAndServeTLS listens on the UDP address s.H3.Addr, and serves WebTransport (and HTTP/3).
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	s.initialize()
+	return s.H3.ListenAndServeTLS(certFile, keyFile)
+}
+
+// Close closes the server immediately.
+func (s *Server) Close() error {
+	return s.H3.Close()
+}
+
+// Upgrade establishes a WebTransport session for an extended CONNECT request.
+// It must be called from the HTTP handler, before anything is written to the http.ResponseWriter.
+// On success, it responds with a 200 status code.
+// The WebTransport session stays open after the handler returns.
+func (s *Server) Upgrade(w http.ResponseWriter, r *http.Request) (*Session, error) {
+	s.initialize()
+	if r.Method != http.MethodConnect {
+		return nil, fmt.Errorf("expected CONNECT request, got %s", r.Method)
+	}
+	if r.Proto != protocolHeader {
+		return nil, fmt.Errorf("unexpected protocol: %s", r.Proto)
+	}
+	if r.Header.Get(versionHeader) != "1" {
+		return nil, fmt.Errorf("missing or invalid %s header", versionHeader)
+	}
+	streamer, ok := w.(http3.DataStreamer)
+	if !ok {
+		return nil, errors.New("webtransport: response writer doesn't implement http3.DataStreamer")
+	}
+	hijacker, ok := w.(http3.Hijacker)
+	if !ok {
+		return nil, errors.New("webtransport: response writer doesn't implement http3.Hijacker")
+	}
+
+	w.Header().Set(versionHeader, "1")
+	w.WriteHeader(http.StatusOK)
+	str := streamer.DataStream()
+	qsess := hijacker.Session()
+	id := sessionID(str.StreamID())
+	sess := newSession(id, qsess, str, func() {
+		str.Close()
+		str.CancelRead(quic.ErrorCode(errorNoError))
+	})
+	s.manager.AddSession(qsess, id, sess)
+	return sess, nil
+}
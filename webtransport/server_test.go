@@ -0,0 +1,58 @@
+package webtransport
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var s *Server
+
+	BeforeEach(func() {
+		s = &Server{}
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodConnect, "https://localhost:443/", nil)
+		req.Proto = protocolHeader
+		req.Header.Set(versionHeader, "1")
+		return req
+	}
+
+	It("configures the HTTP/3 server", func() {
+		s.initialize()
+		Expect(s.H3.EnableDatagrams).To(BeTrue())
+		Expect(s.H3.AdditionalSettings).To(HaveKeyWithValue(uint64(settingsEnableWebtransport), uint64(1)))
+		Expect(s.H3.StreamHijacker).ToNot(BeNil())
+		Expect(s.H3.UniStreamHijacker).ToNot(BeNil())
+	})
+
+	It("rejects requests that don't use the CONNECT method", func() {
+		req := newRequest()
+		req.Method = http.MethodGet
+		_, err := s.Upgrade(httptest.NewRecorder(), req)
+		Expect(err).To(MatchError("expected CONNECT request, got GET"))
+	})
+
+	It("rejects requests for other protocols", func() {
+		req := newRequest()
+		req.Proto = "HTTP/3"
+		_, err := s.Upgrade(httptest.NewRecorder(), req)
+		Expect(err).To(MatchError("unexpected protocol: HTTP/3"))
+	})
+
+	It("rejects requests without the version header", func() {
+		req := newRequest()
+		req.Header.Del(versionHeader)
+		_, err := s.Upgrade(httptest.NewRecorder(), req)
+		Expect(err).To(MatchError("missing or invalid Sec-Webtransport-Http3-Draft02 header"))
+	})
+
+	It("errors if the response writer is not an HTTP/3 response writer", func() {
+		_, err := s.Upgrade(httptest.NewRecorder(), newRequest())
+		Expect(err).To(MatchError("webtransport: response writer doesn't implement http3.DataStreamer"))
+	})
+})
@@ -0,0 +1,258 @@
+package webtransport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+// See https://www.ietf.org/archive/id/draft-ietf-webtrans-http3-02.html.
+const (
+	protocolHeader = "webtransport"
+	versionHeader  = "Sec-Webtransport-Http3-Draft02"
+
+	settingsEnableWebtransport = 0x2b603742
+	webTransportFrameType      = 0x41
+	webTransportUniStreamType  = 0x54
+
+	errorNoError                = 0x100 // H3_NO_ERROR
+	errorBufferedStreamRejected = 0x3994bd84
+)
+
+// the number of datagrams that are queued for a session, before new datagrams are dropped
+const datagramQueueLen = 32
+
+var errSessionClosed = errors.New("webtransport: session closed")
+
+// The session ID is the stream ID of the CONNECT request stream.
+type sessionID quic.StreamID
+
+// A Session is a WebTransport session.
+// All streams and datagrams of a session are sent on the QUIC session that carries the CONNECT request.
+// The session is terminated when the CONNECT request stream is closed.
+type Session struct {
+	sessionID  sessionID
+	qsess      quic.Session
+	requestStr quic.Stream
+	closeStr   func() // closes the CONNECT request stream
+
+	streamHdr    []byte
+	uniStreamHdr []byte
+	datagramHdr  []byte
+
+	acceptQueue    chan quic.Stream
+	uniAcceptQueue chan quic.ReceiveStream
+	datagrams      chan []byte
+
+	closeOnce sync.Once
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func newSession(id sessionID, qsess quic.Session, requestStr quic.Stream, closeStr func()) *Session {
+	ctx, cancel := context.WithCancel(qsess.Context())
+	s := &Session{
+		sessionID:      id,
+		qsess:          qsess,
+		requestStr:     requestStr,
+		closeStr:       closeStr,
+		acceptQueue:    make(chan quic.Stream),
+		uniAcceptQueue: make(chan quic.ReceiveStream),
+		datagrams:      make(chan []byte, datagramQueueLen),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+	buf := &bytes.Buffer{}
+	quicvarint.Write(buf, webTransportFrameType)
+	quicvarint.Write(buf, uint64(id))
+	s.streamHdr = buf.Bytes()
+	buf = &bytes.Buffer{}
+	quicvarint.Write(buf, webTransportUniStreamType)
+	quicvarint.Write(buf, uint64(id))
+	s.uniStreamHdr = buf.Bytes()
+	buf = &bytes.Buffer{}
+	quicvarint.Write(buf, uint64(id)/4) // the Quarter Stream ID
+	s.datagramHdr = buf.Bytes()
+
+	go s.handleRequestStream()
+	return s
+}
+
+// handleRequestStream reads from the CONNECT request stream.
+// No data is sent on this stream, it is only used to signal the end of the session.
+func (s *Session) handleRequestStream() {
+	io.Copy(ioutil.Discard, s.requestStr)
+	s.closeOnce.Do(s.closeStr)
+	s.cancel()
+}
+
+func (s *Session) addStream(str quic.Stream) {
+	select {
+	case s.acceptQueue <- str:
+	case <-s.ctx.Done():
+		str.CancelRead(quic.ErrorCode(errorBufferedStreamRejected))
+		str.CancelWrite(quic.ErrorCode(errorBufferedStreamRejected))
+	}
+}
+
+func (s *Session) addUniStream(str quic.ReceiveStream) {
+	select {
+	case s.uniAcceptQueue <- str:
+	case <-s.ctx.Done():
+		str.CancelRead(quic.ErrorCode(errorBufferedStreamRejected))
+	}
+}
+
+func (s *Session) handleDatagram(data []byte) {
+	select {
+	case s.datagrams <- data:
+	default:
+		// The application isn't reading the datagrams fast enough. Drop it.
+	}
+}
+
+// AcceptStream returns the next bidirectional stream opened by the peer, blocking until one is available.
+func (s *Session) AcceptStream(ctx context.Context) (quic.Stream, error) {
+	select {
+	case str := <-s.acceptQueue:
+		return str, nil
+	case <-s.ctx.Done():
+		return nil, errSessionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// AcceptUniStream returns the next unidirectional stream opened by the peer, blocking until one is available.
+func (s *Session) AcceptUniStream(ctx context.Context) (quic.ReceiveStream, error) {
+	select {
+	case str := <-s.uniAcceptQueue:
+		return str, nil
+	case <-s.ctx.Done():
+		return nil, errSessionClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// OpenStream opens a new bidirectional stream.
+func (s *Session) OpenStream() (quic.Stream, error) {
+	if s.ctx.Err() != nil {
+		return nil, errSessionClosed
+	}
+	str, err := s.qsess.OpenStream()
+	if err != nil {
+		return nil, err
+	}
+	return s.initStream(str)
+}
+
+// OpenStreamSync opens a new bidirectional stream.
+// It blocks until a new stream can be opened.
+func (s *Session) OpenStreamSync(ctx context.Context) (quic.Stream, error) {
+	if s.ctx.Err() != nil {
+		return nil, errSessionClosed
+	}
+	str, err := s.qsess.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.initStream(str)
+}
+
+// initStream writes the stream header, which associates the stream with this session.
+func (s *Session) initStream(str quic.Stream) (quic.Stream, error) {
+	if _, err := str.Write(s.streamHdr); err != nil {
+		str.CancelRead(quic.ErrorCode(errorNoError))
+		str.CancelWrite(quic.ErrorCode(errorNoError))
+		return nil, err
+	}
+	return str, nil
+}
+
+// OpenUniStream opens a new unidirectional stream.
+func (s *Session) OpenUniStream() (quic.SendStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, errSessionClosed
+	}
+	str, err := s.qsess.OpenUniStream()
+	if err != nil {
+		return nil, err
+	}
+	return s.initUniStream(str)
+}
+
+// OpenUniStreamSync opens a new unidirectional stream.
+// It blocks until a new stream can be opened.
+func (s *Session) OpenUniStreamSync(ctx context.Context) (quic.SendStream, error) {
+	if s.ctx.Err() != nil {
+		return nil, errSessionClosed
+	}
+	str, err := s.qsess.OpenUniStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.initUniStream(str)
+}
+
+func (s *Session) initUniStream(str quic.SendStream) (quic.SendStream, error) {
+	if _, err := str.Write(s.uniStreamHdr); err != nil {
+		str.CancelWrite(quic.ErrorCode(errorNoError))
+		return nil, err
+	}
+	return str, nil
+}
+
+// SendMessage sends a message as a datagram associated with this session.
+func (s *Session) SendMessage(b []byte) error {
+	if s.ctx.Err() != nil {
+		return errSessionClosed
+	}
+	if !s.qsess.ConnectionState().SupportsDatagrams {
+		return errors.New("webtransport: datagram support not negotiated")
+	}
+	data := make([]byte, 0, len(s.datagramHdr)+len(b))
+	data = append(data, s.datagramHdr...)
+	data = append(data, b...)
+	return s.qsess.SendMessage(data)
+}
+
+// ReceiveMessage gets a message received in a datagram associated with this session.
+func (s *Session) ReceiveMessage() ([]byte, error) {
+	select {
+	case data := <-s.datagrams:
+		return data, nil
+	case <-s.ctx.Done():
+		return nil, errSessionClosed
+	}
+}
+
+// Close closes the session, by closing the CONNECT request stream.
+// Streams opened on the session are not affected.
+func (s *Session) Close() error {
+	s.closeOnce.Do(s.closeStr)
+	s.cancel()
+	return nil
+}
+
+// Context returns a context that is cancelled when the session is closed.
+func (s *Session) Context() context.Context {
+	return s.ctx
+}
+
+// LocalAddr returns the local address.
+func (s *Session) LocalAddr() net.Addr {
+	return s.qsess.LocalAddr()
+}
+
+// RemoteAddr returns the address of the peer.
+func (s *Session) RemoteAddr() net.Addr {
+	return s.qsess.RemoteAddr()
+}
@@ -0,0 +1,194 @@
+package webtransport
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/lucas-clemente/quic-go"
+	"github.com/lucas-clemente/quic-go/http3"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+)
+
+const defaultStreamReorderingTimeout = 5 * time.Second
+
+type byteReader struct{ io.Reader }
+
+func (br *byteReader) ReadByte() (byte, error) {
+	b := make([]byte, 1)
+	if _, err := br.Reader.Read(b); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+type sessionEntry struct {
+	created chan struct{} // closed once the session is added
+	session *Session
+}
+
+// The sessionManager dispatches the streams and datagrams received on a QUIC session
+// to the WebTransport sessions they belong to.
+//
+// Streams might arrive before the CONNECT request that established the session was processed.
+// They are held for up to timeout, before they are rejected.
+type sessionManager struct {
+	timeout time.Duration
+
+	mutex sync.Mutex
+	conns map[quic.Session]map[sessionID]*sessionEntry
+}
+
+func newSessionManager(timeout time.Duration) *sessionManager {
+	return &sessionManager{
+		timeout: timeout,
+		conns:   make(map[quic.Session]map[sessionID]*sessionEntry),
+	}
+}
+
+// entry returns the entry for a session, creating it if it doesn't exist yet.
+// It must be called with the mutex held.
+func (m *sessionManager) entry(qsess quic.Session, id sessionID) *sessionEntry {
+	sessions, ok := m.conns[qsess]
+	if !ok {
+		sessions = make(map[sessionID]*sessionEntry)
+		m.conns[qsess] = sessions
+		go m.handleConn(qsess)
+	}
+	entry, ok := sessions[id]
+	if !ok {
+		entry = &sessionEntry{created: make(chan struct{})}
+		sessions[id] = entry
+	}
+	return entry
+}
+
+// handleConn receives datagrams on a QUIC session, and cleans up once the QUIC session is closed.
+func (m *sessionManager) handleConn(qsess quic.Session) {
+	go func() {
+		<-qsess.Context().Done()
+		m.mutex.Lock()
+		delete(m.conns, qsess)
+		m.mutex.Unlock()
+	}()
+
+	// Note: ConnectionState() will block until the handshake is complete (relevant when using 0-RTT).
+	if !qsess.ConnectionState().SupportsDatagrams {
+		return
+	}
+	for {
+		data, err := qsess.ReceiveMessage()
+		if err != nil {
+			return
+		}
+		r := bytes.NewReader(data)
+		quarterStreamID, err := quicvarint.Read(r)
+		if err != nil {
+			continue
+		}
+		m.mutex.Lock()
+		var sess *Session
+		if entry, ok := m.conns[qsess][sessionID(quarterStreamID*4)]; ok {
+			sess = entry.session
+		}
+		m.mutex.Unlock()
+		// Datagrams for unknown sessions are dropped.
+		if sess != nil {
+			sess.handleDatagram(data[len(data)-r.Len():])
+		}
+	}
+}
+
+// getSession returns the session with the given ID.
+// If the session doesn't exist yet, it waits for it to be added, for up to timeout.
+// It returns nil if the session wasn't added in time.
+func (m *sessionManager) getSession(qsess quic.Session, id sessionID) *Session {
+	m.mutex.Lock()
+	entry := m.entry(qsess, id)
+	m.mutex.Unlock()
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+	select {
+	case <-entry.created:
+		return entry.session
+	case <-timer.C:
+	case <-qsess.Context().Done():
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if entry.session == nil {
+		if sessions, ok := m.conns[qsess]; ok && sessions[id] == entry {
+			delete(sessions, id)
+		}
+	}
+	return entry.session
+}
+
+func (m *sessionManager) AddSession(qsess quic.Session, id sessionID, sess *Session) {
+	m.mutex.Lock()
+	entry := m.entry(qsess, id)
+	entry.session = sess
+	close(entry.created)
+	m.mutex.Unlock()
+
+	go func() {
+		<-sess.Context().Done()
+		m.mutex.Lock()
+		if sessions, ok := m.conns[qsess]; ok && sessions[id] == entry {
+			delete(sessions, id)
+		}
+		m.mutex.Unlock()
+	}()
+}
+
+func (m *sessionManager) addStream(qsess quic.Session, id sessionID, str quic.Stream) {
+	sess := m.getSession(qsess, id)
+	if sess == nil {
+		str.CancelRead(quic.ErrorCode(errorBufferedStreamRejected))
+		str.CancelWrite(quic.ErrorCode(errorBufferedStreamRejected))
+		return
+	}
+	sess.addStream(str)
+}
+
+func (m *sessionManager) addUniStream(qsess quic.Session, id sessionID, str quic.ReceiveStream) {
+	sess := m.getSession(qsess, id)
+	if sess == nil {
+		str.CancelRead(quic.ErrorCode(errorBufferedStreamRejected))
+		return
+	}
+	sess.addUniStream(str)
+}
+
+// HandleStream is used as the http3.StreamHijacker.
+// It takes over all bidirectional streams that start with a WEBTRANSPORT_STREAM frame.
+func (m *sessionManager) HandleStream(ft http3.FrameType, qsess quic.Session, str quic.Stream) (bool, error) {
+	if ft != webTransportFrameType {
+		return false, nil
+	}
+	id, err := quicvarint.Read(&byteReader{str})
+	if err != nil {
+		return false, err
+	}
+	go m.addStream(qsess, sessionID(id), str)
+	return true, nil
+}
+
+// HandleUniStream is used as the http3.UniStreamHijacker.
+// It takes over all unidirectional streams of the WebTransport stream type.
+func (m *sessionManager) HandleUniStream(st http3.StreamType, qsess quic.Session, str quic.ReceiveStream) bool {
+	if st != webTransportUniStreamType {
+		return false
+	}
+	go func() {
+		id, err := quicvarint.Read(&byteReader{str})
+		if err != nil {
+			str.CancelRead(quic.ErrorCode(errorNoError))
+			return
+		}
+		m.addUniStream(qsess, sessionID(id), str)
+	}()
+	return true
+}
@@ -0,0 +1,167 @@
+package webtransport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session Manager", func() {
+	const id = 1336
+
+	var (
+		m              *sessionManager
+		qsess          *mockquic.MockEarlySession
+		qsessCancel    context.CancelFunc
+		datagramChan   chan []byte
+		requestStrDone chan struct{}
+	)
+
+	BeforeEach(func() {
+		m = newSessionManager(scaleDuration(50 * time.Millisecond))
+		ctx, cancel := context.WithCancel(context.Background())
+		qsessCancel = cancel
+		datagrams := make(chan []byte, 10)
+		datagramChan = datagrams
+		requestStrDone = make(chan struct{})
+		qsess = mockquic.NewMockEarlySession(mockCtrl)
+		qsess.EXPECT().Context().Return(ctx).AnyTimes()
+		qsess.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: true}).AnyTimes()
+		qsess.EXPECT().ReceiveMessage().DoAndReturn(func() ([]byte, error) {
+			select {
+			case data := <-datagrams:
+				return data, nil
+			case <-ctx.Done():
+				return nil, errors.New("session closed")
+			}
+		}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		close(requestStrDone)
+		qsessCancel()
+		// wait for the session manager to clean up
+		Eventually(func() int {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			return len(m.conns)
+		}).Should(BeZero())
+	})
+
+	newTestSession := func() *Session {
+		done := requestStrDone
+		requestStr := mockquic.NewMockStream(mockCtrl)
+		requestStr.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+			<-done
+			return 0, errors.New("test done")
+		}).AnyTimes()
+		return newSession(id, qsess, requestStr, func() {})
+	}
+
+	newStreamWithHeader := func(sessID uint64) *mockquic.MockStream {
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, sessID)
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().Read(gomock.Any()).DoAndReturn(buf.Read).AnyTimes()
+		return str
+	}
+
+	It("doesn't hijack streams that don't belong to WebTransport", func() {
+		hijacked, err := m.HandleStream(0x1337, qsess, mockquic.NewMockStream(mockCtrl))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hijacked).To(BeFalse())
+		Expect(m.HandleUniStream(0x1337, qsess, mockquic.NewMockStream(mockCtrl))).To(BeFalse())
+	})
+
+	It("dispatches bidirectional streams to the session", func() {
+		sess := newTestSession()
+		m.AddSession(qsess, id, sess)
+		str := newStreamWithHeader(id)
+		hijacked, err := m.HandleStream(webTransportFrameType, qsess, str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hijacked).To(BeTrue())
+		s, err := sess.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+	})
+
+	It("dispatches unidirectional streams to the session", func() {
+		sess := newTestSession()
+		m.AddSession(qsess, id, sess)
+		str := newStreamWithHeader(id)
+		Expect(m.HandleUniStream(webTransportUniStreamType, qsess, str)).To(BeTrue())
+		s, err := sess.AcceptUniStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+	})
+
+	It("holds streams that arrive before the session is added", func() {
+		str := newStreamWithHeader(id)
+		hijacked, err := m.HandleStream(webTransportFrameType, qsess, str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hijacked).To(BeTrue())
+		time.Sleep(scaleDuration(10 * time.Millisecond))
+		sess := newTestSession()
+		m.AddSession(qsess, id, sess)
+		s, err := sess.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+	})
+
+	It("rejects streams if the session isn't added in time", func() {
+		str := newStreamWithHeader(id)
+		done := make(chan struct{})
+		str.EXPECT().CancelRead(quic.ErrorCode(errorBufferedStreamRejected))
+		str.EXPECT().CancelWrite(quic.ErrorCode(errorBufferedStreamRejected)).Do(func(quic.ErrorCode) { close(done) })
+		hijacked, err := m.HandleStream(webTransportFrameType, qsess, str)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(hijacked).To(BeTrue())
+		Eventually(done).Should(BeClosed())
+	})
+
+	It("dispatches datagrams to the session", func() {
+		sess := newTestSession()
+		m.AddSession(qsess, id, sess)
+		buf := &bytes.Buffer{}
+		quicvarint.Write(buf, 42) // a different session
+		buf.Write([]byte("foo"))
+		datagramChan <- buf.Bytes()
+		buf = &bytes.Buffer{}
+		quicvarint.Write(buf, id/4)
+		buf.Write([]byte("bar"))
+		datagramChan <- buf.Bytes()
+		data, err := sess.ReceiveMessage()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("bar")))
+	})
+
+	It("removes sessions when they are closed", func() {
+		sess := newTestSession()
+		m.AddSession(qsess, id, sess)
+		Expect(sess.Close()).To(Succeed())
+		Eventually(func() int {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			return len(m.conns[qsess])
+		}).Should(BeZero())
+	})
+
+	It("removes QUIC sessions when they are closed", func() {
+		m.AddSession(qsess, id, newTestSession())
+		qsessCancel()
+		Eventually(func() int {
+			m.mutex.Lock()
+			defer m.mutex.Unlock()
+			return len(m.conns)
+		}).Should(BeZero())
+	})
+})
@@ -0,0 +1,198 @@
+package webtransport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/golang/mock/gomock"
+	"github.com/lucas-clemente/quic-go"
+	mockquic "github.com/lucas-clemente/quic-go/internal/mocks/quic"
+	"github.com/lucas-clemente/quic-go/quicvarint"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session", func() {
+	const id = 1336 // the stream ID of the CONNECT request stream
+
+	var (
+		sess          *Session
+		qsess         *mockquic.MockEarlySession
+		requestStr    *mockquic.MockStream
+		closeRequest  chan struct{} // closing this channel makes the CONNECT stream return io.EOF
+		closeStrCalls int
+	)
+
+	BeforeEach(func() {
+		closeReq := make(chan struct{})
+		closeRequest = closeReq
+		closeStrCalls = 0
+		qsess = mockquic.NewMockEarlySession(mockCtrl)
+		qsess.EXPECT().Context().Return(context.Background())
+		requestStr = mockquic.NewMockStream(mockCtrl)
+		requestStr.EXPECT().Read(gomock.Any()).DoAndReturn(func([]byte) (int, error) {
+			<-closeReq
+			return 0, io.EOF
+		}).AnyTimes()
+		sess = newSession(id, qsess, requestStr, func() {
+			closeStrCalls++
+			select {
+			case <-closeReq:
+			default:
+				close(closeReq)
+			}
+		})
+	})
+
+	AfterEach(func() {
+		Expect(sess.Close()).To(Succeed())
+	})
+
+	readHeader := func(b []byte) (uint64, uint64) {
+		r := bytes.NewReader(b)
+		t, err := quicvarint.Read(r)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		id, err := quicvarint.Read(r)
+		ExpectWithOffset(1, err).ToNot(HaveOccurred())
+		ExpectWithOffset(1, r.Len()).To(BeZero())
+		return t, id
+	}
+
+	It("opens bidirectional streams", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		qsess.EXPECT().OpenStream().Return(str, nil)
+		var hdr []byte
+		str.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			hdr = b
+			return len(b), nil
+		})
+		s, err := sess.OpenStream()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+		t, sessID := readHeader(hdr)
+		Expect(t).To(BeEquivalentTo(webTransportFrameType))
+		Expect(sessID).To(BeEquivalentTo(id))
+	})
+
+	It("opens unidirectional streams", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		qsess.EXPECT().OpenUniStreamSync(gomock.Any()).Return(str, nil)
+		var hdr []byte
+		str.EXPECT().Write(gomock.Any()).DoAndReturn(func(b []byte) (int, error) {
+			hdr = b
+			return len(b), nil
+		})
+		s, err := sess.OpenUniStreamSync(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+		t, sessID := readHeader(hdr)
+		Expect(t).To(BeEquivalentTo(webTransportUniStreamType))
+		Expect(sessID).To(BeEquivalentTo(id))
+	})
+
+	It("resets the stream when writing the stream header fails", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		qsess.EXPECT().OpenStreamSync(gomock.Any()).Return(str, nil)
+		str.EXPECT().Write(gomock.Any()).Return(0, errors.New("test error"))
+		str.EXPECT().CancelRead(gomock.Any())
+		str.EXPECT().CancelWrite(gomock.Any())
+		_, err := sess.OpenStreamSync(context.Background())
+		Expect(err).To(MatchError("test error"))
+	})
+
+	It("accepts streams", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		go sess.addStream(str)
+		s, err := sess.AcceptStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+	})
+
+	It("accepts unidirectional streams", func() {
+		str := mockquic.NewMockStream(mockCtrl)
+		go sess.addUniStream(str)
+		s, err := sess.AcceptUniStream(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s).To(Equal(str))
+	})
+
+	It("stops accepting when the context is canceled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := sess.AcceptStream(ctx)
+		Expect(err).To(MatchError(context.Canceled))
+	})
+
+	It("sends datagrams, using the quarter stream ID", func() {
+		qsess.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: true})
+		qsess.EXPECT().SendMessage(gomock.Any()).DoAndReturn(func(b []byte) error {
+			defer GinkgoRecover()
+			r := bytes.NewReader(b)
+			quarterStreamID, err := quicvarint.Read(r)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(quarterStreamID).To(BeEquivalentTo(id / 4))
+			Expect(b[len(b)-r.Len():]).To(Equal([]byte("foobar")))
+			return nil
+		})
+		Expect(sess.SendMessage([]byte("foobar"))).To(Succeed())
+	})
+
+	It("errors when sending datagrams if datagram support wasn't negotiated", func() {
+		qsess.EXPECT().ConnectionState().Return(quic.ConnectionState{SupportsDatagrams: false})
+		Expect(sess.SendMessage([]byte("foobar"))).To(MatchError("webtransport: datagram support not negotiated"))
+	})
+
+	It("receives datagrams", func() {
+		sess.handleDatagram([]byte("foo"))
+		sess.handleDatagram([]byte("bar"))
+		data, err := sess.ReceiveMessage()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("foo")))
+		data, err = sess.ReceiveMessage()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(data).To(Equal([]byte("bar")))
+	})
+
+	It("drops datagrams when the queue is full", func() {
+		for i := 0; i < datagramQueueLen+1; i++ {
+			sess.handleDatagram([]byte{byte(i)})
+		}
+		for i := 0; i < datagramQueueLen; i++ {
+			data, err := sess.ReceiveMessage()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal([]byte{byte(i)}))
+		}
+		Expect(sess.datagrams).To(BeEmpty())
+	})
+
+	It("closes the CONNECT stream when closed", func() {
+		Expect(sess.Close()).To(Succeed())
+		Expect(sess.Context().Done()).To(BeClosed())
+		Expect(closeRequest).To(BeClosed())
+		_, err := sess.AcceptStream(context.Background())
+		Expect(err).To(MatchError("webtransport: session closed"))
+		_, err = sess.OpenStream()
+		Expect(err).To(MatchError("webtransport: session closed"))
+		_, err = sess.ReceiveMessage()
+		Expect(err).To(MatchError("webtransport: session closed"))
+		Expect(sess.SendMessage([]byte("foobar"))).To(MatchError("webtransport: session closed"))
+		Expect(closeStrCalls).To(Equal(1))
+	})
+
+	It("is closed when the peer closes the CONNECT stream", func() {
+		close(closeRequest)
+		Eventually(sess.Context().Done()).Should(BeClosed())
+		Expect(closeStrCalls).To(Equal(1))
+	})
+
+	It("rejects streams after it was closed", func() {
+		Expect(sess.Close()).To(Succeed())
+		str := mockquic.NewMockStream(mockCtrl)
+		str.EXPECT().CancelRead(quic.ErrorCode(errorBufferedStreamRejected))
+		str.EXPECT().CancelWrite(quic.ErrorCode(errorBufferedStreamRejected))
+		sess.addStream(str)
+	})
+})